@@ -17,6 +17,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -29,6 +30,7 @@ import (
 	"github.com/richardlehane/siegfried/internal/chart"
 	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/custom"
 	"github.com/richardlehane/siegfried/pkg/loc"
 	"github.com/richardlehane/siegfried/pkg/mimeinfo"
 	"github.com/richardlehane/siegfried/pkg/pronom"
@@ -102,7 +104,8 @@ Additional flags:
       Useful for inspecting test signatures during development.
       E.g. roy inspect -extend my-groovy-sig.xml dev/1
    -limit, -exclude
-      Limit signatures to a comma-separated list of formats (or sets).
+      Limit signatures to a comma-separated list of formats (or sets, or
+      MIME types).
       Useful for priority graphs.
       E.g. roy inspect -limit @pdfa priorities
    -mi, -loc, -fdd
@@ -126,14 +129,15 @@ var (
 	locfdd        = build.Bool("loc", false, "build a LOC FDD signature file")
 	wikidata      = build.Bool("wikidata", false, "build a Wikidata identifier")
 	wikidataDebug = build.Bool("wikidatadebug", false, "build a Wikidata identifier in debug mode")
+	customDefs    = build.String("custom", "", "build an identifier from a JSON file of user-defined signatures")
 	noPRONOM      = build.Bool("nopronom", false, "don't include PRONOM sigs with LOC or Wikidata signature file")
 	container     = build.String("container", config.Container(), "set name/path for Droid Container signature file")
 	name          = build.String("name", "", "set identifier name")
 	details       = build.String("details", config.Details(), "set identifier details")
 	extend        = build.String("extend", "", "comma separated list of additional signatures")
 	extendc       = build.String("extendc", "", "comma separated list of additional container signatures")
-	include       = build.String("limit", "", "comma separated list of PRONOM signatures to include")
-	exclude       = build.String("exclude", "", "comma separated list of PRONOM signatures to exclude")
+	include       = build.String("limit", "", "comma separated list of formats to include: IDs, sets (e.g. @pdfa or a PRONOM format family such as @dwg) or MIME types e.g. fmt/1,fmt/2,@pdfa,image/tiff")
+	exclude       = build.String("exclude", "", "comma separated list of formats to exclude: IDs, sets (e.g. @pdfa or a PRONOM format family such as @dwg) or MIME types")
 	bof           = build.Int("bof", 0, "define a maximum BOF offset")
 	eof           = build.Int("eof", 0, "define a maximum EOF offset")
 	noeof         = build.Bool("noeof", false, "ignore EOF segments in signatures")
@@ -145,6 +149,7 @@ var (
 	nomime        = build.Bool("nomime", false, "skip MIME matcher")
 	noxml         = build.Bool("noxml", false, "skip XML matcher")
 	noriff        = build.Bool("noriff", false, "skip RIFF matcher")
+	xmlns         = build.String("xmlns", "", "JSON file mapping XML namespaces (or DOCTYPE identifiers) to format IDs e.g. {\"http://www.loc.gov/METS/\": \"fmt/1234\"}")
 	noreports     = build.Bool("noreports", false, "build directly from DROID file rather than PRONOM reports")
 	noclass       = build.Bool("noclass", false, "omit format classes from the signature file")
 	doubleup      = build.Bool("doubleup", false, "include byte signatures for formats that also have container signatures")
@@ -154,6 +159,7 @@ var (
 	cost          = build.Int("cost", config.Cost(), "define a maximum tolerable cost in the worst case for segmentation (overrides distance/range/choices)")
 	repetition    = build.Int("repetition", config.Repetition(), "define a maximum tolerable repetition in a segment, used in combination with cost to determine segmentation")
 	quiet         = build.Bool("quiet", false, "lower verbosity level of logging output when building signatures")
+	index         = build.Bool("index", false, "save an indexed signature file: bigger, as it isn't compressed, but sf memory maps it and decodes it lazily, so starts faster")
 
 	// HARVEST
 	harvest                    = flag.NewFlagSet("harvest", flag.ExitOnError)
@@ -222,6 +228,8 @@ func makegob(s *siegfried.Siegfried, opts []config.Option) error {
 		id, err = loc.New(opts...)
 	} else if *wikidata || *wikidataDebug {
 		id, err = wd.New(opts...)
+	} else if *customDefs != "" {
+		id, err = custom.New(opts...)
 	} else {
 		id, err = pronom.New(opts...)
 	}
@@ -236,6 +244,9 @@ func makegob(s *siegfried.Siegfried, opts []config.Option) error {
 	} else {
 		log.Println("Identifier returned nil, not adding to a Siegfried")
 	}
+	if *index {
+		config.SetIndexed()
+	}
 	return s.Save(config.Signature())
 }
 
@@ -353,6 +364,9 @@ func getOptions() []config.Option {
 	if *wikidataDebug {
 		opts = append(opts, config.SetWikidataDebug())
 	}
+	if *customDefs != "" {
+		opts = append(opts, config.SetCustom(*customDefs))
+	}
 	if *noPRONOM || *inspectNoPRONOM {
 		opts = append(opts, config.SetNoPRONOM())
 		opts = append(opts, config.SetWikidataNoPRONOM())
@@ -414,6 +428,13 @@ the DROID signature file you should also include a regular signature extension
 	if *noriff {
 		opts = append(opts, config.SetNoRIFF())
 	}
+	if *xmlns != "" {
+		m, err := readXMLNamespaces(*xmlns)
+		if err != nil {
+			log.Fatalf("Roy: error reading XML namespace mapping %s: %v", *xmlns, err)
+		}
+		opts = append(opts, config.SetXMLNamespaces(m))
+	}
 	if *noreports {
 		opts = append(opts, config.SetNoReports())
 	}
@@ -484,6 +505,24 @@ the DROID signature file you should also include a regular signature extension
 	return opts
 }
 
+// readXMLNamespaces reads a JSON object that maps XML namespaces (or DOCTYPE public or system identifiers) to format IDs
+func readXMLNamespaces(path string) (map[string]string, error) {
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string)
+	if err = json.Unmarshal(byt, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range m {
+		if k == "" || v == "" {
+			return nil, fmt.Errorf("empty namespace or format ID in mapping %q: %q", k, v)
+		}
+	}
+	return m, nil
+}
+
 func setHarvestOptions() {
 	if *harvestDroid != config.Droid() {
 		config.SetDroid(*harvestDroid)()
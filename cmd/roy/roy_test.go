@@ -156,6 +156,28 @@ func TestPronomTikaLoc(t *testing.T) {
 	}
 }
 
+func TestLimitMIME(t *testing.T) {
+	s := siegfried.New()
+	config.SetHome(*testhome)
+	p, err := pronom.New(config.Clear(), config.SetLimit(sets.Expand("application/pdf,@dwg")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer config.Clear()()
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	fmts := strings.Join(s.Formats()[0], ",")
+	for _, f := range []string{"fmt/18", "fmt/276", "fmt/21"} { // PDF 1.4, PDF 1.7 and AutoCAD Drawing 1.0
+		if !strings.Contains(","+fmts+",", ","+f+",") {
+			t.Errorf("expecting %s in a signature limited to application/pdf and @dwg, got %s", f, fmts)
+		}
+	}
+	if strings.Contains(","+fmts+",", ",x-fmt/111,") {
+		t.Error("expecting plain text to be excluded from a signature limited to application/pdf and @dwg")
+	}
+}
+
 func TestDeluxe(t *testing.T) {
 	s := siegfried.New()
 	config.SetHome(*testhome)
@@ -0,0 +1,219 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/richardlehane/siegfried/internal/checksum"
+)
+
+var bagf = flag.Bool("bag", false, "validate BagIt bags found when walking directories: check payload and tag files against the bag's strongest manifest, reporting bag relative paths and manifest agreement in bagpath and manifest fields, and exit with code 5 if a bag is invalid")
+
+// bags checks the BagIt bags found in a walk; it is nil if the -bag flag isn't given
+var bags *bagChecker
+
+// bagFields are the extra fields reported by -bag
+var bagFields = []string{"bagpath", "manifest"}
+
+// bag manifest algorithms, strongest first
+var bagAlgorithms = []string{"sha512", "sha256", "sha1", "md5"}
+
+// bagChecker finds bags (directories with a bagit.txt file) as they are walked. Files within a bag are hashed, in the same read as
+// they are identified, with the algorithm of the bag's strongest manifest; and compared with the payload manifest (for files under data/)
+// or tag manifest. Bags aren't nested, so a bag within a bag's payload is checked as payload only; and files in bags aren't grouped into sequences (-seq).
+type bagChecker struct {
+	bags  map[string]*bag // by bag root; written and read only by the walker
+	order []*bag
+	field int // index of the bagpath field in the extra fields
+}
+
+type bag struct {
+	root  string
+	alg   string
+	hash  checksum.HashTyp
+	files map[string]*bagEntry // bag relative, slash separated, paths
+	err   error                // an invalid bag declaration or manifest
+	// counts, kept by the printer
+	ok, mismatched, unlisted, missing int
+}
+
+type bagEntry struct {
+	sum  string
+	seen bool
+}
+
+func newBagChecker() *bagChecker {
+	return &bagChecker{bags: make(map[string]*bag)}
+}
+
+// dir is called by the walker for each directory: it reads the manifests if the directory is a bag. It returns an error if the bag is invalid.
+func (bc *bagChecker) dir(path string) error {
+	if _, err := os.Stat(filepath.Join(path, "bagit.txt")); err != nil || bc.find(path) != nil {
+		return nil
+	}
+	b := &bag{root: path, files: make(map[string]*bagEntry)}
+	bc.bags[path] = b
+	bc.order = append(bc.order, b)
+	b.err = b.load()
+	return b.err
+}
+
+func (b *bag) load() error {
+	decl, err := os.ReadFile(filepath.Join(b.root, "bagit.txt"))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(decl), "BagIt-Version:") {
+		return errors.New("invalid bag: bagit.txt has no BagIt-Version")
+	}
+	for _, alg := range bagAlgorithms {
+		if _, err := os.Stat(filepath.Join(b.root, "manifest-"+alg+".txt")); err == nil {
+			b.alg, b.hash = alg, checksum.GetHash(alg)
+			break
+		}
+	}
+	if b.alg == "" {
+		return errors.New("invalid bag: no payload manifest (one of manifest-sha512.txt, manifest-sha256.txt, manifest-sha1.txt or manifest-md5.txt)")
+	}
+	if err := b.readManifest("manifest-"+b.alg+".txt", false); err != nil {
+		return err
+	}
+	if err := b.readManifest("tagmanifest-"+b.alg+".txt", true); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readManifest reads lines of a checksum, whitespace, and a bag relative path (with CR, LF and % percent encoded)
+func (b *bag) readManifest(name string, tag bool) error {
+	f, err := os.Open(filepath.Join(b.root, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return fmt.Errorf("invalid bag: bad line in %s: %q", name, line)
+		}
+		p := strings.TrimLeft(line[i:], " \t")
+		p = strings.NewReplacer("%0A", "\n", "%0a", "\n", "%0D", "\r", "%0d", "\r", "%25", "%").Replace(p)
+		p = strings.TrimPrefix(p, "./")
+		if !tag && !strings.HasPrefix(p, "data/") {
+			return fmt.Errorf("invalid bag: %s lists %s, which isn't in the payload directory", name, p)
+		}
+		b.files[p] = &bagEntry{sum: strings.ToLower(line[:i])}
+	}
+	return scanner.Err()
+}
+
+// find returns the bag a path is within, or nil
+func (bc *bagChecker) find(path string) *bag {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if b, ok := bc.bags[dir]; ok {
+			return b
+		}
+		if next := filepath.Dir(dir); next == dir {
+			return nil
+		}
+	}
+}
+
+// file is called by the walker before a file is identified: if the file is in a valid bag, the bag's hash is calculated as it is read
+func (bc *bagChecker) file(ctx *context) {
+	b := bc.find(ctx.path)
+	if b == nil || b.err != nil {
+		return
+	}
+	ctx.bag = b
+	ctx.bh = checksum.MakeHash(b.hash)
+}
+
+// fields is called by the printer for each result and returns the bagpath and manifest fields. The manifest field is ok, a checksum
+// mismatch, "not in manifest" (for unlisted payload files) or, for tag files that aren't listed in a tag manifest, empty.
+func (bc *bagChecker) fields(ctx *context, err error) []string {
+	b := ctx.bag
+	if b == nil {
+		return []string{"", ""}
+	}
+	rel, rerr := filepath.Rel(b.root, ctx.path)
+	if rerr != nil {
+		return []string{"", ""}
+	}
+	rel = filepath.ToSlash(rel)
+	e, ok := b.files[rel]
+	switch {
+	case !ok && strings.HasPrefix(rel, "data/"):
+		b.unlisted++
+		return []string{rel, "not in manifest"}
+	case !ok:
+		return []string{rel, ""}
+	}
+	e.seen = true
+	if err != nil {
+		b.missing++ // the read error is reported in the errors field
+		return []string{rel, "error"}
+	}
+	if sum := hex.EncodeToString(ctx.bh.Sum(nil)); sum != e.sum {
+		b.mismatched++
+		return []string{rel, fmt.Sprintf("%s mismatch: expected %s, got %s", b.alg, e.sum, sum)}
+	}
+	b.ok++
+	return []string{rel, "ok"}
+}
+
+// finish logs listed files that weren't found, and a summary of each bag. It reports whether all bags are valid.
+func (bc *bagChecker) finish() bool {
+	valid := true
+	for _, b := range bc.order {
+		if b.err != nil {
+			log.Printf("bag %s: %v\n", b.root, b.err)
+			valid = false
+			continue
+		}
+		paths := make([]string, 0, len(b.files))
+		for p := range b.files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			if !b.files[p].seen {
+				b.missing++
+				log.Printf("[WARN] bag %s: %s is listed in a manifest but wasn't found\n", b.root, p)
+			}
+		}
+		state := "valid"
+		if b.mismatched > 0 || b.unlisted > 0 || b.missing > 0 {
+			state, valid = "invalid", false
+		}
+		log.Printf("bag %s: %s (%s); %d ok, %d mismatched, %d not in manifest, %d missing or unreadable\n", b.root, state, b.alg, b.ok, b.mismatched, b.unlisted, b.missing)
+	}
+	return valid
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBag(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "bag")
+	os.MkdirAll(filepath.Join(root, "data", "sub"), 0755)
+	os.WriteFile(filepath.Join(root, "bagit.txt"), []byte("BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"), 0644)
+	os.WriteFile(filepath.Join(root, "manifest-md5.txt"), []byte("5d41402abc4b2a76b9719d911017c592 data/a.txt\n"+
+		"5d41402abc4b2a76b9719d911017c592  data/sub/100%25.txt\n"), 0644)
+	os.WriteFile(filepath.Join(root, "manifest-sha1.txt"), []byte("aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d data/a.txt\n"), 0644)
+	bc := newBagChecker()
+	if err := bc.dir(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.dir(filepath.Join(root, "data")); err != nil || len(bc.order) != 1 {
+		t.Fatal("expecting a payload directory not to be a bag")
+	}
+	if b := bc.bags[root]; b.alg != "sha1" || len(b.files) != 1 {
+		t.Fatalf("expecting the strongest manifest to be read, got %s with %d files", b.alg, len(b.files))
+	}
+	check := func(rel, content string) []string {
+		ctx := &context{path: filepath.Join(root, filepath.FromSlash(rel))}
+		bc.file(ctx)
+		if ctx.bh == nil {
+			t.Fatalf("expecting %s to be hashed", rel)
+		}
+		ctx.bh.Write([]byte(content))
+		return bc.fields(ctx, nil)
+	}
+	if f := check("data/a.txt", "hello"); f[0] != "data/a.txt" || f[1] != "ok" {
+		t.Errorf("expecting data/a.txt to be ok, got %v", f)
+	}
+	if f := check("data/sub/b.txt", "hello"); f[1] != "not in manifest" {
+		t.Errorf("expecting an unlisted payload file, got %v", f)
+	}
+	if f := check("bag-info.txt", "hello"); f[0] != "bag-info.txt" || f[1] != "" {
+		t.Errorf("expecting an unlisted tag file to have an empty manifest field, got %v", f)
+	}
+	if f := bc.fields(&context{path: filepath.Join(root, "..", "other")}, nil); f[0] != "" {
+		t.Errorf("expecting no bag fields outside a bag, got %v", f)
+	}
+	if bc.finish() {
+		t.Error("expecting a bag with an unlisted payload file to be invalid")
+	}
+}
+
+func TestBagManifest(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "bagit.txt"), []byte("BagIt-Version: 1.0\n"), 0644)
+	os.WriteFile(filepath.Join(root, "manifest-sha256.txt"), []byte("abc bagit.txt\n"), 0644)
+	bc := newBagChecker()
+	if err := bc.dir(root); err == nil {
+		t.Error("expecting an error for a payload manifest listing a tag file")
+	}
+	ctx := &context{path: filepath.Join(root, "data", "a.txt")}
+	if bc.file(ctx); ctx.bh != nil {
+		t.Error("expecting files in an invalid bag not to be checked")
+	}
+	os.WriteFile(filepath.Join(root, "manifest-sha256.txt"), []byte("abc data/a%0Ab.txt\n"), 0644)
+	bc = newBagChecker()
+	if err := bc.dir(root); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bc.bags[root].files["data/a\nb.txt"]; !ok {
+		t.Errorf("expecting percent encoded paths to be decoded, got %v", bc.bags[root].files)
+	}
+}
+
+// TestExtraSlots tests that the bag, fixity and test fields, which are filled in by the printer, are found in the extra fields
+func TestExtraSlots(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		bag, verify, test bool
+	}{
+		{true, true, true},
+		{true, false, true},
+		{false, true, true},
+		{true, true, false},
+	} {
+		bags, verifying, corpus = nil, nil, nil
+		if v.bag {
+			bags = newBagChecker()
+		}
+		if v.verify {
+			verifying = &verifier{}
+		}
+		if v.test {
+			corpus = &tester{}
+		}
+		ex, sl := extraSlots(s)
+		if v.bag && (ex[sl.bag] != bagFields[0] || len(ex) < sl.bag+len(bagFields)) {
+			t.Errorf("%v: bad bag slot %d in %v", v, sl.bag, ex)
+		}
+		if v.verify && ex[sl.fixity] != "fixity" {
+			t.Errorf("%v: bad fixity slot %d in %v", v, sl.fixity, ex)
+		}
+		if v.test && ex[sl.test] != "test" {
+			t.Errorf("%v: bad test slot %d in %v", v, sl.test, ex)
+		}
+	}
+	bags, verifying, corpus = nil, nil, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var cachef = flag.String("cache", "", "cache results in this file, keyed by each file's path, device, inode, size and modified time, and report cached results for unchanged files instead of scanning them again; the cache is cleared if the signature file or output options change e.g. -cache coll.cache")
+
+// rcache is the result cache; it is nil if the -cache flag isn't given
+var rcache *resultCache
+
+// resultCache holds the results of previous scans. Unlike -state, cached results are reported in full.
+// Only the results of whole files are cached: not the members of containers, disk images or sequences;
+// and not files whose content must be read again (archives with -z, and any file with -disk, -ole, -bag or -verify).
+// Files with errors aren't cached, so they are tried again. Entries for files that aren't visited are kept.
+type resultCache struct {
+	key  string                // the signature file (and its creation time), hash and extra fields used by this scan
+	prev map[string]cacheEntry // read only during a scan (consulted by readFile)
+	next map[string]cacheEntry // written by the printer
+}
+
+// fileID identifies a file on disk; it is zero where the platform doesn't have device and inode numbers
+type fileID struct {
+	Dev, Ino uint64
+}
+
+type cacheEntry struct {
+	ID   fileID
+	Size int64
+	Mod  int64 // unix nanoseconds
+	CS   []byte
+	IDs  []cachedID
+	Ex   []string
+}
+
+// cachedID is an identification restored from the cache
+type cachedID struct {
+	ID, Warning string
+	Match       bool
+	Arc         config.Archive
+	Vals        []string
+}
+
+func (c cachedID) String() string          { return c.ID }
+func (c cachedID) Known() bool             { return c.Match }
+func (c cachedID) Warn() string            { return c.Warning }
+func (c cachedID) Values() []string        { return c.Vals }
+func (c cachedID) Archive() config.Archive { return c.Arc }
+
+type cacheHeader struct {
+	Key     string
+	Entries int
+}
+
+func loadCache(path, key string) (*resultCache, error) {
+	rc := &resultCache{key: key, prev: make(map[string]cacheEntry), next: make(map[string]cacheEntry)}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rc, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	var hdr cacheHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Key != key { // new signatures or options: start again
+		return rc, nil
+	}
+	for i := 0; i < hdr.Entries; i++ {
+		var p string
+		var e cacheEntry
+		if err := dec.Decode(&p); err != nil {
+			return nil, err
+		}
+		if err := dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		rc.prev[p] = e
+	}
+	return rc, nil
+}
+
+// cacheable reports whether a context's result may be taken from, or put in, the cache
+func cacheable(ctx *context) bool {
+	return ctx.depth == 0 && ctx.seq == nil && ctx.rec == nil && !ctx.anon && ctx.sz >= 0 && ctx.bh == nil &&
+		!*diskf && !*olef && verifying == nil
+}
+
+// get is called by readFile with the opened file: it returns the cached result, if the file hasn't changed
+func (rc *resultCache) get(ctx *context, info os.FileInfo) (results, bool) {
+	e, ok := rc.prev[ctx.path]
+	if !ok || e.ID != getFileID(info) || e.Size != info.Size() || e.Mod != info.ModTime().UnixNano() {
+		return results{}, false
+	}
+	ids := make([]core.Identification, len(e.IDs))
+	for i, id := range e.IDs {
+		if ctx.z && id.Arc > config.None { // the archive's members would need to be scanned
+			return results{}, false
+		}
+		ids[i] = id
+	}
+	return results{nil, e.CS, ids, append([]string(nil), e.Ex...)}, true
+}
+
+// put is called by the printer for each result of a file read with the cache (the file's ID is set by readFile)
+func (rc *resultCache) put(ctx *context, res results) {
+	if ctx.fid == nil || res.err != nil || res.ids == nil {
+		return
+	}
+	e := cacheEntry{
+		ID:   *ctx.fid,
+		Size: ctx.sz,
+		Mod:  ctx.mod.UnixNano(),
+		CS:   res.cs,
+		IDs:  make([]cachedID, len(res.ids)),
+		Ex:   append([]string(nil), res.ex...), // the printer appends its own fields to res.ex
+	}
+	for i, id := range res.ids {
+		e.IDs[i] = cachedID{id.String(), id.Warn(), id.Known(), id.Archive(), id.Values()}
+	}
+	rc.next[ctx.path] = e
+}
+
+// save writes the cache file, via a temporary file so that an interrupted save doesn't lose the cache
+func (rc *resultCache) save(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	for p := range rc.next {
+		delete(rc.prev, p)
+	}
+	buf := bufio.NewWriter(f)
+	enc := gob.NewEncoder(buf)
+	err = enc.Encode(cacheHeader{rc.key, len(rc.prev) + len(rc.next)})
+	for _, m := range []map[string]cacheEntry{rc.prev, rc.next} {
+		for p, e := range m {
+			if err == nil {
+				err = enc.Encode(p)
+			}
+			if err == nil {
+				err = enc.Encode(e)
+			}
+		}
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
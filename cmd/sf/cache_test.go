@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.cache")
+	f := filepath.Join(dir, "a.txt")
+	os.WriteFile(f, []byte("hello"), 0644)
+	info, _ := os.Stat(f)
+	rc, err := loadCache(path, "default.sig 2023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &context{path: f, sz: info.Size(), mod: info.ModTime()}
+	if _, ok := rc.get(ctx, info); ok {
+		t.Error("expecting a new file not to be cached")
+	}
+	fid := getFileID(info)
+	ctx.fid = &fid
+	rc.put(ctx, results{nil, []byte{1, 2}, []core.Identification{cmpID{"x-fmt/111", "extension mismatch"}}, []string{"ex"}})
+	if err := rc.save(path); err != nil {
+		t.Fatal(err)
+	}
+	rc, err = loadCache(path, "default.sig 2023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.fid = nil
+	res, ok := rc.get(ctx, info)
+	if !ok {
+		t.Fatal("expecting an unchanged file to be cached")
+	}
+	if len(res.ids) != 1 || res.ids[0].String() != "x-fmt/111" || res.ids[0].Warn() != "extension mismatch" ||
+		string(res.cs) != "\x01\x02" || len(res.ex) != 1 || res.ex[0] != "ex" {
+		t.Errorf("bad cached result: %v", res)
+	}
+	// archives are scanned again with -z, so that their members are reported
+	rc.prev[f] = cacheEntry{ID: fid, Size: info.Size(), Mod: info.ModTime().UnixNano(), IDs: []cachedID{{ID: "x-fmt/263", Match: true, Arc: config.Zip}}}
+	if _, ok := rc.get(&context{path: f, z: true}, info); ok {
+		t.Error("expecting an archive not to be taken from the cache with -z")
+	}
+	// a changed file isn't taken from the cache
+	os.WriteFile(f, []byte("hello world"), 0644)
+	info, _ = os.Stat(f)
+	if _, ok := rc.get(ctx, info); ok {
+		t.Error("expecting a changed file not to be cached")
+	}
+	// with a new signature file, the cache is cleared
+	rc, _ = loadCache(path, "default.sig 2024")
+	if len(rc.prev) != 0 {
+		t.Error("expecting the cache to be cleared with new signatures")
+	}
+}
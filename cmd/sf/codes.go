@@ -0,0 +1,48 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var codesf = flag.Bool("codes", false, "report machine-readable warning codes for each file e.g. EXTENSION_MISMATCH, EMPTY_FILE, EXTENSION_ONLY, MULTIPLE_MATCHES")
+
+// codesExtra reports, as a "codes" field, the warning codes for each file's identifications.
+// The codes for each identification are separated by spaces, and identifications are separated by "; ".
+func codesExtra() siegfried.Extra {
+	return siegfried.Extra{
+		Name: "codes",
+		Fn: func(c siegfried.Content, ids []core.Identification) string {
+			var empty string
+			if c.SizeNow() == 0 {
+				empty = core.EmptyFile
+			}
+			strs := make([]string, len(ids))
+			for i, id := range ids {
+				codes := core.Codes(id)
+				if empty != "" {
+					codes = append([]string{empty}, codes...)
+				}
+				strs[i] = strings.Join(codes, " ")
+			}
+			return strings.Join(strs, "; ")
+		},
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"sort"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var comparef = flag.String("compare", "", "identify files with a second signature file too and report where the two disagree e.g. -compare new.sig")
+
+// comparator is the second signature file loaded with -compare
+var comparator *siegfried.Siegfried
+
+// the fields added by -compare: the IDs given by the second signature file, and a description of any disagreement
+var compareFields = []string{"compare", "disagreement"}
+
+// compare identifies a buffer with the second signature file and returns values for the compareFields
+func compare(ids []core.Identification, b *siegreader.Buffer, berr error, name, mime string) []string {
+	other, err := comparator.IdentifyBuffer(b, berr, name, mime)
+	if err != nil && other == nil {
+		return []string{"", "error: " + err.Error()}
+	}
+	return []string{idList(other), disagreement(ids, other)}
+}
+
+func idList(ids []core.Identification) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+// disagreement describes the differences between two sets of results: different IDs, and added or removed warnings.
+// It is empty if the results agree.
+func disagreement(a, b []core.Identification) string {
+	var diffs []string
+	if ai, bi := idList(a), idList(b); ai != bi {
+		diffs = append(diffs, "id "+ai+" -> "+bi)
+	}
+	aw, bw := warnings(a), warnings(b)
+	for _, w := range bw {
+		if !check(w, aw) {
+			diffs = append(diffs, "warning added: "+w)
+		}
+	}
+	for _, w := range aw {
+		if !check(w, bw) {
+			diffs = append(diffs, "warning removed: "+w)
+		}
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// warnings splits the (semi-colon separated) warnings of results
+func warnings(ids []core.Identification) []string {
+	var ret []string
+	for _, id := range ids {
+		for _, w := range strings.Split(id.Warn(), "; ") {
+			if w != "" && !check(w, ret) {
+				ret = append(ret, w)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+type cmpID struct{ id, warn string }
+
+func (c cmpID) String() string          { return c.id }
+func (c cmpID) Known() bool             { return c.id != "UNKNOWN" }
+func (c cmpID) Warn() string            { return c.warn }
+func (c cmpID) Values() []string        { return []string{"pronom", c.id, "", "", "", "", c.warn} }
+func (c cmpID) Archive() config.Archive { return config.None }
+
+func TestDisagreement(t *testing.T) {
+	for _, v := range []struct {
+		a, b   []core.Identification
+		expect string
+	}{
+		{[]core.Identification{cmpID{"fmt/4", ""}}, []core.Identification{cmpID{"fmt/4", ""}}, ""},
+		{[]core.Identification{cmpID{"fmt/3", ""}}, []core.Identification{cmpID{"fmt/4", ""}}, "id fmt/3 -> fmt/4"},
+		{[]core.Identification{cmpID{"fmt/4", "extension mismatch; match on extension only"}}, []core.Identification{cmpID{"fmt/4", "extension mismatch; filename mismatch"}},
+			"warning added: filename mismatch; warning removed: match on extension only"},
+	} {
+		if d := disagreement(v.a, v.b); d != v.expect {
+			t.Errorf("expecting %q, got %q", v.expect, d)
+		}
+	}
+}
@@ -28,9 +28,9 @@ import (
 
 var (
 	// list of flags that can be configured
-	setableFlags = []string{"coe", "csv", "droid", "hash", "json", "log", "multi", "nr", "serve", "sig", "throttle", "yaml", "z", "zs"}
+	setableFlags = []string{"bases", "budget", "cache", "codes", "coe", "compare", "csv", "csvfields", "data", "disk", "dot", "droid", "embedded", "encrypted", "engine", "endpoint", "evidence", "exclude", "fido", "follow", "fuzzy", "grpc", "hash", "hidden", "include", "inventory", "journal", "json", "jsonl", "log", "max-bytes", "maxsize", "mets", "minsize", "multi", "nameonly", "names", "newer", "notemp", "notify", "nr", "older", "ole", "premis", "preview", "probe", "records", "sample", "seq", "seqf", "serve", "servelimit", "servetls", "servetoken", "sig", "state", "test", "text", "texttype", "throttle", "unknown", "watch", "watchpost", "watchroll", "watchsettle", "why", "yaml", "z", "zlimit", "zs", "zsum"}
 	// list of flags that control output - these are exclusive of each other
-	outputFlags = []string{"csv", "droid", "json", "yaml"}
+	outputFlags = []string{"csv", "dot", "droid", "fido", "json", "jsonl", "mets", "premis", "yaml"}
 )
 
 // also used in sf_test.go
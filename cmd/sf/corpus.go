@@ -0,0 +1,208 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var testf = flag.String("test", "", "check files against a corpus file listing their expected identifications (lines of path: id, with paths relative to the corpus file), reporting each regression in a test field and exiting with code 6 if any fail e.g. sf -test corpus.yaml (the listed files are scanned if no files or directories are given)")
+
+// exitTest is the exit code when files fail -test
+const exitTest = 6
+
+// corpus holds the expected identifications for -test; it is nil if the flag isn't given
+var corpus *tester
+
+// tester checks results against the expected identifications in a corpus file. The corpus file is a YAML mapping
+// of paths (relative to the corpus file) to IDs, e.g.:
+//
+//	pdf/minimal.pdf: fmt/18
+//	"archive.zip#member.doc": fmt/40
+//	ambiguous.bin: [fmt/1, fmt/2]
+//	blob.bin: UNKNOWN
+//
+// Paths are compared as absolute paths. Where a file has more than one ID, their order doesn't matter.
+type tester struct {
+	expect map[string]*expectation
+	order  []string // paths in the order given
+	field  int      // index of the test field in the extra fields
+	// counts
+	passed, failed, missing int
+}
+
+type expectation struct {
+	ids    string // sorted, comma separated
+	result string // the test field for the last scan of this file
+	seen   bool
+}
+
+func newTester(path string) (*tester, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := &tester{expect: make(map[string]*expectation)}
+	dir := filepath.Dir(path)
+	scanner := bufio.NewScanner(f)
+	var n int
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(strings.TrimSuffix(scanner.Text(), "\r"))
+		if line == "" || line == "---" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, ids, err := corpusLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		p = filepath.FromSlash(p)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		if _, ok := t.expect[p]; ok {
+			return nil, fmt.Errorf("line %d: %s is listed more than once", n, p)
+		}
+		t.order = append(t.order, p)
+		t.expect[p] = &expectation{ids: ids}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.order) == 0 {
+		return nil, fmt.Errorf("no files listed")
+	}
+	return t, nil
+}
+
+// corpusLine parses a path: id line, returning the path and the sorted list of IDs
+func corpusLine(line string) (string, string, error) {
+	var p, rest string
+	if q := line[0]; q == '"' || q == '\'' {
+		end := strings.IndexByte(line[1:], q)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quote in %q", line)
+		}
+		p, rest = line[1:end+1], strings.TrimSpace(line[end+2:])
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("expecting path: id, got %q", line)
+		}
+		rest = rest[1:]
+	} else {
+		i := strings.Index(line, ": ")
+		if i < 0 {
+			return "", "", fmt.Errorf("expecting path: id, got %q", line)
+		}
+		p, rest = line[:i], line[i+2:]
+	}
+	if i := strings.Index(rest, " #"); i > -1 { // trailing comment
+		rest = rest[:i]
+	}
+	rest = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(rest), "["), "]")
+	var ids []string
+	for _, id := range strings.Split(rest, ",") {
+		if id = strings.Trim(strings.TrimSpace(id), "\"'"); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if p == "" || len(ids) == 0 {
+		return "", "", fmt.Errorf("expecting path: id, got %q", line)
+	}
+	sort.Strings(ids)
+	return p, strings.Join(ids, ", "), nil
+}
+
+func sortedIDs(ids []core.Identification) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	sort.Strings(strs)
+	return strings.Join(strs, ", ")
+}
+
+// paths returns the paths in the corpus file, to scan if no files or directories are given
+func (t *tester) paths() []string {
+	ret := make([]string, len(t.order))
+	copy(ret, t.order)
+	sort.Strings(ret)
+	return ret
+}
+
+// check is called by the printer for each result and returns a value for the test field:
+// pass, a description of the regression, or error (the file couldn't be read).
+// Files and directories that aren't in the corpus have no test value.
+func (t *tester) check(path string, sz int64, err error, ids []core.Identification) string {
+	if sz < 0 {
+		return ""
+	}
+	key := path
+	if abs, aerr := filepath.Abs(path); aerr == nil {
+		key = abs
+	}
+	e, ok := t.expect[key]
+	if !ok {
+		return ""
+	}
+	e.seen = true
+	switch {
+	case err != nil && ids == nil:
+		e.result = "error"
+	case sortedIDs(ids) != e.ids:
+		e.result = "expected " + e.ids + ", got " + sortedIDs(ids)
+	default:
+		e.result = "pass"
+	}
+	return e.result
+}
+
+// finish counts the passes and failures, logging each regression, and counts listed files that weren't scanned as missing
+func (t *tester) finish() {
+	for _, p := range t.order {
+		e := t.expect[p]
+		switch {
+		case !e.seen:
+			t.missing++
+			log.Printf("[FAIL] -test: %s wasn't scanned\n", p)
+		case e.result == "pass":
+			t.passed++
+		default:
+			t.failed++
+			log.Printf("[FAIL] -test: %s: %s\n", p, e.result)
+		}
+	}
+}
+
+// regressed reports whether any listed files failed, or weren't scanned
+func (t *tester) regressed() bool {
+	return t.failed > 0 || t.missing > 0
+}
+
+func (t *tester) String() string {
+	return fmt.Sprintf("%d passed, %d failed, %d not scanned", t.passed, t.failed, t.missing)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestCorpusLine(t *testing.T) {
+	for _, v := range []struct {
+		line, path, ids string
+		err             bool
+	}{
+		{"pdf/minimal.pdf: fmt/18", "pdf/minimal.pdf", "fmt/18", false},
+		{"\"a: b.zip#c.doc\": fmt/40 # a comment", "a: b.zip#c.doc", "fmt/40", false},
+		{"both.bin: [fmt/2, 'fmt/1']", "both.bin", "fmt/1, fmt/2", false},
+		{"nothing.bin:", "", "", true},
+		{"\"unterminated: fmt/1", "", "", true},
+	} {
+		p, ids, err := corpusLine(v.line)
+		if (err != nil) != v.err || p != v.path || ids != v.ids {
+			t.Errorf("%s: expecting %q, %q (error %t), got %q, %q, %v", v.line, v.path, v.ids, v.err, p, ids, err)
+		}
+	}
+}
+
+func TestTester(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "corpus.yaml")
+	os.WriteFile(path, []byte("---\n# expected results\na.pdf: fmt/18\r\nsub/b.bin: [fmt/2, fmt/1]\nc.txt: x-fmt/111\nd.txt: UNKNOWN\n"), 0644)
+	tst, err := newTester(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := filepath.Join(dir, "a.pdf"), filepath.Join(dir, "sub", "b.bin"), filepath.Join(dir, "c.txt")
+	if p := tst.paths(); len(p) != 4 || p[0] != a || p[1] != c {
+		t.Fatalf("expecting paths relative to the corpus file, got %v", p)
+	}
+	if r := tst.check(a, 5, nil, []core.Identification{cmpID{"fmt/18", ""}}); r != "pass" {
+		t.Errorf("expecting pass, got %s", r)
+	}
+	if r := tst.check(b, 5, nil, []core.Identification{cmpID{"fmt/1", ""}, cmpID{"fmt/2", ""}}); r != "pass" {
+		t.Errorf("expecting pass regardless of order, got %s", r)
+	}
+	if r := tst.check(c, 5, nil, []core.Identification{cmpID{"fmt/1", ""}}); r != "expected x-fmt/111, got fmt/1" {
+		t.Errorf("expecting a regression, got %s", r)
+	}
+	if r := tst.check(filepath.Join(dir, "e.txt"), 5, errors.New("bad"), nil); r != "" {
+		t.Errorf("expecting no test value for an unlisted file, got %s", r)
+	}
+	tst.finish()
+	if !tst.regressed() || tst.String() != "2 passed, 1 failed, 1 not scanned" {
+		t.Errorf("unexpected summary %s", tst)
+	}
+	os.WriteFile(path, []byte("a.pdf: fmt/18\na.pdf: fmt/19\n"), 0644)
+	if _, err = newTester(path); err == nil {
+		t.Error("expecting an error for a file listed twice")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var dataf = flag.Bool("data", false, "treat arguments (or the lines of -f lists) as data: URIs and identify their decoded payloads e.g. sf -data \"data:image/png;base64,iVBORw0KGgo...\"")
+
+const defaultDataType = "text/plain;charset=US-ASCII"
+
+// parseDataURI decodes a data URI (RFC 2397) and returns its declared media type and payload.
+// If no media type is given, the declared type is text/plain;charset=US-ASCII.
+func parseDataURI(uri string) (string, []byte, error) {
+	if len(uri) < 5 || !strings.EqualFold(uri[:5], "data:") {
+		return "", nil, errors.New("missing data: scheme")
+	}
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return "", nil, errors.New("missing comma before payload")
+	}
+	header, payload := uri[5:comma], uri[comma+1:]
+	var b64 bool
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		b64 = true
+		header = header[:len(header)-7]
+	}
+	mt := header
+	if mt == "" || strings.HasPrefix(mt, ";") {
+		mt = "text/plain" + mt
+		if mt == "text/plain" {
+			mt = defaultDataType
+		}
+	}
+	if b64 {
+		// tolerate whitespace, percent encoding and missing padding
+		payload, err := url.PathUnescape(payload)
+		if err != nil {
+			return mt, nil, err
+		}
+		payload = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+				return -1
+			}
+			return r
+		}, payload)
+		byts, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+		if err != nil {
+			return mt, nil, fmt.Errorf("bad base64 payload: %v", err)
+		}
+		return mt, byts, nil
+	}
+	str, err := url.PathUnescape(payload)
+	if err != nil {
+		return mt, nil, fmt.Errorf("bad percent encoded payload: %v", err)
+	}
+	return mt, []byte(str), nil
+}
+
+// dataName gives a short name for a data URI in results: the URI up to the start of the payload, and the first few characters of the payload
+func dataName(uri string) string {
+	if comma := strings.IndexByte(uri, ','); comma > -1 && len(uri) > comma+17 {
+		return uri[:comma+17] + "..."
+	}
+	return uri
+}
+
+// declared returns the media type of a data URI without parameters, for the declared field
+func declared(mt string) string {
+	if i := strings.IndexByte(mt, ';'); i > -1 {
+		return mt[:i]
+	}
+	return mt
+}
+
+func identifyData(uri string, ctxts chan *context) {
+	mt, byts, err := parseDataURI(uri)
+	if err != nil {
+		printFile(ctxts, getCtx(dataName(uri), "", time.Time{}, 0), fmt.Errorf("bad data URI: %v", err))
+		return
+	}
+	// the declared type is reported but, as it may be wrong, it isn't given to the MIME matcher
+	ctx := getCtx(dataName(uri), "", time.Time{}, int64(len(byts)))
+	ctx.anon, ctx.declared = true, declared(mt)
+	ctx.wg.Add(1)
+	ctxts <- ctx
+	identifyRdr(bytes.NewReader(byts), ctx, ctxts, getCtx)
+}
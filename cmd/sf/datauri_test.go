@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richardlehane/siegfried/internal/logger"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestParseDataURI(t *testing.T) {
+	for _, v := range []struct {
+		uri, mt, payload string
+		ok               bool
+	}{
+		{"data:,Hello%2C%20World", defaultDataType, "Hello, World", true},
+		{"data:;charset=utf-8,caf%C3%A9", "text/plain;charset=utf-8", "café", true},
+		{"data:text/html;base64,PGI+aGk8L2I+", "text/html", "<b>hi</b>", true},
+		{"DATA:image/gif;BASE64,R0lG ODlh", "image/gif", "GIF89a", true},
+		{"data:text/plain", "", "", false},
+		{"data:image/png;base64,!!!", "image/png", "", false},
+		{"http://example.com", "", "", false},
+	} {
+		mt, payload, err := parseDataURI(v.uri)
+		if (err == nil) != v.ok || (v.ok && (mt != v.mt || string(payload) != v.payload)) {
+			t.Errorf("bad parse of %s: got %q %q %v", v.uri, mt, payload, err)
+		}
+	}
+}
+
+// resultWriter sends the name, size, checksum (if calculated), IDs, warnings and extra fields of the files it is given
+type resultWriter chan string
+
+func (r resultWriter) Head(string, time.Time, time.Time, [3]int, [][2]string, [][]string, string, []string) {
+}
+
+func (r resultWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	res := []string{name, strconv.FormatInt(sz, 10)}
+	if checksum != nil {
+		res = append(res, hex.EncodeToString(checksum))
+	}
+	for _, id := range ids {
+		res = append(res, id.String(), id.Warn())
+	}
+	r <- strings.Join(append(res, extra...), "|")
+}
+
+func (r resultWriter) Tail() {}
+
+func TestIdentifyData(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	*dataf = true
+	defer func() { *dataf = false }()
+	lg, _ := logger.New("")
+	res := make(resultWriter, 1)
+	ctxts := make(chan *context, 1)
+	printed := make(chan struct{})
+	go func() {
+		printer(ctxts, lg)
+		close(printed)
+	}()
+	wg := &sync.WaitGroup{}
+	setCtxPool(s, wg, res, false, false, -1)
+	// a text payload declared as a PDF: the declared type is reported, but isn't a basis for identification
+	identifyData("data:application/pdf,hello%20world", ctxts)
+	wg.Wait()
+	close(ctxts)
+	<-printed
+	got := <-res
+	if !strings.HasSuffix(got, "|application/pdf") || strings.Contains(got, "MIME") {
+		t.Errorf("expecting the declared type to be reported without a MIME match, got %s", got)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+var dupsf = flag.String("dups", "", "with -hash, write a CSV report of duplicate files (clusters of files with the same checksum, largest wasted bytes first) to this file at the end of a scan e.g. -hash sha256 -dups dups.csv")
+
+// duplicates collects checksums for the -dups report; it is nil if the flag isn't given
+var duplicates *dupFinder
+
+// dupFinder groups files by their (first -hash) checksum. Empty files, directories and files with errors are left out.
+type dupFinder struct {
+	hash  string // name of the hash algorithm
+	files map[string][]dupFile
+	order []string // checksums in the order first seen
+}
+
+type dupFile struct {
+	path string
+	size int64
+}
+
+type dupCluster struct {
+	sum   string
+	files []dupFile
+}
+
+// wasted is the number of bytes taken by all but one copy of a cluster's file
+func (c dupCluster) wasted() int64 {
+	return c.files[0].size * int64(len(c.files)-1)
+}
+
+func newDupFinder(hash string) *dupFinder {
+	return &dupFinder{hash: hash, files: make(map[string][]dupFile)}
+}
+
+// add is called by the printer for each result
+func (d *dupFinder) add(path string, sz int64, cs []byte, err error) {
+	if err != nil || sz <= 0 || cs == nil {
+		return
+	}
+	sum := hex.EncodeToString(cs)
+	if _, ok := d.files[sum]; !ok {
+		d.order = append(d.order, sum)
+	}
+	d.files[sum] = append(d.files[sum], dupFile{path, sz})
+}
+
+// clusters returns the checksums shared by more than one file, the most wasted bytes first
+func (d *dupFinder) clusters() []dupCluster {
+	var ret []dupCluster
+	for _, sum := range d.order {
+		if files := d.files[sum]; len(files) > 1 {
+			ret = append(ret, dupCluster{sum, files})
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].wasted() > ret[j].wasted() })
+	return ret
+}
+
+// write writes the report: a row for each file in each cluster
+func (d *dupFinder) write(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"cluster", d.hash, "filesize", "copies", "wasted", "filename"})
+	for i, c := range d.clusters() {
+		for _, f := range c.files {
+			cw.Write([]string{strconv.Itoa(i + 1), c.sum, strconv.FormatInt(f.size, 10), strconv.Itoa(len(c.files)), strconv.FormatInt(c.wasted(), 10), f.path})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeDups(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = duplicates.write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// String summarises the report e.g. "3 clusters of duplicates, 7 files, 1024 bytes wasted"
+func (d *dupFinder) String() string {
+	var files int
+	var wasted int64
+	cs := d.clusters()
+	for _, c := range cs {
+		files += len(c.files)
+		wasted += c.wasted()
+	}
+	return fmt.Sprintf("%d clusters of duplicates, %d files, %d bytes wasted", len(cs), files, wasted)
+}
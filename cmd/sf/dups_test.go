@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDups(t *testing.T) {
+	d := newDupFinder("md5")
+	d.add("a", 10, []byte{1}, nil)
+	d.add("b", 100, []byte{2}, nil)
+	d.add("c", 10, []byte{1}, nil)
+	d.add("d", 100, []byte{2}, nil)
+	d.add("e", 10, []byte{1}, nil)
+	d.add("f", 5, []byte{3}, nil)
+	d.add("empty1", 0, []byte{4}, nil)
+	d.add("empty2", 0, []byte{4}, nil)
+	d.add("bad", 10, []byte{1}, errors.New("read error"))
+	d.add("dir", -1, nil, nil)
+	cs := d.clusters()
+	if len(cs) != 2 || cs[0].sum != "02" || cs[1].wasted() != 20 {
+		t.Fatalf("expecting two clusters, the largest wasted first, got %v", cs)
+	}
+	if s := d.String(); s != "2 clusters of duplicates, 5 files, 120 bytes wasted" {
+		t.Errorf("unexpected summary: %s", s)
+	}
+	buf := &bytes.Buffer{}
+	if err := d.write(buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := "cluster,md5,filesize,copies,wasted,filename\n1,02,100,2,100,b\n1,02,100,2,100,d\n2,01,10,3,20,a\n2,01,10,3,20,c\n2,01,10,3,20,e\n"
+	if buf.String() != expect {
+		t.Errorf("expecting:\n%s\ngot:\n%s", expect, buf.String())
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var embeddedf = flag.Bool("embedded", false, "report, in an embedded field, formats detected inside files at nonzero offsets e.g. a JPEG thumbnail inside a PDF or a ZIP appended to an EXE")
+
+// embeddedExtra reports, as an "embedded" field, the formats detected within each file and their offsets.
+// It scans the full content of the file.
+func embeddedExtra(s *siegfried.Siegfried) siegfried.Extra {
+	return siegfried.Extra{
+		Name: "embedded",
+		Full: true,
+		Fn: func(c siegfried.Content, ids []core.Identification) string {
+			es := s.Embedded(c)
+			strs := make([]string, len(es))
+			for i, e := range es {
+				strs[i] = fmt.Sprintf("%s at offset %d", e.ID, e.Offset)
+			}
+			return strings.Join(strs, "; ")
+		},
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var entropyf = flag.Bool("entropy", false, "for unidentified files, add hints from the byte entropy of the file's first MB to the warning e.g. \"possibly encrypted or compressed (entropy 7.99 bits per byte)\"")
+
+const (
+	entropySample = 1 << 20 // bytes read to measure entropy
+	entropyMin    = 4096    // smaller samples can't be told apart from random data
+	entropyHigh   = 7.9     // bits per byte; the maximum is 8
+)
+
+// entropy returns the Shannon entropy, in bits per byte, of a byte distribution
+func entropy(counts *[256]int64, total int64) float64 {
+	var h float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(total)
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// entropyHint measures the bytes in the sample and returns a hint, or an empty string. Uniform content (every byte the same)
+// is hinted for files of any size (e.g. zeroed or sparse files); high entropy, which is typical of encrypted and compressed data
+// that has no recognisable structure, is hinted for samples of at least 4KB.
+func entropyHint(b *siegreader.Buffer) string {
+	var counts [256]int64
+	var total int64
+	for total < entropySample {
+		buf, _ := b.Slice(total, 65536)
+		if len(buf) == 0 {
+			break
+		}
+		for _, c := range buf {
+			counts[c]++
+		}
+		total += int64(len(buf))
+	}
+	if total == 0 {
+		return ""
+	}
+	for i, c := range counts {
+		if c == total {
+			return fmt.Sprintf("uniform content (every byte is 0x%02x)", i)
+		}
+	}
+	if total < entropyMin {
+		return ""
+	}
+	if h := entropy(&counts, total); h >= entropyHigh {
+		return fmt.Sprintf("possibly encrypted or compressed (entropy %.2f bits per byte)", h)
+	}
+	return ""
+}
+
+// addEntropyHints appends an entropy hint, if there is one, to the warnings of unknown identifications
+func addEntropyHints(b *siegreader.Buffer, ids []core.Identification) []core.Identification {
+	var hint string
+	ret := make([]core.Identification, len(ids))
+	for i, id := range ids {
+		if id.Known() {
+			ret[i] = id
+			continue
+		}
+		if hint == "" {
+			if hint = entropyHint(b); hint == "" {
+				return ids
+			}
+		}
+		ret[i] = hinted{id, hint}
+	}
+	return ret
+}
+
+// hinted adds a message to an identification's warning
+type hinted struct {
+	core.Identification
+	hint string
+}
+
+func (h hinted) Warn() string {
+	if w := h.Identification.Warn(); w != "" {
+		return w + "; " + h.hint
+	}
+	return h.hint
+}
+
+func (h hinted) Bases() []core.Basis { return core.Bases(h.Identification) }
+
+// Values replaces the warning, which identifiers give as their last value
+func (h hinted) Values() []string {
+	vals := append([]string(nil), h.Identification.Values()...)
+	if len(vals) > 0 {
+		vals[len(vals)-1] = h.Warn()
+	}
+	return vals
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestEntropyHint(t *testing.T) {
+	random := make([]byte, 65536)
+	rand.New(rand.NewSource(1)).Read(random)
+	bufs := siegreader.New()
+	for _, v := range []struct {
+		content []byte
+		expect  string
+	}{
+		{random, "possibly encrypted or compressed (entropy 8.00 bits per byte)"},
+		{random[:1000], ""}, // too small to measure
+		{bytes.Repeat([]byte{0xff}, 10), "uniform content (every byte is 0xff)"},
+		{[]byte(strings.Repeat("the quick brown fox ", 1000)), ""},
+	} {
+		b, err := bufs.Get(bytes.NewReader(v.content))
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if got := entropyHint(b); got != v.expect {
+			t.Errorf("expecting %q for %d bytes, got %q", v.expect, len(v.content), got)
+		}
+		bufs.Put(b)
+	}
+}
+
+func TestEntropyHints(t *testing.T) {
+	bufs := siegreader.New()
+	b, _ := bufs.Get(bytes.NewReader(make([]byte, 100)))
+	defer bufs.Put(b)
+	ids := addEntropyHints(b, []core.Identification{cmpID{"UNKNOWN", "no match"}, cmpID{"fmt/1", ""}})
+	if ids[0].Warn() != "no match; uniform content (every byte is 0x00)" || ids[1].Warn() != "" {
+		t.Errorf("expecting a hint for the unknown identification only, got %q and %q", ids[0].Warn(), ids[1].Warn())
+	}
+	if codes := core.Codes(ids[0]); len(codes) != 2 || codes[1] != core.UniformContent {
+		t.Errorf("expecting a UNIFORM_CONTENT code, got %v", codes)
+	}
+}
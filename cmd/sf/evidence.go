@@ -0,0 +1,70 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var evidencef = flag.Bool("evidence", false, "report the byte ranges that matched signatures, as a JSON list of evidence regions for each file (e.g. for highlighting in a hex viewer)")
+
+// evidence is a region of a file (or of a container member) that matched a signature
+type evidence struct {
+	ID        string `json:"id"`
+	Matcher   string `json:"matcher"`          // "byte" or "container"
+	Member    string `json:"member,omitempty"` // for container matches, the member that the offset is relative to
+	Signature int    `json:"signature,omitempty"`
+	Sequence  int    `json:"sequence"` // index of the matching sequence within the signature
+	Offset    int64  `json:"offset"`
+	Length    int64  `json:"length"`
+}
+
+// evidenceExtra reports, as an "evidence" field, the regions given in the basis of each identification
+func evidenceExtra(s *siegfried.Siegfried) siegfried.Extra {
+	return siegfried.Extra{
+		Name: "evidence",
+		Fn: func(c siegfried.Content, ids []core.Identification) string {
+			var ev []evidence
+			for _, id := range ids {
+				for _, kv := range s.Label(id) {
+					if kv[0] == "basis" {
+						ev = append(ev, parseEvidence(id.String(), kv[1])...)
+					}
+				}
+			}
+			if len(ev) == 0 {
+				return ""
+			}
+			byt, _ := json.Marshal(ev)
+			return string(byt)
+		},
+	}
+}
+
+// parseEvidence reads a basis string, such as "extension match odt; container name content.xml with byte match at [[39 23] [127 20]]",
+// for the offsets and lengths of byte matches.
+func parseEvidence(id, basis string) []evidence {
+	var ev []evidence
+	for _, b := range core.ParseBases(core.SplitBasis(basis)) {
+		for _, h := range b.Hits {
+			ev = append(ev, evidence{id, b.Matcher, h.Member, b.Signature, h.Sequence, h.Offset, h.Length})
+		}
+	}
+	return ev
+}
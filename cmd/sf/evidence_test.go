@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestParseEvidence(t *testing.T) {
+	for _, v := range []struct {
+		basis  string
+		expect []evidence
+	}{
+		{"extension match gif; byte match at [[0 6] [18124 1]]", []evidence{{"a", "byte", "", 0, 0, 0, 6}, {"a", "byte", "", 0, 1, 18124, 1}}},
+		{"byte match at 0, 4 (signature 2/5)", []evidence{{"a", "byte", "", 2, 0, 0, 4}}},
+		{"extension match odt; container name content.xml with byte match at [[39 23]]; name META-INF/manifest.xml with byte match at 192, 60",
+			[]evidence{{"a", "container", "content.xml", 0, 0, 39, 23}, {"a", "container", "META-INF/manifest.xml", 0, 0, 192, 60}}},
+		{"extension match zip; container match with trigger and default extension", nil},
+		{"extension match txt; text match ASCII", nil},
+	} {
+		ev := parseEvidence("a", v.basis)
+		if len(ev) != len(v.expect) {
+			t.Errorf("%s: expecting %v, got %v", v.basis, v.expect, ev)
+			continue
+		}
+		for i := range ev {
+			if ev[i] != v.expect[i] {
+				t.Errorf("%s: expecting %v, got %v", v.basis, v.expect[i], ev[i])
+			}
+		}
+	}
+}
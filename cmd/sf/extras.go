@@ -0,0 +1,148 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/decompress"
+	"github.com/richardlehane/siegfried/pkg/probe"
+)
+
+var (
+	probef = flag.String("probe", "", "report structural details for supported formats e.g. -probe all or -probe swf,flv; probes: "+probe.List())
+	encf   = flag.Bool("encrypted", false, "report whether files are encrypted or password protected (pdf, ooxml, zip, rar, 7z) and the mechanism used")
+)
+
+// addExtras registers extra fields, as selected by flags, with a newly loaded siegfried
+func addExtras(s *siegfried.Siegfried) error {
+	if *probef != "" {
+		e, err := probe.Extra(strings.Split(*probef, ",")...)
+		if err != nil {
+			return err
+		}
+		if err = s.AddExtra(e); err != nil {
+			return err
+		}
+	}
+	if *encf {
+		for _, e := range probe.Encryption() {
+			if err := s.AddExtra(e); err != nil {
+				return err
+			}
+		}
+	}
+	if *evidencef {
+		if err := s.AddExtra(evidenceExtra(s)); err != nil {
+			return err
+		}
+	}
+	if *embeddedf {
+		if err := s.AddExtra(embeddedExtra(s)); err != nil {
+			return err
+		}
+	}
+	if *fuzzyf {
+		if err := s.AddExtra(fuzzyExtra(s)); err != nil {
+			return err
+		}
+	}
+	if *texttypef {
+		for _, e := range textTypeExtras() {
+			if err := s.AddExtra(e); err != nil {
+				return err
+			}
+		}
+	}
+	if *previewf != 0 {
+		e, err := previewExtra(*previewf)
+		if err != nil {
+			return err
+		}
+		if err = s.AddExtra(e); err != nil {
+			return err
+		}
+	}
+	if *codesf {
+		if err := s.AddExtra(codesExtra()); err != nil {
+			return err
+		}
+	}
+	if *whyf {
+		if err := s.AddExtra(whyExtra()); err != nil {
+			return err
+		}
+	}
+	if sampling != nil {
+		if err := s.AddExtra(sampleExtra(sampling)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extraFields returns the names of any additional fields reported for each file
+func extraFields(s *siegfried.Siegfried) []string {
+	ex, _ := extraSlots(s)
+	return ex
+}
+
+// slots are the indexes, in the extra fields, of the fields filled in by the printer (with -bag, -verify and -corpus)
+type slots struct {
+	bag, fixity, test int
+}
+
+// extraSlots returns the names of any additional fields reported for each file, and the slots of those filled in by the printer.
+// The siegfried is nil when results are replayed.
+func extraSlots(s *siegfried.Siegfried) ([]string, slots) {
+	var sl slots
+	var ex []string
+	if s != nil {
+		ex = s.ExtraFields()
+	}
+	for _, h := range extraHashes {
+		ex = append(ex, h.String())
+	}
+	if *dataf {
+		ex = append(ex, "declared")
+	}
+	if comparator != nil {
+		ex = append(ex, compareFields...)
+	}
+	if *recordsf {
+		ex = append(ex, decompress.RecordFields...)
+	}
+	if *summarise {
+		ex = append(ex, decompress.SummaryFields...)
+	}
+	if sequencing != nil {
+		ex = append(ex, seqFields...)
+	}
+	if bags != nil {
+		sl.bag = len(ex)
+		ex = append(ex, bagFields...)
+	}
+	if verifying != nil {
+		sl.fixity = len(ex)
+		ex = append(ex, "fixity")
+	}
+	if corpus != nil {
+		sl.test = len(ex)
+		ex = append(ex, "test")
+	}
+	return ex, sl
+}
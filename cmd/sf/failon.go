@@ -0,0 +1,92 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var failonf = flag.String("failon", "", "exit with code 4 if any file is unknown, has an extension mismatch or has an error, printing counts to stderr e.g. -failon unknown,mismatch,error")
+
+// exitFailOn is the exit code when files meet a -failon condition
+const exitFailOn = 4
+
+// failures counts the files that meet -failon conditions; it is nil if the -failon flag isn't given
+var failures *failOn
+
+var failConditions = []string{"unknown", "mismatch", "error"}
+
+// failOn counts the files that are unknown, have an extension (or filename) mismatch, or have errors, for the selected conditions.
+// A file with more than one identification counts once for each condition it meets.
+type failOn struct {
+	on     [3]bool
+	counts [3]int
+}
+
+func newFailOn(conditions string) (*failOn, error) {
+	f := &failOn{}
+outer:
+	for _, c := range strings.Split(conditions, ",") {
+		c = strings.TrimSpace(c)
+		for i, v := range failConditions {
+			if c == v {
+				f.on[i] = true
+				continue outer
+			}
+		}
+		return nil, fmt.Errorf("bad -failon condition %q; expecting one or more of %s", c, strings.Join(failConditions, ", "))
+	}
+	return f, nil
+}
+
+// add counts a file result. Directories (with a negative size) are only counted if they have errors.
+func (f *failOn) add(sz int64, err error, ids []core.Identification) {
+	var unknown, mismatch bool
+	for _, id := range ids {
+		if !id.Known() {
+			unknown = true
+		}
+		for _, c := range core.Codes(id) {
+			if c == core.ExtensionMismatch || c == core.FilenameMismatch {
+				mismatch = true
+			}
+		}
+	}
+	for i, b := range [3]bool{unknown && sz >= 0, mismatch, err != nil} {
+		if b && f.on[i] {
+			f.counts[i]++
+		}
+	}
+}
+
+// failed reports whether any files met the selected conditions
+func (f *failOn) failed() bool {
+	return f.counts[0]+f.counts[1]+f.counts[2] > 0
+}
+
+// String gives the counts for the selected conditions e.g. "2 unknown, 0 mismatch"
+func (f *failOn) String() string {
+	var strs []string
+	for i, c := range failConditions {
+		if f.on[i] {
+			strs = append(strs, fmt.Sprintf("%d %s", f.counts[i], c))
+		}
+	}
+	return strings.Join(strs, ", ")
+}
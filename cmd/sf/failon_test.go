@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestFailOn(t *testing.T) {
+	if _, err := newFailOn("unknown,bogus"); err == nil {
+		t.Error("expecting an error for a bad -failon condition")
+	}
+	f, err := newFailOn("unknown,mismatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.add(-1, nil, nil) // directory
+	f.add(5, nil, []core.Identification{cmpID{"fmt/18", ""}})
+	if f.failed() {
+		t.Errorf("expecting no failures, got %s", f)
+	}
+	f.add(5, errors.New("bad read"), []core.Identification{cmpID{"UNKNOWN", "no match"}})
+	f.add(5, nil, []core.Identification{cmpID{"fmt/18", "extension mismatch"}})
+	if !f.failed() || f.String() != "1 unknown, 1 mismatch" {
+		t.Errorf("expecting 1 unknown and 1 mismatch, got %s", f)
+	}
+}
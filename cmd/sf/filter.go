@@ -0,0 +1,232 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	excludef = flag.String("exclude", "", "skip files and directories that match comma separated glob patterns, or re: regular expressions, when walking directories e.g. -exclude .git,Thumbs.db,*.tmp")
+	includef = flag.String("include", "", "only identify files that match comma separated glob patterns, or re: regular expressions, when walking directories e.g. -include *.pdf,*.tif")
+	minsizef = flag.String("minsize", "", "only identify files of at least this size when walking directories e.g. -minsize 1KB")
+	maxsizef = flag.String("maxsize", "", "only identify files of at most this size when walking directories e.g. -maxsize 2GB")
+	newerf   = flag.String("newer", "", "only identify files modified after a time when walking directories: a date, an RFC3339 time, a duration before now, or the modified time of a file e.g. -newer 2023-05-01, -newer 7d or -newer results.csv")
+	olderf   = flag.String("older", "", "only identify files modified before a time when walking directories (see -newer) e.g. -older 2020-01-01")
+	hiddenf  = flag.Bool("hidden", true, "identify hidden files and directories when walking directories: -hidden=false skips dotfiles, OS metadata files (e.g. desktop.ini, Thumbs.db) and, on Windows, files with the hidden or system attribute")
+)
+
+// walkFilters prunes directory walks; it is nil if no filtering flags are given
+var walkFilters *walkFilter
+
+// walkFilter decides which files and directories are left out of a walk.
+// Excluded directories aren't descended into. Inclusion patterns, and size and modified time limits, apply to files only, so that all directories are walked.
+type walkFilter struct {
+	exclude  []pattern
+	include  []pattern
+	nohidden bool
+	minSize  int64 // -1 if not set
+	maxSize  int64 // -1 if not set
+	newer    time.Time
+	older    time.Time
+	log      func(path, reason string) // logs skipped paths (with -log skip)
+}
+
+// A pattern is a glob or, if prefixed with re:, a regular expression.
+// Globs without a slash match the base name of a file or directory (e.g. *.tmp); globs with a slash match the slash separated
+// path relative to the parent of the walked item (e.g. */cache/*). Regular expressions are matched (unanchored) against that relative path.
+type pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func newPatterns(flg, settings string) ([]pattern, error) {
+	var pats []pattern
+	for _, v := range strings.Split(settings, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.HasPrefix(v, "re:") {
+			re, err := regexp.Compile(v[3:])
+			if err != nil {
+				return nil, fmt.Errorf("bad -%s pattern %q: %v", flg, v, err)
+			}
+			pats = append(pats, pattern{re: re})
+			continue
+		}
+		if _, err := filepath.Match(v, ""); err != nil {
+			return nil, fmt.Errorf("bad -%s pattern %q: %v", flg, v, err)
+		}
+		pats = append(pats, pattern{glob: v})
+	}
+	return pats, nil
+}
+
+func (p pattern) match(rel string) bool {
+	if p.re != nil {
+		return p.re.MatchString(rel)
+	}
+	if strings.Contains(p.glob, "/") {
+		ok, _ := filepath.Match(p.glob, rel)
+		return ok
+	}
+	ok, _ := filepath.Match(p.glob, rel[strings.LastIndexByte(rel, '/')+1:])
+	return ok
+}
+
+func matchAny(pats []pattern, rel string) bool {
+	for _, p := range pats {
+		if p.match(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func newWalkFilter(exclude, include string) (*walkFilter, error) {
+	f := &walkFilter{minSize: -1, maxSize: -1}
+	var err error
+	if f.exclude, err = newPatterns("exclude", exclude); err != nil {
+		return nil, err
+	}
+	if f.include, err = newPatterns("include", include); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// setLimits sets the size and modified time filters
+func (f *walkFilter) setLimits(minsize, maxsize, newer, older string, now time.Time) error {
+	var err error
+	for _, v := range []struct {
+		flg, val string
+		sz       *int64
+		t        *time.Time
+	}{
+		{"minsize", minsize, &f.minSize, nil},
+		{"maxsize", maxsize, &f.maxSize, nil},
+		{"newer", newer, nil, &f.newer},
+		{"older", older, nil, &f.older},
+	} {
+		if v.val == "" {
+			continue
+		}
+		if v.sz != nil {
+			*v.sz, err = parseSize(v.val)
+		} else {
+			*v.t, err = parseTime(v.val, now)
+		}
+		if err != nil {
+			return fmt.Errorf("bad -%s %q: %v", v.flg, v.val, err)
+		}
+	}
+	return nil
+}
+
+// parseTime parses a date (e.g. 2023-05-01), an RFC3339 time, a duration before now (e.g. 36h or 7d) or, failing those, the path of a file
+// whose modified time is used (e.g. the results of the last scan).
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && strings.HasSuffix(s, "d") {
+		return now.AddDate(0, 0, -d), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	info, err := os.Stat(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expecting a date, time, duration or file e.g. 2023-05-01, 7d or results.csv")
+	}
+	return info.ModTime(), nil
+}
+
+// metadata files written by operating systems (compared case insensitively; dotfiles, like .DS_Store, are hidden anyway)
+var metaFiles = map[string]bool{"desktop.ini": true, "thumbs.db": true, "ehthumbs.db": true, "icon\r": true}
+
+// hidden reports whether a file or directory is hidden: a dotfile, an OS metadata file or, on Windows, a file with the hidden or system attribute
+func hidden(info os.FileInfo) bool {
+	name := info.Name()
+	return (strings.HasPrefix(name, ".") && name != "." && name != "..") || metaFiles[strings.ToLower(name)] || hiddenAttr(info)
+}
+
+// skip returns the reason a path in a walk of root should be left out, or an empty string. A walked directory is never skipped itself.
+func (f *walkFilter) skip(root, path string, info os.FileInfo) string {
+	if info.IsDir() && path == root {
+		return ""
+	}
+	if f.nohidden && hidden(info) {
+		return "hidden"
+	}
+	rel := filepath.Base(root)
+	if r, err := filepath.Rel(filepath.Dir(root), path); err == nil {
+		rel = r
+	}
+	rel = filepath.ToSlash(rel)
+	if matchAny(f.exclude, rel) {
+		return "excluded"
+	}
+	if info.IsDir() {
+		return ""
+	}
+	switch {
+	case len(f.include) > 0 && !matchAny(f.include, rel):
+		return "not included"
+	case f.minSize > -1 && info.Size() < f.minSize:
+		return "smaller than -minsize"
+	case f.maxSize > -1 && info.Size() > f.maxSize:
+		return "larger than -maxsize"
+	case !f.newer.IsZero() && !info.ModTime().After(f.newer):
+		return "not modified after -newer"
+	case !f.older.IsZero() && !info.ModTime().Before(f.older):
+		return "not modified before -older"
+	}
+	return ""
+}
+
+// filtered applies any walk filters; it reports whether to skip a path, and the error to return to filepath.Walk
+func filtered(root, path string, info os.FileInfo) (bool, error) {
+	return filter(root, path, info, true)
+}
+
+// filter is filtered, with the option not to log skipped paths (the progress meter's count walks the same paths)
+func filter(root, path string, info os.FileInfo, log bool) (bool, error) {
+	if walkFilters == nil {
+		return false, nil
+	}
+	reason := walkFilters.skip(root, path, info)
+	if reason == "" {
+		return false, nil
+	}
+	if log && walkFilters.log != nil {
+		walkFilters.log(path, reason)
+	}
+	if info.IsDir() {
+		return true, filepath.SkipDir
+	}
+	return true, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWalkFilter(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"a.pdf", "b.tmp", "Thumbs.db", ".git/config", "sub/c.pdf", "sub/d.txt", "cache/e.pdf"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755)
+		os.WriteFile(filepath.Join(dir, p), []byte("x"), 0644)
+	}
+	for _, v := range []struct {
+		exclude, include string
+		expect           []string
+	}{
+		{".git,Thumbs.db,*.tmp", "", []string{"a.pdf", "cache/e.pdf", "sub/c.pdf", "sub/d.txt"}},
+		{"*/cache", "*.pdf", []string{"a.pdf", "sub/c.pdf"}},
+		{`re:\.(txt|db)$`, "", []string{".git/config", "a.pdf", "b.tmp", "cache/e.pdf", "sub/c.pdf"}},
+	} {
+		f, err := newWalkFilter(v.exclude, v.include)
+		if err != nil {
+			t.Fatal(err)
+		}
+		walkFilters = f
+		var got []string
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if skip, err := filtered(dir, path, info); skip {
+				return err
+			}
+			if !info.IsDir() {
+				rel, _ := filepath.Rel(dir, path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		sort.Strings(got)
+		if strings.Join(got, "|") != strings.Join(v.expect, "|") {
+			t.Errorf("-exclude %s -include %s: expecting %v, got %v", v.exclude, v.include, v.expect, got)
+		}
+	}
+	walkFilters = nil
+	if _, err := newWalkFilter("[", ""); err == nil {
+		t.Error("expecting an error for a bad glob")
+	}
+	if _, err := newWalkFilter("", "re:("); err == nil {
+		t.Error("expecting an error for a bad regular expression")
+	}
+}
+
+func TestHidden(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"a.pdf", ".DS_Store", "Desktop.ini", "Thumbs.db", ".svn/entries", "sub/b.pdf"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755)
+		os.WriteFile(filepath.Join(dir, p), []byte("x"), 0644)
+	}
+	walkFilters, _ = newWalkFilter("", "")
+	walkFilters.nohidden = true
+	var got, skipped []string
+	walkFilters.log = func(path, reason string) {
+		skipped = append(skipped, filepath.Base(path)+" "+reason)
+	}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if skip, err := filtered(dir, path, info); skip {
+			return err
+		}
+		if !info.IsDir() {
+			got = append(got, info.Name())
+		}
+		return nil
+	})
+	walkFilters = nil
+	if strings.Join(got, "|") != "a.pdf|b.pdf" {
+		t.Errorf("expecting hidden files to be skipped, got %v", got)
+	}
+	if strings.Join(skipped, "|") != ".DS_Store hidden|.svn hidden|Desktop.ini hidden|Thumbs.db hidden" {
+		t.Errorf("bad skip log, got %v", skipped)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	ref := filepath.Join(t.TempDir(), "results.csv")
+	os.WriteFile(ref, nil, 0644)
+	mod := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(ref, mod, mod)
+	for in, expect := range map[string]time.Time{
+		"2023-05-01T00:00:00Z": time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+		"2023-05-01":           time.Date(2023, 5, 1, 0, 0, 0, 0, time.Local),
+		"7d":                   now.AddDate(0, 0, -7),
+		"36h":                  now.Add(-36 * time.Hour),
+		ref:                    mod,
+	} {
+		if got, err := parseTime(in, now); err != nil || !got.Equal(expect) {
+			t.Errorf("%s: expecting %v, got %v (%v)", in, expect, got, err)
+		}
+	}
+	if _, err := parseTime("yesterday", now); err == nil {
+		t.Error("expecting an error for a bad time")
+	}
+}
+
+func TestSizeAndTimeFilters(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, sz := range map[string]int{"empty": 0, "small": 10, "big": 2048, "old": 10} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, make([]byte, sz), 0644)
+		if name == "old" {
+			os.Chtimes(p, old, old)
+		}
+	}
+	for _, v := range []struct {
+		minsize, maxsize, newer, older string
+		expect                         string
+	}{
+		{"1", "1KB", "", "", "old|small"},
+		{"1", "", "2021-01-01", "", "big|small"},
+		{"", "", "", "2021-01-01", "old"},
+	} {
+		f, _ := newWalkFilter("", "")
+		if err := f.setLimits(v.minsize, v.maxsize, v.newer, v.older, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			info, _ := e.Info()
+			if f.skip(dir, filepath.Join(dir, e.Name()), info) == "" {
+				got = append(got, e.Name())
+			}
+		}
+		if strings.Join(got, "|") != v.expect {
+			t.Errorf("%v: expecting %s, got %v", v, v.expect, got)
+		}
+	}
+	if err := (&walkFilter{}).setLimits("big", "", "", "", time.Now()); err == nil {
+		t.Error("expecting an error for a bad size")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var followf = flag.Bool("follow", false, "follow symbolic links when walking directories (links to a directory that contains them are reported as cycles, and links to missing targets as dangling)")
+
+// isLink reports whether a walked path is a symbolic link that should be followed
+func isLink(info os.FileInfo) bool {
+	return *followf && info.Mode()&os.ModeSymlink != 0
+}
+
+// followLink returns the file info of a symbolic link's target.
+// It returns an error if the link is dangling or if the target is a directory that contains the link.
+func followLink(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			target, _ := os.Readlink(path)
+			return nil, fmt.Errorf("dangling symlink to %s", target)
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		if dir, ok := linkCycle(path); ok {
+			return nil, fmt.Errorf("symlink cycle: links to %s, which contains it", dir)
+		}
+	}
+	return info, nil
+}
+
+// linkCycle reports whether a link to a directory resolves to one of the directories on its own path, once links on that path are
+// resolved: following it would walk the same directories forever. This catches links to a parent as well as chains of links
+// between directories (e.g. a/l -> b and b/m -> a).
+func linkCycle(path string) (string, bool) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for p := filepath.Dir(abs); ; p = filepath.Dir(p) {
+		if r, err := filepath.EvalSymlinks(p); err == nil && r == target {
+			return target, true
+		}
+		if filepath.Dir(p) == p {
+			return "", false
+		}
+	}
+}
+
+// walkLink walks the contents of a linked directory, as filepath.Walk doesn't follow links.
+// Like filepath.Walk, it calls fn again for the directory if it can't be read.
+func walkLink(path string, fn filepath.WalkFunc) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return fn(path, nil, err)
+	}
+	for _, e := range entries {
+		if err := filepath.Walk(filepath.Join(path, e.Name()), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFollowLink(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "a", "sub"), 0755)
+	os.MkdirAll(filepath.Join(dir, "b"), 0755)
+	os.WriteFile(filepath.Join(dir, "a", "f.txt"), []byte("x"), 0644)
+	for link, target := range map[string]string{
+		"a/file":     "f.txt",
+		"a/tob":      "../b",
+		"b/toa":      "../a", // a chain: a/tob/toa is a
+		"a/sub/up":   "..",
+		"a/dangling": "missing.txt",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skip("can't make symlinks: ", err)
+		}
+	}
+	if info, err := followLink(filepath.Join(dir, "a", "file")); err != nil || info.IsDir() || info.Size() != 1 {
+		t.Errorf("expecting the linked file, got %v", err)
+	}
+	if info, err := followLink(filepath.Join(dir, "a", "tob")); err != nil || !info.IsDir() {
+		t.Errorf("expecting the linked directory, got %v", err)
+	}
+	for _, v := range []string{"a/sub/up", "a/tob/toa"} {
+		if _, err := followLink(filepath.Join(dir, v)); err == nil || !strings.Contains(err.Error(), "cycle") {
+			t.Errorf("%s: expecting a cycle, got %v", v, err)
+		}
+	}
+	if _, err := followLink(filepath.Join(dir, "a", "dangling")); err == nil || !strings.Contains(err.Error(), "dangling symlink to missing.txt") {
+		t.Errorf("expecting a dangling link, got %v", err)
+	}
+	// walkLink walks a linked directory's contents with the same walk function
+	var got []string
+	walkLink(filepath.Join(dir, "a", "tob"), func(path string, info os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+		return err
+	})
+	if strings.Join(got, "|") != "a/tob/toa" {
+		t.Errorf("bad walk of linked directory, got %v", got)
+	}
+}
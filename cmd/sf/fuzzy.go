@@ -0,0 +1,43 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var fuzzyf = flag.Bool("fuzzy", false, "report byte signatures that almost matched each file (e.g. that matched all but one segment) with a score")
+
+// fuzzyExtra reports, as a "fuzzy" field, the near misses for each file.
+// It scans the full content of the file.
+func fuzzyExtra(s *siegfried.Siegfried) siegfried.Extra {
+	return siegfried.Extra{
+		Name: "fuzzy",
+		Full: true,
+		Fn: func(c siegfried.Content, ids []core.Identification) string {
+			misses := s.NearMisses(c)
+			strs := make([]string, len(misses))
+			for i, m := range misses {
+				strs[i] = fmt.Sprintf("%s score %.2f (%s)", m.ID, m.Score, m.Basis)
+			}
+			return strings.Join(strs, "; ")
+		},
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/rpc"
+)
+
+var grpcf = flag.String("grpc", "", "start a gRPC identification service (see pkg/rpc/siegfried.proto) e.g. -grpc localhost:5139")
+
+// listenGRPC serves the gRPC identification service, secured with any -servetls and -servetoken settings, until it fails.
+// The signature file is reloaded on SIGHUP.
+func listenGRPC(addr string, s *siegfried.Siegfried) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	opts := []grpc.ServerOption{grpc.ForceServerCodec(rpc.Codec)}
+	if serveAuth.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(serveAuth.tls)))
+	}
+	if serveAuth.token != "" {
+		opts = append(opts, rpc.TokenAuth(serveAuth.token)...)
+	}
+	gs := grpc.NewServer(opts...)
+	srv := rpc.New(s)
+	srv.Register(gs)
+	reloadOnHangup(srv.Swap)
+	return gs.Serve(lis)
+}
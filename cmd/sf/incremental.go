@@ -0,0 +1,151 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var statef = flag.String("state", "", "scan incrementally: record the size, modified time and result of each file in this state file and, on later runs, skip unchanged files and report only new files and changed identifications e.g. -state collection.state")
+
+// incremental is the state of an incremental scan; it is nil if the -state flag isn't given
+var incremental *scanState
+
+// scanState records what is known about the files identified by previous runs. Files are unchanged if their size and modified time
+// are the same; a result is changed if the (FNV-1a) hash of its identifications and warnings differs.
+// If the state was written with a different signature file, no files are skipped but only changed identifications are reported.
+// Files with errors aren't recorded, so they are tried again. Entries for files that aren't visited are kept.
+type scanState struct {
+	sig  string                // the signature file (and its creation time) used by this scan
+	skip bool                  // previous results used the same signatures, so unchanged files can be skipped
+	prev map[string]stateEntry // read only during a scan (consulted by the walker and the printer)
+	next map[string]stateEntry // written by the printer
+}
+
+type stateEntry struct {
+	size int64
+	mod  int64 // unix nanoseconds
+	res  uint64
+}
+
+func loadState(path, sig string) (*scanState, error) {
+	st := &scanState{sig: sig, prev: make(map[string]stateEntry), next: make(map[string]stateEntry)}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	rdr := csv.NewReader(f)
+	rdr.Comma, rdr.FieldsPerRecord = '\t', -1
+	for first := true; ; first = false {
+		rec, err := rdr.Read()
+		if err == io.EOF {
+			return st, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad state file %s: %v", path, err)
+		}
+		if first && len(rec) == 2 && rec[0] == "signature" {
+			st.skip = rec[1] == sig
+			continue
+		}
+		if len(rec) != 4 {
+			return nil, fmt.Errorf("bad state file %s: expecting 4 fields, got %d", path, len(rec))
+		}
+		var e stateEntry
+		e.size, err = strconv.ParseInt(rec[1], 10, 64)
+		if err == nil {
+			e.mod, err = strconv.ParseInt(rec[2], 10, 64)
+		}
+		if err == nil {
+			e.res, err = strconv.ParseUint(rec[3], 16, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad state file %s: %v", path, err)
+		}
+		st.prev[rec[0]] = e
+	}
+}
+
+// unchanged is called by the walker: it reports whether a file can be skipped because it hasn't changed since the last run
+func (st *scanState) unchanged(path string, info os.FileInfo) bool {
+	if !st.skip {
+		return false
+	}
+	e, ok := st.prev[path]
+	return ok && e.size == info.Size() && e.mod == info.ModTime().UnixNano()
+}
+
+// record is called by the printer for each result: it reports whether the result is new or changed, and so should be reported
+func (st *scanState) record(path string, sz int64, mod time.Time, err error, ids []core.Identification) bool {
+	if err != nil || sz < 0 { // retry errors; and don't record directories
+		return true
+	}
+	h := fnv.New64a()
+	for _, id := range ids {
+		fmt.Fprintf(h, "%s\x00%s\x00", id.String(), id.Warn())
+	}
+	e := stateEntry{size: sz, mod: mod.UnixNano(), res: h.Sum64()}
+	st.next[path] = e
+	old, ok := st.prev[path]
+	return !ok || old.res != e.res
+}
+
+// save writes the state file, via a temporary file so that an interrupted save doesn't lose the state
+func (st *scanState) save(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Comma = '\t'
+	w.Write([]string{"signature", st.sig})
+	write := func(p string, e stateEntry) {
+		w.Write([]string{p, strconv.FormatInt(e.size, 10), strconv.FormatInt(e.mod, 10), strconv.FormatUint(e.res, 16)})
+	}
+	for p, e := range st.prev {
+		if _, ok := st.next[p]; !ok {
+			write(p, e)
+		}
+	}
+	for p, e := range st.next {
+		write(p, e)
+	}
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestScanState(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.state")
+	f := filepath.Join(dir, "a\tb.txt")
+	os.WriteFile(f, []byte("hello"), 0644)
+	info, _ := os.Stat(f)
+	st, err := loadState(path, "default.sig 2023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.unchanged(f, info) {
+		t.Error("expecting a new file not to be skipped")
+	}
+	txt := []core.Identification{cmpID{"x-fmt/111", ""}}
+	if !st.record(f, info.Size(), info.ModTime(), nil, txt) {
+		t.Error("expecting a new result to be reported")
+	}
+	st.record(filepath.Join(dir, "bad"), 5, time.Now(), errors.New("bad read"), nil)
+	if err := st.save(path); err != nil {
+		t.Fatal(err)
+	}
+	st, err = loadState(path, "default.sig 2023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.prev) != 1 || !st.unchanged(f, info) {
+		t.Errorf("expecting an unchanged file to be skipped, got %v", st.prev)
+	}
+	if st.record(f, info.Size(), time.Now(), nil, txt) {
+		t.Error("expecting an unchanged result not to be reported")
+	}
+	if !st.record(f, info.Size(), time.Now(), nil, []core.Identification{cmpID{"x-fmt/111", "extension mismatch"}}) {
+		t.Error("expecting a changed result to be reported")
+	}
+	// with a new signature file, nothing is skipped
+	st, _ = loadState(path, "default.sig 2024")
+	if st.unchanged(f, info) {
+		t.Error("expecting files to be rescanned with new signatures")
+	}
+}
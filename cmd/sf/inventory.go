@@ -0,0 +1,227 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	inventoryf = flag.Bool("inventory", false, "treat arguments as cloud storage inventories (S3 Inventory CSV, GCS or Azure CSV exports, or lists of s3:// or gs:// URLs) and identify each listed object e.g. sf -inventory inventory.csv.gz")
+	endpointf  = flag.String("endpoint", "", "set the URL template used to list s3:// prefixes and fetch inventory objects e.g. -endpoint \"https://minio.local/{bucket}/{key}\" (default is the S3 or GCS public endpoint)")
+)
+
+// Access tokens are given in the environment: SF_INVENTORY_TOKEN is sent as a bearer token for gs and az objects (e.g. for GCS or Azure AD),
+// and s3 objects are signed with the AWS credentials (see s3Creds). Azure SAS tokens, and other query string credentials, can be included
+// in the -endpoint template.
+const inventoryToken = "SF_INVENTORY_TOKEN"
+
+var (
+	s3Endpoint  = "https://{bucket}.s3.amazonaws.com/{key}"
+	gcsEndpoint = "https://storage.googleapis.com/{bucket}/{key}"
+)
+
+// an object listed in an inventory
+type object struct {
+	scheme string // s3, gs or az
+	bucket string
+	key    string
+	size   int64
+	mod    time.Time
+}
+
+func (o object) String() string {
+	if o.bucket == "" {
+		return o.scheme + ":" + o.key
+	}
+	return o.scheme + "://" + o.bucket + "/" + o.key
+}
+
+// objectURL expands an endpoint template with an object's bucket and (path escaped) key
+func objectURL(endpoint string, o object) (string, error) {
+	if endpoint == "" {
+		switch o.scheme {
+		case "s3":
+			endpoint = s3Endpoint
+		case "gs":
+			endpoint = gcsEndpoint
+		default:
+			return "", errors.New("an -endpoint is required for this inventory e.g. -endpoint \"https://account.blob.core.windows.net/container/{key}?sv=...\"")
+		}
+	}
+	segs := strings.Split(o.key, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.NewReplacer("{bucket}", o.bucket, "{key}", strings.Join(segs, "/")).Replace(endpoint), nil
+}
+
+// readInventory parses an inventory and calls fn for each object it lists. Inventories may be gzip compressed. Supported formats are:
+//   - S3 Inventory CSV files: rows without a header, starting with the bucket and (URL encoded) key
+//   - CSV files with a header row naming "name" or "key" columns and, optionally, "bucket", "size" and "updated" or "last-modified"
+//     columns (e.g. GCS Storage Insights and Azure blob inventory CSVs; objects without a bucket column are az objects)
+//   - lists of s3:// and gs:// URLs, one per line
+//
+// ORC and Parquet inventories are not supported.
+func readInventory(r io.Reader, fn func(object) error) error {
+	buf := bufio.NewReader(r)
+	magic, _ := buf.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return readInventory(gz, fn)
+	case bytes.HasPrefix(magic, []byte("PAR1")), bytes.HasPrefix(magic, []byte("ORC")):
+		return errors.New("ORC and Parquet inventories aren't supported: choose the CSV output format for the inventory")
+	}
+	rdr := csv.NewReader(buf)
+	rdr.FieldsPerRecord = -1
+	cols := map[string]int{"bucket": 0, "key": 1, "size": -1, "mod": -1}
+	s3 := true // without a header, this is an S3 Inventory
+	for first := true; ; first = false {
+		rec, err := rdr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			if hdr, ok := header(rec); ok {
+				cols, s3 = hdr, false
+				continue
+			}
+		}
+		o, ok := parseObject(rec, cols, s3)
+		if !ok {
+			continue
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+}
+
+// header maps the columns of a header row
+func header(rec []string) (map[string]int, bool) {
+	cols := map[string]int{"bucket": -1, "key": -1, "size": -1, "mod": -1}
+	for i, c := range rec {
+		switch strings.ToLower(strings.TrimSpace(c)) {
+		case "bucket":
+			cols["bucket"] = i
+		case "name", "key":
+			cols["key"] = i
+		case "size", "content-length":
+			cols["size"] = i
+		case "updated", "last-modified", "lastmodifieddate":
+			cols["mod"] = i
+		}
+	}
+	return cols, cols["key"] > -1
+}
+
+func parseObject(rec []string, cols map[string]int, s3 bool) (object, bool) {
+	field := func(k string) string {
+		if i := cols[k]; i > -1 && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	if len(rec) == 1 {
+		for _, scheme := range []string{"s3", "gs"} {
+			if u := strings.TrimPrefix(rec[0], scheme+"://"); u != rec[0] {
+				bucket, key, ok := strings.Cut(u, "/")
+				return object{scheme: scheme, bucket: bucket, key: key}, ok && key != "" && !strings.HasSuffix(key, "/")
+			}
+		}
+	}
+	o := object{bucket: field("bucket"), key: field("key")}
+	switch {
+	case s3:
+		// S3 Inventory keys are URL encoded
+		o.scheme = "s3"
+		if k, err := url.QueryUnescape(o.key); err == nil {
+			o.key = k
+		}
+	case cols["bucket"] < 0:
+		o.scheme = "az"
+	default:
+		o.scheme = "gs"
+	}
+	if o.key == "" || strings.HasSuffix(o.key, "/") {
+		return o, false
+	}
+	o.size, _ = strconv.ParseInt(field("size"), 10, 64)
+	o.mod, _ = time.Parse(time.RFC3339, field("mod"))
+	return o, true
+}
+
+// identifyInventory fetches and identifies the objects listed in an inventory file. As with identifyS3, objects are read with range
+// requests: s3 objects are signed with the AWS credentials (if given) and other objects are sent the SF_INVENTORY_TOKEN (if set).
+// Objects listed without a size are sized by their first range request.
+func identifyInventory(path string, ctxts chan *context) error {
+	f, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	c := newS3Client()
+	var bearer func(*http.Request)
+	if token := os.Getenv(inventoryToken); token != "" {
+		bearer = func(req *http.Request) {
+			req.Header.Add("Authorization", "Bearer "+token)
+		}
+	}
+	return readInventory(f, func(o object) error {
+		if !jnl.next() {
+			return nil
+		}
+		if jnl.stopped() {
+			return errBudget
+		}
+		u, err := objectURL(*endpointf, o)
+		if err != nil {
+			return err
+		}
+		r := &remote{url: u, client: c.client, transport: c.transport, timeout: c.timeout, sz: o.size, blocks: make(map[int64][]byte), auth: bearer}
+		if o.scheme == "s3" {
+			r.auth = c.auth
+		}
+		if o.size <= 0 {
+			identifyRemote(r, o.String(), o.mod, ctxts)
+			return nil
+		}
+		ctx := getCtx(o.String(), "", o.mod, o.size)
+		ctx.wg.Add(1)
+		ctxts <- ctx
+		identifyRdr(r, ctx, ctxts, getCtx)
+		return nil
+	})
+}
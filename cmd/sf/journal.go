@@ -0,0 +1,147 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	budgetf  = flag.Duration("budget", 0, "stop scanning after a wall-clock time: in-flight files are finished, results flushed and sf exits with code 3 e.g. -budget 2h")
+	journalf = flag.String("journal", "", "write a checkpoint to this file if a scan is stopped early (e.g. by -budget), and resume from it on the next run")
+)
+
+// exitBudget is the exit code when a scan is stopped by -budget
+const exitBudget = 3
+
+var errBudget = errors.New("scan time budget exceeded")
+
+// jnl tracks progress through the scan so that a stopped scan can be resumed.
+// Scans are made of items (the file and directory arguments, or the lines of -f lists) that are walked in lexical order.
+// A checkpoint records the current item and the last path dispatched for identification within it.
+var jnl = &journal{item: -1, resumeItem: -1}
+
+type journal struct {
+	deadline time.Time
+	item     int    // index of the current item
+	path     string // last path dispatched in the current item
+	// resume point
+	resumeItem int
+	resumePath string
+}
+
+// loadJournal reads a checkpoint, if one is present, and sets the deadline for the scan
+func loadJournal(path string, budget time.Duration) error {
+	if budget > 0 {
+		jnl.deadline = time.Now().Add(budget)
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, _ := strings.Cut(scanner.Text(), "=")
+		switch k {
+		case "item":
+			if jnl.resumeItem, err = strconv.Atoi(v); err != nil {
+				return fmt.Errorf("bad checkpoint %s: %v", path, err)
+			}
+		case "path":
+			jnl.resumePath = v
+		}
+	}
+	return scanner.Err()
+}
+
+// save writes a checkpoint
+func (j *journal) save(path string) error {
+	return os.WriteFile(path, []byte(fmt.Sprintf("item=%d\npath=%s\n", j.item, j.path)), 0644)
+}
+
+// done removes the checkpoint once a scan is complete
+func (j *journal) done(path string) {
+	if path != "" {
+		os.Remove(path)
+	}
+}
+
+func (j *journal) stopped() bool {
+	return !j.deadline.IsZero() && time.Now().After(j.deadline)
+}
+
+// next starts the next item. It returns false if the item was completed by a previous run.
+func (j *journal) next() bool {
+	j.item++
+	j.path = ""
+	return j.item >= j.resumeItem
+}
+
+// visit is called for each path in a walk. It returns errBudget if the scan should stop, filepath.SkipDir
+// for directories that were completed by a previous run, and true if a file was completed by a previous run.
+func (j *journal) visit(path string, dir bool) (bool, error) {
+	if j.stopped() {
+		return false, errBudget
+	}
+	if j.item != j.resumeItem || j.resumePath == "" {
+		if !dir {
+			j.path = path
+		}
+		return false, nil
+	}
+	if dir {
+		// skip directories that were walked completely
+		if walkBefore(path, j.resumePath) && !strings.HasPrefix(j.resumePath, path+string(filepath.Separator)) {
+			return false, filepath.SkipDir
+		}
+		return false, nil
+	}
+	if path == j.resumePath {
+		j.resumePath = "" // resume from the next file
+		return true, nil
+	}
+	if walkBefore(path, j.resumePath) {
+		return true, nil
+	}
+	j.resumePath = ""
+	j.path = path
+	return false, nil
+}
+
+// walkBefore reports whether a is visited before b by filepath.Walk, which walks each directory in lexical order
+func walkBefore(a, b string) bool {
+	as, bs := strings.Split(a, string(filepath.Separator)), strings.Split(b, string(filepath.Separator))
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
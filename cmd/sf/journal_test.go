@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWalkBefore(t *testing.T) {
+	sep := string(filepath.Separator)
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{"a" + sep + "b", "a" + sep + "c", true},
+		{"a" + sep + "z", "a-b", true}, // a sorts before a-b, so all of a's contents are walked first
+		{"a-b", "a" + sep + "z", false},
+		{"a", "a" + sep + "b", true},
+		{"a" + sep + "b", "a", false},
+	} {
+		if got := walkBefore(c.a, c.b); got != c.want {
+			t.Errorf("walkBefore(%s, %s): got %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []string{"a/1", "a/2", "b/1", "b/2", "c"} {
+		p := filepath.Join(dir, filepath.FromSlash(n))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte("x"), 0644)
+	}
+	walk := func(j *journal) ([]string, error) {
+		var files []string
+		j.next()
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			skip, err := j.visit(path, info.IsDir())
+			if skip || err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		return files, err
+	}
+	// stop after three files have been dispatched
+	j := &journal{item: -1, resumeItem: -1}
+	var first []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if !info.IsDir() {
+			first = append(first, path)
+		}
+		return nil
+	})
+	j.next()
+	j.path = first[2]
+	ckpt := filepath.Join(dir, "ckpt")
+	if err := j.save(ckpt); err != nil {
+		t.Fatal(err)
+	}
+	jnl = &journal{item: -1, resumeItem: -1}
+	defer func() { jnl = &journal{item: -1, resumeItem: -1} }()
+	if err := loadJournal(ckpt, 0); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(ckpt)
+	files, err := walk(jnl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0] != first[3] || files[1] != first[4] {
+		t.Errorf("expecting resume from %s, got %v", first[3], files)
+	}
+	// an expired budget stops the walk
+	jnl = &journal{item: -1, resumeItem: -1, deadline: time.Now().Add(-time.Second)}
+	if _, err := walk(jnl); err != errBudget {
+		t.Errorf("expecting errBudget, got %v", err)
+	}
+}
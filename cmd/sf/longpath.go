@@ -28,7 +28,8 @@ func retryOpen(path string, err error) (*os.File, error) {
 }
 
 func identify(ctxts chan *context, root, orig string, coerr, norecurse, droid bool, gf getFn) error {
-	walkFunc := func(path string, info os.FileInfo, err error) error {
+	var walkFunc filepath.WalkFunc
+	walkFunc = func(path string, info os.FileInfo, err error) error {
 		if *throttlef > 0 {
 			<-throttle.C
 		}
@@ -39,13 +40,43 @@ func identify(ctxts chan *context, root, orig string, coerr, norecurse, droid bo
 			}
 			return walkError{path, err}
 		}
+		if skip, err := filtered(root, path, info); skip {
+			return err
+		}
+		link := isLink(info)
+		if link {
+			if info, err = followLink(path); err != nil {
+				printFile(ctxts, gf(path, "", time.Time{}, 0), err)
+				return nil
+			}
+		}
+		if sampling != nil && sampling.skip(path, info.IsDir()) {
+			return nil
+		}
+		if skip, err := jnl.visit(path, info.IsDir()); skip || err != nil {
+			if link && err == filepath.SkipDir {
+				return nil // SkipDir would skip the rest of the link's directory
+			}
+			return err
+		}
 		if info.IsDir() {
 			if norecurse && path != root {
+				if link {
+					return nil
+				}
 				return filepath.SkipDir
 			}
 			if droid {
 				printFile(ctxts, gf(path, "", info.ModTime(), -1), nil)
 			}
+			if bags != nil {
+				if err := bags.dir(path); err != nil {
+					printFile(ctxts, gf(path, "", info.ModTime(), -1), err)
+				}
+			}
+			if link {
+				return walkLink(path, walkFunc)
+			}
 			return nil
 		}
 		// zero user read permissions mask, octal 400 (decimal 256)
@@ -53,8 +84,22 @@ func identify(ctxts chan *context, root, orig string, coerr, norecurse, droid bo
 			printFile(ctxts, gf(path, "", info.ModTime(), info.Size()), modeError(info.Mode()))
 			return nil
 		}
-		identifyFile(gf(path, "", info.ModTime(), info.Size()), ctxts, gf)
+		if incremental != nil && incremental.unchanged(path, info) {
+			return nil
+		}
+		if sequencing != nil && (bags == nil || bags.find(path) == nil) && sequencing.file(ctxts, path, info.ModTime(), info.Size(), gf) {
+			return nil
+		}
+		ctx := gf(path, "", info.ModTime(), info.Size())
+		if bags != nil {
+			bags.file(ctx)
+		}
+		identifyFile(ctx, ctxts, gf)
 		return nil
 	}
-	return filepath.Walk(root, walkFunc)
+	err := filepath.Walk(root, walkFunc)
+	if sequencing != nil {
+		sequencing.flush(ctxts, gf)
+	}
+	return err
 }
@@ -76,7 +76,8 @@ func retryOpen(path string, err error) (*os.File, error) {
 }
 
 func identify(ctxts chan *context, root, orig string, coerr, norecurse, droid bool, gf getFn) error {
-	walkFunc := func(path string, info os.FileInfo, err error) error {
+	var walkFunc filepath.WalkFunc
+	walkFunc = func(path string, info os.FileInfo, err error) error {
 		var retry bool
 		var lp, sp string
 		if *throttlef > 0 {
@@ -94,8 +95,30 @@ func identify(ctxts chan *context, root, orig string, coerr, norecurse, droid bo
 			lp, sp = longpath(path), path
 			retry = true
 		}
+		if skip, err := filtered(root, path, info); skip {
+			return err
+		}
+		link := isLink(info)
+		if link {
+			if info, err = followLink(path); err != nil {
+				printFile(ctxts, gf(shortpath(path, orig), "", time.Time{}, 0), err)
+				return nil
+			}
+		}
+		if sampling != nil && sampling.skip(shortpath(path, orig), info.IsDir()) {
+			return nil
+		}
+		if skip, err := jnl.visit(shortpath(path, orig), info.IsDir()); skip || err != nil {
+			if link && err == filepath.SkipDir {
+				return nil // SkipDir would skip the rest of the link's directory
+			}
+			return err
+		}
 		if info.IsDir() {
 			if norecurse && path != root {
+				if link {
+					return nil
+				}
 				return filepath.SkipDir
 			}
 			if retry { // if a dir long path, restart the recursion with a long path as the new root
@@ -104,14 +127,36 @@ func identify(ctxts chan *context, root, orig string, coerr, norecurse, droid bo
 			if droid {
 				printFile(ctxts, gf(shortpath(path, orig), "", info.ModTime(), -1), nil)
 			}
+			if bags != nil {
+				if err := bags.dir(shortpath(path, orig)); err != nil {
+					printFile(ctxts, gf(shortpath(path, orig), "", info.ModTime(), -1), err)
+				}
+			}
+			if link {
+				return walkLink(path, walkFunc)
+			}
 			return nil
 		}
 		if !info.Mode().IsRegular() {
 			printFile(ctxts, gf(path, "", info.ModTime(), info.Size()), modeError(info.Mode()))
 			return nil
 		}
-		identifyFile(gf(shortpath(path, orig), "", info.ModTime(), info.Size()), ctxts, gf)
+		if incremental != nil && incremental.unchanged(shortpath(path, orig), info) {
+			return nil
+		}
+		if sequencing != nil && (bags == nil || bags.find(shortpath(path, orig)) == nil) && sequencing.file(ctxts, shortpath(path, orig), info.ModTime(), info.Size(), gf) {
+			return nil
+		}
+		ctx := gf(shortpath(path, orig), "", info.ModTime(), info.Size())
+		if bags != nil {
+			bags.file(ctx)
+		}
+		identifyFile(ctx, ctxts, gf)
 		return nil
 	}
-	return filepath.Walk(root, walkFunc)
+	err := filepath.Walk(root, walkFunc)
+	if sequencing != nil {
+		sequencing.flush(ctxts, gf)
+	}
+	return err
 }
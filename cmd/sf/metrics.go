@@ -0,0 +1,229 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// metrics counts the files identified and the requests handled in server mode, for the /metrics endpoint; it is nil
+// unless serving
+var metrics *serverMetrics
+
+// latencyBuckets are the upper bounds, in seconds, of the request duration histogram. Requests to scan a directory
+// can take minutes so the buckets run longer than is usual for web services.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+type idKey struct {
+	namespace, id string
+}
+
+type requestKey struct {
+	handler string
+	code    int
+}
+
+type histogram struct {
+	counts []int64 // per bucket (not cumulative), with a final bucket for +Inf
+	sum    float64
+}
+
+// serverMetrics are reported in the Prometheus text exposition format.
+type serverMetrics struct {
+	mu        sync.Mutex
+	start     time.Time
+	files     int64
+	bytes     int64
+	errors    int64
+	ids       map[idKey]int64
+	requests  map[requestKey]int64
+	durations map[string]*histogram
+}
+
+func newMetrics() *serverMetrics {
+	return &serverMetrics{
+		start:     time.Now(),
+		ids:       make(map[idKey]int64),
+		requests:  make(map[requestKey]int64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+// add counts a file result. Directories (with a negative size and no identifications) aren't counted.
+func (m *serverMetrics) add(sz int64, err error, ids []core.Identification) {
+	if sz < 0 && ids == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files++
+	if sz > 0 {
+		m.bytes += sz
+	}
+	if err != nil {
+		m.errors++
+	}
+	for _, id := range ids {
+		var ns string
+		if vals := id.Values(); len(vals) > 0 {
+			ns = vals[0]
+		}
+		m.ids[idKey{ns, id.String()}]++
+	}
+}
+
+// request counts a request to a handler, with its status code and duration.
+func (m *serverMetrics) request(handler string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{handler, code}]++
+	h, ok := m.durations[handler]
+	if !ok {
+		h = &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+		m.durations[handler] = h
+	}
+	secs := d.Seconds()
+	h.sum += secs
+	h.counts[sort.SearchFloat64s(latencyBuckets, secs)]++
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelReplacer.Replace(value) + `"`
+}
+
+func writeMetric(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v := config.Version()
+	writeMetric(w, "siegfried_info", "gauge", "The siegfried version and signature file.")
+	fmt.Fprintf(w, "siegfried_info{%s,%s} 1\n", label("version", fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])), label("signature", config.SignatureBase()))
+	writeMetric(w, "siegfried_start_time_seconds", "gauge", "When the server started, in seconds since the Unix epoch.")
+	fmt.Fprintf(w, "siegfried_start_time_seconds %d\n", m.start.Unix())
+	writeMetric(w, "siegfried_files_total", "counter", "Files identified, including archive members.")
+	fmt.Fprintf(w, "siegfried_files_total %d\n", m.files)
+	writeMetric(w, "siegfried_bytes_total", "counter", "Total size in bytes of the files identified.")
+	fmt.Fprintf(w, "siegfried_bytes_total %d\n", m.bytes)
+	writeMetric(w, "siegfried_file_errors_total", "counter", "Files with errors (e.g. that couldn't be read).")
+	fmt.Fprintf(w, "siegfried_file_errors_total %d\n", m.errors)
+	writeMetric(w, "siegfried_identifications_total", "counter", "Identifications, by identifier namespace and format ID (e.g. PUID).")
+	ids := make([]idKey, 0, len(m.ids))
+	for k := range m.ids {
+		ids = append(ids, k)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].namespace == ids[j].namespace {
+			return ids[i].id < ids[j].id
+		}
+		return ids[i].namespace < ids[j].namespace
+	})
+	for _, k := range ids {
+		fmt.Fprintf(w, "siegfried_identifications_total{%s,%s} %d\n", label("namespace", k.namespace), label("id", k.id), m.ids[k])
+	}
+	writeMetric(w, "siegfried_http_requests_total", "counter", "HTTP requests, by handler and status code.")
+	reqs := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		reqs = append(reqs, k)
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		if reqs[i].handler == reqs[j].handler {
+			return reqs[i].code < reqs[j].code
+		}
+		return reqs[i].handler < reqs[j].handler
+	})
+	for _, k := range reqs {
+		fmt.Fprintf(w, "siegfried_http_requests_total{%s,%s} %d\n", label("handler", k.handler), label("code", strconv.Itoa(k.code)), m.requests[k])
+	}
+	writeMetric(w, "siegfried_http_request_duration_seconds", "histogram", "HTTP request durations, by handler.")
+	handlers := make([]string, 0, len(m.durations))
+	for k := range m.durations {
+		handlers = append(handlers, k)
+	}
+	sort.Strings(handlers)
+	for _, hn := range handlers {
+		h := m.durations[hn]
+		var cum int64
+		for i, c := range h.counts {
+			cum += c
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = strconv.FormatFloat(latencyBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "siegfried_http_request_duration_seconds_bucket{%s,%s} %d\n", label("handler", hn), label("le", le), cum)
+		}
+		fmt.Fprintf(w, "siegfried_http_request_duration_seconds_sum{%s} %g\n", label("handler", hn), h.sum)
+		fmt.Fprintf(w, "siegfried_http_request_duration_seconds_count{%s} %d\n", label("handler", hn), cum)
+	}
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.write(w)
+}
+
+// statusRecorder records the status code of a response. It passes on Hijack, for WebSocket connections (see handleProgress),
+// and Flush.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response can't be hijacked")
+	}
+	if s.code == 0 {
+		s.code = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/pronom"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	m.add(100, nil, []core.Identification{pronom.Identification{Namespace: "pronom", ID: "fmt/18"}})
+	m.add(50, errors.New("oops"), []core.Identification{pronom.Identification{Namespace: "pronom", ID: "fmt/18"}})
+	m.add(-1, nil, nil) // a directory
+	m.request("identify", 200, 20*time.Millisecond)
+	m.request("identify", 404, 2*time.Second)
+	buf := &strings.Builder{}
+	m.write(buf)
+	out := buf.String()
+	for _, expect := range []string{
+		"siegfried_files_total 2\n",
+		"siegfried_bytes_total 150\n",
+		"siegfried_file_errors_total 1\n",
+		`siegfried_identifications_total{namespace="pronom",id="fmt/18"} 2` + "\n",
+		`siegfried_http_requests_total{handler="identify",code="404"} 1` + "\n",
+		`siegfried_http_request_duration_seconds_bucket{handler="identify",le="0.01"} 0` + "\n",
+		`siegfried_http_request_duration_seconds_bucket{handler="identify",le="0.025"} 1` + "\n",
+		`siegfried_http_request_duration_seconds_bucket{handler="identify",le="2.5"} 2` + "\n",
+		`siegfried_http_request_duration_seconds_bucket{handler="identify",le="+Inf"} 2` + "\n",
+		`siegfried_http_request_duration_seconds_count{handler="identify"} 2` + "\n",
+		"# TYPE siegfried_http_request_duration_seconds histogram\n",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("expecting %q in metrics, got:\n%s", expect, out)
+		}
+	}
+	if l := label("path", "a \"b\"\\c\n"); l != `path="a \"b\"\\c\n"` {
+		t.Errorf("bad label escaping, got %s", l)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	metrics = newMetrics()
+	defer func() { metrics = nil }()
+	srv := httptest.NewServer(&muxer{})
+	defer srv.Close()
+	for _, p := range []string{"/", "/nothing"} {
+		resp, err := http.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+	buf := &strings.Builder{}
+	metrics.write(buf)
+	for _, expect := range []string{
+		`siegfried_http_requests_total{handler="main",code="200"} 1`,
+		`siegfried_http_requests_total{handler="other",code="404"} 1`,
+		`siegfried_http_requests_total{handler="metrics",code="200"} 1`,
+	} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("expecting %q in metrics, got:\n%s", expect, buf.String())
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var namesf = flag.String("names", "", "override format names with a CSV file of IDs and display names e.g. -names names.csv")
+
+// loadNames reads a CSV file of format IDs (e.g. PUIDs) and display names, one pair per row.
+// Blank lines and lines beginning with # are ignored. Entries for IDs that aren't in the known list are returned as unknown
+// (an empty known list, e.g. when replaying results, means the IDs can't be checked).
+func loadNames(path string, known []string) (map[string]string, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	rdr := csv.NewReader(f)
+	rdr.Comment = '#'
+	rdr.FieldsPerRecord = 2
+	rdr.TrimLeadingSpace = true
+	recs, err := rdr.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading names file %s: %v", path, err)
+	}
+	knownIDs := make(map[string]bool, len(known))
+	for _, k := range known {
+		knownIDs[k] = true
+	}
+	names := make(map[string]string, len(recs))
+	var unknown []string
+	for _, rec := range recs {
+		id := strings.TrimSpace(rec[0])
+		names[id] = rec[1]
+		if len(known) > 0 && !knownIDs[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	return names, unknown, nil
+}
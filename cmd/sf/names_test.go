@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names.csv")
+	if err := os.WriteFile(path, []byte("# PUID, display name\nfmt/18, \"PDF, version 1.4\"\n\nfmt/99999,Unknown format\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	names, unknown, err := loadNames(path, []string{"fmt/18", "fmt/19"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names["fmt/18"] != "PDF, version 1.4" {
+		t.Errorf("bad names, got %v", names)
+	}
+	if len(unknown) != 1 || unknown[0] != "fmt/99999" {
+		t.Errorf("expecting fmt/99999 to be unknown, got %v", unknown)
+	}
+	if _, unknown, _ = loadNames(path, nil); unknown != nil {
+		t.Errorf("expecting no unknowns without a list of known formats, got %v", unknown)
+	}
+	os.WriteFile(path, []byte("fmt/18\n"), 0644)
+	if _, _, err = loadNames(path, nil); err == nil {
+		t.Error("expecting an error for a row without a display name")
+	}
+}
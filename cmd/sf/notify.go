@@ -0,0 +1,128 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var notifyf = flag.String("notify", "", "POST a JSON summary (counts of files, bytes, errors and unknowns, and the output files) to a URL when a scan completes or, with -watch, when each drop of files has been identified e.g. -notify http://localhost:8080/done")
+
+// notifying counts results for -notify; it is nil if the flag isn't given
+var notifying *notifier
+
+// notifier counts the files identified since its last summary, and POSTs summaries as JSON.
+type notifier struct {
+	url    string
+	client *http.Client
+	mu     sync.Mutex // the printer counts while the watcher summarises
+	start  time.Time
+	counts counts
+}
+
+type counts struct {
+	Files   int64 `json:"files"`
+	Bytes   int64 `json:"bytes"`
+	Errors  int64 `json:"errors"`
+	Unknown int64 `json:"unknown"`
+}
+
+// summary is the JSON posted to the -notify URL
+type summary struct {
+	Event     string   `json:"event"`  // scan or watch
+	Status    string   `json:"status"` // completed, stopped (e.g. by -budget) or failed
+	Error     string   `json:"error,omitempty"`
+	Started   string   `json:"started"`
+	Finished  string   `json:"finished"`
+	Paths     []string `json:"paths,omitempty"`
+	Signature string   `json:"signature"`
+	counts
+	Outputs []string `json:"outputs,omitempty"`
+}
+
+func newNotifier(url string) *notifier {
+	return &notifier{url: url, client: &http.Client{Timeout: 30 * time.Second}, start: time.Now()}
+}
+
+// add counts a file result. Directories (with a negative size) are only counted if they have errors.
+func (n *notifier) add(sz int64, err error, ids []core.Identification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		n.counts.Errors++
+	}
+	if sz < 0 {
+		return
+	}
+	n.counts.Files++
+	n.counts.Bytes += sz
+	for _, id := range ids {
+		if !id.Known() {
+			n.counts.Unknown++
+			break
+		}
+	}
+}
+
+// summary summarises, and resets, the counts since the last summary.
+func (n *notifier) summary(event, status string, err error, paths []string) summary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	s := summary{
+		Event:     event,
+		Status:    status,
+		Started:   n.start.Format(time.RFC3339),
+		Finished:  now.Format(time.RFC3339),
+		Paths:     paths,
+		Signature: config.SignatureBase(),
+		counts:    n.counts,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	for _, p := range outputPaths() {
+		if abs, e := filepath.Abs(p); e == nil {
+			p = abs
+		}
+		s.Outputs = append(s.Outputs, p)
+	}
+	n.start, n.counts = now, counts{}
+	return s
+}
+
+// send POSTs a summary. Summaries that can't be sent are logged, and not retried.
+func (n *notifier) send(s summary) {
+	byts, _ := json.Marshal(s)
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(byts))
+	if err != nil {
+		log.Printf("[WARN] -notify: error posting summary: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("[WARN] -notify: error posting summary: %s\n", resp.Status)
+	}
+}
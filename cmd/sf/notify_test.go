@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richardlehane/siegfried/internal/logger"
+	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/pronom"
+)
+
+// notifyServer records the summaries posted to it
+func notifyServer(t *testing.T) (*httptest.Server, chan summary) {
+	got := make(chan summary, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s summary
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("bad summary: %v", err)
+		}
+		got <- s
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func TestNotifier(t *testing.T) {
+	srv, got := notifyServer(t)
+	n := newNotifier(srv.URL)
+	n.add(100, nil, []core.Identification{pronom.Identification{ID: "fmt/18"}})
+	n.add(50, errors.New("oops"), []core.Identification{pronom.Identification{ID: "UNKNOWN"}})
+	n.add(-1, nil, nil) // a directory
+	n.send(n.summary("scan", "failed", errors.New("bad"), []string{"data"}))
+	s := <-got
+	if s.Event != "scan" || s.Status != "failed" || s.Error != "bad" || len(s.Paths) != 1 || s.Signature == "" {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if s.counts != (counts{Files: 2, Bytes: 150, Errors: 1, Unknown: 1}) {
+		t.Errorf("unexpected counts %+v", s.counts)
+	}
+	// counts are reset by a summary
+	if s := n.summary("watch", "completed", nil, nil); s.counts != (counts{}) || s.Error != "" {
+		t.Errorf("expecting counts to be reset, got %+v", s)
+	}
+}
+
+func TestWatchNotify(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	srv, got := notifyServer(t)
+	notifying = newNotifier(srv.URL)
+	defer func() { notifying = nil }()
+	dir := t.TempDir()
+	lg, _ := logger.New("")
+	ctxts := make(chan *context, 10)
+	printed := make(chan struct{})
+	go func() {
+		printer(ctxts, lg)
+		close(printed)
+	}()
+	defer func() {
+		close(ctxts)
+		<-printed // the summary is sent before the printer is done with the file's context
+	}()
+	setCtxPool(s, &sync.WaitGroup{}, make(nameWriter, 10), false, false, -1)
+	w, err := newWatcher(s, dir, 100*time.Millisecond, false, false, ctxts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- w.run(stop) }()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello world"), 0644)
+	select {
+	case s := <-got:
+		if s.Event != "watch" || s.Status != "completed" || s.Files != 1 || s.Bytes != 11 || len(s.Paths) != 1 || s.Paths[0] != dir {
+			t.Errorf("unexpected summary %+v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expecting a summary once the file is identified")
+	}
+	close(stop)
+	<-done
+}
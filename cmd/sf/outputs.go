@@ -0,0 +1,175 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/siegfried/pkg/writer"
+)
+
+// outFlag is an output format flag. Given on its own (e.g. -csv) it selects the format for stdout;
+// given a path (e.g. -csv=results.csv) the format is written to that file, alongside any other outputs.
+type outFlag struct {
+	on   bool
+	path string
+}
+
+func (o *outFlag) String() string {
+	if o == nil {
+		return "false"
+	}
+	if o.path != "" {
+		return o.path
+	}
+	return strconv.FormatBool(o.on)
+}
+
+func (o *outFlag) Set(s string) error {
+	if b, err := strconv.ParseBool(s); err == nil {
+		o.on, o.path = b, ""
+		return nil
+	}
+	o.on, o.path = true, s
+	return nil
+}
+
+func (o *outFlag) IsBoolFlag() bool { return true }
+
+func outputFlag(name string, value bool, usage string) *outFlag {
+	o := &outFlag{on: value}
+	flag.Var(o, name, fmt.Sprintf("%s (or -%s=FILE to write to a file)", usage, name))
+	return o
+}
+
+type output struct {
+	name string
+	flag *outFlag
+	fn   func(io.Writer) writer.Writer
+}
+
+// outputs lists the output formats, in order of precedence for stdout
+func outputs() []output {
+	csvFn := writer.CSV
+	if *csvfields != "" {
+		csvFn = func(w io.Writer) writer.Writer { return writer.CSVFields(w, strings.Split(*csvfields, ",")) }
+	}
+	jsonFn, jsonlFn := writer.JSON, writer.JSONL
+	if *basesf {
+		jsonFn, jsonlFn = writer.JSONBases, writer.JSONLBases
+	}
+	return []output{
+		{"csv", csvo, csvFn},
+		{"json", jsono, jsonFn},
+		{"jsonl", jsonlo, jsonlFn},
+		{"droid", droido, writer.Droid},
+		{"fido", fidoo, writer.Fido},
+		{"dot", doto, writer.DOT},
+		{"premis", premiso, func(w io.Writer) writer.Writer { return writer.PREMIS(w, false) }},
+		{"mets", metso, func(w io.Writer) writer.Writer { return writer.PREMIS(w, true) }},
+	}
+}
+
+// openOutputs makes the writers for a scan: a writer to a file for each output flag given a path (and for -sqlite),
+// and a writer to stdout for the first output flag given without one. YAML is written to stdout if there is no other output
+// or if -yaml is given explicitly. Nothing is written to stdout if the logger is using it.
+// The files are returned so they can be closed once the writer's Tail has been called.
+func openOutputs(logOut bool) (writer.Writer, []*os.File, error) {
+	var (
+		ws    []writer.Writer
+		files []*os.File
+		out   writer.Writer
+	)
+	create := func(path string) (*os.File, error) {
+		f, err := os.Create(path)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+		return f, nil
+	}
+	for _, o := range outputs() {
+		if !o.flag.on {
+			continue
+		}
+		if o.flag.path == "" {
+			if out == nil {
+				out = o.fn(os.Stdout)
+			}
+			continue
+		}
+		f, err := create(o.flag.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		ws = append(ws, o.fn(f))
+	}
+	if yamlo.path != "" {
+		f, err := create(yamlo.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		ws = append(ws, writer.YAML(f))
+	}
+	if *sqlitef != "" {
+		f, err := create(*sqlitef)
+		if err != nil {
+			return nil, nil, err
+		}
+		ws = append(ws, writer.SQLite(f))
+	}
+	if out == nil && *csvfields != "" && !csvo.on {
+		out = writer.CSVFields(os.Stdout, strings.Split(*csvfields, ","))
+	}
+	if out == nil && *templatef != "" {
+		t, err := parseTemplate(*templatef)
+		if err != nil {
+			return nil, files, fmt.Errorf("error parsing template: %v", err)
+		}
+		out = writer.Template(os.Stdout, t)
+	}
+	if out == nil && yamlo.path == "" && (len(ws) == 0 || explicit("yaml")) {
+		out = writer.YAML(os.Stdout)
+	}
+	if out != nil && !logOut {
+		ws = append([]writer.Writer{out}, ws...)
+	}
+	switch len(ws) {
+	case 0:
+		return writer.Null(), files, nil
+	case 1:
+		return ws[0], files, nil
+	}
+	return writer.Multi(ws...), files, nil
+}
+
+// explicit reports whether a flag has been set, on the command line or in the conf file
+func explicit(name string) bool {
+	var ret bool
+	flag.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			ret = true
+		}
+	})
+	return ret
+}
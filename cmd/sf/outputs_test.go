@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestOutFlag(t *testing.T) {
+	o := &outFlag{}
+	for _, v := range []struct {
+		set  string
+		on   bool
+		path string
+	}{
+		{"true", true, ""},
+		{"results.csv", true, "results.csv"},
+		{"false", false, ""},
+	} {
+		if err := o.Set(v.set); err != nil {
+			t.Fatal(err)
+		}
+		if o.on != v.on || o.path != v.path {
+			t.Errorf("setting %s: got %v", v.set, o)
+		}
+		// conf files store flags as their String values
+		if o.String() != v.set {
+			t.Errorf("expecting %s, got %s", v.set, o.String())
+		}
+	}
+}
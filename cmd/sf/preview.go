@@ -0,0 +1,68 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var previewf = flag.Int("preview", 0, "report the first N bytes of unidentified files, in hex and as printable characters, in a preview field (e.g. to draft new signatures) e.g. -preview 32 -json")
+
+// maxPreview is the most bytes a preview can show (within siegfried.WindowSize, so extras needn't read full content)
+const maxPreview = 4096
+
+// previewExtra reports, as a "preview" field, the first n bytes of files that no identifier could identify.
+// The field is empty for identified files.
+func previewExtra(n int) (siegfried.Extra, error) {
+	if n < 1 || n > maxPreview {
+		return siegfried.Extra{}, fmt.Errorf("bad -preview %d: expecting a number of bytes from 1 to %d", n, maxPreview)
+	}
+	return siegfried.Extra{
+		Name: "preview",
+		Fn: func(c siegfried.Content, ids []core.Identification) string {
+			for _, id := range ids {
+				if id.Known() {
+					return ""
+				}
+			}
+			buf, _ := c.Slice(0, n) // shorter if the file is
+			return preview(buf)
+		},
+	}, nil
+}
+
+// preview formats bytes as hex, and as printable ASCII with dots for other bytes, like hexdump -C e.g. "25 50 44 46 0a |%PDF.|"
+func preview(buf []byte) string {
+	if len(buf) == 0 {
+		return ""
+	}
+	var hx, txt strings.Builder
+	for i, b := range buf {
+		if i > 0 {
+			hx.WriteByte(' ')
+		}
+		fmt.Fprintf(&hx, "%02x", b)
+		if b < 0x20 || b > 0x7e {
+			b = '.'
+		}
+		txt.WriteByte(b)
+	}
+	return hx.String() + " |" + txt.String() + "|"
+}
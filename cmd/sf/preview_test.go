@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestPreview(t *testing.T) {
+	if p := preview([]byte("%PDF\n\xff")); p != "25 50 44 46 0a ff |%PDF..|" {
+		t.Errorf("unexpected preview %q", p)
+	}
+	if _, err := previewExtra(maxPreview + 1); err == nil {
+		t.Error("expecting an error for a preview larger than the maximum")
+	}
+	e, _ := previewExtra(4)
+	bufs := siegreader.New()
+	b, err := bufs.Get(bytes.NewReader([]byte("ab")))
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	defer bufs.Put(b)
+	if p := e.Fn(b, []core.Identification{cmpID{"UNKNOWN", "no match"}}); p != "61 62 |ab|" {
+		t.Errorf("expecting a preview of a short file, got %q", p)
+	}
+	if p := e.Fn(b, []core.Identification{cmpID{"UNKNOWN", ""}, cmpID{"fmt/1", ""}}); p != "" {
+		t.Errorf("expecting no preview for an identified file, got %q", p)
+	}
+}
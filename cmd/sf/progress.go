@@ -0,0 +1,139 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// countFiles does a pre-pass over the file arguments to give the progress meter a total.
+// It runs alongside identification and reports partial counts as it goes. Arguments are dispatched as in the scan
+// (literal paths aren't globbed, and the entries of -f lists are walked) and directories are walked with the same filters.
+// Archive members, disk partitions and embedded objects can't be counted without reading files so,
+// with -z, -disk or -ole, the meter reports a running rate without an ETA. So too if the count can't match the scan:
+// when lists are read from stdin, and with -sample, -seq or a resumed -journal.
+// It reports whether the total is complete.
+func countFiles(args []string, literal, droid bool, add func(int64)) bool {
+	if *replay || *archive || *diskf || *olef || sampling != nil || sequencing != nil || jnl.resumeItem >= 0 {
+		return false
+	}
+	for _, v := range args {
+		if v == "-" && (*list || *inventoryf) {
+			return false
+		}
+	}
+	for _, v := range args {
+		switch {
+		case *inventoryf:
+			f, err := openFile(v)
+			if err != nil {
+				continue
+			}
+			var n int64
+			readInventory(f, func(object) error { n++; return nil })
+			f.Close()
+			add(n)
+		case *list:
+			f, err := openFile(v)
+			if err != nil {
+				continue
+			}
+			scanner := listScanner(f)
+			if *dataf {
+				scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024) // data URIs can be long
+			}
+			for scanner.Scan() {
+				countItem(scanner.Text(), true, droid, add)
+			}
+			f.Close()
+		default:
+			countItem(v, literal, droid, add)
+		}
+	}
+	return true
+}
+
+// countItem counts the files for an argument or list entry
+func countItem(v string, literal, droid bool, add func(int64)) {
+	switch {
+	case *dataf, v == "-", isURL(v):
+		add(1)
+	case isS3(v):
+		var n int64
+		newS3Client().list(v, func(object) error { n++; return nil })
+		add(n)
+	case literal:
+		countDir(v, *nr, droid, add)
+	default:
+		globs, _ := filepath.Glob(v)
+		for _, g := range globs {
+			countDir(g, *nr, droid, add)
+		}
+	}
+}
+
+// countDir counts the results of a directory walk (see identify), reporting the count to add as it goes.
+// Files that will be reported as errors count as results; with droid, so do directories.
+func countDir(root string, norecurse, droid bool, add func(int64)) {
+	var n int64
+	result := func() {
+		n++
+		// report partial counts so the meter shows progress through large trees
+		if n == 1000 {
+			add(n)
+			n = 0
+		}
+	}
+	var walkFunc filepath.WalkFunc
+	walkFunc = func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			result()
+			return nil
+		}
+		if skip, err := filter(root, path, info, false); skip {
+			return err
+		}
+		link := isLink(info)
+		if link {
+			if info, err = followLink(path); err != nil {
+				result()
+				return nil
+			}
+		}
+		if info.IsDir() {
+			if norecurse && path != root {
+				if link {
+					return nil
+				}
+				return filepath.SkipDir
+			}
+			if droid {
+				result()
+			}
+			if link {
+				return walkLink(path, walkFunc)
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && info.Mode()&256 != 0 && incremental != nil && incremental.unchanged(path, info) {
+			return nil
+		}
+		result()
+		return nil
+	}
+	filepath.Walk(root, walkFunc)
+	add(n)
+}
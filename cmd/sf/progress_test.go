@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCountFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"a.pdf", "b.tmp", "sub/c.pdf", "sub/d.txt", "sub/deeper/e.pdf"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0755)
+		os.WriteFile(filepath.Join(dir, p), []byte("x"), 0644)
+	}
+	count := func(args []string, literal, droid bool) (int64, bool) {
+		var n int64
+		ok := countFiles(args, literal, droid, func(i int64) { n += i })
+		return n, ok
+	}
+	if n, ok := count([]string{dir}, false, false); !ok || n != 5 {
+		t.Errorf("expecting a complete count of 5, got %d (%v)", n, ok)
+	}
+	// with droid output, directories are results too
+	if n, _ := count([]string{dir}, false, true); n != 8 {
+		t.Errorf("expecting 8 with directories, got %d", n)
+	}
+	*nr = true
+	if n, _ := count([]string{dir}, false, false); n != 2 {
+		t.Errorf("expecting 2 with -nr, got %d", n)
+	}
+	*nr = false
+	// globs are expanded, unless literal
+	if n, _ := count([]string{filepath.Join(dir, "*.pdf")}, false, false); n != 1 {
+		t.Errorf("expecting 1 for a glob, got %d", n)
+	}
+	// a literal path that doesn't exist is reported as an error
+	if n, _ := count([]string{filepath.Join(dir, "*.pdf")}, true, false); n != 1 {
+		t.Errorf("expecting 1 for a literal path that doesn't exist, got %d", n)
+	}
+	// walk filters apply, without logging the skipped paths a second time
+	walkFilters, _ = newWalkFilter("*.tmp", "")
+	var logged int
+	walkFilters.log = func(path, reason string) { logged++ }
+	if n, _ := count([]string{dir}, false, false); n != 4 || logged != 0 {
+		t.Errorf("expecting 4 with -exclude and no logging, got %d (logged %d)", n, logged)
+	}
+	walkFilters = nil
+	// entries of -f lists are walked, and split on NUL with -0
+	lst := filepath.Join(dir, "list")
+	os.WriteFile(lst, []byte(filepath.Join(dir, "sub")+"\x00"+filepath.Join(dir, "a.pdf")+"\x00"), 0644)
+	*list, *nul = true, true
+	n, _ := count([]string{lst}, false, false)
+	// lists from stdin can't be counted
+	_, ok := count([]string{"-"}, false, false)
+	*list, *nul = false, false
+	if n != 4 {
+		t.Errorf("expecting 4 for a -0 list, got %d", n)
+	}
+	if ok {
+		t.Error("expecting no total for a list read from stdin")
+	}
+	// a journal being resumed can't be counted
+	jnl.resumeItem = 0
+	_, ok = count([]string{dir}, false, false)
+	jnl.resumeItem = -1
+	if ok {
+		t.Error("expecting no total when resuming a scan")
+	}
+}
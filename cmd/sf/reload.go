@@ -0,0 +1,59 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// loadSig loads the signature file again (e.g. after it has been replaced with a new PRONOM release), with the same extras.
+func loadSig() (sf *siegfried.Siegfried, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sf, err = nil, fmt.Errorf("panic loading %s: %v", config.SignatureBase(), r)
+		}
+	}()
+	sf, err = siegfried.Load(config.Signature())
+	if err == nil {
+		err = addExtras(sf)
+	}
+	return sf, err
+}
+
+// reloadOnHangup reloads the signature file each time the process gets a SIGHUP (e.g. kill -HUP), and passes the new
+// siegfried to swap. If the file can't be loaded, the old one is kept. Platforms without SIGHUP never reload.
+func reloadOnHangup(swap func(*siegfried.Siegfried)) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for range sig {
+			nsf, err := loadSig()
+			if err != nil {
+				log.Printf("[WARN] reload: keeping the loaded signature file, got: %v\n", err)
+				continue
+			}
+			swap(nsf)
+			log.Printf("Reloaded %s (created %s)\n", config.SignatureBase(), nsf.C.Format(time.RFC3339))
+		}
+	}()
+}
@@ -0,0 +1,226 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// rangeBlock is the size of the range requests made to servers that support them. The last few blocks are cached.
+const (
+	rangeBlock  = 1 << 20
+	rangeBlocks = 8
+)
+
+// isURL reports whether an argument is an http or https URL, rather than a file or directory
+func isURL(v string) bool {
+	l := strings.ToLower(v)
+	return strings.HasPrefix(l, "http://") || strings.HasPrefix(l, "https://")
+}
+
+// remote is a resource on a server that supports range requests. Like a file, it has random access, so signatures anchored to the end
+// of the resource can be matched without downloading all of it.
+type remote struct {
+	url       string
+	client    *http.Client
+	transport *http.Transport
+	timeout   time.Duration
+	sz        int64
+	blocks    map[int64][]byte
+	order     []int64
+	off       int64               // for Read
+	auth      func(*http.Request) // signs requests, if set (e.g. for s3:// objects)
+}
+
+// get requests a range of a resource (or all of it if end < 0). The timeout applies to the response headers.
+func (r *remote) get(start, end int64) (*http.Response, error) {
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", config.UserAgent())
+	if end >= 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	}
+	if r.auth != nil {
+		r.auth(req)
+	}
+	timer := time.AfterFunc(r.timeout, func() {
+		r.transport.CancelRequest(req)
+	})
+	defer timer.Stop()
+	return r.client.Do(req)
+}
+
+func (r *remote) block(i int64) ([]byte, error) {
+	if b, ok := r.blocks[i]; ok {
+		return b, nil
+	}
+	start := i * rangeBlock
+	end := start + rangeBlock - 1
+	if end >= r.sz {
+		end = r.sz - 1
+	}
+	resp, err := r.get(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("range request failed: %s", resp.Status)
+	}
+	return r.cache(i, resp.Body, end-start+1)
+}
+
+func (r *remote) cache(i int64, rdr io.Reader, l int64) ([]byte, error) {
+	b := make([]byte, l)
+	if _, err := io.ReadFull(rdr, b); err != nil {
+		return nil, err
+	}
+	if len(r.order) >= rangeBlocks {
+		delete(r.blocks, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.blocks[i], r.order = b, append(r.order, i)
+	return b, nil
+}
+
+func (r *remote) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.sz {
+		return 0, io.EOF
+	}
+	var err error
+	if rem := r.sz - off; int64(len(p)) > rem {
+		p, err = p[:rem], io.EOF
+	}
+	for n := 0; n < len(p); {
+		b, berr := r.block((off + int64(n)) / rangeBlock)
+		if berr != nil {
+			return n, berr
+		}
+		n += copy(p[n:], b[(off+int64(n))%rangeBlock:])
+	}
+	return len(p), err
+}
+
+func (r *remote) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	return n, err
+}
+
+func (r *remote) IsSlicer() bool { return true }
+
+func (r *remote) Slice(off int64, l int) ([]byte, error) {
+	if off >= r.sz {
+		return nil, io.EOF
+	}
+	var err error
+	if off+int64(l) > r.sz {
+		l, err = int(r.sz-off), io.EOF
+	}
+	buf := make([]byte, l)
+	if _, rerr := r.ReadAt(buf, off); rerr != nil && rerr != io.EOF {
+		return nil, rerr
+	}
+	return buf, err
+}
+
+func (r *remote) EofSlice(off int64, l int) ([]byte, error) {
+	if off >= r.sz {
+		return nil, io.EOF
+	}
+	var err error
+	if off+int64(l) > r.sz {
+		l, err = int(r.sz-off), io.EOF
+	}
+	slc, serr := r.Slice(r.sz-off-int64(l), l)
+	if serr != nil && serr != io.EOF {
+		return nil, serr
+	}
+	return slc, err
+}
+
+func (r *remote) Size() int64 { return r.sz }
+
+// contentRange returns the total size given in a Content-Range header e.g. "bytes 0-1023/146515"
+func contentRange(h string) (int64, error) {
+	i := strings.LastIndexByte(h, '/')
+	if !strings.HasPrefix(h, "bytes ") || i < 0 {
+		return 0, errors.New("bad Content-Range header")
+	}
+	return strconv.ParseInt(h[i+1:], 10, 64)
+}
+
+// identifyURL fetches and identifies an http or https resource.
+func identifyURL(u string, ctxts chan *context) {
+	_, timeout, transport := config.UpdateOptions()
+	r := &remote{url: u, client: &http.Client{Transport: transport}, transport: transport, timeout: timeout, blocks: make(map[int64][]byte)}
+	identifyRemote(r, u, time.Time{}, ctxts)
+}
+
+// identifyRemote identifies a remote resource of unknown size. The first block of the resource is requested as a range:
+// if the server supports range requests, the rest is read in ranges as needed; otherwise the resource is streamed.
+// The modified time given is used if the response has no Last-Modified header.
+func identifyRemote(r *remote, name string, mod time.Time, ctxts chan *context) {
+	resp, err := r.get(0, rangeBlock-1)
+	if err != nil {
+		printFile(ctxts, getCtx(name, "", mod, 0), fmt.Errorf("error fetching %s: %v", r.url, err))
+		return
+	}
+	defer resp.Body.Close()
+	if lm, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		mod = lm
+	}
+	mime := resp.Header.Get("Content-Type")
+	var rdr io.Reader
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if r.sz, err = contentRange(resp.Header.Get("Content-Range")); err == nil {
+			l := int64(rangeBlock)
+			if r.sz < l {
+				l = r.sz
+			}
+			_, err = r.cache(0, resp.Body, l)
+		}
+		if err != nil {
+			printFile(ctxts, getCtx(name, "", mod, 0), fmt.Errorf("error fetching %s: %v", r.url, err))
+			return
+		}
+		rdr = r
+	case http.StatusOK:
+		r.sz, rdr = resp.ContentLength, resp.Body
+		if r.sz < 0 {
+			r.sz = 0
+		}
+	case http.StatusRequestedRangeNotSatisfiable: // an empty resource
+		rdr = strings.NewReader("")
+	default:
+		printFile(ctxts, getCtx(name, "", mod, 0), fmt.Errorf("error fetching %s: %s", r.url, resp.Status))
+		return
+	}
+	ctx := getCtx(name, mime, mod, r.sz)
+	ctx.wg.Add(1)
+	ctxts <- ctx
+	identifyRdr(rdr, ctx, ctxts, getCtx)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContentRange(t *testing.T) {
+	for h, expect := range map[string]int64{"bytes 0-1023/146515": 146515, "bytes 0-0/1": 1, "bytes 0-1023/*": -1, "0-1023/10": -1} {
+		sz, err := contentRange(h)
+		if (err != nil) != (expect < 0) || (err == nil && sz != expect) {
+			t.Errorf("%s: expecting %d, got %d (%v)", h, expect, sz, err)
+		}
+	}
+}
+
+func TestRemote(t *testing.T) {
+	content := make([]byte, rangeBlock*5/2)
+	for i := range content {
+		content[i] = byte(i / 7)
+	}
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeContent(w, r, "test.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	transport := &http.Transport{}
+	r := &remote{url: srv.URL, client: &http.Client{Transport: transport}, transport: transport, timeout: time.Minute, sz: int64(len(content)), blocks: make(map[int64][]byte)}
+	buf := make([]byte, 100)
+	if _, err := r.ReadAt(buf, rangeBlock-50); err != nil || !bytes.Equal(buf, content[rangeBlock-50:rangeBlock+50]) {
+		t.Fatalf("bad read across blocks: %v", err)
+	}
+	eof, err := r.EofSlice(0, 10)
+	if err != nil || !bytes.Equal(eof, content[len(content)-10:]) {
+		t.Errorf("bad EOF slice: %v", err)
+	}
+	if _, err := r.Slice(int64(len(content)), 1); err == nil {
+		t.Error("expecting EOF reading past the end")
+	}
+	// three blocks were requested, and reads from cached blocks don't make requests
+	r.Slice(10, 10)
+	if requests != 3 {
+		t.Errorf("expecting 3 range requests, got %d", requests)
+	}
+}
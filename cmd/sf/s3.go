@@ -0,0 +1,253 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// isS3 reports whether an argument is an s3:// bucket or prefix e.g. s3://bucket/prefix
+func isS3(v string) bool {
+	return strings.HasPrefix(strings.ToLower(v), "s3://")
+}
+
+// Credentials are read from the standard AWS environment variables. Without them, requests are anonymous (for public buckets).
+// The region defaults to us-east-1.
+type awsCreds struct {
+	id, secret, token, region string
+}
+
+func s3Creds() *awsCreds {
+	c := &awsCreds{
+		id:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secret: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:  os.Getenv("AWS_SESSION_TOKEN"),
+		region: os.Getenv("AWS_REGION"),
+	}
+	if c.id == "" || c.secret == "" {
+		return nil
+	}
+	if c.region == "" {
+		c.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	return c
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to a (bodiless) S3 request
+func (c *awsCreds) sign(req *http.Request, now time.Time) {
+	const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzdate, date := now.UTC().Format("20060102T150405Z"), now.UTC().Format("20060102")
+	req.Header.Set("X-Amz-Date", amzdate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if c.token != "" {
+		req.Header.Set("X-Amz-Security-Token", c.token)
+	}
+	hdrs := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if c.token != "" {
+		hdrs = append(hdrs, "x-amz-security-token")
+	}
+	var canon strings.Builder
+	for _, h := range hdrs {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.Host
+		}
+		canon.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	segs := strings.Split(req.URL.Path, "/")
+	for i, s := range segs {
+		segs[i] = awsEscape(s)
+	}
+	q := req.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		params = append(params, awsEscape(k)+"="+awsEscape(q.Get(k)))
+	}
+	signed := strings.Join(hdrs, ";")
+	creq := strings.Join([]string{req.Method, strings.Join(segs, "/"), strings.Join(params, "&"), canon.String(), signed, emptyHash}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(creq))
+	sts := "AWS4-HMAC-SHA256\n" + amzdate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := []byte("AWS4" + c.secret)
+	for _, v := range []string{date, c.region, "s3", "aws4_request", sts} {
+		key = hmacSHA256(key, v)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.id, scope, signed, hex.EncodeToString(key)))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// awsEscape percent encodes everything but the unreserved characters, as SigV4 canonical requests require
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// a page of ListObjectsV2 results
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+// s3Client lists and fetches the objects in a bucket, using the -endpoint template to address them
+type s3Client struct {
+	client    *http.Client
+	transport *http.Transport
+	timeout   time.Duration
+	creds     *awsCreds
+}
+
+func newS3Client() *s3Client {
+	_, timeout, transport := config.UpdateOptions()
+	return &s3Client{client: &http.Client{Transport: transport}, transport: transport, timeout: timeout, creds: s3Creds()}
+}
+
+func (c *s3Client) auth(req *http.Request) {
+	if c.creds != nil {
+		c.creds.sign(req, time.Now())
+	}
+}
+
+// list calls fn for each object in a bucket with a key starting with the prefix (e.g. s3://bucket/prefix).
+// Folder placeholders (keys ending in "/") are skipped. With -nr, only the objects directly under the prefix are listed.
+func (c *s3Client) list(arg string, fn func(object) error) error {
+	bucket, prefix, _ := strings.Cut(arg[len("s3://"):], "/")
+	if bucket == "" {
+		return fmt.Errorf("no bucket in %s", arg)
+	}
+	base, err := objectURL(*endpointf, object{scheme: "s3", bucket: bucket})
+	if err != nil {
+		return err
+	}
+	var token string
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if *nr {
+			q.Set("delimiter", "/")
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		page, err := c.page(base, q)
+		if err != nil {
+			return err
+		}
+		for _, v := range page.Contents {
+			if strings.HasSuffix(v.Key, "/") {
+				continue
+			}
+			if err := fn(object{scheme: "s3", bucket: bucket, key: v.Key, size: v.Size, mod: v.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (c *s3Client) page(base string, q url.Values) (*listBucketResult, error) {
+	u := base
+	if strings.Contains(u, "?") {
+		u += "&" + q.Encode()
+	} else {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", config.UserAgent())
+	c.auth(req)
+	timer := time.AfterFunc(c.timeout, func() {
+		c.transport.CancelRequest(req)
+	})
+	defer timer.Stop()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error listing %s: %s", base, resp.Status)
+	}
+	page := &listBucketResult{}
+	if err := xml.NewDecoder(resp.Body).Decode(page); err != nil {
+		return nil, fmt.Errorf("error listing %s: %v", base, err)
+	}
+	return page, nil
+}
+
+// identifyS3 lists and identifies the objects under an s3:// bucket or prefix. Objects are read with range requests,
+// so only the parts of big objects that the matchers need are fetched.
+func identifyS3(arg string, ctxts chan *context) error {
+	c := newS3Client()
+	return c.list(arg, func(o object) error {
+		if !jnl.next() {
+			return nil
+		}
+		if jnl.stopped() {
+			return errBudget
+		}
+		u, err := objectURL(*endpointf, o)
+		if err != nil {
+			return err
+		}
+		ctx := getCtx(o.String(), "", o.mod, o.size)
+		r := &remote{url: u, client: c.client, transport: c.transport, timeout: c.timeout, sz: o.size, blocks: make(map[int64][]byte), auth: c.auth}
+		ctx.wg.Add(1)
+		ctxts <- ctx
+		identifyRdr(r, ctx, ctxts, getCtx)
+		return nil
+	})
+}
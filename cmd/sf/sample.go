@@ -0,0 +1,144 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var samplef = flag.String("sample", "", "identify a reproducible sample of the files in walked directories, for statistical surveys: every Nth file (e.g. -sample every=100) or a hash-based random selection (e.g. -sample rate=0.01,seed=42)")
+
+// sampling selects the files to identify when walking directories; it is nil if the -sample flag isn't given
+var sampling *sampler
+
+// sampler selects files by a deterministic rule so that a sample can be reproduced.
+// Systematic sampling takes the first file of each walked item and every Nth file after it, in walk (lexical) order.
+// Random sampling takes a file if the (mixed) FNV-1a hash of the seed and its path, relative to the parent of the walked item,
+// falls within the sampling rate: a file's selection doesn't depend on the other files in the walk, or on where the collection is mounted.
+type sampler struct {
+	every int
+	rate  float64
+	seed  uint64
+	// walk state
+	item    int
+	root    string
+	count   int
+	sampled int
+	total   int
+}
+
+func newSampler(settings string) (*sampler, error) {
+	s := &sampler{item: -1}
+	for _, kv := range strings.Split(settings, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		var err error
+		switch k {
+		case "every":
+			if s.every, err = strconv.Atoi(v); err == nil && s.every < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		case "rate":
+			if s.rate, err = strconv.ParseFloat(v, 64); err == nil && (s.rate <= 0 || s.rate > 1) {
+				err = fmt.Errorf("must be greater than 0 and no more than 1")
+			}
+		case "seed":
+			s.seed, err = strconv.ParseUint(v, 10, 64)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad -sample setting %q: %v", kv, err)
+		}
+	}
+	if (s.every > 0) == (s.rate > 0) {
+		return nil, fmt.Errorf("bad -sample settings %q: give either every=N or rate=F", settings)
+	}
+	if s.every > 0 {
+		s.rate = 1 / float64(s.every)
+	}
+	return s, nil
+}
+
+// String describes the sampling rule and its effective rate, for the sample field
+func (s *sampler) String() string {
+	rate := strconv.FormatFloat(s.rate, 'g', -1, 64)
+	if s.every > 0 {
+		return fmt.Sprintf("systematic; every=%d; rate=%s", s.every, rate)
+	}
+	return fmt.Sprintf("random; rate=%s; seed=%d", rate, s.seed)
+}
+
+// skip is called for each path in a walk, before the journal (so that resumed scans select the same files).
+// It reports whether a file should be left out of the sample.
+func (s *sampler) skip(path string, dir bool) bool {
+	if s.item != jnl.item {
+		s.item, s.root, s.count = jnl.item, filepath.Dir(path), 0
+	}
+	if dir {
+		return false
+	}
+	s.total++
+	var keep bool
+	if s.every > 0 {
+		keep = s.count%s.every == 0
+		s.count++
+	} else {
+		keep = sampleHash(s.seed, s.rel(path)) < s.rate
+	}
+	if keep {
+		s.sampled++
+	}
+	return !keep
+}
+
+func (s *sampler) rel(path string) string {
+	if rel, err := filepath.Rel(s.root, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// sampleHash maps a seed and path to a number in the range [0,1)
+func sampleHash(seed uint64, path string) float64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], seed)
+	h.Write(buf[:])
+	h.Write([]byte(path))
+	// FNV's high bits change little with the last bytes of the input, so mix them with the splitmix64 finalizer
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11) / (1 << 53)
+}
+
+// sampleExtra reports the sampling rule as a "sample" field, so that results can be extrapolated
+func sampleExtra(s *sampler) siegfried.Extra {
+	desc := s.String()
+	return siegfried.Extra{
+		Name: "sample",
+		Fn:   func(c siegfried.Content, ids []core.Identification) string { return desc },
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	for _, bad := range []string{"", "every=0", "rate=2", "every=10,rate=0.1", "seed=1", "step=3"} {
+		if _, err := newSampler(bad); err == nil {
+			t.Errorf("expecting an error for -sample %q", bad)
+		}
+	}
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		os.WriteFile(filepath.Join(dir, "f"+strconv.Itoa(i)), []byte("x"), 0644)
+	}
+	walk := func(settings string, root string) []string {
+		s, err := newSampler(settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		jnl.next()
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if !s.skip(path, info.IsDir()) && !info.IsDir() {
+				rel, _ := filepath.Rel(root, path)
+				files = append(files, rel)
+			}
+			return nil
+		})
+		if s.sampled != len(files) || s.total != 50 {
+			t.Errorf("bad sample counts for %q: %d of %d", settings, s.sampled, s.total)
+		}
+		return files
+	}
+	if files := walk("every=10", dir); len(files) != 5 || files[0] != "f0" || files[1] != "f18" {
+		t.Errorf("bad systematic sample, got %v", files)
+	}
+	random := walk("rate=0.2,seed=42", dir)
+	if len(random) == 0 || len(random) == 50 {
+		t.Errorf("bad random sample, got %v", random)
+	}
+	// the same sample is selected when the collection is moved
+	moved := filepath.Join(t.TempDir(), filepath.Base(dir))
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	if files := walk("rate=0.2,seed=42", moved); !reflect.DeepEqual(files, random) {
+		t.Errorf("expecting a reproducible sample %v, got %v", random, files)
+	}
+	if files := walk("rate=0.2,seed=43", moved); reflect.DeepEqual(files, random) {
+		t.Error("expecting a different sample with a different seed")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/decompress"
+)
+
+var (
+	seqf       = flag.Bool("seq", false, "group numbered file sequences (e.g. frame.0001.dpx to frame.9999.dpx) and report each sequence as a single result")
+	seqFrames  = flag.Bool("seqf", false, "group numbered file sequences and also report each frame")
+	seqFields  = []string{"frames", "range", "missing"}
+	minFrames  = 3 // a sequence needs a run of at least this many consecutively numbered files
+	minDigits  = 3 // frame numbers are zero-padded to a fixed width: shorter numbers are more likely to be versions or parts (e.g. report1.pdf)
+	maxDigits  = 9 // longer numbers are more likely to be identifiers or dates than frame numbers
+	sequencing *sequencer
+)
+
+type frame struct {
+	n      int
+	digits string
+	path   string
+	mod    time.Time
+	sz     int64
+}
+
+type sequence struct {
+	dir, prefix, suffix string
+	frames              []frame
+}
+
+// name gives a sequence a pattern name e.g. frame.[0001-9999].dpx
+func (sq *sequence) name() string {
+	return sq.dir + sq.prefix + "[" + sq.frames[0].digits + "-" + sq.frames[len(sq.frames)-1].digits + "]" + sq.suffix
+}
+
+// fields reports the frame count, the range and the number of frames missing from the range
+func (sq *sequence) fields() []string {
+	first, last := sq.frames[0], sq.frames[len(sq.frames)-1]
+	return []string{
+		strconv.Itoa(len(sq.frames)),
+		first.digits + "-" + last.digits,
+		strconv.Itoa(last.n - first.n + 1 - len(sq.frames)),
+	}
+}
+
+// run returns the length of the longest run of consecutively numbered frames (the frames must be sorted)
+func (sq *sequence) run() int {
+	run, max := 1, 1
+	for i := 1; i < len(sq.frames); i++ {
+		if sq.frames[i].n == sq.frames[i-1].n+1 {
+			run++
+		} else {
+			run = 1
+		}
+		if run > max {
+			max = run
+		}
+	}
+	return max
+}
+
+// sameFormat reports whether the first and last frames of a sequence are identified as the same format,
+// so that the first frame can stand for the sequence
+func (sq *sequence) sameFormat(gf getFn) bool {
+	ctx := gf("", "", time.Time{}, 0)
+	defer ctxPool.Put(ctx)
+	var ids [2]string
+	for i, fr := range [2]frame{sq.frames[0], sq.frames[len(sq.frames)-1]} {
+		f, err := os.Open(fr.path)
+		if err != nil {
+			return false
+		}
+		res, _ := ctx.s.Identify(f, fr.path, "")
+		f.Close()
+		if len(res) == 0 {
+			return false
+		}
+		for _, id := range res {
+			ids[i] += id.String() + " "
+		}
+	}
+	return ids[0] == ids[1]
+}
+
+// parseFrame splits a path around the last run of digits in its base name (ignoring the extension).
+// The digits must be between minDigits and maxDigits long.
+func parseFrame(path string) (dir, prefix, digits, suffix string, ok bool) {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	stem := base[:len(base)-len(ext)]
+	end := strings.LastIndexAny(stem, "0123456789")
+	if end < 0 {
+		return
+	}
+	end++
+	start := end - 1
+	for start > 0 && stem[start-1] >= '0' && stem[start-1] <= '9' {
+		start--
+	}
+	if end-start < minDigits || end-start > maxDigits {
+		return
+	}
+	return dir, stem[:start], stem[start:end], stem[end:] + ext, true
+}
+
+// sequencer holds numbered files while a directory is walked so that they can be grouped into sequences.
+// Sequences are reported once the walk leaves their directory.
+type sequencer struct {
+	open map[string]map[string]*sequence // dir -> prefix + width + suffix -> sequence
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{open: make(map[string]map[string]*sequence)}
+}
+
+// file is called for each file in the walk. It reports sequences in directories that the walk has left,
+// and returns true if the file is held as a candidate frame.
+func (s *sequencer) file(ctxts chan *context, path string, mod time.Time, sz int64, gf getFn) bool {
+	dir, prefix, digits, suffix, ok := parseFrame(path)
+	for k := range s.open {
+		if !strings.HasPrefix(filepath.Dir(path)+string(filepath.Separator), k) {
+			s.flushDir(ctxts, k, gf)
+		}
+	}
+	if !ok {
+		return false
+	}
+	n, _ := strconv.Atoi(digits)
+	seqs, ok := s.open[dir]
+	if !ok {
+		seqs = make(map[string]*sequence)
+		s.open[dir] = seqs
+	}
+	key := prefix + "\x00" + strconv.Itoa(len(digits)) + "\x00" + suffix // frames of a sequence have numbers of the same width
+	sq, ok := seqs[key]
+	if !ok {
+		sq = &sequence{dir: dir, prefix: prefix, suffix: suffix}
+		seqs[key] = sq
+	}
+	sq.frames = append(sq.frames, frame{n, digits, path, mod, sz})
+	return true
+}
+
+// flush reports all held sequences and frames
+func (s *sequencer) flush(ctxts chan *context, gf getFn) {
+	for k := range s.open {
+		s.flushDir(ctxts, k, gf)
+	}
+}
+
+func (s *sequencer) flushDir(ctxts chan *context, dir string, gf getFn) {
+	seqs := s.open[dir]
+	delete(s.open, dir)
+	keys := make([]string, 0, len(seqs))
+	for k := range seqs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sq := seqs[k]
+		sort.Slice(sq.frames, func(i, j int) bool { return sq.frames[i].n < sq.frames[j].n })
+		if len(sq.frames) < minFrames || sq.run() < minFrames || !sq.sameFormat(gf) {
+			for _, f := range sq.frames {
+				identifyFile(gf(f.path, "", f.mod, f.sz), ctxts, gf)
+			}
+			continue
+		}
+		identifySeq(sq, ctxts, gf)
+		if *seqFrames {
+			for _, f := range sq.frames {
+				identifyFile(gf(f.path, "", f.mod, f.sz), ctxts, gf)
+			}
+		}
+	}
+}
+
+// identifySeq reports a sequence as a single result. The format is identified from the first frame;
+// the size is the total size of the frames and the modified time is that of the most recently modified frame.
+func identifySeq(sq *sequence, ctxts chan *context, gf getFn) {
+	var sz int64
+	var mod time.Time
+	for _, f := range sq.frames {
+		sz += f.sz
+		if f.mod.After(mod) {
+			mod = f.mod
+		}
+	}
+	ctx := gf(sq.name(), "", mod, sz)
+	if *summarise {
+		ctx.seq = make([]string, len(decompress.SummaryFields))
+	}
+	ctx.seq = append(ctx.seq, sq.fields()...)
+	ctx.wg.Add(1)
+	ctxts <- ctx
+	f, err := os.Open(sq.frames[0].path)
+	if err != nil {
+		f, err = retryOpen(sq.frames[0].path, err)
+		if err != nil {
+			ctx.res <- results{err, nil, nil, nil}
+			return
+		}
+	}
+	identifyRdr(f, ctx, ctxts, gf)
+	f.Close()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/logger"
+)
+
+func TestParseFrame(t *testing.T) {
+	for _, v := range []struct {
+		path, prefix, digits, suffix string
+		ok                           bool
+	}{
+		{"frame.0001.dpx", "frame.", "0001", ".dpx", true},
+		{"scan012_left.tif", "scan", "012", "_left.tif", true},
+		{"report1.pdf", "", "", "", false}, // not zero-padded
+		{"scan12_left.tif", "", "", "", false},
+		{"20230101120000123.jpg", "", "", "", false},
+		{"readme.txt", "", "", "", false},
+	} {
+		_, prefix, digits, suffix, ok := parseFrame(filepath.Join("dir", v.path))
+		if ok != v.ok || prefix != v.prefix || digits != v.digits || suffix != v.suffix {
+			t.Errorf("bad parse of %s: got %q %q %q %v", v.path, prefix, digits, suffix, ok)
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	sq := &sequence{dir: "dir/", prefix: "frame.", suffix: ".dpx", frames: []frame{
+		{n: 1, digits: "0001"},
+		{n: 2, digits: "0002"},
+		{n: 5, digits: "0005"},
+	}}
+	if nm := sq.name(); nm != "dir/frame.[0001-0005].dpx" {
+		t.Errorf("bad sequence name, got %s", nm)
+	}
+	if f := sq.fields(); f[0] != "3" || f[1] != "0001-0005" || f[2] != "2" {
+		t.Errorf("bad sequence fields, got %v", f)
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, v := range []struct {
+		ns  []int
+		run int
+	}{
+		{[]int{1, 2, 3}, 3},
+		{[]int{1, 5, 9}, 1},
+		{[]int{1, 2, 4, 5, 6}, 3},
+	} {
+		sq := &sequence{}
+		for _, n := range v.ns {
+			sq.frames = append(sq.frames, frame{n: n})
+		}
+		if r := sq.run(); r != v.run {
+			t.Errorf("%v: expecting a run of %d, got %d", v.ns, v.run, r)
+		}
+	}
+}
+
+func TestSequencer(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	sequencing = newSequencer()
+	defer func() { sequencing = nil }()
+	bench := filepath.Join(*testdata, "benchmark")
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"frame.001.tif": "Benchmark.tif", "frame.002.tif": "Benchmark.tif", "frame.004.tif": "Benchmark.tif", "frame.005.tif": "Benchmark.tif", "frame.006.tif": "Benchmark.tif",
+		"report1.pdf": "Benchmark.pdf", "report2.pdf": "Benchmark.pdf", "report3.pdf": "Benchmark.pdf", // not zero-padded
+		"shot001.tif": "Benchmark.tif", "shot005.tif": "Benchmark.tif", "shot009.tif": "Benchmark.tif", // no run of consecutive frames
+		"scan001.tif": "Benchmark.tif", "scan002.tif": "Benchmark.tif", "scan003.tif": "Benchmark.png", // the last frame is a different format
+		"take099.tif": "Benchmark.tif", "take100.tif": "Benchmark.tif", "take0101.tif": "Benchmark.tif", // numbers of different widths
+	} {
+		byt, err := os.ReadFile(filepath.Join(bench, src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(dir, name), byt, 0644)
+	}
+	lg, _ := logger.New("")
+	names, wg := make(nameWriter, 20), &sync.WaitGroup{}
+	ctxts := make(chan *context, 1)
+	printed := make(chan struct{})
+	go func() {
+		printer(ctxts, lg)
+		close(printed)
+	}()
+	setCtxPool(s, wg, names, false, false, -1)
+	if err := identify(ctxts, dir, "", false, false, false, getCtx); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(ctxts)
+	<-printed
+	close(names)
+	var got []string
+	for n := range names {
+		rel, _ := filepath.Rel(dir, n)
+		got = append(got, rel)
+	}
+	sort.Strings(got)
+	expect := []string{"frame.[001-006].tif", "report1.pdf", "report2.pdf", "report3.pdf", "scan001.tif", "scan002.tif", "scan003.tif",
+		"shot001.tif", "shot005.tif", "shot009.tif", "take0101.tif", "take099.tif", "take100.tif"}
+	if len(got) != len(expect) {
+		t.Fatalf("expecting %v, got %v", expect, got)
+	}
+	for i := range got {
+		if got[i] != expect[i] {
+			t.Fatalf("expecting %v, got %v", expect, got)
+		}
+	}
+}
@@ -16,10 +16,13 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -49,6 +52,78 @@ func decodePath(s, b64 string) (string, error) {
 	return s[10:], nil
 }
 
+// postedPath returns the file or folder path given in the body of a POST request, either as a path param or, if the request
+// has a JSON body, as a path field e.g. {"path": "/home/richardl/My Documents"}. It returns an empty string if no path is given
+// (i.e. a file is attached).
+func postedPath(r *http.Request) (string, error) {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+		var body struct {
+			Path string `json:"path"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("bad request; expecting a JSON body with a path field e.g. {\"path\": \"/home/richardl/file.doc\"}, got %v", err)
+		}
+		if body.Path == "" {
+			return "", fmt.Errorf("bad request; JSON body has no path field")
+		}
+		return body.Path, nil
+	}
+	p := r.FormValue("path")
+	if p != "" && r.FormValue("base64") == "true" {
+		data, err := base64.URLEncoding.DecodeString(p)
+		if err != nil {
+			return "", fmt.Errorf("Error base64 decoding file path, error message %v", err)
+		}
+		return string(data), nil
+	}
+	return p, nil
+}
+
+// upload is a file sent in the body of a POST request.
+type upload struct {
+	name string
+	mime string
+	mod  time.Time
+	sz   int64 // -1 if not known until the file is read e.g. for a chunked body
+	open func() (io.ReadCloser, error)
+}
+
+// postedFiles returns the files sent in the body of a POST request. These are either attached as form-data with the key "file"
+// (a form can attach more than one), or are the body itself (i.e. for requests with any content type other than form-data,
+// form params or JSON). A body may be chunked. Its name can be given with a name param or in a Content-Disposition header,
+// and its Content-Type, unless application/octet-stream, is used as a MIME hint.
+func postedFiles(r *http.Request) ([]upload, error) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mt == "multipart/form-data":
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		fhs := r.MultipartForm.File["file"]
+		if len(fhs) == 0 {
+			return nil, fmt.Errorf("bad request; expecting a file attached as form-data with the key \"file\"")
+		}
+		ret := make([]upload, len(fhs))
+		for i, fh := range fhs {
+			fh := fh
+			ret[i] = upload{name: fh.Filename, sz: fh.Size, open: func() (io.ReadCloser, error) { return fh.Open() }}
+		}
+		return ret, nil
+	case mt == "application/x-www-form-urlencoded", mt == "application/json", mt == "" && r.ContentLength == 0:
+		return nil, fmt.Errorf("bad request; expecting a path param, or a file attached as form-data or sent as the request body")
+	}
+	u := upload{name: r.FormValue("name"), sz: r.ContentLength, open: func() (io.ReadCloser, error) { return r.Body, nil }}
+	if u.name == "" {
+		if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil {
+			u.name = params["filename"]
+		}
+	}
+	if mt != "application/octet-stream" {
+		u.mime = mt
+	}
+	return []upload{u}, nil
+}
+
 func parseRequest(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfried, wg *sync.WaitGroup) (string, writer.Writer, bool, bool, bool, checksum.HashTyp, *siegfried.Siegfried, getFn, error) {
 	// json, csv, droid or yaml
 	paramsErr := func(field, expect string) (string, writer.Writer, bool, bool, bool, checksum.HashTyp, *siegfried.Siegfried, getFn, error) {
@@ -61,11 +136,11 @@ func parseRequest(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfried
 		frmt int
 	)
 	switch {
-	case *jsono:
+	case jsono.on:
 		frmt = 1
-	case *csvo:
+	case csvo.on:
 		frmt = 2
-	case *droido:
+	case droido.on:
 		frmt = 3
 	}
 	if v := r.FormValue("format"); v != "" {
@@ -150,7 +225,10 @@ func parseRequest(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfried
 	if v := r.FormValue("hash"); v != "" {
 		h = v
 	}
-	ht := checksum.GetHash(h)
+	ht := checksum.HashTyp(-1)
+	if hs := checksum.GetHashes(h); hs != nil {
+		ht = hs[0] // any others are calculated as extra fields
+	}
 	// sig
 	sf := s
 	if v := r.FormValue("sig"); v != "" {
@@ -158,14 +236,21 @@ func parseRequest(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfried
 			return "", nil, false, false, false, -1, nil, nil, fmt.Errorf("bad request; sig param should be path to a signature file (absolute or relative to home); got %v", err)
 		}
 		nsf, err := siegfried.Load(config.Local(v))
+		if err == nil {
+			err = addExtras(nsf)
+		}
 		if err == nil {
 			sf = nsf
 		}
 	}
 	gf := func(path, mime string, mod time.Time, sz int64) *context {
 		c := ctxPool.Get().(*context)
-		c.path, c.mime, c.mod, c.sz = path, mime, mod, sz
+		c.path, c.mime, c.mod, c.sz, c.rec = path, mime, mod, sz, nil
+		c.depth, c.bud, c.rewind = 0, nil, nil
 		c.s, c.wg, c.w, c.d, c.z, c.h = sf, wg, wr, d, z, checksum.MakeHash(ht)
+		for _, h := range c.hx {
+			h.Reset()
+		}
 		return c
 	}
 	return mime, wr, coerr, norec, d, ht, sf, gf, nil
@@ -178,37 +263,50 @@ func handleIdentify(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfri
 		handleErr(w, http.StatusNotFound, err)
 		return
 	}
-	if r.Method == "POST" {
-		f, h, err := r.FormFile("file")
+	// stop identifying if the client goes away
+	gf = func(get getFn) getFn {
+		return func(path, mime string, mod time.Time, sz int64) *context {
+			c := get(path, mime, mod, sz)
+			c.cancel = r.Context()
+			return c
+		}
+	}(gf)
+	var path string
+	post := r.Method == "POST" || r.Method == "PUT" // a file can be PUT as the request body e.g. curl -T file.doc
+	if post {
+		path, err = postedPath(r)
+		if err != nil {
+			handleErr(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if post && path == "" {
+		uploads, err := postedFiles(r)
 		if err != nil {
 			handleErr(w, http.StatusNotFound, err)
 			return
 		}
-		defer f.Close()
-		var sz int64
-		var mod time.Time
-		osf, ok := f.(*os.File)
-		if ok {
-			info, err := osf.Stat()
+		w.Header().Set("Content-Type", mime)
+		wr.Head(config.SignatureBase(), time.Now(), sf.C, config.Version(), sf.Identifiers(), sf.Fields(), ht.String(), extraFields(sf))
+		for _, u := range uploads {
+			f, err := u.open()
 			if err != nil {
-				handleErr(w, http.StatusInternalServerError, err)
+				printFile(ctxts, gf(u.name, u.mime, u.mod, u.sz), err)
+				continue
 			}
-			sz = info.Size()
-			mod = info.ModTime()
-		} else {
-			sz = r.ContentLength
+			wg.Add(1)
+			ctx := gf(u.name, u.mime, u.mod, u.sz)
+			ctxts <- ctx
+			identifyRdr(f, ctx, ctxts, gf)
+			f.Close()
 		}
-		w.Header().Set("Content-Type", mime)
-		wr.Head(config.SignatureBase(), time.Now(), sf.C, config.Version(), sf.Identifiers(), sf.Fields(), ht.String())
-		wg.Add(1)
-		ctx := gf(h.Filename, "", mod, sz)
-		ctxts <- ctx
-		identifyRdr(f, ctx, ctxts, gf)
 		wg.Wait()
 		wr.Tail()
 		return
 	}
-	path, err := decodePath(r.URL.Path, r.FormValue("base64"))
+	if path == "" {
+		path, err = decodePath(r.URL.Path, r.FormValue("base64"))
+	}
 	if err == nil {
 		_, err = os.Stat(path)
 	}
@@ -217,7 +315,7 @@ func handleIdentify(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfri
 		return
 	}
 	w.Header().Set("Content-Type", mime)
-	wr.Head(config.SignatureBase(), time.Now(), sf.C, config.Version(), sf.Identifiers(), sf.Fields(), ht.String())
+	wr.Head(config.SignatureBase(), time.Now(), sf.C, config.Version(), sf.Identifiers(), sf.Fields(), ht.String(), extraFields(sf))
 	err = identify(ctxts, path, "", coerr, nrec, d, gf)
 	wg.Wait()
 	wr.Tail()
@@ -235,12 +333,22 @@ const usage = `
 			<h1><a name="top">Siegfried server usage</a></h1>
 			<p>The siegfried server has two modes of identification:
 			<ul><li><a href="#get_request">GET request</a>, where a file or directory path is given in the URL and the server retrieves the file(s);</li>
-			<li><a href="#post_request">POST request</a>, where the file is sent over the network as form-data.</li></ul></p> 
+			<li><a href="#post_request">POST request</a>, where the file is sent over the network as form-data, or a file or directory path is given in the request body.</li></ul></p> 
+			<p>To check that a file matches a claimed format before accepting it, POST the file as form-data with the key "file" to <i>/validate?claim=fmt/19</i> (the claim can be a format ID or a MIME type). The JSON response reports whether to accept the file and whether the claim was confirmed, mismatched or indeterminate.</p>
+			<p>E.g. curl "http://localhost:5138/validate?claim=application/pdf" -F file=@myfile.pdf</p>
+			<p>To follow the progress of a scan of a file or directory on the server, open a WebSocket to <i>/progress/[file or folder name (percent encoded)]</i> (or <i>/progress?path=...</i>), with the <i>base64</i>, <i>coe</i>, <i>nr</i>, <i>hash</i>, <i>z</i> and <i>sig</i> parameters of a GET request. The server sends a JSON message as the scan starts ({"event": "start"}), one for each file scanned, with its result as reported in JSON Lines output ({"event": "file", "result": {...}}), one once the files to scan have been counted ({"event": "total"}) and one when the scan ends ({"event": "end"}). Each message reports the number of files scanned so far ("done") and the number to scan ("total"; -1 until the files have been counted, or if they can't be e.g. with z=true).</p>
+			<p>E.g. new WebSocket("ws://localhost:5138/progress/" + encodeURIComponent("c:\\My Documents")).onmessage = (m) => console.log(JSON.parse(m.data))</p>
 			<p>The update command can also be issued as a GET request to <a href="/update">/update</a>. This fetches an updated signature file and hot patches the running siegfried instance.</p>
+			<p>To load a signature file that has been replaced on disk (e.g. with a new PRONOM release), without fetching an update, issue a GET request to <a href="/reload">/reload</a> or send the server a SIGHUP (kill -HUP). Requests in progress finish with the old signatures.</p>
 			<p>If PRONOM isn't being used as the underlying identifier, the update command can be qualified with the name of a different identifer e.g. <a href="/update">/update/wikidata</a>.</p>
+			<p>Metrics, for monitoring with Prometheus, are reported at <a href="/metrics">/metrics</a>: counts of the files identified (and their total size), of identifications by format ID, of files with errors and of requests, and a histogram of request durations.</p>
 			<h2>Default settings</h2>
 			<p>When starting the server, you can use regular sf flags to set defaults for the <i>nr</i>, <i>format</i>, <i>hash</i>, <i>z</i>, and <i>sig</i> parameters that will apply to all requests unless overridden. Logging options can also be set.<p>
 			<p>E.g. sf -nr -z -hash md5 -sig pronom-tika.sig -log p,w,e -serve localhost:5138</p>
+			<p>To serve over a network, use TLS (-servetls) and require a bearer token (-servetoken) or client certificates. Requests then need an Authorization header (or, for WebSockets from a browser, a token param).</p>
+			<p>E.g. sf -servetls cert=server.pem,key=server.key -servetoken @token.txt -serve :5138 then curl -H "Authorization: Bearer $(cat token.txt)" https://hostname:5138/identify/data</p>
+			<p>The -servelimit flag limits the identification requests (to /identify, /progress and /validate) handled at once, the requests that can wait for a turn (others get a 429 Too Many Requests response) and the size of request bodies (larger bodies get a 413 response).</p>
+			<p>E.g. sf -servelimit concurrency=4,queue=16,size=500MB -serve localhost:5138</p>
 			<hr>
 			<h2><a name="get_request">GET request</a></h2>
 			<p><strong>GET</strong> <i>/identify/[file or folder name (percent encoded)](?base64=false&nr=true&format=yaml&hash=md5&z=true&sig=locfdd.sig)</i></p>
@@ -250,8 +358,8 @@ const usage = `
 			<p><i>coe</i> (optional) - continue directory scans even when fatal file access errors are encountered with coe=true.</p>
 			<p><i>nr</i> (optional) - stop sub-directory recursion when a directory path is given with nr=true.</p>
 			<p><i>format</i> (optional) - select the output format (csv, yaml, json, droid). Default is yaml. Alternatively, HTTP content negotiation can be used.</p>
-			<p><i>hash</i> (optional) - calculate file checksum (md5, sha1, sha256, sha512, crc)</p>
-			<p><i>z</i> (optional) - scan archive formats (zip, tar, gzip, warc, arc) with z=true. Default is false.</p>
+			<p><i>hash</i> (optional) - calculate file checksum (md5, sha1, sha256, sha512, blake2b, crc)</p>
+			<p><i>z</i> (optional) - scan archive formats (zip, tar, gzip, bzip2, xz, zstd, warc, arc, ar, 7z, rar, iso, mbox, eml, msg, pst) with z=true. Default is false.</p>
 			<p><i>sig</i> (optional) - load a specific signature file. Default is default.sig.</p>
 			<h3>Example</h2>
 			<!-- set the get target for the example form using js function at bottom page-->
@@ -274,7 +382,8 @@ const usage = `
   				<option value="sha1">sha1</option>
  				<option value="sha256">sha256</option>
  				<option value="sha512">sha512</option>
- 				<option value="crc">crc</option>
+ 				<option value="blake2b">blake2b</option>
+				<option value="crc">crc</option>
 			</select></p>
 			 <p>Scan archive (z): <input type="radio" name="z" value="true"> true <input type="radio" name="z" value="false" checked> false</p>
 			 <p>Signature file (sig): <input type="text" name="sig"></p>
@@ -285,15 +394,25 @@ const usage = `
 			<h2><a name="post_request">POST request</a></h2>
 			<p><strong>POST</strong> <i>/identify(?format=yaml&hash=md5&z=true&sig=locfdd.sig)</i> Attach a file as form-data with the key "file".</p>
 			<p>E.g. curl "http://localhost:5138/identify?format=json&hash=crc" -F file=@myfile.doc</p>
+			<p>More than one file can be attached, with the same key, and each is reported.</p>
+			<p>E.g. curl "http://localhost:5138/identify?format=csv" -F file=@myfile.doc -F file=@myfile.pdf</p>
+			<p>Alternatively, send the file as the request body (which may be chunked) with any content type other than form-data, form params or JSON. Give the file's name with a <i>name</i> param or in a Content-Disposition header. The content type, unless application/octet-stream, is used as a MIME hint.</p>
+			<p>E.g. curl "http://localhost:5138/identify?format=json&name=myfile.doc" -H "Content-Type: application/octet-stream" --data-binary @myfile.doc</p>
+			<p>A file can also be sent as the body of a PUT request to <i>/identify</i>, with the same parameters.</p>
+			<p>E.g. curl "http://localhost:5138/identify?name=myfile.doc" -T myfile.doc</p>
+			<p>Alternatively, give the path of a file or directory on the server, rather than attaching a file, with a <i>path</i> param (which can be URL-safe base64 encoded with base64=true) or with a JSON body.</p>
+			<p>E.g. curl "http://localhost:5138/identify?format=json" -d path=/home/richardl/file.doc</p>
+			<p>E.g. curl "http://localhost:5138/identify?format=json" -H "Content-Type: application/json" -d '{"path": "/home/richardl/My Documents"}'</p>
 			<h3>Parameters</h3>
 			<p><i>format</i> (optional) - select the output format (csv, yaml, json, droid). Default is yaml. Alternatively, HTTP content negotiation can be used.</p>
-			<p><i>hash</i> (optional) - calculate file checksum (md5, sha1, sha256, sha512, crc)</p>
-			<p><i>z</i> (optional) - scan archive formats (zip, tar, gzip, warc, arc) with z=true. Default is false.</p>
+			<p><i>hash</i> (optional) - calculate file checksum (md5, sha1, sha256, sha512, blake2b, crc)</p>
+			<p><i>name</i> (optional) - the name of a file sent as the request body.</p>
+			<p><i>z</i> (optional) - scan archive formats (zip, tar, gzip, bzip2, xz, zstd, warc, arc, ar, 7z, rar, iso, mbox, eml, msg, pst) with z=true. Default is false.</p>
 			<p><i>sig</i> (optional) - load a specific signature file. Default is default.sig.</p>
 			<h3>Example</h2>
 			<form action="/identify" enctype="multipart/form-data" method="post">
 			 <h4>File:</h4>
-			 <p><input type="file" name="file"></p>
+			 <p><input type="file" name="file" multiple></p>
 			 <h4>Parameters:</h4>
 			 <p>Format (format): <select name="format">
   				<option value="json">json</option>
@@ -307,7 +426,8 @@ const usage = `
   				<option value="sha1">sha1</option>
  				<option value="sha256">sha256</option>
  				<option value="sha512">sha512</option>
- 				<option value="crc">crc</option>
+ 				<option value="blake2b">blake2b</option>
+				<option value="crc">crc</option>
 			</select></p>
 			 <p>Scan archive (z): <input type="radio" name="z" value="true"> true <input type="radio" name="z" value="false" checked> false</p>
 			 <p>Signature file (sig): <input type="text" name="sig"></p>
@@ -326,6 +446,36 @@ const usage = `
 	</html>
 `
 
+func handleValidate(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfried) {
+	if r.Method != "POST" {
+		handleErr(w, http.StatusMethodNotAllowed, fmt.Errorf("validate requires a POST request"))
+		return
+	}
+	claim := r.FormValue("claim")
+	if claim == "" {
+		handleErr(w, http.StatusNotFound, fmt.Errorf("bad request; validate requires a claim param e.g. claim=fmt/19 or claim=application/pdf"))
+		return
+	}
+	f, h, err := r.FormFile("file")
+	if err != nil {
+		handleErr(w, http.StatusNotFound, err)
+		return
+	}
+	defer f.Close()
+	v, err := s.Validate(f, h.Filename, claim)
+	if err != nil {
+		handleErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Claim   string `json:"claim"`
+		Accept  bool   `json:"accept"`
+		Outcome string `json:"outcome"`
+		Reason  string `json:"reason"`
+	}{claim, v.Accept(), v.Outcome.String(), v.Reason})
+}
+
 func handleMain(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	io.WriteString(w, usage)
@@ -342,14 +492,9 @@ func handleUpdate(w http.ResponseWriter, r *http.Request, m *muxer) {
 		return
 	}
 	if updated {
-		defer func() {
-			if r := recover(); r != nil {
-				handleErr(w, http.StatusInternalServerError, fmt.Errorf("panic: %v", r))
-			}
-		}()
-		nsf, err := siegfried.Load(config.Signature()) // may panic
+		nsf, err := loadSig()
 		if err == nil {
-			m.s = nsf // hot swap the siegfried!
+			m.swap(nsf) // hot swap the siegfried!
 			w.WriteHeader(http.StatusOK)
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			io.WriteString(w, msg)
@@ -364,36 +509,134 @@ func handleUpdate(w http.ResponseWriter, r *http.Request, m *muxer) {
 	io.WriteString(w, msg)
 }
 
+// handleReload reloads the signature file from disk (e.g. after it has been replaced) and swaps it in.
+// Requests already in progress finish with the old signature file.
+func handleReload(w http.ResponseWriter, r *http.Request, m *muxer) {
+	nsf, err := loadSig()
+	if err != nil {
+		handleErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	m.swap(nsf)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Reloaded %s (created %s)", config.SignatureBase(), nsf.C.Format(time.RFC3339))
+}
+
 type muxer struct {
 	s     *siegfried.Siegfried
 	ctxts chan *context
-	mut   sync.RWMutex
+	mut   sync.RWMutex // guards s
+	upd   sync.Mutex   // one update or reload at a time
+	lim   *limiter     // nil for no limits
+	token string       // bearer token required for requests (see -servetoken)
+}
+
+// sf returns the loaded siegfried. Each request uses the one loaded when it started, so a swap doesn't wait for
+// requests in progress.
+func (m *muxer) sf() *siegfried.Siegfried {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+	return m.s
+}
+
+func (m *muxer) swap(nsf *siegfried.Siegfried) {
+	m.mut.Lock()
+	m.s = nsf
+	m.mut.Unlock()
 }
 
 func (m *muxer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if metrics == nil {
+		m.route(w, r)
+		return
+	}
+	start, rec := time.Now(), &statusRecorder{ResponseWriter: w}
+	m.route(rec, r)
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	metrics.request(handlerName(r.URL.Path), rec.code, time.Since(start))
+}
+
+// handlerName names the handler for a path, for metrics.
+func handlerName(path string) string {
+	if path == "" || path == "/" {
+		return "main"
+	}
+	for _, h := range []string{"identify", "progress", "validate", "update", "reload", "metrics"} {
+		if strings.HasPrefix(path, "/"+h) {
+			return h
+		}
+	}
+	return "other"
+}
+
+func (m *muxer) route(w http.ResponseWriter, r *http.Request) {
+	if !authorized(m.token, r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="siegfried"`)
+		handleErr(w, http.StatusUnauthorized, fmt.Errorf("missing or bad bearer token"))
+		return
+	}
 	if (len(r.URL.Path) == 0 || r.URL.Path == "/") && r.Method == "GET" {
 		handleMain(w, r)
 		return
 	}
 	if len(r.URL.Path) >= 9 && r.URL.Path[:9] == "/identify" {
-		m.mut.RLock()
-		handleIdentify(w, r, m.s, m.ctxts)
-		m.mut.RUnlock()
+		if !m.lim.admit(w, r) {
+			return
+		}
+		defer m.lim.release()
+		handleIdentify(w, r, m.sf(), m.ctxts)
+		return
+	}
+	if len(r.URL.Path) >= 9 && r.URL.Path[:9] == "/progress" {
+		if !m.lim.admit(w, r) {
+			return
+		}
+		defer m.lim.release()
+		handleProgress(w, r, m.sf(), m.ctxts)
+		return
+	}
+	if len(r.URL.Path) >= 9 && r.URL.Path[:9] == "/validate" {
+		if !m.lim.admit(w, r) {
+			return
+		}
+		defer m.lim.release()
+		handleValidate(w, r, m.sf())
 		return
 	}
 	if len(r.URL.Path) >= 7 && r.URL.Path[:7] == "/update" {
-		m.mut.Lock()
+		m.upd.Lock()
 		handleUpdate(w, r, m)
-		m.mut.Unlock()
+		m.upd.Unlock()
 		return
 	}
-	handleErr(w, http.StatusNotFound, fmt.Errorf("valid paths are /, /update, /update/*, /identify and /identify/*"))
+	if r.URL.Path == "/reload" {
+		m.upd.Lock()
+		handleReload(w, r, m)
+		m.upd.Unlock()
+		return
+	}
+	if r.URL.Path == "/metrics" && metrics != nil {
+		handleMetrics(w, r)
+		return
+	}
+	handleErr(w, http.StatusNotFound, fmt.Errorf("valid paths are /, /update, /update/*, /reload, /identify, /identify/*, /progress, /progress/*, /validate and /metrics"))
 }
 
-func listen(port string, s *siegfried.Siegfried, ctxts chan *context) {
+func listen(port string, s *siegfried.Siegfried, ctxts chan *context) error {
+	metrics = newMetrics()
 	mux := &muxer{
 		s:     s,
 		ctxts: ctxts,
+		lim:   newLimiter(serveLimits),
+		token: serveAuth.token,
+	}
+	reloadOnHangup(mux.swap)
+	srv := &http.Server{Addr: port, Handler: mux, TLSConfig: serveAuth.tls}
+	if serveAuth.tls != nil {
+		return srv.ListenAndServeTLS("", "") // the certificate is in the TLS config
 	}
-	http.ListenAndServe(port, mux)
+	return srv.ListenAndServe()
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/internal/logger"
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+func TestPostedPath(t *testing.T) {
+	for _, v := range []struct {
+		ctype, body, expect string
+		err                 bool
+	}{
+		{"application/json", `{"path": "/data/file.doc"}`, "/data/file.doc", false},
+		{"application/json; charset=utf-8", `{"path": "/data/My Documents"}`, "/data/My Documents", false},
+		{"application/json", `{}`, "", true},
+		{"application/json", `not json`, "", true},
+		{"application/x-www-form-urlencoded", "path=%2Fdata%2Ffile.doc", "/data/file.doc", false},
+		{"application/x-www-form-urlencoded", "base64=true&path=" + base64.URLEncoding.EncodeToString([]byte("/data/file.doc")), "/data/file.doc", false},
+		{"application/x-www-form-urlencoded", "format=json", "", false},
+	} {
+		r := httptest.NewRequest("POST", "/identify", strings.NewReader(v.body))
+		r.Header.Set("Content-Type", v.ctype)
+		got, err := postedPath(r)
+		if (err != nil) != v.err || got != v.expect {
+			t.Errorf("%s %s: expecting %q (error %t), got %q (%v)", v.ctype, v.body, v.expect, v.err, got, err)
+		}
+	}
+}
+
+func TestPostedFiles(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, name := range []string{"a.doc", "b.pdf"} {
+		fw, _ := mw.CreateFormFile("file", name)
+		io.WriteString(fw, "content of "+name)
+	}
+	mw.Close()
+	for _, v := range []struct {
+		ctype, disp, query, body string
+		expect                   []string // name, mime and size of each file
+		err                      bool
+	}{
+		{mw.FormDataContentType(), "", "", buf.String(), []string{"a.doc  16", "b.pdf  16"}, false},
+		{"application/octet-stream", "", "?name=c.doc", "raw", []string{"c.doc  3"}, false},
+		{"application/pdf", `attachment; filename="d.pdf"`, "", "raw", []string{"d.pdf application/pdf 3"}, false},
+		{"", "", "", "raw", []string{"  3"}, false},
+		{"", "", "", "", nil, true},
+		{"application/x-www-form-urlencoded", "", "", "format=json", nil, true},
+	} {
+		r := httptest.NewRequest("POST", "/identify"+v.query, strings.NewReader(v.body))
+		if v.ctype != "" {
+			r.Header.Set("Content-Type", v.ctype)
+		}
+		if v.disp != "" {
+			r.Header.Set("Content-Disposition", v.disp)
+		}
+		uploads, err := postedFiles(r)
+		if (err != nil) != v.err || len(uploads) != len(v.expect) {
+			t.Errorf("%s %s: expecting %d files (error %t), got %d (%v)", v.ctype, v.query, len(v.expect), v.err, len(uploads), err)
+			continue
+		}
+		for i, u := range uploads {
+			if got := fmt.Sprintf("%s %s %d", u.name, u.mime, u.sz); got != v.expect[i] {
+				t.Errorf("%s %s: expecting %q, got %q", v.ctype, v.query, v.expect[i], got)
+			}
+			f, err := u.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			byts, _ := io.ReadAll(f)
+			f.Close()
+			if int64(len(byts)) != u.sz {
+				t.Errorf("%s %s: expecting %d bytes, read %d", v.ctype, v.query, u.sz, len(byts))
+			}
+		}
+	}
+}
+
+func TestServeUpload(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	lg, _ := logger.New("")
+	ctxts := make(chan *context, 1)
+	go printer(ctxts, lg)
+	defer close(ctxts)
+	setCtxPool(s, &sync.WaitGroup{}, nil, false, false, -1)
+	srv := httptest.NewServer(&muxer{s: s, ctxts: ctxts})
+	defer srv.Close()
+	pdf, err := os.ReadFile(filepath.Join(*testdata, "benchmark", "Benchmark.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		Files []struct {
+			Filename string
+			Filesize int64
+			Matches  []struct{ ID string }
+		}
+	}
+	// two files attached as form-data
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, name := range []string{"one.pdf", "two.pdf"} {
+		fw, _ := mw.CreateFormFile("file", name)
+		fw.Write(pdf)
+	}
+	mw.Close()
+	resp, err := http.Post(srv.URL+"/identify?format=json", mw.FormDataContentType(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res result
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("expecting JSON results, got %v", err)
+	}
+	if len(res.Files) != 2 || res.Files[1].Filename != "two.pdf" || res.Files[1].Filesize != int64(len(pdf)) || len(res.Files[1].Matches) == 0 || res.Files[1].Matches[0].ID != "fmt/18" {
+		t.Errorf("unexpected results for form-data files: %+v", res)
+	}
+	// a chunked PUT body (its length isn't known as the request has no Content-Length)
+	req, _ := http.NewRequest("PUT", srv.URL+"/identify?format=json&name=put.pdf", io.MultiReader(bytes.NewReader(pdf)))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = result{}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("expecting JSON results, got %v", err)
+	}
+	if len(res.Files) != 1 || res.Files[0].Filename != "put.pdf" || res.Files[0].Filesize != int64(len(pdf)) || len(res.Files[0].Matches) == 0 || res.Files[0].Matches[0].ID != "fmt/18" {
+		t.Errorf("unexpected results for a chunked body: %+v", res)
+	}
+}
+
+func TestServePostPath(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	lg, _ := logger.New("")
+	ctxts := make(chan *context, 1)
+	go printer(ctxts, lg)
+	defer close(ctxts)
+	setCtxPool(s, &sync.WaitGroup{}, nil, false, false, -1)
+	srv := httptest.NewServer(&muxer{s: s, ctxts: ctxts})
+	defer srv.Close()
+	path, _ := filepath.Abs(filepath.Join(*testdata, "benchmark", "Benchmark.pdf"))
+	resp, err := http.Post(srv.URL+"/identify?format=json", "application/x-www-form-urlencoded", strings.NewReader(url.Values{"path": {path}}.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Files []struct {
+			Filename string
+			Matches  []struct{ ID string }
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("expecting JSON results, got %v", err)
+	}
+	if len(res.Files) != 1 || res.Files[0].Filename != path || len(res.Files[0].Matches) == 0 || res.Files[0].Matches[0].ID != "fmt/18" {
+		t.Errorf("unexpected results for POSTed path: %+v", res)
+	}
+}
+
+func TestServeReload(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	m := &muxer{s: siegfried.New()}
+	old := m.sf()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	get := func() int {
+		resp, err := http.Get(srv.URL + "/reload")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("expecting a 200, got %d", code)
+	}
+	loaded := m.sf()
+	if loaded == old || len(loaded.Identifiers()) != 1 {
+		t.Errorf("expecting the signature file to be swapped in, got %v", loaded.Identifiers())
+	}
+	// a signature file that can't be loaded is reported, and the loaded one kept
+	sig := config.SignatureBase()
+	config.SetSignature("missing.sig")
+	defer config.SetSignature(sig)
+	if code := get(); code != http.StatusInternalServerError {
+		t.Errorf("expecting a 500 for a missing signature file, got %d", code)
+	}
+	if m.sf() != loaded {
+		t.Error("expecting the loaded signature file to be kept")
+	}
+}
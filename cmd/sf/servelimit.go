@@ -0,0 +1,133 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var servelimitf = flag.String("servelimit", "", "in server mode, limit the identification requests handled at once, the requests queued while waiting (others get a 429 response) and the size of uploads e.g. -servelimit concurrency=4,queue=16,size=500MB (by default there are no limits; with a concurrency limit the default queue is 0)")
+
+// serveLimits guard the server against bursts of requests. They are set with -servelimit.
+var serveLimits serveLimit
+
+type serveLimit struct {
+	concurrency int   // identification requests (to /identify, /progress and /validate) handled at once (0 for no limit)
+	queue       int   // requests that wait for a turn when concurrency requests are being handled
+	size        int64 // maximum size of a request body (0 for no limit)
+}
+
+func newServeLimits(settings string) (serveLimit, error) {
+	var l serveLimit
+	for _, kv := range strings.Split(settings, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		var err error
+		switch k {
+		case "concurrency":
+			if l.concurrency, err = strconv.Atoi(v); err == nil && l.concurrency < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "queue":
+			if l.queue, err = strconv.Atoi(v); err == nil && l.queue < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "size":
+			l.size, err = parseSize(v)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return l, fmt.Errorf("bad -servelimit setting %q: %v", kv, err)
+		}
+	}
+	return l, nil
+}
+
+// limiter admits requests to the server within its serveLimit. A nil limiter admits everything.
+type limiter struct {
+	size  int64
+	slots chan struct{} // a token for each request being handled; nil for no concurrency limit
+	queue chan struct{} // a token for each request waiting for a slot
+}
+
+func newLimiter(l serveLimit) *limiter {
+	if l == (serveLimit{}) {
+		return nil
+	}
+	lim := &limiter{size: l.size}
+	if l.concurrency > 0 {
+		lim.slots = make(chan struct{}, l.concurrency)
+		lim.queue = make(chan struct{}, l.queue)
+	}
+	return lim
+}
+
+// acquire waits for a turn to handle a request. It returns false, without waiting, if the queue is full,
+// or if it is cancelled while it waits. Requests that are admitted must call release when they are done.
+func (l *limiter) acquire(cancel <-chan struct{}) bool {
+	if l == nil || l.slots == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	select {
+	case l.queue <- struct{}{}:
+	default:
+		return false
+	}
+	defer func() { <-l.queue }()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+func (l *limiter) release() {
+	if l == nil || l.slots == nil {
+		return
+	}
+	<-l.slots
+}
+
+// admit checks a request against the limits, responding with a 413 if its body is too large or a 429 if the server is
+// too busy to handle it. Admitted requests (for which admit returns true) must call l.release when they are done.
+// Bodies of unknown length (i.e. chunked) are cut off at the size limit, which is reported as an error.
+func (l *limiter) admit(w http.ResponseWriter, r *http.Request) bool {
+	if l == nil {
+		return true
+	}
+	if l.size > 0 {
+		if r.ContentLength > l.size {
+			handleErr(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body of %d bytes is larger than the server's limit of %d bytes", r.ContentLength, l.size))
+			return false
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, l.size)
+	}
+	if !l.acquire(r.Context().Done()) {
+		w.Header().Set("Retry-After", "1")
+		handleErr(w, http.StatusTooManyRequests, fmt.Errorf("server busy; too many identification requests, try again later"))
+		return false
+	}
+	return true
+}
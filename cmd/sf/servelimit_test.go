@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestServeLimits(t *testing.T) {
+	l, err := newServeLimits("concurrency=2, queue=1,size=1MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != (serveLimit{2, 1, 1 << 20}) {
+		t.Errorf("unexpected limits %+v", l)
+	}
+	for _, bad := range []string{"concurrency=-1", "queue=x", "size=big", "speed=1"} {
+		if _, err := newServeLimits(bad); err == nil {
+			t.Errorf("expecting an error for %s", bad)
+		}
+	}
+	if newLimiter(serveLimit{}) != nil {
+		t.Error("expecting a nil limiter without limits")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	lim := newLimiter(serveLimit{concurrency: 1, queue: 1})
+	never := make(chan struct{})
+	if !lim.acquire(never) {
+		t.Fatal("expecting the first request to be admitted")
+	}
+	// the second request waits in the queue until the first is released
+	admitted := make(chan bool)
+	go func() { admitted <- lim.acquire(never) }()
+	for len(lim.queue) == 0 {
+		runtime.Gosched()
+	}
+	// the queue is full, so a third request is refused
+	if lim.acquire(never) {
+		t.Fatal("expecting a request to be refused when the queue is full")
+	}
+	lim.release()
+	if !<-admitted {
+		t.Fatal("expecting the queued request to be admitted")
+	}
+	// a waiting request that is cancelled gives up its place
+	cancel := make(chan struct{})
+	close(cancel)
+	if lim.acquire(cancel) {
+		t.Error("expecting a cancelled request not to be admitted")
+	}
+	if len(lim.queue) != 0 {
+		t.Error("expecting a cancelled request to leave the queue")
+	}
+	lim.release()
+	var nl *limiter
+	if !nl.acquire(never) {
+		t.Error("expecting a nil limiter to admit requests")
+	}
+	nl.release()
+}
+
+func TestAdmit(t *testing.T) {
+	lim := newLimiter(serveLimit{concurrency: 1, size: 10})
+	w := httptest.NewRecorder()
+	if lim.admit(w, httptest.NewRequest("POST", "/identify", strings.NewReader("more than ten bytes"))) || w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expecting a 413, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	if !lim.admit(w, httptest.NewRequest("POST", "/identify", strings.NewReader("ten bytes!"))) {
+		t.Fatalf("expecting a request to be admitted, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	if lim.admit(w, httptest.NewRequest("GET", "/identify/file.doc", nil)) || w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expecting a 429 with Retry-After while busy, got %d", w.Code)
+	}
+	lim.release()
+	w = httptest.NewRecorder()
+	if !lim.admit(w, httptest.NewRequest("GET", "/identify/file.doc", nil)) {
+		t.Errorf("expecting a request to be admitted once the server isn't busy, got %d", w.Code)
+	}
+	lim.release()
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	servetlsf   = flag.String("servetls", "", "serve (with -serve or -grpc) over TLS with a certificate and key, and optionally require client certificates signed by a CA e.g. -servetls cert=server.pem,key=server.key,clientca=ca.pem")
+	servetokenf = flag.String("servetoken", "", "require a bearer token (Authorization: Bearer TOKEN) for requests in server mode (-serve or -grpc); give the token or @ and a file that contains it e.g. -servetoken @token.txt")
+)
+
+// serveAuth secures the server. It is set with -servetls and -servetoken.
+var serveAuth auth
+
+type auth struct {
+	tls   *tls.Config // nil to serve without TLS
+	token string      // empty for no token
+}
+
+func newAuth(tlsSettings, token string) (auth, error) {
+	var a auth
+	if strings.HasPrefix(token, "@") {
+		byts, err := os.ReadFile(token[1:])
+		if err != nil {
+			return a, fmt.Errorf("bad -servetoken: %v", err)
+		}
+		if t := strings.TrimSpace(string(byts)); t != "" {
+			token = t
+		} else {
+			return a, fmt.Errorf("bad -servetoken: %s is empty", token[1:])
+		}
+	}
+	a.token = token
+	if tlsSettings == "" {
+		return a, nil
+	}
+	var cert, key, clientca string
+	for _, kv := range strings.Split(tlsSettings, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		switch k {
+		case "cert":
+			cert = v
+		case "key":
+			key = v
+		case "clientca":
+			clientca = v
+		default:
+			return a, fmt.Errorf("bad -servetls setting %q: unknown setting", kv)
+		}
+	}
+	if cert == "" || key == "" {
+		return a, fmt.Errorf("bad -servetls: expecting both a cert and a key e.g. -servetls cert=server.pem,key=server.key")
+	}
+	kp, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return a, fmt.Errorf("bad -servetls: %v", err)
+	}
+	a.tls = &tls.Config{Certificates: []tls.Certificate{kp}, MinVersion: tls.VersionTLS12}
+	if clientca != "" {
+		pem, err := os.ReadFile(clientca)
+		if err != nil {
+			return a, fmt.Errorf("bad -servetls clientca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return a, fmt.Errorf("bad -servetls clientca: no PEM certificates in %s", clientca)
+		}
+		a.tls.ClientCAs, a.tls.ClientAuth = pool, tls.RequireAndVerifyClientCert
+	}
+	return a, nil
+}
+
+// authorized checks a request's bearer token. Browsers can't set headers for WebSocket connections (see handleProgress),
+// so the token can also be given as a token param.
+func authorized(token string, r *http.Request) bool {
+	if token == "" {
+		return true
+	}
+	got := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		got = strings.TrimPrefix(h, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert makes a certificate, signed by parent (or self-signed if parent is nil), and writes it and its key as PEM files
+// to dir, returning their paths.
+func testCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (string, string, *x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid, tmpl.KeyUsage = true, true, x509.KeyUsageCertSign|x509.KeyUsageDigitalSignature
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	kb, _ := x509.MarshalECPrivateKey(key)
+	cp, kp := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	os.WriteFile(cp, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(kp, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+	return cp, kp, cert, key
+}
+
+func TestNewAuth(t *testing.T) {
+	dir := t.TempDir()
+	cp, kp, _, _ := testCert(t, dir, "server", nil, nil)
+	tp := filepath.Join(dir, "token.txt")
+	os.WriteFile(tp, []byte("secret\n"), 0600)
+	a, err := newAuth("cert="+cp+",key="+kp, "@"+tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.token != "secret" || a.tls == nil || a.tls.ClientAuth != tls.NoClientCert {
+		t.Errorf("unexpected auth %+v", a)
+	}
+	for _, v := range [][2]string{
+		{"cert=" + cp, ""},
+		{"cert=" + cp + ",key=" + kp + ",ca=" + cp, ""},
+		{"cert=" + cp + ",key=" + kp + ",clientca=" + kp, ""},
+		{"", "@" + filepath.Join(dir, "missing")},
+	} {
+		if _, err := newAuth(v[0], v[1]); err == nil {
+			t.Errorf("expecting an error for %v", v)
+		}
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	for _, v := range []struct {
+		token, header, query string
+		ok                   bool
+	}{
+		{"", "", "", true},
+		{"secret", "Bearer secret", "", true},
+		{"secret", "", "?token=secret", true},
+		{"secret", "Bearer wrong", "?token=secret", false},
+		{"secret", "secret", "", false},
+		{"secret", "", "", false},
+	} {
+		r := httptest.NewRequest("GET", "/identify/data"+v.query, nil)
+		if v.header != "" {
+			r.Header.Set("Authorization", v.header)
+		}
+		if authorized(v.token, r) != v.ok {
+			t.Errorf("token %q, header %q, query %q: expecting %v", v.token, v.header, v.query, v.ok)
+		}
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	cap, _, ca, caKey := testCert(t, dir, "ca", nil, nil)
+	sp, sk, _, _ := testCert(t, dir, "server", ca, caKey)
+	clp, clk, _, _ := testCert(t, dir, "client", ca, caKey)
+	a, err := newAuth("cert="+sp+",key="+sk+",clientca="+cap, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(&muxer{token: a.token})
+	srv.TLS = a.tls
+	srv.StartTLS()
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(clp, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(certs []tls.Certificate, token string) (int, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	if code, err := get([]tls.Certificate{clientCert}, "secret"); err != nil || code != http.StatusOK {
+		t.Errorf("expecting a 200 with a client certificate and token, got %d (%v)", code, err)
+	}
+	if code, err := get([]tls.Certificate{clientCert}, "wrong"); err != nil || code != http.StatusUnauthorized {
+		t.Errorf("expecting a 401 with a bad token, got %d (%v)", code, err)
+	}
+	if _, err := get(nil, "secret"); err == nil {
+		t.Error("expecting the handshake to fail without a client certificate")
+	}
+}
@@ -16,6 +16,9 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	gocontext "context" // the context type in this package is the state of a file being identified
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
@@ -24,12 +27,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/richardlehane/siegfried"
 	"github.com/richardlehane/siegfried/internal/checksum"
 	"github.com/richardlehane/siegfried/internal/logger"
+	"github.com/richardlehane/siegfried/internal/siegreader"
 	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/core"
 	"github.com/richardlehane/siegfried/pkg/decompress"
@@ -46,24 +52,45 @@ var (
 	update         = flag.Bool("update", false, "update or install the default signature file")
 	versionShort   = flag.Bool("v", false, "display version information")
 	version        = flag.Bool("version", false, "display version information")
-	logf           = flag.String("log", "error", "log errors, warnings, debug or slow output, knowns or unknowns to stderr or stdout e.g. -log error,warn,unknown,stdout")
+	logf           = flag.String("log", "error", "log errors, warnings, debug or slow output, knowns or unknowns to stderr or stdout e.g. -log error,warn,unknown,stdout; -log errors.log logs to a file; -log skip logs files left out by walk filters; -log eta reports progress with an ETA")
 	nr             = flag.Bool("nr", false, "prevent automatic directory recursion")
-	_              = flag.Bool("yaml", true, "YAML output format") // yaml is the default, need a flag so can overwrite config (see conf.go)
-	csvo           = flag.Bool("csv", false, "CSV output format")
-	jsono          = flag.Bool("json", false, "JSON output format")
-	droido         = flag.Bool("droid", false, "DROID CSV output format")
+	yamlo          = outputFlag("yaml", true, "YAML output format") // yaml is the default, need a flag so can overwrite config (see conf.go)
+	csvo           = outputFlag("csv", false, "CSV output format")
+	csvfields      = flag.String("csvfields", "", "CSV output with a selection of columns, in order e.g. -csvfields filename,puid,sha256,mime")
+	jsono          = outputFlag("json", false, "JSON output format")
+	jsonlo         = outputFlag("jsonl", false, "JSON Lines output format, writing a JSON object for each file as soon as it is identified")
+	droido         = outputFlag("droid", false, "DROID CSV output format")
+	fidoo          = outputFlag("fido", false, "fido CSV output format, as written with fido's default -matchprintf")
+	doto           = outputFlag("dot", false, "Graphviz DOT output format, graphing archive members (with -z) e.g. sf -z -dot DIR | dot -Tsvg > tree.svg")
+	premiso        = outputFlag("premis", false, "PREMIS XML output format, with an object entry for each file")
+	metso          = outputFlag("mets", false, "METS XML output format, wrapping PREMIS object entries for each file")
+	sqlitef        = flag.String("sqlite", "", "store results in a new SQLite database at this path, alongside any other outputs e.g. -sqlite results.db")
+	templatef      = flag.String("template", "", "render results for each file with a Go text/template, given inline or as @file e.g. -template '{{.Filename}},{{range .Matches}}{{.ID}}{{end}}'")
 	sig            = flag.String("sig", config.SignatureBase(), "set the signature file")
 	home           = flag.String("home", config.Home(), "override the default home directory")
 	serve          = flag.String("serve", "", "start siegfried server e.g. -serve localhost:5138")
 	multi          = flag.Int("multi", 1, "set number of parallel file ID processes")
 	archive        = flag.Bool("z", false, fmt.Sprintf("scan archive formats: (%s)", config.ListAllArcTypes()))
 	selectArchives = flag.String("zs", "", fmt.Sprintf("select archive formats to scan: (%s)", config.ListAllArcTypes()))
-	hashf          = flag.String("hash", "", "calculate file checksum with hash algorithm; options "+checksum.HashChoices)
+	diskf          = flag.Bool("disk", false, "scan the partitions and filesystems (FAT, NTFS, ext, ISO 9660) of raw and EWF (E01) disk images")
+	olef           = flag.Bool("ole", false, "scan objects embedded in OLE2 compound documents (e.g. packaged files in Word and Excel documents)")
+	notempf        = flag.Bool("notemp", false, "don't copy large archive members to temp files: members of compressed streams (e.g. tar.gz) are decompressed again if needed, but big random-access members (e.g. a zip within a tar.gz) can't be scanned")
+	maxBytesf      = flag.String("max-bytes", "", "stop scanning each file this far from its start, unless a signature is matched sooner (signatures anchored to the end of a file are still checked); files that may have matched further in have an error e.g. -max-bytes 10MB")
+	enginef        = flag.String("engine", "", "select the bytematcher's sequence matching engine: dynamic (default), static (scans for all sequences in one pass, which can be faster for corpora of mostly unknown files) or lowmem (as static, with a smaller tree)")
+	recordsf       = flag.Bool("records", false, "with -z, report the target URI and date of each WARC and ARC record identified")
+	summarise      = flag.Bool("zsum", false, "summarise archive formats (member count, sizes and compression methods) without identifying their contents")
+	hashf          = flag.String("hash", "", "calculate file checksums with one or more hash algorithms, given in a single read e.g. -hash md5,sha256; options "+checksum.HashChoices)
 	throttlef      = flag.Duration("throttle", 0, "set a time to wait between scanning files e.g. 50ms")
+	basesf         = flag.Bool("bases", false, "with -json or -jsonl, also report the basis of each match in a structured form: a list of the matchers that matched, with the offsets and lengths of byte matches")
+	nameOnly       = flag.Bool("nameonly", false, "identify files by filename and MIME type only, without reading their content, for a fast pre-classification pass")
 	utcf           = flag.Bool("utc", false, "report file modified times in UTC, rather than local, TZ")
+	textf          = flag.String("text", "", "tune the heuristic used to detect text files e.g. -text sample=8192,tolerance=0.01,ranges=ascii+latin1")
+	unknownf       = flag.String("unknown", "", "report unidentified files with a placeholder id and format, rather than empty values e.g. -unknown UNKNOWN")
 	coe            = flag.Bool("coe", false, "continue on fatal errors during directory walks (this may result in directories being skipped)")
 	replay         = flag.Bool("replay", false, "replay one (or more) results files to change output or logging e.g. sf -replay -csv results.yaml")
+	queryf         = flag.String("query", "", "replay results files, re-emitting only the files that match a query e.g. sf -query \"puid = fmt/276 and warning ~ *mismatch*\" results.json")
 	list           = flag.Bool("f", false, "scan one (or more) lists of filenames e.g. sf -f myfiles.txt")
+	nul            = flag.Bool("0", false, "with -f, read lists of filenames delimited by NUL characters, rather than newlines e.g. find . -print0 | sf -f -0 -")
 	name           = flag.String("name", "", "provide a filename when scanning a stream e.g. sf -name myfile.txt -")
 	conff          = flag.String("conf", "", "set the configuration file")
 	setconff       = flag.Bool("setconf", false, "record flags used with this command in configuration file")
@@ -71,7 +98,9 @@ var (
 
 var (
 	throttle *time.Ticker
-	ctxPool  *sync.Pool
+	// display names for formats, set by -names
+	formatNames map[string]string
+	ctxPool     *sync.Pool
 )
 
 type modeError os.FileMode
@@ -114,6 +143,7 @@ func setCtxPool(s *siegfried.Siegfried, wg *sync.WaitGroup, w writer.Writer, d,
 				d:   d,
 				z:   z,
 				h:   checksum.MakeHash(h),
+				hx:  makeHashes(extraHashes),
 				res: make(chan results, 1),
 			}
 		},
@@ -127,7 +157,12 @@ func getCtx(path, mime string, mod time.Time, sz int64) *context {
 	if c.h != nil {
 		c.h.Reset()
 	}
-	c.path, c.mime, c.mod, c.sz = path, mime, mod, sz
+	for _, h := range c.hx {
+		h.Reset()
+	}
+	c.path, c.mime, c.mod, c.sz, c.seq, c.rec, c.anon, c.declared = path, mime, mod, sz, nil, nil, false, ""
+	c.depth, c.bud, c.rewind, c.members = 0, nil, nil, nil
+	c.bag, c.bh, c.fid, c.cancel = nil, nil, nil, nil
 	return c
 }
 
@@ -137,13 +172,32 @@ type context struct {
 	w  writer.Writer
 	d  bool // droid
 	// opts
-	z bool
-	h hash.Hash
+	z  bool
+	h  hash.Hash
+	hx []hash.Hash // any hashes after the first given to -hash e.g. -hash md5,sha256
 	// info
 	path string
 	mime string
 	mod  time.Time
 	sz   int64
+	seq  []string // extra fields for a file sequence
+	rec  []string // extra fields for a web archive record
+	anon bool     // path isn't a filename so shouldn't be used for identification (e.g. a data URI)
+	// the media type declared by a data URI: reported in the declared field, but not used for identification
+	declared string
+	// containers
+	depth  int                       // number of containers this file is nested within
+	bud    *budget                   // shared by the members of an outermost container
+	rewind func() (io.Reader, error) // decompresses a member of a compressed stream again (see -notemp)
+	// with -multi, the members of a container scanned alongside other files (see identifyFile)
+	members chan *context
+	// bags
+	bag *bag      // the BagIt bag the file is in (see -bag)
+	bh  hash.Hash // the hash of the bag's manifest
+	// the device and inode of a file read with -cache, so that its result can be cached
+	fid *fileID
+	// with -serve, the context of the request: its identifications stop if the client goes away
+	cancel gocontext.Context
 	// results
 	res chan results
 }
@@ -152,43 +206,165 @@ type results struct {
 	err error
 	cs  []byte
 	ids []core.Identification
+	ex  []string // extra fields
 }
 
 func printer(ctxts chan *context, lg *logger.Logger) {
 	for ctx := range ctxts {
-		lg.Progress(ctx.path)
-		// block on the results
-		res := <-ctx.res
-		lg.Error(ctx.path, res.err)
-		lg.IDs(ctx.path, res.ids)
-		if *utcf {
-			ctx.mod = ctx.mod.UTC()
-		}
-		// write the result
-		ctx.w.File(ctx.path, ctx.sz, ctx.mod.Format(time.RFC3339), res.cs, res.err, res.ids)
+		members := ctx.members
+		printCtx(ctx, lg)
+		// report the members of a container after it, and before the next file
+		if members != nil {
+			for m := range members {
+				printCtx(m, lg)
+			}
+		}
+	}
+}
+
+// printCtx writes a file's result, once it is ready
+func printCtx(ctx *context, lg *logger.Logger) {
+	lg.Progress(ctx.path)
+	// block on the results
+	res := <-ctx.res
+	lg.Error(ctx.path, res.err)
+	if metrics != nil {
+		metrics.add(ctx.sz, res.err, res.ids)
+	}
+	if notifying != nil {
+		notifying.add(ctx.sz, res.err, res.ids)
+	}
+	if rcache != nil {
+		rcache.put(ctx, res)
+	}
+	if *unknownf != "" {
+		res.ids = writer.Placeholder(res.ids, *unknownf)
+	}
+	if formatNames != nil {
+		res.ids = writer.Rename(res.ids, formatNames)
+	}
+	// with -state, only report new files and changed identifications
+	if incremental != nil && !incremental.record(ctx.path, ctx.sz, ctx.mod, res.err, res.ids) {
 		ctx.wg.Done()
-		ctxPool.Put(ctx) // return the context to the pool
+		ctxPool.Put(ctx)
+		return
+	}
+	lg.IDs(ctx.path, res.ids)
+	if failures != nil {
+		failures.add(ctx.sz, res.err, res.ids)
+	}
+	if duplicates != nil {
+		duplicates.add(ctx.path, ctx.sz, res.cs, res.err)
+	}
+	if bags != nil {
+		for len(res.ex) < bags.field {
+			res.ex = append(res.ex, "")
+		}
+		res.ex = append(res.ex[:bags.field], bags.fields(ctx, res.err)...)
+	}
+	if verifying != nil {
+		for len(res.ex) < verifying.field {
+			res.ex = append(res.ex, "")
+		}
+		res.ex = append(res.ex[:verifying.field], verifying.check(ctx.path, ctx.sz, res.cs, res.err, res.ids))
 	}
+	if corpus != nil {
+		for len(res.ex) < corpus.field {
+			res.ex = append(res.ex, "")
+		}
+		res.ex = append(res.ex[:corpus.field], corpus.check(ctx.path, ctx.sz, res.err, res.ids))
+	}
+	if *utcf {
+		ctx.mod = ctx.mod.UTC()
+	}
+	// write the result
+	ctx.w.File(ctx.path, ctx.sz, ctx.mod.Format(time.RFC3339), res.cs, res.err, res.ids, res.ex)
+	ctx.wg.Done()
+	ctxPool.Put(ctx) // return the context to the pool
 }
 
 // convenience function for printing files we haven't ID'ed (e.g. dirs or errors)
 func printFile(ctxs chan *context, ctx *context, err error) {
-	ctx.res <- results{err, nil, nil}
+	ctx.res <- results{err, nil, nil, nil}
 	ctx.wg.Add(1)
 	ctxs <- ctx
 }
 
+// extraHashes are the hashes after the first given to -hash. They are calculated in the same pass as the first hash,
+// and reported as extra fields named for the algorithm.
+var extraHashes []checksum.HashTyp
+
+func makeHashes(typs []checksum.HashTyp) []hash.Hash {
+	if len(typs) == 0 {
+		return nil
+	}
+	ret := make([]hash.Hash, len(typs))
+	for i, t := range typs {
+		ret[i] = checksum.MakeHash(t)
+	}
+	return ret
+}
+
+// hashing reports whether a context has any hashes to calculate
+func (c *context) hashing() bool {
+	return c.h != nil || c.bh != nil
+}
+
+// hashWriter writes to all of a context's hashes
+func (c *context) hashWriter() io.Writer {
+	if len(c.hx) == 0 && c.bh == nil {
+		return c.h
+	}
+	w := make([]io.Writer, 0, len(c.hx)+2)
+	if c.h != nil {
+		w = append(w, c.h)
+	}
+	for _, h := range c.hx {
+		w = append(w, h)
+	}
+	if c.bh != nil {
+		w = append(w, c.bh)
+	}
+	return io.MultiWriter(w...)
+}
+
+// sums returns the hex encoded sums of any extra hashes (or empty fields, if hashes weren't calculated)
+func (c *context) sums(calculated bool) []string {
+	ret := make([]string, len(c.hx))
+	if calculated {
+		for i, h := range c.hx {
+			ret[i] = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return ret
+}
+
 // identify() defined in longpath.go and longpath_windows.go
 
 func readFile(ctx *context, ctxts chan *context, gf getFn) {
+	if *nameOnly {
+		ctx.res <- results{nil, nil, ctx.s.IdentifyName(ctx.path, ctx.mime), nil}
+		return
+	}
 	f, err := os.Open(ctx.path)
 	if err != nil {
 		f, err = retryOpen(ctx.path, err) // retry open in case is a windows long path error
 		if err != nil {
-			ctx.res <- results{err, nil, nil}
+			ctx.res <- results{err, nil, nil, nil}
 			return
 		}
 	}
+	if rcache != nil && cacheable(ctx) {
+		if info, err := f.Stat(); err == nil {
+			if res, ok := rcache.get(ctx, info); ok {
+				f.Close()
+				ctx.res <- res
+				return
+			}
+			fid := getFileID(info)
+			ctx.fid = &fid
+		}
+	}
 	identifyRdr(f, ctx, ctxts, gf)
 	f.Close()
 }
@@ -196,60 +372,193 @@ func readFile(ctx *context, ctxts chan *context, gf getFn) {
 func identifyFile(ctx *context, ctxts chan *context, gf getFn) {
 	wg := ctx.wg
 	wg.Add(1)
-	ctxts <- ctx
-	if *multi == 1 || ctx.z || config.Slow() || config.Debug() {
+	ctx.members = nil
+	if *multi == 1 || config.Slow() || config.Debug() {
+		ctxts <- ctx
 		readFile(ctx, ctxts, gf)
 		return
 	}
+	// the members of a container are sent on a channel of its own, which the printer drains after the container's
+	// result: so they are reported together, in order, while other files are scanned
+	members := ctxts
+	if ctx.z || *diskf || *olef {
+		members = make(chan *context, *multi)
+		ctx.members = members
+	}
+	ctxts <- ctx
 	wg.Add(1)
 	go func() {
-		readFile(ctx, ctxts, gf)
+		readFile(ctx, members, gf)
+		if members != ctxts {
+			close(members)
+		}
 		wg.Done()
 	}()
 }
 
+// identifyStdin identifies the stream given on stdin (sf -), using the -name flag as its filename
+func identifyStdin(ctxts chan *context) {
+	ctx := getCtx(*name, "", time.Time{}, 0)
+	ctx.wg.Add(1)
+	ctxts <- ctx
+	identifyRdr(os.Stdin, ctx, ctxts, getCtx)
+}
+
 func identifyRdr(r io.Reader, ctx *context, ctxts chan *context, gf getFn) {
 	s := ctx.s
+	stdin := r == os.Stdin
+	// hash streams as they are read, as a big stream won't keep all its bytes with -notemp
+	tee := ctx.hashing() && streamed(r)
+	if tee {
+		r = io.TeeReader(r, ctx.hashWriter())
+	}
 	b, berr := s.Buffer(r)
 	defer s.Put(b)
-	ids, err := s.IdentifyBuffer(b, berr, ctx.path, ctx.mime)
+	// archives, disk images and OLE2 objects are read in full after identification, so big streams can't be windowed (unless -notemp)
+	if b != nil && (ctx.z || *diskf || *olef) {
+		b.Window = false
+	}
+	if ctx.cancel != nil && b != nil {
+		b.SetContext(ctx.cancel)
+	}
+	name := ctx.path
+	if ctx.anon {
+		name = ""
+	}
+	ids, err := s.IdentifyBuffer(b, berr, name, ctx.mime)
 	if ids == nil {
-		ctx.res <- results{err, nil, nil}
+		ctx.res <- results{err, nil, nil, nil}
 		return
 	}
+	if *entropyf {
+		ids = addEntropyHints(b, ids)
+	}
 	// calculate checksum
 	var cs []byte
-	if ctx.h != nil {
+	if tee {
+		b.SizeNow() // finish reading the stream
+	} else if ctx.hashing() {
 		var i int64
-		l := ctx.h.BlockSize()
+		l, hw := 4096, ctx.hashWriter()
 		for ; ; i += int64(l) {
 			buf, _ := b.Slice(i, l)
 			if buf == nil {
 				break
 			}
-			ctx.h.Write(buf)
+			hw.Write(buf)
 		}
+	}
+	if ctx.h != nil {
 		cs = ctx.h.Sum(nil)
 	}
+	hs := ctx.sums(cs != nil && ctx.seq == nil) // a sequence's hashes would only be those of its first frame
+	// the size of stdin, or of another stream of unknown length (e.g. a chunked upload), is only known once it has been read to the end
+	if stdin || ctx.sz < 0 {
+		ctx.sz = b.SizeNow()
+	}
+	// calculate any extra fields
+	ex := append(s.Extra(b, ids), hs...)
+	if *dataf {
+		ex = append(ex, ctx.declared)
+	}
+	if comparator != nil {
+		ex = append(ex, compare(ids, b, berr, name, ctx.mime)...)
+	}
+	if *recordsf {
+		if ctx.rec == nil {
+			ex = append(ex, make([]string, len(decompress.RecordFields))...)
+		} else {
+			ex = append(ex, ctx.rec...)
+		}
+	}
+	// report a file sequence as a single result (its checksum would only be that of the first frame)
+	if ctx.seq != nil {
+		ctx.res <- results{err, nil, ids, append(ex, ctx.seq...)}
+		return
+	}
+	// scan partitions and filesystems if a disk image
+	if *diskf {
+		d, derr := decompress.NewDisk(b, ctx.path)
+		if derr == nil {
+			c := *ctx // the context is returned to the pool, and may be reused, once its result is printed
+			ctx.res <- results{err, cs, ids, ex}
+			recurse(d, &c, ctxts, gf)
+			return
+		}
+		if derr != decompress.ErrNoPartitions {
+			ctx.res <- results{fmt.Errorf("failed to read disk image, got: %v", derr), cs, ids, ex}
+			return
+		}
+	}
+	// scan embedded objects if an OLE2 compound document
+	if *olef {
+		if d, oerr := decompress.NewOLE(b, ctx.path); oerr == nil {
+			c := *ctx
+			ctx.res <- results{err, cs, ids, ex}
+			recurse(d, &c, ctxts, gf)
+			return
+		}
+	}
 	// decompress if an archive format
 	if !ctx.z {
-		ctx.res <- results{err, cs, ids}
+		ctx.res <- results{err, cs, ids, ex}
 		return
 	}
 	arc := decompress.IsArc(ids)
 	if arc == config.None {
-		ctx.res <- results{err, cs, ids}
+		ctx.res <- results{err, cs, ids, ex}
+		return
+	}
+	// summarise rather than decompress if -zsum
+	if *summarise {
+		sum, serr := decompress.Summarise(arc, b, ctx.path, ctx.sz)
+		if serr != nil {
+			ctx.res <- results{fmt.Errorf("failed to summarise archive, got: %v", serr), cs, ids, ex}
+			return
+		}
+		ctx.res <- results{err, cs, ids, append(ex, sum.Fields()...)}
 		return
 	}
+	// a stream too big to buffer in full (with -notemp) is decompressed again to scan its contents
+	if b.Truncated() {
+		rr, rerr := rewind(ctx)
+		if rerr == nil {
+			var rb *siegreader.Buffer
+			rb, rerr = s.Buffer(rr)
+			defer s.Put(rb)
+			if rb != nil {
+				rb.Window = false
+			}
+			b = rb
+		}
+		if rerr != nil {
+			ctx.res <- results{fmt.Errorf("failed to decompress, got: %v", rerr), cs, ids, ex}
+			return
+		}
+	}
 	d, err := decompress.New(arc, b, ctx.path, ctx.sz)
 	if err != nil {
-		ctx.res <- results{fmt.Errorf("failed to decompress, got: %v", err), cs, ids}
+		ctx.res <- results{fmt.Errorf("failed to decompress, got: %v", err), cs, ids, ex}
 		return
 	}
 	// send the result
+	c := *ctx
+	ctx.res <- results{err, cs, ids, ex}
+	recurse(d, &c, ctxts, gf)
+}
+
+// identify each member of an archive or disk image
+func recurse(d decompress.Decompressor, ctx *context, ctxts chan *context, gf getFn) {
 	zpath := ctx.path
-	ctx.res <- results{err, cs, ids}
-	// decompress and recurse
+	if ctx.depth >= zlimits.depth {
+		printFile(ctxts, gf(decompress.Arcpath(zpath, ""), "", time.Time{}, 0), depthError(zlimits.depth))
+		return
+	}
+	bud := ctx.bud
+	if bud == nil {
+		bud = newBudget(ctx.sz)
+	}
+	var err error
 	for err = d.Next(); err == nil; err = d.Next() {
 		if ctx.d {
 			for _, v := range d.Dirs() {
@@ -257,15 +566,76 @@ func identifyRdr(r io.Reader, ctx *context, ctxts chan *context, gf getFn) {
 			}
 		}
 		nctx := gf(d.Path(), d.MIME(), d.Mod(), d.Size())
+		if r, ok := d.(decompress.Recorder); ok && *recordsf {
+			nctx.rec = r.Fields()
+		}
+		nctx.depth, nctx.bud = ctx.depth+1, bud
+		if rw, ok := d.(decompress.Rewinder); ok {
+			nctx.rewind = rw.Rewind
+		}
 		nctx.wg.Add(1)
 		ctxts <- nctx
-		identifyRdr(d.Reader(), nctx, ctxts, gf)
+		identifyRdr(bud.reader(d.Reader()), nctx, ctxts, gf)
+		// abandon the outermost container, and any within it, once the budget is exceeded
+		if bud.err != nil {
+			if ctx.bud == nil {
+				printFile(ctxts, gf(decompress.Arcpath(zpath, ""), "", time.Time{}, 0), bud.err)
+			}
+			return
+		}
 	}
 	if err != io.EOF && err != nil {
 		printFile(ctxts, gf(decompress.Arcpath(zpath, ""), "", time.Time{}, 0), fmt.Errorf("error occurred during decompression: %v", err))
 	}
 }
 
+// streamed reports whether a reader will be buffered as a stream, rather than as a file or in place
+func streamed(r io.Reader) bool {
+	switch v := r.(type) {
+	case *os.File:
+		fi, err := v.Stat()
+		return err != nil || fi.Mode()&os.ModeType != 0
+	case interface{ IsSlicer() bool }:
+		return !v.IsSlicer()
+	}
+	return true
+}
+
+// rewind decompresses a member of a compressed stream again from its start
+func rewind(ctx *context) (io.Reader, error) {
+	if ctx.rewind == nil {
+		return nil, decompress.ErrTruncated
+	}
+	r, err := ctx.rewind()
+	if err == nil && ctx.bud != nil {
+		r = ctx.bud.reader(r)
+	}
+	return r, err
+}
+
+// scanArgs returns the files and directories to scan: with -verify or -test, the files listed in the manifest if none are given
+// (literal is true if so). It also sets the slots of the bag, fixity and test fields, which are filled in by the printer.
+func scanArgs(s *siegfried.Siegfried, args []string) ([]string, bool) {
+	var literal bool
+	_, sl := extraSlots(s)
+	if verifying != nil {
+		if len(args) == 0 {
+			args, literal = verifying.paths(), true // listed paths aren't globs; and missing files are reported
+		}
+		verifying.field = sl.fixity
+	}
+	if corpus != nil {
+		if len(args) == 0 {
+			args, literal = corpus.paths(), true
+		}
+		corpus.field = sl.test
+	}
+	if bags != nil {
+		bags.field = sl.bag
+	}
+	return args, literal
+}
+
 func openFile(path string) (*os.File, error) {
 	if path == "-" {
 		return os.Stdin, nil
@@ -273,7 +643,49 @@ func openFile(path string) (*os.File, error) {
 	return os.Open(path)
 }
 
-var firstReplay sync.Once
+// listScanner splits a list of filenames (given with -f) into lines or, with -0, NUL delimited entries. Blank entries are skipped.
+func listScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	split := bufio.ScanLines
+	if *nul {
+		split = scanNul
+	}
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		adv, tok, err := split(data, atEOF)
+		if tok != nil && len(tok) == 0 {
+			return adv, nil, err
+		}
+		return adv, tok, err
+	})
+	return scanner
+}
+
+func scanNul(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// parseTemplate parses a -template: a template file if the flag is given as @file, or the flag's text otherwise.
+// A newline is added to inline template text that doesn't end with one, so that each file is reported on its own line.
+func parseTemplate(text string) (*template.Template, error) {
+	if strings.HasPrefix(text, "@") {
+		return template.ParseFiles(text[1:])
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return template.New("template").Parse(text)
+}
+
+var (
+	firstReplay sync.Once
+	query       *reader.Query // set by -query
+)
 
 func replayFile(path string, ctxts chan *context, w writer.Writer) error {
 	f, err := openFile(path)
@@ -286,16 +698,33 @@ func replayFile(path string, ctxts chan *context, w writer.Writer) error {
 		return fmt.Errorf("[FATAL] error reading results file %s; got %v", path, err)
 	}
 	hd := rdr.Head()
-	if *droido && (len(hd.Identifiers) != 1 || len(hd.Fields[0]) != 7) {
+	if droido.on && (len(hd.Identifiers) != 1 || len(hd.Fields[0]) != 7) {
 		return errors.New("[FATAL] DROID output is limited to signature files with a single PRONOM identifier")
 	}
 	firstReplay.Do(func() {
-		w.Head(hd.SignaturePath, hd.Scanned, hd.Created, hd.Version, hd.Identifiers, hd.Fields, hd.HashHeader)
+		w.Head(hd.SignaturePath, hd.Scanned, hd.Created, hd.Version, hd.Identifiers, hd.Fields, hd.HashHeader, nil)
+		if e, ok := w.(interface{ Err() error }); ok {
+			err = e.Err()
+		}
 	})
+	if err != nil {
+		return fmt.Errorf("[FATAL] %v", err)
+	}
 	var rf reader.File
 	for rf, err = rdr.Next(); err == nil; rf, err = rdr.Next() {
+		if query != nil && !query.Match(hd, rf) {
+			continue
+		}
 		ctx := getCtx(rf.Path, "", rf.Mod, rf.Size)
-		ctx.res <- results{rf.Err, rf.Hash, rf.IDs}
+		// results files record hashes hex encoded, and the writers encode them again
+		var cs []byte
+		if len(rf.Hash) > 0 {
+			var herr error
+			if cs, herr = hex.DecodeString(string(rf.Hash)); herr != nil {
+				cs = nil
+			}
+		}
+		ctx.res <- results{rf.Err, cs, rf.IDs, nil}
 		ctx.wg.Add(1)
 		ctxts <- ctx
 	}
@@ -346,21 +775,152 @@ func main() {
 		return
 	}
 	// handle -hash error
-	hashT := checksum.GetHash(*hashf)
-	if *hashf != "" && hashT < 0 {
-		log.Fatalf("[FATAL] invalid hash type; choose from %s", checksum.HashChoices)
+	hashT := checksum.HashTyp(-1)
+	if *hashf != "" {
+		hashes := checksum.GetHashes(*hashf)
+		if hashes == nil {
+			log.Fatalf("[FATAL] invalid hash type; choose one or more (e.g. md5,sha256) from %s", checksum.HashChoices)
+		}
+		hashT, extraHashes = hashes[0], hashes[1:]
+	}
+	// handle -verify
+	if *verifyf != "" {
+		if *replay {
+			log.Fatalln("[FATAL] -verify re-reads files, so can't be combined with -replay")
+		}
+		var err error
+		if verifying, err = newVerifier(*verifyf); err != nil {
+			log.Fatalf("[FATAL] error reading %s: %v", *verifyf, err)
+		}
+		if verifying.hash >= 0 {
+			if hashT < 0 {
+				hashT = verifying.hash
+			} else if hashT != verifying.hash {
+				log.Fatalf("[FATAL] -verify: %s has %s checksums, so the first -hash must be %s", *verifyf, verifying.hash, verifying.hash)
+			}
+		}
+	}
+	// handle -test
+	if *testf != "" {
+		if *replay {
+			log.Fatalln("[FATAL] -test re-reads files, so can't be combined with -replay")
+		}
+		var err error
+		if corpus, err = newTester(*testf); err != nil {
+			log.Fatalf("[FATAL] error reading %s: %v", *testf, err)
+		}
+	}
+	// handle -dups
+	if *dupsf != "" {
+		switch {
+		case *replay:
+			duplicates = newDupFinder("checksum") // use the checksums in the results files
+		case hashT < 0:
+			log.Fatalln("[FATAL] -dups finds duplicates by their checksums, so needs -hash e.g. -hash sha256 -dups dups.csv")
+		default:
+			duplicates = newDupFinder(hashT.String())
+		}
+	}
+	// handle -query
+	if *queryf != "" {
+		var err error
+		if query, err = reader.NewQuery(*queryf); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+		*replay = true // if query flag given, no need to also give replay flag
+	}
+	// handle -sample
+	if *samplef != "" {
+		var err error
+		if sampling, err = newSampler(*samplef); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -exclude, -include, -hidden and the size and time filters
+	if *excludef != "" || *includef != "" || !*hiddenf || *minsizef != "" || *maxsizef != "" || *newerf != "" || *olderf != "" {
+		var err error
+		if walkFilters, err = newWalkFilter(*excludef, *includef); err == nil {
+			err = walkFilters.setLimits(*minsizef, *maxsizef, *newerf, *olderf, time.Now())
+		}
+		if err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+		walkFilters.nohidden = !*hiddenf
+	}
+	// handle -zlimit
+	if *zlimitf != "" {
+		var err error
+		if zlimits, err = newLimits(*zlimitf); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -servelimit
+	if *servelimitf != "" {
+		var err error
+		if serveLimits, err = newServeLimits(*servelimitf); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -servetls and -servetoken
+	if *servetlsf != "" || *servetokenf != "" {
+		var err error
+		if serveAuth, err = newAuth(*servetlsf, *servetokenf); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -failon
+	if *failonf != "" {
+		var err error
+		if failures, err = newFailOn(*failonf); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -notify
+	if *notifyf != "" {
+		notifying = newNotifier(*notifyf)
 	}
 	// load and handle signature errors
 	var (
 		s   *siegfried.Siegfried
 		err error
 	)
-	if !*replay || *version || *versionShort || *fprflag || *serve != "" {
+	if !*replay || *version || *versionShort || *fprflag || *serve != "" || *grpcf != "" {
 		s, err = load(config.Signature())
 	}
 	if err != nil {
 		log.Fatalf("[FATAL] error loading signature file, got: %v", err)
 	}
+	if s != nil {
+		if err = addExtras(s); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -compare
+	if *comparef != "" && s != nil {
+		if comparator, err = siegfried.Load(config.Local(*comparef)); err != nil {
+			log.Fatalf("[FATAL] error loading signature file %s, got: %v", *comparef, err)
+		}
+		if len(comparator.Identifiers()) != len(s.Identifiers()) {
+			log.Println("[WARN] -compare: the signature files have different numbers of identifiers, so results will differ in their namespaces")
+		}
+	}
+	// handle -names
+	if *namesf != "" {
+		var known []string
+		if s != nil {
+			for _, f := range s.Formats() {
+				known = append(known, f...)
+			}
+		}
+		var unknown []string
+		formatNames, unknown, err = loadNames(*namesf, known)
+		if err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+		for _, u := range unknown {
+			log.Printf("[WARN] -names: %s isn't a format in the signature file", u)
+		}
+	}
 	// handle -version
 	if *version || *versionShort {
 		version := config.Version()
@@ -378,9 +938,9 @@ func main() {
 		}
 		return
 	}
-	// handle -z and -zs
-	if *archive || *selectArchives != "" {
-		*archive = true // if zs flag given, no need to also give z flag
+	// handle -z, -zs and -zsum
+	if *archive || *selectArchives != "" || *summarise {
+		*archive = true // if zs or zsum flag given, no need to also give z flag
 		if *selectArchives == "" {
 			config.SetArchiveFilterPermissive(config.ListAllArcTypes())
 
@@ -388,6 +948,47 @@ func main() {
 			config.SetArchiveFilterPermissive(*selectArchives)
 		}
 	}
+	// handle -bag
+	if *bagf {
+		bags = newBagChecker()
+	}
+	// handle -text
+	if *textf != "" {
+		if err := config.SetText(*textf); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -seq and -seqf
+	if *seqf || *seqFrames {
+		sequencing = newSequencer()
+	}
+	// check -nameonly
+	if *nameOnly && s != nil && (*archive || *diskf || *olef || *hashf != "" || *entropyf || len(extraFields(s)) > 0) {
+		log.Fatalln("[FATAL] -nameonly doesn't read file content, so can't be combined with -z, -disk, -ole, -hash, -entropy or flags that add fields (e.g. -probe)")
+	}
+	if *nameOnly {
+		config.SetNameOnly()
+	}
+	if *notempf {
+		config.SetNoTemp()
+	}
+	// handle -engine
+	if *enginef != "" {
+		if err := config.SetEngine(*enginef); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	// handle -max-bytes
+	if *maxBytesf != "" {
+		mb, err := parseSize(*maxBytesf)
+		if err != nil {
+			log.Fatalf("[FATAL] bad -max-bytes %q: %v", *maxBytesf, err)
+		}
+		config.SetMaxBytes(int(mb))
+	}
+	if *whyf {
+		config.SetWhy()
+	}
 	// handle -fpr
 	if *fprflag {
 		log.Printf("FPR server started at %s. Use CTRL-C to quit.\n", config.Fpr())
@@ -395,8 +996,8 @@ func main() {
 		return
 	}
 	// check -multi
-	if *multi > maxMulti || *multi < 1 || (*archive && *multi > 1) {
-		log.Println("[WARN] -multi must be > 0 and =< 1024. If -z, -multi must be 1. Resetting -multi to 1")
+	if *multi > maxMulti || *multi < 1 {
+		log.Println("[WARN] -multi must be > 0 and =< 1024. Resetting -multi to 1")
 		*multi = 1
 	}
 	// start logger
@@ -404,8 +1005,11 @@ func main() {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if walkFilters != nil {
+		walkFilters.log = lg.Skip
+	}
 	if config.Slow() || config.Debug() {
-		if *serve != "" || *fprflag {
+		if *serve != "" || *grpcf != "" || *fprflag {
 			log.Fatalln("[FATAL] debug and slow logging cannot be run in server mode")
 		}
 	}
@@ -421,26 +1025,35 @@ func main() {
 	}
 	ctxts := make(chan *context, lenCtxts)
 	go printer(ctxts, lg)
-	// set default writer
-	var w writer.Writer
+	// set up the writers
 	var d bool
-	switch {
-	case lg.IsOut():
-		w = writer.Null()
-	case *csvo:
-		w = writer.CSV(os.Stdout)
-	case *jsono:
-		w = writer.JSON(os.Stdout)
-	case *droido:
+	if droido.on {
 		if !*replay && (len(s.Fields()) != 1 || len(s.Fields()[0]) < 7) {
 			close(ctxts)
 			log.Fatalln("[FATAL] DROID output is limited to signature files with a single PRONOM identifier")
 		}
 		decompress.SetDroid()
-		w = writer.Droid(os.Stdout)
 		d = true
-	default:
-		w = writer.YAML(os.Stdout)
+	}
+	// with -watch, output files left by an earlier run are renamed rather than overwritten
+	if *watchf != "" {
+		if err := rollAside(); err != nil {
+			close(ctxts)
+			log.Fatalf("[FATAL] -watch: %v\n", err)
+		}
+	}
+	w, outFiles, err := openOutputs(lg.IsOut())
+	if err != nil {
+		close(ctxts)
+		log.Fatalf("[FATAL] %v\n", err)
+	}
+	var rw *roller
+	if *watchf != "" {
+		rw = newRoller(w, outFiles, lg.IsOut(), *watchrollf)
+		w, outFiles = rw, nil
+		if *watchpostf != "" {
+			w = writer.Multi(rw, newPoster(*watchpostf))
+		}
 	}
 	// setup default waitgroup
 	wg := &sync.WaitGroup{}
@@ -449,32 +1062,127 @@ func main() {
 	// handle -serve
 	if *serve != "" {
 		log.Printf("Starting server at %s. Use CTRL-C to quit.\n", *serve)
-		listen(*serve, s, ctxts)
+		if err := listen(*serve, s, ctxts); err != nil {
+			log.Fatalf("[FATAL] server failed, got: %v", err)
+		}
 		return
 	}
+	// handle -grpc
+	if *grpcf != "" {
+		log.Printf("Starting gRPC server at %s. Use CTRL-C to quit.\n", *grpcf)
+		if err := listenGRPC(*grpcf, s); err != nil {
+			log.Fatalf("[FATAL] gRPC server failed, got: %v", err)
+		}
+		return
+	}
+	// handle -watch
+	if *watchf != "" {
+		wt, err := newWatcher(s, *watchf, *watchsettlef, *nr, d, ctxts)
+		if err != nil {
+			close(ctxts)
+			log.Fatalf("[FATAL] -watch: %v\n", err)
+		}
+		w.Head(config.SignatureBase(), time.Now(), s.C, config.Version(), s.Identifiers(), s.Fields(), hashT.String(), extraFields(s))
+		// on SIGHUP, identify with the reloaded signature file, and start new output files with its header
+		reloadOnHangup(func(nsf *siegfried.Siegfried) {
+			wt.swap(nsf)
+			rw.renew(config.SignatureBase(), nsf.C, config.Version(), nsf.Identifiers(), nsf.Fields(), hashT.String(), extraFields(nsf))
+		})
+		log.Printf("Watching %s. Use CTRL-C to quit.\n", *watchf)
+		wt.run(interrupted())
+		wg.Wait()
+		close(ctxts)
+		w.Tail()
+		lg.Close()
+		return
+	}
+	args, literal := scanArgs(s, flag.Args())
 	// handle no file/directory argument
-	if flag.NArg() < 1 {
+	if len(args) < 1 {
 		close(ctxts)
-		log.Fatalln("[FATAL] expecting one or more file or directory arguments (or '-' to scan stdin)")
+		log.Fatalln("[FATAL] expecting one or more file or directory arguments (or URLs, s3:// prefixes, or '-' to scan stdin)")
 	}
 	if !*replay {
-		w.Head(config.SignatureBase(), time.Now(), s.C, config.Version(), s.Identifiers(), s.Fields(), hashT.String())
+		w.Head(config.SignatureBase(), time.Now(), s.C, config.Version(), s.Identifiers(), s.Fields(), hashT.String(), extraFields(s))
+		if e, ok := w.(interface{ Err() error }); ok && e.Err() != nil {
+			close(ctxts)
+			log.Fatalf("[FATAL] %v\n", e.Err())
+		}
+	}
+	if err := loadJournal(*journalf, *budgetf); err != nil {
+		close(ctxts)
+		log.Fatalf("[FATAL] error reading checkpoint %s: %v\n", *journalf, err)
 	}
-	for _, v := range flag.Args() {
+	if *statef != "" && !*replay {
+		if incremental, err = loadState(*statef, config.SignatureBase()+" "+s.C.Format(time.RFC3339)); err != nil {
+			close(ctxts)
+			log.Fatalf("[FATAL] error reading state file %s: %v\n", *statef, err)
+		}
+	}
+	if *cachef != "" && !*replay {
+		key := config.SignatureBase() + " " + s.C.Format(time.RFC3339) + " " + hashT.String() + " " + strings.Join(extraFields(s), ",")
+		if rcache, err = loadCache(*cachef, key); err != nil {
+			close(ctxts)
+			log.Fatalf("[FATAL] error reading cache file %s: %v\n", *cachef, err)
+		}
+	}
+	if lg.Metering() {
+		go func() {
+			if countFiles(args, literal, d, lg.AddTotal) {
+				lg.Counted()
+			}
+		}()
+	}
+	var stopped bool
+scan:
+	for _, v := range args {
 		if *list {
 			f, err := openFile(v)
 			if err != nil {
 				break
 			}
-			scanner := bufio.NewScanner(f)
+			scanner := listScanner(f)
+			if *dataf {
+				scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024) // data URIs can be long
+			}
 			for scanner.Scan() {
-				if *replay {
+				if !jnl.next() {
+					continue
+				}
+				if jnl.stopped() {
+					stopped = true
+					f.Close()
+					break scan
+				}
+				if *dataf {
+					identifyData(scanner.Text(), ctxts)
+				} else if *replay {
 					err = replayFile(scanner.Text(), ctxts, w)
 					if err != nil {
 						break
 					}
+				} else if isURL(scanner.Text()) {
+					identifyURL(scanner.Text(), ctxts)
+				} else if isS3(scanner.Text()) {
+					err = identifyS3(scanner.Text(), ctxts)
+					if err == errBudget {
+						stopped = true
+						f.Close()
+						break scan
+					}
+					if err != nil {
+						printFile(ctxts,
+							getCtx(scanner.Text(), "", time.Time{}, 0),
+							fmt.Errorf("failed to list %s: %v", scanner.Text(), err))
+						err = nil
+					}
 				} else {
 					err = identify(ctxts, scanner.Text(), "", *coe, *nr, d, getCtx)
+					if err == errBudget {
+						stopped = true
+						f.Close()
+						break scan
+					}
 					if err != nil {
 						printFile(ctxts,
 							getCtx(scanner.Text(), "", time.Time{}, 0),
@@ -484,20 +1192,64 @@ func main() {
 				}
 			}
 			f.Close()
-		} else if *replay {
-			err = replayFile(v, ctxts, w)
-		} else if v == "-" {
-			ctx := getCtx(*name, "", time.Time{}, 0)
-			ctx.wg.Add(1)
-			ctxts <- ctx
-			identifyRdr(os.Stdin, ctx, ctxts, getCtx)
-		} else {
-			globs, err := filepath.Glob(v)
+			continue
+		}
+		if *inventoryf {
+			err = identifyInventory(v, ctxts)
+			if err == errBudget {
+				stopped = true
+				break
+			}
+			if err != nil {
+				printFile(ctxts,
+					getCtx(v, "", time.Time{}, 0),
+					fmt.Errorf("failed to read inventory %s: %v", v, err))
+				err = nil
+			}
+			continue
+		}
+		if isS3(v) && !*dataf && !*replay {
+			err = identifyS3(v, ctxts)
+			if err == errBudget {
+				stopped = true
+				break
+			}
+			if err != nil {
+				printFile(ctxts,
+					getCtx(v, "", time.Time{}, 0),
+					fmt.Errorf("failed to list %s: %v", v, err))
+				err = nil
+			}
+			continue
+		}
+		if isURL(v) && !*dataf && !*replay {
+			if !jnl.next() {
+				continue
+			}
+			if jnl.stopped() {
+				stopped = true
+				break
+			}
+			identifyURL(v, ctxts)
+			continue
+		}
+		if v != "-" && !*dataf && !*replay {
+			globs, err := []string{v}, error(nil)
+			if !literal {
+				globs, err = filepath.Glob(v)
+			}
 			if err != nil {
 				log.Fatalf("[FATAL] bad glob pattern: %s\n", err)
 			}
 			for _, glob := range globs {
+				if !jnl.next() {
+					continue
+				}
 				err = identify(ctxts, glob, "", *coe, *nr, d, getCtx)
+				if err == errBudget || (err == nil && jnl.stopped()) {
+					stopped = true
+					break scan
+				}
 				if err != nil {
 					printFile(ctxts,
 						getCtx(glob, "", time.Time{}, 0),
@@ -505,15 +1257,101 @@ func main() {
 					err = nil
 				}
 			}
+			continue
+		}
+		if !jnl.next() {
+			continue
+		}
+		if jnl.stopped() {
+			stopped = true
+			break
+		}
+		if *dataf {
+			identifyData(v, ctxts)
+		} else if *replay {
+			err = replayFile(v, ctxts, w)
+		} else {
+			identifyStdin(ctxts)
 		}
 	}
 	wg.Wait()
 	close(ctxts)
 	w.Tail()
+	if e, ok := w.(interface{ Err() error }); ok && e.Err() != nil {
+		log.Fatalf("[FATAL] error writing results: %v\n", e.Err())
+	}
+	for _, f := range outFiles {
+		if e := f.Close(); e != nil {
+			log.Fatalf("[FATAL] error writing %s: %v\n", f.Name(), e)
+		}
+	}
+	if incremental != nil {
+		if e := incremental.save(*statef); e != nil {
+			log.Fatalf("[FATAL] error writing state file %s: %v\n", *statef, e)
+		}
+	}
+	if rcache != nil {
+		if e := rcache.save(*cachef); e != nil {
+			log.Fatalf("[FATAL] error writing cache file %s: %v\n", *cachef, e)
+		}
+	}
+	if duplicates != nil {
+		if e := writeDups(*dupsf); e != nil {
+			log.Fatalf("[FATAL] error writing duplicates report %s: %v\n", *dupsf, e)
+		}
+		log.Printf("dups: %s\n", duplicates)
+	}
+	if notifying != nil {
+		status := "completed"
+		if err != nil {
+			status = "failed"
+		} else if stopped {
+			status = "stopped"
+		}
+		notifying.send(notifying.summary("scan", status, err, args))
+	}
 	// log time elapsed and chart
 	lg.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if stopped {
+		if *journalf != "" {
+			if err := jnl.save(*journalf); err != nil {
+				log.Fatalf("[FATAL] error writing checkpoint %s: %v\n", *journalf, err)
+			}
+			log.Printf("[WARN] scan stopped after %s; checkpoint written to %s\n", *budgetf, *journalf)
+		} else {
+			log.Printf("[WARN] scan stopped after %s\n", *budgetf)
+		}
+		os.Exit(exitBudget)
+	}
+	if sampling != nil {
+		log.Printf("sampled %d of %d files walked (%s)\n", sampling.sampled, sampling.total, sampling)
+	}
+	jnl.done(*journalf)
+	if failures != nil {
+		log.Printf("failon: %s\n", failures)
+		if failures.failed() {
+			os.Exit(exitFailOn)
+		}
+	}
+	if bags != nil && !bags.finish() {
+		os.Exit(exitVerify)
+	}
+	if verifying != nil {
+		verifying.finish()
+		log.Printf("verify: %s\n", verifying)
+		if verifying.failed() {
+			os.Exit(exitVerify)
+		}
+	}
+	if corpus != nil {
+		corpus.finish()
+		log.Printf("test: %s\n", corpus)
+		if corpus.regressed() {
+			os.Exit(exitTest)
+		}
+	}
 	os.Exit(0)
 }
@@ -1,19 +1,27 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/internal/checksum"
+	"github.com/richardlehane/siegfried/internal/logger"
 	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/pronom"
+	"github.com/richardlehane/siegfried/pkg/reader"
+	"github.com/richardlehane/siegfried/pkg/writer"
 )
 
 var (
@@ -155,6 +163,28 @@ func TestSuite(t *testing.T) {
 	}
 }
 
+func TestListScanner(t *testing.T) {
+	for _, v := range []struct {
+		nul    bool
+		in     string
+		expect []string
+	}{
+		{false, "a.txt\r\n\nb c.pdf\n", []string{"a.txt", "b c.pdf"}},
+		{true, "a.txt\x00new\nline.pdf\x00\x00last", []string{"a.txt", "new\nline.pdf", "last"}},
+	} {
+		*nul = v.nul
+		scanner := listScanner(strings.NewReader(v.in))
+		var got []string
+		for scanner.Scan() {
+			got = append(got, scanner.Text())
+		}
+		if strings.Join(got, "|") != strings.Join(v.expect, "|") {
+			t.Errorf("expecting %q, got %q", v.expect, got)
+		}
+	}
+	*nul = false
+}
+
 func TestTip(t *testing.T) {
 	expect := "fmt/669"
 	err := setup()
@@ -219,6 +249,271 @@ func TestDROID(t *testing.T) {
 	setup(config.Clear())
 }
 
+// sf - identifies a stream from stdin; -name gives it a filename, which is checked against the format's extensions
+func TestStdin(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	pth := filepath.Join(*testdata, "benchmark", "Benchmark.pdf")
+	info, err := os.Stat(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := func(n string) string {
+		f, err := os.Open(pth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		stdin := os.Stdin
+		os.Stdin, *name = f, n
+		defer func() { os.Stdin, *name = stdin, "" }()
+		lg, _ := logger.New("")
+		res := make(resultWriter, 1)
+		ctxts := make(chan *context, 1)
+		printed := make(chan struct{})
+		go func() {
+			printer(ctxts, lg)
+			close(printed)
+		}()
+		wg := &sync.WaitGroup{}
+		setCtxPool(s, wg, res, false, false, -1)
+		identifyStdin(ctxts)
+		wg.Wait()
+		close(ctxts)
+		<-printed
+		return <-res
+	}
+	size := fmt.Sprintf("%d", info.Size())
+	if got := scan("report.pdf"); got != "report.pdf|"+size+"|fmt/18|" {
+		t.Errorf("expecting fmt/18 without a warning, got %s", got)
+	}
+	if got := scan("report.doc"); !strings.HasPrefix(got, "report.doc|"+size+"|fmt/18|") || !strings.Contains(got, "extension mismatch") {
+		t.Errorf("expecting fmt/18 with an extension mismatch warning, got %s", got)
+	}
+}
+
+// TestMultiZ tests that, with -multi and -z, archive members are reported after their archive as with a single process
+func TestMultiZ(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	config.SetArchiveFilterPermissive(config.ListAllArcTypes())
+	defer config.SetArchiveFilterPermissive("")
+	dir := t.TempDir()
+	bench := filepath.Join(*testdata, "benchmark")
+	entries, _ := os.ReadDir(bench)
+	for i := 0; i < 4; i++ {
+		f, _ := os.Create(filepath.Join(dir, fmt.Sprintf("%d.zip", i)))
+		zw := zip.NewWriter(f)
+		for _, e := range entries {
+			byt, _ := os.ReadFile(filepath.Join(bench, e.Name()))
+			w, _ := zw.Create(e.Name())
+			w.Write(byt)
+		}
+		zw.Close()
+		f.Close()
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.txt", i)), []byte("hello"), 0644)
+	}
+	scan := func(n int) []string {
+		*multi = n
+		defer func() { *multi = 1 }()
+		lg, _ := logger.New("")
+		names, wg := make(nameWriter), &sync.WaitGroup{}
+		ctxts := make(chan *context, n)
+		printed := make(chan struct{})
+		go func() {
+			printer(ctxts, lg)
+			close(printed)
+		}()
+		setCtxPool(s, wg, names, false, true, -1)
+		collected := make(chan []string)
+		go func() {
+			var got []string
+			for n := range names {
+				got = append(got, n)
+			}
+			collected <- got
+		}()
+		if err := identify(ctxts, dir, "", false, false, false, getCtx); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		close(ctxts)
+		<-printed
+		close(names)
+		return <-collected
+	}
+	expect, got := scan(1), scan(4)
+	if len(expect) != 4*(len(entries)+2) || strings.Join(expect, "|") != strings.Join(got, "|") {
+		t.Errorf("expecting -multi to report the same files, in the same order, as a single process; got:\n%v\nexpecting:\n%v", got, expect)
+	}
+}
+
+// TestRecords tests that, with -z and -records, each response record in a WARC is identified separately and reported with its target URI and date
+func TestRecords(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	config.SetArchiveFilterPermissive(config.ListAllArcTypes())
+	defer config.SetArchiveFilterPermissive("")
+	*recordsf = true
+	defer func() { *recordsf = false }()
+	warc := &bytes.Buffer{}
+	for i, rec := range []struct{ uri, date, mime, file string }{
+		{"http://example.com/report.pdf", "2020-01-02T03:04:05Z", "application/pdf", "Benchmark.pdf"},
+		{"http://example.com/logo.png", "2021-06-07T08:09:10Z", "image/png", "Benchmark.png"},
+	} {
+		byt, err := os.ReadFile(filepath.Join(*testdata, "benchmark", rec.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s", rec.mime, len(byt), byt)
+		fmt.Fprintf(warc, "WARC/1.0\r\nWARC-Type: response\r\nWARC-Target-URI: %s\r\nWARC-Date: %s\r\n"+
+			"WARC-Record-ID: <urn:uuid:00000000-0000-0000-0000-00000000000%d>\r\nContent-Type: application/http; msgtype=response\r\n"+
+			"Content-Length: %d\r\n\r\n%s\r\n\r\n", rec.uri, rec.date, i, len(block), block)
+	}
+	pth := filepath.Join(t.TempDir(), "test.warc")
+	if err := os.WriteFile(pth, warc.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lg, _ := logger.New("")
+	res, wg := make(resultWriter, 3), &sync.WaitGroup{}
+	ctxts := make(chan *context, 1)
+	printed := make(chan struct{})
+	go func() {
+		printer(ctxts, lg)
+		close(printed)
+	}()
+	setCtxPool(s, wg, res, false, true, -1)
+	if err := identify(ctxts, pth, "", false, false, false, getCtx); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(ctxts)
+	<-printed
+	close(res)
+	var got []string
+	for r := range res {
+		got = append(got, r)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expecting a WARC and two records, got %v", got)
+	}
+	// the WARC itself has no record fields
+	if !strings.HasSuffix(got[0], "||") {
+		t.Errorf("expecting empty record fields for the WARC, got %s", got[0])
+	}
+	for i, expect := range []struct{ id, fields string }{
+		{"fmt/18", "http://example.com/report.pdf|2020-01-02T03:04:05Z"},
+		{"fmt/12", "http://example.com/logo.png|2021-06-07T08:09:10Z"},
+	} {
+		if f := strings.Split(got[i+1], "|"); len(f) < 4 || f[2] != expect.id || !strings.HasSuffix(got[i+1], "|"+expect.fields) {
+			t.Errorf("expecting %s with %s, got %s", expect.id, expect.fields, got[i+1])
+		}
+	}
+}
+
+// TestHashes tests that, with -hash md5,sha256,blake2b, the first hash is the file's checksum and the others follow as extra fields;
+// and that the checksum is kept when the results are replayed
+func TestHashes(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	hashes := checksum.GetHashes("md5,sha256,blake2b")
+	extraHashes = hashes[1:]
+	defer func() { extraHashes = nil }()
+	pth := filepath.Join(*testdata, "benchmark", "Benchmark.pdf")
+	byt, err := os.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums := make([]string, len(hashes))
+	for i, typ := range hashes {
+		h := checksum.MakeHash(typ)
+		h.Write(byt)
+		sums[i] = hex.EncodeToString(h.Sum(nil))
+	}
+	scan := func(w writer.Writer, fn func(ctxts chan *context) error) string {
+		lg, _ := logger.New("")
+		res, wg := make(resultWriter, 1), &sync.WaitGroup{}
+		ctxts := make(chan *context, 1)
+		printed := make(chan struct{})
+		go func() {
+			printer(ctxts, lg)
+			close(printed)
+		}()
+		setCtxPool(s, wg, writer.Multi(res, w), false, false, hashes[0])
+		if err := fn(ctxts); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		close(ctxts)
+		<-printed
+		return <-res
+	}
+	results := filepath.Join(t.TempDir(), "results.yaml")
+	f, err := os.Create(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := writer.YAML(f)
+	y.Head(config.SignatureBase(), time.Now(), s.C, config.Version(), s.Identifiers(), s.Fields(), hashes[0].String(), extraFields(s))
+	got := scan(y, func(ctxts chan *context) error {
+		return identify(ctxts, pth, "", false, false, false, getCtx)
+	})
+	y.Tail()
+	f.Close()
+	prefix := pth + "|" + strconv.Itoa(len(byt)) + "|" + sums[0] + "|fmt/18|"
+	if expect := prefix + "|" + strings.Join(sums[1:], "|"); got != expect {
+		t.Errorf("expecting:\n%s\ngot:\n%s", expect, got)
+	}
+	got = scan(writer.Null(), func(ctxts chan *context) error {
+		return replayFile(results, ctxts, writer.Null())
+	})
+	if !strings.HasPrefix(got, prefix) {
+		t.Errorf("expecting a replayed result starting:\n%s\ngot:\n%s", prefix, got)
+	}
+}
+
+// TestReplayQuery tests that results files can be replayed and queried, through the setup of the scan arguments, without loading a signature file
+func TestReplayQuery(t *testing.T) {
+	sf, q := s, query
+	s, *replay = nil, true
+	defer func() { s, query, *replay = sf, q, false }()
+	var err error
+	if query, err = reader.NewQuery("puid = fmt/18"); err != nil {
+		t.Fatal(err)
+	}
+	args, literal := scanArgs(s, []string{filepath.Join("..", "..", "pkg", "reader", "examples", "ipresShowcase", "sf.yaml")})
+	if len(args) != 1 || literal {
+		t.Fatalf("expecting the results file, got %v", args)
+	}
+	lg, _ := logger.New("")
+	names, wg := make(nameWriter, 20), &sync.WaitGroup{}
+	ctxts := make(chan *context, 1)
+	printed := make(chan struct{})
+	go func() {
+		printer(ctxts, lg)
+		close(printed)
+	}()
+	setCtxPool(s, wg, names, false, false, -1)
+	if err := replayFile(args[0], ctxts, names); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(ctxts)
+	<-printed
+	close(names)
+	var got int
+	for range names {
+		got++
+	}
+	if got != 10 {
+		t.Errorf("expecting 10 PDFs, got %d", got)
+	}
+}
+
 func Test363(t *testing.T) {
 	repetitions := 10000
 	iter := 0
@@ -361,3 +656,13 @@ func BenchmarkMulti(bench *testing.B) {
 		multiIdentifyT(s, dir)
 	}
 }
+
+// BenchmarkSkeleton scans the skeleton suite: many small files, so allocations per file dominate
+func BenchmarkSkeleton(bench *testing.B) {
+	setup()
+	dir := filepath.Join(*testdata, "skeleton-suite")
+	bench.ReportAllocs()
+	for i := 0; i < bench.N; i++ {
+		multiIdentifyT(s, dir)
+	}
+}
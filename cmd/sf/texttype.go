@@ -0,0 +1,63 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/internal/textmatcher"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var texttypef = flag.Bool("texttype", false, "report whether each file is text or binary, whatever format matched, in a texttype field; and, for text, its charset and line endings (LF, CRLF, CR, mixed or none) in charset and newline fields")
+
+// textTypeExtras report the text matcher's classification of each file as "texttype", "charset" and "newline" fields.
+// They are Full extras because the text sample (see -text sample=) may be bigger than the window given to other extras.
+func textTypeExtras() []siegfried.Extra {
+	classify := func(c siegfried.Content) textmatcher.Class {
+		if b, ok := c.(*siegreader.Buffer); ok {
+			return textmatcher.Classify(b)
+		}
+		return textmatcher.Class{}
+	}
+	return []siegfried.Extra{
+		{
+			Name: "texttype",
+			Full: true,
+			Fn: func(c siegfried.Content, ids []core.Identification) string {
+				if classify(c).Text {
+					return "text"
+				}
+				return "binary"
+			},
+		},
+		{
+			Name: "charset",
+			Full: true,
+			Fn: func(c siegfried.Content, ids []core.Identification) string {
+				return classify(c).Charset
+			},
+		},
+		{
+			Name: "newline",
+			Full: true,
+			Fn: func(c siegfried.Content, ids []core.Identification) string {
+				return classify(c).Newline
+			},
+		},
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/richardlehane/siegfried/internal/checksum"
+	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/reader"
+)
+
+var verifyf = flag.String("verify", "", "check files against a previous sf results file, or a checksum manifest (e.g. md5sum output or a BagIt manifest), reporting changed checksums and identifications in a fixity field and exiting with code 5 if any differ e.g. sf -verify results.csv (the listed files are scanned if no files or directories are given)")
+
+// exitVerify is the exit code when files fail -verify
+const exitVerify = 5
+
+// verifying holds the expected results for -verify; it is nil if the flag isn't given
+var verifying *verifier
+
+// verifier compares results with those recorded in a results file or manifest. Paths are compared as absolute paths.
+// Checksum manifests have lines of hex encoded checksums and paths (relative to the manifest), as written by md5sum or sha256sum.
+// Their hash algorithm is taken from the manifest's name (e.g. manifest-sha256.txt) or, failing that, the length of the checksums.
+type verifier struct {
+	hash   checksum.HashTyp
+	expect map[string]expected
+	order  []string // paths in the order given
+	field  int      // index of the fixity field in the extra fields
+	// counts
+	ok, changed, missing, unlisted int
+}
+
+type expected struct {
+	hash string // hex encoded, empty if the results file has no hashes
+	ids  string // empty for checksum manifests
+	seen bool
+}
+
+var manifestLine = regexp.MustCompile(`^([0-9a-fA-F]+) [ *](.+)$`)
+
+func newVerifier(path string) (*verifier, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	v := &verifier{hash: -1, expect: make(map[string]expected)}
+	buf := bufio.NewReader(f)
+	first, _ := buf.Peek(4096)
+	if i := strings.IndexByte(string(first), '\n'); i > -1 {
+		first = first[:i]
+	}
+	if manifestLine.Match([]byte(strings.TrimSuffix(string(first), "\r"))) {
+		return v, v.readManifest(buf, path)
+	}
+	return v, v.readResults(buf, path)
+}
+
+func (v *verifier) add(path string, e expected) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if _, ok := v.expect[path]; !ok {
+		v.order = append(v.order, path)
+	}
+	v.expect[path] = e
+}
+
+func (v *verifier) readResults(r io.Reader, path string) error {
+	rdr, err := reader.New(r, path)
+	if err != nil {
+		return err
+	}
+	v.hash = checksum.GetHash(rdr.Head().HashHeader)
+	var rf reader.File
+	for rf, err = rdr.Next(); err == nil; rf, err = rdr.Next() {
+		if rf.Size < 0 || rf.Err != nil { // directories, and files that couldn't be identified, can't be verified
+			continue
+		}
+		v.add(rf.Path, expected{hash: strings.ToLower(string(rf.Hash)), ids: idList(rf.IDs)})
+	}
+	if err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (v *verifier) readManifest(r io.Reader, path string) error {
+	name := strings.ToLower(filepath.Base(path))
+	for _, h := range strings.Split(checksum.HashChoices, ",") {
+		h = strings.Trim(h, " '")
+		if strings.Contains(name, h) {
+			v.hash = checksum.GetHash(h)
+		}
+	}
+	dir := filepath.Dir(path)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		m := manifestLine.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("bad manifest line %q", line)
+		}
+		if v.hash < 0 {
+			switch len(m[1]) {
+			case 8:
+				v.hash = checksum.GetHash("crc")
+			case 32:
+				v.hash = checksum.GetHash("md5")
+			case 40:
+				v.hash = checksum.GetHash("sha1")
+			case 64:
+				v.hash = checksum.GetHash("sha256")
+			case 128:
+				v.hash = checksum.GetHash("sha512")
+			default:
+				return fmt.Errorf("can't tell the hash algorithm of the manifest from a checksum of %d characters", len(m[1]))
+			}
+		}
+		p := filepath.FromSlash(m[2])
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		v.add(p, expected{hash: strings.ToLower(m[1])})
+	}
+	return scanner.Err()
+}
+
+// paths returns the paths in a manifest or results file, to scan if no files or directories are given
+func (v *verifier) paths() []string {
+	ret := make([]string, len(v.order))
+	copy(ret, v.order)
+	sort.Strings(ret)
+	return ret
+}
+
+// check is called by the printer for each result and returns a value for the fixity field:
+// ok, a description of a changed checksum or identification, missing or error (the file couldn't be read),
+// or unlisted (the file isn't in the manifest). Directories have no fixity value.
+func (v *verifier) check(path string, sz int64, cs []byte, err error, ids []core.Identification) string {
+	if sz < 0 {
+		return ""
+	}
+	key := path
+	if abs, aerr := filepath.Abs(path); aerr == nil {
+		key = abs
+	}
+	e, ok := v.expect[key]
+	if !ok {
+		v.unlisted++
+		return "unlisted"
+	}
+	e.seen = true
+	v.expect[key] = e
+	if err != nil {
+		v.missing++
+		if _, serr := os.Lstat(path); os.IsNotExist(serr) {
+			return "missing"
+		}
+		return "error"
+	}
+	var diffs []string
+	if e.hash != "" && hex.EncodeToString(cs) != e.hash {
+		diffs = append(diffs, "checksum "+e.hash+" -> "+hex.EncodeToString(cs))
+	}
+	if e.ids != "" {
+		if now := idList(ids); now != e.ids {
+			diffs = append(diffs, "id "+e.ids+" -> "+now)
+		}
+	}
+	if len(diffs) > 0 {
+		v.changed++
+		return strings.Join(diffs, "; ")
+	}
+	v.ok++
+	return "ok"
+}
+
+// finish counts listed files that weren't scanned, and no longer exist, as missing (e.g. files deleted from a walked directory)
+func (v *verifier) finish() {
+	for _, p := range v.order {
+		if v.expect[p].seen {
+			continue
+		}
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
+			v.missing++
+			log.Printf("[WARN] -verify: %s is missing\n", p)
+		}
+	}
+}
+
+// failed reports whether any listed files have changed, or are missing or unreadable
+func (v *verifier) failed() bool {
+	return v.changed > 0 || v.missing > 0
+}
+
+func (v *verifier) String() string {
+	return fmt.Sprintf("%d ok, %d changed, %d missing or unreadable, %d unlisted", v.ok, v.changed, v.missing, v.unlisted)
+}
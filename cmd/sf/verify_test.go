@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/checksum"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestVerifyManifest(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "b.txt")
+	path := filepath.Join(dir, "checksums")
+	os.WriteFile(path, []byte("5d41402abc4b2a76b9719d911017c592  a.txt\r\n\n"+
+		"7d793037a0760186574b0282f2f435e7 *sub/b.txt\n"), 0644)
+	v, err := newVerifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.hash != checksum.GetHash("md5") {
+		t.Errorf("expecting md5 from the checksum length, got %s", v.hash)
+	}
+	if p := v.paths(); len(p) != 2 || p[0] != a || p[1] != b {
+		t.Fatalf("expecting paths relative to the manifest, got %v", p)
+	}
+	cs, _ := hex.DecodeString("5d41402abc4b2a76b9719d911017c592")
+	if r := v.check(a, 5, cs, nil, nil); r != "ok" {
+		t.Errorf("expecting ok, got %s", r)
+	}
+	if r := v.check(b, 5, cs, nil, nil); r != "checksum 7d793037a0760186574b0282f2f435e7 -> 5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("expecting a checksum mismatch, got %s", r)
+	}
+	if r := v.check(filepath.Join(dir, "c.txt"), 5, cs, nil, nil); r != "unlisted" {
+		t.Errorf("expecting unlisted, got %s", r)
+	}
+	if r := v.check(dir, -1, nil, nil, nil); r != "" {
+		t.Errorf("expecting no fixity for a directory, got %s", r)
+	}
+	if !v.failed() || v.String() != "1 ok, 1 changed, 0 missing or unreadable, 1 unlisted" {
+		t.Errorf("unexpected summary %s", v)
+	}
+}
+
+func TestVerifyManifestName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest-sha512.txt")
+	os.WriteFile(path, []byte("5d41402abc4b2a76b9719d911017c592 a.txt\n"), 0644)
+	if _, err := newVerifier(path); err == nil {
+		t.Error("expecting an error for a checksum without a space or star before its path")
+	}
+	os.WriteFile(path, []byte("5d41402abc4b2a76b9719d911017c592  a.txt\n"), 0644)
+	v, err := newVerifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.hash != checksum.GetHash("sha512") {
+		t.Errorf("expecting sha512 from the manifest name, got %s", v.hash)
+	}
+	v.check(filepath.Join(dir, "a.txt"), 5, nil, errors.New("no such file"), nil)
+	v.finish()
+	if v.missing != 1 {
+		t.Errorf("expecting an unreadable file to be counted once, got %d", v.missing)
+	}
+}
+
+func TestVerifyResults(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	path := filepath.Join(dir, "results.csv")
+	os.WriteFile(path, []byte("filename,filesize,modified,errors,md5,namespace,id,format,version,mime,class,basis,warning\n"+
+		a+",5,2023-05-01T00:00:00Z,,5d41402abc4b2a76b9719d911017c592,pronom,x-fmt/111,Plain Text File,,text/plain,,,\n"+
+		filepath.Join(dir, "gone.txt")+",5,2023-05-01T00:00:00Z,,5d41402abc4b2a76b9719d911017c592,pronom,x-fmt/111,Plain Text File,,text/plain,,,\n"), 0644)
+	v, err := newVerifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.hash != checksum.GetHash("md5") {
+		t.Errorf("expecting md5 from the results header, got %s", v.hash)
+	}
+	cs, _ := hex.DecodeString("5d41402abc4b2a76b9719d911017c592")
+	if r := v.check(a, 5, cs, nil, []core.Identification{cmpID{"fmt/101", ""}}); r != "id x-fmt/111 -> fmt/101" {
+		t.Errorf("expecting a changed identification, got %s", r)
+	}
+	v.finish()
+	if v.missing != 1 {
+		t.Errorf("expecting a listed file that wasn't scanned, and no longer exists, to be missing; got %d", v.missing)
+	}
+}
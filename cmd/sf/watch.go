@@ -0,0 +1,423 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/writer"
+)
+
+var (
+	watchf       = flag.String("watch", "", "watch a directory (e.g. a drop folder) and identify files as they arrive, until interrupted e.g. -watch /data/incoming")
+	watchsettlef = flag.Duration("watchsettle", 2*time.Second, "with -watch, identify a file once it hasn't changed for this long (so that it has finished arriving)")
+	watchrollf   = flag.Duration("watchroll", 24*time.Hour, "with -watch, start new output files at this interval, renaming the old ones with the time they were started e.g. results-20230501T000000Z.csv (0 to never roll)")
+	watchpostf   = flag.String("watchpost", "", "with -watch, POST each file's result, as JSON, to a URL e.g. -watchpost http://localhost:8080/results")
+)
+
+// watcher identifies the files that arrive in a directory tree. Files are identified once no events have been seen for them
+// for the settle period. New directories are watched too (unless -nr), and the files already in them are identified.
+// Files are identified with the siegfried loaded when they settle, so it can be swapped (on SIGHUP) while watching.
+type watcher struct {
+	mu     sync.RWMutex
+	s      *siegfried.Siegfried
+	root   string
+	settle time.Duration
+	nr     bool
+	d      bool // droid
+	ctxts  chan *context
+	fsw    *fsnotify.Watcher
+	// pending files and when they last changed
+	pending map[string]time.Time
+	// drop waits for the files identified since the watcher was last idle (with none pending), and drops for
+	// the drops still being identified
+	drop    *sync.WaitGroup
+	dropped bool
+	drops   sync.WaitGroup
+}
+
+func newWatcher(s *siegfried.Siegfried, root string, settle time.Duration, norecurse, droid bool, ctxts chan *context) (*watcher, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s isn't a directory", root)
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{
+		s:       s,
+		root:    root,
+		settle:  settle,
+		nr:      norecurse,
+		d:       droid,
+		ctxts:   ctxts,
+		fsw:     fsw,
+		pending: make(map[string]time.Time),
+		drop:    &sync.WaitGroup{},
+	}
+	if err := w.add(root, false); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// add watches a directory and its subdirectories. For directories that arrive after the watch starts (from is true),
+// the files already in them are pending too.
+func (w *watcher) add(dir string, from bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // e.g. removed since the event
+		}
+		if info, err := d.Info(); err == nil {
+			if skip, err := filtered(w.root, path, info); skip {
+				return err
+			}
+		}
+		if !d.IsDir() {
+			if from {
+				w.pending[path] = time.Now()
+			}
+			return nil
+		}
+		if w.nr && path != w.root {
+			return filepath.SkipDir
+		}
+		return w.fsw.Add(path)
+	})
+}
+
+// event updates the pending files
+func (w *watcher) event(ev fsnotify.Event) {
+	switch {
+	case ev.Has(fsnotify.Create):
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			if err := w.add(ev.Name, true); err != nil {
+				log.Printf("[WARN] -watch: can't watch %s: %v\n", ev.Name, err)
+			}
+			return
+		}
+		w.pending[ev.Name] = time.Now()
+	case ev.Has(fsnotify.Write):
+		w.pending[ev.Name] = time.Now()
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		delete(w.pending, ev.Name) // a file renamed within the tree is created again under its new name
+	}
+}
+
+func (w *watcher) swap(s *siegfried.Siegfried) {
+	w.mu.Lock()
+	w.s = s
+	w.mu.Unlock()
+}
+
+// settled identifies the pending files that haven't changed for the settle period
+func (w *watcher) settled(now time.Time) {
+	w.mu.RLock()
+	sf := w.s
+	w.mu.RUnlock()
+	drop := w.drop
+	gf := func(path, mime string, mod time.Time, sz int64) *context {
+		c := getCtx(path, mime, mod, sz)
+		c.s, c.wg = sf, drop
+		return c
+	}
+	for path, t := range w.pending {
+		if now.Sub(t) < w.settle {
+			continue
+		}
+		delete(w.pending, path)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue // gone (or a directory, which was added when it was created)
+		}
+		if skip, _ := filtered(w.root, path, info); skip {
+			continue
+		}
+		w.dropped = true
+		// zero user read permissions mask, octal 400 (decimal 256)
+		if !info.Mode().IsRegular() || info.Mode()&256 == 0 {
+			printFile(w.ctxts, gf(path, "", info.ModTime(), info.Size()), modeError(info.Mode()))
+			continue
+		}
+		identifyFile(gf(path, "", info.ModTime(), info.Size()), w.ctxts, gf)
+	}
+}
+
+// idle starts a new drop, once the files in the current one are identified (and, with -notify, summarised).
+func (w *watcher) idle() {
+	drop := w.drop
+	w.drop, w.dropped = &sync.WaitGroup{}, false
+	w.drops.Add(1)
+	go func() {
+		drop.Wait()
+		if notifying != nil {
+			notifying.send(notifying.summary("watch", "completed", nil, []string{w.root}))
+		}
+		w.drops.Done()
+	}()
+}
+
+// run handles events until stop is closed, then waits for the files it has sent to be identified.
+func (w *watcher) run(stop <-chan struct{}) error {
+	defer w.fsw.Close()
+	tick := w.settle / 4
+	if tick < 50*time.Millisecond {
+		tick = 50 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			w.event(ev)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("[WARN] -watch: %v\n", err) // e.g. events were lost as the queue overflowed
+		case now := <-ticker.C:
+			w.settled(now)
+			if w.dropped && len(w.pending) == 0 {
+				w.idle()
+			}
+		case <-stop:
+			if w.dropped {
+				w.idle()
+			}
+			w.drops.Wait()
+			return nil
+		}
+	}
+}
+
+// interrupted returns a channel that is closed when the process is interrupted (or terminated), to stop watching
+func interrupted() <-chan struct{} {
+	stop, sig := make(chan struct{}), make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		signal.Stop(sig) // a second interrupt ends the process
+		close(stop)
+	}()
+	return stop
+}
+
+// outputPaths lists the files results are written to
+func outputPaths() []string {
+	var paths []string
+	for _, o := range outputs() {
+		if o.flag.on && o.flag.path != "" {
+			paths = append(paths, o.flag.path)
+		}
+	}
+	if yamlo.path != "" {
+		paths = append(paths, yamlo.path)
+	}
+	if *sqlitef != "" {
+		paths = append(paths, *sqlitef)
+	}
+	return paths
+}
+
+// stamped names a rolled output file for the time it was started e.g. results-20230501T000000Z.csv
+// (or results-20230501T000000Z-1.csv if that is taken)
+func stamped(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext) + "-" + t.UTC().Format("20060102T150405Z")
+	name := base + ext
+	for i := 1; ; i++ { // don't overwrite a file rolled in the same second
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// rollAside renames any output files left by an earlier run, stamped with their modification time, so they aren't overwritten.
+func rollAside() error {
+	for _, p := range outputPaths() {
+		info, err := os.Stat(p)
+		if err != nil || info.Size() == 0 {
+			continue
+		}
+		if err := os.Rename(p, stamped(p, info.ModTime())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roller is a writer that rolls its output files: at each interval the results are finished (with Tail), the files renamed
+// with the time they were started and new files opened (with the same Head). Results are only written by the printer,
+// so files are rolled as results come in rather than on a timer.
+type roller struct {
+	mu     sync.Mutex // guards head and renewed
+	w      writer.Writer
+	files  []*os.File
+	logOut bool
+	every  time.Duration
+	start  time.Time
+	head   func(writer.Writer)
+	// renewed is set when the signature file is reloaded, to start new files with its Head
+	renewed bool
+}
+
+func newRoller(w writer.Writer, files []*os.File, logOut bool, every time.Duration) *roller {
+	return &roller{w: w, files: files, logOut: logOut, every: every, start: time.Now()}
+}
+
+func (r *roller) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setHead(path, created, version, ids, fields, hh, extra)
+	r.head(r.w)
+}
+
+// renew starts new output files, with a new Head, for the next result e.g. after the signature file is reloaded.
+func (r *roller) renew(path string, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setHead(path, created, version, ids, fields, hh, extra)
+	r.renewed = true
+}
+
+func (r *roller) setHead(path string, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	// some writers rewrite the fields they are given, so each Head gets its own copy
+	orig := copyFields(fields)
+	r.head = func(w writer.Writer) {
+		w.Head(path, time.Now(), created, version, ids, copyFields(orig), hh, extra)
+	}
+}
+
+func copyFields(fields [][]string) [][]string {
+	fc := make([][]string, len(fields))
+	for i, f := range fields {
+		fc[i] = append([]string(nil), f...)
+	}
+	return fc
+}
+
+func (r *roller) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	r.mu.Lock()
+	due := r.renewed || (r.every > 0 && time.Since(r.start) >= r.every)
+	r.renewed = false
+	r.mu.Unlock()
+	if due {
+		if e := r.roll(); e != nil {
+			log.Fatalf("[FATAL] -watch: error rolling output files: %v\n", e)
+		}
+	}
+	r.w.File(name, sz, mod, checksum, err, ids, extra)
+}
+
+func (r *roller) Tail() {
+	r.w.Tail()
+	for _, f := range r.files {
+		if e := f.Close(); e != nil {
+			log.Printf("[WARN] -watch: error writing %s: %v\n", f.Name(), e)
+		}
+	}
+	r.files = nil
+}
+
+// Err reports any error from the current writer
+func (r *roller) Err() error {
+	if e, ok := r.w.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+func (r *roller) roll() error {
+	r.Tail()
+	for _, p := range outputPaths() {
+		if err := os.Rename(p, stamped(p, r.start)); err != nil {
+			return err
+		}
+	}
+	w, files, err := openOutputs(r.logOut)
+	if err != nil {
+		return err
+	}
+	r.w, r.files, r.start = w, files, time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.head != nil {
+		r.head(w)
+	}
+	return nil
+}
+
+// poster is a writer that POSTs each result, as a JSON object (as in JSON Lines output), to a URL.
+// Results that can't be posted are logged, and not retried.
+type poster struct {
+	url    string
+	client *http.Client
+	buf    *bytes.Buffer
+	w      writer.Writer
+}
+
+func newPoster(url string) *poster {
+	buf := &bytes.Buffer{}
+	jsonl := writer.JSONL
+	if *basesf {
+		jsonl = writer.JSONLBases
+	}
+	return &poster{url: url, client: &http.Client{Timeout: 30 * time.Second}, buf: buf, w: jsonl(buf)}
+}
+
+func (p *poster) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	p.w.Head(path, scanned, created, version, ids, fields, hh, extra)
+}
+
+func (p *poster) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	p.buf.Reset()
+	p.w.File(name, sz, mod, checksum, err, ids, extra)
+	resp, e := p.client.Post(p.url, "application/json", bytes.NewReader(bytes.TrimSpace(p.buf.Bytes())))
+	if e != nil {
+		log.Printf("[WARN] -watchpost: error posting result for %s: %v\n", name, e)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("[WARN] -watchpost: error posting result for %s: %s\n", name, resp.Status)
+	}
+}
+
+func (p *poster) Tail() {}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richardlehane/siegfried/internal/logger"
+	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/writer"
+)
+
+// nameWriter sends the names of the files it is given
+type nameWriter chan string
+
+func (n nameWriter) Head(string, time.Time, time.Time, [3]int, [][2]string, [][]string, string, []string) {
+}
+
+func (n nameWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	n <- name
+}
+
+func (n nameWriter) Tail() {}
+
+func TestWatch(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	lg, _ := logger.New("")
+	names := make(nameWriter, 10)
+	ctxts := make(chan *context, 10)
+	printed := make(chan struct{})
+	go func() {
+		printer(ctxts, lg)
+		close(printed)
+	}()
+	defer func() {
+		close(ctxts)
+		<-printed // so the next test can reset the context pool
+	}()
+	setCtxPool(s, &sync.WaitGroup{}, names, false, false, -1)
+	os.WriteFile(filepath.Join(dir, "before.txt"), []byte("already here"), 0644)
+	w, err := newWatcher(s, dir, 100*time.Millisecond, false, false, ctxts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- w.run(stop) }()
+	pdf, _ := os.ReadFile(filepath.Join(*testdata, "benchmark", "Benchmark.pdf"))
+	os.WriteFile(filepath.Join(dir, "a.pdf"), pdf, 0644)
+	// a directory that arrives with files in it
+	tmp := t.TempDir()
+	os.MkdirAll(filepath.Join(tmp, "sub"), 0755)
+	os.WriteFile(filepath.Join(tmp, "sub", "b.pdf"), pdf, 0644)
+	if err := os.Rename(filepath.Join(tmp, "sub"), filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for len(got) < 2 {
+		select {
+		case n := <-names:
+			got[filepath.Base(n)] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expecting a.pdf and sub/b.pdf to be identified, got %v", got)
+		}
+	}
+	if !got["a.pdf"] || !got["b.pdf"] {
+		t.Errorf("expecting a.pdf and sub/b.pdf to be identified, got %v", got)
+	}
+	// a file added to the new directory is seen too
+	os.WriteFile(filepath.Join(dir, "sub", "c.pdf"), pdf, 0644)
+	select {
+	case n := <-names:
+		if filepath.Base(n) != "c.pdf" {
+			t.Errorf("expecting sub/c.pdf, got %s", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expecting sub/c.pdf to be identified")
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if _, err := newWatcher(s, filepath.Join(dir, "a.pdf"), time.Second, false, false, ctxts); err == nil {
+		t.Error("expecting an error watching a file")
+	}
+}
+
+func TestStamped(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "results.csv")
+	tm := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	if s := stamped(p, tm); s != filepath.Join(dir, "results-20230501T100000Z.csv") {
+		t.Errorf("unexpected name %s", s)
+	}
+	os.WriteFile(filepath.Join(dir, "results-20230501T100000Z.csv"), nil, 0644)
+	if s := stamped(p, tm); s != filepath.Join(dir, "results-20230501T100000Z-1.csv") {
+		t.Errorf("expecting a taken name to be avoided, got %s", s)
+	}
+}
+
+func TestRoller(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "results.csv")
+	csvo.on, csvo.path = true, p
+	defer func() { csvo.on, csvo.path = false, "" }()
+	w, files, err := openOutputs(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRoller(w, files, false, time.Hour)
+	fields := [][]string{{"namespace", "id"}}
+	r.Head("", time.Now(), time.Now(), [3]int{}, [][2]string{{"pronom", ""}}, fields, "", nil)
+	// another writer may rewrite the fields it is given (as the JSON writers do)
+	fields[0][0] = "rewritten"
+	r.File("a", 1, "", nil, nil, nil, nil)
+	r.start = r.start.Add(-2 * time.Hour)
+	rolled := stamped(p, r.start)
+	r.File("b", 1, "", nil, nil, nil, nil)
+	// after a reload, the next result starts a new file with the new header
+	r.renew("", time.Now(), [3]int{}, [][2]string{{"pronom", ""}}, [][]string{{"namespace", "id", "format"}}, "", nil)
+	renewed := stamped(p, r.start)
+	r.File("c", 1, "", nil, nil, nil, nil)
+	r.Tail()
+	old, err := os.ReadFile(rolled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, _ := os.ReadFile(renewed)
+	cur, _ := os.ReadFile(p)
+	if !strings.Contains(string(old), "\na,") || strings.Contains(string(old), "\nb,") || !strings.Contains(string(prev), "\nb,") {
+		t.Errorf("expecting a in the first rolled file and b in the next, got:\n%s\n%s", old, prev)
+	}
+	if !strings.HasPrefix(string(prev), "filename,filesize,modified,errors,namespace,id\n") {
+		t.Errorf("expecting the rolled file to have the same header, got:\n%s", prev)
+	}
+	if !strings.HasPrefix(string(cur), "filename,filesize,modified,errors,namespace,id,format\n") || !strings.Contains(string(cur), "\nc,") {
+		t.Errorf("expecting c in a new file with the new header, got:\n%s", cur)
+	}
+}
+
+func TestPoster(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byt, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, r.Header.Get("Content-Type")+" "+string(byt))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	var p writer.Writer = newPoster(srv.URL)
+	p.Head("", time.Now(), time.Now(), [3]int{}, [][2]string{{"pronom", ""}}, [][]string{{"namespace", "id"}}, "", nil)
+	p.File("a.pdf", 1, "", nil, nil, nil, nil)
+	p.File("b.pdf", 1, "", nil, nil, nil, nil)
+	p.Tail()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || !strings.HasPrefix(got[0], `application/json {"filename":"a.pdf"`) || !strings.HasSuffix(got[1], "}") {
+		t.Errorf("unexpected posts %q", got)
+	}
+}
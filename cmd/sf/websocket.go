@@ -0,0 +1,181 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/writer"
+)
+
+// progressEvent is a message sent over a WebSocket during a scan started at /progress.
+type progressEvent struct {
+	Event   string          `json:"event"`             // start, total, file or end
+	Path    string          `json:"path,omitempty"`    // the scanned path (start)
+	Done    int64           `json:"done"`              // files scanned so far
+	Total   int64           `json:"total"`             // files to scan, or -1 if not yet counted (or not countable e.g. with z=true)
+	Result  json.RawMessage `json:"result,omitempty"`  // the file's result, as reported in JSON Lines output (file)
+	Elapsed string          `json:"elapsed,omitempty"` // (end)
+	Error   string          `json:"error,omitempty"`   // a directory walk error that stopped the scan (end)
+}
+
+// wsWriter sends a scan's results, and its progress, as JSON messages over a WebSocket.
+// It is safe to send events from the printer and from the goroutine counting the scan.
+type wsWriter struct {
+	conn  *websocket.Conn
+	mu    sync.Mutex
+	buf   *bytes.Buffer
+	w     writer.Writer // JSON Lines writer to buf
+	done  int64
+	total int64
+	start time.Time
+}
+
+func newWSWriter(conn *websocket.Conn) *wsWriter {
+	buf := &bytes.Buffer{}
+	return &wsWriter{conn: conn, buf: buf, w: writer.JSONL(buf), total: -1, start: time.Now()}
+}
+
+// send must be called with the lock held. Send errors (e.g. a closed connection) are ignored: the scan runs to the end.
+func (ws *wsWriter) send(e progressEvent) {
+	e.Done, e.Total = ws.done, ws.total
+	websocket.JSON.Send(ws.conn, e)
+}
+
+func (ws *wsWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.w.Head(path, scanned, created, version, ids, fields, hh, extra)
+	ws.send(progressEvent{Event: "start", Path: path})
+}
+
+func (ws *wsWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.buf.Reset()
+	ws.w.File(name, sz, mod, checksum, err, ids, extra)
+	ws.done++
+	ws.send(progressEvent{Event: "file", Result: bytes.TrimSpace(ws.buf.Bytes())})
+}
+
+func (ws *wsWriter) Tail() {}
+
+// addTotal reports a partial count of the files to scan.
+func (ws *wsWriter) addTotal(n int64) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.total < 0 {
+		ws.total = 0
+	}
+	ws.total += n
+}
+
+// counted sends the total once counting is finished.
+func (ws *wsWriter) counted() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.total < 0 {
+		ws.total = 0
+	}
+	ws.send(progressEvent{Event: "total"})
+}
+
+func (ws *wsWriter) end(err error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	e := progressEvent{Event: "end", Elapsed: time.Since(ws.start).String()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	ws.send(e)
+}
+
+// sameOrigin rejects WebSocket connections from web pages served by other sites (WebSockets aren't subject to CORS).
+// Clients that aren't browsers needn't send an Origin header.
+func sameOrigin(r *http.Request) error {
+	o := r.Header.Get("Origin")
+	if o == "" {
+		return nil
+	}
+	u, err := url.Parse(o)
+	if err != nil || u.Host != r.Host {
+		return fmt.Errorf("WebSocket connections are only accepted from the same origin, got %s", o)
+	}
+	return nil
+}
+
+// handleProgress scans a file or directory on the server, like a GET request to /identify, and streams each file's result
+// and the scan's progress as JSON messages over a WebSocket.
+func handleProgress(w http.ResponseWriter, r *http.Request, s *siegfried.Siegfried, ctxts chan *context) {
+	if err := sameOrigin(r); err != nil {
+		handleErr(w, http.StatusForbidden, err)
+		return
+	}
+	wg := &sync.WaitGroup{}
+	_, _, coerr, nrec, _, ht, sf, gf, err := parseRequest(w, r, s, wg)
+	if err != nil {
+		handleErr(w, http.StatusNotFound, err)
+		return
+	}
+	path, err := postedPath(r)
+	if err == nil && path == "" {
+		path, err = decodePath(r.URL.Path, r.FormValue("base64"))
+	}
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	if err != nil {
+		handleErr(w, http.StatusNotFound, err)
+		return
+	}
+	// archive members can't be counted without reading files, as for the progress meter (see countFiles)
+	z := r.FormValue("z") == "true" || (*archive && r.FormValue("z") != "false")
+	websocket.Server{Handler: func(conn *websocket.Conn) {
+		ws := newWSWriter(conn)
+		wsgf := func(path, mime string, mod time.Time, sz int64) *context {
+			c := gf(path, mime, mod, sz)
+			c.w, c.d = ws, false
+			return c
+		}
+		ws.Head(path, time.Now(), sf.C, config.Version(), sf.Identifiers(), sf.Fields(), ht.String(), extraFields(sf))
+		counting := make(chan struct{})
+		go func() {
+			if !z {
+				countDir(path, nrec, false, ws.addTotal)
+				ws.counted()
+			}
+			close(counting)
+		}()
+		err := identify(ctxts, path, "", coerr, nrec, false, wsgf)
+		wg.Wait()
+		<-counting
+		if _, ok := err.(walkError); !ok { // other errors are reported in the results
+			err = nil
+		}
+		ws.end(err)
+	}}.ServeHTTP(w, r)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/richardlehane/siegfried/internal/logger"
+)
+
+func TestSameOrigin(t *testing.T) {
+	for _, v := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://localhost:5138", true},
+		{"http://evil.example.com", false},
+		{"http://localhost:5139", false},
+	} {
+		r := httptest.NewRequest("GET", "http://localhost:5138/progress/data", nil)
+		if v.origin != "" {
+			r.Header.Set("Origin", v.origin)
+		}
+		if err := sameOrigin(r); (err == nil) != v.ok {
+			t.Errorf("origin %q: expecting ok %v, got %v", v.origin, v.ok, err)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Fatal(err)
+	}
+	lg, _ := logger.New("")
+	ctxts := make(chan *context, 1)
+	go printer(ctxts, lg)
+	defer close(ctxts)
+	setCtxPool(s, &sync.WaitGroup{}, nil, false, false, -1)
+	metrics = newMetrics() // the upgrade must get through the metrics' status recorder
+	defer func() { metrics = nil }()
+	srv := httptest.NewServer(&muxer{s: s, ctxts: ctxts})
+	defer srv.Close()
+	dir, _ := filepath.Abs(filepath.Join(*testdata, "benchmark"))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/progress/"+url.PathEscape(dir), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var (
+		events = make(map[string]int)
+		last   progressEvent
+		pdf    bool
+	)
+	for last.Event != "end" {
+		last = progressEvent{}
+		if err := websocket.JSON.Receive(conn, &last); err != nil {
+			t.Fatalf("expecting an end event, got %v after %v", err, events)
+		}
+		events[last.Event]++
+		if last.Event == "file" {
+			var res struct {
+				Filename string
+				Matches  []struct{ ID string }
+			}
+			if err := json.Unmarshal(last.Result, &res); err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Base(res.Filename) == "Benchmark.pdf" {
+				pdf = len(res.Matches) > 0 && res.Matches[0].ID == "fmt/18"
+			}
+		}
+	}
+	if events["start"] != 1 || events["total"] != 1 || events["file"] != len(entries) {
+		t.Errorf("unexpected events: %v", events)
+	}
+	if last.Done != int64(len(entries)) || last.Total != int64(len(entries)) || last.Error != "" {
+		t.Errorf("unexpected end event: %+v", last)
+	}
+	if !pdf {
+		t.Error("expecting a file event identifying Benchmark.pdf as fmt/18")
+	}
+	// a path that doesn't exist is reported before the upgrade
+	resp, err := http.Get(srv.URL + "/progress/" + url.PathEscape(filepath.Join(dir, "missing")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expecting a 404 for a missing path, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+var whyf = flag.Bool("why", false, "report, in a why field, the candidate identifications that were suppressed in favour of each identification and why e.g. fmt/44 superseded by fmt/645 (priority)")
+
+// whyExtra reports, as a "why" field, the explanations given by identifiers for the candidates they suppressed.
+// The explanations for each identification are separated by "; ", and identifications are separated by " | ".
+func whyExtra() siegfried.Extra {
+	return siegfried.Extra{
+		Name: "why",
+		Fn: func(c siegfried.Content, ids []core.Identification) string {
+			strs := make([]string, len(ids))
+			for i, id := range ids {
+				strs[i] = strings.Join(core.Why(id), "; ")
+			}
+			return strings.Join(strs, " | ")
+		},
+	}
+}
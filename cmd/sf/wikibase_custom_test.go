@@ -164,8 +164,9 @@ func wbSiegfriedRunner(wbSiegfried *siegfried.Siegfried, path string, test wbIde
 		wbSiegfried.Identifiers(),
 		wbSiegfried.Fields(),
 		"md5",
+		nil,
 	)
-	w.File("testName", 10, "testMod", []byte("d41d8c"), nil, res)
+	w.File("testName", 10, "testMod", []byte("d41d8c"), nil, res, nil)
 	w.Tail()
 	if !json.Valid([]byte(buf.String())) {
 		t.Fatalf("Output from JSON writer is invalid: %s", buf.String())
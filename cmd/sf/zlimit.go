@@ -0,0 +1,150 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var zlimitf = flag.String("zlimit", "", "limit the scanning of archives, disk images and OLE2 documents (with -z, -disk or -ole) by nesting depth, expansion ratio and total uncompressed size e.g. -zlimit depth=5,ratio=100,size=10GB (defaults are depth=10,ratio=1000 and no size limit)")
+
+// zlimits guard recursion into containers. The defaults can be changed with -zlimit.
+var zlimits = limits{depth: 10, ratio: 1000}
+
+// ratioFloor is the number of bytes that can be read from a container's members before the ratio limit applies,
+// so that small but highly compressible archives (e.g. of text or empty images) aren't treated as bombs
+const ratioFloor = 1 << 26
+
+type limits struct {
+	depth int     // containers nested deeper than this aren't opened
+	ratio float64 // maximum ratio of bytes read from members to the size of the outermost container (0 for no limit)
+	size  int64   // maximum bytes read from the members of the outermost container (0 for no limit)
+}
+
+func newLimits(settings string) (limits, error) {
+	l := zlimits
+	for _, kv := range strings.Split(settings, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		var err error
+		switch k {
+		case "depth":
+			if l.depth, err = strconv.Atoi(v); err == nil && l.depth < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		case "ratio":
+			if l.ratio, err = strconv.ParseFloat(v, 64); err == nil && l.ratio < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "size":
+			l.size, err = parseSize(v)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return l, fmt.Errorf("bad -zlimit setting %q: %v", kv, err)
+		}
+	}
+	return l, nil
+}
+
+// parseSize parses a number of bytes with an optional KB, MB, GB or TB suffix (multiples of 1024)
+func parseSize(s string) (int64, error) {
+	mul := int64(1)
+	u := strings.ToUpper(s)
+	for i, suf := range []string{"KB", "MB", "GB", "TB"} {
+		if strings.HasSuffix(u, suf) {
+			mul, u = 1<<(10*(i+1)), strings.TrimSuffix(u, suf)
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(u), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expecting a number of bytes e.g. 500MB")
+	}
+	return n * mul, nil
+}
+
+// depthError reports a container that wasn't opened because it is nested too deeply
+type depthError int
+
+func (d depthError) Error() string {
+	return fmt.Sprintf("not scanned: nested more than %d containers deep (see -zlimit)", int(d))
+}
+
+// bombError reports a container whose members were abandoned because they exceeded a limit
+type bombError struct {
+	ratio bool
+	max   int64
+}
+
+func (b bombError) Error() string {
+	if b.ratio {
+		return fmt.Sprintf("possible decompression bomb: scan stopped after %d bytes, more than %g times the archive's size (see -zlimit)", b.max, zlimits.ratio)
+	}
+	return fmt.Sprintf("possible decompression bomb: scan stopped after %d bytes, the size limit (see -zlimit)", b.max)
+}
+
+// budget counts the bytes read from the members of an outermost container, and the members of any containers within it
+type budget struct {
+	read, max int64 // max is 0 for no limit
+	ratio     bool  // max was set by the ratio limit
+	err       error
+}
+
+// newBudget makes a budget for a container of sz bytes (the ratio limit doesn't apply if the size isn't known e.g. for stdin)
+func newBudget(sz int64) *budget {
+	b := &budget{max: zlimits.size}
+	if zlimits.ratio > 0 && sz > 0 {
+		r := int64(zlimits.ratio * float64(sz))
+		if r < ratioFloor {
+			r = ratioFloor
+		}
+		if b.max == 0 || r < b.max {
+			b.max, b.ratio = r, true
+		}
+	}
+	return b
+}
+
+// reader wraps a member's reader so that reads fail once the budget is exceeded.
+// Members read in place (e.g. from an uncompressed tar) aren't wrapped, as they aren't decompressed.
+func (b *budget) reader(r io.Reader) io.Reader {
+	if s, ok := r.(interface{ IsSlicer() bool }); ok && s.IsSlicer() {
+		return r
+	}
+	return &budgetReader{r, b}
+}
+
+type budgetReader struct {
+	r io.Reader
+	b *budget
+}
+
+func (br *budgetReader) Read(p []byte) (int, error) {
+	if br.b.err != nil {
+		return 0, br.b.err
+	}
+	n, err := br.r.Read(p)
+	br.b.read += int64(n)
+	if br.b.max > 0 && br.b.read > br.b.max {
+		br.b.err = bombError{br.b.ratio, br.b.max}
+		return n, br.b.err
+	}
+	return n, err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	for _, bad := range []string{"depth=0", "ratio=-1", "size=lots", "size=-1MB", "bombs=1"} {
+		if _, err := newLimits(bad); err == nil {
+			t.Errorf("expecting an error for -zlimit %q", bad)
+		}
+	}
+	l, err := newLimits("depth=3, size=2MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.depth != 3 || l.size != 2<<20 || l.ratio != zlimits.ratio {
+		t.Errorf("bad limits: %+v", l)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	old := zlimits
+	defer func() { zlimits = old }()
+	// the ratio doesn't apply below the floor
+	zlimits = limits{depth: 10, ratio: 10}
+	b := newBudget(100)
+	if n, err := io.Copy(io.Discard, b.reader(bytes.NewReader(make([]byte, 5000)))); n != 5000 || err != nil {
+		t.Fatalf("expecting 5000 bytes read, got %d, %v", n, err)
+	}
+	// a size limit is shared by all the readers of the budget
+	zlimits = limits{depth: 10, size: 6000}
+	b = newBudget(0)
+	io.Copy(io.Discard, b.reader(bytes.NewReader(make([]byte, 5000))))
+	if _, err := io.Copy(io.Discard, b.reader(bytes.NewReader(make([]byte, 5000)))); err == nil || b.err == nil {
+		t.Fatal("expecting a bomb error")
+	}
+	if e, ok := b.err.(bombError); !ok || e.ratio {
+		t.Errorf("expecting a size bomb error, got %v", b.err)
+	}
+	if n, _ := b.reader(bytes.NewReader([]byte{1})).Read(make([]byte, 1)); n != 0 {
+		t.Error("expecting no reads once the budget is exceeded")
+	}
+}
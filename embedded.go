@@ -0,0 +1,148 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package siegfried
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/richardlehane/siegfried/internal/bytematcher"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// Embedded is a format detected within a file, at a nonzero offset e.g. a JPEG thumbnail inside a PDF, or a ZIP appended to an EXE.
+type Embedded struct {
+	Offset int64
+	ID     string // the identifier and format e.g. "pronom: fmt/43"
+}
+
+// MaxEmbedded is the maximum number of offsets within a file that are tested for embedded formats.
+const MaxEmbedded = 64
+
+// embeddedMagic are the leading bytes of formats that are commonly embedded in other files. Offsets where one occurs are
+// tested with the byte signatures. Magic that also marks records within a file of its format (e.g. the local file
+// headers of a ZIP) is only tested where it first occurs, and is ignored in files that begin with it.
+var embeddedMagic = []struct {
+	magic   []byte
+	records bool
+}{
+	{[]byte{0xFF, 0xD8, 0xFF}, false},                               // JPEG
+	{[]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}, false},    // PNG
+	{[]byte("GIF8"), false},                                         // GIF
+	{[]byte("%PDF-"), false},                                        // PDF
+	{[]byte{'P', 'K', 0x03, 0x04}, true},                            // ZIP
+	{[]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, false}, // OLE2
+	{[]byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}, false},               // 7z
+	{[]byte{'R', 'a', 'r', '!', 0x1A, 0x07}, false},                 // RAR
+	{[]byte{0x1F, 0x8B, 0x08}, false},                               // GZIP
+	{[]byte("RIFF"), true},                                          // RIFF
+}
+
+// Embedded returns the formats detected within the content at nonzero offsets. Offsets where the magic of a commonly embedded
+// format occurs (up to MaxEmbedded of them) are tested with the byte signatures anchored to the beginning of a file, ignoring
+// their EOF segments. Content must be the full content of a file, as returned by the Buffer method, and the entire content is scanned.
+func (s *Siegfried) Embedded(c Content) []Embedded {
+	if s.decode(idsSec, byteSec) != nil {
+		return nil
+	}
+	bm, ok := s.bm.(*bytematcher.Matcher)
+	if !ok {
+		return nil
+	}
+	var ret []Embedded
+	for _, off := range embeddedOffsets(c) {
+		buf, err := s.Buffer(io.LimitReader(&contentReader{c: c, off: off}, WindowSize))
+		if err == nil {
+			for _, idx := range bm.Anchored(buf) {
+				for _, id := range s.ids {
+					if ok, str := id.Recognise(core.ByteMatcher, idx); ok {
+						ret = addEmbedded(ret, Embedded{off, str})
+						break
+					}
+				}
+			}
+		}
+		s.Put(buf)
+	}
+	return ret
+}
+
+// embeddedOffsets scans the content for the magic of commonly embedded formats
+func embeddedOffsets(c Content) []int64 {
+	const chunk = 1 << 20
+	var max int
+	for _, m := range embeddedMagic {
+		if len(m.magic) > max {
+			max = len(m.magic)
+		}
+	}
+	head, _ := c.Slice(0, max)
+	seen := make([]bool, len(embeddedMagic)) // for records magic
+	for i, m := range embeddedMagic {
+		seen[i] = m.records && bytes.HasPrefix(head, m.magic)
+	}
+	var ret []int64
+	for off := int64(0); len(ret) < MaxEmbedded; off += chunk {
+		buf, err := c.Slice(off, chunk+max-1)
+		if len(buf) == 0 {
+			break
+		}
+		for i := 0; i < len(buf) && i < chunk && len(ret) < MaxEmbedded; i++ {
+			if off+int64(i) == 0 {
+				continue
+			}
+			for j, m := range embeddedMagic {
+				if !seen[j] && bytes.HasPrefix(buf[i:], m.magic) {
+					seen[j] = m.records
+					ret = append(ret, off+int64(i))
+					break
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return ret
+}
+
+// addEmbedded adds a format at an offset, unless it has already been found there (e.g. by another signature for the format)
+func addEmbedded(es []Embedded, e Embedded) []Embedded {
+	for _, v := range es {
+		if v == e {
+			return es
+		}
+	}
+	return append(es, e)
+}
+
+// contentReader reads Content from an offset
+type contentReader struct {
+	c   Content
+	off int64
+}
+
+func (r *contentReader) Read(p []byte) (int, error) {
+	buf, err := r.c.Slice(r.off, len(p))
+	n := copy(p, buf)
+	r.off += int64(n)
+	if n > 0 {
+		return n, nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return n, err
+}
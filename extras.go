@@ -0,0 +1,145 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package siegfried
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// WindowSize is the number of bytes at the beginning and end of a file that are
+// available to Extra functions that don't require full content.
+const WindowSize = 65536
+
+// Content gives Extra functions access to the bytes of the file being identified.
+// It is satisfied by the siegreader Buffer returned by the Buffer method.
+type Content interface {
+	Slice(off int64, l int) ([]byte, error)    // Slice returns a slice of length l from offset off
+	EofSlice(off int64, l int) ([]byte, error) // EofSlice returns a slice of length l from offset off, measured from the end of the file
+	SizeNow() int64                            // SizeNow returns the size of the content read so far
+}
+
+// Extra describes a custom, per-file field that is computed in the same pass as identification.
+// Extras let embedders enrich output (e.g. with an entropy score or a local classification) without
+// changing the identification pipeline.
+//
+// Example:
+//  err := s.AddExtra(siegfried.Extra{
+//  	Name: "first",
+//  	Fn: func(c siegfried.Content, ids []core.Identification) string {
+//  		buf, _ := c.Slice(0, 1)
+//  		return fmt.Sprintf("%x", buf)
+//  	},
+//  })
+type Extra struct {
+	Name string // the name of the field as it should appear in output
+	// Full should be set if Fn needs to read the full content of the file.
+	// If false, Fn is limited to the first and last WindowSize bytes
+	// and reads beyond those windows will return io.EOF.
+	// If no registered extra is Full, buffers from the Buffer method keep only a window at the end
+	// of streams too big to buffer in memory, rather than copying them to temp files.
+	Full bool
+	Fn   func(Content, []core.Identification) string
+}
+
+// AddExtra registers a custom field with a Siegfried struct.
+// Extras are not persisted when a Siegfried is saved.
+func (s *Siegfried) AddExtra(e Extra) error {
+	if e.Fn == nil {
+		return fmt.Errorf("siegfried: extra field %s has a nil function", e.Name)
+	}
+	for _, v := range s.extras {
+		if v.Name == e.Name {
+			return fmt.Errorf("siegfried: extra fields must have unique names, you already have a field named %s", e.Name)
+		}
+	}
+	s.extras = append(s.extras, e)
+	return nil
+}
+
+// windowed reports whether extras are registered and all of them are limited to the BOF and EOF windows
+func (s *Siegfried) windowed() bool {
+	for _, v := range s.extras {
+		if v.Full {
+			return false
+		}
+	}
+	return len(s.extras) > 0
+}
+
+// ExtraFields returns the names of the extra fields registered with AddExtra.
+func (s *Siegfried) ExtraFields() []string {
+	if len(s.extras) == 0 {
+		return nil
+	}
+	ret := make([]string, len(s.extras))
+	for i, v := range s.extras {
+		ret[i] = v.Name
+	}
+	return ret
+}
+
+// Extra computes the registered extra fields for a buffer that has been identified with IdentifyBuffer.
+// The returned values are in the same order as the names returned by ExtraFields.
+func (s *Siegfried) Extra(buffer *siegreader.Buffer, ids []core.Identification) []string {
+	if len(s.extras) == 0 {
+		return nil
+	}
+	ret := make([]string, len(s.extras))
+	for i, v := range s.extras {
+		if v.Full {
+			ret[i] = v.Fn(buffer, ids)
+			continue
+		}
+		ret[i] = v.Fn(window{buffer}, ids)
+	}
+	return ret
+}
+
+// window limits reads to the BOF and EOF windows of Content
+type window struct {
+	Content
+}
+
+func (w window) Slice(off int64, l int) ([]byte, error) {
+	if off >= WindowSize {
+		return nil, io.EOF
+	}
+	if off+int64(l) > WindowSize {
+		buf, err := w.Content.Slice(off, WindowSize-int(off))
+		if err == nil {
+			err = io.EOF
+		}
+		return buf, err
+	}
+	return w.Content.Slice(off, l)
+}
+
+func (w window) EofSlice(off int64, l int) ([]byte, error) {
+	if off >= WindowSize {
+		return nil, io.EOF
+	}
+	if off+int64(l) > WindowSize {
+		buf, err := w.Content.EofSlice(off, WindowSize-int(off))
+		if err == nil {
+			err = io.EOF
+		}
+		return buf, err
+	}
+	return w.Content.EofSlice(off, l)
+}
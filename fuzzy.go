@@ -0,0 +1,63 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package siegfried
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/richardlehane/siegfried/internal/bytematcher"
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// NearMiss is a byte signature that almost matched a file.
+type NearMiss struct {
+	ID    string  // the identifier and format the signature belongs to e.g. "pronom: fmt/43"
+	Score float64 // between 0 and 1, the higher the score, the closer the signature came to matching
+	Basis string  // a description of the near miss e.g. "matched 2 of 3 segments"
+}
+
+// NearMisses returns the byte signatures that almost matched the content, sorted from highest to lowest score.
+// A signature almost matches if all but one of its segments matched, or if all of its segments matched
+// but at the wrong offsets relative to each other.
+// Content must be the full content of a file, as returned by the Buffer method, and the entire content is scanned.
+// Signatures that match aren't reported.
+func (s *Siegfried) NearMisses(c Content) []NearMiss {
+	buf, ok := c.(*siegreader.Buffer)
+	if !ok || s.decode(idsSec, byteSec) != nil || s.bm == nil {
+		return nil
+	}
+	bm, ok := s.bm.(*bytematcher.Matcher)
+	if !ok {
+		return nil
+	}
+	misses := bm.NearMisses(buf)
+	ret := make([]NearMiss, 0, len(misses))
+	for _, m := range misses {
+		for _, id := range s.ids {
+			if ok, str := id.Recognise(core.ByteMatcher, m.Index); ok {
+				basis := fmt.Sprintf("matched %d of %d segments", m.Matched, m.Segments)
+				if m.Shifted {
+					basis = fmt.Sprintf("matched %d segments at shifted offsets", m.Segments)
+				}
+				ret = append(ret, NearMiss{str, m.Score(), basis})
+				break
+			}
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Score > ret[j].Score })
+	return ret
+}
@@ -3,18 +3,26 @@ module github.com/richardlehane/siegfried
 go 1.18
 
 require (
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/richardlehane/characterize v1.0.0
 	github.com/richardlehane/match v1.0.5
 	github.com/richardlehane/mscfb v1.0.4
 	github.com/richardlehane/webarchive v1.0.0
 	github.com/richardlehane/xmldetect v1.0.2
 	github.com/ross-spencer/wikiprov v0.2.0
+	github.com/ulikunitz/xz v0.5.11
+	golang.org/x/crypto v0.7.0
 	golang.org/x/image v0.6.0
-	golang.org/x/sys v0.6.0
+	golang.org/x/net v0.9.0
+	golang.org/x/sys v0.7.0
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.30.0
 )
 
 require (
+	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/richardlehane/msoleps v1.0.3 // indirect
 	github.com/ross-spencer/spargo v0.4.1 // indirect
-	golang.org/x/text v0.8.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
 )
@@ -0,0 +1,198 @@
+// Copyright 2026 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package siegfried
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/richardlehane/siegfried/internal/bytematcher"
+	"github.com/richardlehane/siegfried/internal/containermatcher"
+	"github.com/richardlehane/siegfried/internal/mimematcher"
+	"github.com/richardlehane/siegfried/internal/namematcher"
+	"github.com/richardlehane/siegfried/internal/persist"
+	"github.com/richardlehane/siegfried/internal/riffmatcher"
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/internal/textmatcher"
+	"github.com/richardlehane/siegfried/internal/xmlmatcher"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// A signature file is persisted in sections: the create time, each of the matchers, and the identifiers.
+// A compressed signature file is a single flate stream of all the sections.
+// An indexed signature file (see config.SetIndexed) follows the magic and version bytes with indexMarker,
+// a table of the lengths of the sections (little endian uint32s), and then the uncompressed sections.
+// Each section is decoded on first use.
+const (
+	timeSec = iota
+	nameSec
+	mimeSec
+	containerSec
+	xmlSec
+	riffSec
+	byteSec
+	textSec
+	idsSec
+	numSecs
+)
+
+const errReading = "siegfried: error reading signature file, got %v; try running `sf -update`"
+
+// indexMarker can't begin a flate stream (its block type is reserved), so it distinguishes indexed from compressed signature files
+const indexMarker = 0xFF
+
+// lazy holds the undecoded sections of an indexed signature file
+type lazy struct {
+	secs  [numSecs][]byte // may be memory mapped: copied before they are decoded
+	once  [numSecs]sync.Once
+	errs  [numSecs]error
+	unmap func() error // nil if the signature file isn't memory mapped
+}
+
+func (s *Siegfried) saveSection(i int, ls *persist.LoadSaver) {
+	switch i {
+	case timeSec:
+		ls.SaveTime(s.C)
+	case nameSec:
+		namematcher.Save(s.nm, ls)
+	case mimeSec:
+		mimematcher.Save(s.mm, ls)
+	case containerSec:
+		containermatcher.Save(s.cm, ls)
+	case xmlSec:
+		xmlmatcher.Save(s.xm, ls)
+	case riffSec:
+		riffmatcher.Save(s.rm, ls)
+	case byteSec:
+		bytematcher.Save(s.bm, ls)
+	case textSec:
+		textmatcher.Save(s.tm, ls)
+	case idsSec:
+		ls.SaveTinyUInt(len(s.ids))
+		for _, i := range s.ids {
+			i.Save(ls)
+		}
+	}
+}
+
+func (s *Siegfried) loadSection(i int, ls *persist.LoadSaver) {
+	switch i {
+	case timeSec:
+		s.C = ls.LoadTime()
+	case nameSec:
+		s.nm = namematcher.Load(ls)
+	case mimeSec:
+		s.mm = mimematcher.Load(ls)
+	case containerSec:
+		s.cm = containermatcher.Load(ls)
+	case xmlSec:
+		s.xm = xmlmatcher.Load(ls)
+	case riffSec:
+		s.rm = riffmatcher.Load(ls)
+	case byteSec:
+		s.bm = bytematcher.Load(ls)
+	case textSec:
+		s.tm = textmatcher.Load(ls)
+	case idsSec:
+		s.ids = make([]core.Identifier, ls.LoadTinyUInt())
+		for i := range s.ids {
+			s.ids[i] = core.LoadIdentifier(ls)
+		}
+	}
+}
+
+// saveIndexed writes the index and sections of an indexed signature file
+func (s *Siegfried) saveIndexed(w io.Writer) error {
+	var secs [numSecs][]byte
+	hdr := make([]byte, 1+4*numSecs)
+	hdr[0] = indexMarker
+	for i := range secs {
+		ls := persist.NewLoadSaver(nil)
+		s.saveSection(i, ls)
+		if ls.Err != nil {
+			return ls.Err
+		}
+		secs[i] = ls.Bytes()
+		binary.LittleEndian.PutUint32(hdr[1+4*i:], uint32(len(secs[i])))
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	for _, sec := range secs {
+		if _, err := w.Write(sec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadIndexed reads the index of an indexed signature file (buf follows the indexMarker). Only the create time is decoded.
+func loadIndexed(buf []byte) (*Siegfried, error) {
+	if len(buf) < 4*numSecs {
+		return nil, fmt.Errorf(errReading, "truncated index")
+	}
+	l := &lazy{}
+	off := 4 * numSecs
+	for i := range l.secs {
+		sz := binary.LittleEndian.Uint32(buf[4*i:])
+		if uint64(sz) > uint64(len(buf)-off) {
+			return nil, fmt.Errorf(errReading, "section overflows the index")
+		}
+		l.secs[i] = buf[off : off+int(sz)]
+		off += int(sz)
+	}
+	s := &Siegfried{
+		lazy:    l,
+		buffers: siegreader.New(),
+	}
+	if err := s.decode(timeSec); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// decode decodes the given sections of an indexed signature file, if they haven't been decoded already.
+// It is safe to call concurrently and is a no-op if the signature file wasn't indexed.
+// The fields of a section must not be read before it is decoded, or after decode returns an error.
+func (s *Siegfried) decode(secs ...int) error {
+	if s.lazy == nil {
+		return nil
+	}
+	for _, i := range secs {
+		s.lazy.once[i].Do(func() {
+			ls := persist.NewLoadSaver(append([]byte(nil), s.lazy.secs[i]...))
+			s.loadSection(i, ls)
+			if ls.Err != nil {
+				s.lazy.errs[i] = fmt.Errorf(errReading, ls.Err)
+			}
+		})
+		if s.lazy.errs[i] != nil {
+			return s.lazy.errs[i]
+		}
+	}
+	return nil
+}
+
+// decodeAll decodes all the sections of an indexed signature file (e.g. before identifiers are added to it, or it is saved)
+func (s *Siegfried) decodeAll() error {
+	for i := 0; i < numSecs; i++ {
+		if err := s.decode(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
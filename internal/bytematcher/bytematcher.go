@@ -17,9 +17,9 @@ package bytematcher
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
-	"github.com/richardlehane/match/dwac"
 	"github.com/richardlehane/siegfried/internal/bytematcher/frames"
 	"github.com/richardlehane/siegfried/internal/persist"
 	"github.com/richardlehane/siegfried/internal/priority"
@@ -44,8 +44,8 @@ type Matcher struct {
 	// remaining fields are not persisted
 	bmu  *sync.Once
 	emu  *sync.Once
-	bAho *dwac.Dwac
-	eAho *dwac.Dwac
+	bAho engine
+	eAho engine
 }
 
 // SignatureSet for a bytematcher is a slice of frames.Signature.
@@ -171,10 +171,126 @@ func Add(c core.Matcher, ss core.SignatureSet, priorities priority.List) (core.M
 //	}
 func (b *Matcher) Identify(name string, sb *siegreader.Buffer, hints ...core.Hint) (chan core.Result, error) {
 	quit, ret := make(chan struct{}), make(chan core.Result)
-	go b.identify(sb, quit, ret, hints...)
+	go b.identify(sb, quit, ret, nil, hints...)
 	return ret, nil
 }
 
+// NearMiss is a signature that almost matched: either all but one of its segments matched,
+// or all of its segments matched but not at the distances from each other that the signature requires.
+type NearMiss struct {
+	Index    int  // signature index
+	Matched  int  // number of segments matched
+	Segments int  // number of segments in the signature
+	Shifted  bool // all segments matched, but at the wrong relative offsets
+}
+
+// Score is a rough measure of how close a near miss came to matching, between 0 and 1.
+// Shifted near misses score higher than near misses that are missing a segment.
+func (n NearMiss) Score() float64 {
+	if n.Shifted {
+		return float64(2*n.Segments-1) / float64(2*n.Segments)
+	}
+	return float64(n.Matched) / float64(n.Segments)
+}
+
+// NearMisses scans the entire input siegreader.Buffer and returns the signatures that almost matched it.
+// Signatures that do match aren't near misses. Nor are single segment signatures, as their sequences are only
+// searched for at the offsets they permit.
+func (b *Matcher) NearMisses(sb *siegreader.Buffer) []NearMiss {
+	quit, ret, misses := make(chan struct{}), make(chan core.Result), make(chan map[int]*hitItem, 1)
+	go b.identify(sb, quit, ret, misses)
+	for range ret {
+	}
+	return b.nearMisses(<-misses)
+}
+
+// nearMisses reports the unmatched signatures that have partial matches for all but one of their segments,
+// or for all of their segments at the wrong relative offsets
+func (b *Matcher) nearMisses(hits map[int]*hitItem) []NearMiss {
+	ret := make([]NearMiss, 0, 10)
+	for i, h := range hits {
+		if h.matched {
+			continue
+		}
+		var n int
+		for _, p := range h.partials {
+			if p != nil {
+				n++
+			}
+		}
+		switch {
+		case n == len(h.partials)-1 && n > 0:
+			ret = append(ret, NearMiss{i, n, len(h.partials), false})
+		case n == len(h.partials):
+			if ok, _ := searchPartials(h.partials, b.keyFrames[i]); !ok {
+				ret = append(ret, NearMiss{i, n, n, true})
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Index < ret[j].Index })
+	return ret
+}
+
+// Anchored scans the entire input siegreader.Buffer and returns the signatures, anchored to the beginning of the buffer,
+// whose BOF segments matched. A signature's EOF segments are ignored: Anchored is used to identify content embedded within a file,
+// where the buffer starts with the embedded content but doesn't end with it. Signatures outranked by another that matched aren't reported.
+func (b *Matcher) Anchored(sb *siegreader.Buffer) []int {
+	quit, ret, misses := make(chan struct{}), make(chan core.Result), make(chan map[int]*hitItem, 1)
+	go b.identify(sb, quit, ret, misses)
+	var idxs []int
+	add := func(i int) {
+		if kf := b.keyFrames[i][0]; kf.typ != frames.BOF || kf.key.pMin != 0 || kf.key.pMax < 0 {
+			return
+		}
+		for _, v := range idxs {
+			if v == i {
+				return
+			}
+		}
+		idxs = append(idxs, i)
+	}
+	for r := range ret {
+		add(r.Index())
+	}
+	for i, h := range <-misses {
+		if !h.matched && b.bofMatched(i, h.partials) {
+			add(i)
+		}
+	}
+	// drop signatures outranked by others that matched
+	top := make([]int, 0, len(idxs))
+	for _, i := range idxs {
+		var outranked bool
+		for _, sup := range b.priorities.Superiors(i) {
+			for _, j := range idxs {
+				if j == sup {
+					outranked = true
+				}
+			}
+		}
+		if !outranked {
+			top = append(top, i)
+		}
+	}
+	sort.Ints(top)
+	return top
+}
+
+// bofMatched reports whether a signature that has segments positioned relative to the EOF has partial matches for all its other segments
+func (b *Matcher) bofMatched(i int, partials [][][2]int64) bool {
+	var eof bool
+	for j, kf := range b.keyFrames[i] {
+		if kf.typ == frames.EOF || kf.typ == frames.SUCC {
+			eof = true
+			continue
+		}
+		if partials[j] == nil {
+			return false
+		}
+	}
+	return eof
+}
+
 // String returns information about the Bytematcher including the number of BOF, VAR and EOF sequences, the number of BOF and EOF frames, and the total number of tests.
 func (b *Matcher) String() string {
 	str := fmt.Sprintf("BOF seqs: %v\n", len(b.bofSeq.set))
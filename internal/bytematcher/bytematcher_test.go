@@ -8,6 +8,7 @@ import (
 	"github.com/richardlehane/siegfried/internal/bytematcher/frames/tests"
 	"github.com/richardlehane/siegfried/internal/persist"
 	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/core"
 )
 
@@ -82,3 +83,78 @@ func TestMatch(t *testing.T) {
 		t.Errorf("Missing result, got: %v, expecting:%v\n", results, bm)
 	}
 }
+
+func TestEngines(t *testing.T) {
+	defer config.SetEngine("dynamic")
+	for _, e := range []string{"dynamic", "static", "lowmem"} {
+		if err := config.SetEngine(e); err != nil {
+			t.Fatal(err)
+		}
+		bm, _, err := Add(nil, SignatureSet(tests.TestSignatures), nil) // engines are built on first use by each matcher
+		if err != nil {
+			t.Fatal(err)
+		}
+		bufs := siegreader.New()
+		for _, v := range []struct {
+			sample []byte
+			expect []int
+		}{
+			{TestSample1, []int{0, 2, 3, 4}},
+			{TestSample2, []int{0, 1, 2, 3, 4}},
+		} {
+			buf, err := bufs.Get(bytes.NewBuffer(v.sample))
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			res, _ := bm.Identify("", buf)
+			var results []core.Result
+			for r := range res {
+				results = append(results, r)
+			}
+			if !contains(results, v.expect) {
+				t.Errorf("%s engine: missing result, got: %v, expecting: %v", e, results, v.expect)
+			}
+		}
+	}
+	if err := config.SetEngine("memmem"); err == nil {
+		t.Error("expecting an error for an unknown engine")
+	}
+}
+
+func TestNearMisses(t *testing.T) {
+	bm, _, err := Add(nil, SignatureSet(tests.TestSignatures), nil)
+	if err != nil {
+		t.Error(err)
+	}
+	bufs := siegreader.New()
+	for _, v := range []struct {
+		sample []byte
+		expect []NearMiss
+	}{
+		{TestSample1, []NearMiss{{1, 2, 3, false}}}, // sig 1 is missing its [P 0-1:TEST] segment
+		{TestSample2, []NearMiss{}},
+		{[]byte("test12345678910YNESSjunk"), []NearMiss{{2, 1, 2, false}}},
+		{[]byte("junktest12345678910YNESS"), []NearMiss{{3, 1, 2, false}}},
+	} {
+		buf, err := bufs.Get(bytes.NewBuffer(v.sample))
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		got := bm.(*Matcher).NearMisses(buf)
+		if len(got) != len(v.expect) {
+			t.Errorf("%s: expecting near misses %v, got %v", v.sample, v.expect, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != v.expect[i] {
+				t.Errorf("%s: expecting near misses %v, got %v", v.sample, v.expect, got)
+			}
+		}
+	}
+	if s := (NearMiss{1, 2, 3, false}).Score(); s < 0.66 || s > 0.67 {
+		t.Errorf("bad score for missing segment: %f", s)
+	}
+	if s := (NearMiss{1, 3, 3, true}).Score(); s < 0.83 || s > 0.84 {
+		t.Errorf("bad score for shifted segments: %f", s)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bytematcher
+
+import (
+	"io"
+
+	"github.com/richardlehane/match/dwac"
+	"github.com/richardlehane/match/fwac"
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// engine is a multiple sequence matcher for a Matcher's BOF or EOF sequences.
+// Results with an Index of -1 are resume signals: the engine then waits for the sequences that are still
+// live on the returned channel, and scans the remainder of the input for those sequences only.
+type engine interface {
+	Index(io.ByteReader) (<-chan dwac.Result, chan<- []dwac.SeqIndex)
+}
+
+// newEngine builds the engine selected with config.SetEngine.
+func newEngine(seqs []dwac.Seq) engine {
+	switch config.ByteEngine() {
+	case config.StaticEngine:
+		return newStatic(seqs, false)
+	case config.LowMemEngine:
+		return newStatic(seqs, true)
+	}
+	return dwac.New(seqs) // config.DynamicEngine
+}
+
+// static adapts a wild Aho-Corasick tree, which scans for all its sequences in a single pass and never resumes.
+// It saves building a tree of live sequences for each resume, at the cost of testing all sequences to the end of the scan.
+type static struct {
+	wac fwac.Wac
+}
+
+func newStatic(seqs []dwac.Seq, lowMem bool) *static {
+	fseqs := make([]fwac.Seq, len(seqs))
+	for i, s := range seqs {
+		fseqs[i].MaxOffsets = s.MaxOffsets
+		fseqs[i].Choices = make([]fwac.Choice, len(s.Choices))
+		for j, c := range s.Choices {
+			fseqs[i].Choices[j] = fwac.Choice(c)
+		}
+	}
+	return &static{fwac.NewWac(lowMem, fseqs)}
+}
+
+func (s *static) Index(rdr io.ByteReader) (<-chan dwac.Result, chan<- []dwac.SeqIndex) {
+	in := s.wac.Index(rdr)
+	out, resume := make(chan dwac.Result), make(chan []dwac.SeqIndex)
+	go func() {
+		for r := range in {
+			if r.Index[0] == -1 { // drop progress results: they would be taken for resume signals
+				continue
+			}
+			out <- dwac.Result{Index: r.Index, Offset: r.Offset, Length: r.Length}
+		}
+		close(out)
+	}()
+	return out, resume
+}
@@ -17,14 +17,14 @@ package bytematcher
 import (
 	"fmt"
 
-	"github.com/richardlehane/match/dwac"
 	"github.com/richardlehane/siegfried/internal/siegreader"
 	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/core"
 )
 
 // identify function - brings a new matcher into existence
-func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan core.Result, hints ...core.Hint) {
+// if misses is non-nil, the scan is exhaustive and the partial matches of signatures are sent on it when the scan is complete
+func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan core.Result, misses chan<- map[int]*hitItem, hints ...core.Hint) {
 	buf.Quit = quit
 	waitSet := b.priorities.WaitSet(hints...)
 	maxBOF, maxEOF := b.maxBOF, b.maxEOF
@@ -40,7 +40,20 @@ func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan co
 			maxBOF, maxEOF = waitSet.MaxOffsets()
 		}
 	}
-	incoming, resume := b.scorer(buf, waitSet, quit, r)
+	// with a max bytes limit, cap the BOF scan (signatures anchored to the EOF are still checked)
+	var capped bool
+	if mb := config.MaxBytes(); mb > 0 && (maxBOF < 0 || maxBOF > mb) {
+		maxBOF, capped = mb, true
+	}
+	incoming, resume := b.scorer(buf, waitSet, quit, r, misses)
+	// done closes incoming, marking the buffer as limited if the capped scan stopped short of its end
+	// (the scorer unmarks it if the matcher was satisfied)
+	done := func() {
+		if capped {
+			buf.Limited, _ = buf.CanSeek(int64(maxBOF)+1, false)
+		}
+		close(incoming)
+	}
 	rdr := siegreader.LimitReaderFrom(buf, maxBOF)
 	// First test BOF frameset
 	bfchan := b.bofFrames.index(buf, false, quit)
@@ -58,7 +71,7 @@ func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan co
 	}
 	// start bof matcher if not yet started
 	b.bmu.Do(func() {
-		b.bAho = dwac.New(b.bofSeq.set)
+		b.bAho = newEngine(b.bofSeq.set)
 	})
 	var resuming bool
 	var bofOffset int64
@@ -83,6 +96,10 @@ func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan co
 		return
 	default:
 	}
+	// mark the end of the BOF scan (before the EOF scan forces a stream to be read in full)
+	if buf.Provisional {
+		incoming <- strike{idxa: -2}
+	}
 	// check the EOF
 	if maxEOF != 0 {
 		_, _ = buf.CanSeek(0, true) // force a full read to enable EOF scan to proceed for streams
@@ -96,7 +113,7 @@ func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan co
 		}
 		// EOF sequences
 		b.emu.Do(func() {
-			b.eAho = dwac.New(b.eofSeq.set)
+			b.eAho = newEngine(b.eofSeq.set)
 		})
 		rrdr := siegreader.LimitReverseReaderFrom(buf, maxEOF)
 		echan, erchan := b.eAho.Index(rrdr) // todo: handle the possibility of wild EOF segments
@@ -123,7 +140,7 @@ func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan co
 		}
 	}
 	if !resuming {
-		close(incoming)
+		done()
 		return
 	}
 	// Finally, finish BOF scan looking for wilds only
@@ -137,5 +154,5 @@ func (b *Matcher) identify(buf *siegreader.Buffer, quit chan struct{}, r chan co
 		}
 		incoming <- strike{b.bofSeq.testTreeIndex[br.Index[0]], br.Index[1], br.Offset, br.Length, false, false}
 	}
-	close(incoming)
+	done()
 }
@@ -405,8 +405,8 @@ func checkRelated(thisKf, prevKf, nextKf keyFrame, thisOff, prevOff [][2]int64)
 		return thisOff, make([]int, len(thisOff)), true
 	case frames.EOF, frames.SUCC:
 		if prevKf.typ == frames.SUCC && !(prevKf.seg.pMax == -1 && prevKf.seg.pMin == 0) {
-			ret := make([][2]int64, 0, len(thisOff))
-			idx := make([]int, 0, len(prevOff))
+			var ret [][2]int64 // grown on demand: thisOff can be large, but few offsets are usually related
+			var idx []int
 			success := false
 			for _, v := range thisOff {
 				for i, v1 := range prevOff {
@@ -434,8 +434,8 @@ func checkRelated(thisKf, prevKf, nextKf keyFrame, thisOff, prevOff [][2]int64)
 		if thisKf.seg.pMax == -1 && thisKf.seg.pMin == 0 {
 			return thisOff, make([]int, len(thisOff)), true
 		}
-		ret := make([][2]int64, 0, len(thisOff))
-		idx := make([]int, 0, len(prevOff))
+		var ret [][2]int64
+		var idx []int
 		success := false
 		for _, v := range thisOff {
 			for i, v1 := range prevOff {
@@ -16,6 +16,7 @@ package bytematcher
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/richardlehane/siegfried/internal/priority"
 	"github.com/richardlehane/siegfried/internal/siegreader"
@@ -175,6 +176,38 @@ type partial struct {
 	rdistances []int
 }
 
+// scratch is the working space of a scorer: its hit and strike caches and the slices used by testStrike.
+// Scratch space is pooled so that scanning many small files doesn't allocate it afresh for each.
+type scratch struct {
+	hits     map[int]*hitItem
+	strikes  map[int]*strikeItem
+	res      []kfHit
+	partials []partial
+}
+
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		return &scratch{
+			hits:     make(map[int]*hitItem),
+			strikes:  make(map[int]*strikeItem),
+			res:      make([]kfHit, 0, 10),
+			partials: make([]partial, 0, 10),
+		}
+	},
+}
+
+func putScratch(sc *scratch) {
+	for k := range sc.hits {
+		delete(sc.hits, k)
+	}
+	for k := range sc.strikes {
+		delete(sc.strikes, k)
+	}
+	sc.res = sc.res[:0]
+	sc.partials = sc.partials[:0]
+	scratchPool.Put(sc)
+}
+
 // result is the bytematcher implementation of the Result interface.
 type result struct {
 	index int
@@ -189,11 +222,11 @@ func (r result) Basis() string {
 	return r.basis
 }
 
-func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q chan struct{}, r chan<- core.Result) (chan<- strike, <-chan []keyFrameID) {
+func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q chan struct{}, r chan<- core.Result, misses chan<- map[int]*hitItem) (chan<- strike, <-chan []keyFrameID) {
 	incoming := make(chan strike)
 	resume := make(chan []keyFrameID)
-	hits := make(map[int]*hitItem)
-	strikes := make(map[int]*strikeItem)
+	sc := scratchPool.Get().(*scratch)
+	hits, strikes := sc.hits, sc.strikes
 
 	var bof int64
 	var eof int64
@@ -259,7 +292,8 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 		}
 		// grab the relevant testTree
 		t := b.tests[st.idxa+st.idxb]
-		res := make([]kfHit, 0, 10)
+		// res is re-used between calls: callers must be done with the last result before testing another strike
+		res := sc.res[:0]
 		// immediately apply key frames for the completes
 		for _, kf := range t.complete {
 			if b.keyFrames[kf[0]][kf[1]].check(st.offset) && waitSet.Check(kf[0]) {
@@ -268,6 +302,7 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 		}
 		// if there are no incompletes, we are done
 		if len(t.incomplete) < 1 {
+			sc.res = res
 			return res
 		}
 		// see what incompletes are worth pursuing
@@ -286,6 +321,7 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 			}
 		}
 		if !checkl && !checkr {
+			sc.res = res
 			return res
 		}
 		// calculate the offset and lengths for the left and right test slices
@@ -308,7 +344,13 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 			}
 		}
 		//  the partials slice has a mirror entry for each of the testTree incompletes
-		partials := make([]partial, len(t.incomplete))
+		if cap(sc.partials) < len(t.incomplete) {
+			sc.partials = make([]partial, len(t.incomplete))
+		}
+		partials := sc.partials[:len(t.incomplete)]
+		for i := range partials {
+			partials[i] = partial{}
+		}
 		// test left (if there are valid left tests to try)
 		if checkl {
 			if st.reverse {
@@ -366,6 +408,7 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 				}
 			}
 		}
+		sc.res = res
 		return res
 	}
 
@@ -391,12 +434,31 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 		return searchPartials(h.partials, kfs)
 	}
 
+	// drain tests any strikes still cached, so that the partial matches of unmatched signatures are complete
+	drain := func() map[int]*hitItem {
+		for _, s := range strikes {
+			for s.hasPotential() {
+				for _, k := range testStrike(s.pop()) {
+					if h, ok := hits[k.id[0]]; ok && !h.matched {
+						h.partials[k.id[1]] = append(h.partials[k.id[1]], [2]int64{k.offset, int64(k.length)})
+					}
+				}
+			}
+		}
+		return hits
+	}
+
 	go func() {
 		for in := range incoming {
 			// if we've got a positive result, drain any remaining strikes from the matchers
 			if quitting {
 				continue
 			}
+			// HANDLE PROVISIONAL (sent in order with the results of earlier strikes)
+			if in.idxa == -2 {
+				r <- core.Provisional{}
+				continue
+			}
 			// HANDLE RESUME
 			if in.idxa == -1 {
 				w := waitSet.WaitingOn() // todo: this uses bof/eof which are less relevant I don't store progress
@@ -455,7 +517,7 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 					if match, basis := applyKeyFrame(k); match {
 						if waitSet.Check(k.id[0]) {
 							r <- result{k.id[0], basis}
-							if waitSet.PutAt(k.id[0], bof, eof) {
+							if misses == nil && waitSet.PutAt(k.id[0], bof, eof) {
 								quit()
 								goto end
 							}
@@ -480,6 +542,14 @@ func (b *Matcher) scorer(buf *siegreader.Buffer, waitSet *priority.WaitSet, q ch
 			}
 		end: // keep looping until incoming is closed
 		}
+		if quitting {
+			buf.Limited = false
+		}
+		if misses != nil {
+			misses <- drain() // the hits escape, so this scratch space can't be re-used
+		} else {
+			putScratch(sc)
+		}
 		close(r)
 	}()
 	return incoming, resume
@@ -19,7 +19,7 @@ func setup() (chan<- strike, <-chan core.Result) {
 	buf, _ := bufs.Get(bytes.NewBuffer(TestSample1))
 	buf.SizeNow()
 	res := make(chan core.Result)
-	str, _ := bm.scorer(buf, bm.priorities.WaitSet(), make(chan struct{}), res)
+	str, _ := bm.scorer(buf, bm.priorities.WaitSet(), make(chan struct{}), res, nil)
 	return str, res
 }
 
@@ -59,7 +59,7 @@ func TestIssue94(t *testing.T) {
 	buf, _ := bufs.Get(bytes.NewBuffer(sheetPDF))
 	buf.SizeNow()
 	res := make(chan core.Result)
-	incoming := bm.scorer(buf, bm.priorities.WaitSet(), make(chan struct{}), res)
+	incoming := bm.scorer(buf, bm.priorities.WaitSet(), make(chan struct{}), res, nil)
 	incoming <- strike{0, 0, 0, 2, false, false}
 	if r := <-res; r.Index() != 0 {
 		t.Errorf("expecing result %d, got %d", 0, r.Index())
@@ -21,9 +21,12 @@ import (
 	"crypto/sha512"
 	"hash"
 	"hash/crc32"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
 )
 
-const HashChoices = "'md5', 'sha1', 'sha256', 'sha512', 'crc'"
+const HashChoices = "'md5', 'sha1', 'sha256', 'sha512', 'blake2b', 'crc'"
 
 type HashTyp int
 
@@ -33,6 +36,7 @@ const (
 	sha256Hash
 	sha512Hash
 	crcHash
+	blake2bHash // BLAKE2b-512
 )
 
 func GetHash(typ string) HashTyp {
@@ -48,10 +52,30 @@ func GetHash(typ string) HashTyp {
 		return sha512Hash
 	case "crc", "CRC":
 		return crcHash
+	case "blake2b", "BLAKE2B":
+		return blake2bHash
 	}
 	return -1
 }
 
+// GetHashes parses a comma separated list of hash types e.g. md5,sha256. It returns nil if any of the types are invalid, or repeated.
+func GetHashes(typs string) []HashTyp {
+	var ret []HashTyp
+	for _, v := range strings.Split(typs, ",") {
+		h := GetHash(strings.TrimSpace(v))
+		if h < 0 {
+			return nil
+		}
+		for _, e := range ret {
+			if e == h {
+				return nil
+			}
+		}
+		ret = append(ret, h)
+	}
+	return ret
+}
+
 func MakeHash(typ HashTyp) hash.Hash {
 	switch typ {
 	case md5Hash:
@@ -64,6 +88,9 @@ func MakeHash(typ HashTyp) hash.Hash {
 		return sha512.New()
 	case crcHash:
 		return crc32.NewIEEE()
+	case blake2bHash:
+		h, _ := blake2b.New512(nil) // only errors for bad keys
+		return h
 	}
 	return nil
 }
@@ -80,6 +107,8 @@ func (typ HashTyp) String() string {
 		return "sha512"
 	case crcHash:
 		return "crc"
+	case blake2bHash:
+		return "blake2b"
 	}
 	return ""
 }
@@ -0,0 +1,51 @@
+package checksum
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestMakeHash(t *testing.T) {
+	for _, v := range []struct{ typ, sum string }{
+		{"md5", "900150983cd24fb0d6963f7d28e17f72"},
+		{"sha1", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sha512", "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{"crc", "352441c2"},
+		{"blake2b", "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+	} {
+		typ := GetHash(v.typ)
+		if typ.String() != v.typ {
+			t.Fatalf("%s: got hash type %s", v.typ, typ)
+		}
+		h := MakeHash(typ)
+		h.Write([]byte("abc"))
+		if got := hex.EncodeToString(h.Sum(nil)); got != v.sum {
+			t.Errorf("%s of abc: expecting %s, got %s", v.typ, v.sum, got)
+		}
+	}
+}
+
+func TestGetHashes(t *testing.T) {
+	for _, v := range []struct {
+		typs   string
+		expect []HashTyp
+	}{
+		{"md5", []HashTyp{md5Hash}},
+		{"md5, sha256,BLAKE2B", []HashTyp{md5Hash, sha256Hash, blake2bHash}},
+		{"md5,md5", nil},
+		{"md5,sha3", nil},
+		{"", nil},
+	} {
+		got := GetHashes(v.typs)
+		if len(got) != len(v.expect) || (got == nil) != (v.expect == nil) {
+			t.Errorf("%q: expecting %v, got %v", v.typs, v.expect, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != v.expect[i] {
+				t.Errorf("%q: expecting %v, got %v", v.typs, v.expect, got)
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package containermatcher
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 
@@ -40,7 +41,7 @@ func (m Matcher) Identify(n string, b *siegreader.Buffer, hints ...core.Hint) (c
 				close(res)
 				return res, err
 			}
-			go c.identify(n, rdr, res, divhints[i]...)
+			go c.identify(b.Context(), n, rdr, res, divhints[i]...)
 			return res, nil
 		}
 	}
@@ -110,6 +111,7 @@ type identifier struct {
 	ruledOut     []bool  // mark additional signatures as negatively matched
 	waitSet      *priority.WaitSet
 	hits         []hit // shared buffer of hits used when matching
+	matched      []int // signatures with all parts matched, in the order they were matched
 	result       bool
 }
 
@@ -119,11 +121,13 @@ func (c *ContainerMatcher) newIdentifier(numParts int, hints ...core.Hint) *iden
 		make([]bool, numParts),
 		c.priorities.WaitSet(hints...),
 		make([]hit, 0, 1),
+		nil,
 		false,
 	}
 }
 
-func (c *ContainerMatcher) identify(n string, rdr Reader, res chan core.Result, hints ...core.Hint) {
+// identify stops reading the container's members if the context is done
+func (c *ContainerMatcher) identify(ctx context.Context, n string, rdr Reader, res chan core.Result, hints ...core.Hint) {
 	// safe to call on a nil matcher (i.e. container matching switched off)
 	if c == nil {
 		close(res)
@@ -132,6 +136,9 @@ func (c *ContainerMatcher) identify(n string, rdr Reader, res chan core.Result,
 	id := c.newIdentifier(len(c.parts), hints...)
 	var err error
 	for err = rdr.Next(); err == nil; err = rdr.Next() {
+		if ctx.Err() != nil {
+			break
+		}
 		ct, ok := c.nameCTest[rdr.Name()]
 		if !ok {
 			continue
@@ -142,10 +149,19 @@ func (c *ContainerMatcher) identify(n string, rdr Reader, res chan core.Result,
 		// name has matched, let's test the CTests
 		// ct.identify will generate a slice of hits which pass to
 		// processHits which will return true if we can stop
-		if c.processHits(ct.identify(c, id, rdr, rdr.Name()), id, ct, rdr.Name(), res) {
+		if c.processHits(ct.identify(c, id, rdr, rdr.Name()), id, ct, rdr.Name()) {
 			break
 		}
 	}
+	// send the matched signatures that aren't outranked by another match: if there is more than one, the match is ambiguous
+	for _, m := range id.matched {
+		if c.outranked(m, id.matched) {
+			continue
+		}
+		idx, _ := c.priorities.Index(m)
+		res <- toResult(c.startIndexes[idx], id.partsMatched[m])
+		id.result = true // mark id as having a result (for zip default)
+	}
 	// send a default hit if no result and extension matches
 	if c.extension != "" && !id.result && filepath.Ext(n) == "."+c.extension {
 		res <- defaultHit(-1 - int(c.conType))
@@ -185,7 +201,7 @@ func (ct *cTest) identify(c *ContainerMatcher, id *identifier, rdr Reader, name
 
 // process the hits from the ctest: adding hits to the parts matched, checking priorities
 // return true if satisfied and can quit
-func (c *ContainerMatcher) processHits(hits []hit, id *identifier, ct *cTest, name string, res chan core.Result) bool {
+func (c *ContainerMatcher) processHits(hits []hit, id *identifier, ct *cTest, name string) bool {
 	// if there are no hits, rule out any sigs in the ctest
 	if len(hits) == 0 {
 		for _, v := range ct.satisfied {
@@ -198,16 +214,8 @@ func (c *ContainerMatcher) processHits(hits []hit, id *identifier, ct *cTest, na
 	}
 	for _, h := range hits {
 		id.partsMatched[h.id] = append(id.partsMatched[h.id], h)
-		if len(id.partsMatched[h.id]) == c.parts[h.id] {
-			if id.waitSet.Check(h.id) {
-				idx, _ := c.priorities.Index(h.id)
-				res <- toResult(c.startIndexes[idx], id.partsMatched[h.id]) // send a Result here
-				id.result = true                                            // mark id as having a result (for zip default)
-				// set a priority list and return early if can
-				if id.waitSet.Put(h.id) {
-					return true
-				}
-			}
+		if len(id.partsMatched[h.id]) == c.parts[h.id] && id.waitSet.Check(h.id) {
+			id.matched = append(id.matched, h.id)
 		}
 	}
 	// if nothing ruled out by this test, then we must continue
@@ -225,20 +233,37 @@ func (c *ContainerMatcher) processHits(hits []hit, id *identifier, ct *cTest, na
 			id.ruledOut[v] = true
 		}
 	}
-	// if we haven't got a waitList yet, then we should return false
-	waitingOn := id.waitSet.WaitingOn()
-	if waitingOn == nil {
+	return c.settled(id)
+}
+
+// settled reports whether matching can stop: there is at least one match and every other signature is either
+// ruled out, matched, outranked by a match or excluded by hints.
+// Signatures that have no priority relationship with the matches must be tested so that ambiguous containers report all candidates.
+func (c *ContainerMatcher) settled(id *identifier) bool {
+	if len(id.matched) == 0 {
 		return false
 	}
-	// loop over the wait list, seeing if they are all ruled out
-	for _, v := range waitingOn {
-		if !id.ruledOut[v] {
-			return false
+	for i := range c.parts {
+		if id.ruledOut[i] || len(id.partsMatched[i]) == c.parts[i] || c.outranked(i, id.matched) || !id.waitSet.Check(i) {
+			continue
 		}
+		return false
 	}
 	return true
 }
 
+// outranked reports whether any of the matched signatures has priority over signature i
+func (c *ContainerMatcher) outranked(i int, matched []int) bool {
+	for _, sup := range c.priorities.Superiors(i) {
+		for _, m := range matched {
+			if m == sup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // eliminate duplicate hits - must do this since rely on number of matches for each sig as test for full match
 func (id *identifier) checkHits(i int) bool {
 	for _, h := range id.hits {
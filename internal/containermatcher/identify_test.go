@@ -8,6 +8,7 @@ import (
 
 	"github.com/richardlehane/siegfried/internal/bytematcher/frames"
 	"github.com/richardlehane/siegfried/internal/bytematcher/frames/tests"
+	"github.com/richardlehane/siegfried/internal/priority"
 	"github.com/richardlehane/siegfried/internal/siegreader"
 	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/core"
@@ -70,3 +71,22 @@ func TestIdentify(t *testing.T) {
 		}
 	}
 }
+
+func TestOutranked(t *testing.T) {
+	c := &ContainerMatcher{parts: []int{1, 1, 1}, priorities: &priority.Set{}}
+	c.priorities.Add(priority.List{{1}, {}, {}}, 3, 0, 0) // signature 1 has priority over signature 0
+	id := c.newIdentifier(3)
+	id.partsMatched[0], id.partsMatched[1] = []hit{{0, "a", ""}}, []hit{{1, "b", ""}}
+	id.matched = []int{0, 1}
+	if !c.outranked(0, id.matched) || c.outranked(1, id.matched) || c.outranked(2, id.matched) {
+		t.Error("bad outranked: expecting only signature 0 to be outranked")
+	}
+	// signature 2 has no priority relationship with the matches: it must be tested before matching stops
+	if c.settled(id) {
+		t.Error("expecting identification to continue while signature 2 is untested")
+	}
+	id.ruledOut[2] = true
+	if !c.settled(id) {
+		t.Error("expecting identification to be settled")
+	}
+}
@@ -517,6 +517,32 @@ type noXML struct{ Parseable }
 
 func (nx noXML) XMLs() ([][2]string, []string) { return nil, nil }
 
+// xmlNamespaces adds XML signatures from a map of namespaces (or DOCTYPE identifiers) to format IDs.
+// Format IDs that aren't in the Parseable are ignored.
+type xmlNamespaces struct {
+	Parseable
+	m map[string]string
+}
+
+func (xn xmlNamespaces) XMLs() ([][2]string, []string) {
+	xmls, ids := xn.Parseable.XMLs()
+	known := make(map[string]bool)
+	for _, id := range xn.IDs() {
+		known[id] = true
+	}
+	nss := make([]string, 0, len(xn.m))
+	for ns := range xn.m {
+		nss = append(nss, ns)
+	}
+	sort.Strings(nss)
+	for _, ns := range nss {
+		if known[xn.m[ns]] {
+			xmls, ids = append(xmls, [2]string{"", ns}), append(ids, xn.m[ns])
+		}
+	}
+	return xmls, ids
+}
+
 type noByte struct{ Parseable }
 
 func (nb noByte) Signatures() ([]frames.Signature, []string, error) { return nil, nil, nil }
@@ -581,6 +607,11 @@ func (s sorted) Signatures() ([]frames.Signature, []string, error) {
 }
 
 func ApplyConfig(p Parseable) Parseable {
+	// MIME types in the limit or exclude sets select formats by their MIME types (before any are dropped with -nomime)
+	var mimes, mids []string
+	if config.HasLimit() || config.HasExclude() {
+		mimes, mids = p.MIMEs()
+	}
 	if config.NoName() {
 		p = noName{p}
 	}
@@ -589,6 +620,8 @@ func ApplyConfig(p Parseable) Parseable {
 	}
 	if config.NoXML() {
 		p = noXML{p}
+	} else if m := config.XMLNamespaces(); len(m) > 0 {
+		p = xmlNamespaces{p, m}
 	}
 	if config.NoByte() {
 		p = noByte{p}
@@ -610,13 +643,7 @@ func ApplyConfig(p Parseable) Parseable {
 		p = Mirror{p}
 	}
 	if config.HasLimit() || config.HasExclude() {
-		ids := p.IDs()
-		if config.HasLimit() {
-			ids = config.Limit(ids)
-		} else {
-			ids = config.Exclude(ids)
-		}
-		p = Filter(ids, p)
+		p = Filter(config.Select(p.IDs(), mimes, mids), p)
 	}
 	// Sort Parseable so runs of signatures are contiguous.
 	p = sorted{p}
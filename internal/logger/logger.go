@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/richardlehane/siegfried/internal/chart"
@@ -34,15 +35,18 @@ const (
 	errString  = "[ERROR]"
 	warnString = "[WARN]"
 	timeString = "[TIME]"
+	skipString = "[SKIP]"
 )
 
 // Logger logs characteristics of the matching process depending on options set by user.
 type Logger struct {
-	progress, e, warn, known, unknown bool
-	fmts                              map[string]bool
-	cht                               map[string]map[string]int
-	w                                 io.Writer
-	start                             time.Time
+	progress, e, warn, known, unknown, skip bool
+	fmts                                    map[string]bool
+	cht                                     map[string]map[string]int
+	w                                       io.Writer
+	start                                   time.Time
+	m                                       *meter
+	f                                       *os.File // log file, if given
 	// mutate
 	fp bool
 }
@@ -54,13 +58,23 @@ func New(opts string) (*Logger, error) {
 		return lg, nil
 	}
 	var items []string
+	var kinds int // number of options that select what to log
 	for _, o := range strings.Split(opts, ",") {
+		switch {
+		case o == "stderr", o == "stdout", o == "out", o == "o", strings.HasSuffix(o, ".log"):
+		default:
+			kinds++
+		}
 		switch o {
 		case "stderr":
 		case "stdout", "out", "o":
 			lg.w = os.Stdout
 		case "progress", "p":
 			lg.progress = true
+		case "eta":
+			if lg.m == nil {
+				lg.m = newMeter(os.Stderr, IsTerminal(os.Stderr))
+			}
 		case "time", "t":
 			lg.start = time.Now()
 		case "error", "err", "e":
@@ -75,12 +89,29 @@ func New(opts string) (*Logger, error) {
 			lg.unknown = true
 		case "known", "k":
 			lg.known = true
+		case "skip":
+			lg.skip = true
 		case "chart", "c":
 			lg.cht = make(map[string]map[string]int)
 		default:
+			// log to a file e.g. -log error,errors.log
+			if strings.HasSuffix(o, ".log") {
+				if lg.f != nil {
+					lg.f.Close()
+				}
+				f, err := os.Create(o)
+				if err != nil {
+					return nil, err
+				}
+				lg.f, lg.w = f, f
+				continue
+			}
 			items = append(items, o)
 		}
 	}
+	if lg.f != nil && kinds == 0 {
+		lg.e = true // just a log file given: log errors, like the default
+	}
 	if len(items) > 0 {
 		lg.fmts = make(map[string]bool)
 		for _, v := range sets.Sets(items...) {
@@ -89,8 +120,15 @@ func New(opts string) (*Logger, error) {
 	}
 	if config.Debug() || config.Slow() {
 		lg.progress = false // progress reported internally
+		if lg.m != nil {
+			lg.m.close()
+			lg.m = nil
+		}
 		config.SetOut(lg.w)
 	}
+	if lg.m != nil && lg.m.tty && lg.w == os.Stderr {
+		lg.w = lg.m // clear the meter's line before logging
+	}
 	return lg, nil
 }
 
@@ -137,15 +175,44 @@ func (lg *Logger) Chart() {
 	fmt.Fprint(lg.w, chart.Chart("[Chart]", sections, fields, map[string]bool{}, lg.cht))
 }
 
+// Metering reports whether the logger is reporting progress with an ETA
+func (lg *Logger) Metering() bool {
+	return lg.m != nil
+}
+
+// AddTotal adds to the number of files expected by the progress meter.
+// Call Counted once the total is final so that an ETA can be given.
+func (lg *Logger) AddTotal(n int64) {
+	if lg.m != nil {
+		atomic.AddInt64(&lg.m.total, n)
+	}
+}
+
+// Counted signals that the total number of files expected is final.
+func (lg *Logger) Counted() {
+	if lg.m != nil {
+		atomic.StoreInt32(&lg.m.counted, 1)
+	}
+}
+
 // Close prints and chart and time elapsed
 func (lg *Logger) Close() {
+	if lg.m != nil {
+		lg.m.close()
+	}
 	lg.Chart()
 	lg.Elapsed()
+	if lg.f != nil {
+		lg.f.Close()
+	}
 }
 
 // Progress prints file name and resets.
 func (lg *Logger) Progress(p string) {
 	lg.fp = false
+	if lg.m != nil {
+		atomic.AddInt64(&lg.m.n, 1)
+	}
 	if lg.progress {
 		lg.fp = printFile(lg.fp, lg.w, p)
 	}
@@ -159,6 +226,14 @@ func (lg *Logger) Error(p string, e error) {
 	}
 }
 
+// Skip logs paths left out of a directory walk (e.g. by -exclude or -hidden=false) and the reason.
+// It is called by the walker, rather than the printer, so writes each entry in a single write.
+func (lg *Logger) Skip(p, reason string) {
+	if lg.skip {
+		fmt.Fprintf(lg.w, "%s %s (%s)\n", skipString, p, reason)
+	}
+}
+
 // IDs logs warnings, known, unknown and reports matches against supplied formats.
 func (lg *Logger) IDs(p string, ids []core.Identification) {
 	if !lg.warn && !lg.known && !lg.unknown && lg.fmts == nil && lg.cht == nil {
@@ -192,6 +267,9 @@ func (lg *Logger) IDs(p string, ids []core.Identification) {
 
 // helpers
 func abs(p string) string {
+	if strings.Contains(p, "://") { // URLs and cloud storage objects
+		return p
+	}
 	np, _ := filepath.Abs(p)
 	if np == "" {
 		return p
@@ -0,0 +1,140 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const meterString = "[PROGRESS]"
+
+// meter periodically reports the number of files processed, the rate and an ETA.
+// Counts are atomic so that the meter never blocks the printer.
+type meter struct {
+	w       io.Writer
+	tty     bool // overwrite a single line rather than printing a line per report
+	start   time.Time
+	n       int64 // files processed
+	total   int64 // files expected (grows while counting)
+	counted int32 // set when the total is final
+	mu      sync.Mutex
+	last    int // length of the line on the terminal (0 if cleared)
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newMeter(w io.Writer, tty bool) *meter {
+	m := &meter{
+		w:     w,
+		tty:   tty,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	interval := time.Second
+	if !tty {
+		interval = 10 * time.Second
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				m.report(false)
+			case <-m.stop:
+				m.report(true)
+				close(m.done)
+				return
+			}
+		}
+	}()
+	return m
+}
+
+func (m *meter) report(final bool) {
+	s := progress(atomic.LoadInt64(&m.n), atomic.LoadInt64(&m.total), atomic.LoadInt32(&m.counted) == 1, time.Since(m.start), final)
+	if !m.tty {
+		fmt.Fprintln(m.w, s)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// pad to clear the remainder of the previous line
+	pad := m.last - len(s)
+	m.last = len(s)
+	if pad < 0 {
+		pad = 0
+	}
+	if final {
+		fmt.Fprintf(m.w, "\r%s%s\n", s, strings.Repeat(" ", pad))
+		m.last = 0
+		return
+	}
+	fmt.Fprintf(m.w, "\r%s%s", s, strings.Repeat(" ", pad))
+}
+
+// progress formats a report of n files processed (of total, if counted) after elapsed time
+func progress(n, total int64, counted bool, elapsed time.Duration, final bool) string {
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(n) / elapsed.Seconds()
+	}
+	switch {
+	case final:
+		return fmt.Sprintf("%s %d files, %.1f files/s, elapsed %v", meterString, n, rate, elapsed.Round(time.Second))
+	case total == 0:
+		return fmt.Sprintf("%s %d files, %.1f files/s", meterString, n, rate)
+	case !counted:
+		return fmt.Sprintf("%s %d/%d+ files, %.1f files/s, ETA (counting)", meterString, n, total, rate)
+	}
+	eta := "-"
+	if rate > 0 && total > n {
+		eta = (time.Duration(float64(total-n)/rate) * time.Second).Round(time.Second).String()
+	}
+	pc := int64(100)
+	if n < total {
+		pc = n * 100 / total
+	}
+	return fmt.Sprintf("%s %d/%d files (%d%%), %.1f files/s, ETA %s", meterString, n, total, pc, rate, eta)
+}
+
+func (m *meter) close() {
+	close(m.stop)
+	<-m.done
+}
+
+// Write clears the meter's line before writing: the logger writes through the meter when both share a terminal,
+// so that log lines aren't interleaved with the meter's line (which is redrawn on the next report).
+func (m *meter) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last > 0 {
+		fmt.Fprintf(m.w, "\r%s\r", strings.Repeat(" ", m.last))
+		m.last = 0
+	}
+	return m.w.Write(p)
+}
+
+// IsTerminal reports whether a file is a terminal.
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f.Fd())
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	for _, v := range []struct {
+		n, total int64
+		counted  bool
+		elapsed  time.Duration
+		final    bool
+		expect   string
+	}{
+		{0, 0, false, 0, false, "[PROGRESS] 0 files, 0.0 files/s"},
+		{20, 0, false, 10 * time.Second, false, "[PROGRESS] 20 files, 2.0 files/s"},
+		{20, 50, false, 10 * time.Second, false, "[PROGRESS] 20/50+ files, 2.0 files/s, ETA (counting)"},
+		{20, 50, true, 10 * time.Second, false, "[PROGRESS] 20/50 files (40%), 2.0 files/s, ETA 15s"},
+		{0, 50, true, 10 * time.Second, false, "[PROGRESS] 0/50 files (0%), 0.0 files/s, ETA -"},
+		{60, 50, true, 10 * time.Second, false, "[PROGRESS] 60/50 files (100%), 6.0 files/s, ETA -"},
+		{60, 50, true, 10 * time.Second, true, "[PROGRESS] 60 files, 6.0 files/s, elapsed 10s"},
+	} {
+		if got := progress(v.n, v.total, v.counted, v.elapsed, v.final); got != v.expect {
+			t.Errorf("expecting %q, got %q", v.expect, got)
+		}
+	}
+}
+
+func TestMeterWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	m := &meter{w: buf, tty: true, start: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	m.report(false)
+	line := buf.String()
+	// a log line clears the meter's line first
+	fmt.Fprintln(m, "[ERROR] bad file")
+	expect := line + "\r" + strings.Repeat(" ", len(line)-1) + "\r[ERROR] bad file\n"
+	if buf.String() != expect {
+		t.Errorf("expecting %q, got %q", expect, buf.String())
+	}
+	// and the meter is redrawn without padding
+	buf.Reset()
+	m.report(false)
+	if strings.HasSuffix(buf.String(), " ") {
+		t.Errorf("expecting no padding after a log line, got %q", buf.String())
+	}
+	// a second log line doesn't clear again
+	buf.Reset()
+	m.last = 0
+	fmt.Fprintln(m, "[WARN] x")
+	if buf.String() != "[WARN] x\n" {
+		t.Errorf("expecting an unaltered log line, got %q", buf.String())
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	if IsTerminal(f) {
+		t.Error("expecting the null device not to be a terminal")
+	}
+}
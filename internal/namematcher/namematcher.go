@@ -166,6 +166,10 @@ func normalise(s string) (string, string) {
 			case "http", "https", "ftp", "mailto", "file", "data", "irc":
 				// grab the path (trims any trailing query string from the URL)
 				s = u.Path
+				// keep a fragment that is the path of a member of a container at the URL (e.g. a zip member when sf scans a URL with -z)
+				if strings.ContainsAny(u.Fragment, "#/.") {
+					s += "#" + u.Fragment
+				}
 			}
 		}
 	}
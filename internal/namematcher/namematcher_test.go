@@ -112,6 +112,12 @@ var fnames = []string{
 	"http://www.example.org/foo.html#bar",
 	"foo.html",
 	"html",
+	"https://example.org/disk.img#partition1#dir/README.md",
+	"README.md",
+	"md",
+	"https://example.org/files.zip#a.txt",
+	"files.zip#a.txt",
+	"txt",
 	"/root/corpora/ipres-systems-showcase-files/IAH-20080430204825-00000-blackbook.warc#20080430205011/http://www.archive.org/about/faq.php?faq_id=257",
 	"faq.php",
 	"php",
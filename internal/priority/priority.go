@@ -326,6 +326,24 @@ func (s *Set) list(i, j int) []int {
 	}
 }
 
+// Superiors returns the indexes of the signatures that have priority over signature i.
+// It returns nil if there is no priority list for i's priority set.
+func (s *Set) Superiors(i int) []int {
+	idx, prev := s.Index(i)
+	if idx < 0 {
+		return nil
+	}
+	l := s.list(idx, i-prev)
+	if l == nil {
+		return nil
+	}
+	ret := make([]int, len(l))
+	for j, v := range l {
+		ret[j] = v + prev
+	}
+	return ret
+}
+
 // at given BOF and EOF offsets, should we still wait on a given priority set?
 func (s *Set) await(idx int, bof, eof int64) bool {
 	if s.maxOffsets[idx][0] < 0 || (s.maxOffsets[idx][0] > 0 && int64(s.maxOffsets[idx][0]) >= bof) {
@@ -15,7 +15,9 @@
 package riffmatcher
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 
@@ -119,8 +121,9 @@ func Add(c core.Matcher, ss core.SignatureSet, p priority.List) (core.Matcher, i
 }
 
 type result struct {
-	idx int
-	cc  riff.FourCC
+	idx  int
+	cc   riff.FourCC
+	from string // for codec fourCCs, the chunk field the fourCC was read from
 }
 
 func (r result) Index() int {
@@ -128,11 +131,39 @@ func (r result) Index() int {
 }
 
 func (r result) Basis() string {
+	if r.from != "" {
+		return "fourCC matches " + string(r.cc[:]) + " (" + r.from + ")"
+	}
 	return "fourCC matches " + string(r.cc[:])
 }
 
+var (
+	strh = riff.FourCC{'s', 't', 'r', 'h'}
+	strf = riff.FourCC{'s', 't', 'r', 'f'}
+	vids = riff.FourCC{'v', 'i', 'd', 's'}
+)
+
+// codec reads a fourCC from a field of a chunk. It returns false if the field is empty or isn't printable (e.g. BI_RGB compression).
+func codec(data io.Reader, off int) (riff.FourCC, bool) {
+	var cc riff.FourCC
+	buf := make([]byte, off+4)
+	if _, err := io.ReadFull(data, buf); err != nil {
+		return cc, false
+	}
+	copy(cc[:], buf[off:])
+	for _, c := range cc {
+		if c < ' ' || c > '~' {
+			return cc, false
+		}
+	}
+	return cc, cc != riff.FourCC{' ', ' ', ' ', ' '}
+}
+
 func (m Matcher) Identify(na string, b *siegreader.Buffer, hints ...core.Hint) (chan core.Result, error) {
 	buf, err := b.Slice(0, 8)
+	if err == nil && string(buf[4:]) == "ftyp" {
+		return m.identifyBMFF(b, hints...), nil
+	}
 	if err != nil || buf[0] != 'R' || buf[1] != 'I' || buf[2] != 'F' || buf[3] != 'F' {
 		res := make(chan core.Result)
 		close(res)
@@ -149,7 +180,7 @@ func (m Matcher) Identify(na string, b *siegreader.Buffer, hints ...core.Hint) (
 	res := make(chan core.Result)
 	waitset := m.priorities.WaitSet(hints...)
 	// send and report if satisified
-	send := func(cc riff.FourCC) bool {
+	send := func(cc riff.FourCC, from string) bool {
 		if config.Debug() {
 			fmt.Fprintf(config.Out(), "riff match %s\n", string(cc[:]))
 		}
@@ -162,7 +193,7 @@ func (m Matcher) Identify(na string, b *siegreader.Buffer, hints ...core.Hint) (
 				if config.Debug() {
 					fmt.Fprintf(config.Out(), "sending riff match %s\n", string(cc[:]))
 				}
-				res <- result{hit, cc}
+				res <- result{hit, cc, from}
 				if waitset.Put(hit) {
 					return true
 				}
@@ -171,16 +202,32 @@ func (m Matcher) Identify(na string, b *siegreader.Buffer, hints ...core.Hint) (
 		return false
 	}
 	// riff walk
+	// As well as chunk IDs, the codecs of AVI streams are matched: the handler given in each stream header (strh)
+	// and, for video streams, the compression given in the stream format (strf) that follows it.
+	var video bool
 	var descend func(*riff.Reader) bool
 	descend = func(r *riff.Reader) bool {
 		for {
 			chunkID, chunkLen, chunkData, err := r.Next()
-			if err != nil || send(chunkID) {
+			if err != nil || send(chunkID, "") {
 				return true
 			}
-			if chunkID == riff.LIST {
+			switch chunkID {
+			case strh:
+				typ, _ := codec(chunkData, 0) // fccType
+				video = typ == vids
+				if cc, ok := codec(chunkData, 0); ok && send(cc, "stream handler") { // fccHandler follows fccType
+					return true
+				}
+			case strf:
+				if video {
+					if cc, ok := codec(chunkData, 16); ok && send(cc, "video compression") {
+						return true
+					}
+				}
+			case riff.LIST:
 				listType, list, err := riff.NewListReader(chunkLen, chunkData)
-				if err != nil || send(listType) {
+				if err != nil || send(listType, "") {
 					return true
 				}
 				if descend(list) {
@@ -191,7 +238,7 @@ func (m Matcher) Identify(na string, b *siegreader.Buffer, hints ...core.Hint) (
 	}
 	// go time
 	go func() {
-		if send(rcc) {
+		if send(rcc, "") {
 			close(res)
 			return
 		}
@@ -201,6 +248,83 @@ func (m Matcher) Identify(na string, b *siegreader.Buffer, hints ...core.Hint) (
 	return res, nil
 }
 
+// identifyBMFF matches the brands in the file type box of an ISO Base Media File Format file (e.g. MP4, MOV, HEIF, 3GP or JPEG 2000)
+// and the types of its top-level boxes (e.g. moov, mdat or meta). Brands and box types are fourCCs, so they share the matcher's signatures with RIFF.
+func (m Matcher) identifyBMFF(b *siegreader.Buffer, hints ...core.Hint) chan core.Result {
+	res := make(chan core.Result)
+	waitset := m.priorities.WaitSet(hints...)
+	uniqs := make(map[riff.FourCC]bool)
+	send := func(cc riff.FourCC, from string) bool {
+		if uniqs[cc] {
+			return false
+		}
+		uniqs[cc] = true
+		for _, hit := range m.riffs[cc] {
+			if waitset.Check(hit) {
+				if config.Debug() {
+					fmt.Fprintf(config.Out(), "sending BMFF match %s (%s)\n", string(cc[:]), from)
+				}
+				res <- result{hit, cc, from}
+				if waitset.Put(hit) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	go func() {
+		defer close(res)
+		var off int64
+		for {
+			hdr, err := b.Slice(off, 16)
+			if len(hdr) < 8 {
+				return
+			}
+			size := int64(binary.BigEndian.Uint32(hdr))
+			var typ riff.FourCC
+			copy(typ[:], hdr[4:8])
+			if off == 0 {
+				// ftyp: major brand, minor version, then compatible brands
+				if size < 16 || size > 1024 {
+					return
+				}
+				ftyp, err := b.Slice(0, int(size))
+				if err != nil && len(ftyp) < int(size) {
+					return
+				}
+				var major riff.FourCC
+				copy(major[:], ftyp[8:12])
+				if send(major, "major brand") {
+					return
+				}
+				for i := 16; i+4 <= len(ftyp); i += 4 {
+					var compat riff.FourCC
+					copy(compat[:], ftyp[i:i+4])
+					if send(compat, "compatible brand") {
+						return
+					}
+				}
+			} else if send(typ, "box") {
+				return
+			}
+			switch size {
+			case 0: // box extends to the end of the file
+				return
+			case 1: // 64-bit size follows the type
+				if err != nil || len(hdr) < 16 {
+					return
+				}
+				size = int64(binary.BigEndian.Uint64(hdr[8:]))
+			}
+			if size < 8 {
+				return
+			}
+			off += size
+		}
+	}()
+	return res
+}
+
 func (m Matcher) String() string {
 	keys := make([]string, 0, len(m.riffs))
 	for k := range m.riffs {
@@ -1,6 +1,8 @@
 package riffmatcher
 
 import (
+	"bytes"
+	"encoding/binary"
 	"flag"
 	"os"
 	"path/filepath"
@@ -51,6 +53,73 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func chunk(id string, data []byte) []byte {
+	c := append([]byte(id), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(c[4:], uint32(len(data)))
+	c = append(c, data...)
+	if len(data)%2 == 1 {
+		c = append(c, 0)
+	}
+	return c
+}
+
+func TestCodec(t *testing.T) {
+	strh := make([]byte, 56)
+	copy(strh, "vidsmjpg")
+	strf := make([]byte, 40)
+	copy(strf[16:], "MJPG")
+	strl := chunk("LIST", append([]byte("strl"), append(chunk("strh", strh), chunk("strf", strf)...)...))
+	avi := chunk("RIFF", append([]byte("AVI "), chunk("LIST", append([]byte("hdrl"), strl...))...))
+	m, _, _ := Add(nil, SignatureSet{{'A', 'V', 'I', ' '}, {'M', 'J', 'P', 'G'}, {'m', 'j', 'p', 'g'}}, nil)
+	b, _ := siegreader.New().Get(bytes.NewReader(avi))
+	res, err := m.Identify("", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bases []string
+	for r := range res {
+		bases = append(bases, r.Basis())
+	}
+	expect := []string{"fourCC matches AVI ", "fourCC matches mjpg (stream handler)", "fourCC matches MJPG (video compression)"}
+	if len(bases) != len(expect) {
+		t.Fatalf("expecting %v, got %v", expect, bases)
+	}
+	for i, v := range expect {
+		if bases[i] != v {
+			t.Errorf("expecting %q, got %q", v, bases[i])
+		}
+	}
+}
+
+func TestBMFF(t *testing.T) {
+	box := func(typ string, data []byte) []byte {
+		b := append([]byte{0, 0, 0, 0}, typ...)
+		binary.BigEndian.PutUint32(b, uint32(len(data)+8))
+		return append(b, data...)
+	}
+	mp4 := append(box("ftyp", []byte("mp42\x00\x00\x00\x00isommp42")), box("moov", nil)...)
+	mp4 = append(mp4, box("mdat", make([]byte, 8))...)
+	m, _, _ := Add(nil, SignatureSet{{'i', 's', 'o', 'm'}, {'m', 'd', 'a', 't'}, {'m', 'p', '4', '2'}, {'f', 't', 'y', 'p'}}, nil)
+	b, _ := siegreader.New().Get(bytes.NewReader(mp4))
+	res, err := m.Identify("", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bases []string
+	for r := range res {
+		bases = append(bases, r.Basis())
+	}
+	expect := []string{"fourCC matches mp42 (major brand)", "fourCC matches isom (compatible brand)", "fourCC matches mdat (box)"}
+	if len(bases) != len(expect) {
+		t.Fatalf("expecting %v, got %v", expect, bases)
+	}
+	for i, v := range expect {
+		if bases[i] != v {
+			t.Errorf("expecting %q, got %q", v, bases[i])
+		}
+	}
+}
+
 func TestIO(t *testing.T) {
 	str := rm.String()
 	saver := persist.NewLoadSaver(nil)
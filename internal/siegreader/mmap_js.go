@@ -1,15 +0,0 @@
-package siegreader
-
-func mmapable(sz int64) bool {
-	return false
-}
-
-func (m *mmap) mapFile() error {
-	var err error
-	return err
-}
-
-func (m *mmap) unmap() error {
-	var err error
-	return err
-}
@@ -0,0 +1,19 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package siegreader
+
+// files aren't memory mapped on platforms without mmap (e.g. js and plan9): they are read into big or small file buffers instead
+func mmapable(sz int64) bool {
+	return false
+}
+
+func (m *mmap) mapFile() error {
+	var err error
+	return err
+}
+
+func (m *mmap) unmap() error {
+	var err error
+	return err
+}
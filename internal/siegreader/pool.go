@@ -16,38 +16,20 @@ package siegreader
 
 import "sync"
 
-// pool of precons - just a simple free list
+// pool of precons - a sync.Pool so that idle buffers can be reclaimed by the GC
+// while busy scans of many small files re-use them rather than allocating afresh
 type pool struct {
-	mu   *sync.Mutex
-	fn   func() interface{}
-	head *item
-}
-
-type item struct {
-	next *item
-	val  interface{}
+	p *sync.Pool
 }
 
 func newPool(f func() interface{}) *pool {
-	return &pool{
-		mu: &sync.Mutex{},
-		fn: f,
-	}
+	return &pool{&sync.Pool{New: f}}
 }
 
 func (p *pool) get() interface{} {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.head == nil {
-		return p.fn()
-	}
-	ret := p.head.val
-	p.head = p.head.next
-	return ret
+	return p.p.Get()
 }
 
 func (p *pool) put(v interface{}) {
-	p.mu.Lock()
-	p.head = &item{p.head, v}
-	p.mu.Unlock()
+	p.p.Put(v)
 }
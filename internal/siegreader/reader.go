@@ -55,7 +55,7 @@ func (r *Reader) setBuf(o int64) error {
 }
 
 // ReadByte implements the io.ByteReader interface.
-// Checks the quit channel every 4096 bytes.
+// Checks the quit channel, and the Buffer's context, every 4096 bytes.
 func (r *Reader) ReadByte() (byte, error) {
 	if r.j >= len(r.scratch) {
 		if r.end {
@@ -65,6 +65,8 @@ func (r *Reader) ReadByte() (byte, error) {
 		select {
 		case <-r.Quit:
 			return 0, io.EOF
+		case <-r.done():
+			return 0, io.EOF
 		default:
 		}
 		err := r.setBuf(r.i)
@@ -207,6 +209,8 @@ func (r *ReverseReader) ReadByte() (byte, error) {
 		select {
 		case <-r.Quit:
 			return 0, io.EOF
+		case <-r.done():
+			return 0, io.EOF
 		default:
 		}
 		err := r.setBuf(r.i)
@@ -30,16 +30,20 @@
 package siegreader
 
 import (
+	"context"
 	"errors"
 	"io"
 
 	"github.com/richardlehane/characterize"
+
+	"github.com/richardlehane/siegfried/pkg/config"
 )
 
 var (
 	ErrEmpty     = errors.New("empty source")
 	ErrQuit      = errors.New("siegreader: quit chan closed while awaiting EOF")
 	ErrNilBuffer = errors.New("siegreader: attempt to SetSource on a nil buffer")
+	ErrDiscarded = errors.New("siegreader: bytes discarded from a stream too big to buffer without a temp file")
 )
 
 const (
@@ -49,6 +53,7 @@ const (
 	wheelSz         = readSz * 16
 	smallFileSz     = readSz * 16
 	streamSz        = smallFileSz * 1024
+	tailSz          = smallFileSz * 256 // window kept at the end of a stream that exceeds streamSz, if temp files are disabled
 )
 
 type bufferSrc interface {
@@ -62,12 +67,41 @@ type bufferSrc interface {
 // Buffer allows multiple readers to read from the same source.
 // Readers include reverse (from EOF) and limit readers.
 type Buffer struct {
-	Quit   chan struct{} // when this channel is closed, readers will return io.EOF
+	Quit    chan struct{} // when this channel is closed, readers will return io.EOF
+	Limited bool          // set by the bytematcher if it stopped short of the end of the Buffer at config.MaxBytes without being satisfied
+	// Provisional asks the bytematcher to send a core.Provisional result once it has scanned the start of the Buffer
+	Provisional bool
+	// Window asks a stream too big to buffer in memory to keep a window of its last bytes, rather than copy them to a temp file
+	// (as if temp files were disabled: see config.NoTemp). It must be set before the stream is read beyond the first bytes.
+	Window bool
+	ctx    context.Context
 	texted bool
 	text   characterize.CharType
+	cs     string
 	bufferSrc
 }
 
+// SetContext sets a context for the Buffer: when it is done, readers return io.EOF (as if the Quit channel were closed).
+func (b *Buffer) SetContext(ctx context.Context) {
+	b.ctx = ctx
+}
+
+// Context returns the Buffer's context (the background context if none has been set).
+func (b *Buffer) Context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
+// done returns the channel that is closed when the Buffer's context is done (nil if there is no context)
+func (b *Buffer) done() <-chan struct{} {
+	if b.ctx == nil {
+		return nil
+	}
+	return b.ctx.Done()
+}
+
 // Bytes returns a byte slice for a full read of the buffered file or stream.
 // Returns nil on error
 func (b *Buffer) Bytes() []byte {
@@ -83,19 +117,42 @@ func (b *Buffer) Bytes() []byte {
 	return s
 }
 
-// Text returns the CharType of the first 4096 bytes of the Buffer.
+// Text returns the CharType of the start of the Buffer.
+// The size of the sample, and the thresholds for text, are config settings (see config.TextSample).
 func (b *Buffer) Text() characterize.CharType {
 	if b.texted {
 		return b.text
 	}
 	b.texted = true
-	buf, err := b.Slice(0, readSz)
+	buf, err := b.Slice(0, config.TextSample())
 	if err == nil || err == io.EOF {
-		b.text = characterize.Detect(buf)
+		b.text = detect(buf)
+		b.cs = charset(buf, b.text)
 	}
 	return b.text
 }
 
+// Charset returns the IANA name of the character encoding of the start of the Buffer (e.g. UTF-8, UTF-16LE, Shift_JIS).
+// It returns an empty string if the Buffer is binary data.
+// Charset may name an encoding that Text doesn't recognise (UTF-32, and UTF-16 without a byte order mark): Text reports these as characterize.DATA.
+func (b *Buffer) Charset() string {
+	b.Text()
+	return b.cs
+}
+
+// Stream reports whether the Buffer is reading from a stream, rather than from a file or an external source with random access.
+func (b *Buffer) Stream() bool {
+	_, ok := b.bufferSrc.(*stream)
+	return ok
+}
+
+// Truncated reports whether bytes have been discarded from a stream because it was too big to buffer in full
+// and temp files are disabled (see config.SetNoTemp). A truncated stream can no longer be read from its start.
+func (b *Buffer) Truncated() bool {
+	s, ok := b.bufferSrc.(*stream)
+	return ok && s.truncated()
+}
+
 // Reader exposes a Reader for the Buffer.
 // This is to support external uses of this internal package.
 func (b *Buffer) Reader() *Reader {
@@ -10,6 +10,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/richardlehane/characterize"
+
+	"github.com/richardlehane/siegfried/pkg/config"
 )
 
 const testString = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -54,6 +58,86 @@ func (b *Buffer) setsmallfile() {
 	})
 }
 
+func TestText(t *testing.T) {
+	defer config.SetText("sample=4096,tolerance=0,ranges=ascii+latin1+extended")
+	// borderline files: a text file with a stray NUL byte, a text file with ISO-8859 accents and a text file whose
+	// only binary bytes come after the first 4096 bytes
+	stray := strings.Repeat("plain text ", 100) + "\x00"
+	accents := "caf\xe9 " + strings.Repeat("plain text ", 100)
+	late := strings.Repeat("plain text ", 400) + "\x00\x01\x02"
+	for _, v := range []struct {
+		settings string
+		content  string
+		expect   characterize.CharType
+	}{
+		{"", stray, characterize.DATA},
+		{"tolerance=0.01", stray, characterize.ASCII},
+		{"tolerance=0.0001", stray, characterize.DATA},
+		{"tolerance=0", accents, characterize.LATIN1},
+		{"ranges=ascii", accents, characterize.DATA},
+		{"tolerance=0.01", accents, characterize.ASCII}, // ranges=ascii still applies: the accent is a tolerated non-text byte
+		{"ranges=ascii+latin1", accents, characterize.LATIN1},
+		{"tolerance=0,ranges=ascii+latin1+extended", late, characterize.ASCII},
+		{"sample=8192", late, characterize.DATA},
+	} {
+		if v.settings != "" {
+			if err := config.SetText(v.settings); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b := setup(strings.NewReader(v.content), t)
+		if tt := b.Text(); tt != v.expect {
+			t.Errorf("text settings %q: expecting %v, got %v", v.settings, v.expect, tt)
+		}
+		bufs.Put(b)
+	}
+	if err := config.SetText("tolerance=2"); err == nil {
+		t.Error("expecting an error for a tolerance greater than 1")
+	}
+}
+
+func TestCharset(t *testing.T) {
+	utf32 := func(s string, big bool) string {
+		var buf []byte
+		for _, r := range s {
+			if big {
+				buf = append(buf, 0, byte(r>>16), byte(r>>8), byte(r))
+			} else {
+				buf = append(buf, byte(r), byte(r>>8), byte(r>>16), 0)
+			}
+		}
+		return string(buf)
+	}
+	utf16le := func(s string) string {
+		var buf []byte
+		for _, r := range s {
+			buf = append(buf, byte(r), byte(r>>8))
+		}
+		return string(buf)
+	}
+	for _, v := range []struct {
+		label, content, expect string
+	}{
+		{"ascii", "plain text", "US-ASCII"},
+		{"utf8", "ᚠᛇᚻ᛫ᛒᛦᚦ", "UTF-8"},
+		{"utf16 with BOM", "\xff\xfe" + utf16le("plain text"), "UTF-16LE"},
+		{"utf16 without BOM", utf16le("plain text"), "UTF-16LE"},
+		{"utf32le with BOM", utf32("\ufeffplain text", false), "UTF-32LE"},
+		{"utf32be with BOM", utf32("\ufeffplain text", true), "UTF-32BE"},
+		{"utf32le without BOM", utf32("plain text ᚠᛇᚻ", false), "UTF-32LE"},
+		{"latin1", "caf\xe9 au lait", "ISO-8859-1"},
+		{"windows-1252", "\x93quoted\x94 caf\xe9", "windows-1252"},
+		{"shift_jis", "\x93\xfa\x96\x7b\x8c\xea\x82\xcc\x83\x65\x83\x4c\x83\x58\x83\x67", "Shift_JIS"}, // 日本語のテキスト
+		{"binary", "\x00\x01\x02\x03\xff\xd8\xff\xe0", ""},
+	} {
+		b := setup(strings.NewReader(v.content), t)
+		if cs := b.Charset(); cs != v.expect {
+			t.Errorf("%s: expecting charset %q, got %q", v.label, v.expect, cs)
+		}
+		bufs.Put(b)
+	}
+}
+
 func TestStrSource(t *testing.T) {
 	r := strings.NewReader(testString)
 	b := setup(r, t)
@@ -91,6 +175,62 @@ func TestMMAPFile(t *testing.T) {
 	}
 }
 
+// TestMMAPBackend tests that a file is memory mapped, where the platform allows, once a read goes past the initial read; and unmapped when the buffer is returned
+func TestMMAPBackend(t *testing.T) {
+	tf, err := makeTmp(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tf.Name())
+	defer tf.Close()
+	b := setup(tf, t)
+	f := b.bufferSrc.(*file)
+	if !mmapable(f.sz) {
+		bufs.Put(b)
+		t.Skip("files aren't memory mapped on this platform")
+	}
+	expect := make([]byte, 100)
+	tf.ReadAt(expect, 50000)
+	if slc, err := b.Slice(50000, 100); err != nil || !bytes.Equal(slc, expect) {
+		t.Errorf("bad slice from a mapped file; got %v", err)
+	}
+	m, ok := f.data.(*mmap)
+	if !ok {
+		t.Fatalf("expecting a mapped file, got %T", f.data)
+	}
+	tf.ReadAt(expect, 100000-1000-100)
+	if slc, err := b.EofSlice(1000, 100); err != nil || !bytes.Equal(slc, expect) {
+		t.Errorf("bad EOF slice from a mapped file; got %v", err)
+	}
+	bufs.Put(b)
+	if m.buf != nil {
+		t.Error("expecting the file to be unmapped when the buffer is returned")
+	}
+}
+
+// TestMMAPFail tests that a file is read with a big file buffer if it can't be mapped.
+// The mapping fails because the file claims to be larger than the address space.
+func TestMMAPFail(t *testing.T) {
+	tf, err := makeTmp(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tf.Name())
+	defer tf.Close()
+	f := &file{sz: 1 << 50, src: tf, pool: bufs.fdatas}
+	d := bufs.fdatas.get(f)
+	defer bufs.fdatas.put(d)
+	bf, ok := d.(*bigfile)
+	if !ok {
+		t.Fatalf("expecting a big file when a file can't be mapped, got %T", d)
+	}
+	expect := make([]byte, 100)
+	tf.ReadAt(expect, 50000)
+	if slc := bf.slice(50000, 100); !bytes.Equal(slc, expect) {
+		t.Error("bad slice from a big file")
+	}
+}
+
 func TestBigFile(t *testing.T) {
 	f, err := os.Open(testBigFile)
 	defer f.Close()
@@ -298,3 +438,94 @@ func testBuffer(t *testing.T, checks int, tf *os.File, bs *Buffer) error {
 	}
 	return joinErrs(errs)
 }
+
+// patternReader reads sz bytes of a repeating pattern, without a backing file
+type patternReader struct{ off, sz int64 }
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.off >= p.sz {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.sz-p.off {
+		b = b[:p.sz-p.off]
+	}
+	for i := range b {
+		b[i] = byte((p.off + int64(i)) % 251)
+	}
+	p.off += int64(len(b))
+	return len(b), nil
+}
+
+func patternAt(off int64, l int) []byte {
+	b := make([]byte, l)
+	(&patternReader{off, off + int64(l)}).Read(b)
+	return b
+}
+
+func noTempStream(sz int64) *Buffer {
+	b := &Buffer{Quit: make(chan struct{})}
+	s := newStream().(*stream)
+	s.setSource(&patternReader{sz: sz}, b)
+	s.noTemp = true
+	b.bufferSrc = s
+	return b
+}
+
+func TestNoTempStream(t *testing.T) {
+	sz := int64(streamSz+2*tailSz) + 1234
+	// read sequentially, the window keeps up with the reader
+	b := noTempStream(sz)
+	rdr := ReaderFrom(b)
+	buf := make([]byte, 100000)
+	for off := int64(0); ; {
+		n, err := rdr.Read(buf)
+		if !bytes.Equal(buf[:n], patternAt(off, n)) {
+			t.Fatalf("bad read at %d", off)
+		}
+		off += int64(n)
+		if err != nil {
+			if err != io.EOF || off != sz {
+				t.Fatalf("expecting to read %d bytes, got %d, %v", sz, off, err)
+			}
+			break
+		}
+	}
+	// once read to the end, the start and end are kept but the middle is discarded
+	b = noTempStream(sz)
+	if b.SizeNow() != sz {
+		t.Fatalf("expecting a size of %d", sz)
+	}
+	if slc, err := b.EofSlice(0, eofSz); err != nil || !bytes.Equal(slc, patternAt(sz-int64(eofSz), eofSz)) {
+		t.Errorf("bad EOF slice: %v", err)
+	}
+	if !b.Truncated() || b.bufferSrc.(*stream).tf != nil {
+		t.Error("expecting a truncated stream without a temp file")
+	}
+	if slc, err := b.Slice(10, 100); err != nil || !bytes.Equal(slc, patternAt(10, 100)) {
+		t.Errorf("bad BOF slice: %v", err)
+	}
+	if slc, err := b.Slice(sz-int64(tailSz), tailSz); err != nil || !bytes.Equal(slc, patternAt(sz-int64(tailSz), tailSz)) {
+		t.Errorf("bad slice of the window: %v", err)
+	}
+	if _, err := b.Slice(int64(streamSz)+10, 100); err != ErrDiscarded {
+		t.Errorf("expecting ErrDiscarded, got %v", err)
+	}
+}
+
+// a Buffer that asks for a Window keeps a window at the end of a big stream, as if temp files were disabled
+func TestWindowStream(t *testing.T) {
+	sz := int64(streamSz+2*tailSz) + 1234
+	b := &Buffer{Quit: make(chan struct{}), Window: true}
+	s := newStream().(*stream)
+	s.setSource(&patternReader{sz: sz}, b)
+	b.bufferSrc = s
+	if b.SizeNow() != sz {
+		t.Fatalf("expecting a size of %d", sz)
+	}
+	if !b.Truncated() || s.tf != nil {
+		t.Error("expecting a truncated stream without a temp file")
+	}
+	if slc, err := b.EofSlice(0, eofSz); err != nil || !bytes.Equal(slc, patternAt(sz-int64(eofSz), eofSz)) {
+		t.Errorf("bad EOF slice: %v", err)
+	}
+}
@@ -5,16 +5,21 @@ import (
 	"io/ioutil"
 	"os"
 	"sync"
+
+	"github.com/richardlehane/siegfried/pkg/config"
 )
 
 type stream struct {
-	b     *Buffer
-	src   io.Reader
-	sz    int64
-	buf   []byte
-	tf    *os.File // temp backing file - used when stream exceeds streamSz
-	tfBuf []byte
-	eofc  chan struct{}
+	b      *Buffer
+	src    io.Reader
+	sz     int64
+	buf    []byte
+	tf     *os.File // temp backing file - used when stream exceeds streamSz
+	tfBuf  []byte
+	tail   []byte // window of the last bytes read - used instead of a temp file if noTemp or the Buffer asks for a Window
+	win    bool   // stream exceeded streamSz and is using the tail window
+	noTemp bool   // config.NoTemp() when the source was set
+	eofc   chan struct{}
 
 	mu  sync.Mutex
 	i   int // marks how much of buf we have filled
@@ -32,6 +37,8 @@ func (s *stream) setSource(src io.Reader, b *Buffer) error {
 	s.eofc = make(chan struct{})
 	s.i = 0
 	s.eof = false
+	s.win = false
+	s.noTemp = config.NoTemp()
 	_, err := s.fill()
 	return err
 }
@@ -53,6 +60,8 @@ func (s *stream) Size() int64 {
 		return s.sz
 	case <-s.b.Quit:
 		return 0
+	case <-s.b.done():
+		return 0
 	}
 }
 
@@ -64,14 +73,27 @@ func (s *stream) SizeNow() int64 {
 	return s.sz
 }
 
+// truncated reports whether bytes have been discarded from the middle of the stream
+func (s *stream) truncated() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.win && s.sz > int64(len(s.buf)+tailSz)
+}
+
 func (s *stream) grow() error {
-	if s.tf != nil { // return if we already have a temp file
+	if s.tf != nil || s.win { // return if we already have a temp file or window
 		return nil
 	}
 	c := cap(s.buf) * 2
 	if c > streamSz {
 		if cap(s.buf) < streamSz {
 			c = streamSz
+		} else if s.noTemp || s.b.Window { // if we've exceeded streamSz and temp files are disabled, keep a window of the last bytes read
+			if s.tail == nil {
+				s.tail = make([]byte, tailSz)
+			}
+			s.win = true
+			return nil
 		} else { // if we've exceeded streamSz, use a temp file to copy remainder
 			var err error
 			s.tf, err = ioutil.TempFile("", "siegfried")
@@ -90,12 +112,21 @@ func (s *stream) fill() (int64, error) {
 		return s.sz, io.EOF
 	}
 	// if we've run out of room in buf, & there is no backing file, grow the buffer
-	if len(s.buf)-readSz < s.i && s.tf == nil {
+	if len(s.buf)-readSz < s.i && s.tf == nil && !s.win {
 		s.grow()
 	}
 	// now let's read
 	var err error
-	if s.tf != nil {
+	if s.win {
+		// if we have a window, overwrite its oldest bytes
+		o := int((s.sz - int64(len(s.buf))) % int64(tailSz))
+		var i int
+		i, err = io.ReadFull(s.src, s.tail[o:o+readSz])
+		s.sz += int64(i)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+	} else if s.tf != nil {
 		// if we have a backing file, fill that
 		var wi int64
 		wi, err = io.CopyBuffer(s.tf, io.LimitReader(s.src, int64(readSz)), s.tfBuf)
@@ -156,6 +187,10 @@ func (s *stream) Slice(off int64, l int) ([]byte, error) {
 	if off+int64(l) <= int64(len(s.buf)) {
 		return s.buf[int(off) : int(off)+l], err
 	}
+	// bytes between buf and the window have been discarded
+	if s.win && off+int64(l) > int64(len(s.buf)) && off < s.sz-int64(tailSz) && s.sz > int64(len(s.buf)+tailSz) {
+		return nil, ErrDiscarded
+	}
 	ret := make([]byte, l)
 	// if slice crosses border, copy first bit from end of buf
 	var ci int
@@ -165,6 +200,14 @@ func (s *stream) Slice(off int64, l int) ([]byte, error) {
 	} else {
 		off -= int64(len(s.buf))
 	}
+	if s.win {
+		for ci < l {
+			n := copy(ret[ci:], s.tail[int(off%int64(tailSz)):])
+			ci += n
+			off += int64(n)
+		}
+		return ret, err
+	}
 	_, rerr := s.tf.ReadAt(ret[ci:], off)
 	if rerr != nil {
 		err = rerr
@@ -179,6 +222,8 @@ func (s *stream) EofSlice(o int64, l int) ([]byte, error) {
 	select {
 	case <-s.b.Quit:
 		return nil, ErrQuit
+	case <-s.b.done():
+		return nil, ErrQuit
 	case <-s.eofc:
 	}
 	if o >= s.sz {
@@ -0,0 +1,189 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package siegreader
+
+import (
+	"bytes"
+
+	"github.com/richardlehane/characterize"
+
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// detect classifies a sample as text or binary data. With the default config settings, this is characterize.Detect.
+// When some latin1 or extended bytes aren't text (config.TextRanges), samples that Detect classifies with those
+// ranges are binary data. When a non-text tolerance is set (config.TextTolerance), samples
+// that Detect classifies as binary data are re-tested, counting non-text bytes against the tolerance.
+func detect(buf []byte) characterize.CharType {
+	latin1, extended := config.TextRanges()
+	ct := characterize.Detect(buf)
+	if (ct == characterize.LATIN1 && !latin1) || (ct == characterize.EXTENDED && !extended) {
+		ct = characterize.DATA
+	}
+	tolerance := config.TextTolerance()
+	if ct != characterize.DATA || tolerance <= 0 || len(buf) == 0 {
+		return ct
+	}
+	var bad int
+	var hasLatin1, hasExtended bool
+	for _, c := range buf {
+		switch {
+		case c == 0x85 || (c >= 0x07 && c <= 0x0D) || c == 0x1B || (c >= 0x20 && c < 0x7F):
+		case c >= 0xA0 && latin1:
+			hasLatin1 = true
+		case c >= 0x80 && c < 0xA0 && extended:
+			hasExtended = true
+		default:
+			bad++
+		}
+	}
+	if float64(bad) > tolerance*float64(len(buf)) {
+		return characterize.DATA
+	}
+	switch {
+	case hasExtended:
+		return characterize.EXTENDED
+	case hasLatin1:
+		return characterize.LATIN1
+	}
+	return characterize.ASCII
+}
+
+// charset names the character encoding of a sample of type ct, using IANA charset names.
+// Detect doesn't recognise UTF-32, or UTF-16 without a byte order mark, so samples it classifies as binary data are tested for these encodings:
+// without a byte order mark, these samples must be mostly ASCII characters (padded with zero bytes) to rule out binary data.
+// ISO-8859 and extended-ASCII samples are tested for Shift_JIS and are otherwise reported as ISO-8859-1 and windows-1252
+// (the other parts of ISO-8859 can't be told apart from byte values alone).
+// An empty string is returned for binary data.
+func charset(buf []byte, ct characterize.CharType) string {
+	switch ct {
+	case characterize.ASCII:
+		return "US-ASCII"
+	case characterize.UTF7:
+		return "UTF-7"
+	case characterize.UTF8, characterize.UTF8BOM:
+		return "UTF-8"
+	case characterize.UTF16LE:
+		return "UTF-16LE"
+	case characterize.UTF16BE:
+		return "UTF-16BE"
+	case characterize.LATIN1, characterize.EXTENDED:
+		if shiftJIS(buf) {
+			return "Shift_JIS"
+		}
+		if ct == characterize.LATIN1 {
+			return "ISO-8859-1"
+		}
+		return "windows-1252"
+	case characterize.EBCDIC:
+		return "IBM037"
+	case characterize.EBCDICINT:
+		return "IBM500"
+	}
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xFF, 0xFE, 0, 0}) && unicodeText(buf[4:], 4, false, false):
+		return "UTF-32LE"
+	case bytes.HasPrefix(buf, []byte{0, 0, 0xFE, 0xFF}) && unicodeText(buf[4:], 4, true, false):
+		return "UTF-32BE"
+	case bytes.HasPrefix(buf, []byte{0xFF, 0xFE}) && unicodeText(buf[2:], 2, false, false):
+		return "UTF-16LE"
+	case bytes.HasPrefix(buf, []byte{0xFE, 0xFF}) && unicodeText(buf[2:], 2, true, false):
+		return "UTF-16BE"
+	case unicodeText(buf, 4, false, true):
+		return "UTF-32LE"
+	case unicodeText(buf, 4, true, true):
+		return "UTF-32BE"
+	case unicodeText(buf, 2, false, true):
+		return "UTF-16LE"
+	case unicodeText(buf, 2, true, true):
+		return "UTF-16BE"
+	}
+	return ""
+}
+
+// unicodeText reports whether buf is a sequence of UTF-16 (sz 2) or UTF-32 (sz 4) text characters.
+// A partial character at the end of a sample is ignored. If ascii is set, most characters must be in the ASCII range.
+func unicodeText(buf []byte, sz int, big, ascii bool) bool {
+	n := len(buf) / sz
+	if n == 0 {
+		return false
+	}
+	var low, surrogate int
+	for i := 0; i < n; i++ {
+		u := buf[i*sz : i*sz+sz]
+		var r rune
+		for j := range u {
+			if big {
+				r = r<<8 | rune(u[j])
+			} else {
+				r = r<<8 | rune(u[sz-1-j])
+			}
+		}
+		switch {
+		case r >= 0xD800 && r <= 0xDBFF && sz == 2:
+			if surrogate > 0 {
+				return false
+			}
+			surrogate = 1
+			continue
+		case r >= 0xDC00 && r <= 0xDFFF && sz == 2:
+			if surrogate == 0 {
+				return false
+			}
+			surrogate = 0
+			continue
+		case surrogate > 0, r > 0x10FFFF, r >= 0xD800 && r <= 0xDFFF, r == 0xFFFE:
+			return false
+		case r < 0x80:
+			if r < 0x20 && r != 0x1B && (r < 0x07 || r > 0x0D) || r == 0x7F {
+				return false
+			}
+			low++
+		case r < 0xA0:
+			return false
+		}
+	}
+	return !ascii || low*2 > n
+}
+
+// shiftJIS reports whether buf is Shift_JIS text. Text in the windows-1252 or ISO-8859 encodings may also be valid Shift_JIS,
+// but the accented letters of those encodings rarely occur side by side: most double-byte characters in the sample must be in runs.
+func shiftJIS(buf []byte) bool {
+	var pairs, runs int
+	var prev bool
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		switch {
+		case c < 0x80 || (c >= 0xA1 && c <= 0xDF): // ASCII and half-width katakana
+			prev = false
+		case (c >= 0x81 && c <= 0x9F) || (c >= 0xE0 && c <= 0xFC):
+			if i+1 == len(buf) { // a partial character at the end of the sample
+				break
+			}
+			if t := buf[i+1]; t < 0x40 || t == 0x7F || t > 0xFC {
+				return false
+			}
+			pairs++
+			if prev {
+				runs++
+			}
+			prev = true
+			i++
+		default:
+			return false
+		}
+	}
+	return pairs > 1 && runs*2 >= pairs
+}
@@ -0,0 +1,329 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlite writes SQLite database files (https://www.sqlite.org/fileformat.html).
+// It does just what siegfried needs to save results: it creates tables and appends rows to them.
+// There are no indexes, updates or deletes (indexes can be added later with the sqlite3 tool e.g. CREATE INDEX).
+// Rows are written to disk as pages fill, so a database can be much larger than memory.
+package sqlite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	pageSize  = 4096
+	lockByte  = 1 << 30                   // the page that contains this offset is reserved by SQLite and can't be used
+	maxLocal  = pageSize - 35             // the largest payload stored entirely on a table leaf page
+	minLocal  = (pageSize-12)*32/255 - 23 // the smallest part of a payload stored on a leaf page before spilling to overflow pages
+	maxCells  = (pageSize-12)/(4+9+2) + 1 // the number of children for an interior page: cells of a page number, a varint key and a cell pointer, plus the right-most pointer
+	leafFlag  = 0x0d                      // table b-tree leaf page
+	innerFlag = 0x05                      // table b-tree interior page
+	version   = 3040001                   // the SQLite version number to claim in the header
+	header    = "SQLite format 3\x00"
+)
+
+// DB is a SQLite database being written. Close must be called to complete the database.
+type DB struct {
+	w      io.WriteSeeker
+	pages  uint32 // number of pages allocated
+	tables []*Table
+	err    error
+}
+
+// Table is a table in a DB.
+type Table struct {
+	db       *DB
+	name     string
+	sql      string
+	rowid    int64
+	cells    [][]byte // cells in the current leaf page
+	size     int      // bytes used in the current leaf page
+	children []child  // leaf pages written so far
+	page     uint32   // the root page, set on Close
+}
+
+type child struct {
+	page uint32
+	key  int64 // the largest rowid in the page
+}
+
+// Create starts a new database. Page 1 holds the database header and schema and is written on Close.
+func Create(w io.WriteSeeker) *DB {
+	return &DB{w: w, pages: 1}
+}
+
+// Table adds a table to the database. The sql is the CREATE TABLE statement for the table,
+// its columns must match the values given to Insert.
+func (db *DB) Table(name, sql string) *Table {
+	t := &Table{db: db, name: name, sql: sql, size: 8}
+	db.tables = append(db.tables, t)
+	return t
+}
+
+// Insert appends a row to a table and returns its rowid. Values may be nil, int, int64, string or []byte.
+// Rowids start at 1: give nil for a column declared INTEGER PRIMARY KEY, as it is an alias for the rowid.
+func (t *Table) Insert(values ...interface{}) (int64, error) {
+	if t.db.err != nil {
+		return 0, t.db.err
+	}
+	rec, err := record(values)
+	if err != nil {
+		t.db.err = err
+		return 0, err
+	}
+	t.rowid++
+	cell := t.db.cell(t.rowid, rec)
+	if t.size+2+len(cell) > pageSize {
+		t.flush()
+	}
+	t.cells = append(t.cells, cell)
+	t.size += 2 + len(cell)
+	return t.rowid, t.db.err
+}
+
+// flush writes the current leaf page
+func (t *Table) flush() {
+	p := t.db.alloc()
+	t.db.write(p, page(0, leafFlag, t.cells, 0))
+	t.children = append(t.children, child{p, t.rowid - 1})
+	t.cells, t.size = t.cells[:0], 8
+}
+
+// root writes the remaining pages of a table's b-tree and returns the root page
+func (t *Table) root() uint32 {
+	if len(t.cells) > 0 || len(t.children) == 0 {
+		p := t.db.alloc()
+		t.db.write(p, page(0, leafFlag, t.cells, 0))
+		t.children = append(t.children, child{p, t.rowid})
+	}
+	level := t.children
+	for len(level) > 1 {
+		// spread children evenly over the pages of the next level, so that no interior page is left with a single child
+		n := (len(level) + maxCells - 1) / maxCells
+		per := (len(level) + n - 1) / n
+		next := make([]child, 0, n)
+		for len(level) > 0 {
+			l := per
+			if l > len(level) {
+				l = len(level)
+			}
+			cells := make([][]byte, l-1)
+			for i, c := range level[:l-1] {
+				cells[i] = be(nil, uint64(c.page), 4)
+				cells[i] = append(cells[i], varint(uint64(c.key))...)
+			}
+			p := t.db.alloc()
+			t.db.write(p, page(0, innerFlag, cells, level[l-1].page))
+			next = append(next, child{p, level[l-1].key})
+			level = level[l:]
+		}
+		level = next
+	}
+	return level[0].page
+}
+
+// Close writes the remaining pages of each table, then the schema and database header to page 1.
+func (db *DB) Close() error {
+	if db.err != nil {
+		return db.err
+	}
+	schema := make([][]byte, len(db.tables))
+	size := 100 + 8
+	for i, t := range db.tables {
+		t.page = t.root()
+		rec, _ := record([]interface{}{"table", t.name, t.name, int64(t.page), t.sql})
+		if len(rec) > maxLocal {
+			return errors.New("sqlite: schema too large")
+		}
+		schema[i] = db.cell(int64(i+1), rec)
+		size += 2 + len(schema[i])
+	}
+	if size > pageSize {
+		return errors.New("sqlite: schema too large")
+	}
+	buf := page(100, leafFlag, schema, 0)
+	copy(buf, header)
+	binary.BigEndian.PutUint16(buf[16:], pageSize)
+	buf[18], buf[19] = 1, 1                        // legacy journal mode file format versions
+	buf[21], buf[22], buf[23] = 64, 32, 32         // payload fractions, these must be 64, 32 and 32
+	binary.BigEndian.PutUint32(buf[24:], 1)        // file change counter
+	binary.BigEndian.PutUint32(buf[28:], db.pages) // database size in pages
+	binary.BigEndian.PutUint32(buf[40:], 1)        // schema cookie
+	binary.BigEndian.PutUint32(buf[44:], 4)        // schema format number
+	binary.BigEndian.PutUint32(buf[56:], 1)        // UTF-8 text encoding
+	binary.BigEndian.PutUint32(buf[92:], 1)        // version-valid-for: matches the file change counter
+	binary.BigEndian.PutUint32(buf[96:], version)
+	db.write(1, buf)
+	return db.err
+}
+
+// alloc returns the next free page, skipping the lock-byte page
+func (db *DB) alloc() uint32 {
+	db.pages++
+	if int64(db.pages-1)*pageSize == lockByte {
+		db.pages++
+	}
+	return db.pages
+}
+
+func (db *DB) write(p uint32, buf []byte) {
+	if db.err != nil {
+		return
+	}
+	if _, db.err = db.w.Seek(int64(p-1)*pageSize, io.SeekStart); db.err != nil {
+		return
+	}
+	_, db.err = db.w.Write(buf)
+}
+
+// cell makes a table leaf cell, writing any part of the payload that doesn't fit on the leaf page to overflow pages
+func (db *DB) cell(rowid int64, rec []byte) []byte {
+	cell := varint(uint64(len(rec)))
+	cell = append(cell, varint(uint64(rowid))...)
+	if len(rec) <= maxLocal {
+		return append(cell, rec...)
+	}
+	local := minLocal + (len(rec)-minLocal)%(pageSize-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, rec[:local]...)
+	rest := rec[local:]
+	overflow := make([]uint32, (len(rest)+pageSize-5)/(pageSize-4))
+	for i := range overflow {
+		overflow[i] = db.alloc()
+	}
+	for i, p := range overflow {
+		buf := make([]byte, pageSize)
+		if i < len(overflow)-1 {
+			binary.BigEndian.PutUint32(buf, overflow[i+1])
+		}
+		rest = rest[copy(buf[4:], rest):]
+		db.write(p, buf)
+	}
+	return be(cell, uint64(overflow[0]), 4)
+}
+
+// page lays out a b-tree page, with its header at offset off, and its cells in order
+func page(off int, flag byte, cells [][]byte, right uint32) []byte {
+	buf := make([]byte, pageSize)
+	buf[off] = flag
+	hdr := 8
+	if flag == innerFlag {
+		hdr = 12
+		binary.BigEndian.PutUint32(buf[off+8:], right)
+	}
+	binary.BigEndian.PutUint16(buf[off+3:], uint16(len(cells)))
+	ptr, end := off+hdr, pageSize
+	for _, c := range cells {
+		end -= len(c)
+		copy(buf[end:], c)
+		binary.BigEndian.PutUint16(buf[ptr:], uint16(end))
+		ptr += 2
+	}
+	binary.BigEndian.PutUint16(buf[off+5:], uint16(end))
+	return buf
+}
+
+// record encodes values in the SQLite record format: a header of serial types, followed by the values
+func record(values []interface{}) ([]byte, error) {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case int:
+			types, body = integer(types, body, int64(v))
+		case int64:
+			types, body = integer(types, body, v)
+		case string:
+			types = append(types, varint(uint64(len(v))*2+13)...)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, varint(uint64(len(v))*2+12)...)
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("sqlite: unsupported value type %T", v)
+		}
+	}
+	// the header size includes the varint that gives the header size
+	hl := len(types) + 1
+	for len(varint(uint64(hl)))+len(types) != hl {
+		hl = len(varint(uint64(hl))) + len(types)
+	}
+	rec := append(varint(uint64(hl)), types...)
+	return append(rec, body...), nil
+}
+
+// integer appends the smallest serial type and encoding for i
+func integer(types, body []byte, i int64) ([]byte, []byte) {
+	switch {
+	case i == 0:
+		return append(types, 8), body
+	case i == 1:
+		return append(types, 9), body
+	case i >= -1<<7 && i < 1<<7:
+		return append(types, 1), append(body, byte(i))
+	case i >= -1<<15 && i < 1<<15:
+		return append(types, 2), be(body, uint64(i), 2)
+	case i >= -1<<23 && i < 1<<23:
+		return append(types, 3), be(body, uint64(i), 3)
+	case i >= -1<<31 && i < 1<<31:
+		return append(types, 4), be(body, uint64(i), 4)
+	case i >= -1<<47 && i < 1<<47:
+		return append(types, 5), be(body, uint64(i), 6)
+	}
+	return append(types, 6), be(body, uint64(i), 8)
+}
+
+// be appends the low n bytes of v, big-endian
+func be(buf []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*uint(i))))
+	}
+	return buf
+}
+
+// varint encodes v as a SQLite varint: big-endian, seven bits to a byte, except for a ninth byte which has eight
+func varint(v uint64) []byte {
+	if v <= 0x7f {
+		return []byte{byte(v)}
+	}
+	if v > 0x00ffffffffffffff {
+		buf := make([]byte, 9)
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return buf
+	}
+	var tmp [9]byte
+	n := 0
+	for ; v > 0; n++ {
+		tmp[n] = byte(v&0x7f) | 0x80
+		v >>= 7
+	}
+	tmp[0] &= 0x7f
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = tmp[n-1-i]
+	}
+	return buf
+}
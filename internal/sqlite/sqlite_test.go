@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	for _, v := range []struct {
+		in     uint64
+		expect []byte
+	}{
+		{0, []byte{0}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x81, 0x80, 0}},
+		{1<<64 - 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	} {
+		if got := varint(v.in); !bytes.Equal(got, v.expect) {
+			t.Errorf("varint %x: expecting %x, got %x", v.in, v.expect, got)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	rec, err := record([]interface{}{nil, 0, 1, 200, int64(-1), "ab", []byte{9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header size 8, then serial types: null, zero, one, 16-bit int, 8-bit int, 2 byte text (17), 1 byte blob (14)
+	expect := []byte{8, 0, 8, 9, 2, 1, 17, 14, 0, 200, 0xff, 'a', 'b', 9}
+	if !bytes.Equal(rec, expect) {
+		t.Errorf("expecting %x, got %x", expect, rec)
+	}
+	if _, err = record([]interface{}{1.5}); err == nil {
+		t.Error("expecting an error for a float value")
+	}
+}
+
+// seekBuffer is an in-memory io.WriteSeeker
+type seekBuffer struct {
+	buf []byte
+	off int
+}
+
+func (s *seekBuffer) Write(b []byte) (int, error) {
+	if l := s.off + len(b); l > len(s.buf) {
+		s.buf = append(s.buf, make([]byte, l-len(s.buf))...)
+	}
+	copy(s.buf[s.off:], b)
+	s.off += len(b)
+	return len(b), nil
+}
+
+func (s *seekBuffer) Seek(off int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		panic("unexpected seek")
+	}
+	s.off = int(off)
+	return off, nil
+}
+
+func TestCreate(t *testing.T) {
+	w := &seekBuffer{}
+	db := Create(w)
+	a := db.Table("a", "CREATE TABLE a (id INTEGER PRIMARY KEY, v TEXT)")
+	b := db.Table("b", "CREATE TABLE b (v BLOB)")
+	for i := 0; i < 1000; i++ {
+		if id, err := a.Insert(nil, "row"); err != nil || id != int64(i+1) {
+			t.Fatalf("bad insert: got rowid %d, err %v", id, err)
+		}
+	}
+	if _, err := b.Insert(make([]byte, pageSize*3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(w.buf), header) {
+		t.Fatal("missing SQLite header")
+	}
+	if len(w.buf)%pageSize != 0 {
+		t.Errorf("expecting a whole number of pages, got %d bytes", len(w.buf))
+	}
+	if pages := binary.BigEndian.Uint32(w.buf[28:]); int(pages)*pageSize != len(w.buf) {
+		t.Errorf("header gives %d pages, file has %d", pages, len(w.buf)/pageSize)
+	}
+	// the schema leaf on page 1 should have a cell for each table
+	if w.buf[100] != leafFlag || binary.BigEndian.Uint16(w.buf[103:]) != 2 {
+		t.Error("bad schema page")
+	}
+	// 1000 rows don't fit on one leaf, so table a's root (written last of its pages) is an interior page
+	root := int(db.tables[0].page)
+	if w.buf[(root-1)*pageSize] != innerFlag {
+		t.Errorf("expecting an interior root page for table a, got flag %x", w.buf[(root-1)*pageSize])
+	}
+}
@@ -15,11 +15,14 @@
 package textmatcher
 
 import (
+	"bytes"
+
 	"github.com/richardlehane/characterize"
 
 	"github.com/richardlehane/siegfried/internal/persist"
 	"github.com/richardlehane/siegfried/internal/priority"
 	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/core"
 )
 
@@ -67,13 +70,14 @@ func (r result) Basis() string {
 
 func (m *Matcher) Identify(na string, buf *siegreader.Buffer, hints ...core.Hint) (chan core.Result, error) {
 	if *m > 0 {
-		tt := buf.Text()
-		if tt != characterize.DATA {
+		tt, cs := buf.Text(), buf.Charset()
+		if isText(tt, cs) {
+			basis := "text match " + describe(tt, cs) + " (charset " + cs + ")"
 			res := make(chan core.Result, *m)
 			for i := 1; i < int(*m)+1; i++ {
 				res <- result{
 					idx:   i,
-					basis: "text match " + tt.String(),
+					basis: basis,
 				}
 			}
 			close(res)
@@ -85,6 +89,82 @@ func (m *Matcher) Identify(na string, buf *siegreader.Buffer, hints ...core.Hint
 	return res, nil
 }
 
+// isText reports whether a sample is text: either of a type that characterize recognises, or in a charset it doesn't
+func isText(tt characterize.CharType, cs string) bool {
+	return tt != characterize.DATA || cs != ""
+}
+
+// Class is the text matcher's classification of content, given whether or not a format matched.
+type Class struct {
+	Text    bool
+	Type    string // e.g. ASCII, UTF-8 Unicode; empty for binary content
+	Charset string // IANA name e.g. US-ASCII, UTF-16LE; empty for binary content
+	Newline string // line endings of text: LF, CRLF, CR, mixed or none
+}
+
+// String describes a class e.g. "binary" or "text (UTF-8 Unicode, CRLF)"
+func (c Class) String() string {
+	if !c.Text {
+		return "binary"
+	}
+	return "text (" + c.Type + ", " + c.Newline + ")"
+}
+
+// Classify classifies the start of a buffer (see config.TextSample) as text, with its charset and line endings, or binary.
+func Classify(buf *siegreader.Buffer) Class {
+	tt, cs := buf.Text(), buf.Charset()
+	if !isText(tt, cs) {
+		return Class{}
+	}
+	sample, _ := buf.Slice(0, config.TextSample())
+	return Class{Text: true, Type: describe(tt, cs), Charset: cs, Newline: newline(sample, cs)}
+}
+
+// newline names the line endings in a text sample. Zero bytes are dropped from UTF-16 and UTF-32 samples first,
+// so that their line endings can be counted as bytes.
+func newline(sample []byte, cs string) string {
+	switch cs {
+	case "UTF-16LE", "UTF-16BE", "UTF-32LE", "UTF-32BE":
+		sample = bytes.ReplaceAll(sample, []byte{0}, nil)
+	}
+	crlf := bytes.Count(sample, []byte("\r\n"))
+	lf, cr := bytes.Count(sample, []byte("\n"))-crlf, bytes.Count(sample, []byte("\r"))-crlf
+	var kinds []string
+	for _, v := range []struct {
+		n    int
+		name string
+	}{{lf, "LF"}, {crlf, "CRLF"}, {cr, "CR"}} {
+		if v.n > 0 {
+			kinds = append(kinds, v.name)
+		}
+	}
+	switch len(kinds) {
+	case 0:
+		return "none"
+	case 1:
+		return kinds[0]
+	}
+	return "mixed"
+}
+
+// describe names the text type of a sample. Encodings that characterize doesn't recognise are named in its style.
+func describe(tt characterize.CharType, cs string) string {
+	if tt != characterize.DATA {
+		return tt.String()
+	}
+	switch cs {
+	case "UTF-32LE":
+		return "Little-endian UTF-32 Unicode"
+	case "UTF-32BE":
+		return "Big-endian UTF-32 Unicode"
+	case "UTF-16LE":
+		return "Little-endian UTF-16 Unicode"
+	case "UTF-16BE":
+		return "Big-endian UTF-16 Unicode"
+	}
+	return cs
+}
+
 func (m *Matcher) String() string {
 	return "text matcher"
 }
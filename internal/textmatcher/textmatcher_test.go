@@ -40,13 +40,19 @@ var suite = []unit{
 	{
 		label:   "utf8",
 		rdr:     bytes.NewBuffer([]byte("ᚠᛇᚻ᛫ᛒᛦᚦ᛫ᚠᚱᚩᚠ")),
-		expect:  "text match UTF-8 Unicode",
+		expect:  "text match UTF-8 Unicode (charset UTF-8)",
 		results: 3,
 	},
 	{
 		label:   "ascii",
 		rdr:     bytes.NewBuffer([]byte("hello world")),
-		expect:  "text match ASCII",
+		expect:  "text match ASCII (charset US-ASCII)",
+		results: 3,
+	},
+	{
+		label:   "utf32",
+		rdr:     bytes.NewBuffer([]byte{0xff, 0xfe, 0, 0, 'h', 0, 0, 0, 'i', 0, 0, 0}),
+		expect:  "text match Little-endian UTF-32 Unicode (charset UTF-32LE)",
 		results: 3,
 	},
 }
@@ -94,3 +100,26 @@ func TestSuite(t *testing.T) {
 		}
 	}
 }
+
+func TestClassify(t *testing.T) {
+	bufs := siegreader.New()
+	for _, v := range []struct {
+		content []byte
+		expect  string
+		charset string
+	}{
+		{[]byte{0, 1, 50, 255}, "binary", ""},
+		{[]byte("hello\nworld\n"), "text (ASCII, LF)", "US-ASCII"},
+		{[]byte("hello\r\nworld\r\n"), "text (ASCII, CRLF)", "US-ASCII"},
+		{[]byte("hello\rworld\n"), "text (ASCII, mixed)", "US-ASCII"},
+		{[]byte("hello world"), "text (ASCII, none)", "US-ASCII"},
+		{[]byte{0xff, 0xfe, 'h', 0, 'i', 0, '\r', 0, '\n', 0}, "text (Little-endian UTF-16 Unicode, CRLF)", "UTF-16LE"},
+	} {
+		buf, _ := bufs.Get(bytes.NewBuffer(v.content))
+		c := Classify(buf)
+		if c.String() != v.expect || c.Charset != v.charset {
+			t.Errorf("expecting %s (charset %q) for %q, got %s (charset %q)", v.expect, v.charset, v.content, c, c.Charset)
+		}
+		bufs.Put(buf)
+	}
+}
@@ -15,7 +15,10 @@
 package xmlmatcher
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"regexp"
 
 	"github.com/richardlehane/xmldetect"
 
@@ -27,7 +30,9 @@ import (
 
 type Matcher map[[2]string][]int
 
-type SignatureSet [][2]string // slice of root, namespace (both optional)
+// SignatureSet is a slice of root, namespace pairs (both optional).
+// The namespace may also be a DOCTYPE public or system identifier e.g. {"svg", "-//W3C//DTD SVG 1.1//EN"}.
+type SignatureSet [][2]string
 
 func Load(ls *persist.LoadSaver) core.Matcher {
 	le := ls.LoadSmallInt()
@@ -97,43 +102,101 @@ func Add(c core.Matcher, ss core.SignatureSet, p priority.List) (core.Matcher, i
 	return m, length + len(sigs), nil
 }
 
+// recorder records the bytes read by xmldetect, so that the DOCTYPE and root element can be parsed once the root is found
+type recorder struct {
+	io.ByteReader
+	buf []byte
+}
+
+func (r *recorder) ReadByte() (byte, error) {
+	c, err := r.ByteReader.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, c)
+	}
+	return c, err
+}
+
+// Identify matches the root element and its namespace, any other namespaces declared on the root element, and the public and system
+// identifiers of a DOCTYPE declaration, against the signature set.
 func (m Matcher) Identify(s string, b *siegreader.Buffer, hints ...core.Hint) (chan core.Result, error) {
-	rdr := siegreader.TextReaderFrom(b)
+	rdr := &recorder{ByteReader: siegreader.TextReaderFrom(b)}
 	_, root, ns, err := xmldetect.Root(rdr)
 	if err != nil {
 		res := make(chan core.Result)
 		close(res)
 		return res, nil
 	}
-	both := m[[2]string{root, ns}]
-	var nsonly []int
-	var rootonly []int
+	var ret []result
+	seen := make(map[int]bool)
+	add := func(k [2]string, basis string) {
+		for _, v := range m[k] {
+			if !seen[v] {
+				seen[v] = true
+				ret = append(ret, result{v, basis})
+			}
+		}
+	}
+	add([2]string{root, ns}, makeBasis(root, ns))
 	if ns != "" {
-		nsonly = m[[2]string{"", ns}]
-		rootonly = m[[2]string{root, ""}]
+		add([2]string{root, ""}, makeBasis(root, ns))
+		add([2]string{"", ns}, makeBasis(root, ns))
 	}
-	res := make(chan core.Result, len(both)+len(rootonly)+len(nsonly))
-	for _, v := range both {
-		res <- makeResult(v, root, ns)
+	tag := rdr.buf[bytes.LastIndexByte(rdr.buf, '<')+1:]
+	for _, uri := range Namespaces(tag) {
+		if uri != ns {
+			add([2]string{root, uri}, makeBasis(root, uri))
+			add([2]string{"", uri}, makeBasis(root, uri))
+		}
 	}
-	for _, v := range rootonly {
-		res <- makeResult(v, root, ns)
+	_, public, system := Doctype(rdr.buf)
+	for _, id := range []string{public, system} {
+		if id != "" {
+			basis := fmt.Sprintf("xml match with root %s and doctype %s", root, id)
+			add([2]string{root, id}, basis)
+			add([2]string{"", id}, basis)
+		}
 	}
-	for _, v := range nsonly {
-		res <- makeResult(v, root, ns)
+	res := make(chan core.Result, len(ret))
+	for _, r := range ret {
+		res <- r
 	}
 	close(res)
 	return res, nil
 }
 
-func makeResult(idx int, root, ns string) result {
+func makeBasis(root, ns string) string {
 	switch {
 	case root == "":
-		return result{idx, "xml match with ns " + ns}
+		return "xml match with ns " + ns
 	case ns == "":
-		return result{idx, "xml match with root " + root}
+		return "xml match with root " + root
+	}
+	return fmt.Sprintf("xml match with root %s and ns %s", root, ns)
+}
+
+var (
+	nsRe      = regexp.MustCompile(`xmlns(?::[^\s=]+)?\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	doctypeRe = regexp.MustCompile(`<!DOCTYPE\s+([^\s>\[]+)(?:\s+(?:PUBLIC\s+(?:"([^"]*)"|'([^']*)')|SYSTEM)(?:\s+(?:"([^"]*)"|'([^']*)'))?)?`)
+)
+
+// Namespaces returns the namespace URIs declared in an element's start tag.
+func Namespaces(tag []byte) []string {
+	matches := nsRe.FindAllSubmatch(tag, -1)
+	ret := make([]string, 0, len(matches))
+	for _, v := range matches {
+		ret = append(ret, string(v[1])+string(v[2]))
+	}
+	return ret
+}
+
+// Doctype returns the root element name and the public and system identifiers of the first DOCTYPE declaration in buf.
+// Identifiers that aren't given are returned as empty strings.
+func Doctype(buf []byte) (root, public, system string) {
+	v := doctypeRe.FindSubmatch(buf)
+	if v == nil {
+		return "", "", ""
 	}
-	return result{idx, fmt.Sprintf("xml match with root %s and ns %s", root, ns)}
+	return string(v[1]), string(v[2]) + string(v[3]), string(v[4]) + string(v[5])
 }
 
 type result struct {
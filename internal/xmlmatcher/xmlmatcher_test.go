@@ -27,6 +27,8 @@ var (
 		{"MD_metadata", ""},
 		{"MD_metadata", "http://www.isotc211.org/2005/gmd"},
 		{"", "http://purl.org/rss/1.0/"},
+		{"", "http://www.loc.gov/METS/"},
+		{"svg", "-//W3C//DTD SVG 1.1//EN"},
 	}
 	testCases = []struct {
 		name   string
@@ -37,13 +39,15 @@ var (
 		{"mdXML", "<MD_metadata>bla bla", []int{0}},
 		{"mdXMLns", "<MD_metadata xmlns='http://www.isotc211.org/2005/gmd'>bla bla", []int{1, 0}},
 		{"rssXMLns", "<atom xmlns='http://purl.org/rss/1.0/'>", []int{2}},
+		{"metsXMLns", "<?xml version='1.0'?>\n<mets:mets xmlns:xlink=\"http://www.w3.org/1999/xlink\"\n  xmlns:mets=\"http://www.loc.gov/METS/\">", []int{3}},
+		{"svgDOCTYPE", "<?xml version=\"1.0\"?>\n<!DOCTYPE svg PUBLIC \"-//W3C//DTD SVG 1.1//EN\" \"http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd\">\n<svg>", []int{4}},
 	}
 )
 
 func TestAdd(t *testing.T) {
 	_, i, err := Add(nil, testSet, nil)
-	if err != nil || i != 3 {
-		t.Errorf("expecting no errors and three signatures added, got %v and %d", err, i)
+	if err != nil || i != 5 {
+		t.Errorf("expecting no errors and five signatures added, got %v and %d", err, i)
 	}
 
 }
@@ -65,7 +69,7 @@ func identifyString(m Matcher, s string) ([]core.Result, error) {
 
 func TestIdentify(t *testing.T) {
 	m, i, e := Add(nil, testSet, nil)
-	if i != 3 || e != nil {
+	if i != 5 || e != nil {
 		t.Fatal("failed to create matcher")
 	}
 	for _, tc := range testCases {
@@ -85,3 +89,20 @@ func TestIdentify(t *testing.T) {
 		}
 	}
 }
+
+func TestDoctype(t *testing.T) {
+	for _, v := range []struct {
+		decl                 string
+		root, public, system string
+	}{
+		{`<!DOCTYPE html>`, "html", "", ""},
+		{`<!DOCTYPE book PUBLIC "-//OASIS//DTD DocBook XML V4.5//EN" "http://www.oasis-open.org/docbook/xml/4.5/docbookx.dtd">`, "book", "-//OASIS//DTD DocBook XML V4.5//EN", "http://www.oasis-open.org/docbook/xml/4.5/docbookx.dtd"},
+		{`<!DOCTYPE ead SYSTEM 'ead.dtd' [ <!ENTITY x "y"> ]>`, "ead", "", "ead.dtd"},
+		{`<root/>`, "", "", ""},
+	} {
+		root, public, system := Doctype([]byte(v.decl))
+		if root != v.root || public != v.public || system != v.system {
+			t.Errorf("%s: expecting %q, %q, %q; got %q, %q, %q", v.decl, v.root, v.public, v.system, root, public, system)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "path/filepath"
+
+var custom = struct {
+	defs string // path to a JSON definitions file
+	name string
+}{
+	name: "custom",
+}
+
+// Custom returns the location of the custom signature definitions file.
+func Custom() string {
+	if filepath.Dir(custom.defs) == "." {
+		return filepath.Join(siegfried.home, custom.defs)
+	}
+	return custom.defs
+}
+
+// SetCustom builds an identifier from a file of user-defined signatures.
+func SetCustom(defs string) func() private {
+	return func() private {
+		wikidata.namespace = "" // reset wikidata to prevent pollution
+		loc.fdd = ""            // reset loc to prevent pollution
+		mimeinfo.mi = ""        // reset mimeinfo to prevent pollution
+		custom.defs = defs
+		return private{}
+	}
+}
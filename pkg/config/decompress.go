@@ -24,28 +24,52 @@ type Archive int
 
 // Archive type enum.
 const (
-	None Archive = iota // None means the format cannot be decompressed by sf.
-	Zip                 // Zip describes a Zip type archive.
-	Gzip                // Gzip describes a Gzip type archive.	.
-	Tar                 // Tar describes a Tar type archive
-	ARC                 // ARC describes an ARC web archive.
-	WARC                // WARC describes a WARC web archive.
+	None     Archive = iota // None means the format cannot be decompressed by sf.
+	Zip                     // Zip describes a Zip type archive.
+	Gzip                    // Gzip describes a Gzip type archive.	.
+	Tar                     // Tar describes a Tar type archive
+	ARC                     // ARC describes an ARC web archive.
+	WARC                    // WARC describes a WARC web archive.
+	AR                      // AR describes a Unix ar archive (including Debian packages).
+	SevenZip                // SevenZip describes a 7-Zip archive.
+	RAR                     // RAR describes a RAR archive.
+	Bzip2                   // Bzip2 describes a bzip2 compressed stream.
+	XZ                      // XZ describes an xz compressed stream.
+	Zstd                    // Zstd describes a Zstandard compressed stream.
+	ISO                     // ISO describes an ISO 9660 or UDF disc image.
+	MBOX                    // MBOX describes an mbox mailbox.
+	EML                     // EML describes an Internet (RFC 822/MIME) email message.
+	MSG                     // MSG describes an Outlook email message.
+	PST                     // PST describes an Outlook personal folders file.
 )
 
 const (
-	zipArc  = "zip"
-	tarArc  = "tar"
-	gzipArc = "gzip"
-	warcArc = "warc"
-	arcArc  = "arc"
+	zipArc    = "zip"
+	tarArc    = "tar"
+	gzipArc   = "gzip"
+	warcArc   = "warc"
+	arcArc    = "arc"
+	arArc     = "ar"
+	sevenzArc = "7z"
+	rarArc    = "rar"
+	bzip2Arc  = "bzip2"
+	xzArc     = "xz"
+	zstdArc   = "zstd"
+	isoArc    = "iso"
+	mboxArc   = "mbox"
+	emlArc    = "eml"
+	msgArc    = "msg"
+	pstArc    = "pst"
 )
 
 // ArcZipTypes returns a string array with all Zip identifiers Siegfried
-// can match and decompress.
+// can match and decompress. These include zip-based web archive collections (WACZ).
 func ArcZipTypes() []string {
 	return []string{
 		pronom.zip,
+		pronom.wacz,
 		mimeinfo.zip,
+		mimeinfo.wacz,
 		loc.zip,
 	}
 }
@@ -60,6 +84,39 @@ func ArcGzipTypes() []string {
 	}
 }
 
+// ArcBzip2Types returns a string array with all bzip2 identifiers
+// Siegfried can match and decompress.
+func ArcBzip2Types() []string {
+	return []string{
+		pronom.bzip2,
+		mimeinfo.bzip2,
+		mimeinfo.bzip,
+		mimeinfo.bzip2Tar,
+		wikidata.bzip2,
+	}
+}
+
+// ArcXZTypes returns a string array with all xz identifiers
+// Siegfried can match and decompress.
+func ArcXZTypes() []string {
+	return []string{
+		pronom.xz,
+		mimeinfo.xz,
+		mimeinfo.xzTar,
+		wikidata.xz,
+	}
+}
+
+// ArcZstdTypes returns a string array with all Zstandard identifiers
+// Siegfried can match and decompress.
+func ArcZstdTypes() []string {
+	return []string{
+		mimeinfo.zstd,
+		mimeinfo.zstdTar,
+		wikidata.zstd,
+	}
+}
+
 // ArcTarTypes returns a string array with all Tar identifiers Siegfried
 // can match and decompress.
 func ArcTarTypes() []string {
@@ -88,22 +145,124 @@ func ArcArcTypes() []string {
 func ArcWarcTypes() []string {
 	return []string{
 		pronom.warc,
+		pronom.warc10,
+		pronom.warc11,
 		mimeinfo.warc,
 		loc.warc,
 		wikidata.warc,
 	}
 }
 
+// ArcARTypes returns a string array with all ar identifiers
+// Siegfried can match and decompress.
+func ArcARTypes() []string {
+	return []string{
+		pronom.ar,
+		mimeinfo.ar,
+		mimeinfo.deb,
+		mimeinfo.debpkg,
+	}
+}
+
+// ArcSevenZipTypes returns a string array with all 7-Zip identifiers
+// Siegfried can match and decompress.
+func ArcSevenZipTypes() []string {
+	return []string{
+		pronom.sevenz,
+		mimeinfo.sevenz,
+		wikidata.sevenz,
+	}
+}
+
+// ArcRARTypes returns a string array with all RAR identifiers
+// Siegfried can match and decompress.
+func ArcRARTypes() []string {
+	return []string{
+		pronom.rar,
+		pronom.rar29,
+		pronom.rar5,
+		mimeinfo.rar,
+		mimeinfo.xrar,
+		mimeinfo.xrar4,
+		mimeinfo.xrar5,
+		wikidata.rar4,
+		wikidata.rar5,
+	}
+}
+
+// ArcISOTypes returns a string array with all ISO 9660 and UDF disc image
+// identifiers Siegfried can match and decompress.
+func ArcISOTypes() []string {
+	return []string{
+		pronom.iso,
+		pronom.udf,
+		pronom.udfISO,
+		pronom.isoAPM,
+		mimeinfo.iso,
+		mimeinfo.cdImage,
+		wikidata.iso,
+	}
+}
+
+// ArcMBOXTypes returns a string array with all mbox identifiers
+// Siegfried can match and decompress.
+func ArcMBOXTypes() []string {
+	return []string{
+		pronom.mbox,
+		mimeinfo.mbox,
+	}
+}
+
+// ArcEMLTypes returns a string array with all email message identifiers
+// Siegfried can match and decompress.
+func ArcEMLTypes() []string {
+	return []string{
+		pronom.eml,
+		pronom.mime,
+		mimeinfo.eml,
+	}
+}
+
+// ArcMSGTypes returns a string array with all Outlook message identifiers
+// Siegfried can match and decompress.
+func ArcMSGTypes() []string {
+	return []string{
+		pronom.msg,
+		mimeinfo.msg,
+	}
+}
+
+// ArcPSTTypes returns a string array with all Outlook personal folders
+// identifiers Siegfried can match and decompress.
+func ArcPSTTypes() []string {
+	return []string{
+		pronom.pst,
+		pronom.pstuni,
+		mimeinfo.pst,
+	}
+}
+
 // ListAllArcTypes returns a list of archive file-format extensions that
 // can be used to filter the files Siegfried will decompress to identify
 // the contents of.
 func ListAllArcTypes() string {
-	return fmt.Sprintf("%s, %s, %s, %s, %s",
+	return fmt.Sprintf("%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s",
 		zipArc,
 		tarArc,
 		gzipArc,
+		bzip2Arc,
+		xzArc,
+		zstdArc,
 		warcArc,
 		arcArc,
+		arArc,
+		sevenzArc,
+		rarArc,
+		isoArc,
+		mboxArc,
+		emlArc,
+		msgArc,
+		pstArc,
 	)
 }
 
@@ -125,10 +284,32 @@ func SetArchiveFilterPermissive(value string) []string {
 			arr = append(arr, ArcTarTypes()...)
 		case gzipArc:
 			arr = append(arr, ArcGzipTypes()...)
+		case bzip2Arc:
+			arr = append(arr, ArcBzip2Types()...)
+		case xzArc:
+			arr = append(arr, ArcXZTypes()...)
+		case zstdArc:
+			arr = append(arr, ArcZstdTypes()...)
 		case warcArc:
 			arr = append(arr, ArcWarcTypes()...)
 		case arcArc:
 			arr = append(arr, ArcArcTypes()...)
+		case arArc:
+			arr = append(arr, ArcARTypes()...)
+		case sevenzArc:
+			arr = append(arr, ArcSevenZipTypes()...)
+		case rarArc:
+			arr = append(arr, ArcRARTypes()...)
+		case isoArc:
+			arr = append(arr, ArcISOTypes()...)
+		case mboxArc:
+			arr = append(arr, ArcMBOXTypes()...)
+		case emlArc:
+			arr = append(arr, ArcEMLTypes()...)
+		case msgArc:
+			arr = append(arr, ArcMSGTypes()...)
+		case pstArc:
+			arr = append(arr, ArcPSTTypes()...)
 		}
 	}
 	permissiveFilter = arr
@@ -153,6 +334,28 @@ func (a Archive) String() string {
 		return "ARC"
 	case WARC:
 		return "WARC"
+	case AR:
+		return "ar"
+	case SevenZip:
+		return "7z"
+	case RAR:
+		return "RAR"
+	case Bzip2:
+		return "bzip2"
+	case XZ:
+		return "xz"
+	case Zstd:
+		return "zstd"
+	case ISO:
+		return "ISO"
+	case MBOX:
+		return "mbox"
+	case EML:
+		return "EML"
+	case MSG:
+		return "MSG"
+	case PST:
+		return "PST"
 	}
 	return ""
 }
@@ -0,0 +1,55 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "fmt"
+
+// Engine is the sequence matching engine used by the bytematcher.
+type Engine int
+
+const (
+	DynamicEngine Engine = iota // Default. A wild Aho-Corasick tree that scans for wildcard sequences only while their signatures are still live.
+	StaticEngine                // A wild Aho-Corasick tree that scans for all sequences in a single pass. Can be faster when most files are unknown or have many wildcard matches.
+	LowMemEngine                // As StaticEngine, but with a smaller (and slower) tree.
+)
+
+func (e Engine) String() string {
+	switch e {
+	case DynamicEngine:
+		return "dynamic"
+	case StaticEngine:
+		return "static"
+	case LowMemEngine:
+		return "lowmem"
+	}
+	return ""
+}
+
+// ByteEngine reports the sequence matching engine used by the bytematcher.
+func ByteEngine() Engine {
+	return siegfried.engine
+}
+
+// SetEngine sets the bytematcher's sequence matching engine by name: dynamic, static or lowmem.
+// Engines are built on first use, so set before identifying any files.
+func SetEngine(name string) error {
+	for _, e := range []Engine{DynamicEngine, StaticEngine, LowMemEngine} {
+		if e.String() == name {
+			siegfried.engine = e
+			return nil
+		}
+	}
+	return fmt.Errorf("bad engine %q: expecting dynamic, static or lowmem", name)
+}
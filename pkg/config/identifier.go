@@ -22,24 +22,25 @@ import (
 
 // Name of the default identifier as well as settings for how a new identifer will be built
 var identifier = struct {
-	name        string   // Name of the default identifier
-	details     string   // a short string describing the signature e.g. with what DROID and container file versions was it built?
-	maxBOF      int      // maximum offset from beginning of file to scan
-	maxEOF      int      // maximum offset from end of file to scan
-	noEOF       bool     // trim end of file segments from signatures
-	noByte      bool     // don't build with byte signatures
-	noContainer bool     // don't build with container signatures
-	multi       Multi    // define how many results identifiers should return
-	noText      bool     // don't build with text signatures
-	noName      bool     // don't build with filename signatures
-	noMIME      bool     // don't build with MIME signatures
-	noXML       bool     // don't build with XML signatures
-	noRIFF      bool     // don't build with RIFF signatures
-	limit       []string // limit signature to a set of included PRONOM reports
-	exclude     []string // exclude a set of PRONOM reports from the signature
-	extensions  string   // directory where custom signature extensions are stored
-	extend      []string // list of custom signature extensions
-	verbose     bool     // verbose output when building signatures
+	name        string            // Name of the default identifier
+	details     string            // a short string describing the signature e.g. with what DROID and container file versions was it built?
+	maxBOF      int               // maximum offset from beginning of file to scan
+	maxEOF      int               // maximum offset from end of file to scan
+	noEOF       bool              // trim end of file segments from signatures
+	noByte      bool              // don't build with byte signatures
+	noContainer bool              // don't build with container signatures
+	multi       Multi             // define how many results identifiers should return
+	noText      bool              // don't build with text signatures
+	noName      bool              // don't build with filename signatures
+	noMIME      bool              // don't build with MIME signatures
+	noXML       bool              // don't build with XML signatures
+	noRIFF      bool              // don't build with RIFF signatures
+	xmlns       map[string]string // additional XML signatures: a map of namespaces (or DOCTYPE identifiers) to format IDs
+	limit       []string          // limit signature to a set of included PRONOM reports
+	exclude     []string          // exclude a set of PRONOM reports from the signature
+	extensions  string            // directory where custom signature extensions are stored
+	extend      []string          // list of custom signature extensions
+	verbose     bool              // verbose output when building signatures
 }{
 	multi:      Conclusive,
 	extensions: "custom",
@@ -59,6 +60,8 @@ func Name() string {
 		return loc.name
 	case GetWikidataNamespace() != emptyNamespace:
 		return GetWikidataNamespace()
+	case custom.defs != emptyNamespace:
+		return custom.name
 	default:
 		return pronom.name
 	}
@@ -91,6 +94,8 @@ func Details(extra ...string) string {
 				extra = append(extra, ContainerBase())
 			}
 		}
+	} else if custom.defs != "" {
+		str = custom.defs
 	} else {
 		str = DroidBase()
 		if !identifier.noContainer {
@@ -133,6 +138,9 @@ func Details(extra ...string) string {
 	if identifier.noRIFF {
 		str += "; no RIFF matcher"
 	}
+	if len(identifier.xmlns) > 0 {
+		str += fmt.Sprintf("; %d XML namespace mappings", len(identifier.xmlns))
+	}
 	if pronom.reports == "" {
 		str += "; built without reports"
 	}
@@ -209,6 +217,11 @@ func NoXML() bool {
 	return identifier.noXML
 }
 
+// XMLNamespaces returns a map of XML namespaces (or DOCTYPE public or system identifiers) to format IDs, for additional XML signatures.
+func XMLNamespaces() map[string]string {
+	return identifier.xmlns
+}
+
 // NoRIFF reports whether RIFF FOURCC signatures should be omitted.
 func NoRIFF() bool {
 	return identifier.noRIFF
@@ -259,6 +272,37 @@ func Exclude(ids []string) []string {
 	return exclude(ids, identifier.exclude)
 }
 
+// Select applies the limit set or, if there isn't one, the exclude set to a slice of ids.
+// Items in the set that are MIME types (e.g. application/pdf) select the ids with those MIME types: mimes and mids are
+// MIME types and their corresponding ids (as returned by the MIMEs method of a parseable). A MIME value may be a comma separated list.
+func Select(ids, mimes, mids []string) []string {
+	set, keep := identifier.limit, true
+	if !HasLimit() {
+		set, keep = identifier.exclude, false
+	}
+	if len(set) == 0 {
+		return ids
+	}
+	selected := make(map[string]bool, len(set))
+	for _, v := range set {
+		selected[strings.ToLower(v)] = true
+	}
+	for i, v := range mimes {
+		for _, m := range strings.Split(v, ",") {
+			if selected[strings.ToLower(strings.TrimSpace(m))] {
+				selected[strings.ToLower(mids[i])] = true
+			}
+		}
+	}
+	ret := make([]string, 0, len(ids))
+	for _, v := range ids {
+		if selected[strings.ToLower(v)] == keep {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
 func extensionPaths(e []string) []string {
 	ret := make([]string, len(e))
 	for i, v := range e {
@@ -307,6 +351,28 @@ func IsArchive(id string) Archive {
 		return ARC
 	case contains(id, ArcWarcTypes()):
 		return WARC
+	case contains(id, ArcARTypes()):
+		return AR
+	case contains(id, ArcSevenZipTypes()):
+		return SevenZip
+	case contains(id, ArcRARTypes()):
+		return RAR
+	case contains(id, ArcBzip2Types()):
+		return Bzip2
+	case contains(id, ArcXZTypes()):
+		return XZ
+	case contains(id, ArcZstdTypes()):
+		return Zstd
+	case contains(id, ArcISOTypes()):
+		return ISO
+	case contains(id, ArcMBOXTypes()):
+		return MBOX
+	case contains(id, ArcEMLTypes()):
+		return EML
+	case contains(id, ArcMSGTypes()):
+		return MSG
+	case contains(id, ArcPSTTypes()):
+		return PST
 	}
 	return None
 }
@@ -321,8 +387,10 @@ func Clear() func() private {
 		identifier.limit = nil
 		identifier.exclude = nil
 		identifier.multi = Conclusive
+		identifier.xmlns = nil
 		loc.fdd = ""
 		mimeinfo.mi = ""
+		custom.defs = ""
 		return private{}
 	}
 }
@@ -439,6 +507,15 @@ func SetNoXML() func() private {
 	}
 }
 
+// SetXMLNamespaces adds XML signatures to identifiers: m maps XML namespaces (or DOCTYPE public or system identifiers) to format IDs.
+// Format IDs that an identifier doesn't have are ignored by it.
+func SetXMLNamespaces(m map[string]string) func() private {
+	return func() private {
+		identifier.xmlns = m
+		return private{}
+	}
+}
+
 // SetNoRIFF will cause RIFF FOURCC signatures to be omitted.
 func SetNoRIFF() func() private {
 	return func() private {
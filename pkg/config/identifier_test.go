@@ -18,6 +18,7 @@
 package config
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -27,6 +28,11 @@ var locArcUID = "fdd000235"
 var mimeTarUID = "application/x-tar"
 var mimeWarcUID = "application/x-warc"
 var mimeGzipUID = "application/gzip"
+var mimeDebUID = "application/x-debian-package"
+var proWaczUID = "fmt/1840"
+var proSevenZipUID = "fmt/484"
+var proRARUID = "fmt/613"
+var mimeRARUID = "application/vnd.rar"
 
 // Non-archive UID.
 var nonArcUID = "fmt/1000"
@@ -46,9 +52,32 @@ var isArcTests = []arcTest{
 	arcTest{"gZip", mimeGzipUID, Gzip},
 	arcTest{"warc,zip,tar", mimeWarcUID, WARC},
 	arcTest{"zip,arc", locArcUID, ARC},
+	arcTest{"ar", mimeDebUID, AR},
+	arcTest{"7z", proSevenZipUID, SevenZip},
+	arcTest{"rar", proRARUID, RAR},
+	arcTest{"bzip2", "x-fmt/268", Bzip2},
+	arcTest{"gzip,xz", "application/x-xz", XZ},
+	arcTest{"zstd", "application/zstd", Zstd},
+	arcTest{"iso", "fmt/1738", ISO},
+	arcTest{"rar,iso", "application/x-cd-image", ISO},
+	arcTest{"mbox", "fmt/720", MBOX},
+	arcTest{"eml,msg", "message/rfc822", EML},
+	arcTest{"eml", "fmt/950", EML},
+	arcTest{"msg", "x-fmt/430", MSG},
+	arcTest{"pst", "application/vnd.ms-outlook-pst", PST},
+	arcTest{"7z,RAR", mimeRARUID, RAR},
+	arcTest{"zip", proWaczUID, Zip},
+	arcTest{"warc", "fmt/1355", WARC},
 	// Negative tests should all return None.
 	arcTest{"zip,arc", mimeWarcUID, None},
 	arcTest{"zip,arc", mimeGzipUID, None},
+	arcTest{"zip,tar", mimeDebUID, None},
+	arcTest{"zip,ar", proSevenZipUID, None},
+	arcTest{"zip,7z", proRARUID, None},
+	arcTest{"gzip", "application/zstd", None},
+	arcTest{"zip", "fmt/468", None},
+	arcTest{"gzip,warc", proWaczUID, None},
+	arcTest{"eml,msg", "x-fmt/249", None},
 	arcTest{ListAllArcTypes(), nonArcUID, None},
 	arcTest{"", nonArcUID, None},
 }
@@ -68,7 +97,7 @@ func TestIsArchivePositive(t *testing.T) {
 	}
 }
 
-var arcTypes = [...]Archive{Zip, Gzip, Tar, ARC, WARC}
+var arcTypes = [...]Archive{Zip, Gzip, Tar, ARC, WARC, AR, SevenZip, RAR, Bzip2, XZ, Zstd, ISO, MBOX, EML, MSG, PST}
 
 const noneType = None
 
@@ -84,3 +113,19 @@ func TestIsArchiveGreaterThanNone(t *testing.T) {
 		t.Errorf("Archive 0 type should equal zero not %d", noneType)
 	}
 }
+
+// TestSelect checks that limit and exclude sets select IDs, and the IDs of formats with MIME types in the set.
+func TestSelect(t *testing.T) {
+	defer func() { identifier.limit, identifier.exclude = nil, nil }()
+	ids := []string{"fmt/1", "fmt/2", "fmt/18", "x-fmt/111"}
+	mimes, mids := []string{"application/pdf", "audio/x-wav, audio/wav", "text/plain"}, []string{"fmt/18", "fmt/1", "x-fmt/111"}
+	SetLimit([]string{"fmt/2", "Application/PDF", "audio/wav"})()
+	if got := strings.Join(Select(ids, mimes, mids), ","); got != "fmt/1,fmt/2,fmt/18" {
+		t.Errorf("bad limit, got %s", got)
+	}
+	SetLimit(nil)()
+	SetExclude([]string{"fmt/2", "text/plain"})()
+	if got := strings.Join(Select(ids, mimes, mids), ","); got != "fmt/1,fmt/18" {
+		t.Errorf("bad exclude, got %s", got)
+	}
+}
@@ -65,6 +65,7 @@ func SetLOC(fdd string) func() private {
 	return func() private {
 		wikidata.namespace = "" // reset wikidata to prevent pollution
 		mimeinfo.mi = ""        // reset mimeinfo to prevent pollution
+		custom.defs = ""        // reset custom to prevent pollution
 		if fdd == "" {
 			fdd = loc.def
 		}
@@ -26,17 +26,61 @@ var mimeinfo = struct {
 	versions string
 	zip      string
 	gzip     string
+	bzip2    string
+	bzip     string
+	bzip2Tar string
+	xz       string
+	xzTar    string
+	zstd     string
+	zstdTar  string
 	tar      string
 	arc      string
 	warc     string
+	ar       string
+	deb      string
+	debpkg   string
+	wacz     string
+	sevenz   string
+	rar      string
+	xrar     string
+	xrar4    string
+	xrar5    string
+	iso      string
+	cdImage  string
+	mbox     string
+	eml      string
+	msg      string
+	pst      string
 	text     string
 }{
 	versions: "mime-info.json",
 	zip:      "application/zip",
 	gzip:     "application/gzip",
+	bzip2:    "application/x-bzip2",
+	bzip:     "application/x-bzip", // freedesktop's type for bzip2
+	bzip2Tar: "application/x-bzip-compressed-tar",
+	xz:       "application/x-xz",
+	xzTar:    "application/x-xz-compressed-tar",
+	zstd:     "application/zstd",
+	zstdTar:  "application/x-zstd-compressed-tar",
 	tar:      "application/x-tar",
 	arc:      "application/x-arc",
 	warc:     "application/x-warc",
+	ar:       "application/x-archive",
+	deb:      "application/x-debian-package",
+	debpkg:   "application/vnd.debian.binary-package",
+	wacz:     "application/x-wacz",
+	sevenz:   "application/x-7z-compressed",
+	rar:      "application/vnd.rar",
+	xrar:     "application/x-rar-compressed",
+	xrar4:    "application/x-rar-compressed;version=4",
+	xrar5:    "application/x-rar-compressed;version=5",
+	iso:      "application/x-iso9660-image",
+	cdImage:  "application/x-cd-image",
+	mbox:     "application/mbox",
+	eml:      "message/rfc822",
+	msg:      "application/vnd.ms-outlook",
+	pst:      "application/vnd.ms-outlook-pst",
 	text:     "text/plain",
 }
 
@@ -72,6 +116,7 @@ func SetMIMEInfo(mi string) func() private {
 	return func() private {
 		wikidata.namespace = "" // reset wikidata to prevent pollution
 		loc.fdd = ""            // reset loc to prevent pollution
+		custom.defs = ""        // reset custom to prevent pollution
 		switch mi {
 		case "tika", "tika-mimetypes.xml":
 			mimeinfo.mi = "tika-mimetypes.xml"
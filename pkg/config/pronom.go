@@ -41,9 +41,29 @@ var pronom = struct {
 	zip    string
 	tar    string
 	gzip   string
+	bzip2  string
+	xz     string
 	arc    string
 	arc1_1 string
 	warc   string
+	warc10 string
+	warc11 string
+	ar     string
+	wacz   string
+	sevenz string
+	rar    string
+	rar29  string
+	rar5   string
+	iso    string
+	udf    string
+	udfISO string
+	isoAPM string
+	mbox   string
+	eml    string
+	mime   string
+	msg    string
+	pst    string
+	pstuni string
 	// text puid
 	text string
 }{
@@ -56,9 +76,29 @@ var pronom = struct {
 	zip:              "x-fmt/263",
 	tar:              "x-fmt/265",
 	gzip:             "x-fmt/266",
+	bzip2:            "x-fmt/268",
+	xz:               "fmt/1098",
 	arc:              "x-fmt/219",
 	arc1_1:           "fmt/410",
 	warc:             "fmt/289",
+	warc10:           "fmt/1355",
+	warc11:           "fmt/1281",
+	ar:               "fmt/1835",
+	wacz:             "fmt/1840",
+	sevenz:           "fmt/484",
+	rar:              "x-fmt/264",
+	rar29:            "fmt/411",
+	rar5:             "fmt/613",
+	iso:              "fmt/468",
+	udf:              "fmt/1738",
+	udfISO:           "fmt/1739",
+	isoAPM:           "fmt/1741",
+	mbox:             "fmt/720",
+	eml:              "fmt/278",
+	mime:             "fmt/950",
+	msg:              "x-fmt/430",
+	pst:              "x-fmt/248",
+	pstuni:           "x-fmt/249",
 	text:             "x-fmt/111",
 }
 
@@ -16,9 +16,12 @@
 package config
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -40,9 +43,20 @@ var siegfried = struct {
 	updateTransport *http.Transport
 	// Archivematica format policy registry service
 	fpr string
+	// Text matcher heuristic: see TextSample, TextTolerance and TextRanges.
+	textSample     int     // The number of bytes sampled from the start of a file (default is 4096)
+	textTolerance  float64 // The proportion of non-text bytes tolerated in the sample (default is 0)
+	textNoLatin1   bool    // Bytes 0xA0-0xFF are not text
+	textNoExtended bool    // Bytes 0x80-0x9F are not text
 	// DEBUG and SLOW modes
 	debug      bool
 	slow       bool
+	nameOnly   bool   // files are identified by name and MIME only (content isn't read)
+	why        bool   // identifiers explain the candidates they suppress
+	noTemp     bool   // large streams aren't copied to temp files
+	indexed    bool   // signature files are saved indexed and uncompressed, so they can be memory mapped and decoded lazily
+	maxBytes   int    // the bytematcher's BOF scan stops at this offset (0 is no limit)
+	engine     Engine // the bytematcher's sequence matching engine
 	out        io.Writer
 	checkpoint int64
 	userAgent  string
@@ -60,6 +74,7 @@ var siegfried = struct {
 	updateTimeout:   30 * time.Second,
 	updateTransport: &http.Transport{Proxy: http.ProxyFromEnvironment},
 	fpr:             "/tmp/siegfried",
+	textSample:      4096,
 	checkpoint:      524288, // point at which to report slow signatures (must be power of two)
 	userAgent:       "siegfried/siegbot (+https://github.com/richardlehane/siegfried)",
 }
@@ -149,11 +164,62 @@ func Fpr() string {
 	return siegfried.fpr
 }
 
+// TextSample is a text matcher setting. It is the number of bytes, from the start of a file, that are tested to decide whether a file is text.
+//
+// The heuristic follows the file(1) command. A sample is text if it is valid UTF-8 or UTF-16, or EBCDIC,
+// or if all of its bytes are text bytes. Text bytes are printable ASCII and the ASCII controls BEL, BS, TAB, LF, VT, FF, CR and ESC
+// (ASCII text), plus bytes 0xA0-0xFF (ISO-8859 text) and bytes 0x80-0x9F (extended ASCII text).
+// TextTolerance relaxes the "all bytes" requirement and TextRanges narrows the set of text bytes.
+func TextSample() int {
+	return siegfried.textSample
+}
+
+// TextTolerance is a text matcher setting. It is the proportion (0-1) of a sample that may be made up of non-text bytes
+// without the sample being classified as binary data. The default is 0: a single NUL byte makes a file binary.
+func TextTolerance() float64 {
+	return siegfried.textTolerance
+}
+
+// TextRanges is a text matcher setting. It reports whether the ISO-8859 (0xA0-0xFF) and extended ASCII (0x80-0x9F) byte ranges count as text.
+// Both do by default. ASCII text bytes always count as text.
+func TextRanges() (latin1 bool, extended bool) {
+	return !siegfried.textNoLatin1, !siegfried.textNoExtended
+}
+
 // Debug reports whether debug logging is activated.
 func Debug() bool {
 	return siegfried.debug
 }
 
+// NameOnly reports whether files are being identified by name and MIME type only, without reading their content.
+// Identifiers don't rule out name and MIME matches for formats with byte signatures in this mode, as those signatures aren't tested.
+func NameOnly() bool {
+	return siegfried.nameOnly
+}
+
+// Why reports whether identifiers should explain the candidate identifications they suppress (e.g. for lower priority).
+func Why() bool {
+	return siegfried.why
+}
+
+// NoTemp reports whether temp files are disabled. If they are, streams that are too big to buffer in memory
+// (e.g. large archive members) keep only their first and last bytes, rather than being copied to a temp file.
+func NoTemp() bool {
+	return siegfried.noTemp
+}
+
+// Indexed reports whether signature files are saved in the indexed format. An indexed signature file isn't compressed:
+// it is bigger, but sf memory maps it and decodes each matcher (and the identifiers) only when it is first used, so startup is faster.
+func Indexed() bool {
+	return siegfried.indexed
+}
+
+// MaxBytes reports the offset at which the bytematcher stops scanning from the start of a file. Zero means no limit.
+// Signatures anchored to the end of a file are still checked.
+func MaxBytes() int {
+	return siegfried.maxBytes
+}
+
 // Slow reports whether slow logging is activated.
 func Slow() bool {
 	return siegfried.slow
@@ -231,11 +297,80 @@ func SetRepetition(i int) func() private {
 	}
 }
 
+// SetText tunes the text matcher heuristic. The settings string is a comma-separated list of key=value pairs:
+// sample is the number of bytes tested (see TextSample); tolerance is the proportion of non-text bytes
+// tolerated (see TextTolerance); and ranges is a "+" separated list of the byte ranges that count as text: ascii, latin1 and extended (see TextRanges).
+// E.g. "sample=8192,tolerance=0.01,ranges=ascii+latin1".
+func SetText(settings string) error {
+	sample, tolerance := siegfried.textSample, siegfried.textTolerance
+	noLatin1, noExtended := siegfried.textNoLatin1, siegfried.textNoExtended
+	for _, kv := range strings.Split(settings, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		switch k {
+		case "sample":
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 1 {
+				return fmt.Errorf("bad text sample %q: expecting a positive number of bytes", v)
+			}
+			sample = i
+		case "tolerance":
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 || f > 1 {
+				return fmt.Errorf("bad text tolerance %q: expecting a proportion between 0 and 1", v)
+			}
+			tolerance = f
+		case "ranges":
+			noLatin1, noExtended = true, true
+			for _, r := range strings.Split(v, "+") {
+				switch r {
+				case "ascii":
+				case "latin1":
+					noLatin1 = false
+				case "extended":
+					noExtended = false
+				default:
+					return fmt.Errorf("bad text range %q: expecting ascii, latin1 or extended", r)
+				}
+			}
+		default:
+			return fmt.Errorf("bad text setting %q: expecting sample, tolerance or ranges", k)
+		}
+	}
+	siegfried.textSample, siegfried.textTolerance = sample, tolerance
+	siegfried.textNoLatin1, siegfried.textNoExtended = noLatin1, noExtended
+	return nil
+}
+
 // SetDebug sets degub logging on.
 func SetDebug() {
 	siegfried.debug = true
 }
 
+// SetNameOnly sets name-only identification (see NameOnly).
+func SetNameOnly() {
+	siegfried.nameOnly = true
+}
+
+// SetWhy sets identifiers to explain the candidate identifications they suppress (see Why).
+func SetWhy() {
+	siegfried.why = true
+}
+
+// SetNoTemp disables temp files (see NoTemp).
+func SetNoTemp() {
+	siegfried.noTemp = true
+}
+
+// SetIndexed saves signature files in the indexed format (see Indexed).
+func SetIndexed() {
+	siegfried.indexed = true
+}
+
+// SetMaxBytes limits how far the bytematcher scans from the start of a file (see MaxBytes).
+func SetMaxBytes(i int) {
+	siegfried.maxBytes = i
+}
+
 // SetSlow sets slow logging on.
 func SetSlow() {
 	siegfried.slow = true
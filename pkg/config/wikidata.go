@@ -32,9 +32,16 @@ var wikidata = struct {
 	// the Wikidata identifier.
 	arc    string
 	arc1_1 string
+	bzip2  string
 	gzip   string
+	iso    string
+	rar4   string
+	rar5   string
+	sevenz string
 	tar    string
 	warc   string
+	xz     string
+	zstd   string
 	// debug provides a way for users to output errors and warnings
 	// associated with Wikidata records.
 	debug bool
@@ -101,9 +108,16 @@ var wikidata = struct {
 }{
 	arc:                    "Q7978505",
 	arc1_1:                 "Q27824065",
+	bzip2:                  "Q27866052",
 	gzip:                   "Q27824060",
+	iso:                    "Q877050",
+	rar4:                   "Q35221401",
+	rar5:                   "Q35221946",
+	sevenz:                 "Q105853878",
 	tar:                    "Q283579",
 	warc:                   "Q10287816",
+	xz:                     "Q162839",
+	zstd:                   "Q105853477",
 	definitions:            "wikidata-definitions-3.0.0",
 	endpoint:               "https://query.wikidata.org/sparql",
 	filemode:               0644,
@@ -137,6 +151,7 @@ func SetWikidataNamespace() func() private {
 	return func() private {
 		loc.fdd = ""     // reset loc to avoid pollution
 		mimeinfo.mi = "" // reset mimeinfo to avoid pollution
+		custom.defs = "" // reset custom to avoid pollution
 		wikidata.namespace = wikidataNamespace
 		return private{}
 	}
@@ -0,0 +1,142 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Basis is a structured form of one of the reasons given for an identification (one of the messages in its basis).
+type Basis struct {
+	Matcher   string `json:"matcher"`             // extension, filename, mime, text, byte, container, xml, riff or other (for messages that aren't recognised)
+	Match     string `json:"match,omitempty"`     // what matched e.g. the extension, MIME type, text type, XML root or fourCC
+	Signature int    `json:"signature,omitempty"` // for formats with more than one signature, the (1-based) signature that matched
+	Hits      []Hit  `json:"hits,omitempty"`      // for byte and container matches, the regions that matched
+	Text      string `json:"text"`                // the message
+}
+
+// Hit is a region of a file, or of a container member, that matched a sequence of a byte signature.
+type Hit struct {
+	Member   string `json:"member,omitempty"` // for container matches, the member that the offset is relative to
+	Sequence int    `json:"sequence"`         // index of the sequence within the signature
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+}
+
+// Bases returns the structured basis of an identification. Identifications may give their bases with a Bases method;
+// otherwise Bases returns nil.
+func Bases(id Identification) []Basis {
+	if b, ok := id.(interface{ Bases() []Basis }); ok {
+		return b.Bases()
+	}
+	return nil
+}
+
+// ParseBases parses the basis messages of an identification (as kept by identifiers, before they are joined with "; ").
+func ParseBases(msgs []string) []Basis {
+	if len(msgs) == 0 {
+		return nil
+	}
+	ret := make([]Basis, len(msgs))
+	for i, m := range msgs {
+		ret[i] = ParseBasis(m)
+	}
+	return ret
+}
+
+// SplitBasis splits a joined basis (e.g. the basis field of a results file) into its messages. A container match
+// gives a message for each member it matched, joined with "; ", so these are kept together:
+// "extension match odt; container name content.xml with byte match at 39, 23; name mimetype" has two messages.
+func SplitBasis(basis string) []string {
+	if basis == "" {
+		return nil
+	}
+	var ret []string
+	for _, part := range strings.Split(basis, "; ") {
+		if len(ret) > 0 && strings.HasPrefix(part, "name ") && strings.HasPrefix(ret[len(ret)-1], "container name ") {
+			ret[len(ret)-1] += "; " + part
+			continue
+		}
+		ret = append(ret, part)
+	}
+	return ret
+}
+
+var (
+	basisNums = regexp.MustCompile(`\d+`)
+	basisSig  = regexp.MustCompile(` ?\(signature (\d+)/\d+\)`)
+)
+
+// ParseBasis parses a basis message, as given by the matchers e.g. "byte match at [[0 6] [18124 1]] (signature 2/3)".
+func ParseBasis(msg string) Basis {
+	b := Basis{Matcher: "other", Text: msg}
+	if m := basisSig.FindStringSubmatch(msg); m != nil {
+		b.Signature, _ = strconv.Atoi(m[1])
+		msg = strings.Replace(msg, m[0], "", 1)
+	}
+	for _, v := range []struct{ prefix, matcher string }{
+		{"extension match ", "extension"},
+		{"glob match ", "filename"},
+		{"mime match ", "mime"},
+		{"text match ", "text"},
+		{"xml match with ", "xml"},
+		{"fourCC matches ", "riff"},
+	} {
+		if strings.HasPrefix(msg, v.prefix) {
+			b.Matcher, b.Match = v.matcher, strings.TrimPrefix(msg, v.prefix)
+			return b
+		}
+	}
+	switch {
+	case strings.HasPrefix(msg, "byte match at "):
+		b.Matcher, b.Hits = "byte", parseHits("", strings.TrimPrefix(msg, "byte match at "))
+	case strings.HasPrefix(msg, "container "):
+		b.Matcher = "container"
+		for _, part := range strings.Split(strings.TrimPrefix(msg, "container "), "; ") {
+			if !strings.HasPrefix(part, "name ") {
+				continue
+			}
+			member, rest, _ := strings.Cut(strings.TrimPrefix(part, "name "), " with ")
+			if _, regions, ok := strings.Cut(rest, "byte match at "); ok {
+				b.Hits = append(b.Hits, parseHits(member, regions)...)
+			}
+		}
+	}
+	return b
+}
+
+// parseHits reads offset and length pairs, given as "0, 4" or "[[0 6] [18124 1]]"
+func parseHits(member, regions string) []Hit {
+	nums := basisNums.FindAllString(regions, -1)
+	ret := make([]Hit, 0, len(nums)/2)
+	for i := 0; i+1 < len(nums); i += 2 {
+		off, _ := strconv.ParseInt(nums[i], 10, 64)
+		l, _ := strconv.ParseInt(nums[i+1], 10, 64)
+		ret = append(ret, Hit{member, i / 2, off, l})
+	}
+	return ret
+}
+
+// Why returns explanations of the candidate identifications that were suppressed in favour of an identification
+// e.g. "fmt/44 superseded by fmt/645 (priority)". Identifications may give these with a Why method, when config.Why is set;
+// otherwise Why returns nil.
+func Why(id Identification) []string {
+	if w, ok := id.(interface{ Why() []string }); ok {
+		return w.Why()
+	}
+	return nil
+}
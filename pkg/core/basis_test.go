@@ -0,0 +1,38 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBasis(t *testing.T) {
+	for _, v := range []struct {
+		msg    string
+		expect Basis
+	}{
+		{"extension match txt", Basis{Matcher: "extension", Match: "txt"}},
+		{"glob match *.foo", Basis{Matcher: "filename", Match: "*.foo"}},
+		{"text match ASCII (charset US-ASCII)", Basis{Matcher: "text", Match: "ASCII (charset US-ASCII)"}},
+		{"byte match at 0, 4 (signature 2/5)", Basis{Matcher: "byte", Signature: 2, Hits: []Hit{{"", 0, 0, 4}}}},
+		{"byte match at [[0 6] [18124 1]]", Basis{Matcher: "byte", Hits: []Hit{{"", 0, 0, 6}, {"", 1, 18124, 1}}}},
+		{"container name content.xml with byte match at [[39 23]]; name mimetype with name only (signature 1/3)",
+			Basis{Matcher: "container", Signature: 1, Hits: []Hit{{"content.xml", 0, 39, 23}}}},
+		{"container match with trigger and default extension", Basis{Matcher: "container"}},
+		{"xml match with root svg", Basis{Matcher: "xml", Match: "root svg"}},
+		{"fourCC matches WAVE", Basis{Matcher: "riff", Match: "WAVE"}},
+		{"something new", Basis{Matcher: "other"}},
+	} {
+		v.expect.Text = v.msg
+		if got := ParseBasis(v.msg); !reflect.DeepEqual(got, v.expect) {
+			t.Errorf("%s: expecting %+v, got %+v", v.msg, v.expect, got)
+		}
+	}
+}
+
+func TestSplitBasis(t *testing.T) {
+	got := SplitBasis("extension match odt; container name content.xml with byte match at 39, 23; name mimetype; byte match at 0, 4")
+	expect := []string{"extension match odt", "container name content.xml with byte match at 39, 23; name mimetype", "byte match at 0, 4"}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expecting %q, got %q", expect, got)
+	}
+}
@@ -43,6 +43,7 @@ const (
 	MIMEInfo
 	LOC
 	Wikidata
+	Custom // Custom identifiers are built from user-defined signatures
 )
 
 // IdentifierLoader unmarshals an Identifer from a LoadSaver.
@@ -79,7 +80,7 @@ type Hint struct {
 type Recorder interface {
 	Record(MatcherType, Result) bool    // Record results for each matcher; return true if match recorded (siegfried will iterate through the identifiers until an identifier returns true).
 	Satisfied(MatcherType) (bool, Hint) // Called before matcher starts - should we continue onto this matcher? Should we pass any hints (exclude or pivot) to this matcher?
-	Report() []Identification           // Return results as slice
+	Report() []Identification           // Return results as slice (may be called for a provisional result before more results are recorded).
 	Active(MatcherType)                 // Instruct Recorder that can expect results of type MatcherType.
 }
 
@@ -93,6 +94,7 @@ type Identification interface {
 }
 
 // Matcher does the matching (against the name/mime string or the byte stream) and sends results
+// Matchers should stop reading the Buffer, and close their results channel, once the Buffer's context is done (see siegreader.Buffer.Context)
 type Matcher interface {
 	Identify(string, *siegreader.Buffer, ...Hint) (chan Result, error) // Given a name/MIME string and bytes, identify the file. Include the collected Hints
 	String() string
@@ -120,3 +122,10 @@ type Result interface {
 	Index() int
 	Basis() string
 }
+
+// Provisional is sent by the bytematcher, among its results, once it has scanned the start of a Buffer and before it reads to the end
+// (only if the Buffer's Provisional field is set). It marks the point at which a provisional identification can be reported. It isn't a hit.
+type Provisional struct{}
+
+func (Provisional) Index() int    { return -1 }
+func (Provisional) Basis() string { return "" }
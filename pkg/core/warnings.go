@@ -0,0 +1,105 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import "strings"
+
+// Warning codes are machine-readable equivalents of the messages that identifiers give in identification warnings.
+const (
+	NoMatch           = "NO_MATCH"           // no match
+	MultipleMatches   = "MULTIPLE_MATCHES"   // multiple matches
+	ExtensionOnly     = "EXTENSION_ONLY"     // match on extension only
+	FilenameOnly      = "FILENAME_ONLY"      // match on filename (glob) only
+	MIMEOnly          = "MIME_ONLY"          // match on MIME only
+	TextOnly          = "TEXT_ONLY"          // match on text only
+	ExtensionMismatch = "EXTENSION_MISMATCH" // extension mismatch
+	FilenameMismatch  = "FILENAME_MISMATCH"  // filename (glob) mismatch
+	MIMEMismatch      = "MIME_MISMATCH"      // MIME mismatch
+	SignatureMismatch = "SIGNATURE_MISMATCH" // byte/xml signatures for this format did not match
+	EmptyFile         = "EMPTY_FILE"         // the file is empty (not reported in warnings, see cmd/sf -codes)
+	HighEntropy       = "HIGH_ENTROPY"       // possibly encrypted or compressed (a hint for unidentified files, see cmd/sf -entropy)
+	UniformContent    = "UNIFORM_CONTENT"    // every byte is the same (a hint for unidentified files, see cmd/sf -entropy)
+	OtherWarning      = "OTHER"              // a warning without a code
+)
+
+var onlyCodes = map[string]string{
+	"extension": ExtensionOnly,
+	"filename":  FilenameOnly,
+	"MIME":      MIMEOnly,
+	"text":      TextOnly,
+}
+
+var warningCodes = map[string]string{
+	"no match":           NoMatch,
+	"extension mismatch": ExtensionMismatch,
+	"filename mismatch":  FilenameMismatch,
+	"MIME mismatch":      MIMEMismatch,
+	"byte/xml signatures for this format did not match": SignatureMismatch,
+}
+
+// WarningCodes returns the codes for the messages in a warning, in the order they are given.
+// Identifiers join messages with "; ".
+func WarningCodes(warn string) []string {
+	if warn == "" {
+		return nil
+	}
+	var codes []string
+	add := func(c string) {
+		for _, v := range codes {
+			if v == c {
+				return
+			}
+		}
+		codes = append(codes, c)
+	}
+	for _, msg := range strings.Split(warn, "; ") {
+		switch {
+		case warningCodes[msg] != "":
+			add(warningCodes[msg])
+		case strings.HasPrefix(msg, "multiple matches"):
+			add(MultipleMatches)
+		case strings.HasPrefix(msg, "possibly encrypted or compressed"):
+			add(HighEntropy)
+		case strings.HasPrefix(msg, "uniform content"):
+			add(UniformContent)
+		case strings.HasPrefix(msg, "possibilities based on"):
+			// detail for a preceding no match
+		case strings.HasPrefix(msg, "match on ") && strings.HasSuffix(msg, " only"):
+			// e.g. "match on extension, MIME and text only"
+			bases := strings.FieldsFunc(strings.TrimSuffix(strings.TrimPrefix(msg, "match on "), " only"), func(r rune) bool { return r == ',' })
+			for _, b := range bases {
+				for _, s := range strings.Split(strings.TrimSpace(b), " and ") {
+					if c, ok := onlyCodes[s]; ok {
+						add(c)
+					} else {
+						add(OtherWarning)
+					}
+				}
+			}
+		default:
+			add(OtherWarning)
+		}
+	}
+	return codes
+}
+
+// Codes returns the warning codes for an identification. Identifications may give their codes with a Codes method,
+// otherwise they are taken from the identification's warning.
+func Codes(id Identification) []string {
+	if c, ok := id.(interface{ Codes() []string }); ok {
+		return c.Codes()
+	}
+	return WarningCodes(id.Warn())
+}
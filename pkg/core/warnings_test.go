@@ -0,0 +1,27 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWarningCodes(t *testing.T) {
+	for _, v := range []struct {
+		warn   string
+		expect string
+	}{
+		{"", ""},
+		{"no match; possibilities based on extension are fmt/14, fmt/15", "NO_MATCH"},
+		{"multiple matches fmt/1, fmt/2", "MULTIPLE_MATCHES"},
+		{"match on extension only; MIME mismatch", "EXTENSION_ONLY MIME_MISMATCH"},
+		{"match on extension, MIME and text only", "EXTENSION_ONLY MIME_ONLY TEXT_ONLY"},
+		{"match on filename only; byte/xml signatures for this format did not match", "FILENAME_ONLY SIGNATURE_MISMATCH"},
+		{"extension mismatch; something new", "EXTENSION_MISMATCH OTHER"},
+		{"no match; possibly encrypted or compressed (entropy 7.99 bits per byte)", "NO_MATCH HIGH_ENTROPY"},
+		{"no match; uniform content (every byte is 0x00)", "NO_MATCH UNIFORM_CONTENT"},
+	} {
+		if got := strings.Join(WarningCodes(v.warn), " "); got != v.expect {
+			t.Errorf("codes for %q: expecting %q, got %q", v.warn, v.expect, got)
+		}
+	}
+}
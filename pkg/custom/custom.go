@@ -0,0 +1,277 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package custom implements user-defined signatures as a siegfried identifier, so that local and bespoke formats can be identified
+// without changes to the PRONOM identifier.
+//
+// Signatures are given in a JSON definitions file that lists formats by ID, with their names, MIME types, extensions and byte signatures.
+// A byte signature is a list of hex sequences that must all match. Sequences are anchored to the beginning ("bof", the default)
+// or end ("eof") of a file: at an offset or, if a maxoffset is given, within a range of offsets (a maxoffset of -1 means anywhere after the offset).
+// Within a sequence, ?? matches any byte. A format's priorities are the IDs of formats that it should be reported in preference to
+// (e.g. a more general format that it is a profile of).
+//
+// Example:
+//
+//	{
+//	  "updated": "2023-06-01",
+//	  "formats": [
+//	    {
+//	      "id": "acme/1",
+//	      "name": "ACME Widget",
+//	      "mime": "application/x-acme-widget",
+//	      "extensions": ["wdg"],
+//	      "signatures": [
+//	        [{"hex": "41434D45 ?? 01"}, {"position": "eof", "hex": "454E44"}]
+//	      ],
+//	      "priorities": ["acme/0"]
+//	    }
+//	  ]
+//	}
+//
+// Build a signature file with roy build -custom definitions.json (or add it to an existing one with roy add -custom).
+package custom
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/richardlehane/siegfried/internal/bytematcher/frames"
+	"github.com/richardlehane/siegfried/internal/bytematcher/patterns"
+	"github.com/richardlehane/siegfried/internal/identifier"
+	"github.com/richardlehane/siegfried/internal/priority"
+)
+
+type definitions struct {
+	Updated string   `json:"updated"`
+	Formats []format `json:"formats"`
+}
+
+type format struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	MIME       string       `json:"mime"`
+	Extensions []string     `json:"extensions"`
+	Signatures [][]sequence `json:"signatures"`
+	Priorities []string     `json:"priorities"`
+}
+
+type sequence struct {
+	Position  string `json:"position"` // "bof" or "eof"
+	Offset    int    `json:"offset"`
+	MaxOffset *int   `json:"maxoffset"`
+	Hex       string `json:"hex"`
+}
+
+type defs struct {
+	definitions
+	sigs [][]frames.Signature // compiled byte signatures for each format
+	identifier.Blank
+}
+
+func newCustom(path string) (identifier.Parseable, error) {
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("custom: error reading %s; got %v", path, err)
+	}
+	var d definitions
+	if err = json.Unmarshal(byt, &d); err != nil {
+		return nil, fmt.Errorf("custom: error parsing %s; got %v", path, err)
+	}
+	seen := make(map[string]bool, len(d.Formats))
+	sigs := make([][]frames.Signature, len(d.Formats))
+	for i, f := range d.Formats {
+		if f.ID == "" || seen[f.ID] {
+			return nil, fmt.Errorf("custom: format %d in %s has a missing or duplicate id %q", i, path, f.ID)
+		}
+		seen[f.ID] = true
+		for j, s := range f.Signatures {
+			sig, err := signature(s)
+			if err != nil {
+				return nil, fmt.Errorf("custom: bad signature %d for %s; %v", j, f.ID, err)
+			}
+			sigs[i] = append(sigs[i], sig)
+		}
+	}
+	return defs{d, sigs, identifier.Blank{}}, nil
+}
+
+// signature compiles a list of sequences as a byte signature, with BOF anchored sequences before EOF anchored ones
+func signature(seqs []sequence) (frames.Signature, error) {
+	if len(seqs) == 0 {
+		return nil, fmt.Errorf("no sequences")
+	}
+	var bof, eof frames.Signature
+	for _, s := range seqs {
+		min, max := s.Offset, s.Offset
+		if s.MaxOffset != nil {
+			max = *s.MaxOffset
+			if max >= 0 && max < min {
+				return nil, fmt.Errorf("maxoffset %d is less than offset %d", max, min)
+			}
+		}
+		if min < 0 {
+			return nil, fmt.Errorf("negative offset %d", min)
+		}
+		frags, gaps, err := dehex(s.Hex)
+		if err != nil {
+			return nil, err
+		}
+		switch s.Position {
+		case "", "bof":
+			// gaps[0] is the number of wildcard bytes before the first fragment
+			fs := frames.Signature{frames.NewFrame(frames.BOF, patterns.Sequence(frags[0]), min+gaps[0], shift(max, gaps[0]))}
+			for i, f := range frags[1:] {
+				fs = append(fs, frames.NewFrame(frames.PREV, patterns.Sequence(f), gaps[i+1], gaps[i+1]))
+			}
+			bof = append(bof, fs...)
+		case "eof":
+			// the last fragment is anchored to the end of the file, and preceding ones to their successors
+			last := len(frags) - 1
+			fs := make(frames.Signature, len(frags))
+			fs[last] = frames.NewFrame(frames.EOF, patterns.Sequence(frags[last]), min+gaps[last+1], shift(max, gaps[last+1]))
+			for i := last - 1; i >= 0; i-- {
+				fs[i] = frames.NewFrame(frames.SUCC, patterns.Sequence(frags[i]), gaps[i+1], gaps[i+1])
+			}
+			eof = append(eof, fs...)
+		default:
+			return nil, fmt.Errorf("unknown position %q (expecting bof or eof)", s.Position)
+		}
+	}
+	return append(bof, eof...), nil
+}
+
+func shift(max, n int) int {
+	if max < 0 {
+		return max
+	}
+	return max + n
+}
+
+// dehex decodes a hex sequence, with optional spaces and ?? wildcards, as fragments of literal bytes.
+// The gaps are the numbers of wildcard bytes before, between and after the fragments (so there is one more gap than fragments).
+func dehex(h string) ([][]byte, []int, error) {
+	h = strings.Join(strings.Fields(h), "")
+	if len(h) == 0 || len(h)%2 != 0 {
+		return nil, nil, fmt.Errorf("bad hex sequence %q", h)
+	}
+	var (
+		frags [][]byte
+		gaps  = []int{0}
+		frag  []byte
+	)
+	for i := 0; i < len(h); i += 2 {
+		if h[i:i+2] == "??" {
+			if frag != nil {
+				frags, frag = append(frags, frag), nil
+				gaps = append(gaps, 0)
+			}
+			gaps[len(gaps)-1]++
+			continue
+		}
+		b, err := hex.DecodeString(h[i : i+2])
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad hex sequence %q", h)
+		}
+		frag = append(frag, b[0])
+	}
+	if frag != nil {
+		frags = append(frags, frag)
+		gaps = append(gaps, 0)
+	}
+	if len(frags) == 0 {
+		return nil, nil, fmt.Errorf("hex sequence %q has only wildcards", h)
+	}
+	return frags, gaps, nil
+}
+
+func (d defs) IDs() []string {
+	ids := make([]string, len(d.Formats))
+	for i, v := range d.Formats {
+		ids[i] = v.ID
+	}
+	return ids
+}
+
+type formatInfo struct {
+	name     string
+	mimeType string
+}
+
+func (f formatInfo) String() string {
+	return f.name
+}
+
+// turn generic FormatInfo into custom formatInfo
+func infos(m map[string]identifier.FormatInfo) map[string]formatInfo {
+	i := make(map[string]formatInfo, len(m))
+	for k, v := range m {
+		i[k] = v.(formatInfo)
+	}
+	return i
+}
+
+func (d defs) Infos() map[string]identifier.FormatInfo {
+	fmap := make(map[string]identifier.FormatInfo, len(d.Formats))
+	for _, v := range d.Formats {
+		fmap[v.ID] = formatInfo{
+			name:     v.Name,
+			mimeType: v.MIME,
+		}
+	}
+	return fmap
+}
+
+func (d defs) Globs() ([]string, []string) {
+	globs, ids := make([]string, 0, len(d.Formats)), make([]string, 0, len(d.Formats))
+	for _, v := range d.Formats {
+		for _, w := range v.Extensions {
+			globs, ids = append(globs, "*."+strings.TrimPrefix(w, ".")), append(ids, v.ID)
+		}
+	}
+	return globs, ids
+}
+
+func (d defs) MIMEs() ([]string, []string) {
+	mimes, ids := make([]string, 0, len(d.Formats)), make([]string, 0, len(d.Formats))
+	for _, v := range d.Formats {
+		if v.MIME != "" {
+			mimes, ids = append(mimes, v.MIME), append(ids, v.ID)
+		}
+	}
+	return mimes, ids
+}
+
+func (d defs) Signatures() ([]frames.Signature, []string, error) {
+	sigs, ids := make([]frames.Signature, 0, len(d.Formats)), make([]string, 0, len(d.Formats))
+	for i, v := range d.Formats {
+		for _, s := range d.sigs[i] {
+			sigs, ids = append(sigs, s), append(ids, v.ID)
+		}
+	}
+	return sigs, ids, nil
+}
+
+func (d defs) Priorities() priority.Map {
+	p := make(priority.Map)
+	for _, v := range d.Formats {
+		for _, w := range v.Priorities {
+			p.Add(w, v.ID) // w is subordinate to v
+		}
+	}
+	p.Complete()
+	return p
+}
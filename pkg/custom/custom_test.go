@@ -0,0 +1,81 @@
+package custom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/bytematcher/frames"
+	"github.com/richardlehane/siegfried/internal/bytematcher/patterns"
+)
+
+const testDefs = `{
+  "updated": "2023-06-01",
+  "formats": [
+    {"id": "acme/0", "name": "ACME Generic", "extensions": ["acm"], "signatures": [[{"hex": "41434D45"}]]},
+    {"id": "acme/1", "name": "ACME Widget", "mime": "application/x-acme-widget", "extensions": ["wdg", ".wdgt"],
+     "signatures": [[{"position": "eof", "hex": "454E44"}, {"hex": "41434D45 ?? 01", "offset": 2, "maxoffset": 10}]], "priorities": ["acme/0"]}
+  ]
+}`
+
+func writeDefs(t *testing.T, defs string) string {
+	path := filepath.Join(t.TempDir(), "defs.json")
+	if err := os.WriteFile(path, []byte(defs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCustom(t *testing.T) {
+	c, err := newCustom(writeDefs(t, testDefs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := c.IDs(); len(ids) != 2 || ids[1] != "acme/1" {
+		t.Errorf("bad ids, got %v", ids)
+	}
+	if globs, ids := c.Globs(); len(globs) != 3 || globs[2] != "*.wdgt" || ids[2] != "acme/1" {
+		t.Errorf("bad globs, got %v %v", globs, ids)
+	}
+	if mimes, _ := c.MIMEs(); len(mimes) != 1 || mimes[0] != "application/x-acme-widget" {
+		t.Errorf("bad MIMEs, got %v", mimes)
+	}
+	if p := c.Priorities(); len(p["acme/0"]) != 1 || p["acme/0"][0] != "acme/1" {
+		t.Errorf("expecting acme/1 to have priority over acme/0, got %v", p)
+	}
+	sigs, ids, _ := c.Signatures()
+	expect := frames.Signature{
+		frames.NewFrame(frames.BOF, patterns.Sequence("ACME"), 2, 10),
+		frames.NewFrame(frames.PREV, patterns.Sequence{1}, 1, 1),
+		frames.NewFrame(frames.EOF, patterns.Sequence("END"), 0, 0),
+	}
+	if len(sigs) != 2 || ids[1] != "acme/1" || !sigs[1].Equals(expect) {
+		t.Errorf("bad signatures, got %v %v", sigs, ids)
+	}
+}
+
+func TestBadDefs(t *testing.T) {
+	for _, defs := range []string{
+		`{"formats": [{"name": "no id"}]}`,
+		`{"formats": [{"id": "a"}, {"id": "a"}]}`,
+		`{"formats": [{"id": "a", "signatures": [[{"hex": "4G"}]]}]}`,
+		`{"formats": [{"id": "a", "signatures": [[{"hex": "????"}]]}]}`,
+		`{"formats": [{"id": "a", "signatures": [[{"hex": "41", "position": "middle"}]]}]}`,
+		`{"formats": [{"id": "a", "signatures": [[{"hex": "41", "offset": 8, "maxoffset": 4}]]}]}`,
+		`{"formats": [`,
+	} {
+		if _, err := newCustom(writeDefs(t, defs)); err == nil {
+			t.Errorf("expecting an error for %s", defs)
+		}
+	}
+}
+
+func TestDehex(t *testing.T) {
+	frags, gaps, err := dehex("?? 4142 ?? ?? 43 ??")
+	if err != nil || len(frags) != 2 || string(frags[0]) != "AB" || string(frags[1]) != "C" {
+		t.Fatalf("bad fragments, got %q %v", frags, err)
+	}
+	if len(gaps) != 3 || gaps[0] != 1 || gaps[1] != 2 || gaps[2] != 1 {
+		t.Errorf("bad gaps, got %v", gaps)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	arMagic  = "!<arch>\n"
+	arHdrLen = 60
+)
+
+// ErrNotAR is returned by NewAR if content doesn't begin with the ar global header.
+var ErrNotAR = errors.New("decompress: not an ar archive")
+
+var errBadAR = errors.New("decompress: bad ar member size")
+
+type arD struct {
+	p     string
+	ra    io.ReaderAt
+	off   int64 // offset of the next header
+	names []byte
+	deb   bool // the archive is a Debian package
+	// current member
+	name string
+	mod  time.Time
+	dat  *io.SectionReader
+	rdr  io.Reader
+	sz   int64
+	tar  bool // member is a compressed tarball that has been decompressed
+}
+
+// NewAR returns a Decompressor for ar archives. GNU and BSD long names are supported.
+// When the archive is a Debian package (its first member is debian-binary), compressed control and data tarballs
+// (gzip, bzip2 and xz) are decompressed so that their contents can be scanned as tar archives.
+func NewAR(ra io.ReaderAt, path string) (Decompressor, error) {
+	buf := make([]byte, len(arMagic))
+	if _, err := ra.ReadAt(buf, 0); err != nil || string(buf) != arMagic {
+		return nil, ErrNotAR
+	}
+	return &arD{p: path, ra: ra, off: int64(len(arMagic))}, nil
+}
+
+func (a *arD) Next() error {
+	for {
+		hdr := make([]byte, arHdrLen)
+		if _, err := a.ra.ReadAt(hdr, a.off); err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return err
+		}
+		if hdr[58] != '`' || hdr[59] != '\n' {
+			return errors.New("decompress: bad ar header")
+		}
+		sz, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || sz < 0 {
+			return errBadAR
+		}
+		start := a.off + arHdrLen
+		// the member's data must be within the archive (checked by reading its last byte)
+		if sz > 0 {
+			if _, err := a.ra.ReadAt(make([]byte, 1), start+sz-1); err != nil {
+				return errBadAR
+			}
+		}
+		a.off = start + sz + sz%2 // data is padded to an even offset
+		name := strings.TrimSpace(string(hdr[:16]))
+		switch {
+		case name == "/" || name == "/SYM64/" || name == "__.SYMDEF" || name == "__.SYMDEF SORTED":
+			continue // symbol tables
+		case name == "//": // GNU long name table
+			a.names = make([]byte, sz)
+			if _, err := a.ra.ReadAt(a.names, start); err != nil {
+				return err
+			}
+			continue
+		case strings.HasPrefix(name, "#1/"): // BSD long names are stored at the start of the data
+			l, err := strconv.Atoi(name[3:])
+			if err != nil || int64(l) > sz {
+				return errors.New("decompress: bad BSD ar name")
+			}
+			nm := make([]byte, l)
+			if _, err := a.ra.ReadAt(nm, start); err != nil {
+				return err
+			}
+			name = strings.TrimRight(string(nm), "\x00")
+			start, sz = start+int64(l), sz-int64(l)
+		case len(name) > 1 && name[0] == '/': // GNU long name
+			idx, err := strconv.Atoi(name[1:])
+			if err != nil || idx >= len(a.names) {
+				return errors.New("decompress: bad GNU ar name")
+			}
+			name = string(a.names[idx:])
+			if i := strings.Index(name, "/\n"); i > -1 {
+				name = name[:i]
+			}
+		default:
+			name = strings.TrimSuffix(name, "/")
+		}
+		if start == int64(len(arMagic))+arHdrLen && name == "debian-binary" {
+			a.deb = true
+		}
+		a.name = name
+		a.mod = time.Time{}
+		if secs, err := strconv.ParseInt(strings.TrimSpace(string(hdr[16:28])), 10, 64); err == nil {
+			a.mod = time.Unix(secs, 0)
+		}
+		a.dat = io.NewSectionReader(a.ra, start, sz)
+		a.rdr, a.sz, a.tar = a.dat, sz, false
+		if a.deb {
+			a.rdr, a.sz, a.tar = debTarball(name, a.dat)
+		}
+		return nil
+	}
+}
+
+// debTarball decompresses the control and data tarballs of a Debian package.
+// Only gzip records the uncompressed size: for other compression types it is reported as 0.
+func debTarball(name string, sr *io.SectionReader) (io.Reader, int64, bool) {
+	if !strings.HasPrefix(name, "control.tar.") && !strings.HasPrefix(name, "data.tar.") {
+		return sr, sr.Size(), false
+	}
+	switch name[strings.LastIndex(name, ".")+1:] {
+	case "gz":
+		buf := make([]byte, 4)
+		if _, err := sr.ReadAt(buf, sr.Size()-4); err != nil {
+			break
+		}
+		if r, err := gzip.NewReader(sr); err == nil {
+			return r, int64(binary.LittleEndian.Uint32(buf)), true
+		}
+	case "bz2":
+		return bzip2.NewReader(sr), 0, true
+	case "xz":
+		if r, err := xz.NewReader(sr); err == nil {
+			return r, 0, true
+		}
+	}
+	sr.Seek(0, io.SeekStart)
+	return sr, sr.Size(), false
+}
+
+func (a *arD) Reader() io.Reader {
+	return a.rdr
+}
+
+func (a *arD) Path() string {
+	if a.tar {
+		return Arcpath(a.p, a.name[:strings.LastIndex(a.name, ".")])
+	}
+	return Arcpath(a.p, a.name)
+}
+
+func (a *arD) MIME() string {
+	return ""
+}
+
+func (a *arD) Size() int64 {
+	return a.sz
+}
+
+func (a *arD) Mod() time.Time {
+	return a.mod
+}
+
+func (a *arD) Dirs() []string {
+	return nil
+}
@@ -0,0 +1,47 @@
+package decompress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func arMember(name, size, data string) string {
+	return fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", name, "0", "0", "0", "644", size) + data
+}
+
+func TestAR(t *testing.T) {
+	ar := arMagic + arMember("hello.txt/", "5", "hello\n") + arMember("b/", "2", "hi")
+	d, err := NewAR(strings.NewReader(ar), "test.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for err = d.Next(); err == nil; err = d.Next() {
+		names = append(names, d.Path())
+	}
+	if err != io.EOF || len(names) != 2 || names[0] != "test.a#hello.txt" || names[1] != "test.a#b" {
+		t.Errorf("bad ar members, got %v, %v", names, err)
+	}
+}
+
+func TestBadAR(t *testing.T) {
+	for _, ar := range []string{
+		arMagic + arMember("loop/", "-60", ""),   // a negative size would move back onto the same header
+		arMagic + arMember("big/", "1000", "hi"), // beyond the end of the archive
+		arMagic + arMember("nan/", "x", ""),
+	} {
+		d, err := NewAR(strings.NewReader(ar), "bad.a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var i int
+		for err = d.Next(); err == nil && i < 10; err = d.Next() {
+			i++
+		}
+		if err != errBadAR {
+			t.Errorf("expecting a bad size error, got %v after %d members", err, i)
+		}
+	}
+}
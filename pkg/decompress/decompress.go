@@ -12,10 +12,11 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package decompress provides zip, tar, gzip and webarchive decompression/unpacking
+// Package decompress provides zip, tar, gzip, bzip2, xz, zstd, ar, 7z, rar, ISO 9660/UDF, webarchive and email (mbox, EML, MSG and PST) decompression/unpacking
 package decompress
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -67,12 +68,32 @@ func New(arc config.Archive, buf *siegreader.Buffer, path string, sz int64) (Dec
 		return newZip(siegreader.ReaderFrom(buf), path, sz)
 	case config.Gzip:
 		return newGzip(buf, path)
+	case config.Bzip2:
+		return newBzip2(buf, path)
+	case config.XZ:
+		return newXZ(buf, path)
+	case config.Zstd:
+		return newZstd(buf, path)
 	case config.Tar:
-		return newTar(siegreader.ReaderFrom(buf), path)
+		return newTar(buf, path)
 	case config.ARC:
 		return newARC(siegreader.ReaderFrom(buf), path)
 	case config.WARC:
 		return newWARC(siegreader.ReaderFrom(buf), path)
+	case config.AR:
+		return NewAR(siegreader.ReaderFrom(buf), path)
+	case config.SevenZip:
+		return New7z(siegreader.ReaderFrom(buf), path, sz)
+	case config.RAR:
+		return NewRAR(siegreader.ReaderFrom(buf), path, sz)
+	case config.ISO:
+		return NewISO(siegreader.ReaderFrom(buf), path, sz)
+	case config.MBOX, config.EML:
+		return NewMail(siegreader.ReaderFrom(buf), path)
+	case config.MSG:
+		return NewMSG(buf, path)
+	case config.PST:
+		return NewPST(siegreader.ReaderFrom(buf), path)
 	}
 	return nil, fmt.Errorf("Decompress: unknown archive type %v", arc)
 }
@@ -141,13 +162,21 @@ func (z *zipD) Dirs() []string {
 
 type tarD struct {
 	p       string
+	buf     *siegreader.Buffer // set if members can be read in place
+	src     *siegreader.Reader
+	off     int64 // offset of the current member's data
 	hdr     *tar.Header
 	rdr     *tar.Reader
 	written map[string]bool
 }
 
-func newTar(r io.Reader, path string) (Decompressor, error) {
-	return &tarD{p: path, rdr: tar.NewReader(r)}, nil
+func newTar(b *siegreader.Buffer, path string) (Decompressor, error) {
+	t := &tarD{p: path, src: siegreader.ReaderFrom(b)}
+	if !b.Stream() {
+		t.buf = b
+	}
+	t.rdr = tar.NewReader(t.src)
+	return t, nil
 }
 
 func (t *tarD) Next() error {
@@ -155,13 +184,30 @@ func (t *tarD) Next() error {
 	// scan past directories
 	for t.hdr, err = t.rdr.Next(); err == nil && t.hdr.FileInfo().IsDir(); t.hdr, err = t.rdr.Next() {
 	}
+	if err == nil {
+		t.off, _ = t.src.Seek(0, io.SeekCurrent) // the tar reader doesn't read ahead, so this is the start of the member's data
+	}
 	return err
 }
 
+// Reader returns the member in place, rather than copying it to a stream buffer, if the tar has random access
+// (i.e. isn't a stream) and the member isn't sparse
 func (t *tarD) Reader() io.Reader {
+	if t.buf != nil && t.hdr.Typeflag == tar.TypeReg && !sparse(t.hdr) {
+		return newSection(t.buf, t.off, t.hdr.Size)
+	}
 	return t.rdr
 }
 
+func sparse(hdr *tar.Header) bool {
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *tarD) Path() string {
 	return Arcpath(t.p, filepath.FromSlash(t.hdr.Name))
 }
@@ -185,23 +231,88 @@ func (t *tarD) Dirs() []string {
 	return dirs(t.p, t.hdr.Name, t.written)
 }
 
+// ErrTruncated is returned by Rewind if a compressed stream is itself too big to buffer in full.
+var ErrTruncated = errors.New("stream is too big to buffer without a temp file")
+
+// Rewinder is implemented by decompressors of compressed streams. Rewind decompresses the current member again
+// from its start: this allows a member that was too big to buffer in full (see config.SetNoTemp) to be read again.
+type Rewinder interface {
+	Rewind() (io.Reader, error)
+}
+
+// section is a member that is stored contiguously within an archive with random access. It is identified in place,
+// as an external source for siegreader, rather than by copying it to a stream buffer (or, for large members, a temp file).
+type section struct {
+	buf     *siegreader.Buffer
+	off, sz int64
+	*io.SectionReader
+}
+
+func newSection(buf *siegreader.Buffer, off, sz int64) *section {
+	return &section{buf, off, sz, io.NewSectionReader(siegreader.ReaderFrom(buf), off, sz)}
+}
+
+func (s *section) IsSlicer() bool { return true }
+
+func (s *section) Slice(off int64, l int) ([]byte, error) {
+	if off >= s.sz {
+		return nil, io.EOF
+	}
+	var err error
+	if off+int64(l) > s.sz {
+		l, err = int(s.sz-off), io.EOF
+	}
+	slc, serr := s.buf.Slice(s.off+off, l)
+	if serr != nil && serr != io.EOF {
+		return nil, serr
+	}
+	return slc, err
+}
+
+func (s *section) EofSlice(off int64, l int) ([]byte, error) {
+	if off >= s.sz {
+		return nil, io.EOF
+	}
+	var err error
+	if off+int64(l) > s.sz {
+		l, err = int(s.sz-off), io.EOF
+	}
+	slc, serr := s.Slice(s.sz-off-int64(l), l)
+	if serr != nil && serr != io.EOF {
+		return nil, serr
+	}
+	return slc, err
+}
+
+func (s *section) Size() int64 { return s.sz }
+
 type gzipD struct {
 	sz   int64
 	p    string
 	read bool
+	buf  *siegreader.Buffer
 	rdr  *gzip.Reader
 }
 
 func newGzip(b *siegreader.Buffer, path string) (Decompressor, error) {
 	b.Quit = make(chan struct{}) // in case a stream with a closed quit channel, make a new one
-	_ = b.SizeNow()              // in case a stream, force full read
-	buf, err := b.EofSlice(0, 4) // gzip stores uncompressed size in last 4 bytes of the stream
-	if err != nil {
-		return nil, err
+	var sz int64
+	if streamSize(b) >= 0 { // the size is unknown, without a full read, for a stream if temp files are disabled
+		buf, err := b.EofSlice(0, 4) // gzip stores uncompressed size in last 4 bytes of the stream
+		if err != nil {
+			return nil, err
+		}
+		sz = int64(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16 | uint32(buf[3])<<24)
 	}
-	sz := int64(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16 | uint32(buf[3])<<24)
 	g, err := gzip.NewReader(siegreader.ReaderFrom(b))
-	return &gzipD{sz: sz, p: path, rdr: g}, err
+	return &gzipD{sz: sz, p: path, buf: b, rdr: g}, err
+}
+
+func (g *gzipD) Rewind() (io.Reader, error) {
+	if g.buf.Truncated() {
+		return nil, ErrTruncated
+	}
+	return gzip.NewReader(siegreader.ReaderFrom(g.buf))
 }
 
 func (g *gzipD) Next() error {
@@ -256,6 +367,15 @@ func trimWebPath(p string) string {
 	return p
 }
 
+// RecordFields are the names of the fields reported for each web archive record.
+var RecordFields = []string{"target_uri", "record_date"}
+
+// Recorder is implemented by the WARC and ARC decompressors.
+// Fields reports the current record's target URI and date, in the order of RecordFields.
+type Recorder interface {
+	Fields() []string
+}
+
 type wa struct {
 	p   string
 	rec webarchive.Record
@@ -302,6 +422,10 @@ func (w *wa) Dirs() []string {
 	return nil
 }
 
+func (w *wa) Fields() []string {
+	return []string{w.rec.URL(), w.rec.Date().Format(time.RFC3339)}
+}
+
 func dirs(path, name string, written map[string]bool) []string {
 	ds := strings.Split(filepath.ToSlash(name), "/")
 	if len(ds) > 1 {
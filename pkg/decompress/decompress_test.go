@@ -0,0 +1,103 @@
+package decompress
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+func tarTest(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range []struct{ name, content string }{
+		{"dir/", ""},
+		{"dir/a.txt", "hello world\n"},
+		{"b.txt", "goodbye"},
+	} {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content))}
+		if f.content == "" {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.content))
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func TestTar(t *testing.T) {
+	tf := filepath.Join(t.TempDir(), "test.tar")
+	if err := os.WriteFile(tf, tarTest(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bufs := siegreader.New()
+	for _, stream := range []bool{false, true} {
+		var src io.Reader = f
+		if stream {
+			src = bytes.NewReader(tarTest(t))
+		}
+		buf, _ := bufs.Get(src)
+		d, err := New(config.Tar, buf, "test.tar", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expect := range []string{"hello world\n", "goodbye"} {
+			if err = d.Next(); err != nil {
+				t.Fatal(err)
+			}
+			r := d.Reader()
+			// members of a file are read in place
+			if sec, ok := r.(*section); ok == stream {
+				t.Errorf("stream %v: expecting a section only when reading a file", stream)
+			} else if ok {
+				if slc, _ := sec.EofSlice(0, 3); string(slc) != expect[len(expect)-3:] {
+					t.Errorf("bad EOF slice of %s: %q", d.Path(), slc)
+				}
+			}
+			if byt, _ := io.ReadAll(r); string(byt) != expect {
+				t.Errorf("stream %v: expecting %q, got %q", stream, expect, byt)
+			}
+		}
+		if err = d.Next(); err != io.EOF {
+			t.Errorf("expecting EOF, got %v", err)
+		}
+		bufs.Put(buf)
+	}
+}
+
+func TestRewind(t *testing.T) {
+	gz := &bytes.Buffer{}
+	w := gzip.NewWriter(gz)
+	w.Write([]byte("hello world\n"))
+	w.Close()
+	bufs := siegreader.New()
+	buf, _ := bufs.Get(bytes.NewReader(gz.Bytes()))
+	defer bufs.Put(buf)
+	d, err := New(config.Gzip, buf, "hello.gz", int64(gz.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Next()
+	io.ReadAll(d.Reader())
+	r, err := d.(Rewinder).Rewind()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byt, _ := io.ReadAll(r); string(byt) != "hello world\n" {
+		t.Errorf("expecting the member to be decompressed again, got %q", byt)
+	}
+}
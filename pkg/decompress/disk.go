@@ -0,0 +1,338 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+)
+
+// ErrNoPartitions is returned when content doesn't begin with a valid MBR or GPT partition table.
+var ErrNoPartitions = errors.New("decompress: no partition table")
+
+const sectorSz = 512
+
+// Partition is an entry in a disk image's partition table.
+type Partition struct {
+	Start int64  // offset of the partition in bytes
+	Size  int64  // size of the partition in bytes
+	Type  string // name of the partition type (or the raw type if not recognised)
+}
+
+// Partitions parses the MBR or GPT partition table at the start of a disk image of size sz.
+// It returns the table type ("mbr" or "gpt") and the partitions in table order.
+// Extended MBR partitions are followed and their logical partitions returned in place of the extended partition.
+func Partitions(ra io.ReaderAt, sz int64) (string, []Partition, error) {
+	mbr := make([]byte, sectorSz)
+	if _, err := ra.ReadAt(mbr, 0); err != nil || mbr[510] != 0x55 || mbr[511] != 0xAA {
+		return "", nil, ErrNoPartitions
+	}
+	// boot sectors of FAT and NTFS volumes also end 0x55AA
+	if Filesystem(ra) != "" {
+		return "", nil, ErrNoPartitions
+	}
+	entries, ok := mbrEntries(mbr)
+	if !ok {
+		return "", nil, ErrNoPartitions
+	}
+	if len(entries) > 0 && entries[0].typ == 0xEE {
+		parts, err := gptPartitions(ra, sz)
+		return "gpt", parts, err
+	}
+	var parts []Partition
+	for _, e := range entries {
+		start, size := int64(e.start)*sectorSz, int64(e.sectors)*sectorSz
+		switch e.typ {
+		case 0x05, 0x0F, 0x85:
+			parts = append(parts, ebrPartitions(ra, start, sz)...)
+			continue
+		}
+		if start+size > sz {
+			return "", nil, ErrNoPartitions
+		}
+		parts = append(parts, Partition{start, size, mbrType(e.typ)})
+	}
+	if len(parts) == 0 {
+		return "", nil, ErrNoPartitions
+	}
+	return "mbr", parts, nil
+}
+
+type mbrEntry struct {
+	typ     byte
+	start   uint32
+	sectors uint32
+}
+
+// mbrEntries returns the non-empty entries in a MBR or EBR, and false if any entry is invalid
+func mbrEntries(sec []byte) ([]mbrEntry, bool) {
+	var ret []mbrEntry
+	for i := 0; i < 4; i++ {
+		e := sec[446+i*16 : 446+i*16+16]
+		if e[0] != 0x00 && e[0] != 0x80 {
+			return nil, false
+		}
+		if e[4] == 0 {
+			continue
+		}
+		me := mbrEntry{e[4], binary.LittleEndian.Uint32(e[8:]), binary.LittleEndian.Uint32(e[12:])}
+		if me.sectors == 0 {
+			return nil, false
+		}
+		ret = append(ret, me)
+	}
+	return ret, true
+}
+
+// follow a chain of extended boot records
+func ebrPartitions(ra io.ReaderAt, ext, sz int64) []Partition {
+	var parts []Partition
+	ebr := make([]byte, sectorSz)
+	off := ext
+	for i := 0; i < 128; i++ {
+		if _, err := ra.ReadAt(ebr, off); err != nil || ebr[510] != 0x55 || ebr[511] != 0xAA {
+			break
+		}
+		entries, ok := mbrEntries(ebr)
+		if !ok || len(entries) == 0 {
+			break
+		}
+		start, size := off+int64(entries[0].start)*sectorSz, int64(entries[0].sectors)*sectorSz
+		if start+size <= sz {
+			parts = append(parts, Partition{start, size, mbrType(entries[0].typ)})
+		}
+		if len(entries) < 2 {
+			break
+		}
+		off = ext + int64(entries[1].start)*sectorSz
+	}
+	return parts
+}
+
+func gptPartitions(ra io.ReaderAt, sz int64) ([]Partition, error) {
+	hdr := make([]byte, 92)
+	if _, err := ra.ReadAt(hdr, sectorSz); err != nil || string(hdr[:8]) != "EFI PART" {
+		return nil, ErrNoPartitions
+	}
+	lba := int64(binary.LittleEndian.Uint64(hdr[72:]))
+	num := binary.LittleEndian.Uint32(hdr[80:])
+	esz := binary.LittleEndian.Uint32(hdr[84:])
+	if esz < 128 || num > 1024 {
+		return nil, fmt.Errorf("decompress: bad GPT header; %d entries of size %d", num, esz)
+	}
+	var parts []Partition
+	entry := make([]byte, esz)
+	for i := int64(0); i < int64(num); i++ {
+		if _, err := ra.ReadAt(entry, lba*sectorSz+i*int64(esz)); err != nil {
+			return parts, err
+		}
+		if bytes.Equal(entry[:16], make([]byte, 16)) {
+			continue
+		}
+		first, last := int64(binary.LittleEndian.Uint64(entry[32:])), int64(binary.LittleEndian.Uint64(entry[40:]))
+		start, size := first*sectorSz, (last-first+1)*sectorSz
+		if last < first || start+size > sz {
+			continue
+		}
+		parts = append(parts, Partition{start, size, gptType(entry[:16])})
+	}
+	return parts, nil
+}
+
+func mbrType(t byte) string {
+	switch t {
+	case 0x01:
+		return "FAT12"
+	case 0x04, 0x06, 0x0E:
+		return "FAT16"
+	case 0x07:
+		return "NTFS/exFAT"
+	case 0x0B, 0x0C:
+		return "FAT32"
+	case 0x82:
+		return "Linux swap"
+	case 0x83:
+		return "Linux"
+	case 0x8E:
+		return "Linux LVM"
+	case 0xA5:
+		return "FreeBSD"
+	case 0xAF:
+		return "HFS/HFS+"
+	case 0xEF:
+		return "EFI system"
+	}
+	return fmt.Sprintf("0x%02X", t)
+}
+
+// GUIDs are stored with the first three groups little endian
+func guid(b []byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]), binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
+
+var gptTypes = map[string]string{
+	"C12A7328-F81F-11D2-BA4B-00A0C93EC93B": "EFI system",
+	"EBD0A0A2-B9E5-4433-87C0-68B6B72699C7": "Microsoft basic data",
+	"E3C9E316-0B5C-4DB8-817D-F92DF00215AE": "Microsoft reserved",
+	"0FC63DAF-8483-4772-8E79-3D69D8477DE4": "Linux",
+	"0657FD6D-A4AB-43C4-84E5-0933C84B4F4F": "Linux swap",
+	"E6D6D379-F507-44C2-A23C-238F2A3DF928": "Linux LVM",
+	"48465300-0000-11AA-AA11-00306543ECAC": "HFS+",
+	"7C3457EF-0000-11AA-AA11-00306543ECAC": "APFS",
+}
+
+func gptType(b []byte) string {
+	g := guid(b)
+	if t, ok := gptTypes[g]; ok {
+		return t
+	}
+	return g
+}
+
+// Filesystem reports the type of filesystem found by checking for the superblocks (or boot sectors) of common filesystems
+// at the start of the content. It returns an empty string if no filesystem is recognised.
+func Filesystem(ra io.ReaderAt) string {
+	check := func(off int64, magic string) bool {
+		buf := make([]byte, len(magic))
+		_, err := ra.ReadAt(buf, off)
+		return err == nil && string(buf) == magic
+	}
+	switch {
+	case check(3, "NTFS    "):
+		return "ntfs"
+	case check(3, "EXFAT   "):
+		return "exfat"
+	case check(82, "FAT32   "):
+		return "fat32"
+	case check(54, "FAT16   "):
+		return "fat16"
+	case check(54, "FAT12   "):
+		return "fat12"
+	case check(1080, "\x53\xEF"):
+		return "ext"
+	case check(1024, "H+"), check(1024, "HX"):
+		return "hfs+"
+	case check(32, "NXSB"):
+		return "apfs"
+	case check(32769, "CD001"):
+		return "iso9660"
+	}
+	return ""
+}
+
+type diskD struct {
+	idx   int
+	p     string
+	ra    io.ReaderAt
+	parts []Partition
+}
+
+// NewDisk returns a Decompressor for a raw (dd) or EWF (E01) disk image. The members of an image with an MBR or GPT partition table are its
+// partitions (and, as these are scanned in turn, the files of their filesystems). The members of an image of a single volume are the files
+// of its filesystem, if an opener is registered for it (see RegisterFS). An EWF image with neither has its media as a single member.
+// It returns ErrNoPartitions if the buffer isn't an EWF image and doesn't begin with a valid partition table or a supported filesystem.
+func NewDisk(buf *siegreader.Buffer, path string) (Decompressor, error) {
+	var ra io.ReaderAt = siegreader.ReaderFrom(buf)
+	sz := buf.SizeNow()
+	var media *ewfMedia
+	if IsEWF(ra) {
+		var err error
+		if media, err = newEWF(ra, path, sz); err != nil {
+			return nil, err
+		}
+		ra, sz = media, media.sz
+	}
+	d, err := disk(ra, path, sz)
+	if media == nil {
+		return d, err
+	}
+	if err == ErrNoPartitions {
+		d, err = &fsD{p: path, ra: ra, idx: -1, files: []fsFile{{name: "media", size: sz, extents: []fsExtent{{0, sz}}}}}, nil
+	}
+	if err != nil {
+		media.Close()
+		return nil, err
+	}
+	return &closeD{d, media}, nil
+}
+
+func disk(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	_, parts, err := Partitions(ra, sz)
+	if err == nil {
+		return &diskD{idx: -1, p: path, ra: ra, parts: parts}, nil
+	}
+	if err != ErrNoPartitions {
+		return nil, err
+	}
+	d, err := OpenFS(ra, path, sz)
+	if err == ErrNoFilesystem {
+		return nil, ErrNoPartitions
+	}
+	return d, err
+}
+
+// closeD closes the segment files of a multi-segment image once its members have been read
+type closeD struct {
+	Decompressor
+	c io.Closer
+}
+
+func (d *closeD) Next() error {
+	err := d.Decompressor.Next()
+	if err != nil {
+		d.c.Close()
+	}
+	return err
+}
+
+func (d *diskD) Next() error {
+	d.idx++
+	if d.idx >= len(d.parts) {
+		return io.EOF
+	}
+	return nil
+}
+
+func (d *diskD) Reader() io.Reader {
+	return &runs{ra: d.ra, extents: []fsExtent{{d.parts[d.idx].Start, d.parts[d.idx].Size}}, sz: d.parts[d.idx].Size}
+}
+
+func (d *diskD) Path() string {
+	return Arcpath(d.p, fmt.Sprintf("partition%d", d.idx+1))
+}
+
+func (d *diskD) MIME() string {
+	return ""
+}
+
+func (d *diskD) Size() int64 {
+	return d.parts[d.idx].Size
+}
+
+func (d *diskD) Mod() time.Time {
+	return time.Time{}
+}
+
+func (d *diskD) Dirs() []string {
+	return nil
+}
+
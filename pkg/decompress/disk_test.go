@@ -0,0 +1,523 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+)
+
+var le = binary.LittleEndian
+
+func fatEntry(name string, attr byte, case_ byte, clus uint16, sz uint32) []byte {
+	e := make([]byte, 32)
+	copy(e, name)
+	e[11], e[12] = attr, case_
+	le.PutUint16(e[22:], 5<<11|6<<5|3) // 05:06:06
+	le.PutUint16(e[24:], 42<<9|3<<5|4) // 2022-03-04
+	le.PutUint16(e[26:], clus)
+	le.PutUint32(e[28:], sz)
+	return e
+}
+
+// fatLFN returns the long file name entries for a short name entry, in the order they precede it
+func fatLFN(name string, short []byte) []byte {
+	u := append(utf16.Encode([]rune(name)), 0)
+	for len(u)%13 != 0 {
+		u = append(u, 0xffff)
+	}
+	n := len(u) / 13
+	var ret []byte
+	for seq := n; seq > 0; seq-- {
+		e := make([]byte, 32)
+		e[0] = byte(seq)
+		if seq == n {
+			e[0] |= 0x40
+		}
+		e[11], e[13] = 0x0f, fatSum(short)
+		part := u[(seq-1)*13 : seq*13]
+		j := 0
+		for _, r := range [][2]int{{1, 11}, {14, 26}, {28, 32}} {
+			for k := r[0]; k < r[1]; k += 2 {
+				le.PutUint16(e[k:], part[j])
+				j++
+			}
+		}
+		ret = append(ret, e...)
+	}
+	return ret
+}
+
+// a FAT12 or FAT16 volume with a long file name, a lower cased short name, a deleted file, and a fragmented file in a subdirectory
+func fatTest(bits int) []byte {
+	total, fatSz, label := 64, 1, "FAT12   "
+	if bits == 16 {
+		total, fatSz, label = 4200, 17, "FAT16   "
+	}
+	img := make([]byte, total*512)
+	le.PutUint16(img[11:], 512)
+	img[13] = 1
+	le.PutUint16(img[14:], 1)
+	img[16] = 1
+	le.PutUint16(img[17:], 16)
+	le.PutUint16(img[19:], uint16(total))
+	le.PutUint16(img[22:], uint16(fatSz))
+	copy(img[54:], label)
+	img[510], img[511] = 0x55, 0xaa
+	fat := img[512:]
+	set := func(c, v uint16) {
+		if bits == 16 {
+			le.PutUint16(fat[c*2:], v)
+			return
+		}
+		off := int(c + c/2)
+		cur := le.Uint16(fat[off:])
+		if c&1 == 1 {
+			cur = cur&0x000f | v<<4
+		} else {
+			cur = cur&0xf000 | v&0xfff
+		}
+		le.PutUint16(fat[off:], cur)
+	}
+	eoc := uint16(0xffff)
+	for _, c := range []uint16{2, 3, 4, 7} {
+		set(c, eoc)
+	}
+	set(5, 7)
+	root := img[(1+fatSz)*512:]
+	clus := func(c int) []byte { return img[(2+fatSz+c-2)*512:] }
+	short := fatEntry("LONGFI~1TXT", 0x20, 0, 2, 4)
+	var r []byte
+	r = append(r, fatEntry("VOLUME     ", 0x08, 0, 0, 0)...)
+	r = append(r, fatLFN("Long File Name.txt", short)...)
+	r = append(r, short...)
+	r = append(r, fatEntry("A       TXT", 0x20, 0x18, 3, 6)...)
+	deleted := fatEntry("\xe5OLD    TXT", 0x20, 0, 6, 3)
+	r = append(r, deleted...)
+	r = append(r, fatEntry("D          ", 0x10, 0, 4, 0)...)
+	copy(root, r)
+	copy(clus(2), "long")
+	copy(clus(3), "hello\n")
+	copy(clus(6), "old")
+	d := append(fatEntry(".          ", 0x10, 0, 4, 0), fatEntry("..         ", 0x10, 0, 0, 0)...)
+	copy(clus(4), append(d, fatEntry("B       TXT", 0x20, 0, 5, 600)...))
+	copy(clus(5), strings.Repeat("x", 512))
+	copy(clus(7), strings.Repeat("y", 88))
+	return img
+}
+
+// an ext filesystem with 1K blocks: a small file and a file with a hole and an indirect block (mapped by block maps), and a directory
+// with a file mapped by extents (with a hole and an unwritten extent)
+func extTest() []byte {
+	const bs = 1024
+	img := make([]byte, 128*bs)
+	sb := img[1024:]
+	le.PutUint32(sb[0:], 16)
+	le.PutUint32(sb[4:], 128)
+	le.PutUint32(sb[20:], 1)
+	le.PutUint32(sb[40:], 16)
+	le.PutUint16(sb[56:], 0xef53)
+	le.PutUint32(sb[76:], 1)
+	le.PutUint16(sb[88:], 256)
+	le.PutUint32(sb[96:], 2)
+	le.PutUint32(img[2*bs+8:], 3) // inode table
+	inode := func(n int, mode uint16, sz int, flags uint32) []byte {
+		ino := img[3*bs+(n-1)*256:]
+		le.PutUint16(ino, mode)
+		le.PutUint32(ino[4:], uint32(sz))
+		le.PutUint32(ino[16:], 1646370367)
+		le.PutUint32(ino[32:], flags)
+		return ino[40:100]
+	}
+	blk := func(n int) []byte { return img[n*bs : (n+1)*bs] }
+	dirent := func(n uint32, name string, typ byte, last bool, off int) []byte {
+		rl := (8 + len(name) + 3) &^ 3
+		if last {
+			rl = bs - off
+		}
+		e := make([]byte, rl)
+		le.PutUint32(e, n)
+		le.PutUint16(e[4:], uint16(rl))
+		e[6], e[7] = byte(len(name)), typ
+		copy(e[8:], name)
+		return e
+	}
+	dir := func(b []byte, ents ...[]byte) {
+		var off int
+		for _, e := range ents {
+			copy(b[off:], e)
+			off += len(e)
+		}
+	}
+	var off int
+	ents := [][]byte{}
+	for i, e := range []struct {
+		n    uint32
+		name string
+		typ  byte
+	}{{2, ".", 2}, {2, "..", 2}, {12, "a.txt", 1}, {13, "d", 2}, {15, "big.bin", 1}, {16, "link", 7}} {
+		ent := dirent(e.n, e.name, e.typ, i == 5, off)
+		off += len(ent)
+		ents = append(ents, ent)
+	}
+	le.PutUint32(inode(2, 0x41ed, bs, 0), 10)
+	dir(blk(10), ents...)
+	le.PutUint32(inode(12, 0x81a4, 6, 0), 20)
+	copy(blk(20), "hello\n")
+	// big.bin: 14 blocks, the second a hole, the last two by the indirect block
+	ib := inode(15, 0x81a4, 14*bs-1, 0)
+	for i := 0; i < 12; i++ {
+		if i != 1 {
+			le.PutUint32(ib[i*4:], uint32(30+i))
+			copy(blk(30+i), bytes.Repeat([]byte{'a' + byte(i)}, bs))
+		}
+	}
+	le.PutUint32(ib[48:], 50)
+	le.PutUint32(blk(50), 51)
+	le.PutUint32(blk(50)[4:], 52)
+	copy(blk(51), bytes.Repeat([]byte{'m'}, bs))
+	copy(blk(52), bytes.Repeat([]byte{'n'}, bs))
+	le.PutUint32(inode(16, 0xa1ff, 5, 0), 0)
+	// d, by an extent
+	extents := func(b []byte, leaves ...[3]uint32) {
+		le.PutUint16(b, 0xf30a)
+		le.PutUint16(b[2:], uint16(len(leaves)))
+		le.PutUint16(b[4:], 4)
+		for i, l := range leaves {
+			e := b[12+i*12:]
+			le.PutUint32(e, l[0])
+			le.PutUint16(e[4:], uint16(l[1]))
+			le.PutUint32(e[8:], l[2])
+		}
+	}
+	extents(inode(13, 0x41ed, bs, 0x80000), [3]uint32{0, 1, 11})
+	off = 0
+	ents = ents[:0]
+	for i, e := range []struct {
+		n    uint32
+		name string
+	}{{13, "."}, {2, ".."}, {14, "b.bin"}} {
+		ent := dirent(e.n, e.name, 1, i == 2, off)
+		off += len(ent)
+		ents = append(ents, ent)
+	}
+	dir(blk(11), ents...)
+	extents(inode(14, 0x81a4, 2*bs+10, 0x80000), [3]uint32{2, 32768 + 1, 61}, [3]uint32{1, 1, 60})
+	copy(blk(60), bytes.Repeat([]byte{'b'}, bs))
+	copy(blk(61), bytes.Repeat([]byte{'u'}, bs))
+	return img
+}
+
+func ntfsAttr(typ uint32, val []byte) []byte {
+	l := (24 + len(val) + 7) &^ 7
+	a := make([]byte, l)
+	le.PutUint32(a, typ)
+	le.PutUint32(a[4:], uint32(l))
+	le.PutUint32(a[16:], uint32(len(val)))
+	le.PutUint16(a[20:], 24)
+	copy(a[24:], val)
+	return a
+}
+
+func ntfsNonres(sz int64, flags uint16, vcn int64, runlist ...byte) []byte {
+	l := (64 + len(runlist) + 1 + 7) &^ 7
+	a := make([]byte, l)
+	le.PutUint32(a, 0x80)
+	le.PutUint32(a[4:], uint32(l))
+	a[8] = 1
+	le.PutUint16(a[12:], flags)
+	le.PutUint64(a[16:], uint64(vcn))
+	le.PutUint16(a[32:], 64)
+	le.PutUint64(a[48:], uint64(sz))
+	copy(a[64:], runlist)
+	return a
+}
+
+func ntfsFN(parent uint64, ns byte, name string) []byte {
+	u := utf16.Encode([]rune(name))
+	v := make([]byte, 66+len(u)*2)
+	le.PutUint64(v, parent)
+	v[64], v[65] = byte(len(u)), ns
+	for i, c := range u {
+		le.PutUint16(v[66+i*2:], c)
+	}
+	return ntfsAttr(0x30, v)
+}
+
+func ntfsSI() []byte {
+	v := make([]byte, 48)
+	le.PutUint64(v[8:], 132908439670000000) // 2022-03-04 05:06:07 UTC
+	return ntfsAttr(0x10, v)
+}
+
+// an NTFS volume (512 byte clusters, 1K records): a resident file with a DOS name, a fragmented and sparse file in a subdirectory,
+// a compressed file, and a file whose data is in an extension record
+func ntfsTest() []byte {
+	img := make([]byte, 100*512)
+	copy(img[3:], "NTFS    ")
+	le.PutUint16(img[11:], 512)
+	img[13] = 1
+	le.PutUint64(img[48:], 16)
+	img[64] = 0xf6
+	img[510], img[511] = 0x55, 0xaa
+	rec := func(n int, flags uint16, base uint64, attrs ...[]byte) {
+		r := img[16*512+n*1024 : 16*512+(n+1)*1024]
+		copy(r, "FILE")
+		le.PutUint16(r[4:], 48)
+		le.PutUint16(r[6:], 3)
+		le.PutUint16(r[20:], 56)
+		le.PutUint16(r[22:], flags)
+		le.PutUint64(r[32:], base)
+		off := 56
+		for _, a := range attrs {
+			copy(r[off:], a)
+			off += len(a)
+		}
+		le.PutUint32(r[off:], 0xffffffff)
+		// fixups
+		r[48], r[49] = 7, 0
+		for i := 1; i < 3; i++ {
+			copy(r[48+i*2:], r[i*512-2:i*512])
+			r[i*512-2], r[i*512-1] = 7, 0
+		}
+	}
+	rec(0, 1, 0, ntfsFN(5, 3, "$MFT"), ntfsNonres(22*1024, 0, 0, 0x11, 44, 16))
+	rec(5, 3, 0, ntfsSI(), ntfsFN(5, 3, "."))
+	rec(16, 3, 0, ntfsSI(), ntfsFN(5, 1, "d"))
+	rec(17, 1, 0, ntfsSI(), ntfsFN(5, 2, "A~1.TXT"), ntfsFN(5, 1, "a.txt"), ntfsAttr(0x80, []byte("hello\n")))
+	rec(18, 1, 0, ntfsSI(), ntfsFN(16, 1, "b.bin"), ntfsNonres(3*512-100, 0, 0, 0x11, 1, 80, 0x01, 1, 0x11, 1, 0xfe))
+	rec(19, 1, 0, ntfsSI(), ntfsFN(5, 1, "c.bin"), ntfsNonres(512, 1, 0, 0x11, 1, 90))
+	rec(20, 1, 21, ntfsNonres(5, 0, 0, 0x11, 1, 91))
+	rec(21, 1, 0, ntfsSI(), ntfsFN(5, 3, "e.txt"))
+	copy(img[80*512:], bytes.Repeat([]byte{'b'}, 512))
+	copy(img[78*512:], bytes.Repeat([]byte{'c'}, 512))
+	copy(img[91*512:], "extra")
+	return img
+}
+
+// ewfTest stores an image in EWF segments of 4K chunks, alternately compressed and uncompressed
+func ewfTest(img []byte, segs int) [][]byte {
+	const csz = 4096
+	n := (len(img) + csz - 1) / csz
+	per := (n + segs - 1) / segs
+	section := func(b []byte, typ string, data []byte, last bool) []byte {
+		d := make([]byte, 76)
+		copy(d, typ)
+		off := len(b)
+		le.PutUint64(d[24:], uint64(76+len(data)))
+		if last {
+			le.PutUint64(d[16:], uint64(off))
+		} else {
+			le.PutUint64(d[16:], uint64(off+76+len(data)))
+		}
+		return append(append(b, d...), data...)
+	}
+	var ret [][]byte
+	for s := 0; s < segs; s++ {
+		b := append([]byte(ewfMagic), 1, byte(s+1), 0, 0, 0)
+		if s == 0 {
+			vol := make([]byte, 1052)
+			le.PutUint32(vol[4:], uint32(n))
+			le.PutUint32(vol[8:], csz/512)
+			le.PutUint32(vol[12:], 512)
+			le.PutUint64(vol[16:], uint64(len(img)/512))
+			b = section(b, "volume", vol, false)
+		}
+		var data, table []byte
+		start := len(b) + 76
+		for c := s * per; c < (s+1)*per && c < n; c++ {
+			end := (c + 1) * csz
+			if end > len(img) {
+				end = len(img)
+			}
+			e := uint32(start + len(data))
+			if c%2 == 0 {
+				var z bytes.Buffer
+				zw := zlib.NewWriter(&z)
+				zw.Write(img[c*csz : end])
+				zw.Close()
+				data = append(data, z.Bytes()...)
+				e |= 0x80000000
+			} else {
+				data = append(append(data, img[c*csz:end]...), 0, 0, 0, 0)
+			}
+			table = append(table, 0, 0, 0, 0)
+			le.PutUint32(table[len(table)-4:], e)
+		}
+		b = section(b, "sectors", data, false)
+		hdr := make([]byte, 24)
+		le.PutUint32(hdr, uint32(len(table)/4))
+		b = section(b, "table", append(append(hdr, table...), 0, 0, 0, 0), false)
+		if s == segs-1 {
+			b = section(b, "done", nil, true)
+		} else {
+			b = section(b, "next", nil, true)
+		}
+		ret = append(ret, b)
+	}
+	return ret
+}
+
+func fsMembers(t *testing.T, name string, d Decompressor, expect []isoMember) {
+	for _, e := range expect {
+		if err := d.Next(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if d.Path() != "test.img#"+filepath.FromSlash(e.name) {
+			t.Errorf("%s: expecting %s, got %s", name, e.name, d.Path())
+		}
+		// members that can't be read are expected with no content
+		if e.content == "" {
+			continue
+		}
+		if d.Size() != int64(len(e.content)) {
+			t.Errorf("%s: expecting %d bytes for %s, got %d", name, len(e.content), e.name, d.Size())
+		}
+		if byt, err := io.ReadAll(d.Reader()); string(byt) != e.content {
+			t.Errorf("%s: expecting content %q for %s, got %q (%v)", name, e.content, e.name, byt, err)
+		}
+	}
+	if err := d.Next(); err != io.EOF {
+		t.Errorf("%s: expecting EOF, got %v (%s)", name, err, d.Path())
+	}
+}
+
+func TestFilesystems(t *testing.T) {
+	fatFiles := []isoMember{{"Long File Name.txt", "long"}, {"a.txt", "hello\n"}, {"D/B.TXT", strings.Repeat("x", 512) + strings.Repeat("y", 88)}}
+	var big string
+	for i := 0; i < 12; i++ {
+		c := "a" + string(rune('a'+i))
+		if i == 1 {
+			c = "\x00"
+		}
+		big += strings.Repeat(c[len(c)-1:], 1024)
+	}
+	big += strings.Repeat("m", 1024) + strings.Repeat("n", 1023)
+	for _, v := range []struct {
+		name   string
+		img    []byte
+		expect []isoMember
+	}{
+		{"fat12", fatTest(12), fatFiles},
+		{"fat16", fatTest(16), fatFiles},
+		{"ext", extTest(), []isoMember{{"a.txt", "hello\n"}, {"d/b.bin", strings.Repeat("\x00", 1024) + strings.Repeat("b", 1024) + strings.Repeat("\x00", 10)}, {"big.bin", big}}},
+		{"ntfs", ntfsTest(), []isoMember{{"a.txt", "hello\n"}, {"c.bin", ""}, {"d/b.bin", strings.Repeat("b", 512) + strings.Repeat("\x00", 512) + strings.Repeat("c", 412)}, {"e.txt", "extra"}}},
+	} {
+		if fs := Filesystem(bytes.NewReader(v.img)); fs != v.name && !(v.name == "ext" && fs == "ext") {
+			t.Errorf("%s: Filesystem returned %q", v.name, fs)
+		}
+		d, err := OpenFS(bytes.NewReader(v.img), "test.img", int64(len(v.img)))
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		fsMembers(t, v.name, d, v.expect)
+	}
+	// an ext inode whose size has the sign bit set, for a file and for a directory
+	for _, n := range []int{12, 2} {
+		img := extTest()
+		le.PutUint32(img[3*1024+(n-1)*256+108:], 0x80000000)
+		if _, err := OpenFS(bytes.NewReader(img), "test.img", int64(len(img))); err != errBadExt {
+			t.Errorf("ext: expecting a bad ext error for a negative size in inode %d, got %v", n, err)
+		}
+	}
+	d, _ := OpenFS(bytes.NewReader(fatTest(12)), "test.img", 64*512)
+	d.Next()
+	if d.Mod().Format("2006-01-02 15:04:05") != "2022-03-04 05:06:06" {
+		t.Errorf("fat: bad modified time %v", d.Mod())
+	}
+	d, _ = OpenFS(bytes.NewReader(ntfsTest()), "test.img", 100*512)
+	d.Next()
+	if d.Mod().Unix() != 1646370367 {
+		t.Errorf("ntfs: bad modified time %v", d.Mod())
+	}
+	d.Next()
+	if _, err := io.ReadAll(d.Reader()); err != errNTFSData {
+		t.Errorf("ntfs: expecting an error for a compressed file, got %v", err)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	// an MBR with a FAT16 partition
+	vol := fatTest(16)
+	img := make([]byte, 2048+len(vol))
+	img[446+4] = 0x06
+	le.PutUint32(img[446+8:], 4)
+	le.PutUint32(img[446+12:], uint32(len(vol)/512))
+	img[510], img[511] = 0x55, 0xaa
+	copy(img[2048:], vol)
+	bufs := siegreader.New()
+	for _, v := range []struct {
+		name   string
+		img    []byte
+		expect string
+	}{
+		{"mbr", img, "partition1"},
+		{"volume", vol, "Long File Name.txt"},
+		{"ewf", ewfTest(img, 1)[0], "partition1"},
+		{"ewf media", ewfTest([]byte(strings.Repeat("media", 2000)), 1)[0], "media"},
+	} {
+		buf, _ := bufs.Get(bytes.NewReader(v.img))
+		d, err := NewDisk(buf, "test.img")
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if err = d.Next(); err != nil || d.Path() != "test.img#"+v.expect {
+			t.Errorf("%s: expecting %s, got %s (%v)", v.name, v.expect, d.Path(), err)
+		}
+		if v.name == "mbr" {
+			if byt, _ := io.ReadAll(d.Reader()); !bytes.Equal(byt, vol) {
+				t.Errorf("%s: bad partition content", v.name)
+			}
+		}
+		bufs.Put(buf)
+	}
+	buf, _ := bufs.Get(strings.NewReader("not a disk image"))
+	if _, err := NewDisk(buf, "test.img"); err != ErrNoPartitions {
+		t.Errorf("expecting ErrNoPartitions, got %v", err)
+	}
+	bufs.Put(buf)
+}
+
+func TestEWF(t *testing.T) {
+	for in, out := range map[string]string{"a.E01": "a.E02", "a.E09": "a.E10", "a.E99": "a.EAA", "a.EAZ": "a.EBA", "a.EZZ": "a.FAA", "a.e01": "a.e02"} {
+		if got := ewfNext(in); got != out {
+			t.Errorf("expecting %s after %s, got %s", out, in, got)
+		}
+	}
+	img := fatTest(12)
+	dir := t.TempDir()
+	segs := ewfTest(img, 3)
+	for i, s := range segs {
+		if err := os.WriteFile(filepath.Join(dir, "test.E0"+string(rune('1'+i))), s, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := newEWF(bytes.NewReader(segs[0]), filepath.Join(dir, "test.E01"), int64(len(segs[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(img))
+	if _, err = m.ReadAt(got, 0); err != nil || !bytes.Equal(got, img) {
+		t.Errorf("bad media: %v", err)
+	}
+	m.Close()
+	os.Remove(filepath.Join(dir, "test.E03"))
+	if _, err = newEWF(bytes.NewReader(segs[0]), filepath.Join(dir, "test.E01"), int64(len(segs[0]))); err != errEWFSegment {
+		t.Errorf("expecting a missing segment error, got %v", err)
+	}
+	// a table that claims more entries than the segment has room for
+	seg := ewfTest(img, 1)[0]
+	tbl := bytes.Index(seg, []byte("table\x00"))
+	le.PutUint64(seg[tbl+24:], 1<<40)
+	le.PutUint32(seg[tbl+76:], 0xFFFFFFFF)
+	if _, err = newEWF(bytes.NewReader(seg), filepath.Join(dir, "bad.E01"), int64(len(seg))); err != errBadEWF {
+		t.Errorf("expecting a bad EWF error for an oversized table, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const ewfMagic = "EVF\x09\x0d\x0a\xff\x00"
+
+var (
+	errBadEWF     = errors.New("decompress: bad EWF (E01) image")
+	errEWFSegment = errors.New("decompress: missing segment file of EWF (E01) image")
+)
+
+// IsEWF reports whether a reader begins with the signature of an EWF (Expert Witness/EnCase E01) segment file.
+func IsEWF(ra io.ReaderAt) bool {
+	buf := make([]byte, len(ewfMagic))
+	_, err := ra.ReadAt(buf, 0)
+	return err == nil && string(buf) == ewfMagic
+}
+
+type ewfChunk struct {
+	seg      int
+	off, len int64
+	zlib     bool
+}
+
+// ewfMedia reads the media stored in the chunks of an EWF image
+type ewfMedia struct {
+	segs    []io.ReaderAt
+	closers []io.Closer
+	chunks  []ewfChunk
+	csz     int64 // chunk size
+	sz      int64
+
+	mu    sync.Mutex
+	cache int
+	buf   []byte
+}
+
+// newEWF opens an EWF image from its first segment file. If the image has more segments, they are opened from files with the following
+// extensions (E02 ... E99, EAA ...) beside path.
+func newEWF(ra io.ReaderAt, path string, sz int64) (*ewfMedia, error) {
+	m := &ewfMedia{cache: -1}
+	var want int64 = -1
+	for seg := 0; ; seg++ {
+		if seg > 0 {
+			if want >= 0 && int64(len(m.chunks)) >= want {
+				break
+			}
+			path = ewfNext(path)
+			f, err := os.Open(path)
+			if err != nil {
+				m.Close()
+				return nil, errEWFSegment
+			}
+			m.closers = append(m.closers, f)
+			fi, err := f.Stat()
+			if err != nil || !IsEWF(f) {
+				m.Close()
+				return nil, errBadEWF
+			}
+			ra, sz = f, fi.Size()
+		}
+		m.segs = append(m.segs, ra)
+		done, err := m.segment(seg, ra, sz, &want)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	if m.csz == 0 || int64(len(m.chunks))*m.csz < m.sz {
+		m.Close()
+		return nil, errBadEWF
+	}
+	return m, nil
+}
+
+// segment reads the sections of a segment file, adding its chunks. It reports whether this is the last segment.
+func (m *ewfMedia) segment(seg int, ra io.ReaderAt, sz int64, want *int64) (bool, error) {
+	desc := make([]byte, 76)
+	var sectors [2]int64 // the data span of the last sectors section
+	for off, i := int64(13), 0; off+76 <= sz && i < 1<<16; i++ {
+		if _, err := ra.ReadAt(desc, off); err != nil {
+			return false, errBadEWF
+		}
+		typ, next, size := string(bytes.TrimRight(desc[:16], "\x00")), int64(binary.LittleEndian.Uint64(desc[16:])), int64(binary.LittleEndian.Uint64(desc[24:]))
+		switch typ {
+		case "volume", "disk":
+			vol := make([]byte, 24)
+			if _, err := ra.ReadAt(vol, off+76); err != nil {
+				return false, errBadEWF
+			}
+			spc, bps := int64(binary.LittleEndian.Uint32(vol[8:])), int64(binary.LittleEndian.Uint32(vol[12:]))
+			*want, m.csz = int64(binary.LittleEndian.Uint32(vol[4:])), spc*bps
+			m.sz = int64(binary.LittleEndian.Uint32(vol[16:]))
+			if size-76 >= 1052 { // EnCase volume sections have a 64-bit sector count; SMART ones 32-bit
+				m.sz = int64(binary.LittleEndian.Uint64(vol[16:]))
+			}
+			m.sz *= bps
+			if m.csz <= 0 || m.csz > 1<<26 {
+				return false, errBadEWF
+			}
+		case "sectors":
+			sectors = [2]int64{off + 76, off + size}
+		case "table":
+			hdr := make([]byte, 24)
+			if _, err := ra.ReadAt(hdr, off+76); err != nil {
+				return false, errBadEWF
+			}
+			n, base := int64(binary.LittleEndian.Uint32(hdr)), int64(binary.LittleEndian.Uint64(hdr[8:]))
+			if n > (size-100)/4 || n > (sz-off-100)/4 || n < 0 {
+				return false, errBadEWF
+			}
+			ents := make([]byte, n*4)
+			if _, err := ra.ReadAt(ents, off+100); err != nil {
+				return false, errBadEWF
+			}
+			for j := int64(0); j < n; j++ {
+				e := binary.LittleEndian.Uint32(ents[j*4:])
+				c := ewfChunk{seg: seg, off: base + int64(e&0x7fffffff), zlib: e&0x80000000 != 0}
+				// a chunk runs to the next, and the last to the end of the sectors section
+				end := sectors[1]
+				if j+1 < n {
+					end = base + int64(binary.LittleEndian.Uint32(ents[j*4+4:])&0x7fffffff)
+				} else if end <= c.off {
+					end = off
+				}
+				c.len = end - c.off
+				m.chunks = append(m.chunks, c)
+			}
+		case "done":
+			return true, nil
+		case "next":
+			return false, nil
+		}
+		if next <= off {
+			break
+		}
+		off = next
+	}
+	return false, errBadEWF
+}
+
+func (m *ewfMedia) chunk(i int) ([]byte, error) {
+	if i == m.cache {
+		return m.buf, nil
+	}
+	c := m.chunks[i]
+	if c.len <= 0 || c.len > m.csz+m.csz/2+64 {
+		return nil, errBadEWF
+	}
+	rdr := io.Reader(io.NewSectionReader(m.segs[c.seg], c.off, c.len))
+	if c.zlib {
+		zr, err := zlib.NewReader(rdr)
+		if err != nil {
+			return nil, errBadEWF
+		}
+		rdr = zr
+	}
+	if m.buf == nil {
+		m.buf = make([]byte, m.csz)
+	}
+	m.cache = -1
+	if _, err := io.ReadFull(rdr, m.buf); err != nil && (err != io.ErrUnexpectedEOF || i < len(m.chunks)-1) {
+		return nil, errBadEWF
+	}
+	m.cache = i
+	return m.buf, nil
+}
+
+func (m *ewfMedia) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if off >= m.sz {
+		return 0, io.EOF
+	}
+	var err error
+	if rem := m.sz - off; int64(len(p)) > rem {
+		p, err = p[:rem], io.EOF
+	}
+	for n := 0; n < len(p); {
+		buf, cerr := m.chunk(int((off + int64(n)) / m.csz))
+		if cerr != nil {
+			return n, cerr
+		}
+		n += copy(p[n:], buf[(off+int64(n))%m.csz:])
+	}
+	return len(p), err
+}
+
+func (m *ewfMedia) Close() error {
+	for _, c := range m.closers {
+		c.Close()
+	}
+	m.closers = nil
+	return nil
+}
+
+// ewfNext returns the name of the following segment file: the extension counts from E01 to E99, then to EAA ... EZZ, FAA and so on.
+func ewfNext(path string) string {
+	ext := filepath.Ext(path)
+	if len(ext) != 4 {
+		return path + ".E02"
+	}
+	b := []byte(ext[1:])
+	lower := strings.ToLower(ext) == ext
+	a := byte('A')
+	if lower {
+		a = 'a'
+	}
+	switch {
+	case b[1] >= '0' && b[1] <= '9':
+		if string(b[1:]) == "99" {
+			b[1], b[2] = a, a
+		} else if b[2] == '9' {
+			b[1], b[2] = b[1]+1, '0'
+		} else {
+			b[2]++
+		}
+	case b[2] != a+25:
+		b[2]++
+	case b[1] != a+25:
+		b[1], b[2] = b[1]+1, a
+	default:
+		b[0], b[1], b[2] = b[0]+1, a, a
+	}
+	return strings.TrimSuffix(path, ext) + "." + string(b)
+}
@@ -0,0 +1,297 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+var (
+	errBadExt         = errors.New("decompress: bad ext2/3/4 filesystem")
+	errExtUnsupported = errors.New("decompress: unsupported ext4 feature (meta_bg)")
+)
+
+func init() {
+	RegisterFS("ext", NewExt)
+}
+
+type extVol struct {
+	ra       io.ReaderAt
+	bs       int64 // block size
+	ipg      uint32
+	inodes   uint32
+	isz      int64
+	descSz   int64
+	is64     bool
+	filetype bool // directory entries have a file type byte
+	gdt      []byte
+	d        *fsD
+	visited  map[uint32]bool
+}
+
+// NewExt returns a Decompressor for the regular files of an ext2, ext3 or ext4 filesystem. The data of files is mapped by their
+// block maps or (for ext4) extent trees, and unwritten extents read as zeros. Symbolic links and special files are skipped.
+func NewExt(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	sb := make([]byte, 1024)
+	if _, err := ra.ReadAt(sb, 1024); err != nil || binary.LittleEndian.Uint16(sb[56:]) != 0xef53 {
+		return nil, errBadExt
+	}
+	lbs := binary.LittleEndian.Uint32(sb[24:])
+	v := &extVol{ra: ra, inodes: binary.LittleEndian.Uint32(sb[0:]), ipg: binary.LittleEndian.Uint32(sb[40:]), isz: 128, descSz: 32, visited: make(map[uint32]bool)}
+	if lbs > 6 || v.ipg == 0 {
+		return nil, errBadExt
+	}
+	v.bs = 1024 << lbs
+	if binary.LittleEndian.Uint32(sb[76:]) > 0 { // dynamic revision
+		v.isz = int64(binary.LittleEndian.Uint16(sb[88:]))
+	}
+	incompat := binary.LittleEndian.Uint32(sb[96:])
+	if incompat&0x10 != 0 {
+		return nil, errExtUnsupported
+	}
+	v.filetype = incompat&0x2 != 0
+	if incompat&0x80 != 0 {
+		v.is64, v.descSz = true, int64(binary.LittleEndian.Uint16(sb[254:]))
+	}
+	groups := (int64(v.inodes) + int64(v.ipg) - 1) / int64(v.ipg)
+	if v.isz < 128 || v.isz > v.bs || v.descSz < 32 || groups*v.descSz > maxDir {
+		return nil, errBadExt
+	}
+	// the group descriptors are in the block after the superblock
+	v.gdt = make([]byte, groups*v.descSz)
+	if _, err := ra.ReadAt(v.gdt, (int64(binary.LittleEndian.Uint32(sb[20:]))+1)*v.bs); err != nil {
+		return nil, errBadExt
+	}
+	v.d = &fsD{p: path, ra: ra, idx: -1}
+	v.visited[2] = true
+	return v.d, v.walk("", 2)
+}
+
+func (v *extVol) inode(n uint32) ([]byte, error) {
+	if n == 0 || n > v.inodes {
+		return nil, errBadExt
+	}
+	g, i := int64((n-1)/v.ipg), int64((n-1)%v.ipg)
+	desc := v.gdt[g*v.descSz:]
+	table := int64(binary.LittleEndian.Uint32(desc[8:]))
+	if v.is64 {
+		table |= int64(binary.LittleEndian.Uint32(desc[40:])) << 32
+	}
+	buf := make([]byte, v.isz)
+	if _, err := v.ra.ReadAt(buf, table*v.bs+i*v.isz); err != nil {
+		return nil, errBadExt
+	}
+	return buf, nil
+}
+
+// file returns the size, modification time, and the extents (or inline data) of an inode
+func (v *extVol) file(ino []byte) (fsFile, error) {
+	f := fsFile{
+		size: int64(binary.LittleEndian.Uint32(ino[4:])) | int64(binary.LittleEndian.Uint32(ino[108:]))<<32,
+		mod:  time.Unix(int64(binary.LittleEndian.Uint32(ino[16:])), 0).UTC(),
+	}
+	if f.size < 0 { // the high word of the size sets the sign bit
+		return f, errBadExt
+	}
+	flags := binary.LittleEndian.Uint32(ino[32:])
+	var err error
+	switch {
+	case flags&0x10000000 != 0: // inline data (only the part in i_block is read)
+		f.data = ino[40:100]
+	case flags&0x80000 != 0:
+		var leaves [][4]int64
+		if leaves, err = v.extents(ino[40:100], 0, nil); err == nil {
+			sort.Slice(leaves, func(i, j int) bool { return leaves[i][0] < leaves[j][0] })
+			f.extents = v.runs(leaves)
+		}
+	default:
+		f.extents, err = v.blockmap(ino[40:100], f.size)
+	}
+	return f, err
+}
+
+// extents returns the leaves of an extent tree: logical block, length, physical block (or -1 if unwritten)
+func (v *extVol) extents(node []byte, depth int, leaves [][4]int64) ([][4]int64, error) {
+	if len(node) < 12 || binary.LittleEndian.Uint16(node) != 0xf30a || depth > 5 {
+		return nil, errBadExt
+	}
+	n, leaf := int(binary.LittleEndian.Uint16(node[2:])), binary.LittleEndian.Uint16(node[6:]) == 0
+	for i := 0; i < n && 24+i*12 <= len(node); i++ {
+		e := node[12+i*12:]
+		if leaf {
+			l, p := int64(binary.LittleEndian.Uint16(e[4:])), int64(binary.LittleEndian.Uint16(e[6:]))<<32|int64(binary.LittleEndian.Uint32(e[8:]))
+			if l > 32768 {
+				l, p = l-32768, -1
+			}
+			leaves = append(leaves, [4]int64{int64(binary.LittleEndian.Uint32(e)), l, p})
+			continue
+		}
+		child := make([]byte, v.bs)
+		if _, err := v.ra.ReadAt(child, (int64(binary.LittleEndian.Uint16(e[8:]))<<32|int64(binary.LittleEndian.Uint32(e[4:])))*v.bs); err != nil {
+			return nil, errBadExt
+		}
+		var err error
+		if leaves, err = v.extents(child, depth+1, leaves); err != nil {
+			return nil, err
+		}
+	}
+	return leaves, nil
+}
+
+// runs converts sorted leaves to extents, with sparse extents for holes
+func (v *extVol) runs(leaves [][4]int64) []fsExtent {
+	var ret []fsExtent
+	var pos int64
+	for _, l := range leaves {
+		if l[0] < pos {
+			continue
+		}
+		if l[0] > pos {
+			ret = append(ret, fsExtent{-1, (l[0] - pos) * v.bs})
+		}
+		off := int64(-1)
+		if l[2] >= 0 {
+			off = l[2] * v.bs
+		}
+		ret = append(ret, fsExtent{off, l[1] * v.bs})
+		pos = l[0] + l[1]
+	}
+	return ret
+}
+
+// blockmap maps the blocks of an ext2/3 file: twelve direct blocks, then single, double and triple indirect blocks
+func (v *extVol) blockmap(iblock []byte, sz int64) ([]fsExtent, error) {
+	n := (sz + v.bs - 1) / v.bs
+	if sz < 0 || n > maxDir {
+		return nil, errBadExt
+	}
+	blocks := make([]uint32, 0, n)
+	ppb := v.bs / 4
+	var fill func(ptr uint32, level int) error
+	fill = func(ptr uint32, level int) error {
+		if level == 0 || ptr == 0 {
+			cover := int64(1)
+			for i := 0; i < level; i++ {
+				cover *= ppb
+			}
+			for j := int64(0); j < cover && int64(len(blocks)) < n; j++ {
+				blocks = append(blocks, ptr)
+				if ptr != 0 {
+					break
+				}
+			}
+			return nil
+		}
+		buf := make([]byte, v.bs)
+		if _, err := v.ra.ReadAt(buf, int64(ptr)*v.bs); err != nil {
+			return errBadExt
+		}
+		for i := int64(0); i < ppb && int64(len(blocks)) < n; i++ {
+			if err := fill(binary.LittleEndian.Uint32(buf[i*4:]), level-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := 0; i < 15 && int64(len(blocks)) < n; i++ {
+		level := 0
+		if i >= 12 {
+			level = i - 11
+		}
+		if err := fill(binary.LittleEndian.Uint32(iblock[i*4:]), level); err != nil {
+			return nil, err
+		}
+	}
+	var ret []fsExtent
+	for i, b := range blocks {
+		off := int64(-1)
+		if b != 0 {
+			off = int64(b) * v.bs
+		}
+		if i > 0 {
+			last := &ret[len(ret)-1]
+			if (off < 0 && last.off < 0) || (off >= 0 && last.off >= 0 && last.off+last.len == off) {
+				last.len += v.bs
+				continue
+			}
+		}
+		ret = append(ret, fsExtent{off, v.bs})
+	}
+	return ret, nil
+}
+
+// walk adds the files in a directory and recurses into its subdirectories. Directory entries are read linearly, which also suits hashed (htree) directories.
+func (v *extVol) walk(dir string, n uint32) error {
+	ino, err := v.inode(n)
+	if err != nil {
+		return err
+	}
+	f, err := v.file(ino)
+	if err != nil {
+		return err
+	}
+	if f.size < 0 || f.size > maxDir {
+		return errBadExt
+	}
+	b := make([]byte, f.size)
+	if _, err := f.reader(v.ra).Read(b); err != nil && err != io.EOF {
+		return err
+	}
+	// inline directories begin with the parent's inode number in place of the . and .. entries
+	if binary.LittleEndian.Uint32(ino[32:])&0x10000000 != 0 && len(b) >= 4 {
+		b = b[4:]
+	}
+	for off := 0; off+8 <= len(b); {
+		e := b[off:]
+		rl, nl := int(binary.LittleEndian.Uint16(e[4:])), int(e[6])
+		if !v.filetype {
+			nl = int(binary.LittleEndian.Uint16(e[6:]))
+		}
+		if rl < 8 || off+rl > len(b) || 8+nl > rl {
+			break
+		}
+		off += rl
+		child, name := binary.LittleEndian.Uint32(e), string(e[8:8+nl])
+		if child == 0 || name == "." || name == ".." {
+			continue
+		}
+		ino, err := v.inode(child)
+		if err != nil {
+			return err
+		}
+		switch binary.LittleEndian.Uint16(ino) & 0xf000 {
+		case 0x4000:
+			if v.visited[child] {
+				continue
+			}
+			v.visited[child] = true
+			if err := v.walk(dir+name+"/", child); err != nil {
+				return err
+			}
+		case 0x8000:
+			f, err := v.file(ino)
+			if err != nil {
+				return err
+			}
+			f.name = dir + name
+			v.d.files = append(v.d.files, f)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+var errBadFAT = errors.New("decompress: bad FAT filesystem")
+
+const maxDir = 1 << 26 // limit for the size of a directory read by the filesystem readers
+
+func init() {
+	for _, fs := range []string{"fat12", "fat16", "fat32"} {
+		RegisterFS(fs, NewFAT)
+	}
+}
+
+type fatVol struct {
+	ra      io.ReaderAt
+	bits    int   // 12, 16 or 32
+	clus    int64 // bytes per cluster
+	data    int64 // offset of the data region (which starts with cluster 2)
+	n       uint32
+	fat     []byte
+	d       *fsD
+	visited map[uint32]bool
+}
+
+// NewFAT returns a Decompressor for the files of a FAT12, FAT16 or FAT32 volume. Files are named by their long file names, or their
+// short (8.3) names if they have none. Deleted files aren't recovered.
+func NewFAT(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	bs := make([]byte, 512)
+	if _, err := ra.ReadAt(bs, 0); err != nil {
+		return nil, errBadFAT
+	}
+	// the BIOS parameter block
+	bps, spc, rsv := int64(binary.LittleEndian.Uint16(bs[11:])), int64(bs[13]), int64(binary.LittleEndian.Uint16(bs[14:]))
+	fats, roots := int64(bs[16]), int64(binary.LittleEndian.Uint16(bs[17:]))
+	total, fatSz := int64(binary.LittleEndian.Uint16(bs[19:])), int64(binary.LittleEndian.Uint16(bs[22:]))
+	if total == 0 {
+		total = int64(binary.LittleEndian.Uint32(bs[32:]))
+	}
+	if fatSz == 0 {
+		fatSz = int64(binary.LittleEndian.Uint32(bs[36:]))
+	}
+	if bps < 512 || bps > 4096 || bps&(bps-1) != 0 || spc == 0 || spc&(spc-1) != 0 || fats == 0 || fatSz == 0 || fatSz*bps > maxDir {
+		return nil, errBadFAT
+	}
+	rootSecs := (roots*32 + bps - 1) / bps
+	first := rsv + fats*fatSz + rootSecs
+	if total <= first {
+		return nil, errBadFAT
+	}
+	v := &fatVol{ra: ra, clus: spc * bps, data: first * bps, n: uint32((total - first) / spc), fat: make([]byte, fatSz*bps), visited: make(map[uint32]bool)}
+	// the FAT type is determined by the count of clusters
+	switch {
+	case v.n < 4085:
+		v.bits = 12
+	case v.n < 65525:
+		v.bits = 16
+	default:
+		v.bits = 32
+	}
+	if _, err := ra.ReadAt(v.fat, rsv*bps); err != nil {
+		return nil, errBadFAT
+	}
+	v.d = &fsD{p: path, ra: ra, idx: -1}
+	if v.bits == 32 {
+		root := binary.LittleEndian.Uint32(bs[44:])
+		v.visited[root] = true
+		return v.d, v.walk("", v.chain(root, -1))
+	}
+	return v.d, v.walk("", []fsExtent{{(rsv + fats*fatSz) * bps, roots * 32}})
+}
+
+// next returns the cluster that follows c in a chain, or 0 at the end of the chain (or if the chain is broken)
+func (v *fatVol) next(c uint32) uint32 {
+	var n uint32
+	switch v.bits {
+	case 12:
+		off := int(c + c/2)
+		if off+2 > len(v.fat) {
+			return 0
+		}
+		n = uint32(binary.LittleEndian.Uint16(v.fat[off:]))
+		if c&1 == 1 {
+			n >>= 4
+		}
+		n &= 0xfff
+	case 16:
+		if int(c)*2+2 > len(v.fat) {
+			return 0
+		}
+		n = uint32(binary.LittleEndian.Uint16(v.fat[c*2:]))
+	default:
+		if int(c)*4+4 > len(v.fat) {
+			return 0
+		}
+		n = binary.LittleEndian.Uint32(v.fat[c*4:]) & 0x0fffffff
+	}
+	if n < 2 || n >= v.n+2 {
+		return 0
+	}
+	return n
+}
+
+// chain returns the extents of a chain of clusters, up to sz bytes (or to the end of the chain if sz < 0)
+func (v *fatVol) chain(c uint32, sz int64) []fsExtent {
+	var ret []fsExtent
+	var l int64
+	for i := uint32(0); c >= 2 && c < v.n+2 && i < v.n && (sz < 0 || l < sz); i++ {
+		off := v.data + int64(c-2)*v.clus
+		if len(ret) > 0 && ret[len(ret)-1].off+ret[len(ret)-1].len == off {
+			ret[len(ret)-1].len += v.clus
+		} else {
+			ret = append(ret, fsExtent{off, v.clus})
+		}
+		l += v.clus
+		c = v.next(c)
+	}
+	return ret
+}
+
+// walk adds the files in a directory and recurses into its subdirectories
+func (v *fatVol) walk(dir string, extents []fsExtent) error {
+	var sz int64
+	for _, e := range extents {
+		sz += e.len
+	}
+	if sz > maxDir {
+		return errBadFAT
+	}
+	b := make([]byte, sz)
+	if _, err := (&runs{ra: v.ra, extents: extents, sz: sz}).ReadAt(b, 0); err != nil && err != io.EOF {
+		return err
+	}
+	var lfn [][]uint16
+	var sum byte
+	for i := 0; i+32 <= len(b); i += 32 {
+		e := b[i : i+32]
+		if e[0] == 0 {
+			break
+		}
+		if e[0] == 0xe5 {
+			lfn = nil
+			continue
+		}
+		// long file name entries precede the short name entry, in reverse order
+		if e[11]&0x3f == 0x0f {
+			seq := int(e[0] & 0x1f)
+			if e[0]&0x40 != 0 {
+				lfn, sum = make([][]uint16, seq), e[13]
+			}
+			if seq == 0 || seq > len(lfn) || e[13] != sum {
+				lfn = nil
+				continue
+			}
+			part := make([]uint16, 0, 13)
+			for _, r := range [][2]int{{1, 11}, {14, 26}, {28, 32}} {
+				for j := r[0]; j < r[1]; j += 2 {
+					part = append(part, binary.LittleEndian.Uint16(e[j:]))
+				}
+			}
+			lfn[seq-1] = part
+			continue
+		}
+		name := fatShort(e)
+		if lfn != nil && fatSum(e) == sum {
+			name = fatLong(lfn)
+		}
+		lfn = nil
+		if e[11]&0x08 != 0 || name == "." || name == ".." || name == "" {
+			continue
+		}
+		c := uint32(binary.LittleEndian.Uint16(e[26:]))
+		if v.bits == 32 {
+			c |= uint32(binary.LittleEndian.Uint16(e[20:])) << 16
+		}
+		if e[11]&0x10 != 0 {
+			if c < 2 || v.visited[c] {
+				continue
+			}
+			v.visited[c] = true
+			if err := v.walk(dir+name+"/", v.chain(c, -1)); err != nil {
+				return err
+			}
+			continue
+		}
+		sz := int64(binary.LittleEndian.Uint32(e[28:]))
+		v.d.files = append(v.d.files, fsFile{name: dir + name, mod: fatTime(binary.LittleEndian.Uint16(e[24:]), binary.LittleEndian.Uint16(e[22:])), size: sz, extents: v.chain(c, sz)})
+	}
+	return nil
+}
+
+// fatShort returns an 8.3 name, lower cased as flagged by Windows NT
+func fatShort(e []byte) string {
+	str := func(b []byte, lower bool) string {
+		r := make([]rune, 0, len(b))
+		for _, c := range b {
+			r = append(r, rune(c))
+		}
+		s := strings.TrimRight(string(r), " ")
+		if lower {
+			s = strings.ToLower(s)
+		}
+		return s
+	}
+	base := append([]byte{}, e[:8]...)
+	if base[0] == 0x05 {
+		base[0] = 0xe5
+	}
+	name := str(base, e[12]&0x08 != 0)
+	if ext := str(e[8:11], e[12]&0x10 != 0); ext != "" {
+		name += "." + ext
+	}
+	return name
+}
+
+// fatSum is the checksum of a short name that is recorded in its long file name entries
+func fatSum(e []byte) byte {
+	var sum byte
+	for _, c := range e[:11] {
+		sum = (sum&1)<<7 + sum>>1 + c
+	}
+	return sum
+}
+
+func fatLong(parts [][]uint16) string {
+	var u []uint16
+	for _, p := range parts {
+		u = append(u, p...)
+	}
+	for i, c := range u {
+		if c == 0 {
+			u = u[:i]
+			break
+		}
+	}
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(string(utf16.Decode(u)))
+}
+
+// fatTime converts an MS-DOS date and time, which have no time zone
+func fatTime(d, t uint16) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Date(1980+int(d>>9), time.Month(d>>5&0xf), int(d&0x1f), int(t>>11), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.UTC)
+}
@@ -0,0 +1,209 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoFilesystem is returned by OpenFS if a volume doesn't have a filesystem with a registered opener.
+var ErrNoFilesystem = errors.New("decompress: no supported filesystem")
+
+// FSOpener opens the filesystem on a volume of size sz (such as a partition of a disk image) and returns a Decompressor for its files.
+type FSOpener func(ra io.ReaderAt, path string, sz int64) (Decompressor, error)
+
+var filesystems = make(map[string]FSOpener)
+
+// RegisterFS adds an opener for a type of filesystem, named as by Filesystem (e.g. "hfs+" or "apfs"), for NewDisk and OpenFS to use.
+// It replaces any existing opener: the native FAT, NTFS, ext and ISO 9660 readers in this package register themselves in init functions.
+func RegisterFS(fs string, fn FSOpener) {
+	filesystems[fs] = fn
+}
+
+// OpenFS returns a Decompressor for the files of the filesystem on a volume. It returns ErrNoFilesystem if the filesystem isn't recognised
+// by Filesystem or no opener is registered for it.
+func OpenFS(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	fn, ok := filesystems[Filesystem(ra)]
+	if !ok {
+		return nil, ErrNoFilesystem
+	}
+	return fn(ra, path, sz)
+}
+
+// fsExtent is a run of a member's data. Unrecorded (sparse) extents have an offset of -1 and read as zeros.
+type fsExtent struct {
+	off, len int64
+}
+
+// fsFile is a file in a filesystem
+type fsFile struct {
+	name    string
+	mod     time.Time
+	size    int64
+	extents []fsExtent
+	data    []byte // small files may be stored in their metadata (e.g. UDF file entries and NTFS MFT records)
+	err     error  // the data can't be read (e.g. it is encrypted)
+}
+
+func (f fsFile) reader(ra io.ReaderAt) io.Reader {
+	if f.err != nil {
+		return errReader{f.err}
+	}
+	if f.data != nil {
+		return io.LimitReader(bytes.NewReader(f.data), f.size)
+	}
+	return &runs{ra: ra, extents: f.extents, sz: f.size}
+}
+
+// fsD is a Decompressor for the files of a filesystem
+type fsD struct {
+	p       string
+	ra      io.ReaderAt
+	files   []fsFile
+	idx     int
+	written map[string]bool
+}
+
+func (d *fsD) Next() error {
+	d.idx++
+	if d.idx >= len(d.files) {
+		return io.EOF
+	}
+	return nil
+}
+
+func (d *fsD) Reader() io.Reader {
+	return d.files[d.idx].reader(d.ra)
+}
+
+func (d *fsD) Path() string {
+	return Arcpath(d.p, filepath.FromSlash(d.files[d.idx].name))
+}
+
+func (d *fsD) MIME() string {
+	return ""
+}
+
+func (d *fsD) Size() int64 {
+	return d.files[d.idx].size
+}
+
+func (d *fsD) Mod() time.Time {
+	return d.files[d.idx].mod
+}
+
+func (d *fsD) Dirs() []string {
+	if d.written == nil {
+		d.written = make(map[string]bool)
+	}
+	return dirs(d.p, d.files[d.idx].name, d.written)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// runs is a member stored in extents within an image with random access (such as a file in a filesystem, or a partition of a disk image).
+// Like a section, it is identified in place. Anything past the end of the extents, and in sparse extents, reads as zeros.
+type runs struct {
+	ra      io.ReaderAt
+	extents []fsExtent
+	sz      int64
+	off     int64 // for Read
+}
+
+func (r *runs) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.sz {
+		return 0, io.EOF
+	}
+	var err error
+	if rem := r.sz - off; int64(len(p)) > rem {
+		p, err = p[:rem], io.EOF
+	}
+	var n int
+	var pos int64
+	for _, e := range r.extents {
+		if n == len(p) {
+			break
+		}
+		if off+int64(n) >= pos+e.len {
+			pos += e.len
+			continue
+		}
+		start := off + int64(n) - pos
+		l := int(e.len - start)
+		if l > len(p)-n {
+			l = len(p) - n
+		}
+		if e.off < 0 {
+			zeroReader{}.Read(p[n : n+l])
+		} else if _, rerr := r.ra.ReadAt(p[n:n+l], e.off+start); rerr != nil && rerr != io.EOF {
+			return n, rerr
+		}
+		n += l
+		pos += e.len
+	}
+	zeroReader{}.Read(p[n:])
+	return len(p), err
+}
+
+func (r *runs) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	return n, err
+}
+
+func (r *runs) IsSlicer() bool { return true }
+
+func (r *runs) Slice(off int64, l int) ([]byte, error) {
+	if off >= r.sz {
+		return nil, io.EOF
+	}
+	var err error
+	if off+int64(l) > r.sz {
+		l, err = int(r.sz-off), io.EOF
+	}
+	buf := make([]byte, l)
+	if _, rerr := r.ReadAt(buf, off); rerr != nil && rerr != io.EOF {
+		return nil, rerr
+	}
+	return buf, err
+}
+
+func (r *runs) EofSlice(off int64, l int) ([]byte, error) {
+	if off >= r.sz {
+		return nil, io.EOF
+	}
+	var err error
+	if off+int64(l) > r.sz {
+		l, err = int(r.sz-off), io.EOF
+	}
+	slc, serr := r.Slice(r.sz-off-int64(l), l)
+	if serr != nil && serr != io.EOF {
+		return nil, serr
+	}
+	return slc, err
+}
+
+func (r *runs) Size() int64 { return r.sz }
@@ -0,0 +1,572 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const isoSector = 2048
+
+// ErrNotISO is returned by NewISO if content doesn't have an ISO 9660 or UDF volume descriptor.
+var ErrNotISO = errors.New("decompress: not an ISO 9660 or UDF disc image")
+
+var (
+	errBadISO = errors.New("decompress: bad ISO 9660 directory record")
+	errBadUDF = errors.New("decompress: bad UDF descriptor")
+	errVATUDF = errors.New("decompress: unsupported UDF virtual partition")
+)
+
+type isoD struct {
+	fsD
+	sz      int64
+	visited map[int64]bool // directories already walked, in case of loops
+}
+
+// NewISO returns a Decompressor for ISO 9660 and UDF disc images. UDF is read in preference to ISO 9660 when an image has both
+// (e.g. DVDs and UDF bridge discs). ISO 9660 names are taken from the Rock Ridge or, failing that, Joliet extensions when present.
+// Only regular files are unpacked: directories are reported by Dirs, and symbolic links and devices are skipped.
+func NewISO(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	return newISO(ra, path, sz)
+}
+
+func init() {
+	RegisterFS("iso9660", NewISO)
+}
+
+func newISO(ra io.ReaderAt, path string, sz int64) (*isoD, error) {
+	d := &isoD{fsD: fsD{p: path, ra: ra, idx: -1}, sz: sz, visited: make(map[int64]bool)}
+	// the volume recognition sequence starts at sector 16: ISO 9660 volume descriptors are followed by UDF's extended area descriptors
+	var pvd, joliet []byte
+	var udf bool
+	buf := make([]byte, isoSector)
+	for s := int64(16); s < 16+256; s++ {
+		if _, err := ra.ReadAt(buf, s*isoSector); err != nil {
+			break
+		}
+		id := string(buf[1:6])
+		if id == "NSR02" || id == "NSR03" {
+			udf = true
+			continue
+		}
+		if id != "CD001" && id != "BEA01" && id != "TEA01" && id != "BOOT2" && id != "CDW02" {
+			break
+		}
+		switch {
+		case id != "CD001":
+		case buf[0] == 1 && pvd == nil:
+			pvd = append([]byte(nil), buf...)
+		case buf[0] == 2 && buf[88] == '%' && buf[89] == '/' && (buf[90] == '@' || buf[90] == 'C' || buf[90] == 'E'):
+			joliet = append([]byte(nil), buf...)
+		}
+	}
+	if udf {
+		err := d.parseUDF()
+		if err == nil || pvd == nil {
+			return d, err
+		}
+		d.files, d.visited = nil, make(map[int64]bool) // fall back to ISO 9660
+	}
+	if pvd == nil {
+		return nil, ErrNotISO
+	}
+	// Rock Ridge names are recorded in the primary volume's directories: the root's "." record has a SUSP "SP" entry
+	if root, err := d.isoDir(pvd[156:190]); err == nil && len(root) > 34 && bytes.HasPrefix(isoSU(root), []byte("SP")) {
+		return d, d.walkISO("", pvd[156:190], false, true)
+	}
+	if joliet != nil {
+		return d, d.walkISO("", joliet[156:190], true, false)
+	}
+	return d, d.walkISO("", pvd[156:190], false, false)
+}
+
+// isoDir reads the contents of the directory described by a directory record
+func (d *isoD) isoDir(rec []byte) ([]byte, error) {
+	off, l := int64(binary.LittleEndian.Uint32(rec[2:]))*isoSector, int64(binary.LittleEndian.Uint32(rec[10:]))
+	if off+l > d.sz || l > 1<<26 {
+		return nil, errBadISO
+	}
+	buf := make([]byte, l)
+	_, err := d.ra.ReadAt(buf, off)
+	return buf, err
+}
+
+// isoSU returns the system use area of a directory record (where Rock Ridge entries are recorded)
+func isoSU(rec []byte) []byte {
+	start := 33 + int(rec[32])
+	if rec[32]%2 == 0 {
+		start++ // padding byte
+	}
+	if start > int(rec[0]) || int(rec[0]) > len(rec) {
+		return nil
+	}
+	return rec[start:rec[0]]
+}
+
+// walkISO adds the files in an ISO 9660 directory and recurses into its subdirectories
+func (d *isoD) walkISO(dir string, rec []byte, joliet, rr bool) error {
+	off := int64(binary.LittleEndian.Uint32(rec[2:]))
+	if d.visited[off] {
+		return nil
+	}
+	d.visited[off] = true
+	buf, err := d.isoDir(rec)
+	if err != nil {
+		return err
+	}
+	// the "." record gives the directory's size (relocated directories are found from a placeholder that doesn't)
+	if len(buf) >= 34 && int64(binary.LittleEndian.Uint32(buf[10:])) > int64(len(buf)) {
+		if buf, err = d.isoDir(buf[:34]); err != nil {
+			return err
+		}
+	}
+	var multi *fsFile // a file with the multi-extent flag continues in the following record
+	for pos := 0; pos < len(buf); {
+		l := int(buf[pos])
+		if l == 0 { // records don't cross sector boundaries
+			pos = (pos/isoSector + 1) * isoSector
+			continue
+		}
+		if l < 34 || pos+l > len(buf) || 33+int(buf[pos+32]) > l {
+			return errBadISO
+		}
+		r := buf[pos : pos+l]
+		pos += l
+		flags, nm := r[25], r[33:33+int(r[32])]
+		if len(nm) == 1 && nm[0] < 2 { // "." and ".."
+			continue
+		}
+		name := isoName(nm, joliet)
+		child := false
+		if rr {
+			var relocated bool
+			var n string
+			n, child, relocated = rockRidge(isoSU(r), r)
+			if relocated {
+				continue
+			}
+			if n != "" {
+				name = n
+			}
+		}
+		if flags&0x02 != 0 || child {
+			if err = d.walkISO(dir+name+"/", r, joliet, rr); err != nil {
+				return err
+			}
+			continue
+		}
+		ext := fsExtent{int64(binary.LittleEndian.Uint32(r[2:])) * isoSector, int64(binary.LittleEndian.Uint32(r[10:]))}
+		if ext.off+ext.len > d.sz {
+			return errBadISO
+		}
+		if multi != nil {
+			multi.extents = append(multi.extents, ext)
+			multi.size += ext.len
+		} else {
+			d.files = append(d.files, fsFile{name: dir + name, mod: isoTime(r[18:25]), size: ext.len, extents: []fsExtent{ext}})
+			multi = &d.files[len(d.files)-1]
+		}
+		if flags&0x80 == 0 {
+			multi = nil
+		}
+	}
+	return nil
+}
+
+// isoName decodes a file identifier, dropping the version number and the trailing dot of names without an extension
+func isoName(nm []byte, joliet bool) string {
+	name := string(nm)
+	if joliet {
+		name = ucs2(nm)
+	}
+	if i := strings.LastIndexByte(name, ';'); i > 0 {
+		name = name[:i]
+	}
+	if len(name) > 1 {
+		name = strings.TrimSuffix(name, ".")
+	}
+	return name
+}
+
+// rockRidge reads the alternate name (NM) from the SUSP entries in a system use area. The CL entry of a relocated directory's
+// placeholder gives the directory's location, which is then patched into the directory record; the RE entry marks the relocated directory itself.
+func rockRidge(su, rec []byte) (name string, child, relocated bool) {
+	for len(su) >= 4 {
+		l := int(su[2])
+		if l < 4 || l > len(su) {
+			break
+		}
+		switch string(su[:2]) {
+		case "NM":
+			if l > 5 && su[4]&0x06 == 0 {
+				name += string(su[5:l])
+			}
+		case "CL":
+			if l >= 12 {
+				copy(rec[2:6], su[4:8])
+				binary.LittleEndian.PutUint32(rec[10:], isoSector) // the size is read from the relocated directory's "." record
+				child = true
+			}
+		case "RE":
+			relocated = true
+		case "ST":
+			return
+		}
+		su = su[l:]
+	}
+	return
+}
+
+// isoTime decodes a directory record's date: years since 1900, month, day, hour, minute, second and offset from GMT in 15 minute intervals
+func isoTime(b []byte) time.Time {
+	if b[1] == 0 {
+		return time.Time{}
+	}
+	return time.Date(1900+int(b[0]), time.Month(b[1]), int(b[2]), int(b[3]), int(b[4]), int(b[5]), 0, time.FixedZone("", int(int8(b[6]))*15*60))
+}
+
+func ucs2(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// udfVol holds the block size and partition maps of a UDF logical volume
+type udfVol struct {
+	d     *isoD
+	bs    int64
+	parts []udfPart
+}
+
+// udfPart is a partition map: a physical partition's start, in blocks, and for metadata partitions the extents of the metadata file
+type udfPart struct {
+	start int64
+	meta  []fsExtent
+}
+
+type udfEntry struct {
+	typ     byte // 4 is a directory, 5 a regular file
+	size    int64
+	mod     time.Time
+	extents []fsExtent
+	data    []byte
+}
+
+// udfTag checks the 16 byte tag that starts UDF descriptors: its identifier and checksum
+func udfTag(b []byte, ident uint16) bool {
+	if len(b) < 16 || binary.LittleEndian.Uint16(b) != ident {
+		return false
+	}
+	var sum byte
+	for i, c := range b[:16] {
+		if i != 4 {
+			sum += c
+		}
+	}
+	return sum == b[4]
+}
+
+// parseUDF finds the anchor volume descriptor pointer at sector 256, reads the partition and logical volume descriptors
+// from the volume descriptor sequence it points to, and walks the directories from the file set descriptor's root
+func (d *isoD) parseUDF() error {
+	v := &udfVol{d: d}
+	anchor := make([]byte, 512)
+	for _, bs := range []int64{isoSector, 512, 4096} {
+		if _, err := d.ra.ReadAt(anchor, 256*bs); err == nil && udfTag(anchor, 2) {
+			v.bs = bs
+			break
+		}
+	}
+	if v.bs == 0 {
+		return errBadUDF
+	}
+	loc, l := int64(binary.LittleEndian.Uint32(anchor[20:])), int64(binary.LittleEndian.Uint32(anchor[16:]))
+	parts := make(map[uint16]int64)
+	var lvd []byte
+	var lvdSeq uint32
+	for i := int64(0); i < l/v.bs && i < 256; i++ {
+		buf := make([]byte, v.bs)
+		if _, err := d.ra.ReadAt(buf, (loc+i)*v.bs); err != nil {
+			return err
+		}
+		switch {
+		case udfTag(buf, 5): // partition descriptor
+			parts[binary.LittleEndian.Uint16(buf[22:])] = int64(binary.LittleEndian.Uint32(buf[188:]))
+		case udfTag(buf, 6): // logical volume descriptor: the one with the highest sequence number prevails
+			if seq := binary.LittleEndian.Uint32(buf[16:]); lvd == nil || seq >= lvdSeq {
+				lvd, lvdSeq = buf, seq
+			}
+		case udfTag(buf, 8): // terminating descriptor
+			i = l
+		}
+	}
+	if lvd == nil || len(lvd) < 440 || int64(binary.LittleEndian.Uint32(lvd[212:])) != v.bs {
+		return errBadUDF
+	}
+	pm := lvd[440:]
+	for n := binary.LittleEndian.Uint32(lvd[268:]); n > 0; n-- {
+		if len(pm) < 6 || int(pm[1]) < 6 || int(pm[1]) > len(pm) {
+			return errBadUDF
+		}
+		num := binary.LittleEndian.Uint16(pm[4:])
+		if pm[0] == 2 {
+			if pm[1] < 64 {
+				return errBadUDF
+			}
+			num = binary.LittleEndian.Uint16(pm[38:])
+		}
+		start, ok := parts[num]
+		if !ok {
+			return errBadUDF
+		}
+		p := udfPart{start: start}
+		if pm[0] == 2 {
+			switch id := string(bytes.TrimRight(pm[5:28], "\x00")); id {
+			case "*UDF Sparable Partition": // spared packets are read from their original location
+			case "*UDF Metadata Partition":
+				// the directories and file entries of UDF 2.5 and later are in a metadata file recorded in the physical partition
+				e, err := v.entry(&p, (start+int64(binary.LittleEndian.Uint32(pm[40:])))*v.bs)
+				if err != nil {
+					return err
+				}
+				p.meta = e.extents
+			default:
+				return errVATUDF
+			}
+		}
+		v.parts = append(v.parts, p)
+		pm = pm[pm[1]:]
+	}
+	// the file set descriptor's location is given in the logical volume's contents use field
+	off, ok := v.addr(binary.LittleEndian.Uint16(lvd[256:]), binary.LittleEndian.Uint32(lvd[252:]))
+	if !ok {
+		return errBadUDF
+	}
+	fsd := make([]byte, 512)
+	if _, err := d.ra.ReadAt(fsd, off); err != nil || !udfTag(fsd, 256) {
+		return errBadUDF
+	}
+	ref := binary.LittleEndian.Uint16(fsd[408:])
+	if off, ok = v.addr(ref, binary.LittleEndian.Uint32(fsd[404:])); !ok {
+		return errBadUDF
+	}
+	root, err := v.entry(&v.parts[ref], off)
+	if err != nil {
+		return err
+	}
+	d.visited[off] = true
+	return v.walk("", root)
+}
+
+// addr gives the offset of a logical block in a partition
+func (v *udfVol) addr(ref uint16, lbn uint32) (int64, bool) {
+	if int(ref) >= len(v.parts) {
+		return 0, false
+	}
+	return v.parts[ref].addr(lbn, v.bs)
+}
+
+func (p *udfPart) addr(lbn uint32, bs int64) (int64, bool) {
+	off := int64(lbn) * bs
+	if p.meta == nil {
+		return p.start*bs + off, true
+	}
+	for _, e := range p.meta {
+		if off < e.len {
+			return e.off + off, e.off >= 0
+		}
+		off -= e.len
+	}
+	return 0, false
+}
+
+// entry reads a file entry or extended file entry. Short allocation descriptors are relative to the partition p that records the entry.
+func (v *udfVol) entry(p *udfPart, off int64) (*udfEntry, error) {
+	buf := make([]byte, v.bs)
+	if _, err := v.d.ra.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	var lea, base, mod int
+	switch {
+	case udfTag(buf, 261):
+		lea, base, mod = 168, 176, 84
+	case udfTag(buf, 266):
+		lea, base, mod = 208, 216, 92
+	default:
+		return nil, errBadUDF
+	}
+	ea, ad := int(binary.LittleEndian.Uint32(buf[lea:])), int(binary.LittleEndian.Uint32(buf[lea+4:]))
+	if ea < 0 || ad < 0 || base+ea+ad > len(buf) {
+		return nil, errBadUDF
+	}
+	e := &udfEntry{typ: buf[27], size: int64(binary.LittleEndian.Uint64(buf[56:])), mod: udfTime(buf[mod:])}
+	if e.size < 0 {
+		return nil, errBadUDF
+	}
+	ads := buf[base+ea : base+ea+ad]
+	adt := binary.LittleEndian.Uint16(buf[34:]) & 7
+	switch adt {
+	case 0, 1: // short and long allocation descriptors
+	case 3: // the file's data is embedded in the entry
+		e.data = ads
+		return e, nil
+	default:
+		return nil, errBadUDF
+	}
+	for n := 0; n < 1024; {
+		var l uint32
+		var off int64
+		ok := true
+		if adt == 0 {
+			if len(ads) < 8 {
+				break
+			}
+			l = binary.LittleEndian.Uint32(ads)
+			off, ok = p.addr(binary.LittleEndian.Uint32(ads[4:]), v.bs)
+			ads = ads[8:]
+		} else {
+			if len(ads) < 16 {
+				break
+			}
+			l = binary.LittleEndian.Uint32(ads)
+			off, ok = v.addr(binary.LittleEndian.Uint16(ads[8:]), binary.LittleEndian.Uint32(ads[4:]))
+			ads = ads[16:]
+		}
+		ln := int64(l & 0x3fffffff)
+		if ln == 0 {
+			break
+		}
+		switch l >> 30 {
+		case 0: // recorded
+			if !ok || off+ln > v.d.sz {
+				return nil, errBadUDF
+			}
+			e.extents = append(e.extents, fsExtent{off, ln})
+		case 1, 2: // unrecorded
+			e.extents = append(e.extents, fsExtent{-1, ln})
+		case 3: // the descriptors continue in an allocation extent descriptor
+			nxt := make([]byte, v.bs)
+			if !ok {
+				return nil, errBadUDF
+			}
+			if _, err := v.d.ra.ReadAt(nxt, off); err != nil || !udfTag(nxt, 258) {
+				return nil, errBadUDF
+			}
+			if l := int(binary.LittleEndian.Uint32(nxt[20:])); l >= 0 && 24+l <= len(nxt) {
+				ads = nxt[24 : 24+l]
+			} else {
+				return nil, errBadUDF
+			}
+			n++
+		}
+	}
+	return e, nil
+}
+
+func (e *udfEntry) file(name string) fsFile {
+	return fsFile{name: name, mod: e.mod, size: e.size, extents: e.extents, data: e.data}
+}
+
+// walk adds the files in a UDF directory, read from its file identifier descriptors, and recurses into subdirectories
+func (v *udfVol) walk(dir string, e *udfEntry) error {
+	if e.size > 1<<26 {
+		return errBadUDF
+	}
+	f := e.file("")
+	buf, err := io.ReadAll(f.reader(v.d.ra))
+	if err != nil {
+		return err
+	}
+	for pos := 0; pos+38 <= len(buf); {
+		b := buf[pos:]
+		if !udfTag(b, 257) {
+			return errBadUDF
+		}
+		chars, lfi, liu := b[18], int(b[19]), int(binary.LittleEndian.Uint16(b[36:]))
+		if 38+liu+lfi > len(b) {
+			return errBadUDF
+		}
+		pos += (38 + liu + lfi + 3) &^ 3
+		if chars&0x0c != 0 { // deleted and parent entries
+			continue
+		}
+		name := cs0(b[38+liu : 38+liu+lfi])
+		ref := binary.LittleEndian.Uint16(b[28:])
+		off, ok := v.addr(ref, binary.LittleEndian.Uint32(b[24:]))
+		if !ok {
+			return errBadUDF
+		}
+		child, err := v.entry(&v.parts[ref], off)
+		if err != nil {
+			return err
+		}
+		switch child.typ {
+		case 4:
+			if v.d.visited[off] {
+				continue
+			}
+			v.d.visited[off] = true
+			if err = v.walk(dir+name+"/", child); err != nil {
+				return err
+			}
+		case 5:
+			v.d.files = append(v.d.files, child.file(dir+name))
+		}
+	}
+	return nil
+}
+
+// cs0 decodes an OSTA compressed unicode identifier: the first byte gives 8 or 16 bits per character
+func cs0(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case 8, 254:
+		r := make([]rune, len(b)-1)
+		for i, c := range b[1:] {
+			r[i] = rune(c)
+		}
+		return string(r)
+	case 16, 255:
+		return ucs2(b[1:])
+	}
+	return string(b[1:])
+}
+
+// udfTime decodes a UDF timestamp: a type and timezone offset in minutes, then the year, month, day, hour, minute, second,
+// centiseconds, hundreds of microseconds and microseconds
+func udfTime(b []byte) time.Time {
+	if b[4] == 0 {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if tz := int(binary.LittleEndian.Uint16(b) & 0x0fff); tz != 0x801 { // -2047 means no timezone is given
+		if tz >= 0x800 {
+			tz -= 0x1000
+		}
+		loc = time.FixedZone("", tz*60)
+	}
+	ns := (int(b[9])*10000 + int(b[10])*100 + int(b[11])) * 1000
+	return time.Date(int(int16(binary.LittleEndian.Uint16(b[2:]))), time.Month(b[4]), int(b[5]), int(b[6]), int(b[7]), int(b[8]), ns, loc)
+}
@@ -0,0 +1,268 @@
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type isoMember struct {
+	name, content string
+}
+
+func isoSect(img []byte, s int) []byte {
+	return img[s*isoSector : (s+1)*isoSector]
+}
+
+func both32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
+
+func isoRec(name []byte, extent, size uint32, dir bool, su []byte) []byte {
+	l := 33 + len(name)
+	if len(name)%2 == 0 {
+		l++
+	}
+	r := make([]byte, l+len(su)+len(su)%2)
+	r[0] = byte(len(r))
+	both32(r[2:], extent)
+	both32(r[10:], size)
+	copy(r[18:], []byte{122, 3, 4, 5, 6, 7, 0}) // 2022-03-04 05:06:07 GMT
+	if dir {
+		r[25] = 2
+	}
+	r[28], r[31], r[32] = 1, 1, byte(len(name))
+	copy(r[33:], name)
+	copy(r[l:], su)
+	return r
+}
+
+func rrName(s string) []byte {
+	return append([]byte{'N', 'M', byte(5 + len(s)), 1, 0}, s...)
+}
+
+func jolietName(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return b
+}
+
+// iso9660Test builds an image with a.txt, d/b.txt and a file with a long name. The primary volume's directories have
+// Rock Ridge names if rr is set, and a Joliet volume is added if joliet is set.
+func iso9660Test(rr, joliet bool) []byte {
+	img := make([]byte, 27*isoSector)
+	vd := func(s int, typ byte, root uint32) {
+		b := isoSect(img, s)
+		b[0], b[6] = typ, 1
+		copy(b[1:], "CD001")
+		if typ == 2 {
+			copy(b[88:], "%/E")
+		}
+		copy(b[156:], isoRec([]byte{0}, root, isoSector, true, nil))
+	}
+	vd(16, 1, 20)
+	term := 17
+	if joliet {
+		vd(17, 2, 22)
+		term = 18
+	}
+	img[term*isoSector] = 255
+	copy(img[term*isoSector+1:], "CD001\x01")
+	dir := func(s int, parent uint32, sp bool, recs ...[]byte) {
+		var su []byte
+		if sp {
+			su = []byte{'S', 'P', 7, 1, 0xbe, 0xef, 0}
+		}
+		b := append(isoRec([]byte{0}, uint32(s), isoSector, true, su), isoRec([]byte{1}, parent, isoSector, true, nil)...)
+		for _, r := range recs {
+			b = append(b, r...)
+		}
+		copy(isoSect(img, s), b)
+	}
+	nm := func(s string) []byte {
+		if rr {
+			return rrName(s)
+		}
+		return nil
+	}
+	dir(20, 20, rr,
+		isoRec([]byte("A.TXT;1"), 24, 6, false, nm("a.txt")),
+		isoRec([]byte("D"), 21, isoSector, true, nm("d")),
+		isoRec([]byte("LONG_FIL.TXT;1"), 26, 4, false, nm("Long File Name.txt")))
+	dir(21, 20, false, isoRec([]byte("B.TXT;1"), 25, 6, false, nm("b.txt")))
+	if joliet {
+		dir(22, 22, false,
+			isoRec(jolietName("a.txt;1"), 24, 6, false, nil),
+			isoRec(jolietName("d"), 23, isoSector, true, nil),
+			isoRec(jolietName("Long File Name (Joliet).txt;1"), 26, 4, false, nil))
+		dir(23, 22, false, isoRec(jolietName("b.txt;1"), 25, 6, false, nil))
+	}
+	copy(isoSect(img, 24), "hello\n")
+	copy(isoSect(img, 25), "world\n")
+	copy(isoSect(img, 26), "long")
+	return img
+}
+
+func udfTagged(b []byte, ident uint16, loc uint32) {
+	binary.LittleEndian.PutUint16(b, ident)
+	binary.LittleEndian.PutUint16(b[2:], 2)
+	binary.LittleEndian.PutUint32(b[12:], loc)
+	var sum byte
+	for i, c := range b[:16] {
+		if i != 4 {
+			sum += c
+		}
+	}
+	b[4] = sum
+}
+
+func udfFE(b []byte, typ byte, adType uint16, size int, ads []byte, ext bool) {
+	lea, base, mod, ident := 168, 176, 84, uint16(261)
+	if ext {
+		lea, base, mod, ident = 208, 216, 92, 266
+	}
+	b[27] = typ
+	binary.LittleEndian.PutUint16(b[34:], adType)
+	binary.LittleEndian.PutUint64(b[56:], uint64(size))
+	copy(b[mod:], []byte{0, 0x10, 0xe6, 0x07, 3, 4, 5, 6, 7}) // 2022-03-04 05:06:07 UTC
+	binary.LittleEndian.PutUint32(b[lea+4:], uint32(len(ads)))
+	copy(b[base:], ads)
+	udfTagged(b, ident, 0)
+}
+
+func udfAD(l, lbn uint32, long bool) []byte {
+	b := make([]byte, 8)
+	if long {
+		b = make([]byte, 16)
+	}
+	binary.LittleEndian.PutUint32(b, l)
+	binary.LittleEndian.PutUint32(b[4:], lbn)
+	return b
+}
+
+func udfFID(chars byte, fi []byte, lbn uint32) []byte {
+	b := make([]byte, (38+len(fi)+3)&^3)
+	b[18], b[19] = chars, byte(len(fi))
+	binary.LittleEndian.PutUint32(b[20:], isoSector)
+	binary.LittleEndian.PutUint32(b[24:], lbn)
+	copy(b[38:], fi)
+	udfTagged(b, 257, 0)
+	return b
+}
+
+// udfTest builds a UDF image with a.txt, d/b.txt, an embedded file and a sparse file. If hybrid is set, it also has an
+// ISO 9660 volume with a single file.
+func udfTest(hybrid bool) []byte {
+	const part = 257
+	img := make([]byte, (part+12)*isoSector)
+	vrs := 16
+	if hybrid {
+		b := isoSect(img, 16)
+		b[0], b[6] = 1, 1
+		copy(b[1:], "CD001")
+		copy(b[156:], isoRec([]byte{0}, 21, isoSector, true, nil))
+		copy(isoSect(img, 17), "\xffCD001\x01")
+		copy(isoSect(img, 21), append(append(isoRec([]byte{0}, 21, isoSector, true, nil), isoRec([]byte{1}, 21, isoSector, true, nil)...),
+			isoRec([]byte("ISO.TXT;1"), part+4, 6, false, nil)...))
+		vrs = 18
+	}
+	for i, id := range []string{"BEA01", "NSR02", "TEA01"} {
+		copy(isoSect(img, vrs+i), "\x00"+id+"\x01")
+	}
+	// anchor and volume descriptor sequence: partition, logical volume and terminating descriptors
+	a := isoSect(img, 256)
+	binary.LittleEndian.PutUint32(a[16:], 3*isoSector)
+	binary.LittleEndian.PutUint32(a[20:], 32)
+	udfTagged(a, 2, 256)
+	pd := isoSect(img, 32)
+	binary.LittleEndian.PutUint32(pd[188:], part)
+	binary.LittleEndian.PutUint32(pd[192:], 12)
+	udfTagged(pd, 5, 32)
+	lvd := isoSect(img, 33)
+	binary.LittleEndian.PutUint32(lvd[212:], isoSector)
+	copy(lvd[248:], udfAD(isoSector, 0, true))
+	binary.LittleEndian.PutUint32(lvd[264:], 6)
+	binary.LittleEndian.PutUint32(lvd[268:], 1)
+	copy(lvd[440:], []byte{1, 6, 1, 0, 0, 0})
+	udfTagged(lvd, 6, 33)
+	udfTagged(isoSect(img, 34), 8, 34)
+	// the partition: file set descriptor, then file entries, directories and data
+	lbn := func(n int) []byte { return isoSect(img, part+n) }
+	copy(lbn(0)[400:], udfAD(isoSector, 1, true))
+	udfTagged(lbn(0), 256, 0)
+	fids := func(n int, recs ...[]byte) int {
+		b := bytes.Join(recs, nil)
+		copy(lbn(n), b)
+		return len(b)
+	}
+	udfFE(lbn(1), 4, 0, fids(2,
+		udfFID(0x0a, nil, 1),
+		udfFID(0, []byte("\x08a.txt"), 3),
+		udfFID(0x02, []byte("\x08d"), 5),
+		udfFID(0, []byte("\x08e.txt"), 9),
+		udfFID(0x04, []byte("\x08deleted.txt"), 3),
+		udfFID(0, []byte("\x08sparse.bin"), 10)), udfAD(isoSector, 2, false), false)
+	udfFE(lbn(3), 5, 0, 6, udfAD(6, 4, false), false)
+	copy(lbn(4), "hello\n")
+	udfFE(lbn(5), 4, 0, fids(6,
+		udfFID(0x0a, nil, 1),
+		udfFID(0, []byte("\x10\x00b\x00.\x00t\x00x\x00t"), 7)), udfAD(isoSector, 6, false), true)
+	udfFE(lbn(7), 5, 1, 6, udfAD(6, 8, true), false)
+	copy(lbn(8), "world\n")
+	udfFE(lbn(9), 5, 3, 9, []byte("embedded\n"), false)
+	udfFE(lbn(10), 5, 0, isoSector+3, append(udfAD(1<<30|isoSector, 0, false), udfAD(3, 11, false)...), true)
+	copy(lbn(11), "abc")
+	return img
+}
+
+func TestISO(t *testing.T) {
+	if _, err := NewISO(bytes.NewReader(make([]byte, 20*isoSector)), "test.iso", 20*isoSector); err != ErrNotISO {
+		t.Errorf("expecting ErrNotISO, got %v", err)
+	}
+	sparse := strings.Repeat("\x00", isoSector) + "abc"
+	for _, v := range []struct {
+		name   string
+		img    []byte
+		expect []isoMember
+		dirs   string
+	}{
+		{"plain", iso9660Test(false, false), []isoMember{{"A.TXT", "hello\n"}, {"D/B.TXT", "world\n"}, {"LONG_FIL.TXT", "long"}}, "D"},
+		{"joliet", iso9660Test(false, true), []isoMember{{"a.txt", "hello\n"}, {"d/b.txt", "world\n"}, {"Long File Name (Joliet).txt", "long"}}, "d"},
+		{"rock ridge", iso9660Test(true, true), []isoMember{{"a.txt", "hello\n"}, {"d/b.txt", "world\n"}, {"Long File Name.txt", "long"}}, "d"},
+		{"udf", udfTest(false), []isoMember{{"a.txt", "hello\n"}, {"d/b.txt", "world\n"}, {"e.txt", "embedded\n"}, {"sparse.bin", sparse}}, "d"},
+		{"hybrid", udfTest(true), []isoMember{{"a.txt", "hello\n"}, {"d/b.txt", "world\n"}, {"e.txt", "embedded\n"}, {"sparse.bin", sparse}}, "d"},
+	} {
+		d, err := NewISO(bytes.NewReader(v.img), "test.iso", int64(len(v.img)))
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		var dirs []string
+		for _, e := range v.expect {
+			if err = d.Next(); err != nil {
+				t.Fatalf("%s: %v", v.name, err)
+			}
+			dirs = append(dirs, d.Dirs()...)
+			if d.Path() != "test.iso#"+filepath.FromSlash(e.name) || d.Size() != int64(len(e.content)) {
+				t.Errorf("%s: expecting %s (%d bytes), got %s (%d bytes)", v.name, e.name, len(e.content), d.Path(), d.Size())
+			}
+			if d.Mod().Unix() != 1646370367 {
+				t.Errorf("%s: bad modified time for %s: %v", v.name, e.name, d.Mod())
+			}
+			if byt, _ := io.ReadAll(d.Reader()); string(byt) != e.content {
+				t.Errorf("%s: expecting content %q for %s, got %q", v.name, e.content, e.name, byt)
+			}
+		}
+		if err = d.Next(); err != io.EOF {
+			t.Errorf("%s: expecting EOF, got %v", v.name, err)
+		}
+		if len(dirs) != 1 || dirs[0] != filepath.Join("test.iso", v.dirs) {
+			t.Errorf("%s: expecting directory %s, got %v", v.name, v.dirs, dirs)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+)
+
+const maxMailDepth = 32 // limit for the nesting of multipart bodies
+
+// mailPart is an attachment of an email message.
+type mailPart struct {
+	name string
+	mime string
+	data []byte
+	err  error
+}
+
+type mailD struct {
+	p       string
+	rdr     *bufio.Reader
+	mbox    bool
+	n       int    // messages read
+	dir     string // directory for the current message's attachments
+	mod     time.Time
+	parts   []mailPart
+	idx     int
+	written map[string]bool
+}
+
+// NewMail returns a Decompressor for the attachments of the messages in an mbox mailbox, or of an Internet (RFC 822/MIME) email message.
+// Attachments are the parts of a message that have a file name or an attachment disposition, or that aren't text. Attached messages without
+// a file name are given an .eml extension.
+// Each message's attachments are named within a directory for the message: its Message-ID, or message-N (counting messages from 1) if it has none.
+func NewMail(r io.Reader, path string) (Decompressor, error) {
+	m := &mailD{p: path, rdr: bufio.NewReader(r)}
+	if b, _ := m.rdr.Peek(len(mboxFrom)); bytes.Equal(b, mboxFrom) {
+		m.mbox = true
+		if _, err := m.rdr.ReadBytes('\n'); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+var mboxFrom = []byte("From ")
+
+// message reads the next message. In an mbox, messages are separated by "From " lines that follow a blank line,
+// and lines within messages that start with ">From " (after any number of >) are unquoted.
+func (m *mailD) message() ([]byte, error) {
+	if !m.mbox {
+		if m.n > 0 {
+			return nil, io.EOF
+		}
+		m.n++
+		return io.ReadAll(m.rdr)
+	}
+	var buf bytes.Buffer
+	var blank, read bool
+	for {
+		line, err := m.rdr.ReadBytes('\n')
+		if len(line) > 0 {
+			if blank && bytes.HasPrefix(line, mboxFrom) {
+				break
+			}
+			read = true
+			blank = len(bytes.TrimRight(line, "\r\n")) == 0
+			if q := bytes.TrimLeft(line, ">"); len(q) < len(line) && bytes.HasPrefix(q, mboxFrom) {
+				line = line[1:]
+			}
+			buf.Write(line)
+		}
+		if err == io.EOF {
+			if !read {
+				return nil, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.n++
+	return buf.Bytes(), nil
+}
+
+// read parses a message and finds its attachments
+func (m *mailD) read(raw []byte) {
+	m.parts, m.mod = nil, time.Time{}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		m.dir = mailDir("", m.n)
+		m.parts = append(m.parts, mailPart{name: "message", err: fmt.Errorf("bad email message: %v", err)})
+		return
+	}
+	m.dir = mailDir(msg.Header.Get("Message-Id"), m.n)
+	m.mod, _ = msg.Header.Date()
+	m.walk(textproto.MIMEHeader(msg.Header), msg.Body, "text/plain", 0)
+}
+
+// walk adds the attachments in a message part, descending into multipart parts
+func (m *mailD) walk(h textproto.MIMEHeader, body io.Reader, def string, depth int) {
+	mt, params := def, map[string]string{}
+	if ct := h.Get("Content-Type"); ct != "" {
+		var err error
+		mt, params, err = mime.ParseMediaType(ct)
+		if err != nil && err != mime.ErrInvalidMediaParameter {
+			mt = "application/octet-stream"
+		}
+	}
+	if strings.HasPrefix(mt, "multipart/") && params["boundary"] != "" {
+		if depth >= maxMailDepth {
+			return
+		}
+		def := "text/plain"
+		if mt == "multipart/digest" {
+			def = "message/rfc822"
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err != nil {
+				return
+			}
+			m.walk(p.Header, p, def, depth+1)
+		}
+	}
+	disp, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if dec, err := new(mime.WordDecoder).DecodeHeader(name); err == nil {
+		name = dec
+	}
+	name = mailName(name)
+	if name == "" {
+		// the message's text
+		if disp != "attachment" && (mt == "text/plain" || mt == "text/html") {
+			return
+		}
+		name = fmt.Sprintf("part-%d", len(m.parts)+1)
+		if mt == "message/rfc822" {
+			name += ".eml"
+		}
+	}
+	data, err := mailData(h.Get("Content-Transfer-Encoding"), body)
+	m.parts = append(m.parts, mailPart{name: name, mime: mt, data: data, err: err})
+}
+
+// mailData decodes a part's body. Base64 is decoded leniently, ignoring anything (such as line breaks, spaces and padding)
+// that isn't in the base64 alphabet.
+func mailData(enc string, r io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(enc)) {
+	case "base64":
+		byt, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		clean := byt[:0]
+		for _, c := range byt {
+			if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' {
+				clean = append(clean, c)
+			}
+		}
+		if len(clean)%4 == 1 {
+			clean = clean[:len(clean)-1]
+		}
+		out := make([]byte, base64.RawStdEncoding.DecodedLen(len(clean)))
+		n, err := base64.RawStdEncoding.Decode(out, clean)
+		return out[:n], err
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	return io.ReadAll(r)
+}
+
+// mailDir is the directory for a message's attachments: its message-id, without angle brackets, or message-N if it has none
+func mailDir(id string, n int) string {
+	id = mailName(strings.Trim(strings.TrimSpace(id), "<>"))
+	if id == "" {
+		return fmt.Sprintf("message-%d", n)
+	}
+	return id
+}
+
+// mailName replaces any path separators in a name
+func mailName(s string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(strings.TrimSpace(s))
+}
+
+func (m *mailD) Next() error {
+	m.idx++
+	for m.idx >= len(m.parts) {
+		raw, err := m.message()
+		if err != nil {
+			return err
+		}
+		m.read(raw)
+		m.idx = 0
+	}
+	return nil
+}
+
+func (m *mailD) Reader() io.Reader {
+	if m.parts[m.idx].err != nil {
+		return errReader{m.parts[m.idx].err}
+	}
+	return bytes.NewReader(m.parts[m.idx].data)
+}
+
+func (m *mailD) Path() string {
+	return Arcpath(m.p, filepath.FromSlash(m.dir+"/"+m.parts[m.idx].name))
+}
+
+func (m *mailD) MIME() string {
+	return m.parts[m.idx].mime
+}
+
+func (m *mailD) Size() int64 {
+	return int64(len(m.parts[m.idx].data))
+}
+
+func (m *mailD) Mod() time.Time {
+	return m.mod
+}
+
+func (m *mailD) Dirs() []string {
+	if m.written == nil {
+		m.written = make(map[string]bool)
+	}
+	return dirs(m.p, m.dir+"/"+m.parts[m.idx].name, m.written)
+}
+
+// Outlook messages: properties are in __substg1.0_ streams named by property id and type, or (for fixed length values)
+// in the __properties_version1.0 stream of the message, attachment or recipient storage.
+const (
+	msgProps   = "__properties_version1.0"
+	msgNameid  = "__nameid_version1.0"
+	msgAttach  = "__attach_version1.0_"
+	msgSubstg  = "__substg1.0_"
+	msgMIMEID  = "application/vnd.ms-outlook"
+	msgPropLen = 16
+)
+
+type msgD struct {
+	p        string
+	children map[string][]*mscfb.File
+	atts     []*mscfb.File
+	nameid   *mscfb.File
+	dir      string
+	date     time.Time
+	idx      int
+	name     string
+	mime     string
+	mod      time.Time
+	rdr      io.Reader
+	sz       int64
+	written  map[string]bool
+}
+
+// NewMSG returns a Decompressor for the attachments of an Outlook (.msg) email message. Attached files are named by their
+// long or short file name (or display name), and attached messages are rebuilt as standalone .msg files, named by their display name,
+// so that they can be scanned in turn. Other attached OLE objects are rebuilt as compound documents. Attachments by reference, which have no data, are skipped.
+// The attachments are named within a directory for the message, as with NewMail.
+func NewMSG(buf *siegreader.Buffer, path string) (Decompressor, error) {
+	r, err := mscfb.New(siegreader.ReaderFrom(buf))
+	if err != nil {
+		return nil, err
+	}
+	d := &msgD{p: path, idx: -1, children: make(map[string][]*mscfb.File)}
+	for f, err := r.Next(); err == nil; f, err = r.Next() {
+		k := oleKey(f.Path)
+		d.children[k] = append(d.children[k], f)
+	}
+	root := d.children[""]
+	for _, f := range root {
+		switch {
+		case !f.FileInfo().IsDir():
+		case strings.HasPrefix(f.Name, msgAttach):
+			d.atts = append(d.atts, f)
+		case f.Name == msgNameid:
+			d.nameid = f
+		}
+	}
+	sort.Slice(d.atts, func(i, j int) bool { return d.atts[i].Name < d.atts[j].Name })
+	d.dir = mailDir(msgString(root, "1035"), 1)
+	props := msgProperties(root, 32)
+	if d.date = msgTime(props, 0x0E06); d.date.IsZero() {
+		d.date = msgTime(props, 0x0039)
+	}
+	return d, nil
+}
+
+func msgEntry(kids []*mscfb.File, name string) *mscfb.File {
+	for _, k := range kids {
+		if strings.EqualFold(k.Name, name) {
+			return k
+		}
+	}
+	return nil
+}
+
+func msgRead(f *mscfb.File) ([]byte, error) {
+	return io.ReadAll(io.NewSectionReader(f, 0, f.Size))
+}
+
+// msgString returns a string property, which may be in Unicode (type 001F) or 8-bit (001E)
+func msgString(kids []*mscfb.File, id string) string {
+	if f := msgEntry(kids, msgSubstg+id+"001F"); f != nil {
+		b, _ := msgRead(f)
+		return utf16String(b)
+	}
+	if f := msgEntry(kids, msgSubstg+id+"001E"); f != nil {
+		b, _ := msgRead(f)
+		return strings.TrimRight(string(b), "\x00")
+	}
+	return ""
+}
+
+func utf16String(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+}
+
+// msgProperties reads the fixed length values in a properties stream, which has a header of 32 bytes for a message,
+// 24 for an attached message and 8 for attachments and recipients, followed by 16 byte entries: a property tag, flags and the value
+func msgProperties(kids []*mscfb.File, hdr int) map[uint32][]byte {
+	ret := make(map[uint32][]byte)
+	f := msgEntry(kids, msgProps)
+	if f == nil {
+		return ret
+	}
+	b, _ := msgRead(f)
+	for i := hdr; i+msgPropLen <= len(b); i += msgPropLen {
+		ret[binary.LittleEndian.Uint32(b[i:])] = b[i+8 : i+16]
+	}
+	return ret
+}
+
+// msgTime returns a time property (type 0040), a FILETIME
+func msgTime(props map[uint32][]byte, id uint16) time.Time {
+	if b := props[uint32(id)<<16|0x0040]; len(b) == 8 && binary.LittleEndian.Uint64(b) > 0 {
+		return filetime(b)
+	}
+	return time.Time{}
+}
+
+func (d *msgD) Next() error {
+	for d.idx++; d.idx < len(d.atts); d.idx++ {
+		a := d.atts[d.idx]
+		kids := d.children[oleKey(append(append([]string{}, a.Path...), a.Name))]
+		if d.mod = msgTime(msgProperties(kids, 8), 0x3008); d.mod.IsZero() {
+			d.mod = d.date
+		}
+		d.mime = msgString(kids, "370E")
+		for _, id := range []string{"3707", "3704", "3001"} {
+			if d.name = mailName(msgString(kids, id)); d.name != "" {
+				break
+			}
+		}
+		if f := msgEntry(kids, msgSubstg+"37010102"); f != nil {
+			if d.name == "" {
+				d.name = fmt.Sprintf("attachment-%d", d.idx+1)
+			}
+			d.rdr, d.sz = io.NewSectionReader(f, 0, f.Size), f.Size
+			return nil
+		}
+		if f := msgEntry(kids, msgSubstg+"3701000D"); f != nil && f.FileInfo().IsDir() {
+			byt, err := d.object(f)
+			if err != nil {
+				d.rdr, d.sz = errReader{err}, 0
+			} else {
+				d.rdr, d.sz = bytes.NewReader(byt), int64(len(byt))
+			}
+			return nil
+		}
+	}
+	return io.EOF
+}
+
+// object rebuilds an attached message or OLE object. An attached message's properties stream has a shorter header than a message's
+// and the message's named property mapping is copied to it.
+func (d *msgD) object(f *mscfb.File) ([]byte, error) {
+	root, err := copyStorage(d.children, f, 0)
+	if err != nil {
+		return nil, err
+	}
+	root.name = "Root Entry"
+	var msg bool
+	for _, k := range root.kids {
+		if k.name == msgProps && !k.storage && len(k.data) >= 24 {
+			msg = true
+			k.data = append(append(append([]byte{}, k.data[:24]...), make([]byte, 8)...), k.data[24:]...)
+		}
+	}
+	if d.name == "" {
+		d.name = fmt.Sprintf("attachment-%d", d.idx+1)
+	}
+	if msg {
+		if d.nameid != nil {
+			nameid, err := copyStorage(d.children, d.nameid, 0)
+			if err != nil {
+				return nil, err
+			}
+			root.kids = append(root.kids, nameid)
+		}
+		if !strings.HasSuffix(strings.ToLower(d.name), ".msg") {
+			d.name += ".msg"
+		}
+		d.mime = msgMIMEID
+	}
+	return writeCFB(root), nil
+}
+
+func (d *msgD) Reader() io.Reader {
+	return d.rdr
+}
+
+func (d *msgD) Path() string {
+	return Arcpath(d.p, filepath.FromSlash(d.dir+"/"+d.name))
+}
+
+func (d *msgD) MIME() string {
+	return d.mime
+}
+
+func (d *msgD) Size() int64 {
+	return d.sz
+}
+
+func (d *msgD) Mod() time.Time {
+	return d.mod
+}
+
+func (d *msgD) Dirs() []string {
+	if d.written == nil {
+		d.written = make(map[string]bool)
+	}
+	return dirs(d.p, d.dir+"/"+d.name, d.written)
+}
@@ -0,0 +1,143 @@
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+)
+
+const testMbox = "From a@example.com Mon Jan  1 10:00:00 2024\n" +
+	"Message-ID: <one@example.com>\nDate: Mon, 1 Jan 2024 10:00:00 +0000\nMIME-Version: 1.0\n" +
+	"Content-Type: multipart/mixed; boundary=\"b1\"\n\n" +
+	"--b1\nContent-Type: text/plain\n\nbody\n>From the start\n" +
+	"--b1\nContent-Type: application/pdf; name=\"doc.pdf\"\nContent-Transfer-Encoding: base64\n\nJVBERi0x\nLjQK\n" +
+	"--b1\nContent-Type: message/rfc822\n\nSubject: inner\n\ninner body\n" +
+	"--b1--\n\n" +
+	"From b@example.com Mon Jan  1 11:00:00 2024\n" + testEML
+
+const testEML = "Subject: no id\nContent-Type: text/csv; name=\"=?UTF-8?B?w6kuY3N2?=\"\nContent-Transfer-Encoding: quoted-printable\n\ncaf=C3=A9\n"
+
+type mailTest struct{ name, mime, content string }
+
+func testMail(t *testing.T, d Decompressor, path string, expect []mailTest) {
+	t.Helper()
+	for _, e := range expect {
+		if err := d.Next(); err != nil {
+			t.Fatal(err)
+		}
+		byt, _ := io.ReadAll(d.Reader())
+		if d.Path() != path+"#"+filepath.FromSlash(e.name) || d.MIME() != e.mime || d.Size() != int64(len(byt)) {
+			t.Errorf("expecting %s (%s), got %s (%s, %d bytes, read %d)", e.name, e.mime, d.Path(), d.MIME(), d.Size(), len(byt))
+		}
+		if e.content != "" && string(byt) != e.content {
+			t.Errorf("%s: expecting content %q, got %q", e.name, e.content, byt)
+		}
+	}
+	if err := d.Next(); err != io.EOF {
+		t.Errorf("expecting EOF, got %v", err)
+	}
+}
+
+func TestMail(t *testing.T) {
+	d, err := NewMail(strings.NewReader(testMbox), "test.mbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMail(t, d, "test.mbox", []mailTest{
+		{"one@example.com/doc.pdf", "application/pdf", "%PDF-1.4\n"},
+		{"one@example.com/part-2.eml", "message/rfc822", "Subject: inner\n\ninner body"},
+		{"message-2/é.csv", "text/csv", "café\n"},
+	})
+	d, err = NewMail(strings.NewReader(testEML), "test.eml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMail(t, d, "test.eml", []mailTest{{"message-1/é.csv", "text/csv", "café\n"}})
+}
+
+func utf16Test(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+// a message with an attached file and an attached message
+func msgTest() []byte {
+	props := make([]byte, 32+16)
+	binary.LittleEndian.PutUint32(props[32:], 0x0e060040)
+	binary.LittleEndian.PutUint64(props[40:], 133485408000000000) // 2024-01-01
+	return writeCFB(storage("Root Entry",
+		stream(msgProps, props),
+		stream("__substg1.0_1035001F", utf16Test("<msg@example.com>")),
+		storage(msgNameid, stream("__substg1.0_00020102", nil)),
+		storage("__attach_version1.0_#00000000",
+			stream(msgProps, make([]byte, 8)),
+			stream("__substg1.0_3707001F", utf16Test("a.txt")),
+			stream("__substg1.0_37010102", []byte("hello")),
+		),
+		storage("__attach_version1.0_#00000001",
+			stream(msgProps, make([]byte, 8)),
+			stream("__substg1.0_3001001E", []byte("Fwd: hi\x00")),
+			storage("__substg1.0_3701000D",
+				stream(msgProps, append(make([]byte, 24), 1, 2, 3)),
+				stream("__substg1.0_0037001F", utf16Test("hi")),
+			),
+		),
+		storage("__attach_version1.0_#00000002",
+			stream(msgProps, make([]byte, 8)),
+			stream("__substg1.0_3707001F", utf16Test("by reference.txt")),
+		),
+	))
+}
+
+func TestMSG(t *testing.T) {
+	bufs := siegreader.New()
+	buf, _ := bufs.Get(bytes.NewReader(msgTest()))
+	defer bufs.Put(buf)
+	d, err := NewMSG(buf, "test.msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Mod().Year() != 2024 {
+		t.Errorf("expecting the message's date, got %v", d.Mod())
+	}
+	if err = d.Next(); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, _ := io.ReadAll(d.Reader())
+	d, _ = NewMSG(buf, "test.msg")
+	testMail(t, d, "test.msg", []mailTest{
+		{"msg@example.com/a.txt", "", "hello"},
+		{"msg@example.com/Fwd: hi.msg", msgMIMEID, ""},
+	})
+	// the attached message is rebuilt with a message's properties header and the named property mapping
+	r, err := mscfb.New(bytes.NewReader(rebuilt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for f, err := r.Next(); err == nil; f, err = r.Next() {
+		names = append(names, strings.Join(append(f.Path, f.Name), "/"))
+		if f.Name == msgProps {
+			if byt, _ := io.ReadAll(f); len(byt) != 35 || byt[32] != 1 {
+				t.Errorf("bad properties stream in rebuilt message: %v", byt)
+			}
+		}
+	}
+	if strings.Join(names, ",") != "__nameid_version1.0,__nameid_version1.0/__substg1.0_00020102,__substg1.0_0037001F,__properties_version1.0" {
+		t.Errorf("unexpected entries in rebuilt message: %v", names)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"time"
+	"unicode/utf16"
+)
+
+var (
+	errBadNTFS  = errors.New("decompress: bad NTFS filesystem")
+	errNTFSData = errors.New("decompress: can't read compressed or encrypted NTFS file")
+)
+
+func init() {
+	RegisterFS("ntfs", NewNTFS)
+}
+
+type ntfsPiece struct {
+	vcn     int64
+	extents []fsExtent
+}
+
+// ntfsRec is a file record, merged with its extension records
+type ntfsRec struct {
+	parent uint64
+	name   string
+	named  bool
+	dos    bool // the name is a DOS (8.3) name, used only if there is no other
+	dir    bool
+	mod    time.Time
+	size   int64
+	data   []byte // resident data
+	pieces []ntfsPiece
+	hasDat bool
+	err    error
+}
+
+// NewNTFS returns a Decompressor for the files of an NTFS volume, read from its master file table. Files are named by their
+// long names, and only their unnamed data streams are read. Compressed and encrypted files are reported as errors.
+func NewNTFS(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	bs := make([]byte, 512)
+	if _, err := ra.ReadAt(bs, 0); err != nil {
+		return nil, errBadNTFS
+	}
+	bps, spc := int64(binary.LittleEndian.Uint16(bs[11:])), int64(bs[13])
+	if spc > 0x80 {
+		spc = 1 << (256 - spc)
+	}
+	clus := bps * spc
+	recSz := int64(int8(bs[64]))
+	if recSz > 0 {
+		recSz *= clus
+	} else {
+		recSz = 1 << -recSz
+	}
+	if bps < 512 || bps > 4096 || bps&(bps-1) != 0 || spc == 0 || recSz < 512 || recSz > 65536 {
+		return nil, errBadNTFS
+	}
+	// record 0 is the $MFT itself: its data attribute maps the rest of the table
+	rec := make([]byte, recSz)
+	if _, err := ra.ReadAt(rec, int64(binary.LittleEndian.Uint64(bs[48:]))*clus); err != nil {
+		return nil, errBadNTFS
+	}
+	mft := &ntfsRec{}
+	if _, ok := ntfsRecord(rec, clus, mft); !ok || len(mft.pieces) == 0 || mft.size > sz {
+		return nil, errBadNTFS
+	}
+	table := &runs{ra: ra, extents: mft.extents(), sz: mft.size}
+	recs := make(map[uint64]*ntfsRec)
+	for i := int64(0); (i+1)*recSz <= mft.size; i++ {
+		if _, err := table.ReadAt(rec, i*recSz); err != nil && err != io.EOF {
+			return nil, err
+		}
+		r := &ntfsRec{}
+		base, ok := ntfsRecord(rec, clus, r)
+		if !ok {
+			continue
+		}
+		if base == 0 {
+			base = uint64(i)
+		}
+		if b, ok := recs[base]; ok {
+			b.merge(r)
+		} else {
+			recs[base] = r
+		}
+	}
+	d := &fsD{p: path, ra: ra, idx: -1}
+	paths := map[uint64]string{5: ""}
+	var dirpath func(n uint64, depth int) (string, bool)
+	dirpath = func(n uint64, depth int) (string, bool) {
+		if p, ok := paths[n]; ok {
+			return p, true
+		}
+		r, ok := recs[n]
+		if !ok || !r.dir || !r.named || n < 16 || depth > 255 {
+			return "", false
+		}
+		p, ok := dirpath(r.parent, depth+1)
+		if !ok {
+			return "", false
+		}
+		paths[n] = p + r.name + "/"
+		return paths[n], true
+	}
+	for n, r := range recs {
+		if r.dir || !r.named || !r.hasDat || n < 16 {
+			continue
+		}
+		dir, ok := dirpath(r.parent, 0)
+		if !ok {
+			continue
+		}
+		d.files = append(d.files, fsFile{name: dir + r.name, mod: r.mod, size: r.size, data: r.data, extents: r.extents(), err: r.err})
+	}
+	sort.Slice(d.files, func(i, j int) bool { return d.files[i].name < d.files[j].name })
+	return d, nil
+}
+
+func (r *ntfsRec) merge(e *ntfsRec) {
+	if e.named && (!r.named || (r.dos && !e.dos)) {
+		r.parent, r.name, r.named, r.dos = e.parent, e.name, true, e.dos
+	}
+	r.dir = r.dir || e.dir
+	if r.mod.IsZero() {
+		r.mod = e.mod
+	}
+	if e.hasDat {
+		if e.size > 0 {
+			r.size = e.size
+		}
+		if e.data != nil {
+			r.data = e.data
+		}
+		r.pieces, r.hasDat = append(r.pieces, e.pieces...), true
+	}
+	if r.err == nil {
+		r.err = e.err
+	}
+}
+
+// extents returns the extents of the unnamed data attribute, with sparse extents for any missing pieces
+func (r *ntfsRec) extents() []fsExtent {
+	sort.Slice(r.pieces, func(i, j int) bool { return r.pieces[i].vcn < r.pieces[j].vcn })
+	var ret []fsExtent
+	for _, p := range r.pieces {
+		ret = append(ret, p.extents...)
+	}
+	return ret
+}
+
+// ntfsRecord applies the fixups to a file record and parses its attributes into r. It returns the record's base record number (0 if it is a base record)
+// and false if the record isn't in use.
+func ntfsRecord(b []byte, clus int64, r *ntfsRec) (uint64, bool) {
+	if string(b[:4]) != "FILE" || binary.LittleEndian.Uint16(b[22:])&1 == 0 {
+		return 0, false
+	}
+	usa, cnt := int(binary.LittleEndian.Uint16(b[4:])), int(binary.LittleEndian.Uint16(b[6:]))
+	if usa+cnt*2 > len(b) || cnt*512 > len(b)+512 {
+		return 0, false
+	}
+	for i := 1; i < cnt; i++ {
+		pos := i*512 - 2
+		if b[pos] != b[usa] || b[pos+1] != b[usa+1] {
+			return 0, false
+		}
+		b[pos], b[pos+1] = b[usa+i*2], b[usa+i*2+1]
+	}
+	r.dir = binary.LittleEndian.Uint16(b[22:])&2 != 0
+	for off := int(binary.LittleEndian.Uint16(b[20:])); off+16 <= len(b); {
+		a := b[off:]
+		typ, l := binary.LittleEndian.Uint32(a), int(binary.LittleEndian.Uint32(a[4:]))
+		if typ == 0xffffffff || l < 16 || off+l > len(b) {
+			break
+		}
+		off += l
+		a = a[:l]
+		var val []byte
+		if a[8] == 0 {
+			vo, vl := int(binary.LittleEndian.Uint16(a[20:])), int(binary.LittleEndian.Uint32(a[16:]))
+			if vo+vl > l {
+				continue
+			}
+			val = a[vo : vo+vl]
+		}
+		switch {
+		case typ == 0x10 && len(val) >= 16: // standard information
+			r.mod = filetime(val[8:16])
+		case typ == 0x30 && len(val) >= 66: // file name
+			nl, ns := int(val[64]), val[65]
+			if 66+nl*2 > len(val) || (r.named && (ns == 2 || !r.dos)) {
+				continue
+			}
+			u := make([]uint16, nl)
+			for i := range u {
+				u[i] = binary.LittleEndian.Uint16(val[66+i*2:])
+			}
+			r.parent, r.name, r.named, r.dos = binary.LittleEndian.Uint64(val)&0xffffffffffff, ntfsName(u), true, ns == 2
+		case typ == 0x80 && a[9] == 0: // the unnamed data stream
+			r.hasDat = true
+			if a[8] == 0 {
+				r.data, r.size = append([]byte{}, val...), int64(len(val))
+				continue
+			}
+			if l < 64 {
+				r.err = errBadNTFS
+				continue
+			}
+			if binary.LittleEndian.Uint16(a[12:])&0x4001 != 0 {
+				r.err = errNTFSData
+			}
+			vcn := int64(binary.LittleEndian.Uint64(a[16:]))
+			if vcn == 0 {
+				r.size = int64(binary.LittleEndian.Uint64(a[48:]))
+			}
+			ro := int(binary.LittleEndian.Uint16(a[32:]))
+			if ro > l {
+				r.err = errBadNTFS
+				continue
+			}
+			ext, err := ntfsRuns(a[ro:], clus)
+			if err != nil {
+				r.err = err
+			}
+			r.pieces = append(r.pieces, ntfsPiece{vcn, ext})
+		}
+	}
+	return binary.LittleEndian.Uint64(b[32:]) & 0xffffffffffff, true
+}
+
+// ntfsRuns decodes a runlist: each run has a header byte giving the sizes of its length and (signed, relative) cluster offset fields.
+// Runs without an offset are sparse.
+func ntfsRuns(b []byte, clus int64) ([]fsExtent, error) {
+	var ret []fsExtent
+	var lcn int64
+	le := func(b []byte, signed bool) int64 {
+		var v int64
+		for i := len(b) - 1; i >= 0; i-- {
+			v = v<<8 | int64(b[i])
+		}
+		if signed && len(b) > 0 && len(b) < 8 && b[len(b)-1]&0x80 != 0 {
+			v -= 1 << (8 * uint(len(b)))
+		}
+		return v
+	}
+	for i := 0; i < len(b) && b[i] != 0; {
+		ll, lo := int(b[i]&0xf), int(b[i]>>4)
+		i++
+		if ll == 0 || ll > 8 || lo > 8 || i+ll+lo > len(b) {
+			return ret, errBadNTFS
+		}
+		l := le(b[i:i+ll], false) * clus
+		i += ll
+		if lo == 0 {
+			ret = append(ret, fsExtent{-1, l})
+			continue
+		}
+		lcn += le(b[i:i+lo], true)
+		i += lo
+		if lcn < 0 || l < 0 {
+			return ret, errBadNTFS
+		}
+		ret = append(ret, fsExtent{lcn * clus, l})
+	}
+	return ret, nil
+}
+
+func ntfsName(u []uint16) string {
+	r := []rune(string(utf16.Decode(u)))
+	for i, c := range r {
+		if c == '/' {
+			r[i] = '_'
+		}
+	}
+	return string(r)
+}
@@ -0,0 +1,586 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+)
+
+const oleMagic = "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"
+
+// ErrNoEmbedded is returned by NewOLE if content isn't an OLE2 compound document or doesn't have any embedded objects.
+var ErrNoEmbedded = errors.New("decompress: no embedded objects in OLE2 compound document")
+
+var errBadNative = errors.New("decompress: bad Ole10Native stream")
+
+// oleObject is an embedded object: either a stream (an Ole10Native package, or a Package or CONTENTS stream)
+// or a storage, which is rebuilt as a compound document of its own.
+type oleObject struct {
+	name    string
+	mod     time.Time
+	stream  *mscfb.File
+	native  bool
+	storage *mscfb.File
+}
+
+type oleD struct {
+	p        string
+	objs     []oleObject
+	children map[string][]*mscfb.File
+	idx      int
+	rdr      io.Reader
+	sz       int64
+	written  map[string]bool
+}
+
+// NewOLE returns a Decompressor for the objects embedded in an OLE2 compound document (e.g. in the ObjectPool storage of a Word document
+// or the MBD storages of an Excel workbook). Ole10Native packages are unwrapped to the packaged file, named by the package's label;
+// Package (e.g. embedded OOXML documents) and CONTENTS streams are read as they are; and other embedded objects, which are compound documents
+// themselves, are rebuilt as a standalone compound document so that they can be identified (and so that their own embedded objects are found in turn).
+// Objects embedded in the PowerPoint Document stream of PowerPoint presentations aren't found.
+// It returns ErrNoEmbedded if the buffer isn't a compound document or has no embedded objects.
+func NewOLE(buf *siegreader.Buffer, path string) (Decompressor, error) {
+	if b, err := buf.Slice(0, len(oleMagic)); err != nil || string(b) != oleMagic {
+		return nil, ErrNoEmbedded
+	}
+	r, err := mscfb.New(siegreader.ReaderFrom(buf))
+	if err != nil {
+		return nil, ErrNoEmbedded
+	}
+	d := &oleD{p: path, idx: -1, children: make(map[string][]*mscfb.File)}
+	for f, err := r.Next(); err == nil; f, err = r.Next() {
+		k := oleKey(f.Path)
+		d.children[k] = append(d.children[k], f)
+	}
+	// the document itself may be an embedded object's storage (e.g. the oleObject.bin files in OOXML documents)
+	if s, native := olePayload(d.children[""]); s != nil {
+		d.objs = append(d.objs, oleObject{name: oleName(s, native), mod: oleTime(r.Modified()), stream: s, native: native})
+	}
+	d.walk("", make(map[string]bool))
+	if len(d.objs) == 0 {
+		return nil, ErrNoEmbedded
+	}
+	return d, nil
+}
+
+func oleKey(path []string) string {
+	return strings.Join(path, "/")
+}
+
+func oleFullName(f *mscfb.File) string {
+	if f.Initial != 0 && !unicode.IsPrint(rune(f.Initial)) {
+		return string(rune(f.Initial)) + f.Name
+	}
+	return f.Name
+}
+
+// olePayload finds the stream that holds an embedded object's data amongst the entries of its storage
+func olePayload(kids []*mscfb.File) (*mscfb.File, bool) {
+	var pkg, contents *mscfb.File
+	for _, k := range kids {
+		if k.FileInfo().IsDir() {
+			continue
+		}
+		switch oleFullName(k) {
+		case "\x01Ole10Native":
+			return k, true
+		case "Package":
+			pkg = k
+		case "CONTENTS":
+			contents = k
+		}
+	}
+	if pkg != nil {
+		return pkg, false
+	}
+	return contents, false
+}
+
+// isEmbedded reports whether a storage is an embedded object's: these have CompObj or Ole streams
+func isEmbedded(kids []*mscfb.File) bool {
+	for _, k := range kids {
+		switch oleFullName(k) {
+		case "\x01CompObj", "\x01Ole", "\x01Ole10Native":
+			return !k.FileInfo().IsDir()
+		}
+	}
+	return false
+}
+
+func oleName(s *mscfb.File, native bool) string {
+	path := append(append([]string{}, s.Path...), s.Name)
+	if native {
+		path[len(path)-1] = "Ole10Native"
+		if label := nativeLabel(s); label != "" {
+			path[len(path)-1] = label
+		}
+	}
+	return strings.Join(path, "/")
+}
+
+// walk adds the embedded objects in a storage and recurses into storages that aren't embedded objects.
+// Storages are only walked once: a storage with an empty name has the same key as its parent, so would otherwise be walked forever.
+func (d *oleD) walk(key string, seen map[string]bool) {
+	seen[key] = true
+	for _, f := range d.children[key] {
+		if !f.FileInfo().IsDir() {
+			continue
+		}
+		k := oleKey(append(append([]string{}, f.Path...), f.Name))
+		if seen[k] {
+			continue
+		}
+		kids := d.children[k]
+		if !isEmbedded(kids) {
+			d.walk(k, seen)
+			continue
+		}
+		seen[k] = true
+		if s, native := olePayload(kids); s != nil {
+			d.objs = append(d.objs, oleObject{name: oleName(s, native), mod: oleTime(f.Modified()), stream: s, native: native})
+		} else {
+			d.objs = append(d.objs, oleObject{name: k, mod: oleTime(f.Modified()), storage: f})
+		}
+	}
+}
+
+// readNative unwraps an Ole10Native stream: a size, then for packages a type (2), the label and original path as null-terminated strings,
+// two unknown values, the length-prefixed temporary path and then the data's length and the data. Other objects' data
+// follows the size directly.
+func readNative(s *mscfb.File) (label string, data []byte, err error) {
+	b, err := io.ReadAll(io.NewSectionReader(s, 0, s.Size))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(b) < 4 {
+		return "", nil, errBadNative
+	}
+	sz := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	if sz < 0 || sz > len(b) {
+		sz = len(b)
+	}
+	raw := b[:sz]
+	if len(b) < 2 || binary.LittleEndian.Uint16(b) != 2 {
+		return "", raw, nil
+	}
+	b = b[2:]
+	str := func() (string, bool) {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			return "", false
+		}
+		s := string(b[:i])
+		b = b[i+1:]
+		return s, true
+	}
+	label, ok := str()
+	if _, ok2 := str(); !ok || !ok2 || len(b) < 8 {
+		return "", raw, nil
+	}
+	tl := int(binary.LittleEndian.Uint32(b[4:]))
+	if tl < 0 || 12+tl > len(b) {
+		return "", raw, nil
+	}
+	b = b[8+tl:]
+	dl := int(binary.LittleEndian.Uint32(b))
+	if dl < 0 || 4+dl > len(b) {
+		return "", raw, nil
+	}
+	return label, b[4 : 4+dl], nil
+}
+
+func nativeLabel(s *mscfb.File) string {
+	label, _, _ := readNative(s)
+	if i := strings.LastIndexAny(label, `\/`); i >= 0 {
+		label = label[i+1:]
+	}
+	return label
+}
+
+func (d *oleD) Next() error {
+	d.idx++
+	if d.idx >= len(d.objs) {
+		return io.EOF
+	}
+	o := d.objs[d.idx]
+	switch {
+	case o.native:
+		_, data, err := readNative(o.stream)
+		if err != nil {
+			d.rdr, d.sz = errReader{err}, 0
+			return nil
+		}
+		d.rdr, d.sz = bytes.NewReader(data), int64(len(data))
+	case o.stream != nil:
+		d.rdr, d.sz = io.NewSectionReader(o.stream, 0, o.stream.Size), o.stream.Size
+	default:
+		byt, err := d.rebuild(o.storage)
+		if err != nil {
+			d.rdr, d.sz = errReader{err}, 0
+			return nil
+		}
+		d.rdr, d.sz = bytes.NewReader(byt), int64(len(byt))
+	}
+	return nil
+}
+
+func (d *oleD) Reader() io.Reader {
+	return d.rdr
+}
+
+func (d *oleD) Path() string {
+	return Arcpath(d.p, filepath.FromSlash(d.objs[d.idx].name))
+}
+
+func (d *oleD) MIME() string {
+	return ""
+}
+
+func (d *oleD) Size() int64 {
+	return d.sz
+}
+
+func (d *oleD) Mod() time.Time {
+	return d.objs[d.idx].mod
+}
+
+func (d *oleD) Dirs() []string {
+	if d.written == nil {
+		d.written = make(map[string]bool)
+	}
+	return dirs(d.p, d.objs[d.idx].name, d.written)
+}
+
+// rebuild copies a storage and its descendants into a new compound document, with the storage as the root entry
+func (d *oleD) rebuild(storage *mscfb.File) ([]byte, error) {
+	root, err := copyStorage(d.children, storage, 0)
+	if err != nil {
+		return nil, err
+	}
+	root.name = "Root Entry"
+	return writeCFB(root), nil
+}
+
+// copyStorage copies an entry and, if it is a storage, its descendants (listed by key in children) for writing with writeCFB
+func copyStorage(children map[string][]*mscfb.File, f *mscfb.File, depth int) (*cfbEntry, error) {
+	e := &cfbEntry{name: oleFullName(f), clsid: clsid(f.ID()), created: toFiletime(f.Created()), modified: toFiletime(f.Modified())}
+	if !f.FileInfo().IsDir() {
+		var err error
+		e.data, err = io.ReadAll(io.NewSectionReader(f, 0, f.Size))
+		return e, err
+	}
+	e.storage = true
+	if depth > 64 {
+		return e, nil
+	}
+	for _, k := range children[oleKey(append(append([]string{}, f.Path...), f.Name))] {
+		c, err := copyStorage(children, k, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		e.kids = append(e.kids, c)
+	}
+	return e, nil
+}
+
+// clsid converts a CLSID in mscfb's {XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX} form back to its mixed-endian encoding
+func clsid(s string) [16]byte {
+	var g [16]byte
+	b, err := hex.DecodeString(strings.NewReplacer("{", "", "}", "", "-", "").Replace(s))
+	if err != nil || len(b) != 16 {
+		return g
+	}
+	copy(g[:], b)
+	g[0], g[1], g[2], g[3] = b[3], b[2], b[1], b[0]
+	g[4], g[5], g[6], g[7] = b[5], b[4], b[7], b[6]
+	return g
+}
+
+// oleTime returns a zero time for unset timestamps, which mscfb gives as the Unix epoch
+func oleTime(t time.Time) time.Time {
+	if t.Unix() <= 0 {
+		return time.Time{}
+	}
+	return t
+}
+
+// toFiletime converts a time to a Windows FILETIME: 100 nanosecond intervals since 1601
+func toFiletime(t time.Time) uint64 {
+	if t.Unix() <= 0 {
+		return 0
+	}
+	return uint64(t.Unix()+11644473600)*10000000 + uint64(t.Nanosecond()/100)
+}
+
+// Compound document writing: the sector size is 512, and streams smaller than 4096 bytes are stored in the mini stream in 64 byte sectors.
+const (
+	cfbSector   = 512
+	cfbMini     = 64
+	cfbCutoff   = 4096
+	cfbFree     = 0xffffffff
+	cfbEnd      = 0xfffffffe
+	cfbFATSect  = 0xfffffffd
+	cfbDIFSect  = 0xfffffffc
+	cfbNoStream = 0xffffffff
+)
+
+type cfbEntry struct {
+	name              string
+	storage           bool
+	clsid             [16]byte
+	created, modified uint64
+	data              []byte
+	kids              []*cfbEntry
+	// set by writeCFB
+	left, right, child uint32
+	start              uint32
+}
+
+// cfbLess orders directory entry names as compound documents require: shorter names first, then by upper case code points
+func cfbLess(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(strings.ToUpper(a))), utf16.Encode([]rune(strings.ToUpper(b)))
+	if len(ua) != len(ub) {
+		return len(ua) < len(ub)
+	}
+	for i := range ua {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return false
+}
+
+// writeCFB writes a version 3 compound document. The entries of each storage are arranged as a balanced binary tree, all coloured black.
+func writeCFB(root *cfbEntry) []byte {
+	entries := []*cfbEntry{root}
+	var tree func(storage *cfbEntry)
+	tree = func(storage *cfbEntry) {
+		kids := append([]*cfbEntry{}, storage.kids...)
+		sort.SliceStable(kids, func(i, j int) bool { return cfbLess(kids[i].name, kids[j].name) })
+		idx := make([]uint32, len(kids))
+		for i, k := range kids {
+			k.left, k.right, k.child = cfbNoStream, cfbNoStream, cfbNoStream
+			idx[i] = uint32(len(entries))
+			entries = append(entries, k)
+		}
+		var balance func(lo, hi int) uint32
+		balance = func(lo, hi int) uint32 {
+			if lo >= hi {
+				return cfbNoStream
+			}
+			mid := (lo + hi) / 2
+			kids[mid].left, kids[mid].right = balance(lo, mid), balance(mid+1, hi)
+			return idx[mid]
+		}
+		storage.child = balance(0, len(kids))
+		for _, k := range kids {
+			if k.storage {
+				tree(k)
+			}
+		}
+	}
+	root.left, root.right = cfbNoStream, cfbNoStream
+	tree(root)
+	// lay out the mini stream
+	var mini []byte
+	var minifat []uint32
+	for _, e := range entries[1:] {
+		if e.storage || len(e.data) >= cfbCutoff {
+			continue
+		}
+		e.start = cfbEnd
+		if len(e.data) == 0 {
+			continue
+		}
+		e.start = uint32(len(minifat))
+		n := (len(e.data) + cfbMini - 1) / cfbMini
+		for i := 1; i < n; i++ {
+			minifat = append(minifat, uint32(len(minifat)+1))
+		}
+		minifat = append(minifat, cfbEnd)
+		mini = append(mini, e.data...)
+		mini = append(mini, make([]byte, n*cfbMini-len(e.data))...)
+	}
+	sectors := func(n int) int { return (n + cfbSector - 1) / cfbSector }
+	dirN, minifatN, miniN := sectors(len(entries)*128), sectors(len(minifat)*4), sectors(len(mini))
+	data := dirN + minifatN + miniN
+	for _, e := range entries[1:] {
+		if !e.storage && len(e.data) >= cfbCutoff {
+			data += sectors(len(e.data))
+		}
+	}
+	// the FAT and DIFAT sectors come first: find how many are needed to map every sector, including themselves
+	fatN, difN := 0, 0
+	for fatN*cfbSector/4 < data+fatN+difN {
+		fatN++
+		if fatN > 109 {
+			difN = (fatN - 109 + 126) / 127
+		}
+	}
+	total := fatN + difN + data
+	fat := make([]uint32, fatN*cfbSector/4)
+	for i := range fat {
+		fat[i] = cfbFree
+	}
+	for i := 0; i < fatN; i++ {
+		fat[i] = cfbFATSect
+	}
+	for i := fatN; i < fatN+difN; i++ {
+		fat[i] = cfbDIFSect
+	}
+	next := fatN + difN
+	chain := func(n int) uint32 {
+		if n == 0 {
+			return cfbEnd
+		}
+		start := next
+		for i := 0; i < n; i++ {
+			fat[next] = uint32(next + 1)
+			next++
+		}
+		fat[next-1] = cfbEnd
+		return uint32(start)
+	}
+	dirStart, minifatStart, miniStart := chain(dirN), chain(minifatN), chain(miniN)
+	root.start = miniStart
+	for _, e := range entries[1:] {
+		if !e.storage && len(e.data) >= cfbCutoff {
+			e.start = chain(sectors(len(e.data)))
+		}
+	}
+	out := make([]byte, (1+total)*cfbSector)
+	sect := func(n uint32) []byte { return out[(int(n)+1)*cfbSector:] }
+	// header
+	h := out[:cfbSector]
+	copy(h, oleMagic)
+	binary.LittleEndian.PutUint16(h[24:], 0x3e)
+	binary.LittleEndian.PutUint16(h[26:], 3)
+	binary.LittleEndian.PutUint16(h[28:], 0xfffe)
+	binary.LittleEndian.PutUint16(h[30:], 9)
+	binary.LittleEndian.PutUint16(h[32:], 6)
+	binary.LittleEndian.PutUint32(h[44:], uint32(fatN))
+	binary.LittleEndian.PutUint32(h[48:], dirStart)
+	binary.LittleEndian.PutUint32(h[56:], cfbCutoff)
+	binary.LittleEndian.PutUint32(h[60:], minifatStart)
+	binary.LittleEndian.PutUint32(h[64:], uint32(minifatN))
+	binary.LittleEndian.PutUint32(h[68:], cfbEnd)
+	binary.LittleEndian.PutUint32(h[72:], uint32(difN))
+	difat := make([]uint32, 0, 109+difN*127)
+	for i := 0; i < fatN; i++ {
+		difat = append(difat, uint32(i))
+	}
+	for len(difat) < 109+difN*127 {
+		difat = append(difat, cfbFree)
+	}
+	for i := 0; i < 109; i++ {
+		binary.LittleEndian.PutUint32(h[76+i*4:], difat[i])
+	}
+	if difN > 0 {
+		binary.LittleEndian.PutUint32(h[68:], uint32(fatN))
+		for i := 0; i < difN; i++ {
+			s := sect(uint32(fatN + i))
+			for j := 0; j < 127; j++ {
+				binary.LittleEndian.PutUint32(s[j*4:], difat[109+i*127+j])
+			}
+			nxt := uint32(cfbEnd)
+			if i < difN-1 {
+				nxt = uint32(fatN + i + 1)
+			}
+			binary.LittleEndian.PutUint32(s[508:], nxt)
+		}
+	}
+	for i, v := range fat {
+		binary.LittleEndian.PutUint32(out[cfbSector+i*4:], v)
+	}
+	// directory entries; unused entries in the last directory sector are empty with no siblings or child
+	dir := sect(dirStart)[:dirN*cfbSector]
+	for i := range entries {
+		b := dir[i*128:]
+		e := entries[i]
+		name := utf16.Encode([]rune(e.name))
+		if len(name) > 31 {
+			name = name[:31]
+		}
+		for j, c := range name {
+			binary.LittleEndian.PutUint16(b[j*2:], c)
+		}
+		binary.LittleEndian.PutUint16(b[64:], uint16(len(name)*2+2))
+		switch {
+		case i == 0:
+			b[66] = 5
+		case e.storage:
+			b[66] = 1
+		default:
+			b[66] = 2
+		}
+		b[67] = 1
+		binary.LittleEndian.PutUint32(b[68:], e.left)
+		binary.LittleEndian.PutUint32(b[72:], e.right)
+		binary.LittleEndian.PutUint32(b[76:], e.child)
+		if e.storage || i == 0 {
+			copy(b[80:], e.clsid[:])
+			binary.LittleEndian.PutUint64(b[100:], e.created)
+			binary.LittleEndian.PutUint64(b[108:], e.modified)
+		}
+		switch {
+		case i == 0:
+			binary.LittleEndian.PutUint32(b[116:], e.start)
+			binary.LittleEndian.PutUint32(b[120:], uint32(len(mini)))
+		case !e.storage:
+			binary.LittleEndian.PutUint32(b[116:], e.start)
+			binary.LittleEndian.PutUint32(b[120:], uint32(len(e.data)))
+		}
+	}
+	for i := len(entries); i < dirN*4; i++ {
+		b := dir[i*128:]
+		binary.LittleEndian.PutUint32(b[68:], cfbNoStream)
+		binary.LittleEndian.PutUint32(b[72:], cfbNoStream)
+		binary.LittleEndian.PutUint32(b[76:], cfbNoStream)
+	}
+	if minifatN > 0 {
+		mf := sect(minifatStart)
+		for i := 0; i < minifatN*cfbSector/4; i++ {
+			v := uint32(cfbFree)
+			if i < len(minifat) {
+				v = minifat[i]
+			}
+			binary.LittleEndian.PutUint32(mf[i*4:], v)
+		}
+	}
+	if miniN > 0 {
+		copy(sect(miniStart), mini)
+	}
+	for _, e := range entries[1:] {
+		if !e.storage && len(e.data) >= cfbCutoff {
+			copy(sect(e.start), e.data)
+		}
+	}
+	return out
+}
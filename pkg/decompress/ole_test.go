@@ -0,0 +1,127 @@
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+)
+
+func nativeTest(label, data string) []byte {
+	le32 := func(b []byte, v uint32) []byte {
+		return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	tmp := `C:\Temp\` + label + "\x00"
+	b := append([]byte{0, 0, 0, 0, 2, 0}, label+"\x00"+`C:\docs\`+label+"\x00"+"\x00\x00\x03\x00"...)
+	b = le32(b, uint32(len(tmp)))
+	b = append(b, tmp...)
+	b = le32(b, uint32(len(data)))
+	b = append(b, data...)
+	binary.LittleEndian.PutUint32(b, uint32(len(b)-4))
+	return b
+}
+
+func stream(name string, data []byte) *cfbEntry {
+	return &cfbEntry{name: name, data: data}
+}
+
+func storage(name string, kids ...*cfbEntry) *cfbEntry {
+	return &cfbEntry{name: name, storage: true, kids: kids}
+}
+
+// a document with a packaged file, an embedded OOXML package and an embedded compound document in its object pool
+func oleTest() []byte {
+	big := bytes.Repeat([]byte("word"), 2000)
+	return writeCFB(storage("Root Entry",
+		stream("WordDocument", []byte("host")),
+		storage("Macros", stream("VBA", []byte("not embedded"))),
+		storage("ObjectPool",
+			storage("_1", stream("\x01CompObj", nil), stream("\x01Ole10Native", nativeTest("hello.txt", "hello\n"))),
+			storage("_2", stream("\x01CompObj", nil), stream("Package", []byte("PK\x03\x04"))),
+			storage("_3", stream("\x01CompObj", nil), stream("WordDocument", big), storage("ObjectPool", stream("x", []byte("y")))),
+		),
+	))
+}
+
+func TestOLE(t *testing.T) {
+	bufs := siegreader.New()
+	buf, _ := bufs.Get(bytes.NewReader(oleTest()))
+	defer bufs.Put(buf)
+	d, err := NewOLE(buf, "test.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct{ name, content string }{
+		{"ObjectPool/_1/hello.txt", "hello\n"},
+		{"ObjectPool/_2/Package", "PK\x03\x04"},
+		{"ObjectPool/_3", ""},
+	}
+	var rebuilt []byte
+	for _, e := range expect {
+		if err = d.Next(); err != nil {
+			t.Fatal(err)
+		}
+		byt, _ := io.ReadAll(d.Reader())
+		if d.Path() != "test.doc#"+filepath.FromSlash(e.name) || d.Size() != int64(len(byt)) {
+			t.Errorf("expecting %s, got %s (%d bytes, read %d)", e.name, d.Path(), d.Size(), len(byt))
+		}
+		if e.content == "" {
+			rebuilt = byt
+		} else if string(byt) != e.content {
+			t.Errorf("expecting content %q, got %q", e.content, byt)
+		}
+	}
+	if err = d.Next(); err != io.EOF {
+		t.Errorf("expecting EOF, got %v", err)
+	}
+	// the rebuilt compound document has the embedded storage's entries
+	r, err := mscfb.New(bytes.NewReader(rebuilt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for f, err := r.Next(); err == nil; f, err = r.Next() {
+		names = append(names, strings.Join(append(f.Path, f.Name), "/"))
+		if f.Name == "WordDocument" {
+			if byt, _ := io.ReadAll(f); string(byt) != strings.Repeat("word", 2000) {
+				t.Errorf("bad WordDocument stream in rebuilt document")
+			}
+		}
+	}
+	if strings.Join(names, ",") != "CompObj,ObjectPool,ObjectPool/x,WordDocument" {
+		t.Errorf("unexpected entries in rebuilt document: %v", names)
+	}
+	// compound documents without embedded objects
+	buf, _ = bufs.Get(bytes.NewReader(writeCFB(storage("Root Entry", stream("WordDocument", []byte("host"))))))
+	if _, err = NewOLE(buf, "test.doc"); err != ErrNoEmbedded {
+		t.Errorf("expecting ErrNoEmbedded, got %v", err)
+	}
+	bufs.Put(buf)
+}
+
+// a storage with an empty name has the same key as its parent: walking it must not recurse forever
+func TestOLEHostile(t *testing.T) {
+	bufs := siegreader.New()
+	buf, _ := bufs.Get(bytes.NewReader(writeCFB(storage("Root Entry",
+		storage("", storage("", stream("x", []byte("y")))),
+		storage("ObjectPool", storage("", stream("\x01CompObj", nil), stream("Package", []byte("PK\x03\x04")))),
+	))))
+	defer bufs.Put(buf)
+	d, err := NewOLE(buf, "test.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for err = d.Next(); err == nil; err = d.Next() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("expecting one embedded object, got %d", n)
+	}
+}
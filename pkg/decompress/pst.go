@@ -0,0 +1,596 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Outlook personal folders (PST) files, per [MS-PST]. The node database is read with its two B-trees: the node B-tree (NBT) lists
+// the file's nodes (folders, messages etc.) and the block B-tree (BBT) locates the blocks that hold their data.
+// Messages and attachments are property contexts: B-trees, keyed by property id, held in a heap in the node's data blocks.
+const (
+	pstMagic    = "!BDN"
+	pstClient   = "SM"
+	pstPage     = 512
+	pstMessage  = 0x04 // nid type of a message
+	pstAttached = 0x05 // nid type of an attachment
+	pstInternal = 0x02 // bid flag for blocks of B-tree metadata (XBLOCKs and subnode blocks), which aren't encrypted
+	pstMaxDepth = 16   // limit for the levels of B-trees and nested messages
+)
+
+var (
+	errBadPST         = errors.New("decompress: bad PST file")
+	errPSTUnsupported = errors.New("decompress: unsupported PST file (only unencrypted or compressible encryption, 512 byte page files are supported)")
+)
+
+// pstDecode reverses the permutation of bytes in blocks encoded with compressible encryption, the default
+var pstDecode [256]byte
+
+func init() {
+	for i, v := range [256]byte{
+		65, 54, 19, 98, 168, 33, 110, 187, 244, 22, 204, 4, 127, 100, 232, 93,
+		30, 242, 203, 42, 116, 197, 94, 53, 210, 149, 71, 158, 150, 45, 154, 136,
+		76, 125, 132, 63, 219, 172, 49, 182, 72, 95, 246, 196, 216, 57, 139, 231,
+		35, 59, 56, 142, 200, 193, 223, 37, 177, 32, 165, 70, 96, 78, 156, 251,
+		170, 211, 86, 81, 69, 124, 85, 0, 7, 201, 43, 157, 133, 155, 9, 160,
+		143, 173, 179, 15, 99, 171, 137, 75, 215, 167, 21, 90, 113, 102, 66, 191,
+		38, 74, 107, 152, 250, 234, 119, 83, 178, 112, 5, 44, 253, 89, 58, 134,
+		126, 206, 6, 235, 130, 120, 87, 199, 141, 67, 175, 180, 28, 212, 91, 205,
+		226, 233, 39, 79, 195, 8, 114, 128, 207, 176, 239, 245, 40, 109, 190, 48,
+		77, 52, 146, 213, 14, 60, 34, 50, 229, 228, 249, 159, 194, 209, 10, 129,
+		18, 225, 238, 145, 131, 118, 227, 151, 230, 97, 138, 23, 121, 164, 183, 220,
+		144, 122, 92, 140, 2, 166, 202, 105, 222, 80, 26, 17, 147, 185, 82, 135,
+		88, 252, 237, 29, 55, 73, 27, 106, 224, 41, 51, 153, 189, 108, 217, 148,
+		243, 64, 84, 111, 240, 198, 115, 184, 214, 62, 101, 24, 68, 31, 221, 103,
+		16, 241, 12, 25, 236, 174, 3, 161, 20, 123, 169, 11, 255, 248, 163, 192,
+		162, 1, 247, 46, 188, 36, 104, 117, 13, 254, 186, 47, 181, 208, 218, 61,
+	} {
+		pstDecode[v] = byte(i)
+	}
+}
+
+type pstFile struct {
+	ra       io.ReaderAt
+	w        int // size of bids, ibs and keys: 8 for Unicode files, 4 for ANSI
+	crypt    bool
+	nbt, bbt uint64
+}
+
+// pstNode is a node's data block (or tree of data blocks), and the block that lists its subnodes
+type pstNode struct {
+	nid       uint32
+	data, sub uint64
+}
+
+func newPST(ra io.ReaderAt) (*pstFile, error) {
+	hdr := make([]byte, pstPage+2)
+	if _, err := ra.ReadAt(hdr, 0); err != nil {
+		return nil, errBadPST
+	}
+	if string(hdr[:4]) != pstMagic || string(hdr[8:10]) != pstClient {
+		return nil, errBadPST
+	}
+	p := &pstFile{ra: ra}
+	var crypt byte
+	switch binary.LittleEndian.Uint16(hdr[10:]) {
+	case 14, 15:
+		p.w, crypt = 4, hdr[461]
+		p.nbt, p.bbt = uint64(binary.LittleEndian.Uint32(hdr[188:])), uint64(binary.LittleEndian.Uint32(hdr[196:]))
+	case 23:
+		p.w, crypt = 8, hdr[513]
+		p.nbt, p.bbt = binary.LittleEndian.Uint64(hdr[224:]), binary.LittleEndian.Uint64(hdr[240:])
+	default:
+		return nil, errPSTUnsupported
+	}
+	switch crypt {
+	case 0:
+	case 1:
+		p.crypt = true
+	default:
+		return nil, errPSTUnsupported
+	}
+	return p, nil
+}
+
+func (p *pstFile) word(b []byte) uint64 {
+	if p.w == 8 {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return uint64(binary.LittleEndian.Uint32(b))
+}
+
+// page reads a B-tree page, returning its entries and level (0 for leaf pages)
+func (p *pstFile) page(ib uint64) ([][]byte, int, error) {
+	pg := make([]byte, pstPage)
+	if _, err := p.ra.ReadAt(pg, int64(ib)); err != nil {
+		return nil, 0, errBadPST
+	}
+	// the entries are followed by their count, maximum count, size and the page's level, then padding (Unicode only) and the page trailer
+	sz := pstPage - 8 - p.w*2
+	cEnt, cbEnt, cLevel := int(pg[sz]), int(pg[sz+2]), int(pg[sz+3])
+	if cbEnt < p.w*3 || cEnt*cbEnt > sz {
+		return nil, 0, errBadPST
+	}
+	ents := make([][]byte, cEnt)
+	for i := range ents {
+		ents[i] = pg[i*cbEnt : (i+1)*cbEnt]
+	}
+	return ents, cLevel, nil
+}
+
+// find returns the leaf entry for a key in a B-tree. Entries in intermediate pages are a key and a reference (a bid and ib) to the child page
+// with entries from that key.
+func (p *pstFile) find(ib, key uint64) ([]byte, error) {
+	for i := 0; i < pstMaxDepth; i++ {
+		ents, level, err := p.page(ib)
+		if err != nil {
+			return nil, err
+		}
+		if level == 0 {
+			for _, e := range ents {
+				if p.word(e) == key {
+					return e, nil
+				}
+			}
+			return nil, errBadPST
+		}
+		var found bool
+		for _, e := range ents {
+			if p.word(e) > key {
+				break
+			}
+			ib, found = p.word(e[p.w*2:]), true
+		}
+		if !found {
+			return nil, errBadPST
+		}
+	}
+	return nil, errBadPST
+}
+
+// messages returns the message nodes in the NBT
+func (p *pstFile) messages() ([]pstNode, error) {
+	var ret []pstNode
+	seen := make(map[uint64]bool)
+	var walk func(ib uint64, depth int) error
+	walk = func(ib uint64, depth int) error {
+		if depth >= pstMaxDepth || seen[ib] {
+			return errBadPST
+		}
+		seen[ib] = true
+		ents, level, err := p.page(ib)
+		if err != nil {
+			return err
+		}
+		for _, e := range ents {
+			if level > 0 {
+				if err := walk(p.word(e[p.w*2:]), depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if n := (pstNode{uint32(p.word(e)), p.word(e[p.w:]), p.word(e[p.w*2:])}); n.nid&0x1f == pstMessage {
+				ret = append(ret, n)
+			}
+		}
+		return nil
+	}
+	return ret, walk(p.nbt, 0)
+}
+
+// block reads and decodes a block
+func (p *pstFile) block(bid uint64) ([]byte, error) {
+	e, err := p.find(p.bbt, bid&^1)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.LittleEndian.Uint16(e[p.w*2:]))
+	if _, err := p.ra.ReadAt(buf, int64(p.word(e[p.w:]))); err != nil {
+		return nil, errBadPST
+	}
+	if p.crypt && bid&pstInternal == 0 {
+		for i, c := range buf {
+			buf[i] = pstDecode[c]
+		}
+	}
+	return buf, nil
+}
+
+// data returns a node's data blocks: a single data block, or those listed by an XBLOCK, or by the XBLOCKs listed by an XXBLOCK
+func (p *pstFile) data(bid uint64) ([][]byte, error) {
+	if bid == 0 {
+		return nil, nil
+	}
+	var ret [][]byte
+	var tree func(bid uint64, depth int) error
+	tree = func(bid uint64, depth int) error {
+		b, err := p.block(bid)
+		if err != nil {
+			return err
+		}
+		if bid&pstInternal == 0 {
+			ret = append(ret, b)
+			return nil
+		}
+		// btype (1), cLevel, cEnt, lcbTotal, then the bids
+		if depth > 1 || len(b) < 8 || b[0] != 1 {
+			return errBadPST
+		}
+		n := int(binary.LittleEndian.Uint16(b[2:]))
+		if 8+n*p.w > len(b) {
+			return errBadPST
+		}
+		for i := 0; i < n; i++ {
+			if err := tree(p.word(b[8+i*p.w:]), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return ret, tree(bid, 0)
+}
+
+// subnodes reads a node's subnodes from an SLBLOCK, or from the SLBLOCKs listed by SIBLOCKs
+func (p *pstFile) subnodes(bid uint64) (map[uint32]pstNode, error) {
+	ret := make(map[uint32]pstNode)
+	var tree func(bid uint64, depth int) error
+	tree = func(bid uint64, depth int) error {
+		if depth >= pstMaxDepth {
+			return errBadPST
+		}
+		b, err := p.block(bid)
+		if err != nil {
+			return err
+		}
+		// btype (2), cLevel, cEnt and (Unicode) padding, then the entries
+		hdr := p.w
+		if len(b) < hdr || b[0] != 2 {
+			return errBadPST
+		}
+		n, sz := int(binary.LittleEndian.Uint16(b[2:])), p.w*3
+		if b[1] > 0 {
+			sz = p.w * 2
+		}
+		if hdr+n*sz > len(b) {
+			return errBadPST
+		}
+		for i := 0; i < n; i++ {
+			e := b[hdr+i*sz:]
+			if b[1] > 0 {
+				if err := tree(p.word(e[p.w:]), depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			nid := uint32(p.word(e))
+			ret[nid] = pstNode{nid, p.word(e[p.w:]), p.word(e[p.w*2:])}
+		}
+		return nil
+	}
+	if bid == 0 {
+		return ret, nil
+	}
+	return ret, tree(bid, 0)
+}
+
+// pstHeap is a heap-on-node. Items on the heap are addressed by HIDs: a block index (the high 16 bits) and a 1-based index (bits 5 to 15)
+// into the page map of allocations at the end of the block.
+type pstHeap [][]byte
+
+func (h pstHeap) alloc(hid uint32) ([]byte, error) {
+	blk, idx := int(hid>>16), int(hid>>5&0x7ff)
+	if hid&0x1f != 0 || blk >= len(h) || idx == 0 || len(h[blk]) < 2 {
+		return nil, errBadPST
+	}
+	b := h[blk]
+	pm := int(binary.LittleEndian.Uint16(b))
+	if pm+4+(idx+1)*2 > len(b) || idx > int(binary.LittleEndian.Uint16(b[pm:])) {
+		return nil, errBadPST
+	}
+	start, end := int(binary.LittleEndian.Uint16(b[pm+4+(idx-1)*2:])), int(binary.LittleEndian.Uint16(b[pm+4+idx*2:]))
+	if start > end || end > len(b) {
+		return nil, errBadPST
+	}
+	return b[start:end], nil
+}
+
+// records reads the records of a BTree-on-heap, which has a header giving the size of the keys and data and the number of intermediate levels
+func (h pstHeap) records(hid uint32) (int, int, [][]byte, error) {
+	hdr, err := h.alloc(hid)
+	if err != nil || len(hdr) < 8 || hdr[0] != 0xb5 {
+		return 0, 0, nil, errBadPST
+	}
+	key, ent, levels := int(hdr[1]), int(hdr[2]), int(hdr[3])
+	if levels >= pstMaxDepth || key == 0 {
+		return 0, 0, nil, errBadPST
+	}
+	var ret [][]byte
+	var tree func(hid uint32, level int) error
+	tree = func(hid uint32, level int) error {
+		b, err := h.alloc(hid)
+		if err != nil {
+			return err
+		}
+		sz := key + ent
+		if level > 0 {
+			sz = key + 4
+		}
+		for i := 0; i+sz <= len(b); i += sz {
+			if level == 0 {
+				ret = append(ret, b[i:i+sz])
+			} else if err := tree(binary.LittleEndian.Uint32(b[i+key:]), level-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if root := binary.LittleEndian.Uint32(hdr[4:]); root != 0 {
+		err = tree(root, levels)
+	}
+	return key, ent, ret, err
+}
+
+// pstPC is a property context: property records, keyed by id, with a type and either a value (for types of 4 bytes or less)
+// or a reference to the value in the heap or in a subnode
+type pstPC struct {
+	p     *pstFile
+	heap  pstHeap
+	subs  map[uint32]pstNode
+	props map[uint16][2]uint32
+}
+
+func (p *pstFile) pc(n pstNode) (*pstPC, error) {
+	blocks, err := p.data(n.data)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := p.subnodes(n.sub)
+	if err != nil {
+		return nil, err
+	}
+	// heap header: the page map offset, a signature, the client signature (for property contexts) and the HID of the BTree-on-heap
+	if len(blocks) == 0 || len(blocks[0]) < 8 || blocks[0][2] != 0xec || blocks[0][3] != 0xbc {
+		return nil, errBadPST
+	}
+	pc := &pstPC{p: p, heap: pstHeap(blocks), subs: subs, props: make(map[uint16][2]uint32)}
+	key, ent, recs, err := pc.heap.records(binary.LittleEndian.Uint32(blocks[0][4:]))
+	if err != nil || key != 2 || ent != 6 {
+		return nil, errBadPST
+	}
+	for _, r := range recs {
+		pc.props[binary.LittleEndian.Uint16(r)] = [2]uint32{uint32(binary.LittleEndian.Uint16(r[2:])), binary.LittleEndian.Uint32(r[4:])}
+	}
+	return pc, nil
+}
+
+// value returns a property's type and value
+func (pc *pstPC) value(id uint16) (uint16, []byte, error) {
+	prop, ok := pc.props[id]
+	if !ok {
+		return 0, nil, nil
+	}
+	typ, v := uint16(prop[0]), prop[1]
+	switch typ {
+	case 0x0002, 0x0003, 0x0004, 0x000a, 0x000b:
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, v)
+		return typ, b, nil
+	}
+	if v == 0 {
+		return typ, nil, nil
+	}
+	if v&0x1f == 0 {
+		b, err := pc.heap.alloc(v)
+		return typ, b, err
+	}
+	n, ok := pc.subs[v]
+	if !ok {
+		return typ, nil, errBadPST
+	}
+	blocks, err := pc.p.data(n.data)
+	return typ, bytes.Join(blocks, nil), err
+}
+
+func (pc *pstPC) string(id uint16) string {
+	typ, b, _ := pc.value(id)
+	switch typ {
+	case 0x001f:
+		return utf16String(b)
+	case 0x001e:
+		return strings.TrimRight(string(b), "\x00")
+	}
+	return ""
+}
+
+func (pc *pstPC) int(id uint16) int {
+	if typ, b, _ := pc.value(id); typ == 0x0003 {
+		return int(int32(binary.LittleEndian.Uint32(b)))
+	}
+	return 0
+}
+
+func (pc *pstPC) time(id uint16) time.Time {
+	if typ, b, _ := pc.value(id); typ == 0x0040 && len(b) == 8 && binary.LittleEndian.Uint64(b) > 0 {
+		return filetime(b)
+	}
+	return time.Time{}
+}
+
+// pstAttachment is an attachment of a message, or an error reading a message or attachment
+type pstAttachment struct {
+	name string
+	mime string
+	mod  time.Time
+	pc   *pstPC
+	err  error
+}
+
+type pstD struct {
+	p       string
+	f       *pstFile
+	msgs    []pstNode
+	mi      int
+	atts    []pstAttachment
+	idx     int
+	rdr     io.Reader
+	sz      int64
+	written map[string]bool
+}
+
+// NewPST returns a Decompressor for the attachments of the messages in an Outlook personal folders (PST) file:
+// ANSI and Unicode files with 512 byte pages that are unencrypted or use compressible encryption.
+// Each message's attachments are named within a directory for the message (as with NewMail), and the attachments of attached messages
+// within a further directory named by the attached message's display name. Attached files and OLE objects are read; attachments by reference,
+// which have no data, are skipped.
+func NewPST(ra io.ReaderAt, path string) (Decompressor, error) {
+	f, err := newPST(ra)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := f.messages()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].nid < msgs[j].nid })
+	return &pstD{p: path, f: f, msgs: msgs}, nil
+}
+
+// message finds the attachments of a message (and of any messages attached to it)
+func (d *pstD) message(pc *pstPC, dir string, date time.Time, depth int) {
+	nids := make([]uint32, 0, len(pc.subs))
+	for nid := range pc.subs {
+		if nid&0x1f == pstAttached {
+			nids = append(nids, nid)
+		}
+	}
+	sort.Slice(nids, func(i, j int) bool { return nids[i] < nids[j] })
+	for i, nid := range nids {
+		apc, err := d.f.pc(pc.subs[nid])
+		if err != nil {
+			d.atts = append(d.atts, pstAttachment{name: fmt.Sprintf("%s/attachment-%d", dir, i+1), err: err})
+			continue
+		}
+		a := pstAttachment{mime: apc.string(0x370e), pc: apc}
+		if a.mod = apc.time(0x3008); a.mod.IsZero() {
+			a.mod = date
+		}
+		for _, id := range []uint16{0x3707, 0x3704, 0x3001} {
+			if a.name = mailName(apc.string(id)); a.name != "" {
+				break
+			}
+		}
+		if a.name == "" {
+			a.name = fmt.Sprintf("attachment-%d", i+1)
+		}
+		a.name = dir + "/" + a.name
+		typ, b, err := apc.value(0x3701)
+		switch {
+		case err != nil:
+			a.err = err
+		case typ == 0x0102:
+		case typ == 0x000d && len(b) >= 4:
+			// an attached message or OLE object is a subnode of the attachment
+			sub, ok := apc.subs[binary.LittleEndian.Uint32(b)]
+			if !ok {
+				a.err = errBadPST
+				break
+			}
+			if mpc, err := d.f.pc(sub); err == nil {
+				if depth < pstMaxDepth {
+					d.message(mpc, a.name, a.mod, depth+1)
+				}
+				continue
+			}
+		default:
+			continue
+		}
+		d.atts = append(d.atts, a)
+	}
+}
+
+func (d *pstD) Next() error {
+	d.idx++
+	for d.idx >= len(d.atts) {
+		if d.mi >= len(d.msgs) {
+			return io.EOF
+		}
+		d.atts, d.idx = d.atts[:0], 0
+		d.mi++
+		pc, err := d.f.pc(d.msgs[d.mi-1])
+		if err != nil {
+			d.atts = append(d.atts, pstAttachment{name: fmt.Sprintf("message-%d", d.mi), err: err})
+			continue
+		}
+		date := pc.time(0x0e06)
+		if date.IsZero() {
+			date = pc.time(0x0039)
+		}
+		d.message(pc, mailDir(pc.string(0x1035), d.mi), date, 0)
+	}
+	a := d.atts[d.idx]
+	if a.err != nil {
+		d.rdr, d.sz = errReader{a.err}, 0
+		return nil
+	}
+	typ, b, err := a.pc.value(0x3701)
+	if err == nil && typ == 0x000d {
+		var blocks [][]byte
+		if blocks, err = d.f.data(a.pc.subs[binary.LittleEndian.Uint32(b)].data); err == nil {
+			b = bytes.Join(blocks, nil)
+		}
+	}
+	if err != nil {
+		d.rdr, d.sz = errReader{err}, 0
+		return nil
+	}
+	d.rdr, d.sz = bytes.NewReader(b), int64(len(b))
+	return nil
+}
+
+func (d *pstD) Reader() io.Reader {
+	return d.rdr
+}
+
+func (d *pstD) Path() string {
+	return Arcpath(d.p, filepath.FromSlash(d.atts[d.idx].name))
+}
+
+func (d *pstD) MIME() string {
+	return d.atts[d.idx].mime
+}
+
+func (d *pstD) Size() int64 {
+	return d.sz
+}
+
+func (d *pstD) Mod() time.Time {
+	return d.atts[d.idx].mod
+}
+
+func (d *pstD) Dirs() []string {
+	if d.written == nil {
+		d.written = make(map[string]bool)
+	}
+	return dirs(d.p, d.atts[d.idx].name, d.written)
+}
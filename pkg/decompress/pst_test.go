@@ -0,0 +1,203 @@
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+)
+
+// pstBuilder writes a PST file, with a single leaf page (under an intermediate root page) for each B-tree
+type pstBuilder struct {
+	w     int
+	crypt bool
+	out   []byte
+	bbt   [][]byte
+	last  uint64
+}
+
+func (b *pstBuilder) word(byt []byte, v uint64) []byte {
+	if b.w == 8 {
+		return append(byt, byte(v), byte(v>>8), byte(v>>16), byte(v>>24), byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+	}
+	return append(byt, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (b *pstBuilder) write(data []byte) uint64 {
+	ib := uint64(len(b.out))
+	b.out = append(b.out, data...)
+	b.out = append(b.out, make([]byte, 64-len(data)%64)...)
+	return ib
+}
+
+func (b *pstBuilder) block(data []byte, internal bool) uint64 {
+	b.last += 4
+	bid := b.last
+	if internal {
+		bid |= pstInternal
+	} else if b.crypt {
+		enc := make([]byte, len(data))
+		for i, c := range data {
+			for j, v := range pstDecode {
+				if v == c {
+					enc[i] = byte(j)
+				}
+			}
+		}
+		data = enc
+	}
+	ib := b.write(data)
+	e := b.word(b.word(nil, bid), ib)
+	e = append(e, byte(len(data)), byte(len(data)>>8), 1, 0)
+	b.bbt = append(b.bbt, append(e, make([]byte, b.w-4)...))
+	return bid
+}
+
+// subnodes writes an SLBLOCK
+func (b *pstBuilder) subnodes(nodes ...pstNode) uint64 {
+	byt := append([]byte{2, 0, byte(len(nodes)), 0}, make([]byte, b.w-4)...)
+	for _, n := range nodes {
+		byt = b.word(b.word(b.word(byt, uint64(n.nid)), n.data), n.sub)
+	}
+	return b.block(byt, true)
+}
+
+// xblock writes data blocks and the XBLOCK that lists them
+func (b *pstBuilder) xblock(data ...[]byte) uint64 {
+	byt := []byte{1, 1, byte(len(data)), 0, 0, 0, 0, 0}
+	for _, d := range data {
+		byt = b.word(byt, b.block(d, false))
+	}
+	return b.block(byt, true)
+}
+
+func (b *pstBuilder) page(ents [][]byte, level int) uint64 {
+	pg := make([]byte, pstPage)
+	for i, e := range ents {
+		copy(pg[i*len(e):], e)
+	}
+	sz := pstPage - 8 - b.w*2
+	pg[sz], pg[sz+1], pg[sz+2], pg[sz+3] = byte(len(ents)), byte(len(ents)), byte(len(ents[0])), byte(level)
+	return b.write(pg)
+}
+
+// tree writes a B-tree's leaf page and its root
+func (b *pstBuilder) tree(ents [][]byte) uint64 {
+	leaf := b.page(ents, 0)
+	return b.page([][]byte{b.word(b.word(b.word(nil, b.key(ents[0])), 0), leaf)}, 1)
+}
+
+// key reads the first word of an entry
+func (b *pstBuilder) key(e []byte) uint64 {
+	if b.w == 8 {
+		return binary.LittleEndian.Uint64(e)
+	}
+	return uint64(binary.LittleEndian.Uint32(e))
+}
+
+func (b *pstBuilder) file(msgs ...pstNode) []byte {
+	var nbt [][]byte
+	for _, n := range append(msgs, pstNode{nid: 0x122}) {
+		e := b.word(b.word(b.word(nil, uint64(n.nid)), n.data), n.sub)
+		nbt = append(nbt, append(e, make([]byte, b.w)...))
+	}
+	sort.Slice(nbt, func(i, j int) bool { return b.key(nbt[i]) < b.key(nbt[j]) })
+	nroot, broot := b.tree(nbt), b.tree(b.bbt)
+	hdr := b.out
+	copy(hdr, pstMagic)
+	copy(hdr[8:], pstClient)
+	var crypt byte
+	if b.crypt {
+		crypt = 1
+	}
+	if b.w == 8 {
+		hdr[10], hdr[513] = 23, crypt
+		binary.LittleEndian.PutUint64(hdr[224:], nroot)
+		binary.LittleEndian.PutUint64(hdr[240:], broot)
+	} else {
+		hdr[10], hdr[461] = 14, crypt
+		binary.LittleEndian.PutUint32(hdr[188:], uint32(nroot))
+		binary.LittleEndian.PutUint32(hdr[196:], uint32(broot))
+	}
+	return b.out
+}
+
+type pcProp struct {
+	id, typ uint16
+	v       []byte
+	hnid    uint32 // for values in subnodes
+}
+
+// pc writes a property context heap, with the BTree-on-heap header as the first allocation and its records as the second
+func (b *pstBuilder) pc(props ...pcProp) uint64 {
+	sort.Slice(props, func(i, j int) bool { return props[i].id < props[j].id })
+	allocs := [][]byte{{0xb5, 2, 6, 0, 2 << 5, 0, 0, 0}, nil}
+	for _, p := range props {
+		hnid := p.hnid
+		switch {
+		case hnid != 0:
+		case p.typ == 0x0003:
+			hnid = binary.LittleEndian.Uint32(p.v)
+		default:
+			allocs = append(allocs, p.v)
+			hnid = uint32(len(allocs)) << 5
+		}
+		allocs[1] = append(allocs[1], byte(p.id), byte(p.id>>8), byte(p.typ), byte(p.typ>>8), byte(hnid), byte(hnid>>8), byte(hnid>>16), byte(hnid>>24))
+	}
+	heap := []byte{0, 0, 0xec, 0xbc, 1 << 5, 0, 0, 0, 0, 0, 0, 0}
+	ibs := []uint16{uint16(len(heap))}
+	for _, a := range allocs {
+		heap = append(heap, a...)
+		ibs = append(ibs, uint16(len(heap)))
+	}
+	binary.LittleEndian.PutUint16(heap, uint16(len(heap)))
+	heap = append(heap, byte(len(allocs)), 0, 0, 0)
+	for _, ib := range ibs {
+		heap = append(heap, byte(ib), byte(ib>>8))
+	}
+	return b.block(heap, false)
+}
+
+func le32(v uint32) []byte {
+	return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+}
+
+// a message with an attached file, a file stored in a subnode (in two blocks) and an attached message, which has a file of its own
+func pstTest(w int, crypt bool) []byte {
+	b := &pstBuilder{w: w, crypt: crypt, out: make([]byte, 1024)}
+	mod := make([]byte, 8)
+	binary.LittleEndian.PutUint64(mod, 133485408000000000) // 2024-01-01
+	att1 := b.pc(pcProp{id: 0x3707, typ: 0x001f, v: utf16Test("a.txt")}, pcProp{id: 0x3701, typ: 0x0102, v: []byte("hello")}, pcProp{id: 0x3008, typ: 0x0040, v: mod})
+	att2 := b.pc(pcProp{id: 0x3704, typ: 0x001e, v: []byte("big.bin")}, pcProp{id: 0x3701, typ: 0x0102, hnid: 0x21})
+	att2sub := b.subnodes(pstNode{0x21, b.xblock([]byte("abc"), []byte("def")), 0})
+	att4 := b.pc(pcProp{id: 0x3707, typ: 0x001f, v: utf16Test("inner.txt")}, pcProp{id: 0x3701, typ: 0x0102, v: []byte("in")})
+	emb := b.pc(pcProp{id: 0x0037, typ: 0x001f, v: utf16Test("Fwd")})
+	embsub := b.subnodes(pstNode{0x25, att4, 0})
+	att3 := b.pc(pcProp{id: 0x3001, typ: 0x001f, v: utf16Test("Fwd")}, pcProp{id: 0x3705, typ: 0x0003, v: le32(5)}, pcProp{id: 0x3701, typ: 0x000d, v: append(le32(0x64), le32(0)...)})
+	att3sub := b.subnodes(pstNode{0x64, emb, embsub})
+	msg := b.pc(pcProp{id: 0x1035, typ: 0x001f, v: utf16Test("<pst@example.com>")}, pcProp{id: 0x0e06, typ: 0x0040, v: mod})
+	msgsub := b.subnodes(pstNode{0x25, att1, 0}, pstNode{0x45, att2, att2sub}, pstNode{0x65, att3, att3sub}, pstNode{0x692, 0, 0})
+	return b.file(pstNode{0x2004, msg, msgsub})
+}
+
+func TestPST(t *testing.T) {
+	for _, v := range []struct {
+		w     int
+		crypt bool
+	}{{8, true}, {4, false}} {
+		d, err := NewPST(bytes.NewReader(pstTest(v.w, v.crypt)), "test.pst")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testMail(t, d, "test.pst", []mailTest{
+			{"pst@example.com/a.txt", "", "hello"},
+			{"pst@example.com/big.bin", "", "abcdef"},
+			{"pst@example.com/Fwd/inner.txt", "", "in"},
+		})
+	}
+	byt := pstTest(8, true)
+	byt[10] = 36
+	if _, err := NewPST(bytes.NewReader(byt), "test.pst"); err != errPSTUnsupported {
+		t.Errorf("expecting an unsupported error for a 4K page file, got %v", err)
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	rar4Magic = "Rar!\x1a\x07\x00"
+	rar5Magic = "Rar!\x1a\x07\x01\x00"
+)
+
+// ErrNotRAR is returned by NewRAR if content doesn't begin with a RAR signature.
+var ErrNotRAR = errors.New("decompress: not a RAR archive")
+
+var (
+	errBadRAR       = errors.New("decompress: bad RAR header")
+	errEncryptedRAR = errors.New("decompress: encrypted RAR archive")
+)
+
+type rarFile struct {
+	name   string
+	mod    time.Time
+	dir    bool
+	off    int64 // offset of the member's data
+	pack   int64
+	size   int64
+	method string
+	err    error // the reason the member can't be unpacked
+}
+
+type rarD struct {
+	p       string
+	ra      io.ReaderAt
+	files   []rarFile
+	idx     int
+	written map[string]bool
+}
+
+// NewRAR returns a Decompressor for RAR archives (both the RAR 1.5-4.x and the RAR 5.0 formats).
+// All members are listed but only stored (uncompressed) members can be unpacked: members that are compressed,
+// encrypted or split across volumes are reported with an error when read. Archives with encrypted headers can't be listed.
+func NewRAR(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	return newRAR(ra, path, sz)
+}
+
+func newRAR(ra io.ReaderAt, path string, sz int64) (*rarD, error) {
+	buf := make([]byte, len(rar5Magic))
+	n, _ := ra.ReadAt(buf, 0)
+	r := &rarD{p: path, ra: ra, idx: -1}
+	var err error
+	switch {
+	case n == len(rar5Magic) && string(buf) == rar5Magic:
+		err = r.parse5(int64(len(rar5Magic)), sz)
+	case n >= len(rar4Magic) && string(buf[:len(rar4Magic)]) == rar4Magic:
+		err = r.parse4(int64(len(rar4Magic)), sz)
+	default:
+		return nil, ErrNotRAR
+	}
+	return r, err
+}
+
+// parse4 reads the blocks of a RAR 4 archive. All blocks start with a CRC, type, flags and header size;
+// blocks with the long block flag give the size of data that follows the header.
+func (r *rarD) parse4(off, sz int64) error {
+	for off+7 <= sz {
+		hdr := make([]byte, 7)
+		if _, err := r.ra.ReadAt(hdr, off); err != nil {
+			return err
+		}
+		typ, flags, size := hdr[2], binary.LittleEndian.Uint16(hdr[3:]), int64(binary.LittleEndian.Uint16(hdr[5:]))
+		if size < 7 || off+size > sz {
+			return errBadRAR
+		}
+		buf := make([]byte, size)
+		if _, err := r.ra.ReadAt(buf, off); err != nil {
+			return err
+		}
+		if uint16(crc32.ChecksumIEEE(buf[2:])) != binary.LittleEndian.Uint16(buf) {
+			return errBadRAR
+		}
+		var add int64
+		if flags&0x8000 != 0 && size >= 11 {
+			add = int64(binary.LittleEndian.Uint32(buf[7:]))
+		}
+		switch typ {
+		case 0x73: // main header
+			if flags&0x0080 != 0 {
+				return errEncryptedRAR
+			}
+		case 0x74: // file header
+			if size < 32 {
+				return errBadRAR
+			}
+			f := rarFile{
+				off:  off + size,
+				pack: int64(binary.LittleEndian.Uint32(buf[7:])),
+				size: int64(binary.LittleEndian.Uint32(buf[11:])),
+				mod:  dosTime(binary.LittleEndian.Uint32(buf[20:])),
+				dir:  flags&0xe0 == 0xe0,
+			}
+			name := buf[32:]
+			if flags&0x0100 != 0 { // 64 bit sizes
+				if size < 40 {
+					return errBadRAR
+				}
+				f.pack |= int64(binary.LittleEndian.Uint32(buf[32:])) << 32
+				f.size |= int64(binary.LittleEndian.Uint32(buf[36:])) << 32
+				add, name = f.pack, buf[40:]
+			}
+			if f.pack < 0 || f.size < 0 {
+				return errBadRAR
+			}
+			if l := int(binary.LittleEndian.Uint16(buf[26:])); l <= len(name) {
+				name = name[:l]
+			} else {
+				return errBadRAR
+			}
+			f.name = strings.ReplaceAll(rar4Name(name, flags&0x0200 != 0), "\\", "/")
+			if buf[25] == 0x30 {
+				f.method = "store"
+			} else {
+				f.method = fmt.Sprintf("rar%d", buf[24])
+			}
+			f.err = rarErr(f.method, flags&0x04 != 0, flags&0x03 != 0)
+			r.files = append(r.files, f)
+		case 0x7b: // end of archive
+			return nil
+		}
+		// the block's data must be within the archive
+		if add < 0 || off+size+add > sz {
+			return errBadRAR
+		}
+		off += size + add
+	}
+	return nil
+}
+
+// rar4Name decodes a RAR 4 file name. Unicode names are stored after an ASCII version of the name, in a compact
+// encoding that mostly refers to the ASCII name and a common high byte. Names without an ASCII version are UTF-8.
+func rar4Name(b []byte, unicode bool) string {
+	if !unicode {
+		return string(b) // should be the OEM code page, but that is unknown
+	}
+	i := strings.IndexByte(string(b), 0)
+	if i < 0 {
+		if utf8.Valid(b) {
+			return string(b)
+		}
+		return strings.ToValidUTF8(string(b), "_")
+	}
+	ascii, enc := b[:i], b[i+1:]
+	if len(enc) == 0 {
+		return string(ascii)
+	}
+	high := uint16(enc[0])
+	name := make([]uint16, 0, len(ascii))
+	var flags byte
+	var bits int
+	for pos := 1; pos < len(enc); {
+		if bits == 0 {
+			flags, bits = enc[pos], 8
+			pos++
+			if pos >= len(enc) {
+				break
+			}
+		}
+		switch flags >> 6 {
+		case 0:
+			name = append(name, uint16(enc[pos]))
+			pos++
+		case 1:
+			name = append(name, uint16(enc[pos])|high<<8)
+			pos++
+		case 2:
+			if pos+1 >= len(enc) {
+				pos = len(enc)
+				break
+			}
+			name = append(name, binary.LittleEndian.Uint16(enc[pos:]))
+			pos += 2
+		case 3:
+			l := int(enc[pos])
+			pos++
+			if l&0x80 != 0 {
+				if pos >= len(enc) {
+					break
+				}
+				correction := enc[pos]
+				pos++
+				for l = l&0x7f + 2; l > 0 && len(name) < len(ascii); l-- {
+					name = append(name, uint16(ascii[len(name)]+correction)|high<<8)
+				}
+			} else {
+				for l += 2; l > 0 && len(name) < len(ascii); l-- {
+					name = append(name, uint16(ascii[len(name)]))
+				}
+			}
+		}
+		flags <<= 2
+		bits -= 2
+	}
+	return string(utf16.Decode(name))
+}
+
+// dosTime converts an MS-DOS date and time. Like zip, the time zone isn't known.
+func dosTime(t uint32) time.Time {
+	d := t >> 16
+	return time.Date(int(d>>9)+1980, time.Month(d>>5&0xf), int(d&0x1f), int(t>>11&0x1f), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.UTC)
+}
+
+// parse5 reads the headers of a RAR 5 archive. Headers start with a CRC and the header size, then the type and flags.
+// Flags say whether there is an extra area (at the end of the header) and data (after the header).
+func (r *rarD) parse5(off, sz int64) error {
+	for off < sz {
+		hdr := make([]byte, 7) // the CRC and the header size, which is at most 3 bytes
+		n, err := r.ra.ReadAt(hdr, off)
+		if n < len(hdr) && err != io.EOF {
+			return err
+		}
+		if n < 5 {
+			return errBadRAR
+		}
+		p := &rarBuf{b: hdr[4:n]}
+		size := p.vint()
+		if p.err != nil || size > 2<<20 {
+			return errBadRAR
+		}
+		start := int64(n - len(p.b)) // the offset of the header type, from the start of the header
+		if off+start+int64(size) > sz {
+			return errBadRAR
+		}
+		buf := make([]byte, start+int64(size))
+		if _, err := r.ra.ReadAt(buf, off); err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(buf[4:]) != binary.LittleEndian.Uint32(buf) {
+			return errBadRAR
+		}
+		p = &rarBuf{b: buf[start:]}
+		typ, flags := p.vint(), p.vint()
+		var extra, data uint64
+		if flags&0x01 != 0 {
+			extra = p.vint()
+		}
+		if flags&0x02 != 0 {
+			data = p.vint()
+		}
+		if p.err != nil || extra > uint64(len(p.b)) || data > uint64(sz) {
+			return errBadRAR
+		}
+		switch typ {
+		case 2: // file header
+			f := rarFile{off: off + int64(len(buf)), pack: int64(data)}
+			fflags := p.vint()
+			f.size = int64(p.vint())
+			p.vint() // attributes
+			f.dir = fflags&0x01 != 0
+			if fflags&0x02 != 0 {
+				f.mod = time.Unix(int64(p.uint32()), 0)
+			}
+			if fflags&0x04 != 0 {
+				p.uint32() // CRC
+			}
+			if fflags&0x08 != 0 { // unknown size
+				f.size = f.pack
+			}
+			comp := p.vint()
+			p.vint() // host OS
+			f.name = string(p.bytes(p.vint()))
+			if p.err != nil {
+				return errBadRAR
+			}
+			if comp>>7&0x07 == 0 {
+				f.method = "store"
+			} else {
+				f.method = fmt.Sprintf("rar%d", []int{50, 70}[comp&0x01])
+			}
+			var encrypted bool
+			if mod, enc := rar5Extra(buf[len(buf)-int(extra):]); enc {
+				encrypted = true
+			} else if !mod.IsZero() {
+				f.mod = mod
+			}
+			f.err = rarErr(f.method, encrypted, flags&0x18 != 0)
+			r.files = append(r.files, f)
+		case 4: // archive encryption header
+			return errEncryptedRAR
+		case 5: // end of archive
+			return nil
+		}
+		off += int64(len(buf)) + int64(data)
+	}
+	return nil
+}
+
+// rar5Extra reads a file header's extra area for a modification time and whether the file is encrypted
+func rar5Extra(b []byte) (time.Time, bool) {
+	var mod time.Time
+	p := &rarBuf{b: b}
+	for len(p.b) > 0 && p.err == nil {
+		rec := &rarBuf{b: p.bytes(p.vint())}
+		switch rec.vint() {
+		case 0x01:
+			return mod, true
+		case 0x03:
+			flags := rec.vint()
+			if flags&0x02 == 0 {
+				break
+			}
+			if flags&0x01 != 0 {
+				mod = time.Unix(int64(rec.uint32()), 0)
+			} else if b := rec.bytes(8); len(b) == 8 {
+				mod = filetime(b)
+			}
+		}
+	}
+	return mod, false
+}
+
+func rarErr(method string, encrypted, split bool) error {
+	switch {
+	case encrypted:
+		return errEncryptedRAR
+	case split:
+		return errors.New("decompress: RAR member is split across volumes")
+	case method != "store":
+		return fmt.Errorf("decompress: unsupported RAR compression (%s)", method)
+	}
+	return nil
+}
+
+// rarBuf parses RAR 5 headers. The first error is kept and later reads return zero values.
+type rarBuf struct {
+	b   []byte
+	err error
+}
+
+func (p *rarBuf) bytes(n uint64) []byte {
+	if n > uint64(len(p.b)) {
+		p.err, p.b = errBadRAR, nil
+		return nil
+	}
+	b := p.b[:n]
+	p.b = p.b[n:]
+	return b
+}
+
+// vint reads a RAR 5 variable length integer: seven bits a byte, least significant first, the high bit is set if more bytes follow
+func (p *rarBuf) vint() uint64 {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		b := p.bytes(1)
+		if b == nil {
+			return 0
+		}
+		v |= uint64(b[0]&0x7f) << (7 * uint(i))
+		if b[0]&0x80 == 0 {
+			return v
+		}
+	}
+	p.err = errBadRAR
+	return 0
+}
+
+func (p *rarBuf) uint32() uint32 {
+	if b := p.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *rarD) Next() error {
+	// scan past directories
+	for r.idx++; r.idx < len(r.files) && r.files[r.idx].dir; r.idx++ {
+	}
+	if r.idx >= len(r.files) {
+		return io.EOF
+	}
+	return nil
+}
+
+func (r *rarD) Reader() io.Reader {
+	f := r.files[r.idx]
+	if f.err != nil {
+		return errReader{f.err}
+	}
+	return io.NewSectionReader(r.ra, f.off, f.pack)
+}
+
+func (r *rarD) Path() string {
+	return Arcpath(r.p, filepath.FromSlash(r.files[r.idx].name))
+}
+
+func (r *rarD) MIME() string {
+	return ""
+}
+
+func (r *rarD) Size() int64 {
+	return r.files[r.idx].size
+}
+
+func (r *rarD) Mod() time.Time {
+	return r.files[r.idx].mod
+}
+
+func (r *rarD) Dirs() []string {
+	if r.written == nil {
+		r.written = make(map[string]bool)
+	}
+	return dirs(r.p, r.files[r.idx].name, r.written)
+}
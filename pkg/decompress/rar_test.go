@@ -0,0 +1,173 @@
+package decompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type rarMember struct {
+	name    string
+	content string
+	dir     bool
+	stored  bool
+}
+
+var rarMembers = []rarMember{
+	{"dir", "", true, true},
+	{"dir/a.txt", "hello", false, true},
+	{"b.txt", "world", false, true},
+	{"c.txt", "packed", false, false},
+}
+
+func rar4Block(typ byte, flags uint16, body []byte) []byte {
+	h := []byte{typ, byte(flags), byte(flags >> 8), 0, 0}
+	binary.LittleEndian.PutUint16(h[3:], uint16(7+len(body)))
+	h = append(h, body...)
+	return append([]byte{byte(crc32.ChecksumIEEE(h)), byte(crc32.ChecksumIEEE(h) >> 8)}, h...)
+}
+
+func rar4Test(members []rarMember) []byte {
+	block := rar4Block
+	buf := append([]byte(rar4Magic), block(0x73, 0, make([]byte, 6))...)
+	for _, m := range members {
+		flags, method := uint16(0x8000), byte(0x30)
+		if m.dir {
+			flags |= 0xe0
+		}
+		if !m.stored {
+			method = 0x33
+		}
+		body := make([]byte, 25)
+		binary.LittleEndian.PutUint32(body, uint32(len(m.content)))
+		binary.LittleEndian.PutUint32(body[4:], uint32(len(m.content)))
+		binary.LittleEndian.PutUint32(body[13:], 0x52644000) // 2021-03-04 08:00:00
+		body[17], body[18] = 29, method
+		binary.LittleEndian.PutUint16(body[19:], uint16(len(m.name)))
+		body = append(body, strings.ReplaceAll(m.name, "/", "\\")...)
+		buf = append(buf, block(0x74, flags, body)...)
+		buf = append(buf, m.content...)
+	}
+	return append(buf, block(0x7b, 0, nil)...)
+}
+
+func rar5Test(members []rarMember) []byte {
+	vint := func(v int) []byte {
+		var b []byte
+		for ; v > 0x7f; v >>= 7 {
+			b = append(b, byte(v)|0x80)
+		}
+		return append(b, byte(v))
+	}
+	le32 := func(b []byte, v uint32) []byte {
+		return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	block := func(typ int, body []byte, data string) []byte {
+		h := vint(typ)
+		if data != "" {
+			h = append(append(h, 2), vint(len(data))...)
+		} else {
+			h = append(h, 0)
+		}
+		h = append(h, body...)
+		h = append(vint(len(h)), h...)
+		return append(append(le32(nil, crc32.ChecksumIEEE(h)), h...), data...)
+	}
+	buf := append([]byte(rar5Magic), block(1, vint(0), "")...)
+	for _, m := range members {
+		fflags, comp := 0x02, 0
+		if m.dir {
+			fflags |= 0x01
+		}
+		if !m.stored {
+			comp = 3 << 7
+		}
+		body := append(vint(fflags), vint(len(m.content))...)
+		body = append(body, 0x20)                     // attributes
+		body = le32(body, 1614844800)                 // 2021-03-04 08:00:00
+		body = append(append(body, vint(comp)...), 1) // compression info and host OS
+		body = append(append(body, vint(len(m.name))...), m.name...)
+		buf = append(buf, block(2, body, m.content)...)
+	}
+	return append(buf, block(5, vint(0), "")...)
+}
+
+func TestRAR(t *testing.T) {
+	if _, err := NewRAR(strings.NewReader("Rar!\x1a\x07\x02\x00"), "test.rar", 8); err != ErrNotRAR {
+		t.Errorf("expecting ErrNotRAR, got %v", err)
+	}
+	for _, v := range [][]byte{rar4Test(rarMembers), rar5Test(rarMembers)} {
+		d, err := NewRAR(bytes.NewReader(v), "test.rar", int64(len(v)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range rarMembers[1:] {
+			if err = d.Next(); err != nil {
+				t.Fatal(err)
+			}
+			if d.Path() != "test.rar#"+filepath.FromSlash(m.name) || d.Size() != int64(len(m.content)) {
+				t.Errorf("expecting %s (%d bytes), got %s (%d bytes)", m.name, len(m.content), d.Path(), d.Size())
+			}
+			if d.Mod().Unix() != 1614844800 {
+				t.Errorf("bad modified time for %s: %v", m.name, d.Mod())
+			}
+			byt, err := io.ReadAll(d.Reader())
+			if m.stored && (err != nil || string(byt) != m.content) {
+				t.Errorf("expecting content %q, got %q (%v)", m.content, byt, err)
+			}
+			if !m.stored && (err == nil || !strings.Contains(err.Error(), "unsupported RAR compression")) {
+				t.Errorf("expecting an unsupported compression error for %s, got %v", m.name, err)
+			}
+		}
+		if err = d.Next(); err != io.EOF {
+			t.Errorf("expecting EOF, got %v", err)
+		}
+	}
+}
+
+func TestBadRAR4(t *testing.T) {
+	// a file header with 64 bit sizes, whose packed size is negative: -(header size), so the next block would be the same one
+	body := make([]byte, 34)
+	binary.LittleEndian.PutUint32(body, uint32(0x100000000-int64(7+len(body))))
+	binary.LittleEndian.PutUint32(body[25:], 0xffffffff)
+	binary.LittleEndian.PutUint16(body[19:], 1)
+	body[33] = 'a'
+	neg := append([]byte(rar4Magic), rar4Block(0x74, 0x8100, body)...)
+	// a block whose data runs beyond the end of the archive
+	body = make([]byte, 26)
+	binary.LittleEndian.PutUint32(body, 1000)
+	binary.LittleEndian.PutUint16(body[19:], 1)
+	body[25] = 'b'
+	long := append([]byte(rar4Magic), rar4Block(0x74, 0x8000, body)...)
+	for _, v := range [][]byte{neg, long} {
+		done := make(chan error, 1)
+		go func() {
+			_, err := NewRAR(bytes.NewReader(v), "bad.rar", int64(len(v)))
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != errBadRAR {
+				t.Errorf("expecting a bad RAR error, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("NewRAR didn't return for a block with a negative size")
+		}
+	}
+}
+
+func TestRAR4Name(t *testing.T) {
+	// "café 中.txt": runs from the ASCII name, a full character, a low byte and the rest of the ASCII name
+	enc := "\x00\xe2\x01\xe9\x00 \x2d\x4e\xc0\x02"
+	if got := rar4Name([]byte("cafe _.txt\x00"+enc), true); got != "café 中.txt" {
+		t.Errorf("bad unicode name, got %q", got)
+	}
+	if got := rar4Name([]byte("plain.txt"), false); got != "plain.txt" {
+		t.Errorf("bad name, got %q", got)
+	}
+}
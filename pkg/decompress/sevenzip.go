@@ -0,0 +1,877 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+const sevenZMagic = "7z\xbc\xaf\x27\x1c"
+
+// ErrNot7z is returned by New7z if content doesn't begin with the 7z signature.
+var ErrNot7z = errors.New("decompress: not a 7z archive")
+
+var errBad7z = errors.New("decompress: bad 7z header")
+
+// maxSzHeader caps the size of a decoded 7z header (a hostile archive can claim any unpack size)
+var maxSzHeader int64 = 64 << 20
+
+// 7z header property IDs
+const (
+	szEnd = iota
+	szHeader
+	szArchiveProperties
+	szAdditionalStreamsInfo
+	szMainStreamsInfo
+	szFilesInfo
+	szPackInfo
+	szUnpackInfo
+	szSubStreamsInfo
+	szSize
+	szCRC
+	szFolder
+	szCodersUnpackSize
+	szNumUnpackStream
+	szEmptyStream
+	szEmptyFile
+	szAnti
+	szName
+	szCTime
+	szATime
+	szMTime
+	szWinAttributes
+	szComment
+	szEncodedHeader
+)
+
+// 7z method IDs
+const (
+	szCopy    = "\x00"
+	szDelta   = "\x03"
+	szBCJ     = "\x03\x03\x01\x03"
+	szLZMA    = "\x03\x01\x01"
+	szLZMA2   = "\x21"
+	szPPMD    = "\x03\x04\x01"
+	szDeflate = "\x04\x01\x08"
+	szBzip2   = "\x04\x02\x02"
+	szAES     = "\x06\xf1\x07\x01"
+)
+
+type szCoder struct {
+	id      string
+	props   []byte
+	in, out int
+}
+
+type szFolderInfo struct {
+	coders []szCoder
+	bonds  [][2]int // pairs of coder in and out stream indexes
+	packed []int    // the in stream indexes that read packed streams
+	sizes  []uint64 // unpacked size of each out stream
+	crc    bool     // the folder's unpacked CRC is given
+	pack   int      // index of the folder's first packed stream
+	subs   []uint64 // sizes of the members stored in the folder
+}
+
+// size is the size of the folder's unbound out stream
+func (f *szFolderInfo) size() uint64 {
+	for i, s := range f.sizes {
+		bound := false
+		for _, b := range f.bonds {
+			if b[1] == i {
+				bound = true
+				break
+			}
+		}
+		if !bound {
+			return s
+		}
+	}
+	return 0
+}
+
+type szStreams struct {
+	packPos   uint64
+	packSizes []uint64
+	folders   []*szFolderInfo
+}
+
+// reader returns the decoded output of a folder. Folders are chains of coders with a single packed stream.
+func (st *szStreams) reader(ra io.ReaderAt, fi int) (io.Reader, error) {
+	f := st.folders[fi]
+	if len(f.packed) != 1 || f.pack >= len(st.packSizes) {
+		return nil, errors.New("decompress: unsupported 7z coder arrangement")
+	}
+	for _, c := range f.coders {
+		if c.in != 1 || c.out != 1 {
+			return nil, fmt.Errorf("decompress: unsupported 7z method %s", szMethod(c.id))
+		}
+	}
+	off := 32 + st.packPos
+	for _, s := range st.packSizes[:f.pack] {
+		off += s
+	}
+	var r io.Reader = bufio.NewReader(io.NewSectionReader(ra, int64(off), int64(st.packSizes[f.pack])))
+	// with simple coders, a coder's in and out stream indexes are the coder's index
+	c := f.packed[0]
+	for i := 0; i < len(f.coders); i++ {
+		if c >= len(f.coders) || c >= len(f.sizes) {
+			return nil, errBad7z
+		}
+		var err error
+		if r, err = szDecoder(f.coders[c], r, f.sizes[c]); err != nil {
+			return nil, err
+		}
+		next := -1
+		for _, b := range f.bonds {
+			if b[1] == c {
+				next = b[0]
+			}
+		}
+		if next < 0 {
+			return io.LimitReader(r, int64(f.sizes[c])), nil
+		}
+		c = next
+	}
+	return nil, errBad7z
+}
+
+func szDecoder(c szCoder, r io.Reader, size uint64) (io.Reader, error) {
+	// there's no need for a dictionary bigger than the output
+	dictCap := func(d uint64) int {
+		if d > size {
+			d = size
+		}
+		if d < lzma.MinDictCap {
+			d = lzma.MinDictCap
+		}
+		return int(d)
+	}
+	switch c.id {
+	case szCopy:
+		return r, nil
+	case szLZMA:
+		if len(c.props) < 5 {
+			return nil, errBad7z
+		}
+		// make the header of the classic LZMA format, an unpacked size follows the 7z coder properties
+		hdr := make([]byte, lzma.HeaderLen)
+		hdr[0] = c.props[0]
+		binary.LittleEndian.PutUint32(hdr[1:], uint32(dictCap(uint64(binary.LittleEndian.Uint32(c.props[1:])))))
+		binary.LittleEndian.PutUint64(hdr[5:], size)
+		return lzma.NewReader(io.MultiReader(bytes.NewReader(hdr), r))
+	case szLZMA2:
+		if len(c.props) < 1 || c.props[0] > 40 {
+			return nil, errBad7z
+		}
+		d := uint64(1<<32 - 1)
+		if c.props[0] < 40 {
+			d = uint64(2|c.props[0]&1) << (c.props[0]/2 + 11)
+		}
+		return lzma.Reader2Config{DictCap: dictCap(d)}.NewReader2(byteReader(r))
+	case szDeflate:
+		return flate.NewReader(r), nil
+	case szBzip2:
+		return bzip2.NewReader(r), nil
+	case szBCJ:
+		return &bcjReader{r: r, buf: make([]byte, 1<<16), prevPos: 1<<32 - 5}, nil
+	case szDelta:
+		dist := 1
+		if len(c.props) > 0 {
+			dist = int(c.props[0]) + 1
+		}
+		return &deltaReader{r: r, dist: dist}, nil
+	case szAES:
+		return nil, errors.New("decompress: encrypted 7z archive")
+	}
+	return nil, fmt.Errorf("decompress: unsupported 7z method %s", szMethod(c.id))
+}
+
+func byteReader(r io.Reader) io.Reader {
+	if _, ok := r.(io.ByteReader); ok {
+		return r
+	}
+	return bufio.NewReader(r)
+}
+
+func szMethod(id string) string {
+	switch id {
+	case szCopy:
+		return "store"
+	case szDelta:
+		return "delta"
+	case szBCJ:
+		return "bcj"
+	case szLZMA:
+		return "lzma"
+	case szLZMA2:
+		return "lzma2"
+	case szPPMD:
+		return "ppmd"
+	case szDeflate:
+		return "deflate"
+	case szBzip2:
+		return "bzip2"
+	case szAES:
+		return "aes"
+	}
+	return fmt.Sprintf("method %x", id)
+}
+
+type szFile struct {
+	name   string
+	mod    time.Time
+	dir    bool
+	anti   bool // anti items mark deletions in update archives
+	stream bool // false for directories and empty files
+	folder int
+	size   uint64
+}
+
+type sevenZD struct {
+	p       string
+	ra      io.ReaderAt
+	st      *szStreams
+	files   []szFile
+	idx     int
+	folder  int       // the folder being read
+	frdr    io.Reader // its output
+	ferr    error     // or the error decoding it
+	rdr     io.Reader
+	written map[string]bool
+}
+
+// New7z returns a Decompressor for 7z archives. Members compressed with copy, LZMA, LZMA2, deflate and bzip2
+// (with the BCJ x86 and delta filters) are supported. Solid archives are decoded in order. Members that use other
+// methods, or are encrypted, are reported with an error when read. Archives with encrypted headers can't be listed.
+func New7z(ra io.ReaderAt, path string, sz int64) (Decompressor, error) {
+	return newSevenZip(ra, path, sz)
+}
+
+func newSevenZip(ra io.ReaderAt, path string, sz int64) (*sevenZD, error) {
+	hdr := make([]byte, 32)
+	if _, err := ra.ReadAt(hdr, 0); err != nil || string(hdr[:6]) != sevenZMagic {
+		return nil, ErrNot7z
+	}
+	z := &sevenZD{p: path, ra: ra, st: &szStreams{}, idx: -1, folder: -1}
+	off, n := binary.LittleEndian.Uint64(hdr[12:]), binary.LittleEndian.Uint64(hdr[20:])
+	if n == 0 {
+		return z, nil // an empty archive
+	}
+	if off > uint64(sz) || n > uint64(sz) || 32+off+n > uint64(sz) {
+		return nil, errBad7z
+	}
+	buf := make([]byte, n)
+	if _, err := ra.ReadAt(buf, int64(32+off)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(buf) != binary.LittleEndian.Uint32(hdr[28:]) {
+		return nil, errBad7z
+	}
+	// headers are usually compressed: the encoded header gives the streams to decode to get the real one.
+	// Only one level of encoding is allowed (an encoded header could otherwise decode to itself forever).
+	if len(buf) > 0 && buf[0] == szEncodedHeader {
+		s := &szBuf{b: buf[1:]}
+		st := s.streams()
+		if s.err != nil {
+			return nil, s.err
+		}
+		if len(st.folders) == 0 {
+			return nil, errBad7z
+		}
+		r, err := st.reader(ra, 0)
+		if err != nil {
+			return nil, err
+		}
+		if buf, err = io.ReadAll(io.LimitReader(r, maxSzHeader+1)); err != nil {
+			return nil, err
+		}
+		if int64(len(buf)) > maxSzHeader {
+			return nil, errBad7z
+		}
+	}
+	if len(buf) == 0 || buf[0] != szHeader {
+		return nil, errBad7z
+	}
+	s := &szBuf{b: buf[1:]}
+	id := s.byte()
+	if id == szArchiveProperties {
+		for t := s.byte(); t != szEnd && s.err == nil; t = s.byte() {
+			s.bytes(s.number())
+		}
+		id = s.byte()
+	}
+	if id == szAdditionalStreamsInfo {
+		s.streams()
+		id = s.byte()
+	}
+	if id == szMainStreamsInfo {
+		z.st = s.streams()
+		id = s.byte()
+	}
+	if id == szFilesInfo {
+		z.files = s.filesInfo()
+		id = s.byte()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	if id != szEnd {
+		return nil, errBad7z
+	}
+	// assign members with content to the folders' streams, in order
+	fi, si := 0, 0
+	for i := range z.files {
+		if !z.files[i].stream {
+			continue
+		}
+		for fi < len(z.st.folders) && si >= len(z.st.folders[fi].subs) {
+			fi, si = fi+1, 0
+		}
+		if fi >= len(z.st.folders) {
+			return nil, errBad7z
+		}
+		z.files[i].folder, z.files[i].size = fi, z.st.folders[fi].subs[si]
+		si++
+	}
+	return z, nil
+}
+
+func (z *sevenZD) Next() error {
+	// the remainder of the previous member has to be read to get to the next one in the folder
+	if z.rdr != nil && z.ferr == nil {
+		if _, err := io.Copy(io.Discard, z.rdr); err != nil {
+			z.ferr = err
+		}
+	}
+	z.rdr = nil
+	// scan past directories
+	for z.idx++; z.idx < len(z.files) && (z.files[z.idx].dir || z.files[z.idx].anti); z.idx++ {
+	}
+	if z.idx >= len(z.files) {
+		return io.EOF
+	}
+	f := z.files[z.idx]
+	if !f.stream {
+		z.rdr = bytes.NewReader(nil)
+		return nil
+	}
+	if f.folder != z.folder {
+		z.folder = f.folder
+		z.frdr, z.ferr = z.st.reader(z.ra, f.folder)
+	}
+	if z.ferr != nil {
+		z.rdr = errReader{z.ferr}
+		return nil
+	}
+	z.rdr = io.LimitReader(z.frdr, int64(f.size))
+	return nil
+}
+
+// errReader reports the error decoding a member's folder
+type errReader struct{ err error }
+
+func (e errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
+
+func (z *sevenZD) Reader() io.Reader {
+	return z.rdr
+}
+
+func (z *sevenZD) Path() string {
+	return Arcpath(z.p, filepath.FromSlash(z.files[z.idx].name))
+}
+
+func (z *sevenZD) MIME() string {
+	return ""
+}
+
+func (z *sevenZD) Size() int64 {
+	return int64(z.files[z.idx].size)
+}
+
+func (z *sevenZD) Mod() time.Time {
+	return z.files[z.idx].mod
+}
+
+func (z *sevenZD) Dirs() []string {
+	if z.written == nil {
+		z.written = make(map[string]bool)
+	}
+	return dirs(z.p, z.files[z.idx].name, z.written)
+}
+
+// szBuf parses 7z headers. The first error is kept and later reads return zero values.
+type szBuf struct {
+	b   []byte
+	err error
+}
+
+func (s *szBuf) bad() {
+	if s.err == nil {
+		s.err = errBad7z
+	}
+}
+
+func (s *szBuf) byte() byte {
+	if len(s.b) == 0 {
+		s.bad()
+		return 0
+	}
+	c := s.b[0]
+	s.b = s.b[1:]
+	return c
+}
+
+func (s *szBuf) bytes(n uint64) []byte {
+	if n > uint64(len(s.b)) {
+		s.bad()
+		s.b = nil
+		return nil
+	}
+	b := s.b[:n]
+	s.b = s.b[n:]
+	return b
+}
+
+// number reads a 7z variable length number: the count of leading one bits in the first byte gives the number of
+// bytes that follow, the remaining bits are the high bits of the number
+func (s *szBuf) number() uint64 {
+	first := s.byte()
+	var v uint64
+	for i := 0; i < 8; i++ {
+		mask := byte(0x80) >> uint(i)
+		if first&mask == 0 {
+			return v | uint64(first&(mask-1))<<(8*uint(i))
+		}
+		v |= uint64(s.byte()) << (8 * uint(i))
+	}
+	return v
+}
+
+// count reads a number of items, each item needs at least a bit of the header
+func (s *szBuf) count() int {
+	n := s.number()
+	if n > uint64(len(s.b))*8+1 {
+		s.bad()
+		return 0
+	}
+	return int(n)
+}
+
+func (s *szBuf) bits(n int) []bool {
+	v := make([]bool, n)
+	var c byte
+	for i := range v {
+		if i%8 == 0 {
+			c = s.byte()
+		}
+		v[i] = c&(0x80>>uint(i%8)) != 0
+	}
+	return v
+}
+
+// defined reads a bit field of n items, preceded by a flag that all are defined
+func (s *szBuf) defined(n int) []bool {
+	if s.byte() == 0 {
+		return s.bits(n)
+	}
+	v := make([]bool, n)
+	for i := range v {
+		v[i] = true
+	}
+	return v
+}
+
+func (s *szBuf) digests(n int) []bool {
+	d := s.defined(n)
+	for _, ok := range d {
+		if ok {
+			s.bytes(4)
+		}
+	}
+	return d
+}
+
+func (s *szBuf) streams() *szStreams {
+	st := &szStreams{}
+	id := s.byte()
+	if id == szPackInfo {
+		st.packPos = s.number()
+		n := s.count()
+		for id = s.byte(); id != szEnd && s.err == nil; id = s.byte() {
+			switch id {
+			case szSize:
+				st.packSizes = make([]uint64, n)
+				for i := range st.packSizes {
+					st.packSizes[i] = s.number()
+				}
+			case szCRC:
+				s.digests(n)
+			default:
+				s.bad()
+			}
+		}
+		id = s.byte()
+	}
+	if id == szUnpackInfo {
+		if s.byte() != szFolder {
+			s.bad()
+		}
+		n := s.count()
+		if s.byte() != 0 { // external folders aren't used by 7-Zip
+			s.bad()
+		}
+		var pack int
+		st.folders = make([]*szFolderInfo, n)
+		for i := range st.folders {
+			st.folders[i] = s.folder()
+			st.folders[i].pack = pack
+			pack += len(st.folders[i].packed)
+		}
+		if s.byte() != szCodersUnpackSize {
+			s.bad()
+		}
+		for _, f := range st.folders {
+			for i := range f.sizes {
+				f.sizes[i] = s.number()
+			}
+		}
+		id = s.byte()
+		if id == szCRC {
+			for i, ok := range s.digests(n) {
+				st.folders[i].crc = ok
+			}
+			id = s.byte()
+		}
+		if id != szEnd {
+			s.bad()
+		}
+		id = s.byte()
+	}
+	// by default, each folder holds a single stream
+	for _, f := range st.folders {
+		f.subs = []uint64{f.size()}
+	}
+	if id == szSubStreamsInfo {
+		id = s.byte()
+		if id == szNumUnpackStream {
+			for _, f := range st.folders {
+				f.subs = make([]uint64, s.count())
+			}
+			id = s.byte()
+		}
+		// sizes are given for all but the last stream in a folder, which has the remainder
+		sizes := id == szSize
+		for _, f := range st.folders {
+			if len(f.subs) == 0 {
+				continue
+			}
+			var sum uint64
+			for i := 0; sizes && i < len(f.subs)-1; i++ {
+				f.subs[i] = s.number()
+				sum += f.subs[i]
+			}
+			if sum > f.size() {
+				s.bad()
+				break
+			}
+			f.subs[len(f.subs)-1] = f.size() - sum
+		}
+		if sizes {
+			id = s.byte()
+		}
+		if id == szCRC {
+			var n int
+			for _, f := range st.folders {
+				if len(f.subs) != 1 || !f.crc {
+					n += len(f.subs)
+				}
+			}
+			s.digests(n)
+			id = s.byte()
+		}
+		if id != szEnd {
+			s.bad()
+		}
+		id = s.byte()
+	}
+	if id != szEnd {
+		s.bad()
+	}
+	return st
+}
+
+func (s *szBuf) folder() *szFolderInfo {
+	f := &szFolderInfo{}
+	n := s.count()
+	var ins, outs int
+	for i := 0; i < n && s.err == nil; i++ {
+		flag := s.byte()
+		c := szCoder{id: string(s.bytes(uint64(flag & 0x0f))), in: 1, out: 1}
+		if flag&0x10 != 0 {
+			c.in, c.out = s.count(), s.count()
+		}
+		if flag&0x20 != 0 {
+			c.props = s.bytes(s.number())
+		}
+		if flag&0x80 != 0 { // alternative methods aren't used by 7-Zip
+			s.bad()
+		}
+		f.coders = append(f.coders, c)
+		ins, outs = ins+c.in, outs+c.out
+	}
+	if outs == 0 || ins < outs-1 {
+		s.bad()
+		return f
+	}
+	f.bonds = make([][2]int, outs-1)
+	for i := range f.bonds {
+		f.bonds[i] = [2]int{int(s.number()), int(s.number())}
+	}
+	if ins-len(f.bonds) == 1 {
+		// the packed stream is the in stream that isn't bound
+		for i := 0; i < ins; i++ {
+			bound := false
+			for _, b := range f.bonds {
+				if b[0] == i {
+					bound = true
+				}
+			}
+			if !bound {
+				f.packed = []int{i}
+				break
+			}
+		}
+	} else {
+		f.packed = make([]int, ins-len(f.bonds))
+		for i := range f.packed {
+			f.packed[i] = int(s.number())
+		}
+	}
+	f.sizes = make([]uint64, outs)
+	return f
+}
+
+func (s *szBuf) filesInfo() []szFile {
+	files := make([]szFile, s.count())
+	for i := range files {
+		files[i].stream = true
+	}
+	var empty []int // indexes of the files without streams
+	for t := s.byte(); t != szEnd && s.err == nil; t = s.byte() {
+		p := &szBuf{b: s.bytes(s.number())}
+		switch t {
+		case szEmptyStream:
+			empty = empty[:0]
+			for i, ok := range p.bits(len(files)) {
+				files[i].stream = !ok
+				files[i].dir = ok // unless it is an empty file
+				if ok {
+					empty = append(empty, i)
+				}
+			}
+		case szEmptyFile:
+			for i, ok := range p.bits(len(empty)) {
+				if ok {
+					files[empty[i]].dir = false
+				}
+			}
+		case szAnti:
+			for i, ok := range p.bits(len(empty)) {
+				files[empty[i]].anti = ok
+			}
+		case szName:
+			if p.byte() != 0 {
+				p.bad()
+			}
+			for i := range files {
+				var u []uint16
+				for c := p.bytes(2); len(c) == 2 && (c[0] != 0 || c[1] != 0); c = p.bytes(2) {
+					u = append(u, binary.LittleEndian.Uint16(c))
+				}
+				// names made on Windows may use backslashes
+				files[i].name = strings.ReplaceAll(string(utf16.Decode(u)), "\\", "/")
+			}
+		case szMTime:
+			d := p.defined(len(files))
+			if p.byte() != 0 {
+				p.bad()
+			}
+			for i, ok := range d {
+				if ok {
+					files[i].mod = filetime(p.bytes(8))
+				}
+			}
+		case szWinAttributes:
+			d := p.defined(len(files))
+			if p.byte() != 0 {
+				p.bad()
+			}
+			for i, ok := range d {
+				if ok {
+					if b := p.bytes(4); len(b) == 4 && b[0]&0x10 != 0 { // FILE_ATTRIBUTE_DIRECTORY
+						files[i].dir = true
+					}
+				}
+			}
+		}
+		if p.err != nil {
+			s.bad()
+		}
+	}
+	return files
+}
+
+// filetime converts a Windows FILETIME: 100 nanosecond intervals since 1601
+func filetime(b []byte) time.Time {
+	if len(b) != 8 {
+		return time.Time{}
+	}
+	ft := binary.LittleEndian.Uint64(b)
+	return time.Unix(int64(ft/1e7)-11644473600, int64(ft%1e7)*100)
+}
+
+// bcjReader reverses the BCJ x86 filter, which converts the relative addresses of call and jump instructions
+// in x86 code to absolute addresses, to make executables more compressible. This follows the xz implementation.
+type bcjReader struct {
+	r                 io.Reader
+	buf               []byte
+	off, conv, n      int    // converted bytes in buf are read from off to conv, unconverted bytes run to n
+	pos               uint32 // stream position of buf[0]
+	prevMask, prevPos uint32
+	eof               bool
+}
+
+func (b *bcjReader) Read(p []byte) (int, error) {
+	for b.off == b.conv {
+		copy(b.buf, b.buf[b.conv:b.n])
+		b.pos += uint32(b.conv)
+		b.off, b.n, b.conv = 0, b.n-b.conv, 0
+		if b.eof {
+			if b.n == 0 {
+				return 0, io.EOF
+			}
+			b.conv = b.n // the last few bytes are left as they are
+			break
+		}
+		m, err := b.r.Read(b.buf[b.n:])
+		b.n += m
+		if err == io.EOF {
+			b.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		b.conv = b.x86(b.buf[:b.n])
+	}
+	n := copy(p, b.buf[b.off:b.conv])
+	b.off += n
+	return n, nil
+}
+
+func test86(c byte) bool {
+	return c == 0 || c == 0xff
+}
+
+func (b *bcjReader) x86(buf []byte) int {
+	allowed := [8]bool{true, true, true, false, true, false, false, false}
+	bitNumber := [8]uint32{0, 1, 2, 2, 3, 3, 3, 3}
+	if len(buf) < 5 {
+		return 0
+	}
+	if b.pos-b.prevPos > 5 {
+		b.prevPos = b.pos - 5
+	}
+	i := 0
+	for i <= len(buf)-5 {
+		if buf[i] != 0xe8 && buf[i] != 0xe9 {
+			i++
+			continue
+		}
+		offset := b.pos + uint32(i) - b.prevPos
+		b.prevPos = b.pos + uint32(i)
+		if offset > 5 {
+			b.prevMask = 0
+		} else {
+			for j := uint32(0); j < offset; j++ {
+				b.prevMask &= 0x77
+				b.prevMask <<= 1
+			}
+		}
+		c := buf[i+4]
+		if test86(c) && allowed[(b.prevMask>>1)&7] && b.prevMask>>1 < 0x10 {
+			src := binary.LittleEndian.Uint32(buf[i+1:])
+			var dest uint32
+			for {
+				dest = src - (b.pos + uint32(i) + 5)
+				if b.prevMask == 0 {
+					break
+				}
+				j := bitNumber[b.prevMask>>1]
+				if !test86(byte(dest >> (24 - j*8))) {
+					break
+				}
+				src = dest ^ (1<<(32-j*8) - 1)
+			}
+			dest &= 0x01ffffff
+			if dest&0x01000000 != 0 {
+				dest |= 0xff000000
+			}
+			binary.LittleEndian.PutUint32(buf[i+1:], dest)
+			i += 5
+			b.prevMask = 0
+		} else {
+			i++
+			b.prevMask |= 1
+			if test86(c) {
+				b.prevMask |= 0x10
+			}
+		}
+	}
+	return i
+}
+
+// deltaReader reverses the delta filter: each byte is stored as the difference from the byte dist bytes before it
+type deltaReader struct {
+	r    io.Reader
+	dist int
+	hist [256]byte
+	pos  byte
+}
+
+func (d *deltaReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	for i := range p[:n] {
+		p[i] += d.hist[byte(d.dist+int(d.pos))]
+		d.hist[d.pos] = p[i]
+		d.pos--
+	}
+	return n, err
+}
@@ -0,0 +1,100 @@
+package decompress
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a solid LZMA2 archive with a compressed header: a.txt, d/b.txt and the directory d
+const small7z = "7z\xbc\xaf'\x1c\x00\x04\xfb\xa7\xde/`\x00\x00\x00\x00\x00\x00\x00\x14\x00\x00\x00\x00\x00\x00\x00\xc9A\xdaA\x01\x00\x0bhello\x0aworld\x0a\x00\xe0\x00c\x00H]\x00\x00\x813\x07\xae\x0f\xce\xf2\xb2\x0c\x07\xc8C\x7fA\x88\x9a\x9b\xc6\xc0\xbc\xe3\xdaZ\xbf5\xdab\x8d,G\x84A\xb2vD\xef\xcc\x81^N\xbb\x96\x9b,\x85\xc3{^D|\xf4\x11\xddK\xf2O\x16Z\xd94\x90\x8e\x18\x9f\x0b\x93\xae\xc0k\x8d\x00\x00\x17\x06\x10\x01\x09P\x00\x07\x0b\x01\x00\x01!!\x01\x10\x0cd\x00\x00"
+
+func TestNumber(t *testing.T) {
+	for _, v := range []struct {
+		in     string
+		expect uint64
+	}{
+		{"\x7f", 0x7f},
+		{"\x80\x80", 0x80},
+		{"\xbf\xff", 0x3fff},
+		{"\xc0\x00\x40", 0x4000},
+		{"\xe1\x02\x03\x04", 0x01040302},
+		{"\xff\x01\x02\x03\x04\x05\x06\x07\x08", 0x0807060504030201},
+	} {
+		s := &szBuf{b: []byte(v.in)}
+		if got := s.number(); got != v.expect || s.err != nil || len(s.b) != 0 {
+			t.Errorf("number %x: expecting %x, got %x (%v)", v.in, v.expect, got, s.err)
+		}
+	}
+}
+
+func TestSevenZip(t *testing.T) {
+	if _, err := New7z(strings.NewReader("PK\x03\x04"+strings.Repeat("\x00", 28)), "test.zip", 32); err != ErrNot7z {
+		t.Errorf("expecting ErrNot7z, got %v", err)
+	}
+	d, err := New7z(strings.NewReader(small7z), "test.7z", int64(len(small7z)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct{ name, content, dirs string }{
+		{"a.txt", "hello\n", ""},
+		{filepath.Join("d", "b.txt"), "world\n", filepath.Join("test.7z", "d")},
+	}
+	for _, e := range expect {
+		if err = d.Next(); err != nil {
+			t.Fatal(err)
+		}
+		if d.Path() != "test.7z#"+e.name || d.Size() != int64(len(e.content)) || strings.Join(d.Dirs(), ",") != e.dirs {
+			t.Errorf("expecting %s (%d bytes, dirs %q), got %s (%d bytes, dirs %q)", e.name, len(e.content), e.dirs, d.Path(), d.Size(), d.Dirs())
+		}
+		if d.Mod().Year() != 2022 {
+			t.Errorf("bad modified time for %s: %v", e.name, d.Mod())
+		}
+		if byt, _ := io.ReadAll(d.Reader()); string(byt) != e.content {
+			t.Errorf("expecting content %q, got %q", e.content, byt)
+		}
+	}
+	if err = d.Next(); err != io.EOF {
+		t.Errorf("expecting EOF, got %v", err)
+	}
+}
+
+// hostile7z returns an archive whose header is an encoded header, packed with the copy coder, that decodes to itself
+func hostile7z() string {
+	n := byte(18)
+	hdr := string([]byte{szEncodedHeader, szPackInfo, 0, 1, szSize, n, szEnd, szUnpackInfo, szFolder, 1, 0, 1, 1, 0, szCodersUnpackSize, n, szEnd, szEnd})
+	start := make([]byte, 32)
+	copy(start, sevenZMagic)
+	start[7] = 4
+	binary.LittleEndian.PutUint64(start[20:], uint64(len(hdr)))
+	binary.LittleEndian.PutUint32(start[28:], crc32.ChecksumIEEE([]byte(hdr)))
+	binary.LittleEndian.PutUint32(start[8:], crc32.ChecksumIEEE(start[12:]))
+	return string(start) + hdr
+}
+
+func TestHostile7z(t *testing.T) {
+	h := hostile7z()
+	done := make(chan error, 1)
+	go func() {
+		_, err := New7z(strings.NewReader(h), "loop.7z", int64(len(h)))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != errBad7z {
+			t.Errorf("expecting a bad header error for an encoded header that decodes to itself, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("New7z didn't return for an encoded header that decodes to itself")
+	}
+	// the decoded header is capped
+	defer func(m int64) { maxSzHeader = m }(maxSzHeader)
+	maxSzHeader = 8
+	if _, err := New7z(strings.NewReader(h), "big.7z", int64(len(h))); err != errBad7z {
+		t.Errorf("expecting a bad header error for a decoded header over the limit, got %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"compress/bzip2"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/internal/zstd"
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// streamD decompresses a single compressed stream (bzip2, xz or zstd). Like gzip, the stream has a single member:
+// a compressed tarball is decompressed, and the tar is then scanned in turn.
+type streamD struct {
+	p    string
+	name string
+	sz   int64
+	read bool
+	rdr  io.Reader
+	// for Rewind
+	buf *siegreader.Buffer
+	fn  func(r io.ReaderAt, sz int64) (io.Reader, int64, error)
+}
+
+// compressed stream extensions, and whether they are an abbreviation for a compressed tarball
+var streamExts = map[string]bool{
+	".bz2":   false,
+	".bz":    false,
+	".bzip2": false,
+	".tbz2":  true,
+	".tbz":   true,
+	".tb2":   true,
+	".xz":    false,
+	".txz":   true,
+	".zst":   false,
+	".zstd":  false,
+	".tzst":  true,
+}
+
+func newStream(b *siegreader.Buffer, path string, fn func(r io.ReaderAt, sz int64) (io.Reader, int64, error)) (Decompressor, error) {
+	b.Quit = make(chan struct{}) // in case a stream with a closed quit channel, make a new one
+	sz := streamSize(b)
+	rdr, usz, err := fn(siegreader.ReaderFrom(b), sz)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	if tar, ok := streamExts[strings.ToLower(filepath.Ext(path))]; ok {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		if tar {
+			name += ".tar"
+		}
+	}
+	return &streamD{p: path, name: name, sz: usz, rdr: rdr, buf: b, fn: fn}, nil
+}
+
+func (s *streamD) Rewind() (io.Reader, error) {
+	if s.buf.Truncated() {
+		return nil, ErrTruncated
+	}
+	rdr, _, err := s.fn(siegreader.ReaderFrom(s.buf), streamSize(s.buf))
+	return rdr, err
+}
+
+// streamSize forces a full read of a stream to learn its size. If temp files are disabled, a big stream wouldn't keep
+// all its bytes, so the size is reported as -1 (unknown) and the stream is decompressed as it is read.
+func streamSize(b *siegreader.Buffer) int64 {
+	if b.Stream() && config.NoTemp() {
+		return -1
+	}
+	return b.SizeNow()
+}
+
+// whole reads a compressed stream from its start
+func whole(r io.ReaderAt, sz int64) io.Reader {
+	if sz < 0 {
+		return &seqReader{r: r}
+	}
+	return io.NewSectionReader(r, 0, sz)
+}
+
+// seqReader reads sequentially from a ReaderAt of unknown size
+type seqReader struct {
+	r   io.ReaderAt
+	off int64
+}
+
+func (s *seqReader) Read(p []byte) (int, error) {
+	n, err := s.r.ReadAt(p, s.off)
+	s.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// bzip2 doesn't record the uncompressed size, so it is reported as 0
+func newBzip2(b *siegreader.Buffer, path string) (Decompressor, error) {
+	return newStream(b, path, func(r io.ReaderAt, sz int64) (io.Reader, int64, error) {
+		return bzip2.NewReader(whole(r, sz)), 0, nil
+	})
+}
+
+func newXZ(b *siegreader.Buffer, path string) (Decompressor, error) {
+	return newStream(b, path, func(r io.ReaderAt, sz int64) (io.Reader, int64, error) {
+		rdr, err := xz.NewReader(whole(r, sz))
+		return rdr, xzSize(r, sz), err
+	})
+}
+
+func newZstd(b *siegreader.Buffer, path string) (Decompressor, error) {
+	return newStream(b, path, func(r io.ReaderAt, sz int64) (io.Reader, int64, error) {
+		return zstd.NewReader(whole(r, sz)), zstdSize(r, sz), nil
+	})
+}
+
+func (s *streamD) Next() error {
+	if s.read {
+		return io.EOF
+	}
+	s.read = true
+	return nil
+}
+
+func (s *streamD) Reader() io.Reader {
+	return s.rdr
+}
+
+func (s *streamD) Path() string {
+	return Arcpath(s.p, s.name)
+}
+
+func (s *streamD) MIME() string {
+	return ""
+}
+
+func (s *streamD) Size() int64 {
+	return s.sz
+}
+
+func (s *streamD) Mod() time.Time {
+	return time.Time{}
+}
+
+func (s *streamD) Dirs() []string {
+	return nil
+}
+
+// xzSize totals the uncompressed sizes in the indexes of the streams in an xz file, reading backwards from the last stream's footer.
+// It returns 0 if the file can't be read this way.
+func xzSize(r io.ReaderAt, sz int64) int64 {
+	var total int64
+	buf := make([]byte, 12)
+	for pos := sz; pos > 0; {
+		if _, err := r.ReadAt(buf[:4], pos-4); err != nil {
+			return 0
+		}
+		if binary.LittleEndian.Uint32(buf) == 0 { // stream padding
+			pos -= 4
+			continue
+		}
+		// stream footer: CRC32, backward size, stream flags and the magic bytes "YZ"
+		if pos < 24 {
+			return 0
+		}
+		if _, err := r.ReadAt(buf, pos-12); err != nil || string(buf[10:]) != "YZ" {
+			return 0
+		}
+		isz := (int64(binary.LittleEndian.Uint32(buf[4:])) + 1) * 4
+		if isz > pos-24 || isz > 1<<24 {
+			return 0
+		}
+		idx := make([]byte, isz)
+		if _, err := r.ReadAt(idx, pos-12-isz); err != nil || idx[0] != 0 {
+			return 0
+		}
+		p := &rarBuf{b: idx[1:]} // xz's multibyte integers are the same as RAR 5's
+		var blocks int64
+		for n := p.vint(); n > 0 && p.err == nil; n-- {
+			unpadded, usz := p.vint(), p.vint()
+			blocks += int64(unpadded+3) &^ 3
+			total += int64(usz)
+		}
+		if p.err != nil {
+			return 0
+		}
+		pos -= 12 + isz + blocks + 12
+		if pos < 0 {
+			return 0
+		}
+		if _, err := r.ReadAt(buf[:6], pos); err != nil || string(buf[:6]) != "\xfd7zXZ\x00" {
+			return 0
+		}
+	}
+	return total
+}
+
+// zstdSize totals the content sizes given in the headers of the frames in a zstd file, skipping over each frame's blocks.
+// It returns 0 if a frame doesn't give its content size or the file can't be read this way.
+func zstdSize(r io.ReaderAt, sz int64) int64 {
+	var total int64
+	buf := make([]byte, 14)
+	for pos := int64(0); pos < sz; {
+		n, _ := r.ReadAt(buf, pos)
+		if n < 8 {
+			return 0
+		}
+		magic := binary.LittleEndian.Uint32(buf)
+		if magic&0xfffffff0 == 0x184d2a50 { // skippable frame
+			pos += 8 + int64(binary.LittleEndian.Uint32(buf[4:]))
+			continue
+		}
+		if magic != 0xfd2fb528 {
+			return 0
+		}
+		desc := buf[4]
+		fcsSize := []int{0, 2, 4, 8}[desc>>6]
+		single := desc&0x20 != 0
+		if fcsSize == 0 && single {
+			fcsSize = 1
+		}
+		if fcsSize == 0 {
+			return 0 // no content size
+		}
+		off := 5 + []int{0, 1, 2, 4}[desc&0x03]
+		if !single {
+			off++ // window descriptor
+		}
+		if off+fcsSize > n {
+			return 0
+		}
+		var fcs uint64
+		for i := fcsSize - 1; i >= 0; i-- {
+			fcs = fcs<<8 | uint64(buf[off+i])
+		}
+		if fcsSize == 2 {
+			fcs += 256
+		}
+		total += int64(fcs)
+		pos += int64(off + fcsSize)
+		// blocks: a three byte header gives the last block flag, the type and the size
+		for {
+			if n, _ := r.ReadAt(buf[:3], pos); n < 3 {
+				return 0
+			}
+			hdr := uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16
+			bsz := int64(hdr >> 3)
+			if hdr>>1&3 == 1 { // RLE blocks are a single byte
+				bsz = 1
+			}
+			pos += 3 + bsz
+			if hdr&1 == 1 {
+				break
+			}
+		}
+		if desc&0x04 != 0 {
+			pos += 4 // content checksum
+		}
+	}
+	return total
+}
@@ -0,0 +1,55 @@
+package decompress
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// "hello world\n" compressed with the bzip2 and xz command line tools, and "hello, world\n" with zstd
+const (
+	helloBz2  = "BZh91AY&SYN\xec\xe86\x00\x00\x02Q\x80\x00\x10@\x00\x06D\x90\x80 \x001\x06LA\x01\xa7\xa9\xa5\x80\xbb\x941\xf8\xbb\x92)\xc2\x84\x82wgA\xb0"
+	helloXZ   = "\xfd7zXZ\x00\x00\x04\xe6\xd6\xb4F\x02\x00!\x01\x16\x00\x00\x00t/\xe5\xa3\x01\x00\x0bhello world\x0a\x00\xa1\xf2\xff\xc4j\x7f\xbf\xcf\x00\x01$\x0c\xa6\x18\xd8\xd8\x1f\xb6\xf3}\x01\x00\x00\x00\x00\x04YZ"
+	helloZstd = "\x28\xb5\x2f\xfd\x24\x0d\x69\x00\x00hello, world\x0a\x4c\x1f\xf9\xf1"
+)
+
+func TestStream(t *testing.T) {
+	bufs := siegreader.New()
+	for _, v := range []struct {
+		arc           config.Archive
+		path, in      string
+		name, content string
+		size          int64
+	}{
+		{config.Bzip2, "hello.txt.bz2", helloBz2, "hello.txt", "hello world\n", 0},
+		{config.XZ, "hello.txz", helloXZ, "hello.tar", "hello world\n", 12},
+		{config.XZ, "hello.xz", helloXZ + "\x00\x00\x00\x00" + helloXZ, "hello", "hello world\nhello world\n", 24},
+		{config.Zstd, "hello.zst", helloZstd + "\x50\x2a\x4d\x18\x02\x00\x00\x00ab" + helloZstd, "hello", "hello, world\nhello, world\n", 26},
+		{config.Zstd, "hello", helloZstd, "hello", "hello, world\n", 13},
+	} {
+		buf, err := bufs.Get(strings.NewReader(v.in))
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		d, err := New(v.arc, buf, v.path, int64(len(v.in)))
+		if err != nil {
+			t.Fatalf("%s: %v", v.path, err)
+		}
+		if err = d.Next(); err != nil {
+			t.Fatal(err)
+		}
+		if d.Path() != v.path+"#"+v.name || d.Size() != v.size {
+			t.Errorf("%s: expecting %s (%d bytes), got %s (%d bytes)", v.path, v.name, v.size, d.Path(), d.Size())
+		}
+		if byt, err := io.ReadAll(d.Reader()); string(byt) != v.content {
+			t.Errorf("%s: expecting content %q, got %q (%v)", v.path, v.content, byt, err)
+		}
+		if err = d.Next(); err != io.EOF {
+			t.Errorf("%s: expecting EOF, got %v", v.path, err)
+		}
+		bufs.Put(buf)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decompress
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/siegfried/internal/siegreader"
+	"github.com/richardlehane/siegfried/pkg/config"
+)
+
+// SummaryFields are the names of the fields reported for an archive summary.
+var SummaryFields = []string{"members", "compressed", "uncompressed", "methods"}
+
+// Summary describes the members of an archive without identifying them.
+type Summary struct {
+	Members      int      // number of members (excluding directories)
+	Compressed   int64    // total compressed size of the members
+	Uncompressed int64    // total uncompressed size of the members
+	Methods      []string // compression methods used by the members
+}
+
+// Fields returns the summary as a slice of strings in the order of SummaryFields.
+func (s Summary) Fields() []string {
+	return []string{
+		strconv.Itoa(s.Members),
+		strconv.FormatInt(s.Compressed, 10),
+		strconv.FormatInt(s.Uncompressed, 10),
+		strings.Join(s.Methods, ", "),
+	}
+}
+
+func (s *Summary) addMethod(m string) {
+	for _, v := range s.Methods {
+		if v == m {
+			return
+		}
+	}
+	s.Methods = append(s.Methods, m)
+	sort.Strings(s.Methods)
+}
+
+// Summarise enumerates the members of an archive and reports their count, sizes and compression methods.
+// Unlike New, the members are not read so summarising is cheap.
+func Summarise(arc config.Archive, buf *siegreader.Buffer, path string, sz int64) (Summary, error) {
+	var s Summary
+	switch arc {
+	case config.Zip:
+		zr, err := zip.NewReader(siegreader.ReaderFrom(buf), sz)
+		if err != nil {
+			return s, err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			s.Members++
+			s.Compressed += int64(f.CompressedSize64)
+			s.Uncompressed += int64(f.UncompressedSize64)
+			s.addMethod(zipMethod(f.Method))
+		}
+		return s, nil
+	case config.Gzip:
+		d, err := newGzip(buf, path)
+		if err != nil {
+			return s, err
+		}
+		s.Members, s.Compressed, s.Uncompressed = 1, buf.SizeNow(), d.Size()
+		s.addMethod("deflate")
+		return s, nil
+	case config.Bzip2, config.XZ, config.Zstd:
+		d, err := New(arc, buf, path, sz)
+		if err != nil {
+			return s, err
+		}
+		s.Members, s.Compressed, s.Uncompressed = 1, buf.SizeNow(), d.Size()
+		s.addMethod(arc.String())
+		return s, nil
+	case config.SevenZip:
+		z, err := newSevenZip(siegreader.ReaderFrom(buf), path, sz)
+		if err != nil {
+			return s, err
+		}
+		for _, f := range z.files {
+			if f.dir || f.anti {
+				continue
+			}
+			s.Members++
+			s.Uncompressed += int64(f.size)
+		}
+		for _, p := range z.st.packSizes {
+			s.Compressed += int64(p)
+		}
+		for _, f := range z.st.folders {
+			for _, c := range f.coders {
+				s.addMethod(szMethod(c.id))
+			}
+		}
+		return s, nil
+	case config.RAR:
+		r, err := newRAR(siegreader.ReaderFrom(buf), path, sz)
+		if err != nil {
+			return s, err
+		}
+		for _, f := range r.files {
+			if f.dir {
+				continue
+			}
+			s.Members++
+			s.Compressed += f.pack
+			s.Uncompressed += f.size
+			s.addMethod(f.method)
+		}
+		return s, nil
+	}
+	// for tar, disc images and the web archive and email formats, members are stored rather than compressed
+	d, err := New(arc, buf, path, sz)
+	if err != nil {
+		return s, err
+	}
+	for err = d.Next(); err == nil; err = d.Next() {
+		s.Members++
+		s.Compressed += d.Size()
+		s.Uncompressed += d.Size()
+		s.addMethod("store")
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return s, err
+}
+
+func zipMethod(m uint16) string {
+	switch m {
+	case zip.Store:
+		return "store"
+	case zip.Deflate:
+		return "deflate"
+	case 9:
+		return "deflate64"
+	case 12:
+		return "bzip2"
+	case 14:
+		return "lzma"
+	case 93:
+		return "zstd"
+	case 95:
+		return "xz"
+	case 98:
+		return "ppmd"
+	case 99:
+		return "aes"
+	}
+	return fmt.Sprintf("method %d", m)
+}
@@ -103,6 +103,7 @@ type Recorder struct {
 	*Identifier
 	ids        pids
 	cscore     int
+	xml        int // score for XML matches
 	satisfied  bool
 	extActive  bool
 	mimeActive bool
@@ -179,6 +180,17 @@ func (r *Recorder) Record(m core.MatcherType, res core.Result) bool {
 		} else {
 			return false
 		}
+	case core.XMLMatcher:
+		if hit, id := r.Hit(m, res.Index()); hit {
+			// XML matches refine any byte matches (e.g. a namespace within generic XML) so they are scored higher and share a score
+			if r.xml == 0 {
+				r.cscore += incScore
+				r.xml = r.cscore
+			}
+			r.ids = add(r.ids, r.Name(), id, r.infos[id], res.Basis(), r.xml)
+			return true
+		}
+		return false
 	case core.ByteMatcher:
 		if hit, id := r.Hit(m, res.Index()); hit {
 			if r.satisfied {
@@ -210,6 +222,10 @@ func (r *Recorder) Satisfied(mt core.MatcherType) (bool, core.Hint) {
 			return false, core.Hint{}
 		}
 	}
+	// run XML signatures, if there are any, even after a byte match
+	if mt == core.XMLMatcher && r.Identifier.Active(core.XMLMatcher) {
+		return false, core.Hint{}
+	}
 	r.satisfied = true
 	if mt == core.ByteMatcher {
 		return true, core.Hint{r.Start(mt), nil}
@@ -241,6 +257,8 @@ func lowConfidence(conf int) string {
 }
 
 func (r *Recorder) Report() []core.Identification {
+	// restore the recorded ids (which are pruned below), as a provisional report may be followed by more results
+	defer func(ids []Identification) { r.ids = ids }(r.ids)
 	// no results
 	if len(r.ids) == 0 {
 		return []core.Identification{Identification{
@@ -269,8 +287,8 @@ func (r *Recorder) Report() []core.Identification {
 			if conf > mimeScore && v.confidence != conf {
 				break
 			}
-			// if the match has no corresponding byte or RIFF signature...
-			if ok := r.HasSig(v.ID, core.RIFFMatcher, core.ByteMatcher); !ok {
+			// if the match has no corresponding byte or RIFF signature (or signatures weren't tested, in name-only mode)...
+			if ok := r.HasSig(v.ID, core.RIFFMatcher, core.ByteMatcher); !ok || config.NameOnly() {
 				// break immediately if more than one match
 				if len(nids) > 0 {
 					nids = nids[:0]
@@ -390,6 +408,15 @@ func (id Identification) Warn() string {
 	return id.Warning
 }
 
+// Bases returns the basis of the identification in a structured form.
+func (id Identification) Bases() []core.Basis {
+	return core.ParseBases(id.Basis)
+}
+
+func (id Identification) Codes() []string {
+	return core.WarningCodes(id.Warning)
+}
+
 func (id Identification) Values() []string {
 	var basis string
 	if len(id.Basis) > 0 {
@@ -26,6 +26,7 @@ import (
 	"github.com/richardlehane/siegfried/internal/bytematcher/frames"
 	"github.com/richardlehane/siegfried/internal/identifier"
 	"github.com/richardlehane/siegfried/internal/priority"
+	"github.com/richardlehane/siegfried/internal/xmlmatcher"
 	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/loc/internal/mappings"
 	"github.com/richardlehane/siegfried/pkg/pronom"
@@ -263,7 +264,8 @@ func (f fdds) RIFFs() ([][4]byte, []string) {
 	riffs, ids := make([][4]byte, 0, len(f.f)), make([]string, 0, len(f.f))
 	for _, v := range f.f {
 		for _, w := range v.Others {
-			if w.Tag == "Microsoft FOURCC" {
+			switch w.Tag {
+			case "Microsoft FOURCC":
 				for _, x := range w.Values {
 					if len(x) == 4 {
 						val := [4]byte{}
@@ -271,12 +273,95 @@ func (f fdds) RIFFs() ([][4]byte, []string) {
 						riffs, ids = append(riffs, val), append(ids, v.ID)
 					}
 				}
+			case "File type brand (ISO Base Media File Format)": // brands are matched by the riffmatcher too
+				for _, x := range w.Values {
+					// values may have an escaped space and a note e.g. "jp2\040 [jp2 plus the space character]"
+					if fs := strings.Fields(x); len(fs) > 0 {
+						x = strings.ReplaceAll(fs[0], "\\040", " ")
+					}
+					if len(x) == 4 {
+						val := [4]byte{}
+						copy(val[:], x[:])
+						riffs, ids = append(riffs, val), append(ids, v.ID)
+					}
+				}
 			}
 		}
 	}
 	return riffs, ids
 }
 
+// XMLs returns root, namespace pairs from the XML namespace and DOCTYPE declarations in the FDDs.
+// Namespace declarations of zip-based formats (e.g. OOXML) are for the XML parts within those packages, so are skipped.
+func (f fdds) XMLs() ([][2]string, []string) {
+	zipped := f.zipped()
+	xmls, ids := make([][2]string, 0, len(f.f)), make([]string, 0, len(f.f))
+	for _, v := range f.f {
+		for _, w := range v.Others {
+			switch w.Tag {
+			case "XML namespace declaration":
+				if zipped[v.ID] {
+					continue
+				}
+				for _, x := range w.Values {
+					// values may be given as a URI or as a declaration e.g. xmlns="http://www.w3.org/2000/svg"
+					if ns := xmlmatcher.Namespaces([]byte(x)); len(ns) > 0 {
+						x = ns[0]
+					}
+					if x != "" && !strings.Contains(x, "...") && !strings.ContainsAny(x, " \t\n") {
+						xmls, ids = append(xmls, [2]string{"", x}), append(ids, v.ID)
+					}
+				}
+			case "XML DOCTYPE declaration":
+				for _, x := range w.Values {
+					root, public, system := xmlmatcher.Doctype([]byte(x))
+					if public == "" {
+						public = system
+					}
+					if public != "" {
+						xmls, ids = append(xmls, [2]string{root, public}), append(ids, v.ID)
+					}
+				}
+			}
+		}
+	}
+	return xmls, ids
+}
+
+// zipped returns the IDs of FDDs that are subtypes, directly or by way of other subtypes, of a format with the application/zip MIME type
+func (f fdds) zipped() map[string]bool {
+	byID := make(map[string]mappings.FDD, len(f.f))
+	for _, v := range f.f {
+		byID[v.ID] = v
+	}
+	var isZip func(id string, seen map[string]bool) bool
+	isZip = func(id string, seen map[string]bool) bool {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+		v := byID[id]
+		for _, m := range v.MIMEs {
+			if m == "application/zip" {
+				return true
+			}
+		}
+		for _, r := range v.Relations {
+			if r.Typ == "Subtype of" && isZip(r.Value, seen) {
+				return true
+			}
+		}
+		return false
+	}
+	ret := make(map[string]bool)
+	for _, v := range f.f {
+		if isZip(v.ID, make(map[string]bool)) {
+			ret[v.ID] = true
+		}
+	}
+	return ret
+}
+
 func (f fdds) Priorities() priority.Map {
 	p := make(priority.Map)
 	for _, v := range f.f {
@@ -68,3 +68,28 @@ func TestUpdated(t *testing.T) {
 		t.Fatalf("expected %v, got %v", expect, f.Updated())
 	}
 }
+
+func TestXMLs(t *testing.T) {
+	config.SetHome(filepath.Join("..", "..", "cmd", "roy", "data"))
+	config.SetLOC("")()
+	l, err := newLOC(config.LOC())
+	if err != nil || l == nil {
+		t.Fatalf("couldn't parse LOC file: %v", err)
+	}
+	xmls, ids := l.XMLs()
+	var svg, ooxml bool
+	for i, v := range xmls {
+		switch {
+		case ids[i] == "fdd000020" && v == [2]string{"svg", "-//W3C//DTD SVG 1.1//EN"}:
+			svg = true
+		case ids[i] == "fdd000397":
+			ooxml = true
+		}
+	}
+	if !svg {
+		t.Errorf("expecting a DOCTYPE signature for SVG, got %v", xmls)
+	}
+	if ooxml {
+		t.Error("expecting no namespace signature for DOCX, a zip container format")
+	}
+}
@@ -198,6 +198,8 @@ func (r *Recorder) Satisfied(mt core.MatcherType) (bool, core.Hint) {
 }
 
 func (r *Recorder) Report() []core.Identification {
+	// restore the recorded ids (which are pruned below), as a provisional report may be followed by more results
+	defer func(ids []Identification) { r.ids = ids }(r.ids)
 	// no results
 	if len(r.ids) == 0 {
 		return []core.Identification{Identification{
@@ -285,7 +287,7 @@ func (r *Recorder) updateWarning(i Identification) Identification {
 			i.Warning = "match on " + lowConfidence(i) + " only"
 		}
 		// if the match has no corresponding byte or xml signature...
-		if r.HasSig(i.ID, core.XMLMatcher, core.ByteMatcher) {
+		if !config.NameOnly() && r.HasSig(i.ID, core.XMLMatcher, core.ByteMatcher) {
 			i.Warning += "; byte/xml signatures for this format did not match"
 		}
 	}
@@ -365,6 +367,15 @@ func (id Identification) Warn() string {
 	return id.Warning
 }
 
+// Bases returns the basis of the identification in a structured form.
+func (id Identification) Bases() []core.Basis {
+	return core.ParseBases(id.Basis)
+}
+
+func (id Identification) Codes() []string {
+	return core.WarningCodes(id.Warning)
+}
+
 func (id Identification) Values() []string {
 	var basis string
 	if len(id.Basis) > 0 {
@@ -0,0 +1,164 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/richardlehane/siegfried"
+)
+
+func init() {
+	Register("pcap", PCAP)
+	Register("pcapng", PCAPNG)
+	Register("sqlite-wal", SQLiteWAL)
+	Register("sqlite-journal", SQLiteJournal)
+}
+
+// common link-layer header types (https://www.tcpdump.org/linktypes.html)
+var linkTypes = map[uint32]string{
+	0:   "null",
+	1:   "ethernet",
+	6:   "ieee802.5",
+	9:   "ppp",
+	101: "raw",
+	105: "ieee802.11",
+	113: "linux-sll",
+	127: "ieee802.11-radiotap",
+	187: "bluetooth-hci-h4",
+	195: "ieee802.15.4",
+	220: "usb-linux-mmapped",
+	228: "ipv4",
+	229: "ipv6",
+	276: "linux-sll2",
+}
+
+func linkType(lt uint32) string {
+	if n, ok := linkTypes[lt]; ok {
+		return n
+	}
+	return strconv.FormatUint(uint64(lt), 10)
+}
+
+// PCAP probes libpcap capture files. It reports the format version, timestamp precision, byte order and link-layer type.
+func PCAP(c siegfried.Content) (string, bool) {
+	buf, err := c.Slice(0, 24)
+	if err != nil || len(buf) < 24 {
+		return "", false
+	}
+	var order binary.ByteOrder
+	var precision string
+	for _, o := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch o.Uint32(buf) {
+		case 0xA1B2C3D4:
+			order, precision = o, "microsecond"
+		case 0xA1B23C4D:
+			order, precision = o, "nanosecond"
+		case 0xA1B2CD34: // Alexey Kuznetzov's modified format
+			order, precision = o, "microsecond (modified)"
+		}
+		if order != nil {
+			break
+		}
+	}
+	if order == nil {
+		return "", false
+	}
+	// the link type shares its field with FCS information in the upper bits
+	return describe("pcap",
+		"version", strconv.Itoa(int(order.Uint16(buf[4:])))+"."+strconv.Itoa(int(order.Uint16(buf[6:]))),
+		"precision", precision,
+		"byteorder", byteOrder(order),
+		"snaplen", strconv.FormatUint(uint64(order.Uint32(buf[16:])), 10),
+		"linktype", linkType(order.Uint32(buf[20:])&0x0FFFFFFF),
+	), true
+}
+
+func byteOrder(o binary.ByteOrder) string {
+	if o == binary.LittleEndian {
+		return "little-endian"
+	}
+	return "big-endian"
+}
+
+// PCAPNG probes pcapng capture files. It reports the section version and byte order from the Section Header Block
+// and, when it directly follows, the link-layer type of the first Interface Description Block.
+func PCAPNG(c siegfried.Content) (string, bool) {
+	buf, err := c.Slice(0, 24)
+	if err != nil || len(buf) < 24 || string(buf[:4]) != "\x0A\x0D\x0D\x0A" {
+		return "", false
+	}
+	var order binary.ByteOrder
+	switch string(buf[8:12]) {
+	case "\x4D\x3C\x2B\x1A":
+		order = binary.LittleEndian
+	case "\x1A\x2B\x3C\x4D":
+		order = binary.BigEndian
+	default:
+		return "", false
+	}
+	kvs := []string{
+		"version", strconv.Itoa(int(order.Uint16(buf[12:]))) + "." + strconv.Itoa(int(order.Uint16(buf[14:]))),
+		"byteorder", byteOrder(order),
+	}
+	l := order.Uint32(buf[4:])
+	if l < 28 || l%4 != 0 {
+		return "", false
+	}
+	if idb, err := c.Slice(int64(l), 12); err == nil && order.Uint32(idb) == 1 {
+		kvs = append(kvs, "linktype", linkType(uint32(order.Uint16(idb[8:]))))
+	}
+	return describe("pcapng", kvs...), true
+}
+
+// SQLiteWAL probes SQLite write-ahead log files. It reports the WAL format version, database page size,
+// checkpoint sequence number and the byte order of the frame checksums.
+func SQLiteWAL(c siegfried.Content) (string, bool) {
+	buf, err := c.Slice(0, 32)
+	if err != nil || len(buf) < 32 {
+		return "", false
+	}
+	var checksum string
+	switch binary.BigEndian.Uint32(buf) {
+	case 0x377F0682:
+		checksum = "little-endian"
+	case 0x377F0683:
+		checksum = "big-endian"
+	default:
+		return "", false
+	}
+	return describe("sqlite-wal",
+		"version", strconv.FormatUint(uint64(binary.BigEndian.Uint32(buf[4:])), 10),
+		"pagesize", strconv.FormatUint(uint64(binary.BigEndian.Uint32(buf[8:])), 10),
+		"checkpoint", strconv.FormatUint(uint64(binary.BigEndian.Uint32(buf[12:])), 10),
+		"checksum", checksum,
+	), true
+}
+
+// SQLiteJournal probes SQLite rollback journals. It reports the page count (-1 means the journal extends to the end of the file),
+// the original size of the database in pages, and the sector and page sizes.
+func SQLiteJournal(c siegfried.Content) (string, bool) {
+	buf, err := c.Slice(0, 28)
+	if err != nil || len(buf) < 28 || string(buf[:8]) != "\xD9\xD5\x05\xF9\x20\xA1\x63\xD7" {
+		return "", false
+	}
+	return describe("sqlite-journal",
+		"pages", strconv.Itoa(int(int32(binary.BigEndian.Uint32(buf[8:])))),
+		"dbpages", strconv.FormatUint(uint64(binary.BigEndian.Uint32(buf[16:])), 10),
+		"sectorsize", strconv.FormatUint(uint64(binary.BigEndian.Uint32(buf[20:])), 10),
+		"pagesize", strconv.FormatUint(uint64(binary.BigEndian.Uint32(buf[24:])), 10),
+	), true
+}
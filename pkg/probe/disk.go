@@ -0,0 +1,65 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/decompress"
+)
+
+func init() {
+	Register("disk", Disk)
+	Register("filesystem", Filesystem)
+}
+
+// Disk probes raw disk images. It reports the partition table type (mbr or gpt),
+// the number of partitions and the type of each partition.
+func Disk(c siegfried.Content) (string, bool) {
+	table, parts, err := decompress.Partitions(readerAt{c}, c.SizeNow())
+	if err != nil {
+		return "", false
+	}
+	types := make([]string, len(parts))
+	for i, p := range parts {
+		types[i] = p.Type
+	}
+	return describe(table,
+		"partitions", strconv.Itoa(len(parts)),
+		"types", strings.Join(types, ", "),
+	), true
+}
+
+// Filesystem probes volumes (such as the partitions of a disk image) and reports the filesystem type
+// e.g. fat32, ntfs, exfat, ext, hfs+, apfs or iso9660.
+func Filesystem(c siegfried.Content) (string, bool) {
+	fs := decompress.Filesystem(readerAt{c})
+	if fs == "" {
+		return "", false
+	}
+	return describe("filesystem", "type", fs), true
+}
+
+// readerAt adapts Content to the io.ReaderAt interface
+type readerAt struct {
+	siegfried.Content
+}
+
+func (r readerAt) ReadAt(b []byte, off int64) (int, error) {
+	buf, err := r.Slice(off, len(b))
+	return copy(b, buf), err
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// Encryption returns a pair of extra fields, "encrypted" and "encryption", that report whether content is
+// encrypted or password protected (true or false) and the mechanism used (e.g. "zip aes" or "pdf standard security handler").
+// Encryption is detected for PDF, OOXML (encrypted packages in OLE2 containers), zip, RAR and 7z.
+// The content is checked once, for the "encrypted" field, and the mechanism kept for the "encryption" field (which follows it).
+func Encryption() []siegfried.Extra {
+	var mu sync.Mutex
+	mechs := make(map[siegfried.Content]string)
+	// content is used as a key if it can be (siegfried gives extras a buffer, or a window on one)
+	keyed := func(c siegfried.Content) bool {
+		return c != nil && reflect.TypeOf(c).Comparable()
+	}
+	return []siegfried.Extra{
+		{
+			Name: "encrypted",
+			Fn: func(c siegfried.Content, ids []core.Identification) string {
+				mech, ok := Encrypted(c)
+				if keyed(c) {
+					mu.Lock()
+					mechs[c] = mech
+					mu.Unlock()
+				}
+				return strconv.FormatBool(ok)
+			},
+		},
+		{
+			Name: "encryption",
+			Fn: func(c siegfried.Content, ids []core.Identification) string {
+				if keyed(c) {
+					mu.Lock()
+					mech, ok := mechs[c]
+					delete(mechs, c)
+					mu.Unlock()
+					if ok {
+						return mech
+					}
+				}
+				mech, _ := Encrypted(c)
+				return mech
+			},
+		},
+	}
+}
+
+// Encrypted reports whether content is encrypted and, if so, the mechanism.
+func Encrypted(c siegfried.Content) (string, bool) {
+	for _, fn := range []func(siegfried.Content) (string, bool){encPDF, encOLE, encZip, encRAR, enc7z} {
+		if mech, ok := fn(c); ok {
+			return mech, true
+		}
+	}
+	return "", false
+}
+
+// windows returns the BOF and EOF windows of content
+func windows(c siegfried.Content) ([]byte, []byte) {
+	bof, _ := c.Slice(0, siegfried.WindowSize)
+	eof, _ := c.EofSlice(0, siegfried.WindowSize)
+	if eof == nil && c.SizeNow() < siegfried.WindowSize {
+		eof = bof
+	}
+	return bof, eof
+}
+
+// PDFs are encrypted if the trailer has an /Encrypt entry. Linearized files have a first page trailer near the start of the file.
+func encPDF(c siegfried.Content) (string, bool) {
+	bof, eof := windows(c)
+	if !bytes.HasPrefix(bof, []byte("%PDF")) {
+		return "", false
+	}
+	if !bytes.Contains(eof, []byte("/Encrypt")) && !bytes.Contains(bof, []byte("/Encrypt")) {
+		return "", false
+	}
+	for _, buf := range [][]byte{eof, bof} {
+		switch {
+		case bytes.Contains(buf, []byte("/Standard")):
+			return "pdf standard security handler", true
+		case bytes.Contains(buf, []byte("/Adobe.PubSec")):
+			return "pdf public key security handler", true
+		}
+	}
+	return "pdf", true
+}
+
+// Encrypted OOXML files are OLE2 compound files with EncryptionInfo and EncryptedPackage streams
+func encOLE(c siegfried.Content) (string, bool) {
+	bof, eof := windows(c)
+	if !bytes.HasPrefix(bof, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")) {
+		return "", false
+	}
+	name := utf16le("EncryptedPackage")
+	if bytes.Contains(bof, name) || bytes.Contains(eof, name) {
+		return "ooxml encrypted package", true
+	}
+	return "", false
+}
+
+func utf16le(s string) []byte {
+	ret := make([]byte, len(s)*2)
+	for i := range s {
+		ret[i*2] = s[i]
+	}
+	return ret
+}
+
+// Zip members are encrypted if bit 0 of their general purpose flags is set. AES encrypted members use method 99.
+// The central directory is at the end of the file so it is checked as well as the first local file header.
+func encZip(c siegfried.Content) (string, bool) {
+	bof, eof := windows(c)
+	if !bytes.HasPrefix(bof, []byte("PK\x03\x04")) {
+		return "", false
+	}
+	check := func(flags, method uint16) (string, bool) {
+		if flags&1 == 0 {
+			return "", false
+		}
+		if method == 99 {
+			return "zip aes", true
+		}
+		if flags&0x40 != 0 {
+			return "zip strong encryption", true
+		}
+		return "zip traditional pkware", true
+	}
+	if len(bof) >= 10 {
+		if mech, ok := check(binary.LittleEndian.Uint16(bof[6:]), binary.LittleEndian.Uint16(bof[8:])); ok {
+			return mech, true
+		}
+	}
+	for i := bytes.Index(eof, []byte("PK\x01\x02")); i > -1 && i+12 <= len(eof); {
+		if mech, ok := check(binary.LittleEndian.Uint16(eof[i+8:]), binary.LittleEndian.Uint16(eof[i+10:])); ok {
+			return mech, true
+		}
+		next := bytes.Index(eof[i+4:], []byte("PK\x01\x02"))
+		if next < 0 {
+			break
+		}
+		i += 4 + next
+	}
+	return "", false
+}
+
+// RAR 4 archives flag encrypted headers in the main header and encrypted files in file headers.
+// RAR 5 archives with encrypted headers begin with an archive encryption header; encrypted files have an encryption record
+// in the extra area of their file headers. RAR 5 headers are checked as far as the BOF window goes.
+func encRAR(c siegfried.Content) (string, bool) {
+	buf, _ := c.Slice(0, 64)
+	switch {
+	case bytes.HasPrefix(buf, []byte("Rar!\x1A\x07\x00")) && len(buf) >= 14:
+		// main header: crc(2) type(1) flags(2) size(2)
+		if buf[9] != 0x73 {
+			return "", false
+		}
+		if binary.LittleEndian.Uint16(buf[10:])&0x0080 != 0 {
+			return "rar4 encrypted headers", true
+		}
+		off := 7 + int64(binary.LittleEndian.Uint16(buf[12:]))
+		fh, _ := c.Slice(off, 7)
+		if len(fh) == 7 && fh[2] == 0x74 && binary.LittleEndian.Uint16(fh[3:])&0x0004 != 0 {
+			return "rar4", true
+		}
+	case bytes.HasPrefix(buf, []byte("Rar!\x1A\x07\x01\x00")):
+		bof, _ := c.Slice(0, siegfried.WindowSize)
+		return encRAR5(bof)
+	}
+	return "", false
+}
+
+// header: crc(4) size(vint) type(vint) flags(vint) [extra size(vint)] [data size(vint)] ... [extra area]
+func encRAR5(bof []byte) (string, bool) {
+	for off := uint64(8); off+4 < uint64(len(bof)); {
+		p := &rar5Buf{b: bof[off+4:]}
+		size := p.vint()
+		start := uint64(len(bof)) - uint64(len(p.b)) // the offset of the header type
+		if p.bad {
+			return "", false
+		}
+		cut := size > uint64(len(p.b)) // the header runs past the window: only its type can be checked
+		if !cut {
+			p.b = p.b[:size]
+		}
+		typ, flags := p.vint(), p.vint()
+		var extra, data uint64
+		if flags&0x01 != 0 {
+			extra = p.vint()
+		}
+		if flags&0x02 != 0 {
+			data = p.vint()
+		}
+		if typ == 4 { // archive encryption header
+			return "rar5 encrypted headers", true
+		}
+		if p.bad || cut || extra > size {
+			return "", false
+		}
+		switch typ {
+		case 2, 3: // file and service headers
+			if rar5Crypt(bof[start+size-extra : start+size]) {
+				return "rar5", true
+			}
+		case 5: // end of archive
+			return "", false
+		}
+		off = start + size + data
+		if off < start { // overflow
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// rar5Crypt reads the records of a file header's extra area (as decompress does): each has a size and a type; type 1 is encryption
+func rar5Crypt(extra []byte) bool {
+	p := &rar5Buf{b: extra}
+	for len(p.b) > 0 && !p.bad {
+		rec := &rar5Buf{b: p.bytes(p.vint())}
+		if rec.vint() == 0x01 && !rec.bad {
+			return true
+		}
+	}
+	return false
+}
+
+// rar5Buf reads RAR 5 header fields. Reads past the end of the buffer set bad and return zero values.
+type rar5Buf struct {
+	b   []byte
+	bad bool
+}
+
+func (p *rar5Buf) bytes(n uint64) []byte {
+	if n > uint64(len(p.b)) {
+		p.b, p.bad = nil, true
+		return nil
+	}
+	b := p.b[:n]
+	p.b = p.b[n:]
+	return b
+}
+
+// vint reads a variable length integer: seven bits a byte, least significant first, the high bit is set if more bytes follow
+func (p *rar5Buf) vint() uint64 {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		b := p.bytes(1)
+		if b == nil {
+			return 0
+		}
+		v |= uint64(b[0]&0x7f) << (7 * uint(i))
+		if b[0]&0x80 == 0 {
+			return v
+		}
+	}
+	p.bad = true
+	return 0
+}
+
+// 7z archives that use AES include the 7zAES coder ID in their (typically encoded) header at the end of the file.
+func enc7z(c siegfried.Content) (string, bool) {
+	buf, _ := c.Slice(0, 32)
+	if len(buf) < 32 || !bytes.HasPrefix(buf, []byte("7z\xBC\xAF\x27\x1C")) {
+		return "", false
+	}
+	off, sz := int64(binary.LittleEndian.Uint64(buf[12:])), int64(binary.LittleEndian.Uint64(buf[20:]))
+	if sz <= 0 || sz > siegfried.WindowSize {
+		return "", false
+	}
+	hdr, _ := c.Slice(32+off, int(sz))
+	if hdr == nil {
+		// outside the BOF window, so try the EOF window
+		if c.SizeNow() >= 32+off+sz {
+			hdr, _ = c.EofSlice(c.SizeNow()-32-off-sz, int(sz))
+		}
+	}
+	if bytes.Contains(hdr, []byte("\x06\xF1\x07\x01")) {
+		return "7z aes", true
+	}
+	return "", false
+}
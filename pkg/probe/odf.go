@@ -0,0 +1,140 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+)
+
+func init() {
+	Register("odf", ODF)
+}
+
+const (
+	odfMIME   = "application/vnd.oasis.opendocument."
+	odfOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odfMani   = "urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"
+)
+
+// ODF probes OpenDocument files: packages (zip files with a mimetype first entry) and flat (single XML file) documents.
+// It reports the subtype (e.g. text, spreadsheet or text-template), the packaging and the ODF version.
+// The subtype is read from the stored mimetype entry of packages, or from the office:mimetype attribute of flat documents.
+// The version of packages is read from the office:version attribute of the root element of meta.xml, content.xml or styles.xml,
+// or from the manifest:version attribute of META-INF/manifest.xml. Parts beyond the BOF and EOF windows can't be read.
+func ODF(c siegfried.Content) (string, bool) {
+	buf, _ := c.Slice(0, 30)
+	if len(buf) == 30 && string(buf[:4]) == "PK\x03\x04" {
+		return odfPackage(c, buf)
+	}
+	return odfFlat(c)
+}
+
+func odfPackage(c siegfried.Content, hdr []byte) (string, bool) {
+	// the mimetype entry is first, stored and uncompressed
+	nameLen, extraLen := int64(binary.LittleEndian.Uint16(hdr[26:])), int64(binary.LittleEndian.Uint16(hdr[28:]))
+	size := int(binary.LittleEndian.Uint32(hdr[18:]))
+	if binary.LittleEndian.Uint16(hdr[8:]) != 0 || nameLen != 8 || size > 128 {
+		return "", false
+	}
+	if name, _ := c.Slice(30, 8); string(name) != "mimetype" {
+		return "", false
+	}
+	// writers that stream the package leave sizes to a data descriptor following the entry
+	streamed := binary.LittleEndian.Uint16(hdr[6:])&0x8 != 0 && size == 0
+	if streamed {
+		size = 128
+	}
+	mime, _ := c.Slice(30+nameLen+extraLen, size)
+	if streamed {
+		if i := bytes.Index(mime, []byte("PK")); i > -1 {
+			mime = mime[:i]
+		}
+	}
+	if !bytes.HasPrefix(mime, []byte(odfMIME)) {
+		return "", false
+	}
+	kvs := []string{"subtype", strings.TrimPrefix(string(mime), odfMIME), "package", "zip"}
+	zr, err := zip.NewReader(windowReaderAt{c}, c.SizeNow())
+	if err != nil {
+		return describe("odf", kvs...), true
+	}
+	parts := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+	for _, p := range []struct{ name, space, attr string }{
+		{"meta.xml", odfOffice, "version"},
+		{"content.xml", odfOffice, "version"},
+		{"styles.xml", odfOffice, "version"},
+		{"META-INF/manifest.xml", odfMani, "version"},
+	} {
+		f, ok := parts[p.name]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		attrs := rootAttrs(io.LimitReader(rc, siegfried.WindowSize))
+		rc.Close()
+		if v := attrs[xml.Name{Space: p.space, Local: p.attr}]; v != "" {
+			return describe("odf", append(kvs, "version", v)...), true
+		}
+	}
+	return describe("odf", kvs...), true
+}
+
+func odfFlat(c siegfried.Content) (string, bool) {
+	buf, _ := c.Slice(0, siegfried.WindowSize)
+	if !bytes.Contains(buf, []byte("opendocument")) {
+		return "", false
+	}
+	attrs := rootAttrs(bytes.NewReader(buf))
+	mime := attrs[xml.Name{Space: odfOffice, Local: "mimetype"}]
+	if !strings.HasPrefix(mime, odfMIME) {
+		return "", false
+	}
+	kvs := []string{"subtype", strings.TrimPrefix(mime, odfMIME), "package", "flat"}
+	if v := attrs[xml.Name{Space: odfOffice, Local: "version"}]; v != "" {
+		kvs = append(kvs, "version", v)
+	}
+	return describe("odf", kvs...), true
+}
+
+// rootAttrs returns the attributes of the root element of an XML document
+func rootAttrs(r io.Reader) map[xml.Name]string {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			attrs := make(map[xml.Name]string, len(se.Attr))
+			for _, a := range se.Attr {
+				attrs[a.Name] = a.Value
+			}
+			return attrs
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+
+	"github.com/richardlehane/siegfried"
+)
+
+func init() {
+	Register("pst", PST)
+	Register("msg", MSG)
+}
+
+var pstCrypt = map[byte]string{
+	0x00: "none",
+	0x01: "permute",
+	0x02: "cyclic",
+	0x10: "edp",
+}
+
+// PST probes Outlook Personal Storage Table files: personal folders (PST), offline folders (OST) and personal address books (PAB).
+// It reports the file type, the variant (ANSI, or Unicode with 512 byte or 4K pages) and format version, and the encoding applied to data blocks.
+func PST(c siegfried.Content) (string, bool) {
+	buf, _ := c.Slice(0, 514)
+	if len(buf) < 514 || string(buf[:4]) != "!BDN" {
+		return "", false
+	}
+	var typ string
+	switch string(buf[8:10]) {
+	case "SM":
+		typ = "pst"
+	case "SO":
+		typ = "ost"
+	case "AB":
+		typ = "pab"
+	default:
+		return "", false
+	}
+	ver := binary.LittleEndian.Uint16(buf[10:])
+	var variant string
+	crypt := buf[513]
+	switch {
+	case ver == 14 || ver == 15:
+		variant, crypt = "ansi", buf[461]
+	case ver >= 36:
+		variant = "unicode-4k"
+	case ver >= 23:
+		variant = "unicode"
+	default:
+		return "", false
+	}
+	kvs := []string{"type", typ, "variant", variant, "version", strconv.Itoa(int(ver))}
+	if name, ok := pstCrypt[crypt]; ok {
+		kvs = append(kvs, "encryption", name)
+	}
+	return describe("pst", kvs...), true
+}
+
+// MSG probes Outlook message files: compound files with a __properties_version1.0 stream. It reports the message class
+// (e.g. IPM.Note or IPM.Appointment) and the numbers of recipients and attachments.
+// Messages with large attachments may have streams beyond the BOF and EOF windows, which can't be read.
+func MSG(c siegfried.Content) (string, bool) {
+	if buf, _ := c.Slice(0, 8); string(buf) != "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1" {
+		return "", false
+	}
+	doc, err := mscfb.New(windowReaderAt{c})
+	if err != nil {
+		return "", false
+	}
+	var (
+		props                   bool
+		class                   string
+		recipients, attachments int
+	)
+	for f, err := doc.Next(); err == nil; f, err = doc.Next() {
+		if len(f.Path) > 0 {
+			continue // only the top level of the message
+		}
+		switch {
+		case f.Name == "__properties_version1.0":
+			props = true
+		case strings.HasPrefix(f.Name, "__recip_version1.0_"):
+			recipients++
+		case strings.HasPrefix(f.Name, "__attach_version1.0_"):
+			attachments++
+		case f.Name == "__substg1.0_001A001F" && f.Size < 512: // PR_MESSAGE_CLASS, UTF-16
+			buf := make([]byte, f.Size)
+			if n, _ := io.ReadFull(f, buf); n > 1 {
+				u := make([]uint16, n/2)
+				for i := range u {
+					u[i] = binary.LittleEndian.Uint16(buf[i*2:])
+				}
+				class = strings.TrimRight(string(utf16.Decode(u)), "\x00")
+			}
+		case f.Name == "__substg1.0_001A001E" && f.Size < 256 && class == "": // PR_MESSAGE_CLASS, 8-bit
+			buf := make([]byte, f.Size)
+			n, _ := io.ReadFull(f, buf)
+			class = strings.TrimRight(string(buf[:n]), "\x00")
+		}
+	}
+	if !props {
+		return "", false
+	}
+	var kvs []string
+	if class != "" {
+		kvs = append(kvs, "class", class)
+	}
+	kvs = append(kvs, "recipients", strconv.Itoa(recipients), "attachments", strconv.Itoa(attachments))
+	return describe("msg", kvs...), true
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/decompress"
+)
+
+func init() {
+	Register("rpm", RPM)
+	Register("deb", DEB)
+}
+
+// RPM header tags
+const (
+	rpmName    = 1000
+	rpmVersion = 1001
+	rpmRelease = 1002
+	rpmEpoch   = 1003
+	rpmArch    = 1022
+)
+
+// RPM probes RPM packages. It reports the package NEVRA (name-epoch:version-release.arch) from the package header.
+func RPM(c siegfried.Content) (string, bool) {
+	lead, err := c.Slice(0, 96)
+	if err != nil || string(lead[:4]) != "\xED\xAB\xEE\xDB" {
+		return "", false
+	}
+	source := binary.BigEndian.Uint16(lead[6:]) == 1
+	// the signature header follows the lead and is padded to a multiple of 8 bytes; the package header follows that
+	_, sigLen, ok := rpmHeader(c, 96)
+	if !ok {
+		return "", false
+	}
+	off := 96 + sigLen
+	if off%8 != 0 {
+		off += 8 - off%8
+	}
+	tags, _, ok := rpmHeader(c, off)
+	if !ok {
+		return "", false
+	}
+	name, version, release, arch := tags[rpmName], tags[rpmVersion], tags[rpmRelease], tags[rpmArch]
+	if name == "" || version == "" {
+		return "", false
+	}
+	if source {
+		arch = "src"
+	}
+	nevra := name + "-"
+	if epoch := tags[rpmEpoch]; epoch != "" && epoch != "0" {
+		nevra += epoch + ":"
+	}
+	nevra += version
+	if release != "" {
+		nevra += "-" + release
+	}
+	if arch != "" {
+		nevra += "." + arch
+	}
+	return describe("rpm", "nevra", nevra), true
+}
+
+// rpmHeader parses the NEVRA tags of an RPM header structure at off, and returns the tags and the length of the structure.
+// Headers can be large (they include file lists) so only the store entries for those tags are read.
+func rpmHeader(c siegfried.Content, off int64) (map[int]string, int64, bool) {
+	hdr, err := c.Slice(off, 16)
+	if err != nil || string(hdr[:3]) != "\x8E\xAD\xE8" {
+		return nil, 0, false
+	}
+	n, hsz := int64(binary.BigEndian.Uint32(hdr[8:])), int64(binary.BigEndian.Uint32(hdr[12:]))
+	if n > 4096 {
+		return nil, 0, false
+	}
+	index, err := c.Slice(off+16, int(n*16))
+	if err != nil {
+		return nil, 0, false
+	}
+	store := off + 16 + n*16
+	tags := make(map[int]string)
+	for i := int64(0); i < n; i++ {
+		e := index[i*16 : i*16+16]
+		tag, typ, o := int(binary.BigEndian.Uint32(e)), binary.BigEndian.Uint32(e[4:]), int64(binary.BigEndian.Uint32(e[8:]))
+		if o >= hsz || (tag != rpmName && tag != rpmVersion && tag != rpmRelease && tag != rpmEpoch && tag != rpmArch) {
+			continue
+		}
+		switch typ {
+		case 4: // int32
+			if buf, err := c.Slice(store+o, 4); err == nil {
+				tags[tag] = strconv.FormatUint(uint64(binary.BigEndian.Uint32(buf)), 10)
+			}
+		case 6, 9: // string, i18n string
+			buf, _ := c.Slice(store+o, 256)
+			if end := bytes.IndexByte(buf, 0); end > -1 {
+				tags[tag] = string(buf[:end])
+			}
+		}
+	}
+	return tags, 16 + n*16 + hsz, true
+}
+
+// DEB probes Debian packages. It reports the package format version and, if the control tarball is within
+// the probe window, the package name, version and architecture.
+func DEB(c siegfried.Content) (string, bool) {
+	d, err := decompress.NewAR(readerAt{c}, "")
+	if err != nil || d.Next() != nil || !strings.HasSuffix(d.Path(), "debian-binary") {
+		return "", false
+	}
+	buf := make([]byte, 16)
+	n, _ := io.ReadFull(d.Reader(), buf)
+	kvs := []string{"format", strings.TrimSpace(string(buf[:n]))}
+	for err = d.Next(); err == nil; err = d.Next() {
+		if strings.HasSuffix(d.Path(), "control.tar") {
+			kvs = append(kvs, debControl(d.Reader())...)
+			break
+		}
+	}
+	return describe("deb", kvs...), true
+}
+
+func debControl(r io.Reader) []string {
+	tr := tar.NewReader(r)
+	for hdr, err := tr.Next(); err == nil; hdr, err = tr.Next() {
+		if path.Clean(hdr.Name) != "control" {
+			continue
+		}
+		var kvs []string
+		scanner := bufio.NewScanner(tr)
+		for scanner.Scan() {
+			k, v, ok := strings.Cut(scanner.Text(), ":")
+			if !ok {
+				continue
+			}
+			switch k {
+			case "Package":
+				kvs = append(kvs, "package", strings.TrimSpace(v))
+			case "Version":
+				kvs = append(kvs, "version", strings.TrimSpace(v))
+			case "Architecture":
+				kvs = append(kvs, "arch", strings.TrimSpace(v))
+			}
+		}
+		return kvs
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package probe implements structural probes. Probes read the header of a file and report
+// format-specific details, such as a version number or compression variant, that signatures
+// alone don't surface.
+//
+// Probes are added to a Siegfried as an extra field.
+//
+// Example:
+//  e, err := probe.Extra("swf", "flv")
+//  if err != nil {
+//  	log.Fatal(err)
+//  }
+//  err = s.AddExtra(e)
+package probe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// Probe inspects content and returns a short description of its structure.
+// It returns false if the content isn't recognised.
+type Probe func(siegfried.Content) (string, bool)
+
+var probes = make(map[string]Probe)
+
+// Register adds a named probe. Probes in this package register themselves in init functions.
+func Register(name string, p Probe) {
+	probes[name] = p
+}
+
+// List returns a comma separated list of the names of the registered probes.
+func List() string {
+	return strings.Join(names(), ", ")
+}
+
+func names() []string {
+	ret := make([]string, 0, len(probes))
+	for k := range probes {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Extra returns a siegfried.Extra, named "probe", that runs the named probes (all probes if given
+// no names or "all") and reports the result of the first that recognises the content.
+func Extra(nms ...string) (siegfried.Extra, error) {
+	if len(nms) == 0 || (len(nms) == 1 && nms[0] == "all") {
+		nms = names()
+	}
+	ps := make([]Probe, len(nms))
+	for i, n := range nms {
+		p, ok := probes[strings.TrimSpace(n)]
+		if !ok {
+			return siegfried.Extra{}, fmt.Errorf("probe: unknown probe %s; choose from %s", n, List())
+		}
+		ps[i] = p
+	}
+	return siegfried.Extra{
+		Name: "probe",
+		Fn: func(c siegfried.Content, ids []core.Identification) string {
+			for _, p := range ps {
+				if s, ok := p(c); ok {
+					return s
+				}
+			}
+			return ""
+		},
+	}, nil
+}
+
+// describe formats a probe result as a name followed by key=value pairs
+func describe(name string, kvs ...string) string {
+	strs := make([]string, 1, len(kvs)/2+1)
+	strs[0] = name
+	for i := 0; i+1 < len(kvs); i += 2 {
+		strs = append(strs, kvs[i]+"="+kvs[i+1])
+	}
+	return strings.Join(strs, "; ")
+}
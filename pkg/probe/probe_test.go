@@ -0,0 +1,437 @@
+package probe
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testContent is a simple byte slice backed siegfried.Content
+type testContent []byte
+
+func (t testContent) Slice(off int64, l int) ([]byte, error) {
+	if off >= int64(len(t)) {
+		return nil, io.EOF
+	}
+	if off+int64(l) > int64(len(t)) {
+		return t[off:], io.EOF
+	}
+	return t[off : off+int64(l)], nil
+}
+
+func (t testContent) EofSlice(off int64, l int) ([]byte, error) {
+	if off >= int64(len(t)) {
+		return nil, io.EOF
+	}
+	if off+int64(l) > int64(len(t)) {
+		return t[:int64(len(t))-off], io.EOF
+	}
+	return t[int64(len(t))-off-int64(l) : int64(len(t))-off], nil
+}
+
+func (t testContent) SizeNow() int64 { return int64(len(t)) }
+
+func zlibbed(b []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestSWF(t *testing.T) {
+	tests := []struct {
+		content []byte
+		expect  string
+	}{
+		{append([]byte("FWS\x0a\x20\x00\x00\x00"), make([]byte, 24)...), "swf; compression=none; version=10; length=32"},
+		{append([]byte("CWS\x09\x20\x00\x00\x00"), zlibbed(make([]byte, 24))...), "swf; compression=zlib; version=9; length=32"},
+		{append([]byte("ZWS\x0d\x20\x00\x00\x00\x10\x00\x00\x00\x5d\x00\x00\x10\x00"), make([]byte, 16)...), "swf; compression=lzma; version=13; length=32"},
+		{append([]byte("CWS\x09\x20\x00\x00\x00"), make([]byte, 24)...), ""}, // bad zlib stream
+		{[]byte("FWS"), ""},
+	}
+	for _, v := range tests {
+		res, ok := SWF(testContent(v.content))
+		if res != v.expect || ok != (v.expect != "") {
+			t.Errorf("bad SWF probe for %q: expecting %q, got %q", v.content[:3], v.expect, res)
+		}
+	}
+}
+
+func TestFLV(t *testing.T) {
+	res, ok := FLV(testContent("FLV\x01\x05\x00\x00\x00\x09"))
+	if !ok || res != "flv; version=1; audio=true; video=true" {
+		t.Errorf("bad FLV probe, got %q", res)
+	}
+	if _, ok := FLV(testContent("FLV\x01\x05\x00\x00\x00")); ok {
+		t.Error("FLV probe should fail for a truncated header")
+	}
+}
+
+// mbrImage makes a small disk image with a single FAT32 partition
+func mbrImage() []byte {
+	img := make([]byte, 512*8)
+	img[446+4] = 0x0C
+	binary.LittleEndian.PutUint32(img[446+8:], 2)
+	binary.LittleEndian.PutUint32(img[446+12:], 4)
+	copy(img[510:], "\x55\xAA")
+	copy(img[1024+82:], "FAT32   ")
+	copy(img[1024+510:], "\x55\xAA")
+	return img
+}
+
+func TestDisk(t *testing.T) {
+	img := mbrImage()
+	res, ok := Disk(testContent(img))
+	if !ok || res != "mbr; partitions=1; types=FAT32" {
+		t.Errorf("bad disk probe, got %q", res)
+	}
+	// the partition is a volume, not a disk
+	if _, ok := Disk(testContent(img[1024:])); ok {
+		t.Error("disk probe should fail for a FAT32 volume")
+	}
+	res, ok = Filesystem(testContent(img[1024:]))
+	if !ok || res != "filesystem; type=fat32" {
+		t.Errorf("bad filesystem probe, got %q", res)
+	}
+	// partition extends beyond the end of the image
+	if _, ok := Disk(testContent(img[:2048])); ok {
+		t.Error("disk probe should fail for a truncated image")
+	}
+}
+
+// rpmImage makes a minimal RPM with an empty signature header and a package header with NEVRA tags
+func rpmImage() []byte {
+	lead := make([]byte, 96)
+	copy(lead, "\xED\xAB\xEE\xDB\x03\x00")
+	u32 := func(b []byte, i uint32) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, i)
+		return append(b, buf...)
+	}
+	hdr := func(tags []uint32, types []uint32, store []byte, offs []uint32) []byte {
+		b := []byte("\x8E\xAD\xE8\x01\x00\x00\x00\x00")
+		b = u32(b, uint32(len(tags)))
+		b = u32(b, uint32(len(store)))
+		for i := range tags {
+			b = u32(b, tags[i])
+			b = u32(b, types[i])
+			b = u32(b, offs[i])
+			b = u32(b, 1)
+		}
+		return append(b, store...)
+	}
+	img := append(lead, hdr(nil, nil, nil, nil)...) // the empty signature header is 16 bytes so needs no padding
+	store := []byte("hello\x002.10\x003.fc38\x00x86_64\x00")
+	return append(img, hdr([]uint32{rpmName, rpmVersion, rpmRelease, rpmArch}, []uint32{6, 6, 6, 6}, store, []uint32{0, 6, 11, 18})...)
+}
+
+func TestRPM(t *testing.T) {
+	res, ok := RPM(testContent(rpmImage()))
+	if !ok || res != "rpm; nevra=hello-2.10-3.fc38.x86_64" {
+		t.Errorf("bad RPM probe, got %q", res)
+	}
+}
+
+// debImage makes a minimal Debian package
+func debImage() []byte {
+	var ctrl bytes.Buffer
+	gz := gzip.NewWriter(&ctrl)
+	tw := tar.NewWriter(gz)
+	control := "Package: hello\nVersion: 2.10-3\nArchitecture: amd64\n"
+	tw.WriteHeader(&tar.Header{Name: "./control", Mode: 0644, Size: int64(len(control))})
+	io.WriteString(tw, control)
+	tw.Close()
+	gz.Close()
+	img := []byte("!<arch>\n")
+	member := func(name string, data []byte) {
+		img = append(img, fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, 0, 0, 0, "100644", len(data))...)
+		img = append(img, data...)
+		if len(data)%2 == 1 {
+			img = append(img, '\n')
+		}
+	}
+	member("debian-binary", []byte("2.0\n"))
+	member("control.tar.gz", ctrl.Bytes())
+	member("data.tar.xz", []byte("data"))
+	return img
+}
+
+func TestDEB(t *testing.T) {
+	res, ok := DEB(testContent(debImage()))
+	if !ok || res != "deb; format=2.0; package=hello; version=2.10-3; arch=amd64" {
+		t.Errorf("bad DEB probe, got %q", res)
+	}
+	if _, ok := DEB(testContent("!<arch>\n")); ok {
+		t.Error("DEB probe should fail for an empty ar archive")
+	}
+}
+
+func TestExtra(t *testing.T) {
+	if _, err := Extra("swf", "nonesuch"); err == nil {
+		t.Error("expecting an error for an unknown probe")
+	}
+	e, err := Extra()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := e.Fn(testContent("FLV\x01\x04\x00\x00\x00\x09"), nil); res != "flv; version=1; audio=true; video=false" {
+		t.Errorf("bad probe extra, got %q", res)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	zip := func(flags, method byte) []byte {
+		return []byte{'P', 'K', 3, 4, 20, 0, flags, 0, method, 0, 0, 0}
+	}
+	for _, v := range []struct {
+		content string
+		mech    string
+		ok      bool
+	}{
+		{"%PDF-1.7\ntrailer\n<< /Root 1 0 R /Encrypt 5 0 R >>\n5 0 obj << /Filter /Standard /V 2 >>", "pdf standard security handler", true},
+		{"%PDF-1.7\ntrailer\n<< /Root 1 0 R >>\n%%EOF", "", false},
+		{"\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1" + string(utf16le("EncryptedPackage")), "ooxml encrypted package", true},
+		{string(zip(1, 8)), "zip traditional pkware", true},
+		{string(zip(1, 99)), "zip aes", true},
+		{string(zip(0, 8)), "", false},
+		{"Rar!\x1A\x07\x00\x00\x00\x73\x80\x00\x0D\x00", "rar4 encrypted headers", true},
+		{"Rar!\x1A\x07\x01\x00\x00\x00\x00\x00\x0C\x04\x00\x00\x00\x00", "rar5 encrypted headers", true},
+		{rar5File("\x09\x03\x02\x00\x00\x00\x00\x00\x00\x00"), "", false}, // a modification time record
+		{rar5File("\x05\x01\x00\x00\x0F\x00"), "rar5", true},              // an encryption record
+	} {
+		mech, ok := Encrypted(testContent(v.content))
+		if ok != v.ok || mech != v.mech {
+			t.Errorf("bad encryption result for %q: got %q %v", v.content, mech, ok)
+		}
+	}
+}
+
+// rar5File makes a RAR 5 archive with a main header and a stored file header with the given extra area
+func rar5File(extra string) string {
+	hdr := func(body string) string {
+		return "\x00\x00\x00\x00" + string(rune(len(body))) + body
+	}
+	file := "\x02\x03" + string(rune(len(extra))) + "\x04" + "\x00\x04\x00\x00\x00\x05a.txt" + extra
+	return "Rar!\x1A\x07\x01\x00" + hdr("\x01\x00\x00") + hdr(file) + "data" + hdr("\x05\x00\x00")
+}
+
+// countContent counts reads of testContent (and, unlike testContent, can be a map key)
+type countContent struct {
+	b *testContent
+	n *int
+}
+
+func (c countContent) Slice(off int64, l int) ([]byte, error) {
+	*c.n++
+	return c.b.Slice(off, l)
+}
+
+func (c countContent) EofSlice(off int64, l int) ([]byte, error) {
+	*c.n++
+	return c.b.EofSlice(off, l)
+}
+
+func (c countContent) SizeNow() int64 { return c.b.SizeNow() }
+
+func TestEncryption(t *testing.T) {
+	es := Encryption()
+	var n int
+	b := testContent(rar5File("\x05\x01\x00\x00\x0F\x00"))
+	c := countContent{&b, &n}
+	if v := es[0].Fn(c, nil); v != "true" {
+		t.Errorf("expecting encrypted, got %s", v)
+	}
+	reads := n
+	if v := es[1].Fn(c, nil); v != "rar5" || n != reads {
+		t.Errorf("expecting the mechanism without reading the content again, got %s (%d reads)", v, n-reads)
+	}
+	// without the encrypted field, or for content that can't be a key, the content is checked again
+	if v := es[1].Fn(c, nil); v != "rar5" || n == reads {
+		t.Errorf("expecting the content to be read again, got %s", v)
+	}
+	if v := es[0].Fn(testContent("PK\x03\x04\x00\x00\x01\x00\x63\x00"), nil) + es[1].Fn(testContent("PK\x03\x04\x00\x00\x01\x00\x63\x00"), nil); v != "truezip aes" {
+		t.Errorf("bad result for uncomparable content, got %s", v)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	pcap := make([]byte, 24)
+	copy(pcap, "\xD4\xC3\xB2\xA1\x02\x00\x04\x00")
+	binary.LittleEndian.PutUint32(pcap[16:], 65535)
+	binary.LittleEndian.PutUint32(pcap[20:], 1)
+	be := make([]byte, 24)
+	copy(be, "\xA1\xB2\x3C\x4D\x00\x02\x00\x04")
+	binary.BigEndian.PutUint32(be[20:], 113)
+	ng := make([]byte, 28+20)
+	copy(ng, "\x0A\x0D\x0D\x0A\x1C\x00\x00\x00\x4D\x3C\x2B\x1A\x01\x00\x00\x00")
+	binary.LittleEndian.PutUint32(ng[28:], 1)
+	binary.LittleEndian.PutUint32(ng[32:], 20)
+	binary.LittleEndian.PutUint16(ng[36:], 105)
+	wal := make([]byte, 32)
+	copy(wal, "\x37\x7F\x06\x82\x00\x2D\xE2\x18\x00\x00\x10\x00\x00\x00\x00\x02")
+	jnl := make([]byte, 28)
+	copy(jnl, "\xD9\xD5\x05\xF9\x20\xA1\x63\xD7\xFF\xFF\xFF\xFF\x00\x00\x00\x00\x00\x00\x00\x0A\x00\x00\x02\x00\x00\x00\x10\x00")
+	for _, v := range []struct {
+		p      Probe
+		in     []byte
+		expect string
+	}{
+		{PCAP, pcap, "pcap; version=2.4; precision=microsecond; byteorder=little-endian; snaplen=65535; linktype=ethernet"},
+		{PCAP, be, "pcap; version=2.4; precision=nanosecond; byteorder=big-endian; snaplen=0; linktype=linux-sll"},
+		{PCAP, ng, ""},
+		{PCAPNG, ng, "pcapng; version=1.0; byteorder=little-endian; linktype=ieee802.11"},
+		{SQLiteWAL, wal, "sqlite-wal; version=3007000; pagesize=4096; checkpoint=2; checksum=little-endian"},
+		{SQLiteJournal, jnl, "sqlite-journal; pages=-1; dbpages=10; sectorsize=512; pagesize=4096"},
+		{SQLiteJournal, wal, ""},
+	} {
+		res, ok := v.p(testContent(v.in))
+		if res != v.expect || ok != (v.expect != "") {
+			t.Errorf("bad capture probe for %x: expecting %q, got %q", v.in[:4], v.expect, res)
+		}
+	}
+}
+
+func TestWACZ(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, f := range [][2]string{
+		{"archive/data.warc.gz", "\x1f\x8b"},
+		{"indexes/index.cdx.gz", "\x1f\x8b"},
+		{"pages/pages.jsonl", "{}"},
+		{"datapackage.json", `{"profile":"data-package","wacz_version":"1.1.1","title":"Test","created":"2023-05-01T00:00:00Z","software":"test 1.0","resources":[]}`},
+	} {
+		w, _ := zw.Create(f[0])
+		w.Write([]byte(f[1]))
+	}
+	zw.Close()
+	res, ok := WACZ(testContent(buf.Bytes()))
+	if expect := "wacz; warcs=1; indexes=1; version=1.1.1; title=Test; created=2023-05-01T00:00:00Z; software=test 1.0"; !ok || res != expect {
+		t.Errorf("bad WACZ probe: expecting %q, got %q", expect, res)
+	}
+	buf.Reset()
+	zw = zip.NewWriter(buf)
+	zw.Create("plain.txt")
+	zw.Close()
+	if _, ok := WACZ(testContent(buf.Bytes())); ok {
+		t.Error("WACZ probe should fail for a zip without datapackage.json")
+	}
+}
+
+func TestScience(t *testing.T) {
+	hdf5 := make([]byte, 1024)
+	copy(hdf5[512:], "\x89HDF\r\n\x1a\n\x00\x00\x00\x00\x00\x08\x08")
+	nc4 := make([]byte, 512)
+	copy(nc4, "\x89HDF\r\n\x1a\n\x02\x08\x08")
+	copy(nc4[300:], "_NCProperties\x00\x00\x00\x03\x00version=2,netcdf=4.9.2,hdf5=1.14.0\x00")
+	cdf1 := []byte("CDF\x01\x00\x00\x00\x05\x00\x00\x00\x0A\x00\x00\x00\x02")
+	cdf2 := []byte("CDF\x02\xFF\xFF\xFF\xFF\x00\x00\x00\x00\x00\x00\x00\x00")
+	cdf5 := []byte("CDF\x05\x00\x00\x00\x00\x00\x00\x00\x07\x00\x00\x00\x0A\x00\x00\x00\x00\x00\x00\x00\x03")
+	card := func(s string) string { return fmt.Sprintf("%-80s", s) }
+	fits := []byte(card("SIMPLE  =                    T / conforms to FITS standard") +
+		card("BITPIX  =                   16") +
+		card("NAXIS   =                    2") +
+		card("NAXIS1  =                 1024") +
+		card("NAXIS2  =                  768") +
+		card("EXTEND  =                    T") +
+		card("END"))
+	fits = append(fits, bytes.Repeat([]byte(" "), 2880-len(fits))...)
+	for _, v := range []struct {
+		p      Probe
+		in     []byte
+		expect string
+	}{
+		{HDF5, hdf5, "hdf5; version=0; userblock=512; offsets=8; lengths=8"},
+		{HDF5, nc4, "netcdf4; version=2; offsets=8; lengths=8; ncproperties=version=2,netcdf=4.9.2,hdf5=1.14.0"},
+		{HDF5, cdf1, ""},
+		{NetCDF, cdf1, "netcdf; variant=classic; records=5; dimensions=2"},
+		{NetCDF, cdf2, "netcdf; variant=64-bit offset; records=streaming; dimensions=0"},
+		{NetCDF, cdf5, "netcdf; variant=64-bit data; records=7; dimensions=3"},
+		{NetCDF, hdf5, ""},
+		{FITS, fits, "fits; simple=T; bitpix=16; naxis=2; axes=1024x768; extend=T"},
+		{FITS, fits[:80], ""},
+	} {
+		res, ok := v.p(testContent(v.in))
+		if res != v.expect || ok != (v.expect != "") {
+			t.Errorf("bad science probe for %x: expecting %q, got %q", v.in[:4], v.expect, res)
+		}
+	}
+}
+
+func TestODF(t *testing.T) {
+	pkg := func(mime string, parts ...[2]string) []byte {
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		w, _ := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+		w.Write([]byte(mime))
+		for _, p := range parts {
+			w, _ = zw.Create(p[0])
+			w.Write([]byte(p[1]))
+		}
+		zw.Close()
+		return buf.Bytes()
+	}
+	meta := `<?xml version="1.0" encoding="UTF-8"?><office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" office:version="1.3"><office:meta/></office:document-meta>`
+	manifest := `<?xml version="1.0" encoding="UTF-8"?><manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2"/>`
+	flat := `<?xml version="1.0" encoding="UTF-8"?>
+<office:document xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" office:version="1.2" office:mimetype="application/vnd.oasis.opendocument.spreadsheet"><office:body/></office:document>`
+	for _, v := range []struct {
+		in     []byte
+		expect string
+	}{
+		{pkg("application/vnd.oasis.opendocument.text", [2]string{"meta.xml", meta}, [2]string{"META-INF/manifest.xml", manifest}), "odf; subtype=text; package=zip; version=1.3"},
+		{pkg("application/vnd.oasis.opendocument.presentation-template", [2]string{"META-INF/manifest.xml", manifest}), "odf; subtype=presentation-template; package=zip; version=1.2"},
+		{pkg("application/vnd.oasis.opendocument.graphics"), "odf; subtype=graphics; package=zip"},
+		{pkg("application/epub+zip"), ""},
+		{[]byte(flat), "odf; subtype=spreadsheet; package=flat; version=1.2"},
+		{[]byte(`<?xml version="1.0"?><root about="opendocument"/>`), ""},
+	} {
+		res, ok := ODF(testContent(v.in))
+		if res != v.expect || ok != (v.expect != "") {
+			t.Errorf("bad ODF probe: expecting %q, got %q", v.expect, res)
+		}
+	}
+}
+
+func TestOutlook(t *testing.T) {
+	header := func(client, ver string, crypt byte) []byte {
+		buf := make([]byte, 564)
+		copy(buf, "!BDN\x00\x00\x00\x00"+client+ver)
+		buf[461], buf[513] = crypt, crypt
+		return buf
+	}
+	msg, err := os.ReadFile(filepath.Join("..", "..", "cmd", "sf", "testdata", "benchmark", "Benchmark.msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := os.ReadFile(filepath.Join("..", "..", "cmd", "sf", "testdata", "benchmark", "Benchmark.docx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		p      Probe
+		in     []byte
+		expect string
+	}{
+		{PST, header("SM", "\x17\x00", 1), "pst; type=pst; variant=unicode; version=23; encryption=permute"},
+		{PST, header("SO", "\x24\x00", 0), "pst; type=ost; variant=unicode-4k; version=36; encryption=none"},
+		{PST, header("SM", "\x0E\x00", 2), "pst; type=pst; variant=ansi; version=14; encryption=cyclic"},
+		{PST, header("XX", "\x17\x00", 0), ""},
+		{PST, header("SM", "\x17\x00", 0)[:100], ""},
+		{MSG, msg, "msg; class=IPM.Note; recipients=1; attachments=0"},
+		{MSG, doc, ""},
+	} {
+		res, ok := v.p(testContent(v.in))
+		if res != v.expect || ok != (v.expect != "") {
+			t.Errorf("bad outlook probe for %x: expecting %q, got %q", v.in[:10], v.expect, res)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+)
+
+func init() {
+	Register("hdf5", HDF5)
+	Register("netcdf", NetCDF)
+	Register("fits", FITS)
+}
+
+const hdf5Magic = "\x89HDF\r\n\x1a\n"
+
+// HDF5 probes HDF5 files. It reports the superblock version, the size of the user block that precedes the superblock (if any),
+// and the sizes of offsets and lengths. NetCDF-4 files are HDF5 files: they are reported as netcdf4 when the root group has the
+// _NCProperties attribute (written by netCDF-C 4.4.1 and later), along with the attribute's value (the netCDF and HDF5 library versions).
+func HDF5(c siegfried.Content) (string, bool) {
+	// the superblock is at 0 or, after a user block, at successive powers of two from 512
+	var off int64
+	for off = 0; off < siegfried.WindowSize; {
+		if buf, _ := c.Slice(off, 8); string(buf) == hdf5Magic {
+			break
+		}
+		if off == 0 {
+			off = 512
+		} else {
+			off *= 2
+		}
+	}
+	buf, _ := c.Slice(off, 16)
+	if len(buf) < 16 || string(buf[:8]) != hdf5Magic {
+		return "", false
+	}
+	version := buf[8]
+	var offsets, lengths byte
+	switch version {
+	case 0, 1:
+		offsets, lengths = buf[13], buf[14]
+	case 2, 3:
+		offsets, lengths = buf[9], buf[10]
+	default:
+		return "", false
+	}
+	name := "hdf5"
+	kvs := []string{"version", strconv.Itoa(int(version))}
+	if off > 0 {
+		kvs = append(kvs, "userblock", strconv.FormatInt(off, 10))
+	}
+	kvs = append(kvs, "offsets", strconv.Itoa(int(offsets)), "lengths", strconv.Itoa(int(lengths)))
+	if win, _ := c.Slice(0, siegfried.WindowSize); len(win) > 0 {
+		if i := bytes.Index(win, []byte("_NCProperties")); i > -1 {
+			name = "netcdf4"
+			// the attribute's value follows its name and type and space messages: a string beginning "version="
+			props := win[i:]
+			if len(props) > 256 {
+				props = props[:256]
+			}
+			if j := bytes.Index(props, []byte("version=")); j > -1 {
+				props = props[j:]
+				if k := bytes.IndexByte(props, 0); k > -1 {
+					props = props[:k]
+				}
+				kvs = append(kvs, "ncproperties", string(props))
+			}
+		}
+	}
+	return describe(name, kvs...), true
+}
+
+// NetCDF probes netCDF classic format files: classic (CDF-1), 64-bit offset (CDF-2) and 64-bit data (CDF-5) variants.
+// It reports the variant, the number of records and the number of dimensions.
+// NetCDF-4 files are HDF5 files (see the HDF5 probe).
+func NetCDF(c siegfried.Content) (string, bool) {
+	buf, _ := c.Slice(0, 24)
+	if len(buf) < 16 || string(buf[:3]) != "CDF" {
+		return "", false
+	}
+	var variant string
+	var numrecs, dims uint64
+	var streaming bool // the number of records is indeterminate
+	switch buf[3] {
+	case 1, 2:
+		variant = "classic"
+		if buf[3] == 2 {
+			variant = "64-bit offset"
+		}
+		numrecs = uint64(binary.BigEndian.Uint32(buf[4:]))
+		streaming = numrecs == 0xFFFFFFFF
+		if binary.BigEndian.Uint32(buf[8:]) == 0x0A {
+			dims = uint64(binary.BigEndian.Uint32(buf[12:]))
+		}
+	case 5:
+		if len(buf) < 24 {
+			return "", false
+		}
+		variant = "64-bit data"
+		numrecs = binary.BigEndian.Uint64(buf[4:])
+		streaming = numrecs == 0xFFFFFFFFFFFFFFFF
+		if binary.BigEndian.Uint32(buf[12:]) == 0x0A {
+			dims = binary.BigEndian.Uint64(buf[16:])
+		}
+	default:
+		return "", false
+	}
+	records := strconv.FormatUint(numrecs, 10)
+	if streaming {
+		records = "streaming"
+	}
+	return describe("netcdf", "variant", variant, "records", records, "dimensions", strconv.FormatUint(dims, 10)), true
+}
+
+// FITS probes Flexible Image Transport System files. It reads the keyword records (80 byte "cards") of the primary header
+// and reports whether the file conforms to the standard (SIMPLE), the data type (BITPIX), the number of axes (NAXIS)
+// and their lengths, and whether extensions may follow (EXTEND).
+func FITS(c siegfried.Content) (string, bool) {
+	buf, _ := c.Slice(0, 2880*4)
+	if len(buf) < 2880 || string(buf[:10]) != "SIMPLE  = " {
+		return "", false
+	}
+	vals := make(map[string]string)
+	for i := 0; i+80 <= len(buf); i += 80 {
+		card := string(buf[i : i+80])
+		key := strings.TrimSpace(card[:8])
+		if key == "END" {
+			break
+		}
+		if card[8:10] != "= " {
+			continue
+		}
+		val := strings.TrimSpace(card[10:])
+		// strip comments: a slash following the value (string values are quoted and may contain slashes)
+		start := 0
+		if strings.HasPrefix(val, "'") {
+			if j := strings.IndexByte(val[1:], '\''); j > -1 {
+				start = j + 2
+			}
+		}
+		if j := strings.IndexByte(val[start:], '/'); j > -1 {
+			val = val[:start+j]
+		}
+		vals[key] = strings.TrimSpace(val)
+	}
+	kvs := []string{"simple", vals["SIMPLE"], "bitpix", vals["BITPIX"], "naxis", vals["NAXIS"]}
+	if n, err := strconv.Atoi(vals["NAXIS"]); err == nil && n > 0 && n <= 999 {
+		axes := make([]string, n)
+		for i := range axes {
+			axes[i] = vals["NAXIS"+strconv.Itoa(i+1)]
+		}
+		kvs = append(kvs, "axes", strings.Join(axes, "x"))
+	}
+	if v, ok := vals["EXTEND"]; ok {
+		kvs = append(kvs, "extend", v)
+	}
+	return describe("fits", kvs...), true
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"strconv"
+
+	"github.com/richardlehane/siegfried"
+)
+
+func init() {
+	Register("swf", SWF)
+	Register("flv", FLV)
+}
+
+// SWF probes Shockwave Flash files. It reports the compression variant
+// (none for FWS, zlib for CWS and lzma for ZWS headers), the SWF version and the uncompressed length.
+// For zlib compressed files, the start of the compressed body is inflated to confirm the variant.
+func SWF(c siegfried.Content) (string, bool) {
+	buf, err := c.Slice(0, 8)
+	if err != nil || len(buf) < 8 || buf[1] != 'W' || buf[2] != 'S' {
+		return "", false
+	}
+	var compression string
+	switch buf[0] {
+	case 'F':
+		compression = "none"
+	case 'C':
+		compression = "zlib"
+		// inflate enough of the body to check that the zlib stream is valid
+		body, _ := c.Slice(8, 1024)
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return "", false
+		}
+		if _, err := zr.Read(make([]byte, 8)); err != nil && err != io.EOF {
+			return "", false
+		}
+	case 'Z':
+		compression = "lzma"
+		// ZWS headers are followed by a compressed length and 5 bytes of LZMA properties; the first of these must be < 225
+		props, err := c.Slice(12, 5)
+		if err != nil || props[0] >= 225 {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return describe("swf",
+		"compression", compression,
+		"version", strconv.Itoa(int(buf[3])),
+		"length", strconv.FormatUint(uint64(binary.LittleEndian.Uint32(buf[4:])), 10),
+	), true
+}
+
+// FLV probes Flash Video files. It reports the FLV version and whether audio and video tags are present.
+func FLV(c siegfried.Content) (string, bool) {
+	buf, err := c.Slice(0, 9)
+	if err != nil || len(buf) < 9 || string(buf[:3]) != "FLV" || binary.BigEndian.Uint32(buf[5:]) < 9 {
+		return "", false
+	}
+	return describe("flv",
+		"version", strconv.Itoa(int(buf[3])),
+		"audio", strconv.FormatBool(buf[4]&0x04 == 0x04),
+		"video", strconv.FormatBool(buf[4]&0x01 == 0x01),
+	), true
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/siegfried"
+)
+
+func init() {
+	Register("wacz", WACZ)
+}
+
+// WACZ probes Web Archive Collection Zipped files. It reports the number of WARC files and indexes in the collection and,
+// from the datapackage.json descriptor, the WACZ version, title, creation date and creating software.
+// Writers place the descriptor and the zip central directory at the end of the file, so the probe can read them from the EOF window.
+func WACZ(c siegfried.Content) (string, bool) {
+	if buf, _ := c.Slice(0, 4); string(buf) != "PK\x03\x04" {
+		return "", false
+	}
+	zr, err := zip.NewReader(windowReaderAt{c}, c.SizeNow())
+	if err != nil {
+		return "", false
+	}
+	var warcs, indexes int
+	var pkg *zip.File
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "datapackage.json":
+			pkg = f
+		case strings.HasPrefix(f.Name, "archive/") && (strings.HasSuffix(f.Name, ".warc") || strings.HasSuffix(f.Name, ".warc.gz")):
+			warcs++
+		case strings.HasPrefix(f.Name, "indexes/") && !strings.HasSuffix(f.Name, "/"):
+			indexes++
+		}
+	}
+	if pkg == nil {
+		return "", false
+	}
+	kvs := []string{"warcs", strconv.Itoa(warcs), "indexes", strconv.Itoa(indexes)}
+	if rc, err := pkg.Open(); err == nil {
+		var dp struct {
+			Version  string `json:"wacz_version"`
+			Title    string `json:"title"`
+			Created  string `json:"created"`
+			Software string `json:"software"`
+		}
+		if json.NewDecoder(io.LimitReader(rc, siegfried.WindowSize)).Decode(&dp) == nil {
+			for _, kv := range [][2]string{{"version", dp.Version}, {"title", dp.Title}, {"created", dp.Created}, {"software", dp.Software}} {
+				if kv[1] != "" {
+					kvs = append(kvs, kv[0], kv[1])
+				}
+			}
+		}
+		rc.Close()
+	}
+	return describe("wacz", kvs...), true
+}
+
+// windowReaderAt adapts Content to the io.ReaderAt interface, reading offsets near the end of the content from the EOF window
+type windowReaderAt struct {
+	siegfried.Content
+}
+
+func (w windowReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	sz := w.SizeNow()
+	if off < siegfried.WindowSize || off+int64(len(b)) > sz {
+		buf, err := w.Slice(off, len(b))
+		return copy(b, buf), err
+	}
+	buf, err := w.EofSlice(sz-off-int64(len(b)), len(b))
+	n := copy(b, buf)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
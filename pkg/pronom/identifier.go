@@ -155,6 +155,16 @@ func (i *Identifier) Fields() []string {
 	}
 }
 
+// Formats returns the sorted PUIDs of the formats in the identifier.
+func (i *Identifier) Formats() []string {
+	ret := make([]string, 0, len(i.infos))
+	for k := range i.infos {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 // Recorder provides a new recorder for identification results.
 func (i *Identifier) Recorder() core.Recorder {
 	return &Recorder{
@@ -168,6 +178,8 @@ type Recorder struct {
 	*Identifier
 	ids        pids
 	cscore     int
+	cont       int // score for container matches
+	xml        int // score for XML matches
 	satisfied  bool
 	extActive  bool
 	mimeActive bool
@@ -222,13 +234,28 @@ func (r *Recorder) Record(m core.MatcherType, res core.Result) bool {
 			return false
 		}
 		if hit, id := r.Hit(m, res.Index()); hit {
-			r.cscore += incScore
+			// the container matcher only reports matches that aren't outranked by other matches, so they share a score
+			if r.cont == 0 {
+				r.cscore += incScore
+				r.cont = r.cscore
+			}
 			basis := res.Basis()
 			p, t := r.Place(core.ContainerMatcher, res.Index())
 			if t > 1 {
 				basis = basis + fmt.Sprintf(" (signature %d/%d)", p, t)
 			}
-			r.ids = add(r.ids, r.Name(), id, r.infos[id], basis, r.cscore)
+			r.ids = add(r.ids, r.Name(), id, r.infos[id], basis, r.cont)
+			return true
+		}
+		return false
+	case core.XMLMatcher:
+		if hit, id := r.Hit(m, res.Index()); hit {
+			// XML matches refine any byte matches (e.g. a namespace within generic XML) so they are scored higher and share a score
+			if r.xml == 0 {
+				r.cscore += incScore
+				r.xml = r.cscore
+			}
+			r.ids = add(r.ids, r.Name(), id, r.infos[id], res.Basis(), r.xml)
 			return true
 		}
 		return false
@@ -289,6 +316,10 @@ func (r *Recorder) Satisfied(mt core.MatcherType) (bool, core.Hint) {
 			}
 		}
 	}
+	// run XML signatures, if there are any, even after a byte match
+	if mt == core.XMLMatcher && r.Identifier.Active(core.XMLMatcher) {
+		return false, core.Hint{}
+	}
 	r.satisfied = true
 	if mt == core.ByteMatcher {
 		return true, core.Hint{
@@ -325,6 +356,17 @@ func lowConfidence(conf int) string {
 // Report organizes the results output and lists the highest priority
 // results first.
 func (r *Recorder) Report() []core.Identification {
+	if !config.Why() {
+		return r.report()
+	}
+	cands := append(pids(nil), r.ids...)
+	sort.Sort(cands)
+	return r.explain(r.report(), cands)
+}
+
+func (r *Recorder) report() []core.Identification {
+	// restore the recorded ids (which are pruned below), as a provisional report may be followed by more results
+	defer func(ids []Identification) { r.ids = ids }(r.ids)
 	// no results
 	if len(r.ids) == 0 {
 		if r.hasClass {
@@ -368,8 +410,8 @@ func (r *Recorder) Report() []core.Identification {
 			if v.ID == config.TextPuid() && conf < textScore && r.textActive {
 				continue
 			}
-			// if the match has no corresponding byte or container signature...
-			if ok := r.HasSig(v.ID, core.ContainerMatcher, core.ByteMatcher); !ok {
+			// if the match has no corresponding byte or container signature (or signatures weren't tested, in name-only mode)...
+			if ok := r.HasSig(v.ID, core.ContainerMatcher, core.ByteMatcher); !ok || config.NameOnly() {
 				// break immediately if more than one match
 				if len(nids) > 0 {
 					nids = nids[:0]
@@ -471,6 +513,86 @@ func applyPriorities(pmap priority.Map, ids []core.Identification) []core.Identi
 	return ret
 }
 
+// explain gives the reported identifications explanations of the candidates that weren't reported (see config.Why).
+// A candidate is explained by the identification with priority over it or, otherwise, by the first identification.
+func (r *Recorder) explain(ret []core.Identification, cands pids) []core.Identification {
+	if len(ret) == 0 || !ret[0].Known() {
+		return ret // warnings already explain no match or multiple matches
+	}
+	reported := func(id string) int {
+		for i, v := range ret {
+			if v.String() == id {
+				return i
+			}
+		}
+		return -1
+	}
+	confidence := func(id string) int {
+		for _, v := range cands {
+			if v.ID == id {
+				return v.confidence
+			}
+		}
+		return 0
+	}
+	type group struct {
+		reason string
+		ids    []string
+	}
+	groups := make([][]*group, len(ret)) // candidates with the same reason are grouped for each reported identification
+	for _, c := range cands {
+		if reported(c.ID) >= 0 {
+			continue
+		}
+		var by int
+		for _, sup := range r.priorities[c.ID] {
+			if i := reported(sup); i >= 0 {
+				by = i
+				break
+			}
+		}
+		reason := "priority"
+		if c.confidence <= textScore {
+			reason = "match on " + lowConfidence(c.confidence) + " only"
+			if confidence(ret[by].String()) <= textScore {
+				reason += " and its signatures didn't match"
+			}
+		}
+		var g *group
+		for _, v := range groups[by] {
+			if v.reason == reason {
+				g = v
+				break
+			}
+		}
+		if g == nil {
+			g = &group{reason: reason}
+			groups[by] = append(groups[by], g)
+		}
+		g.ids = append(g.ids, c.ID)
+	}
+	whys := make([][]string, len(ret))
+	for i, gs := range groups {
+		for _, g := range gs {
+			whys[i] = append(whys[i], fmt.Sprintf("%s superseded by %s (%s)", strings.Join(g.ids, ", "), ret[i].String(), g.reason))
+		}
+	}
+	for i, w := range whys {
+		if w == nil {
+			continue
+		}
+		switch id := ret[i].(type) {
+		case Identification:
+			id.why = w
+			ret[i] = id
+		case NoClassIdentification:
+			id.why = w
+			ret[i] = id
+		}
+	}
+	return ret
+}
+
 func (r *Recorder) updateWarning(i Identification) core.Identification {
 	// apply low confidence
 	if i.confidence <= textScore {
@@ -523,6 +645,7 @@ type Identification struct {
 	Warning    string
 	archive    config.Archive
 	confidence int
+	why        []string // candidates suppressed in favour of this identification (see config.Why)
 }
 
 func (id Identification) String() string {
@@ -539,6 +662,21 @@ func (id Identification) Warn() string {
 	return id.Warning
 }
 
+// Bases returns the basis of the identification in a structured form.
+func (id Identification) Bases() []core.Basis {
+	return core.ParseBases(id.Basis)
+}
+
+// Why explains the candidate identifications that were suppressed in favour of this one, if config.Why is set.
+func (id Identification) Why() []string {
+	return id.why
+}
+
+// Codes returns machine-readable codes for the identification's warning.
+func (id Identification) Codes() []string {
+	return core.WarningCodes(id.Warning)
+}
+
 // Values returns the Identification result information to the caller.
 func (id Identification) Values() []string {
 	var basis string
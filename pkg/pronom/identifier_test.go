@@ -0,0 +1,34 @@
+package pronom
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/richardlehane/siegfried/internal/priority"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+func TestExplain(t *testing.T) {
+	r := &Recorder{Identifier: &Identifier{priorities: priority.Map{"fmt/44": {"fmt/645"}}}}
+	cands := pids{
+		{ID: "fmt/645", confidence: 2 * incScore},
+		{ID: "fmt/43", confidence: 2 * incScore},
+		{ID: "fmt/44", confidence: incScore | extScore},
+		{ID: "fmt/41", confidence: extScore},
+		{ID: "fmt/42", confidence: extScore},
+	}
+	ret := r.explain([]core.Identification{Identification{ID: "fmt/43"}, Identification{ID: "fmt/645"}}, cands)
+	if w := core.Why(ret[0]); !reflect.DeepEqual(w, []string{"fmt/41, fmt/42 superseded by fmt/43 (match on extension only)"}) {
+		t.Errorf("unexpected explanations for the first identification: %q", w)
+	}
+	if w := core.Why(ret[1]); !reflect.DeepEqual(w, []string{"fmt/44 superseded by fmt/645 (priority)"}) {
+		t.Errorf("unexpected explanations for the superior identification: %q", w)
+	}
+	ret = r.explain([]core.Identification{Identification{ID: "fmt/41", confidence: extScore}}, pids{{ID: "fmt/41", confidence: extScore}, {ID: "fmt/42", confidence: extScore}})
+	if w := core.Why(ret[0]); !reflect.DeepEqual(w, []string{"fmt/42 superseded by fmt/41 (match on extension only and its signatures didn't match)"}) {
+		t.Errorf("unexpected explanations for an extension only identification: %q", w)
+	}
+	if w := core.Why(r.explain([]core.Identification{Identification{ID: "UNKNOWN"}}, cands)[0]); w != nil {
+		t.Errorf("expecting no explanations for an unknown, got %q", w)
+	}
+}
@@ -105,12 +105,8 @@ func (p *pronom) setParseables() error {
 		p.Parseable = d
 	} else { // otherwise build from reports
 		// get list of puids that applies limit or exclude filters (actual filtering of Parseable delegated to core/identifier)
-		puids := d.IDs()
-		if config.HasLimit() {
-			puids = config.Limit(puids)
-		} else if config.HasExclude() {
-			puids = config.Exclude(puids)
-		}
+		mimes, mids := d.MIMEs()
+		puids := config.Select(d.IDs(), mimes, mids)
 		r, err := newReports(puids, d.idsPuids())
 		if err != nil {
 			return fmt.Errorf("Pronom: error loading reports; got %s\nYou must download PRONOM reports to build a signature (unless you use the -noreports flag). You can use `roy harvest` to download reports", err)
@@ -38,6 +38,12 @@ func next(dec *json.Decoder) ([]string, []string, error) {
 		switch tok := tok.(type) {
 		case string:
 			if i%2 == 0 {
+				if tok == "bases" { // structured bases (see writer.JSONBases) are given in the basis field too
+					if err = skip(dec); err != nil {
+						return nil, nil, err
+					}
+					continue
+				}
 				keys = append(keys, tok)
 			} else {
 				vals = append(vals, tok)
@@ -55,6 +61,28 @@ func next(dec *json.Decoder) ([]string, []string, error) {
 	return nil, nil, err
 }
 
+// skip reads past a value
+func skip(dec *json.Decoder) error {
+	var depth int
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
 func jsonRecord(dec *json.Decoder) (record, error) {
 	keys, vals, err := next(dec)
 	if err != nil {
@@ -0,0 +1,347 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query filters the files in a results file. Create one with NewQuery.
+//
+// A query expression is made of terms joined with "and" and "or" ("and" binds more tightly). Terms can be negated with "not"
+// and grouped with parentheses. A term compares a field with a value:
+//   field = value    equality
+//   field != value   inequality
+//   field ~ pattern  glob match, where * matches any run of characters (including path separators) and ? matches a single character
+//   field !~ pattern negated glob match
+//   field < value    numeric comparison (also <=, > and >=); non-numeric values are compared as strings
+// Values containing spaces or operator characters can be quoted with double quotes.
+//
+// The fields are path (or filename), size (or filesize), modified, errors and the fields reported by the identifiers
+// (e.g. namespace, id, format, version, mime, class, basis, warning). puid is an alias for id.
+// Identifier fields are evaluated for each identification of a file: a file matches if any of its identifications
+// satisfies the whole expression.
+//
+// Example:
+//  id = fmt/276 and warning ~ "*extension mismatch*" and path ~ /archive/2019/*
+type Query struct {
+	root qnode
+}
+
+// NewQuery parses a query expression.
+func NewQuery(expr string) (*Query, error) {
+	toks, err := qlex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &qparser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("query: unexpected %q", p.toks[p.pos].val)
+	}
+	return &Query{n}, nil
+}
+
+// Match reports whether a file, read from a results file with head h, satisfies the query.
+func (q *Query) Match(h Head, f File) bool {
+	fv := fileValues(f)
+	if len(f.IDs) == 0 {
+		return q.root.eval(func(field string) string { return fv(field) })
+	}
+	for _, id := range f.IDs {
+		vals := id.Values()
+		var fields []string
+		for i, ident := range h.Identifiers {
+			if len(vals) > 0 && ident[0] == vals[0] && i < len(h.Fields) {
+				fields = h.Fields[i]
+				break
+			}
+		}
+		lookup := func(field string) string {
+			if field == "puid" {
+				field = "id"
+			}
+			for i, v := range fields {
+				if v == field && i < len(vals) {
+					return vals[i]
+				}
+			}
+			return fv(field)
+		}
+		if q.root.eval(lookup) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileValues(f File) func(string) string {
+	return func(field string) string {
+		switch field {
+		case "path", "filename":
+			return f.Path
+		case "size", "filesize":
+			return strconv.FormatInt(f.Size, 10)
+		case "modified":
+			if f.Mod.IsZero() {
+				return ""
+			}
+			return f.Mod.Format(time.RFC3339)
+		case "errors", "error":
+			if f.Err == nil {
+				return ""
+			}
+			return f.Err.Error()
+		}
+		return ""
+	}
+}
+
+type qnode interface {
+	eval(lookup func(string) string) bool
+}
+
+type qand [2]qnode
+
+func (a qand) eval(l func(string) string) bool { return a[0].eval(l) && a[1].eval(l) }
+
+type qor [2]qnode
+
+func (o qor) eval(l func(string) string) bool { return o[0].eval(l) || o[1].eval(l) }
+
+type qnot struct{ qnode }
+
+func (n qnot) eval(l func(string) string) bool { return !n.qnode.eval(l) }
+
+type qterm struct {
+	field, op, val string
+	glob           *regexp.Regexp
+}
+
+func (t qterm) eval(l func(string) string) bool {
+	v := l(t.field)
+	switch t.op {
+	case "=":
+		return v == t.val
+	case "!=":
+		return v != t.val
+	case "~":
+		return t.glob.MatchString(v)
+	case "!~":
+		return !t.glob.MatchString(v)
+	}
+	var cmp int
+	a, aerr := strconv.ParseFloat(v, 64)
+	b, berr := strconv.ParseFloat(t.val, 64)
+	switch {
+	case aerr == nil && berr == nil:
+		if a < b {
+			cmp = -1
+		} else if a > b {
+			cmp = 1
+		}
+	case v == "": // missing values don't satisfy comparisons
+		return false
+	default:
+		cmp = strings.Compare(v, t.val)
+	}
+	switch t.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+func globRe(pat string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pat {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// lexing
+
+type qtokTyp int
+
+const (
+	tokWord qtokTyp = iota
+	tokOp
+	tokOpen
+	tokClose
+)
+
+type qtoken struct {
+	typ qtokTyp
+	val string
+}
+
+func qlex(expr string) ([]qtoken, error) {
+	var toks []qtoken
+	rs := []rune(expr)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, qtoken{tokOpen, "("})
+			i++
+		case r == ')':
+			toks = append(toks, qtoken{tokClose, ")"})
+			i++
+		case r == '"':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("query: unterminated quote in %s", expr)
+			}
+			toks = append(toks, qtoken{tokWord, sb.String()})
+			i = j + 1
+		case strings.ContainsRune("=!~<>", r):
+			j := i + 1
+			if j < len(rs) && (rs[j] == '=' || (r == '!' && rs[j] == '~')) {
+				j++
+			}
+			op := string(rs[i:j])
+			if op == "!" {
+				return nil, fmt.Errorf("query: bad operator ! in %s", expr)
+			}
+			toks = append(toks, qtoken{tokOp, op})
+			i = j
+		default:
+			j := i
+			for ; j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune("()\"=!~<>", rs[j]); j++ {
+			}
+			toks = append(toks, qtoken{tokWord, string(rs[i:j])})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+// parsing
+
+type qparser struct {
+	toks []qtoken
+	pos  int
+}
+
+func (p *qparser) keyword(kw string) bool {
+	if p.pos < len(p.toks) && p.toks[p.pos].typ == tokWord && strings.EqualFold(p.toks[p.pos].val, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *qparser) or() (qnode, error) {
+	n, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		n = qor{n, r}
+	}
+	return n, nil
+}
+
+func (p *qparser) and() (qnode, error) {
+	n, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		n = qand{n, r}
+	}
+	return n, nil
+}
+
+func (p *qparser) unary() (qnode, error) {
+	if p.keyword("not") {
+		n, err := p.unary()
+		return qnot{n}, err
+	}
+	if p.pos < len(p.toks) && p.toks[p.pos].typ == tokOpen {
+		p.pos++
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.toks) || p.toks[p.pos].typ != tokClose {
+			return nil, fmt.Errorf("query: missing )")
+		}
+		p.pos++
+		return n, nil
+	}
+	if p.pos+3 > len(p.toks) {
+		return nil, fmt.Errorf("query: expecting a term of the form field op value")
+	}
+	f, op, v := p.toks[p.pos], p.toks[p.pos+1], p.toks[p.pos+2]
+	if f.typ != tokWord || op.typ != tokOp || v.typ != tokWord {
+		return nil, fmt.Errorf("query: expecting a term of the form field op value, got %s %s %s", f.val, op.val, v.val)
+	}
+	p.pos += 3
+	t := qterm{field: strings.ToLower(f.val), op: op.val, val: v.val}
+	switch t.op {
+	case "==":
+		t.op = "="
+	case "=", "!=", "~", "!~", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("query: bad operator %s", t.op)
+	}
+	if t.op == "~" || t.op == "!~" {
+		var err error
+		if t.glob, err = globRe(t.val); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
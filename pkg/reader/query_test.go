@@ -0,0 +1,61 @@
+package reader
+
+import (
+	"os"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	for _, bad := range []string{"", "id =", "id ! fmt/1", "(id = fmt/1", "id = \"fmt/1", "id ~= fmt/1"} {
+		if _, err := NewQuery(bad); err == nil {
+			t.Errorf("expecting an error parsing query %q", bad)
+		}
+	}
+	f, err := os.Open("examples/multi/multi.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rdr, err := New(f, "examples/multi/multi.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []File
+	for fi, err := rdr.Next(); err == nil; fi, err = rdr.Next() {
+		files = append(files, fi)
+	}
+	count := func(expr string) int {
+		q, err := NewQuery(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for _, fi := range files {
+			if q.Match(rdr.Head(), fi) {
+				n++
+			}
+		}
+		return n
+	}
+	all := count("size >= 0")
+	if all != len(files) {
+		t.Errorf("expecting all %d files to match, got %d", len(files), all)
+	}
+	if n := count("size < 0"); n != 0 {
+		t.Errorf("expecting no files to match, got %d", n)
+	}
+	words, others := count("puid = x-fmt/44 or puid = x-fmt/43"), count("not (puid = x-fmt/44 or puid = x-fmt/43)")
+	if words == 0 || words+others < all {
+		t.Errorf("bad query results, got %d matches and %d others from %d files", words, others, all)
+	}
+	if n := count(`path ~ "*.doc" and (puid = x-fmt/44 or puid = x-fmt/43)`); n != words {
+		t.Errorf("bad glob query result, expecting %d, got %d", words, n)
+	}
+	if n := count(`path ~ *.gif`); n != 0 {
+		t.Errorf("bad glob query result, expecting 0, got %d", n)
+	}
+	// identifier fields are evaluated per identification
+	if n := count("namespace = loc and puid = x-fmt/44"); n != 0 {
+		t.Errorf("expecting no loc identifications with a PRONOM id, got %d", n)
+	}
+}
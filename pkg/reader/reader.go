@@ -141,13 +141,7 @@ func newFile(path, sz, mod, hash, e string) (File, error) {
 }
 
 func getFile(rec record) (File, error) {
-	var hh string
-	for k := range rec.attributes {
-		if h := checksum.GetHash(k); h >= 0 {
-			hh = k
-			break
-		}
-	}
+	hh := hashKey(rec.attributes)
 	f, err := newFile(rec.attributes["filename"],
 		rec.attributes["filesize"],
 		rec.attributes["modified"],
@@ -184,12 +178,23 @@ func getIdentifiers(vals []string) [][2]string {
 }
 
 func getHash(m map[string]string) string {
+	if k := hashKey(m); k != "" {
+		return checksum.GetHash(k).String()
+	}
+	return ""
+}
+
+// hashKey returns the key of a hash field. If there are several (e.g. sf -hash sha256,md5), the first in the order of the checksum
+// package's hash types (md5 first) is chosen, so that the choice doesn't depend on map order.
+func hashKey(m map[string]string) string {
+	var key string
+	typ := checksum.HashTyp(-1)
 	for k := range m {
-		if h := checksum.GetHash(k); h >= 0 {
-			return h.String()
+		if h := checksum.GetHash(k); h >= 0 && (typ < 0 || h < typ) {
+			key, typ = k, h
 		}
 	}
-	return ""
+	return key
 }
 
 func getFields(keys, vals []string) [][]string {
@@ -262,6 +267,7 @@ func New(rdr io.Reader, path string) (Reader, error) {
 type defaultID struct {
 	id     int
 	warn   int
+	basis  int
 	known  bool
 	values []string
 }
@@ -274,6 +280,12 @@ func (did *defaultID) Warn() string {
 	}
 	return ""
 }
+func (did *defaultID) Bases() []core.Basis {
+	if did.basis > 0 {
+		return core.ParseBases(core.SplitBasis(did.values[did.basis]))
+	}
+	return nil
+}
 func (did *defaultID) Values() []string        { return did.values }
 func (did *defaultID) Archive() config.Archive { return config.None }
 
@@ -290,6 +302,8 @@ func newDefaultID(fields, values []string) *defaultID {
 			}
 		case "warn", "warning":
 			did.warn = i
+		case "basis":
+			did.basis = i
 		}
 	}
 	return did
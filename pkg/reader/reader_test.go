@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"os"
 	"testing"
+
+	"github.com/richardlehane/siegfried/pkg/core"
 )
 
 const (
@@ -88,3 +90,24 @@ func TestCompare(t *testing.T) {
 		t.Fatalf("expecting a complete match; got %s", string(w.Bytes()))
 	}
 }
+
+func TestJSONBases(t *testing.T) {
+	js := `{"siegfried":"1.10.0","scandate":"2023-05-01T00:00:00Z","signature":"default.sig","created":"2023-03-23T15:09:43Z","identifiers":[{"name":"pronom","details":"DROID_SignatureFile_V111.xml"}],"files":[` +
+		`{"filename":"p.pdf","filesize": 23,"modified":"2023-05-01T00:00:00Z","errors": "","matches": [{"ns":"pronom","id":"fmt/18","format":"PDF 1.4","version":"1.4","mime":"application/pdf","basis":"extension match pdf; byte match at [[0 8] [17 5]]","warning":"",` +
+		`"bases":[{"matcher":"extension","match":"pdf","text":"extension match pdf"},{"matcher":"byte","hits":[{"sequence":0,"offset":0,"length":8},{"sequence":1,"offset":17,"length":5}],"text":"byte match at [[0 8] [17 5]]"}]}]}]}`
+	rdr, err := New(bytes.NewReader([]byte(js)), "test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rdr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.IDs) != 1 || f.IDs[0].String() != "fmt/18" || f.IDs[0].Warn() != "" {
+		t.Fatalf("expecting the structured bases to be skipped, got %v", f.IDs)
+	}
+	bases := f.IDs[0].(interface{ Bases() []core.Basis }).Bases()
+	if len(bases) != 2 || bases[1].Hits[1].Offset != 17 {
+		t.Errorf("expecting bases from the basis field, got %v", bases)
+	}
+}
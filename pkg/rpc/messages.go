@@ -0,0 +1,392 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpc
+
+import (
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of siegfried.proto. They are encoded in the protocol buffers wire format by their marshal and unmarshal methods,
+// so there is no generated code. Default (zero) values aren't encoded, as in proto3.
+
+// IdentifyRequest is the content of a single file to identify.
+type IdentifyRequest struct {
+	Name    string // filename (optional)
+	MIME    string // MIME type (optional)
+	Content []byte
+}
+
+// FileChunk is part of the content of a file sent to IdentifyStream.
+type FileChunk struct {
+	Name    string // filename (optional, only read from the first chunk of a file)
+	MIME    string // MIME type (optional, only read from the first chunk of a file)
+	Content []byte
+	Last    bool // set on the last chunk of a file
+}
+
+// IdentifyResponse reports the identifications for a file.
+type IdentifyResponse struct {
+	Name    string
+	Size    int64
+	Error   string // a non-fatal error e.g. for an empty file
+	Matches []*Identification
+}
+
+// Identification is a single identification result.
+type Identification struct {
+	Namespace string
+	ID        string
+	Format    string
+	Version   string
+	MIME      string
+	Basis     string
+	Warning   string
+	Known     bool
+	Fields    map[string]string // all fields reported by the identifier, by label
+}
+
+// VersionRequest has no fields.
+type VersionRequest struct{}
+
+// VersionResponse reports the siegfried version and the loaded signature file.
+type VersionResponse struct {
+	Version     string
+	Signature   string
+	Created     string
+	Identifiers []*Identifier
+}
+
+// Identifier is an identifier in the loaded signature file e.g. pronom.
+type Identifier struct {
+	Name    string
+	Details string
+}
+
+type message interface {
+	marshal(b []byte) []byte
+	unmarshal(b []byte) error
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	return appendVarint(b, num, protowire.EncodeBool(v))
+}
+
+func appendMessage(b []byte, num protowire.Number, m message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.marshal(nil))
+}
+
+// consume calls fn for each field of an encoded message. fn returns the length of the field's value that it consumed,
+// a negative length (a protowire error) if the value is malformed, or 0 for fields that aren't known (or have the wrong type),
+// which are skipped.
+func consume(b []byte, fn func(protowire.Number, protowire.Type, []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n = fn(num, typ, b)
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumeString(typ protowire.Type, b []byte, s *string) int {
+	if typ != protowire.BytesType {
+		return 0
+	}
+	v, n := protowire.ConsumeString(b)
+	*s = v
+	return n
+}
+
+func consumeBytes(typ protowire.Type, b []byte, v *[]byte) int {
+	if typ != protowire.BytesType {
+		return 0
+	}
+	bs, n := protowire.ConsumeBytes(b)
+	*v = append([]byte(nil), bs...) // copy, as the encoded message may be reused
+	return n
+}
+
+func consumeVarint(typ protowire.Type, b []byte, v *uint64) int {
+	if typ != protowire.VarintType {
+		return 0
+	}
+	u, n := protowire.ConsumeVarint(b)
+	*v = u
+	return n
+}
+
+func consumeBool(typ protowire.Type, b []byte, v *bool) int {
+	var u uint64
+	n := consumeVarint(typ, b, &u)
+	*v = protowire.DecodeBool(u)
+	return n
+}
+
+func consumeMessage(typ protowire.Type, b []byte, m message) int {
+	if typ != protowire.BytesType {
+		return 0
+	}
+	bs, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n
+	}
+	if err := m.unmarshal(bs); err != nil {
+		return -1
+	}
+	return n
+}
+
+func (r *IdentifyRequest) marshal(b []byte) []byte {
+	b = appendString(b, 1, r.Name)
+	b = appendString(b, 2, r.MIME)
+	return appendBytes(b, 3, r.Content)
+}
+
+func (r *IdentifyRequest) unmarshal(b []byte) error {
+	return consume(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &r.Name)
+		case 2:
+			return consumeString(typ, b, &r.MIME)
+		case 3:
+			return consumeBytes(typ, b, &r.Content)
+		}
+		return 0
+	})
+}
+
+func (c *FileChunk) marshal(b []byte) []byte {
+	b = appendString(b, 1, c.Name)
+	b = appendString(b, 2, c.MIME)
+	b = appendBytes(b, 3, c.Content)
+	return appendBool(b, 4, c.Last)
+}
+
+func (c *FileChunk) unmarshal(b []byte) error {
+	return consume(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &c.Name)
+		case 2:
+			return consumeString(typ, b, &c.MIME)
+		case 3:
+			return consumeBytes(typ, b, &c.Content)
+		case 4:
+			return consumeBool(typ, b, &c.Last)
+		}
+		return 0
+	})
+}
+
+func (r *IdentifyResponse) marshal(b []byte) []byte {
+	b = appendString(b, 1, r.Name)
+	b = appendVarint(b, 2, uint64(r.Size))
+	b = appendString(b, 3, r.Error)
+	for _, m := range r.Matches {
+		b = appendMessage(b, 4, m)
+	}
+	return b
+}
+
+func (r *IdentifyResponse) unmarshal(b []byte) error {
+	return consume(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &r.Name)
+		case 2:
+			var u uint64
+			n := consumeVarint(typ, b, &u)
+			r.Size = int64(u)
+			return n
+		case 3:
+			return consumeString(typ, b, &r.Error)
+		case 4:
+			m := &Identification{}
+			n := consumeMessage(typ, b, m)
+			if n > 0 {
+				r.Matches = append(r.Matches, m)
+			}
+			return n
+		}
+		return 0
+	})
+}
+
+// a map entry is encoded as a message with key and value fields
+type entry struct {
+	key, value string
+}
+
+func (e *entry) marshal(b []byte) []byte {
+	b = appendString(b, 1, e.key)
+	return appendString(b, 2, e.value)
+}
+
+func (e *entry) unmarshal(b []byte) error {
+	return consume(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &e.key)
+		case 2:
+			return consumeString(typ, b, &e.value)
+		}
+		return 0
+	})
+}
+
+func (id *Identification) marshal(b []byte) []byte {
+	b = appendString(b, 1, id.Namespace)
+	b = appendString(b, 2, id.ID)
+	b = appendString(b, 3, id.Format)
+	b = appendString(b, 4, id.Version)
+	b = appendString(b, 5, id.MIME)
+	b = appendString(b, 6, id.Basis)
+	b = appendString(b, 7, id.Warning)
+	b = appendBool(b, 8, id.Known)
+	keys := make([]string, 0, len(id.Fields))
+	for k := range id.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // for a deterministic encoding
+	for _, k := range keys {
+		b = appendMessage(b, 9, &entry{k, id.Fields[k]})
+	}
+	return b
+}
+
+func (id *Identification) unmarshal(b []byte) error {
+	return consume(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &id.Namespace)
+		case 2:
+			return consumeString(typ, b, &id.ID)
+		case 3:
+			return consumeString(typ, b, &id.Format)
+		case 4:
+			return consumeString(typ, b, &id.Version)
+		case 5:
+			return consumeString(typ, b, &id.MIME)
+		case 6:
+			return consumeString(typ, b, &id.Basis)
+		case 7:
+			return consumeString(typ, b, &id.Warning)
+		case 8:
+			return consumeBool(typ, b, &id.Known)
+		case 9:
+			e := &entry{}
+			n := consumeMessage(typ, b, e)
+			if n > 0 {
+				if id.Fields == nil {
+					id.Fields = make(map[string]string)
+				}
+				id.Fields[e.key] = e.value
+			}
+			return n
+		}
+		return 0
+	})
+}
+
+func (r *VersionRequest) marshal(b []byte) []byte { return b }
+
+func (r *VersionRequest) unmarshal(b []byte) error {
+	return consume(b, func(protowire.Number, protowire.Type, []byte) int { return 0 })
+}
+
+func (r *VersionResponse) marshal(b []byte) []byte {
+	b = appendString(b, 1, r.Version)
+	b = appendString(b, 2, r.Signature)
+	b = appendString(b, 3, r.Created)
+	for _, i := range r.Identifiers {
+		b = appendMessage(b, 4, i)
+	}
+	return b
+}
+
+func (r *VersionResponse) unmarshal(b []byte) error {
+	return consume(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &r.Version)
+		case 2:
+			return consumeString(typ, b, &r.Signature)
+		case 3:
+			return consumeString(typ, b, &r.Created)
+		case 4:
+			i := &Identifier{}
+			n := consumeMessage(typ, b, i)
+			if n > 0 {
+				r.Identifiers = append(r.Identifiers, i)
+			}
+			return n
+		}
+		return 0
+	})
+}
+
+func (i *Identifier) marshal(b []byte) []byte {
+	b = appendString(b, 1, i.Name)
+	return appendString(b, 2, i.Details)
+}
+
+func (i *Identifier) unmarshal(b []byte) error {
+	return consume(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch num {
+		case 1:
+			return consumeString(typ, b, &i.Name)
+		case 2:
+			return consumeString(typ, b, &i.Details)
+		}
+		return 0
+	})
+}
@@ -0,0 +1,269 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpc provides a gRPC identification service for siegfried, as defined in siegfried.proto.
+//
+// Example:
+//
+//	gs := grpc.NewServer(grpc.ForceServerCodec(rpc.Codec))
+//	rpc.New(s).Register(gs)
+//	gs.Serve(lis)
+//
+// Go clients can call the service with the same codec (grpc.ForceCodec(rpc.Codec)); clients in other languages can
+// generate their stubs from siegfried.proto.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// Codec encodes the messages of the siegfried service. Other (generated) protocol buffer messages are encoded
+// with the proto package, so services like grpc health checking can be registered on the same server.
+var Codec encoding.Codec = codec{}
+
+type codec struct{}
+
+func (codec) Name() string { return "proto" }
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case message:
+		return m.marshal(nil), nil
+	case proto.Message:
+		return proto.Marshal(m)
+	}
+	return nil, fmt.Errorf("rpc: can't marshal %T", v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	switch m := v.(type) {
+	case message:
+		return m.unmarshal(data)
+	case proto.Message:
+		return proto.Unmarshal(data, m)
+	}
+	return fmt.Errorf("rpc: can't unmarshal %T", v)
+}
+
+// Server implements the siegfried service.
+type Server struct {
+	mu sync.RWMutex
+	sf *siegfried.Siegfried
+}
+
+// New creates a server for a loaded siegfried.
+func New(s *siegfried.Siegfried) *Server {
+	return &Server{sf: s}
+}
+
+// Swap replaces the server's siegfried e.g. with one loaded from an updated signature file.
+// Calls already in progress finish with the old siegfried.
+func (s *Server) Swap(sf *siegfried.Siegfried) {
+	s.mu.Lock()
+	s.sf = sf
+	s.mu.Unlock()
+}
+
+func (s *Server) current() *siegfried.Siegfried {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sf
+}
+
+// Register registers the siegfried service on a grpc server. The server must use Codec (grpc.ForceServerCodec(rpc.Codec)).
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, s)
+}
+
+// Identify identifies the content of a single file.
+func (s *Server) Identify(ctx context.Context, req *IdentifyRequest) (*IdentifyResponse, error) {
+	sf := s.current()
+	ids, err := sf.IdentifyContext(ctx, bytes.NewReader(req.Content), req.Name, req.MIME)
+	if ids == nil && err != nil {
+		return nil, err
+	}
+	return response(sf, req.Name, int64(len(req.Content)), ids, err), nil
+}
+
+// IdentifyStream identifies a stream of files, each sent as one or more chunks (the last with Last set).
+// A response is sent after the last chunk of each file. Chunks are piped to the identifier as they arrive,
+// so a client that sends faster than files can be identified is held back by the stream's flow control.
+func (s *Server) IdentifyStream(stream grpc.ServerStream) error {
+	for {
+		chunk := &FileChunk{}
+		if err := stream.RecvMsg(chunk); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		resp, err := s.identifyChunks(stream, chunk)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+}
+
+type result struct {
+	ids []core.Identification
+	err error
+}
+
+// identifyChunks identifies a file that starts with chunk, receiving its remaining chunks from the stream.
+func (s *Server) identifyChunks(stream grpc.ServerStream, chunk *FileChunk) (*IdentifyResponse, error) {
+	name, mime, sf := chunk.Name, chunk.MIME, s.current()
+	pr, pw := io.Pipe()
+	done := make(chan result, 1)
+	go func() {
+		ids, err := sf.IdentifyContext(stream.Context(), pr, name, mime)
+		io.Copy(io.Discard, pr) // identification may finish before the file has been read to the end
+		done <- result{ids, err}
+	}()
+	var size int64
+	for {
+		if _, err := pw.Write(chunk.Content); err != nil {
+			break // the identifier has failed: its error is reported in the response
+		}
+		size += int64(len(chunk.Content))
+		if chunk.Last {
+			break
+		}
+		chunk = &FileChunk{}
+		if err := stream.RecvMsg(chunk); err != nil {
+			if err == io.EOF {
+				err = errors.New("rpc: stream closed before the last chunk of " + name)
+			}
+			pw.CloseWithError(err)
+			<-done
+			return nil, err
+		}
+	}
+	pw.Close()
+	res := <-done
+	if res.ids == nil && res.err != nil {
+		return &IdentifyResponse{Name: name, Size: size, Error: res.err.Error()}, nil
+	}
+	return response(sf, name, size, res.ids, res.err), nil
+}
+
+// Version reports the siegfried version and the loaded signature file.
+func (s *Server) Version(ctx context.Context, req *VersionRequest) (*VersionResponse, error) {
+	v, sf := config.Version(), s.current()
+	resp := &VersionResponse{
+		Version:   fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2]),
+		Signature: config.SignatureBase(),
+		Created:   sf.C.Format(time.RFC3339),
+	}
+	for _, i := range sf.Identifiers() {
+		resp.Identifiers = append(resp.Identifiers, &Identifier{Name: i[0], Details: i[1]})
+	}
+	return resp, nil
+}
+
+func response(sf *siegfried.Siegfried, name string, size int64, ids []core.Identification, err error) *IdentifyResponse {
+	resp := &IdentifyResponse{Name: name, Size: size, Matches: make([]*Identification, len(ids))}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	for i, id := range ids {
+		m := &Identification{ID: id.String(), Warning: id.Warn(), Known: id.Known(), Fields: make(map[string]string)}
+		for _, f := range sf.Label(id) {
+			m.Fields[f[0]] = f[1]
+			switch f[0] {
+			case "namespace":
+				m.Namespace = f[1]
+			case "format":
+				m.Format = f[1]
+			case "version":
+				m.Version = f[1]
+			case "mime":
+				m.MIME = f[1]
+			case "basis":
+				m.Basis = f[1]
+			}
+		}
+		resp.Matches[i] = m
+	}
+	return resp
+}
+
+// the service descriptor that protoc-gen-go-grpc would generate from siegfried.proto
+
+type siegfriedServer interface {
+	Identify(context.Context, *IdentifyRequest) (*IdentifyResponse, error)
+	IdentifyStream(grpc.ServerStream) error
+	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "siegfried.Siegfried",
+	HandlerType: (*siegfriedServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Identify", Handler: identifyHandler},
+		{MethodName: "Version", Handler: versionHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "IdentifyStream", Handler: identifyStreamHandler, ServerStreams: true, ClientStreams: true},
+	},
+	Metadata: "siegfried.proto",
+}
+
+func identifyHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &IdentifyRequest{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(siegfriedServer).Identify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/siegfried.Siegfried/Identify"}
+	return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(siegfriedServer).Identify(ctx, req.(*IdentifyRequest))
+	})
+}
+
+func versionHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &VersionRequest{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(siegfriedServer).Version(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/siegfried.Siegfried/Version"}
+	return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(siegfriedServer).Version(ctx, req.(*VersionRequest))
+	})
+}
+
+func identifyStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(siegfriedServer).IdentifyStream(stream)
+}
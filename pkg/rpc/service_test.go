@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/richardlehane/siegfried"
+	"github.com/richardlehane/siegfried/pkg/config"
+	"github.com/richardlehane/siegfried/pkg/pronom"
+)
+
+func TestMessages(t *testing.T) {
+	in := &IdentifyResponse{
+		Name:  "test.pdf",
+		Size:  1 << 40,
+		Error: "a warning",
+		Matches: []*Identification{
+			{Namespace: "pronom", ID: "fmt/18", Known: true, Fields: map[string]string{"id": "fmt/18", "format": "Acrobat PDF 1.4"}},
+			{ID: "UNKNOWN"},
+		},
+	}
+	out := &IdentifyResponse{}
+	if err := out.unmarshal(in.marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != in.Name || out.Size != in.Size || out.Error != in.Error || len(out.Matches) != 2 {
+		t.Fatalf("bad roundtrip, got %+v", out)
+	}
+	if m := out.Matches[0]; m.ID != "fmt/18" || !m.Known || m.Fields["format"] != "Acrobat PDF 1.4" || len(m.Fields) != 2 {
+		t.Errorf("bad roundtrip, got %+v", m)
+	}
+	if m := out.Matches[1]; m.ID != "UNKNOWN" || m.Known || m.Fields != nil {
+		t.Errorf("bad roundtrip, got %+v", m)
+	}
+	// unknown fields are skipped
+	chunk := &FileChunk{}
+	if err := chunk.unmarshal(append(out.marshal(nil), (&FileChunk{Last: true}).marshal(nil)...)); err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Name != "test.pdf" || !chunk.Last {
+		t.Errorf("bad decode, got %+v", chunk)
+	}
+	if err := chunk.unmarshal([]byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Error("expecting an error for a truncated message")
+	}
+}
+
+func testClient(t *testing.T) (*grpc.ClientConn, *Server) {
+	s := siegfried.New()
+	config.SetHome("../../cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer(grpc.ForceServerCodec(Codec))
+	srv := New(s)
+	srv.Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(Codec)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, srv
+}
+
+func TestService(t *testing.T) {
+	pdf, err := os.ReadFile("../../cmd/sf/testdata/benchmark/Benchmark.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, srv := testClient(t)
+	ctx := context.Background()
+	// Identify
+	resp := &IdentifyResponse{}
+	if err := conn.Invoke(ctx, "/siegfried.Siegfried/Identify", &IdentifyRequest{Name: "Benchmark.pdf", Content: pdf}, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Size != int64(len(pdf)) || len(resp.Matches) != 1 || resp.Matches[0].ID != "fmt/18" || resp.Matches[0].Namespace != "pronom" {
+		t.Fatalf("bad Identify response, got %+v", resp)
+	}
+	if m := resp.Matches[0]; !m.Known || m.Format == "" || m.MIME != "application/pdf" || m.Fields["id"] != "fmt/18" {
+		t.Errorf("bad Identify response, got %+v", m)
+	}
+	// IdentifyStream: the PDF in chunks, then an empty file
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/siegfried.Siegfried/IdentifyStream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(pdf); i += 1000 {
+		chunk := &FileChunk{Content: pdf[i:], Last: true}
+		if i+1000 < len(pdf) {
+			chunk.Content, chunk.Last = pdf[i:i+1000], false
+		}
+		if i == 0 {
+			chunk.Name = "Benchmark.pdf"
+		}
+		if err := stream.SendMsg(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.SendMsg(&FileChunk{Name: "empty", Last: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	resp = &IdentifyResponse{}
+	if err := stream.RecvMsg(resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "Benchmark.pdf" || resp.Size != int64(len(pdf)) || len(resp.Matches) != 1 || resp.Matches[0].ID != "fmt/18" {
+		t.Fatalf("bad IdentifyStream response, got %+v", resp)
+	}
+	resp = &IdentifyResponse{}
+	if err := stream.RecvMsg(resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "empty" || resp.Size != 0 || resp.Error == "" {
+		t.Fatalf("expecting an error for an empty file, got %+v", resp)
+	}
+	// Version
+	vresp := &VersionResponse{}
+	if err := conn.Invoke(ctx, "/siegfried.Siegfried/Version", &VersionRequest{}, vresp); err != nil {
+		t.Fatal(err)
+	}
+	if vresp.Version == "" || vresp.Created == "" || len(vresp.Identifiers) != 1 || vresp.Identifiers[0].Name != "pronom" {
+		t.Errorf("bad Version response, got %+v", vresp)
+	}
+	// Swap: later calls use the new siegfried
+	srv.Swap(siegfried.New())
+	vresp = &VersionResponse{}
+	if err := conn.Invoke(ctx, "/siegfried.Siegfried/Version", &VersionRequest{}, vresp); err != nil {
+		t.Fatal(err)
+	}
+	if len(vresp.Identifiers) != 0 {
+		t.Errorf("expecting no identifiers after a swap, got %+v", vresp)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer(append(TokenAuth("secret"), grpc.ForceServerCodec(Codec))...)
+	New(siegfried.New()).Register(gs)
+	go gs.Serve(lis)
+	defer gs.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(Codec)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	err = conn.Invoke(context.Background(), "/siegfried.Siegfried/Version", &VersionRequest{}, &VersionResponse{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expecting an unauthenticated error without a token, got %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	if err := conn.Invoke(ctx, "/siegfried.Siegfried/Version", &VersionRequest{}, &VersionResponse{}); err != nil {
+		t.Errorf("expecting a call with the token to succeed, got %v", err)
+	}
+	stream, err := conn.NewStream(context.Background(), &serviceDesc.Streams[0], "/siegfried.Siegfried/IdentifyStream")
+	if err == nil {
+		err = stream.RecvMsg(&IdentifyResponse{})
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expecting an unauthenticated error for a stream without a token, got %v", err)
+	}
+}
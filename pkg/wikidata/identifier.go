@@ -180,6 +180,16 @@ func (id Identification) Warn() string {
 	return id.Warning
 }
 
+// Bases returns the basis of the identification in a structured form.
+func (id Identification) Bases() []core.Basis {
+	return core.ParseBases(id.Basis)
+}
+
+// Codes returns machine-readable codes for the warning associated with an identification.
+func (id Identification) Codes() []string {
+	return core.WarningCodes(id.Warning)
+}
+
 // Values returns a string slice containing each of the identifier segments.
 func (id Identification) Values() []string {
 	var basis string
@@ -54,6 +54,7 @@ type Recorder struct {
 	*Identifier
 	ids        matchIDs
 	cscore     int
+	cont       int // score for container matches
 	satisfied  bool
 	extActive  bool
 	mimeActive bool
@@ -197,7 +198,11 @@ func recordContainerMatcher(recorder *Recorder, matcher core.MatcherType, result
 		return false
 	}
 	if hit, id := recorder.Hit(matcher, result.Index()); hit {
-		recorder.cscore += incScore
+		// container matches that aren't outranked share a score
+		if recorder.cont == 0 {
+			recorder.cscore += incScore
+			recorder.cont = recorder.cscore
+		}
 		basis := result.Basis()
 		position, total := recorder.Place(
 			core.ContainerMatcher, result.Index(),
@@ -230,7 +235,7 @@ func recordContainerMatcher(recorder *Recorder, matcher core.MatcherType, result
 			id,
 			recorder.infos[id],
 			basis,
-			recorder.cscore,
+			recorder.cont,
 		)
 		return true
 	}
@@ -0,0 +1,128 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+type fidoWriter struct {
+	w      *bufio.Writer
+	fields map[string]map[string]int // for each identifier, the index of each field
+	last   time.Time
+}
+
+// Fido returns a writer that mimics the CSV output of fido with its default -matchprintf and -nomatchprintf:
+//
+//	OK,time,puid,"format name","signature name",size,"filename","mimetype","matchtype"
+//	KO,time,,,,size,"filename",,"fail"
+//
+// There is a line for each known identification of a file. The matchtype is container, signature or extension, according to the basis of the match.
+// As sf doesn't name the signatures that match, the signature name is the format name (or "External" for extension matches, like fido).
+// The time is the milliseconds elapsed since the previous file was written (or the time given in replayed fido results).
+func Fido(w io.Writer) Writer {
+	return &fidoWriter{w: bufio.NewWriter(w)}
+}
+
+func (f *fidoWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	f.fields = make(map[string]map[string]int, len(ids))
+	for i, fs := range fields {
+		if i >= len(ids) {
+			break
+		}
+		m := make(map[string]int, len(fs))
+		for j, v := range fs {
+			m[v] = j
+		}
+		f.fields[ids[i][0]] = m
+	}
+	f.last = time.Now()
+}
+
+func (f *fidoWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	if sz < 0 {
+		return
+	}
+	now := time.Now()
+	t := strconv.FormatInt(now.Sub(f.last).Milliseconds(), 10)
+	f.last = now
+	if len(ids) > 0 {
+		if v := f.value(ids[0].Values(), "time"); v != "" {
+			t = v
+		}
+	}
+	var ok bool
+	for _, id := range ids {
+		if !id.Known() {
+			continue
+		}
+		ok = true
+		values := id.Values()
+		format, mime := f.value(values, "format"), f.value(values, "mime")
+		if mime == "" {
+			mime = "None"
+		}
+		match := fidoMatch(f.value(values, "basis"))
+		sig := f.value(values, "full") // replayed fido results keep their signature names
+		if sig == "" {
+			sig = format
+			if match == "extension" {
+				sig = "External"
+			}
+		}
+		fmt.Fprintf(f.w, "OK,%s,%s,%s,%s,%d,%s,%s,%s\n", t, id.String(), fidoQuote(format), fidoQuote(sig), sz, fidoQuote(name), fidoQuote(mime), fidoQuote(match))
+	}
+	if !ok {
+		fmt.Fprintf(f.w, "KO,%s,,,,%d,%s,,\"fail\"\n", t, sz, fidoQuote(name))
+	}
+}
+
+// value returns the value of a named field of an identification, or an empty string if the identifier has no such field
+func (f *fidoWriter) value(values []string, field string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if i, ok := f.fields[values[0]][field]; ok && i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+// fidoMatch gives fido's matchtype for the basis of a match
+func fidoMatch(basis string) string {
+	switch {
+	case basis == "signature", basis == "extension": // replayed fido results
+		return basis
+	case strings.Contains(basis, "container"):
+		return "container"
+	case strings.Contains(basis, "byte match"), strings.Contains(basis, "xml match"), strings.Contains(basis, "riff match"), strings.Contains(basis, "text match"):
+		return "signature"
+	}
+	return "extension"
+}
+
+// fidoQuote quotes a field like fido does, but doubles any quotes so that the output is valid CSV
+func fidoQuote(s string) string {
+	return "\"" + strings.ReplaceAll(s, "\"", "\"\"") + "\""
+}
+
+func (f *fidoWriter) Tail() { f.w.Flush() }
@@ -0,0 +1,255 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// PREMIS digest algorithm names for sf's hash choices, from the Library of Congress cryptographicHashFunctions vocabulary
+var premisDigests = map[string]string{
+	"md5":     "MD5",
+	"sha1":    "SHA-1",
+	"sha256":  "SHA-256",
+	"sha512":  "SHA-512",
+	"crc":     "CRC32",
+	"blake2b": "BLAKE2b-512",
+}
+
+// registry names for the default identifier names, other identifiers are reported under their own names
+var premisRegistries = map[string]string{
+	"pronom":   "PRONOM",
+	"loc":      "Library of Congress Format Descriptions",
+	"wikidata": "Wikidata",
+}
+
+type premisWriter struct {
+	w       *bufio.Writer
+	mets    bool
+	version [3]int
+	sig     string
+	details [][2]string
+	names   []string         // identifier names, in order
+	fields  []map[string]int // for each identifier, the index of each field
+	digest  string
+	scanned time.Time
+	files   int
+	fileSec *bytes.Buffer // METS file and structMap entries are written at Tail, after the amdSecs
+	structs *bytes.Buffer
+}
+
+// PREMIS returns a writer that emits a PREMIS 3 XML document with an object entry for each file. Objects record the file's size,
+// fixity (if a hash is calculated) and a format entry, with a format registry key, for each identification.
+// The siegfried version and signature file are given in an agent entry.
+// If mets is true, the objects are wrapped in a METS document: each object is the techMD of an amdSec and is linked
+// to a file entry in the fileSec.
+func PREMIS(w io.Writer, mets bool) Writer {
+	return &premisWriter{
+		w:       bufio.NewWriter(w),
+		mets:    mets,
+		fileSec: &bytes.Buffer{},
+		structs: &bytes.Buffer{},
+	}
+}
+
+func (p *premisWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	p.version, p.sig, p.details, p.scanned = version, filepath.Base(path), ids, scanned
+	p.digest = premisDigests[hh]
+	p.names = make([]string, len(ids))
+	p.fields = make([]map[string]int, len(fields))
+	for i, f := range fields {
+		if i < len(ids) {
+			p.names[i] = ids[i][0]
+		}
+		p.fields[i] = make(map[string]int, len(f))
+		for j, v := range f {
+			p.fields[i][v] = j
+		}
+	}
+	p.w.WriteString(xml.Header)
+	if p.mets {
+		fmt.Fprintf(p.w, "<mets:mets xmlns:mets=\"http://www.loc.gov/METS/\" xmlns:premis=\"http://www.loc.gov/premis/v3\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:schemaLocation=\"http://www.loc.gov/METS/ http://www.loc.gov/standards/mets/mets.xsd http://www.loc.gov/premis/v3 http://www.loc.gov/standards/premis/v3/premis.xsd\">\n"+
+			"  <mets:metsHdr CREATEDATE=\"%s\">\n    <mets:agent ROLE=\"CREATOR\" TYPE=\"OTHER\" OTHERTYPE=\"SOFTWARE\">\n      <mets:name>%s</mets:name>\n    </mets:agent>\n  </mets:metsHdr>\n",
+			scanned.Format(time.RFC3339), p.agentName())
+		return
+	}
+	p.w.WriteString("<premis:premis xmlns:premis=\"http://www.loc.gov/premis/v3\" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:schemaLocation=\"http://www.loc.gov/premis/v3 http://www.loc.gov/standards/premis/v3/premis.xsd\" version=\"3.0\">\n")
+}
+
+func (p *premisWriter) agentName() string {
+	return fmt.Sprintf("siegfried %d.%d.%d", p.version[0], p.version[1], p.version[2])
+}
+
+// directories (negative sz) aren't PREMIS file objects so are skipped
+func (p *premisWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	if sz < 0 {
+		return
+	}
+	p.files++
+	indent := "  "
+	if p.mets {
+		indent = "          "
+		fmt.Fprintf(p.w, "  <mets:amdSec ID=\"amd_%d\">\n    <mets:techMD ID=\"tech_%d\">\n      <mets:mdWrap MDTYPE=\"PREMIS:OBJECT\">\n        <mets:xmlData>\n", p.files, p.files)
+	}
+	fmt.Fprintf(p.w, "%s<premis:object xsi:type=\"premis:file\"", indent)
+	if p.mets {
+		p.w.WriteString(" version=\"3.0\"")
+	}
+	p.w.WriteString(">\n")
+	p.element(indent+"  ", "objectIdentifier", "")
+	p.element(indent+"    ", "objectIdentifierType", "local")
+	p.element(indent+"    ", "objectIdentifierValue", name)
+	p.close(indent+"  ", "objectIdentifier")
+	p.element(indent+"  ", "objectCharacteristics", "")
+	p.element(indent+"    ", "compositionLevel", "0")
+	if checksum != nil && p.digest != "" {
+		p.element(indent+"    ", "fixity", "")
+		p.element(indent+"      ", "messageDigestAlgorithm", p.digest)
+		p.element(indent+"      ", "messageDigest", hex.EncodeToString(checksum))
+		p.element(indent+"      ", "messageDigestOriginator", "siegfried")
+		p.close(indent+"    ", "fixity")
+	}
+	p.element(indent+"    ", "size", strconv.FormatInt(sz, 10))
+	for _, id := range ids {
+		p.format(indent+"    ", id, err)
+		err = nil // only report errors once
+	}
+	if len(ids) == 0 {
+		p.element(indent+"    ", "format", "")
+		p.element(indent+"      ", "formatDesignation", "")
+		p.element(indent+"        ", "formatName", "UNKNOWN")
+		p.close(indent+"      ", "formatDesignation")
+		if err != nil {
+			p.element(indent+"      ", "formatNote", "error: "+err.Error())
+		}
+		p.close(indent+"    ", "format")
+	}
+	p.close(indent+"  ", "objectCharacteristics")
+	p.element(indent+"  ", "originalName", name)
+	fmt.Fprintf(p.w, "%s</premis:object>\n", indent)
+	if !p.mets {
+		return
+	}
+	p.w.WriteString("        </mets:xmlData>\n      </mets:mdWrap>\n    </mets:techMD>\n  </mets:amdSec>\n")
+	fmt.Fprintf(p.fileSec, "      <mets:file ID=\"file_%d\" ADMID=\"amd_%d\" SIZE=\"%d\"", p.files, p.files, sz)
+	if checksum != nil && p.digest != "" {
+		fmt.Fprintf(p.fileSec, " CHECKSUM=\"%s\" CHECKSUMTYPE=\"%s\"", hex.EncodeToString(checksum), p.digest)
+	}
+	fmt.Fprintf(p.fileSec, ">\n        <mets:FLocat LOCTYPE=\"OTHER\" OTHERLOCTYPE=\"SYSTEM\" xlink:href=\"%s\"/>\n      </mets:file>\n", escapeXML(name))
+	fmt.Fprintf(p.structs, "      <mets:div TYPE=\"Item\" LABEL=\"%s\">\n        <mets:fptr FILEID=\"file_%d\"/>\n      </mets:div>\n", escapeXML(filepath.Base(name)), p.files)
+}
+
+// format writes a premis format entry for an identification. Known identifications have a format registry key.
+func (p *premisWriter) format(indent string, id core.Identification, err error) {
+	values := id.Values()
+	var fields map[string]int
+	for i, n := range p.names {
+		if len(values) > 0 && values[0] == n {
+			fields = p.fields[i]
+			break
+		}
+	}
+	value := func(field string) string {
+		if i, ok := fields[field]; ok && i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+	name := value("format")
+	if name == "" {
+		name = id.String()
+	}
+	p.element(indent, "format", "")
+	p.element(indent+"  ", "formatDesignation", "")
+	p.element(indent+"    ", "formatName", name)
+	if v := value("version"); v != "" {
+		p.element(indent+"    ", "formatVersion", v)
+	}
+	p.close(indent+"  ", "formatDesignation")
+	if id.Known() && len(values) > 0 {
+		registry := values[0]
+		if r, ok := premisRegistries[registry]; ok {
+			registry = r
+		}
+		p.element(indent+"  ", "formatRegistry", "")
+		p.element(indent+"    ", "formatRegistryName", registry)
+		p.element(indent+"    ", "formatRegistryKey", id.String())
+		p.element(indent+"    ", "formatRegistryRole", "identification")
+		p.close(indent+"  ", "formatRegistry")
+	}
+	for _, f := range []string{"basis", "warning"} {
+		if v := value(f); v != "" {
+			p.element(indent+"  ", "formatNote", f+": "+v)
+		}
+	}
+	if err != nil {
+		p.element(indent+"  ", "formatNote", "error: "+err.Error())
+	}
+	p.close(indent, "format")
+}
+
+// element writes a premis element: if the value is empty, only the start tag is written
+func (p *premisWriter) element(indent, name, value string) {
+	if value == "" {
+		fmt.Fprintf(p.w, "%s<premis:%s>\n", indent, name)
+		return
+	}
+	fmt.Fprintf(p.w, "%s<premis:%s>%s</premis:%s>\n", indent, name, escapeXML(value), name)
+}
+
+func (p *premisWriter) close(indent, name string) {
+	fmt.Fprintf(p.w, "%s</premis:%s>\n", indent, name)
+}
+
+func (p *premisWriter) Tail() {
+	if p.mets {
+		fmt.Fprintf(p.w, "  <mets:fileSec>\n    <mets:fileGrp>\n%s    </mets:fileGrp>\n  </mets:fileSec>\n", p.fileSec.String())
+		fmt.Fprintf(p.w, "  <mets:structMap TYPE=\"physical\">\n    <mets:div TYPE=\"Directory\" LABEL=\"siegfried scan\">\n%s    </mets:div>\n  </mets:structMap>\n</mets:mets>\n", p.structs.String())
+		p.w.Flush()
+		return
+	}
+	p.element("  ", "agent", "")
+	p.element("    ", "agentIdentifier", "")
+	p.element("      ", "agentIdentifierType", "local")
+	p.element("      ", "agentIdentifierValue", "siegfried")
+	p.close("    ", "agentIdentifier")
+	p.element("    ", "agentName", "siegfried")
+	p.element("    ", "agentType", "software")
+	p.element("    ", "agentVersion", fmt.Sprintf("%d.%d.%d", p.version[0], p.version[1], p.version[2]))
+	note := "signature " + p.sig + "; scanned " + p.scanned.Format(time.RFC3339)
+	for _, d := range p.details {
+		note += "; " + d[0] + ": " + d[1]
+	}
+	p.element("    ", "agentNote", note)
+	p.close("  ", "agent")
+	p.w.WriteString("</premis:premis>\n")
+	p.w.Flush()
+}
+
+func escapeXML(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/siegfried/internal/sqlite"
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+type sqliteWriter struct {
+	db     *sqlite.DB
+	err    error
+	hh     string
+	ex     int
+	fields map[string][]string // field names for each identifier
+	cols   map[string]int      // column index in the identifications table for each identifier field
+	ncols  int
+	files  *sqlite.Table
+	ids    *sqlite.Table
+	errs   *sqlite.Table
+	hashes *sqlite.Table
+}
+
+// SQLite returns a writer that stores results in a new SQLite database. The files table has a row for each file
+// (directories are skipped), and the identifications, errors and hashes tables have rows that refer to it by file_id.
+// The identifications table has a column for each field of the identifiers in the signature file.
+// The scan and identifiers tables record the siegfried version, signature file and the name and details of each identifier.
+// The database is complete once Tail has been called; check Err for any error writing it.
+func SQLite(w io.WriteSeeker) Writer {
+	return &sqliteWriter{db: sqlite.Create(w)}
+}
+
+func (s *sqliteWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	s.hh, s.ex = hh, len(extra)
+	idents := s.db.Table("identifiers", `CREATE TABLE identifiers ("name" TEXT, "details" TEXT)`)
+	for _, id := range ids {
+		idents.Insert(id[0], id[1])
+	}
+	scan := s.db.Table("scan", `CREATE TABLE scan ("siegfried" TEXT, "signature" TEXT, "created" TEXT, "scandate" TEXT)`)
+	scan.Insert(fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2]), path, created.Format(time.RFC3339), scanned.Format(time.RFC3339))
+	cols := []string{"id INTEGER PRIMARY KEY", `"filename" TEXT`, `"filesize" INTEGER`, `"modified" TEXT`}
+	for _, e := range extra {
+		cols = append(cols, quoteIdent(e)+" TEXT")
+	}
+	s.files = s.db.Table("files", "CREATE TABLE files ("+strings.Join(cols, ", ")+")")
+	// identifiers share columns for fields with the same name (e.g. namespace, id, format)
+	s.fields, s.cols = make(map[string][]string), make(map[string]int)
+	cols = []string{`"file_id" INTEGER`}
+	for i, f := range fields {
+		if i < len(ids) {
+			s.fields[ids[i][0]] = f
+		}
+		for _, v := range f {
+			if _, ok := s.cols[v]; !ok {
+				s.cols[v] = len(cols)
+				cols = append(cols, quoteIdent(v)+" TEXT")
+			}
+		}
+	}
+	s.ncols = len(cols)
+	s.ids = s.db.Table("identifications", "CREATE TABLE identifications ("+strings.Join(cols, ", ")+")")
+	s.errs = s.db.Table("errors", `CREATE TABLE errors ("file_id" INTEGER, "error" TEXT)`)
+	s.hashes = s.db.Table("hashes", `CREATE TABLE hashes ("file_id" INTEGER, "algorithm" TEXT, "hash" TEXT)`)
+}
+
+func (s *sqliteWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	if sz < 0 || s.files == nil {
+		return
+	}
+	row := make([]interface{}, 4+s.ex)
+	row[1], row[2], row[3] = name, sz, mod
+	for i := 0; i < s.ex; i++ {
+		if i < len(extra) {
+			row[4+i] = extra[i]
+		} else {
+			row[4+i] = ""
+		}
+	}
+	fid, e := s.files.Insert(row...)
+	if e != nil {
+		return
+	}
+	if err != nil {
+		s.errs.Insert(fid, err.Error())
+	}
+	if checksum != nil {
+		s.hashes.Insert(fid, s.hh, hex.EncodeToString(checksum))
+	}
+	for _, id := range ids {
+		row = make([]interface{}, s.ncols)
+		row[0] = fid
+		values := id.Values()
+		if len(values) == 0 {
+			continue
+		}
+		fields := s.fields[values[0]]
+		for i, v := range values {
+			if i < len(fields) {
+				row[s.cols[fields[i]]] = v
+			}
+		}
+		s.ids.Insert(row...)
+	}
+}
+
+func (s *sqliteWriter) Tail() {
+	s.err = s.db.Close()
+}
+
+// Err reports any error writing the database.
+func (s *sqliteWriter) Err() error {
+	return s.err
+}
+
+// quoteIdent quotes a column name for a CREATE TABLE statement
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
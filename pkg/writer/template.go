@@ -0,0 +1,160 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// TemplateHead is the data for a template's "head" template.
+type TemplateHead struct {
+	Siegfried   string
+	Signature   string
+	Scandate    string
+	Created     string
+	Identifiers []TemplateIdentifier
+}
+
+// TemplateIdentifier describes an identifier in a TemplateHead.
+type TemplateIdentifier struct {
+	Name    string
+	Details string
+}
+
+// TemplateFile is the data a template is executed with for each file.
+type TemplateFile struct {
+	Filename string
+	Filesize int64
+	Modified string
+	Errors   string
+	Hash     string            // hex encoded checksum, if a hash is calculated
+	Extra    map[string]string // extra fields, by name
+	Matches  []TemplateMatch
+}
+
+// TemplateMatch is an identification in a TemplateFile.
+type TemplateMatch struct {
+	Namespace string
+	ID        string
+	Warning   string
+	Known     bool
+	Codes     []string          // warning codes e.g. EXTENSION_MISMATCH
+	Fields    map[string]string // the identifier's fields, by name e.g. {{.Fields.format}}
+}
+
+type templateWriter struct {
+	w      *bufio.Writer
+	t      *template.Template
+	fields map[string][]string // field names for each identifier
+	ex     []string
+	err    error
+}
+
+// Template returns a writer that executes a text/template for each file (directories are skipped) with a TemplateFile.
+// If the template defines "head" or "tail" templates, these are executed at the start (with a TemplateHead) and end of the output.
+// Check Err for any errors executing the template.
+func Template(w io.Writer, t *template.Template) Writer {
+	return &templateWriter{w: bufio.NewWriter(w), t: t}
+}
+
+func (t *templateWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	t.ex = extra
+	t.fields = make(map[string][]string, len(ids))
+	for i, f := range fields {
+		if i < len(ids) {
+			t.fields[ids[i][0]] = f
+		}
+	}
+	if t.t.Lookup("head") == nil {
+		return
+	}
+	hd := TemplateHead{
+		Siegfried:   fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2]),
+		Signature:   path,
+		Scandate:    scanned.Format(time.RFC3339),
+		Created:     created.Format(time.RFC3339),
+		Identifiers: make([]TemplateIdentifier, len(ids)),
+	}
+	for i, id := range ids {
+		hd.Identifiers[i] = TemplateIdentifier{id[0], id[1]}
+	}
+	t.execute("head", hd)
+}
+
+func (t *templateWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	if sz < 0 {
+		return
+	}
+	f := TemplateFile{
+		Filename: name,
+		Filesize: sz,
+		Modified: mod,
+		Extra:    make(map[string]string, len(t.ex)),
+		Matches:  make([]TemplateMatch, 0, len(ids)),
+	}
+	if err != nil {
+		f.Errors = err.Error()
+	}
+	if checksum != nil {
+		f.Hash = hex.EncodeToString(checksum)
+	}
+	for i, n := range t.ex {
+		if i < len(extra) {
+			f.Extra[n] = extra[i]
+		}
+	}
+	for _, id := range ids {
+		values := id.Values()
+		m := TemplateMatch{ID: id.String(), Warning: id.Warn(), Known: id.Known(), Codes: core.Codes(id)}
+		if len(values) > 0 {
+			m.Namespace = values[0]
+			names := t.fields[values[0]]
+			m.Fields = make(map[string]string, len(names))
+			for i, v := range values {
+				if i < len(names) {
+					m.Fields[names[i]] = v
+				}
+			}
+		}
+		f.Matches = append(f.Matches, m)
+	}
+	t.execute(t.t.Name(), f)
+}
+
+func (t *templateWriter) execute(name string, data interface{}) {
+	if t.err != nil {
+		return
+	}
+	t.err = t.t.ExecuteTemplate(t.w, name, data)
+}
+
+func (t *templateWriter) Tail() {
+	if t.t.Lookup("tail") != nil {
+		t.execute("tail", nil)
+	}
+	t.w.Flush()
+}
+
+// Err reports the first error executing the template.
+func (t *templateWriter) Err() error {
+	return t.err
+}
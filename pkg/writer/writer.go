@@ -18,6 +18,7 @@ import (
 	"bufio"
 	"encoding/csv"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -30,8 +31,8 @@ import (
 )
 
 type Writer interface {
-	Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string) // 	path := filepath.Base(path)
-	File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification)               // if a directory give a negative sz
+	Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) // 	path := filepath.Base(path)
+	File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string)               // if a directory give a negative sz; extra values are in the order of the extra field names given to Head
 	Tail()
 }
 
@@ -41,25 +42,143 @@ func Null() Writer {
 
 type null struct{}
 
-func (n null) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string) {
+func (n null) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
 }
-func (n null) File(name string, sz int64, mod string, cs []byte, err error, ids []core.Identification) {
+func (n null) File(name string, sz int64, mod string, cs []byte, err error, ids []core.Identification, extra []string) {
 }
 func (n null) Tail() {}
 
+// Multi returns a writer that writes to each of the given writers, so one scan can produce several outputs.
+func Multi(ws ...Writer) Writer {
+	return multi(ws)
+}
+
+type multi []Writer
+
+func (m multi) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	for _, w := range m {
+		w.Head(path, scanned, created, version, ids, fields, hh, extra)
+	}
+}
+
+func (m multi) File(name string, sz int64, mod string, cs []byte, err error, ids []core.Identification, extra []string) {
+	for _, w := range m {
+		w.File(name, sz, mod, cs, err, ids, extra)
+	}
+}
+
+func (m multi) Tail() {
+	for _, w := range m {
+		w.Tail()
+	}
+}
+
+// Err reports the first error of any of the writers that report errors.
+func (m multi) Err() error {
+	for _, w := range m {
+		if e, ok := w.(interface{ Err() error }); ok && e.Err() != nil {
+			return e.Err()
+		}
+	}
+	return nil
+}
+
+// Placeholder replaces the id and format values of unknown identifications with placeholder text (e.g. "UNKNOWN" or "fmt/0")
+// so that every result has a non-empty key. Placeholder identifications aren't Known.
+func Placeholder(ids []core.Identification, text string) []core.Identification {
+	ret := make([]core.Identification, len(ids))
+	for i, id := range ids {
+		if id.Known() {
+			ret[i] = id
+			continue
+		}
+		ret[i] = placeholder{id, text}
+	}
+	return ret
+}
+
+type placeholder struct {
+	core.Identification
+	text string
+}
+
+func (p placeholder) String() string { return p.text }
+
+func (p placeholder) Bases() []core.Basis { return core.Bases(p.Identification) }
+
+func (p placeholder) Values() []string {
+	vals := append([]string(nil), p.Identification.Values()...)
+	if len(vals) > 2 {
+		vals[1] = p.text
+		if vals[2] == "" {
+			vals[2] = p.text
+		}
+	}
+	return vals
+}
+
+// Rename replaces the format values of identifications with display names (e.g. institution-specific format names), keyed by ID.
+// Identifications whose IDs have no display name keep the format name given by the signature file.
+func Rename(ids []core.Identification, names map[string]string) []core.Identification {
+	ret := make([]core.Identification, len(ids))
+	for i, id := range ids {
+		if name, ok := names[id.String()]; ok {
+			ret[i] = renamed{id, name}
+			continue
+		}
+		ret[i] = id
+	}
+	return ret
+}
+
+type renamed struct {
+	core.Identification
+	name string
+}
+
+func (r renamed) Values() []string {
+	vals := append([]string(nil), r.Identification.Values()...)
+	if len(vals) > 2 {
+		vals[2] = r.name
+	}
+	return vals
+}
+
+func (r renamed) Bases() []core.Basis { return core.Bases(r.Identification) }
+
 type csvWriter struct {
 	recs  [][]string
 	names []string
+	ex    int // number of extra fields
 	w     *csv.Writer
+	cols  []string // selected columns, nil for the full layout
+	idx   []int    // index of each selected column in the full layout, -1 if unknown
+	out   []string
+	err   error
 }
 
 func CSV(w io.Writer) Writer {
 	return &csvWriter{w: csv.NewWriter(w)}
 }
 
-func (c *csvWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string) {
+// CSVFields returns a CSV writer that writes just the given columns, in order, rather than the full layout.
+// Columns are named as in the full header: filename, filesize, modified, errors, the hash algorithm (or "hash"),
+// extra fields and identifier fields (e.g. id, format, mime). Identifier fields are taken from the first identifier that has them,
+// or from a named identifier with a prefix e.g. loc.id. Puid is a synonym for id.
+// Unknown columns are written empty and reported by Err once Head has been called.
+func CSVFields(w io.Writer, cols []string) Writer {
+	return &csvWriter{w: csv.NewWriter(w), cols: cols}
+}
+
+// Err reports columns given to CSVFields that aren't in the output.
+func (c *csvWriter) Err() error {
+	return c.err
+}
+
+func (c *csvWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
 	c.names = make([]string, len(fields))
-	l := 4
+	c.ex = len(extra)
+	l := 4 + c.ex
 	if hh != "" {
 		l++
 	}
@@ -75,14 +194,85 @@ func (c *csvWriter) Head(path string, scanned, created time.Time, version [3]int
 		c.recs[0][4] = hh
 		idx++
 	}
-	for _, f := range fields {
+	copy(c.recs[0][idx:], extra)
+	idx += c.ex
+	starts := make([]int, len(fields))
+	for i, f := range fields {
+		starts[i] = idx
 		copy(c.recs[0][idx:], f)
 		idx += len(f)
 	}
-	c.w.Write(c.recs[0])
+	if c.cols == nil {
+		c.w.Write(c.recs[0])
+		return
+	}
+	c.idx, c.out = make([]int, len(c.cols)), make([]string, len(c.cols))
+	var unknown []string
+	for i, col := range c.cols {
+		c.idx[i] = c.column(col, starts, ids, fields, hh)
+		if c.idx[i] < 0 {
+			unknown = append(unknown, col)
+		}
+	}
+	if len(unknown) > 0 {
+		c.err = fmt.Errorf("unknown CSV fields: %s; valid fields are %s", strings.Join(unknown, ", "), strings.Join(c.recs[0], ", "))
+	}
+	c.w.Write(c.cols)
+}
+
+// column returns the index in the full layout of a selected column, or -1
+func (c *csvWriter) column(col string, starts []int, ids [][2]string, fields [][]string, hh string) int {
+	in := func(ns, field string) int {
+		if field == "puid" {
+			field = "id"
+		}
+		for i, f := range fields {
+			if ns != "" && (i >= len(ids) || ids[i][0] != ns) {
+				continue
+			}
+			for j, v := range f {
+				if v == field {
+					return starts[i] + j
+				}
+			}
+		}
+		return -1
+	}
+	if col == "hash" && hh != "" {
+		return 4
+	}
+	if len(starts) > 0 {
+		for i, v := range c.recs[0][:starts[0]] {
+			if v == col {
+				return i
+			}
+		}
+	}
+	if i := in("", col); i >= 0 {
+		return i
+	}
+	if dot := strings.Index(col, "."); dot > 0 {
+		return in(col[:dot], col[dot+1:])
+	}
+	return -1
 }
 
-func (c *csvWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification) {
+func (c *csvWriter) write(rec []string) {
+	if c.idx == nil {
+		c.w.Write(rec)
+		return
+	}
+	for i, j := range c.idx {
+		if j < 0 {
+			c.out[i] = ""
+		} else {
+			c.out[i] = rec[j]
+		}
+	}
+	c.w.Write(c.out)
+}
+
+func (c *csvWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
 	var errStr string
 	if err != nil {
 		errStr = err.Error()
@@ -93,13 +283,23 @@ func (c *csvWriter) File(name string, sz int64, mod string, checksum []byte, err
 		c.recs[0][4] = hex.EncodeToString(checksum)
 		idx++
 	}
+	if c.ex > 0 {
+		for i := 0; i < c.ex; i++ {
+			if i < len(extra) {
+				c.recs[0][idx+i] = extra[i]
+			} else {
+				c.recs[0][idx+i] = ""
+			}
+		}
+		idx += c.ex
+	}
 	if len(ids) == 0 {
 		empty := make([]string, len(c.recs[0])-idx)
 		if checksum != nil {
 			c.recs[0][4] = ""
 		}
 		copy(c.recs[0][idx:], empty)
-		c.w.Write(c.recs[0])
+		c.write(c.recs[0])
 		return
 	}
 
@@ -123,7 +323,7 @@ func (c *csvWriter) File(name string, sz int64, mod string, checksum []byte, err
 		copy(c.recs[rowIdx][colIdx:], fields)
 	}
 	for _, r := range c.recs {
-		c.w.Write(r)
+		c.write(r)
 	}
 	c.recs = c.recs[:1]
 }
@@ -135,6 +335,7 @@ type yamlWriter struct {
 	dblReplacer *strings.Replacer
 	w           *bufio.Writer
 	hh          string
+	ex          []string
 	hstrs       []string
 	vals        [][]interface{}
 }
@@ -179,8 +380,9 @@ func header(fields []string) string {
 	return "  - " + strings.Join(headings, " : %v\n    ") + " : %v\n"
 }
 
-func (y *yamlWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string) {
+func (y *yamlWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
 	y.hh = hh
+	y.ex = extra
 	y.hstrs = make([]string, len(fields))
 	y.vals = make([][]interface{}, len(fields))
 	for i, f := range fields {
@@ -198,7 +400,7 @@ func (y *yamlWriter) Head(path string, scanned, created time.Time, version [3]in
 	}
 }
 
-func (y *yamlWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification) {
+func (y *yamlWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
 	var (
 		errStr   string
 		h        string
@@ -212,6 +414,13 @@ func (y *yamlWriter) File(name string, sz int64, mod string, checksum []byte, er
 	if checksum != nil {
 		h = fmt.Sprintf("%-8s : %s\n", y.hh, hex.EncodeToString(checksum))
 	}
+	for i, n := range y.ex {
+		var v string
+		if i < len(extra) && extra[i] != "" {
+			v = "'" + y.replacer.Replace(extra[i]) + "'"
+		}
+		h += fmt.Sprintf("%-8s : %s\n", n, v)
+	}
 	if strings.ContainsAny(name, nonPrintables) {
 		fname = "\"" + y.dblReplacer.Replace(name) + "\""
 	} else {
@@ -239,9 +448,11 @@ func (y *yamlWriter) Tail() { y.w.Flush() }
 
 type jsonWriter struct {
 	subs     bool
+	bases    bool // report a structured basis for each match
 	replacer *strings.Replacer
 	w        *bufio.Writer
 	hh       string
+	ex       []string
 	hstrs    []func([]string) string
 }
 
@@ -281,6 +492,13 @@ func JSON(w io.Writer) Writer {
 	}
 }
 
+// JSONBases returns a JSON writer that also reports, for each match, its basis in a structured form (see core.Basis) as a "bases" list.
+func JSONBases(w io.Writer) Writer {
+	j := JSON(w).(*jsonWriter)
+	j.bases = true
+	return j
+}
+
 func jsonizer(fields []string) func([]string) string {
 	for i, v := range fields {
 		if v == "namespace" {
@@ -298,12 +516,17 @@ func jsonizer(fields []string) func([]string) string {
 	}
 }
 
-func (j *jsonWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string) {
+func (j *jsonWriter) head(fields [][]string, hh string, extra []string) {
 	j.hh = hh
+	j.ex = extra
 	j.hstrs = make([]func([]string) string, len(fields))
 	for i, f := range fields {
 		j.hstrs[i] = jsonizer(f)
 	}
+}
+
+func (j *jsonWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	j.head(fields, hh, extra)
 	fmt.Fprintf(j.w,
 		"{\"siegfried\":\"%d.%d.%d\",\"scandate\":\"%v\",\"signature\":\"%s\",\"created\":\"%v\",\"identifiers\":[",
 		version[0], version[1], version[2],
@@ -319,10 +542,16 @@ func (j *jsonWriter) Head(path string, scanned, created time.Time, version [3]in
 	j.w.WriteString("],\"files\":[")
 }
 
-func (j *jsonWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification) {
+func (j *jsonWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
 	if j.subs {
 		j.w.WriteString(",")
 	}
+	j.file(name, sz, mod, checksum, err, ids, extra)
+	j.subs = true
+}
+
+// file writes the JSON object for a file
+func (j *jsonWriter) file(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
 	var (
 		errStr   string
 		h        string
@@ -335,6 +564,13 @@ func (j *jsonWriter) File(name string, sz int64, mod string, checksum []byte, er
 	if checksum != nil {
 		h = fmt.Sprintf("\"%s\":\"%s\",", j.hh, hex.EncodeToString(checksum))
 	}
+	for i, n := range j.ex {
+		var v string
+		if i < len(extra) {
+			v = j.replacer.Replace(extra[i])
+		}
+		h += fmt.Sprintf("\"%s\":\"%s\",", n, v)
+	}
 	fmt.Fprintf(j.w, "{\"filename\":\"%s\",\"filesize\": %d,\"modified\":\"%s\",\"errors\": \"%s\",%s\"matches\": [", j.replacer.Replace(name), sz, mod, errStr, h)
 	for i, id := range ids {
 		if i > 0 {
@@ -345,10 +581,18 @@ func (j *jsonWriter) File(name string, sz int64, mod string, checksum []byte, er
 			idx++
 			thisName = values[0]
 		}
-		j.w.WriteString(j.hstrs[idx](values))
+		obj := j.hstrs[idx](values)
+		if j.bases {
+			bases := core.Bases(id)
+			if bases == nil {
+				bases = []core.Basis{}
+			}
+			byt, _ := json.Marshal(bases)
+			obj = obj[:len(obj)-1] + ",\"bases\":" + string(byt) + "}"
+		}
+		j.w.WriteString(obj)
 	}
 	j.w.WriteString("]}")
-	j.subs = true
 }
 
 func (j *jsonWriter) Tail() {
@@ -356,11 +600,115 @@ func (j *jsonWriter) Tail() {
 	j.w.Flush()
 }
 
+type jsonlWriter struct {
+	*jsonWriter
+}
+
+// JSONL returns a writer that emits JSON Lines: a JSON object for each file, on its own line, with the same fields as the
+// file objects in JSON output. There is no header, and each line is flushed as soon as it is written,
+// so that results can be piped to stream processors (e.g. jq) during a scan.
+func JSONL(w io.Writer) Writer {
+	return jsonlWriter{JSON(w).(*jsonWriter)}
+}
+
+// JSONLBases returns a JSON Lines writer that also reports structured bases (see JSONBases).
+func JSONLBases(w io.Writer) Writer {
+	return jsonlWriter{JSONBases(w).(*jsonWriter)}
+}
+
+func (j jsonlWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	j.head(fields, hh, extra)
+}
+
+func (j jsonlWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	j.file(name, sz, mod, checksum, err, ids, extra)
+	j.w.WriteString("\n")
+	j.w.Flush()
+}
+
+func (j jsonlWriter) Tail() { j.w.Flush() }
+
 type droidWriter struct {
 	id      int
 	parents map[string]parent
 	rec     []string
-	w       *csv.Writer
+	w       *bufio.Writer
+}
+
+type dotWriter struct {
+	replacer *strings.Replacer
+	w        *bufio.Writer
+	formats  []int          // index of the format field in each identifier's fields (-1 if none)
+	nodes    int            // count of nodes written
+	parents  map[string]int // paths of archives and their nodes
+}
+
+// DOT returns a writer that emits a Graphviz DOT graph of a scan. Each file is a node labelled by its name and
+// identification (ID and format name). Archive members are connected to the archive that contains them, so the
+// members of nested archives render as a tree, e.g. sf -z -dot DIR | dot -Tsvg > tree.svg
+func DOT(w io.Writer) Writer {
+	return &dotWriter{
+		replacer: strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n"),
+		w:        bufio.NewWriter(w),
+		parents:  make(map[string]int),
+	}
+}
+
+func (d *dotWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	d.formats = make([]int, len(fields))
+	for i, f := range fields {
+		d.formats[i] = -1
+		for j, v := range f {
+			if v == "format" {
+				d.formats[i] = j
+				break
+			}
+		}
+	}
+	fmt.Fprintf(d.w, "// siegfried %d.%d.%d; signature %s; scanned %s\ndigraph siegfried {\n  node [shape=box];\n",
+		version[0], version[1], version[2], filepath.Base(path), scanned.Format(time.RFC3339))
+}
+
+// directories (negative sz) aren't nodes: only archives have members in the graph
+func (d *dotWriter) File(name string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
+	if sz < 0 {
+		return
+	}
+	d.nodes++
+	label, parent := name, 0
+	if i := strings.LastIndex(name, "#"); i > -1 {
+		if p, ok := d.parents[name[:i]]; ok {
+			label, parent = name[i+1:], p
+		}
+	}
+	var thisName string
+	idx := -1
+	for _, id := range ids {
+		values := id.Values()
+		if values[0] != thisName {
+			idx++
+			thisName = values[0]
+		}
+		if id.Archive() > config.None {
+			d.parents[name] = d.nodes
+		}
+		label += "\n" + id.String()
+		if idx < len(d.formats) && d.formats[idx] > -1 && values[d.formats[idx]] != "" {
+			label += " (" + values[d.formats[idx]] + ")"
+		}
+	}
+	if err != nil {
+		label += "\nerror: " + err.Error()
+	}
+	fmt.Fprintf(d.w, "  n%d [label=\"%s\"];\n", d.nodes, d.replacer.Replace(label))
+	if parent > 0 {
+		fmt.Fprintf(d.w, "  n%d -> n%d;\n", parent, d.nodes)
+	}
+}
+
+func (d *dotWriter) Tail() {
+	d.w.WriteString("}\n")
+	d.w.Flush()
 }
 
 type parent struct {
@@ -373,25 +721,46 @@ func Droid(w io.Writer) Writer {
 	return &droidWriter{
 		parents: make(map[string]parent),
 		rec:     make([]string, 18),
-		w:       csv.NewWriter(w),
+		w:       bufio.NewWriter(w),
 	}
 }
 
+// DROID profile exports quote every field, except for empty values in the METHOD, EXT, HASH and PUID columns:
+// these are null, rather than empty, in a DROID profile
+var droidNulls = [18]bool{5: true, 9: true, 12: true, 14: true}
+
+func (d *droidWriter) write(rec []string) {
+	for i, v := range rec {
+		if i > 0 {
+			d.w.WriteByte(',')
+		}
+		if v == "" && droidNulls[i] {
+			continue
+		}
+		d.w.WriteByte('"')
+		d.w.WriteString(strings.ReplaceAll(v, `"`, `""`))
+		d.w.WriteByte('"')
+	}
+	d.w.WriteByte('\n')
+}
+
 // "identifier", "id", "format name", "format version", "mimetype", "basis", "warning"
-func (d *droidWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string) {
-	if hh == "" {
-		hh = "no"
+func (d *droidWriter) Head(path string, scanned, created time.Time, version [3]int, ids [][2]string, fields [][]string, hh string, extra []string) {
+	hh = strings.ToUpper(hh) + "_HASH"
+	if hh == "_HASH" {
+		hh = "HASH"
 	}
-	d.w.Write([]string{
+	d.write([]string{
 		"ID", "PARENT_ID", "URI", "FILE_PATH", "NAME",
 		"METHOD", "STATUS", "SIZE", "TYPE", "EXT",
-		"LAST_MODIFIED", "EXTENSION_MISMATCH", strings.ToUpper(hh) + "_HASH", "FORMAT_COUNT",
+		"LAST_MODIFIED", "EXTENSION_MISMATCH", hh, "FORMAT_COUNT",
 		"PUID", "MIME_TYPE", "FORMAT_NAME", "FORMAT_VERSION"})
 }
 
-func (d *droidWriter) File(p string, sz int64, mod string, checksum []byte, err error, ids []core.Identification) {
+// extra fields aren't part of the DROID profile format so are ignored
+func (d *droidWriter) File(p string, sz int64, mod string, checksum []byte, err error, ids []core.Identification, extra []string) {
 	d.id++
-	d.rec[0], d.rec[6], d.rec[10] = strconv.Itoa(d.id), "Done", mod
+	d.rec[0], d.rec[6], d.rec[10] = strconv.Itoa(d.id), "Done", droidTime(mod)
 	if err != nil {
 		d.rec[6] = err.Error()
 	}
@@ -408,7 +777,7 @@ func (d *droidWriter) File(p string, sz int64, mod string, checksum []byte, err
 			d.rec[8], d.rec[11] = "", ""
 			d.rec[3] = clearArchivePath(d.rec[2], d.rec[3])
 		}
-		d.w.Write(d.rec)
+		d.write(d.rec)
 		return
 	}
 	// size
@@ -420,10 +789,15 @@ func (d *droidWriter) File(p string, sz int64, mod string, checksum []byte, err
 	}
 	// leave early for unknowns
 	if len(ids) < 1 || !ids[0].Known() {
-		d.rec[5], d.rec[8], d.rec[11], d.rec[13] = "", "File", "FALSE", "0"
+		d.rec[5], d.rec[8], d.rec[11], d.rec[13] = "", "File", "false", "0"
 		d.rec[14], d.rec[15], d.rec[16], d.rec[17] = "", "", "", ""
+		if len(ids) > 0 {
+			if p, ok := ids[0].(placeholder); ok {
+				d.rec[14], d.rec[16] = p.text, p.text
+			}
+		}
 		d.rec[3] = clearArchivePath(d.rec[2], d.rec[3])
-		d.w.Write(d.rec)
+		d.write(d.rec)
 		return
 	}
 	d.rec[13] = strconv.Itoa(len(ids))
@@ -438,7 +812,7 @@ func (d *droidWriter) File(p string, sz int64, mod string, checksum []byte, err
 		d.rec[5], d.rec[11] = getMethod(fields[len(fields)-2]), mismatch(fields[len(fields)-1])
 		d.rec[14], d.rec[15], d.rec[16], d.rec[17] = fields[1], fields[4], fields[2], fields[3]
 		d.rec[3] = clearArchivePath(d.rec[2], d.rec[3])
-		d.w.Write(d.rec)
+		d.write(d.rec)
 	}
 }
 
@@ -454,7 +828,11 @@ func (d *droidWriter) processPath(p string) (parent, uri, path, name, ext string
 		parent = strconv.Itoa(par.id)
 		uri = toUri(par.uri, par.archive, escape(name))
 	} else {
-		puri := "file:/" + escape(filepath.ToSlash(dir))
+		// DROID URIs have a single slash after the scheme, e.g. file:/home/richard and file:/C:/Users
+		puri := "file:"
+		if d := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(dir), "/"), "/"); d != "" {
+			puri += "/" + escape(d)
+		}
 		uri = toUri(puri, "", escape(name))
 	}
 	ext = strings.TrimPrefix(filepath.Ext(p), ".")
@@ -532,7 +910,16 @@ func getMethod(basis string) string {
 
 func mismatch(warning string) string {
 	if strings.Contains(warning, "extension mismatch") {
-		return "TRUE"
+		return "true"
+	}
+	return "false"
+}
+
+// DROID reports last modified times in local time, without a time zone
+func droidTime(mod string) string {
+	t, err := time.Parse(time.RFC3339, mod)
+	if err != nil {
+		return mod
 	}
-	return "FALSE"
+	return t.Format("2006-01-02T15:04:05")
 }
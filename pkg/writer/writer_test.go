@@ -18,11 +18,14 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/richardlehane/siegfried/pkg/config"
@@ -75,11 +78,11 @@ var nonControlCharacters = []string{"\u0020", "\u1F5A4", "\u265B", "\u1F0A1",
 func TestControlCharacters(t *testing.T) {
 	buf := &bytes.Buffer{}
 	js := JSON(buf)
-	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "")
+	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
 	// Loop through the control characters to make sure the JSON output
 	// is valid.
 	for _, val := range controlCharacters {
-		js.File(fmt.Sprintf("path/%sto/file", val), 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}})
+		js.File(fmt.Sprintf("path/%sto/file", val), 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}}, nil)
 	}
 	js.Tail()
 	if !json.Valid(buf.Bytes()) {
@@ -92,11 +95,11 @@ func TestControlCharacters(t *testing.T) {
 func TestNonControlCharacters(t *testing.T) {
 	buf := &bytes.Buffer{}
 	js := JSON(buf)
-	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "")
+	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
 	// Loop through the non control characters to make sure the JSON output
 	// is valid.
 	for _, val := range nonControlCharacters {
-		js.File(fmt.Sprintf("path/%sto/file", val), 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}})
+		js.File(fmt.Sprintf("path/%sto/file", val), 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}}, nil)
 	}
 	js.Tail()
 	if !json.Valid(buf.Bytes()) {
@@ -115,8 +118,8 @@ func TestYAMLHeader(t *testing.T) {
 func TestYAMLMultilineString(t *testing.T) {
 	buf := &bytes.Buffer{}
 	yml := YAML(buf)
-	yml.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "")
-	yml.File("example.\ndoc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}})
+	yml.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	yml.File("example.\ndoc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}}, nil)
 	yml.Tail()
 	expect :=
 		`---
@@ -157,27 +160,109 @@ matches  :
 	}
 }
 
+// TestCSVExtra ensures that extra fields are written after the hash
+// and before the identification fields.
+func TestCSVExtra(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := CSV(buf)
+	c.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5", []string{"members", "methods"})
+	c.File("example.zip", 1, "2015-05-24T16:59:13+10:00", []byte{0xd4, 0x1d}, nil, []core.Identification{testID{}}, []string{"2", "store, deflate"})
+	c.Tail()
+	expect := `filename,filesize,modified,errors,md5,members,methods,namespace,id,format,version,mime,basis,warning
+example.zip,1,2015-05-24T16:59:13+10:00,,d41d,2,"store, deflate",pronom,fmt/43,JPEG File Interchange Format,1.01,image/jpeg,extension match jpg; byte match at [[[0 14]] [[75201 2]]],
+`
+	if ret := buf.String(); ret != expect {
+		t.Errorf("Expecting return: %s\nGot: %s", expect, ret)
+	}
+}
+
+func TestCSVFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := CSVFields(buf, []string{"filename", "puid", "md5", "pronom.mime", "methods"})
+	c.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5", []string{"members", "methods"})
+	if err := c.(interface{ Err() error }).Err(); err != nil {
+		t.Fatal(err)
+	}
+	c.File("example.zip", 1, "2015-05-24T16:59:13+10:00", []byte{0xd4, 0x1d}, nil, []core.Identification{testID{}}, []string{"2", "store, deflate"})
+	c.Tail()
+	expect := `filename,puid,md5,pronom.mime,methods
+example.zip,fmt/43,d41d,image/jpeg,"store, deflate"
+`
+	if ret := buf.String(); ret != expect {
+		t.Errorf("Expecting return: %s\nGot: %s", expect, ret)
+	}
+	c = CSVFields(&bytes.Buffer{}, []string{"filename", "loc.id"})
+	c.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	if err := c.(interface{ Err() error }).Err(); err == nil {
+		t.Error("expecting an error for an unknown field")
+	}
+}
+
+type unknownID struct{}
+
+func (u unknownID) String() string          { return "UNKNOWN" }
+func (u unknownID) Known() bool             { return false }
+func (u unknownID) Warn() string            { return "no match" }
+func (u unknownID) Values() []string        { return []string{"pronom", "UNKNOWN", "", "", "", "", "no match"} }
+func (u unknownID) Archive() config.Archive { return 0 }
+
+// TestPlaceholder ensures that unknowns are reported with placeholder values but remain unknown.
+func TestPlaceholder(t *testing.T) {
+	ids := Placeholder([]core.Identification{unknownID{}, testID{}}, "fmt/0")
+	if ids[0].Known() || ids[0].String() != "fmt/0" || !ids[1].Known() {
+		t.Fatalf("bad placeholder identifications: %v", ids)
+	}
+	buf := &bytes.Buffer{}
+	c := CSV(buf)
+	c.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	c.File("example.xyz", 1, "2015-05-24T16:59:13+10:00", nil, nil, ids[:1], nil)
+	c.Tail()
+	expect := "example.xyz,1,2015-05-24T16:59:13+10:00,,pronom,fmt/0,fmt/0,,,,no match\n"
+	if ret := strings.SplitN(buf.String(), "\n", 2)[1]; ret != expect {
+		t.Errorf("Expecting: %sGot: %s", expect, ret)
+	}
+}
+
 // TestDroidHeader ensures that the DROID header is output consistently
 // and matches the DROID CSV specification.
 func TestDroidHeader(t *testing.T) {
 	buf := &bytes.Buffer{}
 	droid := Droid(buf)
-	droid.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5")
+	droid.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5", nil)
 	droid.Tail()
 	// DROID identification result isn't tested here as the paths output
 	// are absolute and require a bit of finessing in SF to get right.
-	expected := `ID,PARENT_ID,URI,FILE_PATH,NAME,METHOD,STATUS,SIZE,TYPE,EXT,LAST_MODIFIED,EXTENSION_MISMATCH,MD5_HASH,FORMAT_COUNT,PUID,MIME_TYPE,FORMAT_NAME,FORMAT_VERSION`
+	expected := `"ID","PARENT_ID","URI","FILE_PATH","NAME","METHOD","STATUS","SIZE","TYPE","EXT","LAST_MODIFIED","EXTENSION_MISMATCH","MD5_HASH","FORMAT_COUNT","PUID","MIME_TYPE","FORMAT_NAME","FORMAT_VERSION"`
 	res := strings.Trim(buf.String(), "\n")
 	if res != expected {
 		t.Errorf("DROID output didn't output as expected: \n'%s' got: \n'%s'", res, expected)
 	}
 }
 
+// TestDroidFile checks file and folder rows against the quoting, date and boolean conventions of DROID profile exports
+func TestDroidFile(t *testing.T) {
+	buf := &bytes.Buffer{}
+	droid := Droid(buf)
+	droid.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	droid.File("/data", -1, "2015-08-30T21:43:29+10:00", nil, nil, nil, nil)
+	droid.File("/data/example one.jpg", 320, "2015-01-21T03:13:44+10:00", nil, nil, []core.Identification{testID{}}, nil)
+	droid.Tail()
+	expect := []string{
+		`"ID","PARENT_ID","URI","FILE_PATH","NAME","METHOD","STATUS","SIZE","TYPE","EXT","LAST_MODIFIED","EXTENSION_MISMATCH","HASH","FORMAT_COUNT","PUID","MIME_TYPE","FORMAT_NAME","FORMAT_VERSION"`,
+		`"1","","file:/data/","/data","data",,"Done","","Folder",,"2015-08-30T21:43:29","false",,"",,"","",""`,
+		`"2","1","file:/data/example%20one.jpg","/data/example one.jpg","example one.jpg","Signature","Done","320","File","jpg","2015-01-21T03:13:44","false",,"1","fmt/43","image/jpeg","JPEG File Interchange Format","1.01"`,
+		"",
+	}
+	if res := strings.Split(buf.String(), "\n"); strings.Join(res, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("DROID output didn't output as expected: \n%s\ngot: \n%s", strings.Join(expect, "\n"), strings.Join(res, "\n"))
+	}
+}
+
 func ExampleYAML() {
 	yml := YAML(ioutil.Discard)
-	yml.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "")
+	yml.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
 	yml.(*yamlWriter).w = bufio.NewWriter(os.Stdout)
-	yml.File("example.doc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}})
+	yml.File("example.doc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}}, nil)
 	yml.Tail()
 	// Output:
 	// ---
@@ -197,10 +282,209 @@ func ExampleYAML() {
 
 func ExampleJSON() {
 	js := JSON(ioutil.Discard)
-	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "")
+	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
 	js.(*jsonWriter).w = bufio.NewWriter(os.Stdout)
-	js.File("example.doc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}})
+	js.File("example.doc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}}, nil)
 	js.Tail()
 	// Output:
 	// {"filename":"example.doc","filesize": 1,"modified":"2015-05-24T16:59:13+10:00","errors": "mscfb: bad OLE","matches": [{"ns":"pronom","id":"fmt/43","format":"JPEG File Interchange Format","version":"1.01","mime":"image/jpeg","basis":"extension match jpg; byte match at [[[0 14]] [[75201 2]]]","warning":""}]}]}
 }
+
+func ExampleJSONL() {
+	js := JSONL(os.Stdout)
+	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5", nil)
+	js.File("example.doc", 1, "2015-05-24T16:59:13+10:00", []byte{0xd4, 0x1d}, nil, []core.Identification{testID{}}, nil)
+	js.File("example2.doc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{testID{}}, nil)
+	js.Tail()
+	// Output:
+	// {"filename":"example.doc","filesize": 1,"modified":"2015-05-24T16:59:13+10:00","errors": "","md5":"d41d","matches": [{"ns":"pronom","id":"fmt/43","format":"JPEG File Interchange Format","version":"1.01","mime":"image/jpeg","basis":"extension match jpg; byte match at [[[0 14]] [[75201 2]]]","warning":""}]}
+	// {"filename":"example2.doc","filesize": 1,"modified":"2015-05-24T16:59:13+10:00","errors": "mscfb: bad OLE","matches": [{"ns":"pronom","id":"fmt/43","format":"JPEG File Interchange Format","version":"1.01","mime":"image/jpeg","basis":"extension match jpg; byte match at [[[0 14]] [[75201 2]]]","warning":""}]}
+}
+
+func ExampleTemplate() {
+	t := template.Must(template.New("example").Parse(`{{define "head"}}{{.Signature}}
+{{end}}{{.Filename}}	{{.Hash}}{{range .Matches}}	{{.ID}}	{{.Fields.format}}{{end}}
+`))
+	w := Template(os.Stdout, t)
+	w.Head("default.sig", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5", nil)
+	w.File("dir", -1, "", nil, nil, nil, nil)
+	w.File("example.doc", 1, "2015-05-24T16:59:13+10:00", []byte{0xd4, 0x1d}, nil, []core.Identification{testID{}}, nil)
+	w.Tail()
+	// Output:
+	// default.sig
+	// example.doc	d41d	fmt/43	JPEG File Interchange Format
+}
+
+func TestFido(t *testing.T) {
+	buf := &bytes.Buffer{}
+	f := Fido(buf)
+	f.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	f.File("dir", -1, "", nil, nil, nil, nil)
+	f.File("example one.jpg", 320, "2015-05-24T16:59:13+10:00", nil, nil, []core.Identification{testID{}}, nil)
+	f.File("example.doc", 1, "2015-05-24T16:59:13+10:00", nil, testErr{}, []core.Identification{unknownID{}}, nil)
+	f.Tail()
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expecting two lines, got %q", buf.String())
+	}
+	// the second field is an elapsed time
+	for i, expect := range [][2]string{
+		{"OK,", `,fmt/43,"JPEG File Interchange Format","JPEG File Interchange Format",320,"example one.jpg","image/jpeg","signature"`},
+		{"KO,", `,,,,1,"example.doc",,"fail"`},
+	} {
+		if !strings.HasPrefix(lines[i], expect[0]) || !strings.HasSuffix(lines[i], expect[1]) {
+			t.Errorf("expecting %s<time>%s, got %s", expect[0], expect[1], lines[i])
+		}
+	}
+}
+
+func TestMulti(t *testing.T) {
+	a, b := &bytes.Buffer{}, &bytes.Buffer{}
+	m := Multi(CSV(a), JSONL(b))
+	m.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	m.File("example.doc", 1, "2015-05-24T16:59:13+10:00", nil, nil, []core.Identification{testID{}}, nil)
+	m.Tail()
+	if !strings.Contains(a.String(), "example.doc,1,") || !strings.Contains(b.String(), `"filename":"example.doc"`) {
+		t.Errorf("expecting both writers to write the file, got:\n%s\n%s", a.String(), b.String())
+	}
+	if err := m.(interface{ Err() error }).Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+type testArc struct{ testID }
+
+func (t testArc) Archive() config.Archive { return config.Zip }
+
+func TestDOT(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := DOT(buf)
+	d.Head("default.sig", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	d.File("dir/example.zip", 1, "", nil, nil, []core.Identification{testArc{}}, nil)
+	d.File("dir/example.zip#sub/\"quoted\".jpg", 1, "", nil, testErr{}, []core.Identification{testID{}}, nil)
+	d.File("dir/other.jpg", 1, "", nil, nil, []core.Identification{testID{}}, nil)
+	d.Tail()
+	expect := `// siegfried 0.0.0; signature default.sig; scanned 0001-01-01T00:00:00Z
+digraph siegfried {
+  node [shape=box];
+  n1 [label="dir/example.zip\nfmt/43 (JPEG File Interchange Format)"];
+  n2 [label="sub/\"quoted\".jpg\nfmt/43 (JPEG File Interchange Format)\nerror: mscfb: bad OLE"];
+  n1 -> n2;
+  n3 [label="dir/other.jpg\nfmt/43 (JPEG File Interchange Format)"];
+}
+`
+	if ret := buf.String(); ret != expect {
+		t.Errorf("Expecting return: %s\nGot: %s", expect, ret)
+	}
+}
+
+func TestRename(t *testing.T) {
+	ids := Rename([]core.Identification{testID{}}, map[string]string{"fmt/43": "JPEG (JFIF)", "fmt/44": "JPEG 1.02"})
+	if vals := ids[0].Values(); vals[2] != "JPEG (JFIF)" || vals[1] != "fmt/43" || testValues[2] != "JPEG File Interchange Format" {
+		t.Errorf("bad rename, got %v", vals)
+	}
+	if ids = Rename([]core.Identification{testID{}}, map[string]string{"fmt/44": "JPEG 1.02"}); ids[0].Values()[2] != testValues[2] {
+		t.Errorf("expecting the signature's format name, got %v", ids[0].Values())
+	}
+}
+
+func TestPREMIS(t *testing.T) {
+	for _, mets := range []bool{false, true} {
+		buf := &bytes.Buffer{}
+		p := PREMIS(buf, mets)
+		p.Head("default.sig", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5", nil)
+		p.File("dir", -1, "", nil, nil, nil, nil)
+		p.File("dir/example & co.jpg", 1, "", []byte{0xd4, 0x1d}, testErr{}, []core.Identification{testID{}}, nil)
+		p.Tail()
+		// check that the output is well-formed
+		dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+		for {
+			if _, err := dec.Token(); err != nil {
+				if err != io.EOF {
+					t.Fatalf("bad XML (mets: %t): %v\n%s", mets, err, buf.String())
+				}
+				break
+			}
+		}
+		ret := buf.String()
+		for _, expect := range []string{
+			"<premis:objectIdentifierValue>dir/example &amp; co.jpg</premis:objectIdentifierValue>",
+			"<premis:messageDigestAlgorithm>MD5</premis:messageDigestAlgorithm>",
+			"<premis:messageDigest>d41d</premis:messageDigest>",
+			"<premis:formatName>JPEG File Interchange Format</premis:formatName>",
+			"<premis:formatVersion>1.01</premis:formatVersion>",
+			"<premis:formatRegistryName>PRONOM</premis:formatRegistryName>",
+			"<premis:formatRegistryKey>fmt/43</premis:formatRegistryKey>",
+			"<premis:formatNote>error: mscfb: bad OLE</premis:formatNote>",
+		} {
+			if !strings.Contains(ret, expect) {
+				t.Errorf("expecting %s in output (mets: %t):\n%s", expect, mets, ret)
+			}
+		}
+		if strings.Count(ret, "<premis:object ") != 1 {
+			t.Errorf("expecting a single object (mets: %t), got:\n%s", mets, ret)
+		}
+		if mets && !strings.Contains(ret, `<mets:file ID="file_1" ADMID="amd_1" SIZE="1" CHECKSUM="d41d" CHECKSUMTYPE="MD5">`) {
+			t.Errorf("expecting a METS file entry, got:\n%s", ret)
+		}
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	f, err := os.CreateTemp("", "sf*.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	s := SQLite(f)
+	s.Head("default.sig", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "md5", nil)
+	s.File("dir", -1, "", nil, nil, nil, nil)
+	s.File("dir/example.jpg", 1, "2015-05-24T16:59:13+10:00", []byte{0xd4, 0x1d}, testErr{}, []core.Identification{testID{}}, nil)
+	s.Tail()
+	if err := s.(interface{ Err() error }).Err(); err != nil {
+		t.Fatal(err)
+	}
+	byt, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(byt, []byte("SQLite format 3\x00")) {
+		t.Fatal("expecting an SQLite header")
+	}
+	// the database stores text values as is
+	for _, expect := range []string{"CREATE TABLE identifications", "dir/example.jpg", "d41d", "mscfb: bad OLE", "fmt/43", "JPEG File Interchange Format"} {
+		if !bytes.Contains(byt, []byte(expect)) {
+			t.Errorf("expecting %q in the database", expect)
+		}
+	}
+}
+
+type basesID struct{ testID }
+
+func (b basesID) Bases() []core.Basis { return core.ParseBases(core.SplitBasis(testValues[5])) }
+
+func TestJSONBases(t *testing.T) {
+	buf := &bytes.Buffer{}
+	js := JSONBases(buf)
+	js.Head("", time.Time{}, time.Time{}, [3]int{}, [][2]string{{"pronom", ""}}, [][]string{makeFields()}, "", nil)
+	js.File("example.jpg", 1, "2015-05-24T16:59:13+10:00", nil, nil, []core.Identification{Rename([]core.Identification{basesID{}}, nil)[0], testID{}}, nil)
+	js.Tail()
+	var res struct {
+		Files []struct {
+			Matches []struct {
+				Bases []core.Basis
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, buf.String())
+	}
+	m := res.Files[0].Matches
+	if len(m) != 2 || len(m[0].Bases) != 2 || m[0].Bases[1].Matcher != "byte" || len(m[0].Bases[1].Hits) != 2 || m[0].Bases[1].Hits[1].Offset != 75201 {
+		t.Errorf("unexpected bases: %s", buf.String())
+	}
+	if m[1].Bases == nil || len(m[1].Bases) != 0 {
+		t.Errorf("expecting an empty list of bases for an identification without them: %s", buf.String())
+	}
+}
@@ -34,26 +34,25 @@
 package siegfried
 
 import (
+	"context"
 	"bytes"
 	"compress/flate"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"runtime"
 	"strings"
 	"time"
 
 	"github.com/richardlehane/siegfried/internal/bytematcher"
 	"github.com/richardlehane/siegfried/internal/containermatcher"
-	"github.com/richardlehane/siegfried/internal/mimematcher"
-	"github.com/richardlehane/siegfried/internal/namematcher"
 	"github.com/richardlehane/siegfried/internal/persist"
-	"github.com/richardlehane/siegfried/internal/riffmatcher"
 	"github.com/richardlehane/siegfried/internal/siegreader"
-	"github.com/richardlehane/siegfried/internal/textmatcher"
-	"github.com/richardlehane/siegfried/internal/xmlmatcher"
 	"github.com/richardlehane/siegfried/pkg/config"
 	"github.com/richardlehane/siegfried/pkg/core"
+	"github.com/richardlehane/siegfried/pkg/custom"
 	"github.com/richardlehane/siegfried/pkg/loc"
 	"github.com/richardlehane/siegfried/pkg/mimeinfo"
 	"github.com/richardlehane/siegfried/pkg/pronom"
@@ -66,11 +65,16 @@ var ( // for side effect - register their patterns/ signature loaders
 	_ = pronom.Range{}
 	_ = mimeinfo.Int8(0)
 	_ = loc.Identifier{}
+	_ = custom.Identifier{}
 
 	// Is this what we want to do here..?
 	_ = wikidata.Identifier{}
 )
 
+// ErrMaxBytes is returned, with the identification results, when the bytematcher stopped scanning a file at
+// the max bytes limit (see config.SetMaxBytes) without being satisfied: a signature further into the file may have matched.
+var ErrMaxBytes = errors.New("stopped scanning at the max bytes limit: result may be affected")
+
 // Siegfried structs are persisent objects that can be serialised to disk and
 // used to identify file formats.
 // They contain three matchers as well as a slice of identifiers. When identifiers
@@ -85,8 +89,11 @@ type Siegfried struct {
 	rm core.Matcher // riffmatcher
 	bm core.Matcher // bytematcher
 	tm core.Matcher // textmatcher
+	// set if loaded from an indexed signature file: the matchers and identifiers are decoded on first use (see decode)
+	lazy *lazy
 	// mutatable fields
 	ids     []core.Identifier // identifiers
+	extras  []Extra           // custom fields (see AddExtra)
 	buffers *siegreader.Buffers
 }
 
@@ -112,6 +119,9 @@ func New() *Siegfried {
 
 // Add adds an identifier to a Siegfried struct.
 func (s *Siegfried) Add(i core.Identifier) error {
+	if err := s.decodeAll(); err != nil {
+		return err
+	}
 	for _, v := range s.ids {
 		if v.Name() == i.Name() {
 			return fmt.Errorf("siegfried: identifiers must have unique names, you already have an identifier named %s. Use the -name flag to assign a new name e.g. `roy add -name richard`", i.Name())
@@ -156,26 +166,24 @@ func (s *Siegfried) Save(path string) error {
 	return f.Close()
 }
 
-// SaveWriter persists a Siegfried struct to an io.Writer
+// SaveWriter persists a Siegfried struct to an io.Writer.
+// The signature file is compressed, unless config.SetIndexed is set.
 func (s *Siegfried) SaveWriter(w io.Writer) error {
+	if err := s.decodeAll(); err != nil {
+		return err
+	}
 	// sprinkle magic
 	_, err := w.Write(append(config.Magic(), byte(config.Version()[0]), byte(config.Version()[1])))
 	if err != nil {
 		return err
 	}
+	if config.Indexed() {
+		return s.saveIndexed(w)
+	}
 	// persist the siegfried
 	ls := persist.NewLoadSaver(nil)
-	ls.SaveTime(s.C)
-	namematcher.Save(s.nm, ls)
-	mimematcher.Save(s.mm, ls)
-	containermatcher.Save(s.cm, ls)
-	xmlmatcher.Save(s.xm, ls)
-	riffmatcher.Save(s.rm, ls)
-	bytematcher.Save(s.bm, ls)
-	textmatcher.Save(s.tm, ls)
-	ls.SaveTinyUInt(len(s.ids))
-	for _, i := range s.ids {
-		i.Save(ls)
+	for i := 0; i < numSecs; i++ {
+		s.saveSection(i, ls)
 	}
 	if ls.Err != nil {
 		return ls.Err
@@ -192,29 +200,49 @@ func (s *Siegfried) SaveWriter(w io.Writer) error {
 	return z.Close()
 }
 
-// Load creates a Siegfried struct and loads content from path
+// Load creates a Siegfried struct and loads content from path.
+// An indexed signature file (see config.SetIndexed) is memory mapped, where the platform allows, and is unmapped once the Siegfried
+// is garbage collected: don't overwrite it in place while it is loaded.
 func Load(path string) (*Siegfried, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("siegfried: error opening signature file, got %v; try running `sf -update`", err)
 	}
-	sf, err := LoadReader(f)
+	fbuf, unmap, err := mapFile(f)
+	if err != nil {
+		sf, err := LoadReader(f)
+		if err != nil {
+			return nil, err
+		}
+		return sf, f.Close()
+	}
+	sf, err := parse(fbuf)
+	if err != nil || sf.lazy == nil { // compressed signature files are inflated in full, so don't need the mapping
+		unmap()
+	} else {
+		sf.lazy.unmap = unmap
+		runtime.SetFinalizer(sf.lazy, func(l *lazy) { l.unmap() })
+	}
 	if err != nil {
 		return nil, err
 	}
 	return sf, f.Close()
-
 }
 
 // LoadReader creates a Siegfried struct and loads content from a reader
 func LoadReader(r io.Reader) (*Siegfried, error) {
-	errReading := "siegfried: error reading signature file, got %v; try running `sf -update`"
-	errNotSig := "siegfried: not a siegfried signature file; try running `sf -update`"
-	errUpdateSig := "siegfried: signature file is incompatible with this version of sf; try running `sf -update`"
 	fbuf, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	return parse(fbuf)
+}
+
+// parse checks the magic and version of a signature file, then loads it: in full, if it is compressed,
+// or just its index, if it is indexed
+func parse(fbuf []byte) (*Siegfried, error) {
+	errNotSig := "siegfried: not a siegfried signature file; try running `sf -update`"
+	errUpdateSig := "siegfried: signature file is incompatible with this version of sf; try running `sf -update`"
 	if len(fbuf) < len(config.Magic())+2 {
 		return nil, fmt.Errorf(errNotSig)
 	}
@@ -224,7 +252,10 @@ func LoadReader(r io.Reader) (*Siegfried, error) {
 	if major, minor := fbuf[len(config.Magic())], fbuf[len(config.Magic())+1]; major < byte(config.Version()[0]) || (major == byte(config.Version()[0]) && minor < byte(config.Version()[1])) {
 		return nil, fmt.Errorf(errUpdateSig)
 	}
-	rb := bytes.NewBuffer(fbuf[len(config.Magic())+2:])
+	if fbuf = fbuf[len(config.Magic())+2:]; len(fbuf) > 0 && fbuf[0] == indexMarker {
+		return loadIndexed(fbuf[1:])
+	}
+	rb := bytes.NewBuffer(fbuf)
 	rc := flate.NewReader(rb)
 	buf, err := ioutil.ReadAll(rc)
 	rc.Close()
@@ -236,28 +267,18 @@ func LoadReader(r io.Reader) (*Siegfried, error) {
 
 func load(buf []byte) (*Siegfried, error) {
 	ls := persist.NewLoadSaver(buf)
-	return &Siegfried{
-		C:  ls.LoadTime(),
-		nm: namematcher.Load(ls),
-		mm: mimematcher.Load(ls),
-		cm: containermatcher.Load(ls),
-		xm: xmlmatcher.Load(ls),
-		rm: riffmatcher.Load(ls),
-		bm: bytematcher.Load(ls),
-		tm: textmatcher.Load(ls),
-		ids: func() []core.Identifier {
-			ids := make([]core.Identifier, ls.LoadTinyUInt())
-			for i := range ids {
-				ids[i] = core.LoadIdentifier(ls)
-			}
-			return ids
-		}(),
-		buffers: siegreader.New(),
-	}, ls.Err
+	s := &Siegfried{buffers: siegreader.New()}
+	for i := 0; i < numSecs; i++ {
+		s.loadSection(i, ls)
+	}
+	return s, ls.Err
 }
 
 // Identifiers returns a slice of the names and details of each identifier.
 func (s *Siegfried) Identifiers() [][2]string {
+	if s.decode(idsSec) != nil {
+		return nil
+	}
 	ret := make([][2]string, len(s.ids))
 	for i, v := range s.ids {
 		ret[i][0] = v.Name()
@@ -268,6 +289,9 @@ func (s *Siegfried) Identifiers() [][2]string {
 
 // Fields returns a slice of the names of the fields in each identifier.
 func (s *Siegfried) Fields() [][]string {
+	if s.decode(idsSec) != nil {
+		return nil
+	}
 	ret := make([][]string, len(s.ids))
 	for i, v := range s.ids {
 		ret[i] = v.Fields()
@@ -275,12 +299,32 @@ func (s *Siegfried) Fields() [][]string {
 	return ret
 }
 
-// Buffer gets a siegreader buffer from the pool
+// Formats returns a slice of the format IDs (e.g. PUIDs) known to each identifier.
+// The slice is nil for identifiers that can't list their formats.
+func (s *Siegfried) Formats() [][]string {
+	if s.decode(idsSec) != nil {
+		return nil
+	}
+	ret := make([][]string, len(s.ids))
+	for i, v := range s.ids {
+		if f, ok := v.(interface{ Formats() []string }); ok {
+			ret[i] = f.Formats()
+		}
+	}
+	return ret
+}
+
+// Buffer gets a siegreader buffer from the pool.
+// If extra fields are registered and none of them is Full, a stream too big to buffer in memory keeps only a window
+// of its last bytes (rather than being copied to a temp file): see siegreader.Buffer's Window.
 func (s *Siegfried) Buffer(r io.Reader) (*siegreader.Buffer, error) {
 	buffer, err := s.buffers.Get(r)
 	if err == io.EOF {
 		err = nil
 	}
+	if buffer != nil && s.windowed() {
+		buffer.Window = true
+	}
 	return buffer, err
 }
 
@@ -315,10 +359,35 @@ func satisfied(mt core.MatcherType, recs []core.Recorder) (bool, []core.Hint) {
 }
 
 // IdentifyBuffer identifies a siegreader buffer. Supply the error from Get as the second argument.
+// The identification is cancelled if the buffer's context is done (see IdentifyBufferContext).
 func (s *Siegfried) IdentifyBuffer(buffer *siegreader.Buffer, err error, name, mime string) ([]core.Identification, error) {
+	ctx := context.Background()
+	if buffer != nil {
+		ctx = buffer.Context()
+	}
+	return s.IdentifyBufferContext(ctx, buffer, err, name, mime)
+}
+
+// IdentifyBufferContext identifies a siegreader buffer, stopping early if the context is cancelled or its deadline passes.
+// The context is set on the buffer, so that matchers stop reading it. A cancelled identification returns the context's error
+// and no identifications; the buffer can then be returned to the pool with Put.
+func (s *Siegfried) IdentifyBufferContext(ctx context.Context, buffer *siegreader.Buffer, err error, name, mime string) ([]core.Identification, error) {
+	return s.identify(ctx, buffer, err, name, mime, nil)
+}
+
+// identify runs the matchers. If prov is given, and the buffer asks for a provisional result, prov is called with the identifications
+// made once the bytematcher has scanned the start of the buffer.
+func (s *Siegfried) identify(ctx context.Context, buffer *siegreader.Buffer, err error, name, mime string, prov func([]core.Identification)) ([]core.Identification, error) {
 	if err != nil && err != siegreader.ErrEmpty {
 		return nil, fmt.Errorf("siegfried: error reading file; got %v", err)
 	}
+	if cerr := ctx.Err(); cerr != nil {
+		return nil, cerr
+	}
+	buffer.SetContext(ctx)
+	if derr := s.decode(idsSec, nameSec, mimeSec, containerSec, xmlSec, riffSec, byteSec, textSec); derr != nil {
+		return nil, derr
+	}
 	recs := make([]core.Recorder, len(s.ids))
 	for i, v := range s.ids {
 		recs[i] = v.Recorder()
@@ -337,28 +406,7 @@ func (s *Siegfried) IdentifyBuffer(buffer *siegreader.Buffer, err error, name, m
 	if config.Debug() || config.Slow() {
 		fmt.Fprintf(config.Out(), "[FILE] %s\n", name)
 	}
-	// Name Matcher
-	if len(name) > 0 && s.nm != nil {
-		nms, _ := s.nm.Identify(name, nil) // we don't care about an error here
-		for v := range nms {
-			for _, rec := range recs {
-				if rec.Record(core.NameMatcher, v) {
-					break
-				}
-			}
-		}
-	}
-	// MIME Matcher
-	if len(mime) > 0 && s.mm != nil {
-		mms, _ := s.mm.Identify(mime, nil) // we don't care about an error here
-		for v := range mms {
-			for _, rec := range recs {
-				if rec.Record(core.MIMEMatcher, v) {
-					break
-				}
-			}
-		}
-	}
+	s.matchName(recs, name, mime)
 	// Container Matcher
 	_, hints := satisfied(core.ContainerMatcher, recs)
 	if s.cm != nil {
@@ -366,82 +414,133 @@ func (s *Siegfried) IdentifyBuffer(buffer *siegreader.Buffer, err error, name, m
 			fmt.Fprintln(config.Out(), ">>START CONTAINER MATCHER")
 		}
 		cms, cerr := s.cm.Identify(name, buffer, hints...)
-		for v := range cms {
-			for _, rec := range recs {
-				if rec.Record(core.ContainerMatcher, v) {
-					break
-				}
-			}
-		}
+		record(ctx, core.ContainerMatcher, cms, recs, nil)
 		if err == nil {
 			err = cerr
 		}
 	}
 	sat, _ := satisfied(core.XMLMatcher, recs)
 	// XML Matcher
-	if s.xm != nil && !sat {
+	if s.xm != nil && !sat && ctx.Err() == nil {
 		if config.Debug() {
 			fmt.Fprintln(config.Out(), ">>START XML MATCHER")
 		}
 		xms, xerr := s.xm.Identify("", buffer)
-		for v := range xms {
-			for _, rec := range recs {
-				if rec.Record(core.XMLMatcher, v) {
-					break
-				}
-			}
-		}
+		record(ctx, core.XMLMatcher, xms, recs, nil)
 		if err == nil {
 			err = xerr
 		}
 	}
 	sat, _ = satisfied(core.RIFFMatcher, recs)
 	// RIFF Matcher
-	if s.rm != nil && !sat {
+	if s.rm != nil && !sat && ctx.Err() == nil {
 		if config.Debug() {
 			fmt.Fprintln(config.Out(), ">>START RIFF MATCHER")
 		}
 		rms, rerr := s.rm.Identify("", buffer)
-		for v := range rms {
-			for _, rec := range recs {
-				if rec.Record(core.RIFFMatcher, v) {
-					break
-				}
-			}
-		}
+		record(ctx, core.RIFFMatcher, rms, recs, nil)
 		if err == nil {
 			err = rerr
 		}
 	}
 	sat, hints = satisfied(core.ByteMatcher, recs)
 	// Byte Matcher
-	if s.bm != nil && !sat {
+	if s.bm != nil && !sat && ctx.Err() == nil {
 		if config.Debug() {
 			fmt.Fprintln(config.Out(), ">>START BYTE MATCHER")
 		}
 		ids, _ := s.bm.Identify("", buffer, hints...) // we don't care about an error here
-		for v := range ids {
+		record(ctx, core.ByteMatcher, ids, recs, prov)
+		if buffer.Limited && err == nil {
+			err = ErrMaxBytes
+		}
+	}
+	sat, _ = satisfied(core.TextMatcher, recs)
+	// Text Matcher
+	if s.tm != nil && !sat && ctx.Err() == nil {
+		ids, _ := s.tm.Identify("", buffer) // we don't care about an error here
+		record(ctx, core.TextMatcher, ids, recs, nil)
+	}
+	if cerr := ctx.Err(); cerr != nil {
+		return nil, cerr
+	}
+	return report(recs), err
+}
+
+// record passes a matcher's results to the recorders. Once the context is done, results are drained (so that the matcher can finish)
+// but not recorded. A core.Provisional result calls prov (if given) with the identifications so far.
+func record(ctx context.Context, mt core.MatcherType, res chan core.Result, recs []core.Recorder, prov func([]core.Identification)) {
+	for v := range res {
+		if ctx.Err() != nil {
+			continue
+		}
+		if _, ok := v.(core.Provisional); ok {
+			if prov != nil {
+				prov(report(recs))
+			}
+			continue
+		}
+		for _, rec := range recs {
+			if rec.Record(mt, v) {
+				break
+			}
+		}
+	}
+}
+
+// IdentifyName identifies a file by its name and MIME type alone, without reading its content: only the name (extension)
+// and MIME matchers are run. This is a fast, but unreliable, pre-classification: results are identified on name or MIME only.
+// Set config.SetNameOnly, otherwise identifiers rule out name matches for formats with byte signatures (as if those signatures had failed to match).
+func (s *Siegfried) IdentifyName(name, mime string) []core.Identification {
+	if s.decode(idsSec, nameSec, mimeSec) != nil {
+		return nil
+	}
+	recs := make([]core.Recorder, len(s.ids))
+	for i, v := range s.ids {
+		recs[i] = v.Recorder()
+		if name != "" {
+			recs[i].Active(core.NameMatcher)
+		}
+		if mime != "" {
+			recs[i].Active(core.MIMEMatcher)
+		}
+	}
+	if config.Debug() || config.Slow() {
+		fmt.Fprintf(config.Out(), "[FILE] %s\n", name)
+	}
+	s.matchName(recs, name, mime)
+	return report(recs)
+}
+
+// run the name and MIME matchers
+func (s *Siegfried) matchName(recs []core.Recorder, name, mime string) {
+	// Name Matcher
+	if len(name) > 0 && s.nm != nil {
+		nms, _ := s.nm.Identify(name, nil) // we don't care about an error here
+		for v := range nms {
 			for _, rec := range recs {
-				if rec.Record(core.ByteMatcher, v) {
+				if rec.Record(core.NameMatcher, v) {
 					break
 				}
 			}
 		}
 	}
-	sat, _ = satisfied(core.TextMatcher, recs)
-	// Text Matcher
-	if s.tm != nil && !sat {
-		ids, _ := s.tm.Identify("", buffer) // we don't care about an error here
-		for v := range ids {
+	// MIME Matcher
+	if len(mime) > 0 && s.mm != nil {
+		mms, _ := s.mm.Identify(mime, nil) // we don't care about an error here
+		for v := range mms {
 			for _, rec := range recs {
-				if rec.Record(core.TextMatcher, v) {
+				if rec.Record(core.MIMEMatcher, v) {
 					break
 				}
 			}
 		}
 	}
+}
+
+func report(recs []core.Recorder) []core.Identification {
 	if len(recs) < 2 {
-		return recs[0].Report(), err
+		return recs[0].Report()
 	}
 	var res []core.Identification
 	for idx, rec := range recs {
@@ -456,16 +555,21 @@ func (s *Siegfried) IdentifyBuffer(buffer *siegreader.Buffer, err error, name, m
 		}
 		res = append(res, rec.Report()...)
 	}
-	return res, err
+	return res
 }
 
 // Identify identifies a stream or file object.
 // It takes an io.Reader and the name and mimetype of the file/stream (if unknown, give empty strings).
 // It returns a slice of identifications and an error.
 func (s *Siegfried) Identify(r io.Reader, name, mime string) ([]core.Identification, error) {
+	return s.IdentifyContext(context.Background(), r, name, mime)
+}
+
+// IdentifyContext is like Identify, but stops early if the context is cancelled or its deadline passes (returning the context's error).
+func (s *Siegfried) IdentifyContext(ctx context.Context, r io.Reader, name, mime string) ([]core.Identification, error) {
 	buffer, err := s.Buffer(r)
 	defer s.buffers.Put(buffer)
-	return s.IdentifyBuffer(buffer, err, name, mime)
+	return s.IdentifyBufferContext(ctx, buffer, err, name, mime)
 }
 
 // Label takes the values of a core.Identification and returns a slice that pairs these values with the
@@ -488,6 +592,9 @@ func (s *Siegfried) Label(id core.Identification) [][2]string {
 // tree index. It can be used when identifying in a debug mode to check which identification results trigger
 // which strikes.
 func (s *Siegfried) Blame(idx, ct int, cn string) string {
+	if err := s.decode(idsSec, containerSec, byteSec); err != nil {
+		return err.Error()
+	}
 	toID := func(i int, typ core.MatcherType) string {
 		for _, id := range s.ids {
 			if ok, str := id.Recognise(typ, i); ok {
@@ -553,6 +660,9 @@ func (s *Siegfried) Blame(idx, ct int, cn string) string {
 
 // Inspect returns a string containing detail about the various matchers in the Siegfried struct.
 func (s *Siegfried) Inspect(t core.MatcherType) string {
+	if err := s.decodeAll(); err != nil {
+		return err.Error()
+	}
 	switch t {
 	case core.ByteMatcher:
 		if s.bm != nil {
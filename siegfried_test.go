@@ -2,6 +2,13 @@ package siegfried
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/richardlehane/siegfried/internal/persist"
@@ -40,6 +47,47 @@ func TestIdentify(t *testing.T) {
 	}
 }
 
+// cancelMatcher cancels the identification while it is reading the buffer
+type cancelMatcher struct {
+	cancel func()
+	read   *error
+}
+
+func (t cancelMatcher) Identify(nm string, sb *siegreader.Buffer, hints ...core.Hint) (chan core.Result, error) {
+	t.cancel()
+	_, *t.read = siegreader.ReaderFrom(sb).ReadByte()
+	ret := make(chan core.Result)
+	go func() {
+		ret <- testResult(0)
+		close(ret)
+	}()
+	return ret, nil
+}
+
+func (t cancelMatcher) String() string { return "" }
+
+func TestIdentifyContext(t *testing.T) {
+	s := New()
+	s.nm = testEMatcher{}
+	s.cm = nil
+	s.ids = append(s.ids, testIdentifier{})
+	ctx, cancel := context.WithCancel(context.Background())
+	var read error
+	s.bm = cancelMatcher{cancel, &read}
+	c, err := s.IdentifyContext(ctx, bytes.NewBufferString("test"), "test.doc", "")
+	if err != context.Canceled || c != nil {
+		t.Errorf("expecting a cancelled identification, got %v, %v", c, err)
+	}
+	if read != io.EOF {
+		t.Errorf("expecting reads of the buffer to stop once cancelled, got %v", read)
+	}
+	// an identification that is cancelled before it starts doesn't run the matchers
+	read = nil
+	if _, err = s.IdentifyContext(ctx, bytes.NewBufferString("test"), "test.doc", ""); err != context.Canceled || read != nil {
+		t.Errorf("expecting a cancelled identification, got %v", err)
+	}
+}
+
 func TestLabel(t *testing.T) {
 	s := &Siegfried{ids: []core.Identifier{testIdentifier{}}}
 	res := s.Label(testIdentification{})
@@ -52,6 +100,56 @@ func TestLabel(t *testing.T) {
 	}
 }
 
+func TestExtra(t *testing.T) {
+	s := New()
+	first := func(c Content, ids []core.Identification) string {
+		buf, _ := c.Slice(0, 4)
+		return string(buf) + ids[0].String()
+	}
+	beyond := func(c Content, ids []core.Identification) string {
+		if _, err := c.Slice(WindowSize, 1); err == nil {
+			return "full"
+		}
+		return "window"
+	}
+	if err := s.AddExtra(Extra{Name: "first", Fn: first}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddExtra(Extra{Name: "first", Fn: first}); err == nil {
+		t.Error("expecting an error for a duplicate extra field name")
+	}
+	s.AddExtra(Extra{Name: "window", Fn: beyond})
+	s.AddExtra(Extra{Name: "full", Full: true, Fn: beyond})
+	if names := s.ExtraFields(); len(names) != 3 || names[0] != "first" || names[2] != "full" {
+		t.Errorf("bad extra field names, got %v", names)
+	}
+	buf, err := s.Buffer(bytes.NewReader(make([]byte, WindowSize*2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Put(buf)
+	ex := s.Extra(buf, []core.Identification{testIdentification{}})
+	if len(ex) != 3 || ex[0] != "\x00\x00\x00\x00fmt/3" || ex[1] != "window" || ex[2] != "full" {
+		t.Errorf("bad extra field values, got %q", ex)
+	}
+	// only if all the extras are limited to windows can big streams be windowed, rather than copied to temp files
+	if buf.Window {
+		t.Error("expecting a full buffer with a Full extra")
+	}
+	w := New()
+	nbuf, _ := w.Buffer(bytes.NewReader([]byte("abc")))
+	if nbuf.Window {
+		t.Error("expecting a full buffer without extras")
+	}
+	w.Put(nbuf)
+	w.AddExtra(Extra{Name: "window", Fn: beyond})
+	wbuf, _ := w.Buffer(bytes.NewReader([]byte("abc")))
+	defer w.Put(wbuf)
+	if !wbuf.Window {
+		t.Error("expecting a windowed buffer when no extra is Full")
+	}
+}
+
 // extension matcher test stub
 
 type testEMatcher struct{}
@@ -123,3 +221,260 @@ func (t testIdentification) Warn() string            { return "" }
 func (t testIdentification) Known() bool             { return true }
 func (t testIdentification) Values() []string        { return []string{"a", "fmt/3"} }
 func (t testIdentification) Archive() config.Archive { return 0 }
+
+func TestValidate(t *testing.T) {
+	s := New()
+	config.SetHome("./cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	pdf := "%PDF-1.4\n1 0 obj\n<<>>\nendobj\ntrailer\n<<>>\n%%EOF\n"
+	for _, v := range []struct {
+		content, name, claim string
+		outcome              Outcome
+	}{
+		{pdf, "", "fmt/18", Confirmed},
+		{pdf, "", "application/pdf", Confirmed},
+		{pdf, "", "fmt/19", Mismatch},
+		{"\x00\x01\x02\x03", "test.pdf", "fmt/18", Indeterminate},
+	} {
+		verdict, err := s.Validate(bytes.NewBufferString(v.content), v.name, v.claim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verdict.Outcome != v.outcome {
+			t.Errorf("validating %s: expecting %s, got %s (%s)", v.claim, v.outcome, verdict.Outcome, verdict.Reason)
+		}
+	}
+}
+
+func TestIdentifyStream(t *testing.T) {
+	s := New()
+	config.SetHome("./cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	pdf := "%PDF-1.4\n1 0 obj\n<<>>\nendobj\n"
+	tail := "trailer\n<<>>\n%%EOF\n"
+	large := pdf + strings.Repeat(" ", WindowSize) + tail
+	for _, v := range []struct {
+		content string
+		calls   int
+	}{
+		{pdf + tail, 1},
+		{large, 2},
+	} {
+		var got []Result
+		res := s.IdentifyStream(strings.NewReader(v.content), "", "", func(r Result) { got = append(got, r) })
+		if len(got) != v.calls {
+			t.Fatalf("expecting %d results, got %d", v.calls, len(got))
+		}
+		if res.Err != nil || res.Provisional || got[len(got)-1].Provisional {
+			t.Errorf("bad final result: %v", res)
+		}
+		if v.calls == 2 && (!got[0].Provisional || len(got[0].IDs) == 0) {
+			t.Errorf("bad provisional result: %v", got[0])
+		}
+		if len(res.IDs) == 0 || res.IDs[0].String() != "fmt/18" {
+			t.Errorf("expecting fmt/18, got %v", res.IDs)
+		}
+	}
+}
+
+func TestIdentifyStreamEOF(t *testing.T) {
+	s := New()
+	config.SetHome("./cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	// a PDF's EOF marker ends the first WindowSize bytes, but not the stream: it isn't a PDF, even provisionally
+	pdf := "%PDF-1.4\n1 0 obj\n<<>>\nendobj\n"
+	tail := "trailer\n<<>>\n%%EOF\n"
+	content := pdf + strings.Repeat(" ", WindowSize-len(pdf)-len(tail)) + tail + strings.Repeat("x", WindowSize)
+	var got []Result
+	res := s.IdentifyStream(strings.NewReader(content), "", "", func(r Result) { got = append(got, r) })
+	if len(got) != 2 || !got[0].Provisional {
+		t.Fatalf("expecting a provisional and a final result, got %v", got)
+	}
+	for _, r := range got {
+		if len(r.IDs) == 0 || r.IDs[0].String() == "fmt/18" {
+			t.Errorf("expecting no fmt/18 result, got %v (provisional: %v)", r.IDs, r.Provisional)
+		}
+	}
+	if res.Provisional || len(res.IDs) == 0 || res.IDs[0].String() == "fmt/18" {
+		t.Errorf("bad final result: %v", res)
+	}
+}
+
+func TestIdentifyName(t *testing.T) {
+	s := New()
+	config.SetHome("./cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	// without name-only mode, extension matches for formats with byte signatures are ruled out
+	ids := s.IdentifyName("missing/file.pdf", "")
+	if len(ids) != 1 || ids[0].Known() || !strings.Contains(ids[0].Warn(), "fmt/18") {
+		t.Errorf("expecting an unknown result with fmt/18 as a possibility, got %v", ids)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	s := New()
+	config.SetHome("./cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	config.SetMaxBytes(1024)
+	defer config.SetMaxBytes(0)
+	pdf := "%PDF-1.4\n1 0 obj\n<<>>\nendobj\n"
+	// signatures anchored to the EOF are still checked
+	ids, _ := s.Identify(strings.NewReader(pdf+strings.Repeat(" ", 4096)+"%%EOF\n"), "", "")
+	if len(ids) != 1 || ids[0].String() != "fmt/18" {
+		t.Errorf("expecting fmt/18, got %v", ids)
+	}
+	// an unknown smaller than the limit was scanned in full
+	if _, err = s.Identify(strings.NewReader(strings.Repeat("x", 512)), "", ""); err != nil {
+		t.Errorf("expecting no error for a file within the limit, got %v", err)
+	}
+	// an unknown larger than the limit may have matched further in
+	if _, err = s.Identify(strings.NewReader(strings.Repeat("x", 4096)), "", ""); err != ErrMaxBytes {
+		t.Errorf("expecting ErrMaxBytes, got %v", err)
+	}
+}
+
+func TestNearMisses(t *testing.T) {
+	s := New()
+	config.SetHome("./cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	// a PDF that is missing its %%EOF trailer
+	buf, err := s.Buffer(bytes.NewBufferString("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Put(buf)
+	for _, m := range s.NearMisses(buf) {
+		if m.ID == "pronom: fmt/18" {
+			if m.Score != 0.5 || m.Basis != "matched 1 of 2 segments" {
+				t.Errorf("bad near miss for fmt/18, got %v", m)
+			}
+			return
+		}
+	}
+	t.Error("expecting fmt/18 to be a near miss")
+}
+
+func TestEmbedded(t *testing.T) {
+	s := New()
+	config.SetHome("./cmd/roy/data")
+	p, err := pronom.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	jpg := append([]byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"), bytes.Repeat([]byte{0}, 100)...)
+	jpg = append(jpg, 0xFF, 0xD9)
+	sample := append(bytes.Repeat([]byte("junk"), 50), jpg...)
+	sample = append(sample, []byte("PK\x03\x04 local header; PK\x03\x04 another")...)
+	buf, _ := s.Buffer(bytes.NewReader(sample))
+	defer s.Put(buf)
+	if offs := embeddedOffsets(buf); len(offs) != 2 || offs[0] != 200 || offs[1] != 322 {
+		t.Errorf("expecting candidate offsets of the JPEG and first ZIP magic, got %v", offs)
+	}
+	es := s.Embedded(buf)
+	if len(es) != 2 || es[0] != (Embedded{200, "pronom: fmt/43"}) || es[1] != (Embedded{322, "pronom: x-fmt/263"}) {
+		t.Errorf("expecting a JPEG at offset 200 and a ZIP at offset 322, got %v", es)
+	}
+}
+
+func TestIndexed(t *testing.T) {
+	s, err := Load("./cmd/roy/data/default.sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(append(config.Magic(), byte(config.Version()[0]), byte(config.Version()[1])))
+	if err = s.saveIndexed(buf); err != nil {
+		t.Fatal(err)
+	}
+	indexed := buf.Bytes()
+	path := filepath.Join(t.TempDir(), "indexed.sig")
+	if err = os.WriteFile(path, indexed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.lazy == nil || l.bm != nil || l.ids != nil || !l.C.Equal(s.C) {
+		t.Fatal("expecting only the create time to be decoded on load")
+	}
+	if got := l.IdentifyName("file.pdf", ""); len(got) != 1 || l.bm != nil {
+		t.Errorf("expecting a name-only identification without decoding the bytematcher, got %v", got)
+	}
+	pdf := "%PDF-1.4\n1 0 obj\n<<>>\nendobj\n%%EOF\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids, err := l.Identify(strings.NewReader(pdf), "", "")
+			if err != nil || len(ids) != 1 || ids[0].String() != "fmt/18" {
+				t.Errorf("expecting fmt/18, got %v (%v)", ids, err)
+			}
+		}()
+	}
+	wg.Wait()
+	// resaving decodes the rest and gives the same signature file
+	rebuf := &bytes.Buffer{}
+	if err = l.SaveWriter(rebuf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadReader(rebuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.lazy != nil || r.Inspect(core.ByteMatcher) != s.Inspect(core.ByteMatcher) || len(r.Identifiers()) != len(s.Identifiers()) {
+		t.Error("expecting a resaved indexed signature file to match the original")
+	}
+	// the identifiers are the last section: shorten it to just their count
+	bad := append([]byte(nil), indexed...)
+	binary.LittleEndian.PutUint32(bad[len(config.Magic())+2+1+4*idsSec:], 1)
+	b, err := LoadReader(bytes.NewReader(bad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.Identify(strings.NewReader(pdf), "", ""); err == nil || b.Identifiers() != nil {
+		t.Error("expecting an error decoding a corrupt section")
+	}
+	if _, err = LoadReader(bytes.NewReader(indexed[:len(indexed)-1])); err == nil {
+		t.Error("expecting an error loading a truncated signature file")
+	}
+}
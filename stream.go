@@ -0,0 +1,59 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package siegfried
+
+import (
+	"context"
+	"io"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// Result is a result reported by IdentifyStream.
+type Result struct {
+	IDs []core.Identification
+	Err error
+	// Provisional results are based on the start of a stream only: they are reported once the bytematcher has scanned the start of
+	// the stream, and before it reads to the end to test EOF signatures. They may differ from the final result: formats with EOF
+	// signatures can't be matched until the whole stream has been read.
+	Provisional bool
+}
+
+// IdentifyStream identifies a stream, sending results to fn as they become available.
+// If the stream is larger than WindowSize, and isn't identified from its start alone, a provisional result is sent (with Provisional set)
+// once the bytematcher has scanned the start of the stream. The stream is identified once, and the final result is always sent last.
+// IdentifyStream returns the final result.
+//
+// Example:
+//  res := s.IdentifyStream(r, "large.tif", "", func(res siegfried.Result) {
+//  	if res.Provisional {
+//  		fmt.Println("probably", res.IDs[0])
+//  	}
+//  })
+func (s *Siegfried) IdentifyStream(r io.Reader, name, mime string, fn func(Result)) Result {
+	buffer, err := s.Buffer(r)
+	defer s.Put(buffer)
+	if err == nil && buffer.Stream() {
+		if ok, _ := buffer.CanSeek(WindowSize, false); ok {
+			buffer.Provisional = true
+		}
+	}
+	ids, err := s.identify(context.Background(), buffer, err, name, mime, func(ids []core.Identification) {
+		fn(Result{ids, nil, true})
+	})
+	res := Result{ids, err, false}
+	fn(res)
+	return res
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package siegfried
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/richardlehane/siegfried/pkg/core"
+)
+
+// Outcome is the result of validating a file against a claimed format.
+type Outcome int
+
+const (
+	Confirmed     Outcome = iota // the content matches the claimed format
+	Mismatch                     // the content matches a different format
+	Indeterminate                // the content couldn't be identified, or was identified by filename or MIME only
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Confirmed:
+		return "confirmed"
+	case Mismatch:
+		return "mismatch"
+	}
+	return "indeterminate"
+}
+
+// Verdict is returned by Validate.
+type Verdict struct {
+	Outcome Outcome
+	Reason  string                // describes the outcome e.g. "claimed fmt/19 but content matches fmt/276"
+	IDs     []core.Identification // the identification results the verdict is based on
+}
+
+// Accept reports whether the file should be accepted i.e. the claimed format is confirmed.
+func (v Verdict) Accept() bool {
+	return v.Outcome == Confirmed
+}
+
+// basis strings that show a match is based on the content of a file, rather than its name or MIME type
+var contentBasis = []string{"byte match", "container match", "xml match", "riff match", "text match"}
+
+// Validate identifies a stream and checks whether it matches a claimed format, given as a format ID (e.g. a PUID) or a MIME type.
+// The name of the file is optional: if given, it is used for identification but a match on name alone won't confirm a claim.
+// A claim is confirmed if any identifier matches the claimed format based on content. It is a mismatch if
+// identifiers match the content to other formats only. Otherwise the verdict is indeterminate.
+// The returned error is any error encountered reading the stream; a Verdict is returned regardless.
+func (s *Siegfried) Validate(r io.Reader, name, claim string) (Verdict, error) {
+	ids, err := s.Identify(r, name, "")
+	v := Verdict{Outcome: Indeterminate, IDs: ids}
+	if ids == nil {
+		v.Reason = "unable to identify content"
+		return v, err
+	}
+	var mismatches []string
+	for _, id := range ids {
+		if !id.Known() {
+			continue
+		}
+		var mime, basis string
+		hasBasis := false
+		for _, kv := range s.Label(id) {
+			switch kv[0] {
+			case "mime":
+				mime = kv[1]
+			case "basis":
+				basis, hasBasis = kv[1], true
+			}
+		}
+		if hasBasis && !byContent(basis) {
+			continue
+		}
+		if id.String() == claim || matchMIME(mime, claim) {
+			v.Outcome = Confirmed
+			v.Reason = fmt.Sprintf("content matches claimed format %s", claim)
+			return v, err
+		}
+		mismatches = append(mismatches, id.String())
+	}
+	if len(mismatches) > 0 {
+		v.Outcome = Mismatch
+		v.Reason = fmt.Sprintf("claimed %s but content matches %s", claim, strings.Join(mismatches, ", "))
+		return v, err
+	}
+	v.Reason = fmt.Sprintf("content could not be matched to %s, or any other format, by its bytes", claim)
+	return v, err
+}
+
+func byContent(basis string) bool {
+	for _, b := range contentBasis {
+		if strings.Contains(basis, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// MIME fields may hold a comma separated list
+func matchMIME(mime, claim string) bool {
+	if mime == "" || claim == "" {
+		return false
+	}
+	for _, m := range strings.Split(mime, ",") {
+		if strings.EqualFold(strings.TrimSpace(m), claim) {
+			return true
+		}
+	}
+	return false
+}